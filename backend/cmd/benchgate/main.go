@@ -0,0 +1,131 @@
+// Command benchgate compares two `go test -bench` outputs and fails when a
+// benchmark got slower (or allocates more) than the allowed threshold.
+//
+//	benchgate [-threshold 0.2] old.txt new.txt
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type samples struct {
+	nsPerOp     []float64
+	allocsPerOp []float64
+}
+
+func main() {
+	threshold := flag.Float64("threshold", 0.2, "allowed relative slowdown, e.g. 0.2 = 20%")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		log.Fatal("usage: benchgate [-threshold 0.2] old.txt new.txt")
+	}
+
+	oldRuns, err := readBench(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	newRuns, err := readBench(flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	names := make([]string, 0, len(newRuns))
+	for name := range newRuns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failed := false
+	for _, name := range names {
+		before, ok := oldRuns[name]
+		if !ok {
+			fmt.Printf("%-60s new benchmark\n", name)
+			continue
+		}
+		after := newRuns[name]
+		timeDelta := relDelta(median(before.nsPerOp), median(after.nsPerOp))
+		allocDelta := relDelta(median(before.allocsPerOp), median(after.allocsPerOp))
+
+		status := "ok"
+		if timeDelta > *threshold || allocDelta > *threshold {
+			status = "REGRESSION"
+			failed = true
+		}
+		fmt.Printf("%-60s time %+6.1f%%  allocs %+6.1f%%  %s\n", name, timeDelta*100, allocDelta*100, status)
+	}
+
+	if failed {
+		fmt.Printf("benchmarks regressed by more than %.0f%%\n", *threshold*100)
+		os.Exit(1)
+	}
+}
+
+// readBench collects ns/op and allocs/op of every benchmark line in path.
+func readBench(path string) (map[string]*samples, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	runs := make(map[string]*samples)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		// Drop the -GOMAXPROCS suffix so runs on different machines match.
+		name := fields[0]
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i]
+			}
+		}
+		s := runs[name]
+		if s == nil {
+			s = &samples{}
+			runs[name] = s
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				s.nsPerOp = append(s.nsPerOp, v)
+			case "allocs/op":
+				s.allocsPerOp = append(s.allocsPerOp, v)
+			}
+		}
+	}
+	return runs, scanner.Err()
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func relDelta(before, after float64) float64 {
+	if before == 0 {
+		return 0
+	}
+	return (after - before) / before
+}
@@ -0,0 +1,164 @@
+// Command desktop runs NormoControl as a single-user application on a laptop
+// without network access: the API server, the SQLite database and (optionally)
+// the built frontend are served from one process bound to the local machine,
+// and the browser that opened the link printed at startup is logged in as the
+// bootstrap admin.
+// Results are carried to the central server with the admin sync export/import.
+//
+//	desktop [-addr 127.0.0.1:8090] [-data DIR] [-web frontend/dist]
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"github.com/Ullyminat/NormoControl/backend/internal/auth"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/handlers"
+	"github.com/Ullyminat/NormoControl/backend/internal/server"
+	"github.com/Ullyminat/NormoControl/backend/internal/settings"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8090", "listen address; must be a loopback address")
+	dataDir := flag.String("data", defaultDataDir(), "directory for the database and uploaded files")
+	webDir := flag.String("web", "", "directory with the built frontend (frontend/dist) to serve")
+	flag.Parse()
+
+	if _, _, err := net.SplitHostPort(*addr); err != nil {
+		log.Fatalf("Invalid -addr: %v", err)
+	}
+	if !isLocalHost(*addr) {
+		log.Fatalf("The desktop build only listens on the local machine, got %s", *addr)
+	}
+
+	if *webDir != "" {
+		abs, err := filepath.Abs(*webDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*webDir = abs
+	}
+
+	// Handlers store uploads relative to the working directory.
+	if err := os.MkdirAll(*dataDir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(*dataDir); err != nil {
+		log.Fatal(err)
+	}
+
+	// Tokens are only needed by other clients of the API; a fresh secret per run
+	// is enough when nothing else is configured.
+	if os.Getenv("JWT_SECRET") == "" {
+		os.Setenv("JWT_SECRET", randomSecret())
+	}
+
+	database.InitDBAt(filepath.Join(*dataDir, "academic.db"))
+	if err := settings.Load(); err != nil {
+		log.Printf("Failed to load settings: %v", err)
+	}
+
+	adminID, err := database.BootstrapAdminID()
+	if err != nil {
+		log.Fatalf("Local account not found: %v", err)
+	}
+	// Other programs and web pages can reach the port too: only the browser
+	// that opens the printed link gets the session.
+	sessionSecret := randomSecret()
+	auth.EnableLocalMode(adminID, "admin", sessionSecret)
+	handlers.StartUploadJanitor()
+
+	r := server.NewRouter()
+	r.GET("/api/local/session", auth.LocalSession)
+	if *webDir != "" {
+		serveFrontend(r, *webDir)
+	}
+
+	log.Printf("NormoControl desktop: open http://%s/api/local/session?token=%s (data in %s)", *addr, sessionSecret, *dataDir)
+	if err := http.ListenAndServe(*addr, localOnly(r)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func randomSecret() string {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatal(err)
+	}
+	return hex.EncodeToString(secret)
+}
+
+// localOnly rejects requests for another host name, as sent by a page whose
+// domain was rebound to 127.0.0.1, and requests from pages of other origins.
+func localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := isLocalHost(r.Host)
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			allowed = allowed && err == nil && isLocalHost(u.Host)
+		}
+		if !allowed {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(gin.H{"error": "Only requests from the local machine are allowed"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLocalHost reports whether hostport names the local machine.
+func isLocalHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func defaultDataDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "normocontrol-data"
+	}
+	return filepath.Join(dir, "normocontrol")
+}
+
+// serveFrontend serves the single-page app from dir, answering unknown paths
+// outside /api with index.html so client-side routes work on reload.
+func serveFrontend(r *gin.Engine, dir string) {
+	files := http.Dir(dir)
+	fileServer := http.FileServer(files)
+	r.NoRoute(func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if strings.HasPrefix(path, "/api/") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		if f, err := files.Open(path); err == nil {
+			stat, statErr := f.Stat()
+			f.Close()
+			if statErr == nil && !stat.IsDir() {
+				fileServer.ServeHTTP(c.Writer, c.Request)
+				return
+			}
+		}
+		c.File(filepath.Join(dir, "index.html"))
+	})
+}
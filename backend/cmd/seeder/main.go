@@ -1,8 +1,8 @@
 package main
 
 import (
-	"academic-check-sys/internal/database"
 	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
 	"log"
 	"math/rand"
 	"time"
@@ -132,7 +132,7 @@ func seedResults() {
 		failedRules := totalRules - passedRules
 		procTime := 100 + rand.Intn(400) // 100-500ms
 
-		_, err := stmt.Exec(documentID, standardID, checkDate, score, totalRules, passedRules, failedRules, procTime)
+		_, err := stmt.Exec(documentID, standardID, database.Timestamp(checkDate), score, totalRules, passedRules, failedRules, procTime)
 		if err != nil {
 			log.Println("Error inserting result:", err)
 		}
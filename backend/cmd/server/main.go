@@ -1,16 +1,17 @@
 package main
 
 import (
-	"academic-check-sys/internal/auth"
-	"academic-check-sys/internal/database"
-	"academic-check-sys/internal/handlers"
-	"academic-check-sys/internal/middleware"
+	"github.com/Ullyminat/NormoControl/backend/internal/convert"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/handlers"
+	"github.com/Ullyminat/NormoControl/backend/internal/redis"
+	"github.com/Ullyminat/NormoControl/backend/internal/reporting"
+	"github.com/Ullyminat/NormoControl/backend/internal/server"
+	"github.com/Ullyminat/NormoControl/backend/internal/settings"
 	"log"
 	"os"
 
-	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -22,124 +23,33 @@ func main() {
 	// Initialize Database
 	database.InitDB()
 
-	r := gin.Default()
-	// Increase Max Multipart Memory for uploads
-	r.MaxMultipartMemory = 100 << 20 // 100 MiB
-
-	// Initialize Rate Limiters
-	// Global: 50 req/sec, burst of 100
-	globalLimiter := middleware.NewIPRateLimiter(50, 100)
-	// Auth routes (Login/Register): 2 req/sec, burst of 5 (Anti-Bruteforce)
-	authLimiter := middleware.NewIPRateLimiter(2, 5)
-	// AI verification is expensive: 6 req/min per IP with a small burst.
-	aiLimiter := middleware.NewIPRateLimiter(0.1, 3)
-
-	// Apply Global Rate Limiting
-	r.Use(middleware.RateLimitMiddleware(globalLimiter))
-
-	// Security Headers & CORS Middleware
-	r.Use(func(c *gin.Context) {
-		allowedOrigin := os.Getenv("ALLOWED_ORIGIN")
-		if allowedOrigin == "" {
-			allowedOrigin = "http://localhost:5173" // Default fail-safe
-		}
-
-		origin := c.Request.Header.Get("Origin")
-
-		// STRICT CORS: Only allow the exact origin specified, no dynamic reflection
-		if origin == allowedOrigin {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
-		}
-
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-
-		// Security Headers (OWASP Recommended)
-		c.Writer.Header().Set("X-Content-Type-Options", "nosniff")
-		c.Writer.Header().Set("X-Frame-Options", "DENY")
-		c.Writer.Header().Set("X-XSS-Protection", "1; mode=block")
-		c.Writer.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	})
-
-	api := r.Group("/api")
-	{
-		// Serve Static Uploads (for PDFs)
-		api.Static("/uploads", "./uploads")
-
-		authGroup := api.Group("/auth")
-		authGroup.Use(middleware.RateLimitMiddleware(authLimiter)) // Strict rate limit for auth
-		{
-			authGroup.POST("/register", auth.Register)
-			authGroup.POST("/login", auth.Login)
-			authGroup.POST("/logout", auth.Logout)
-
-			// Secured Auth Routes
-			authGroup.GET("/me", auth.AuthMiddleware(), auth.Me)
-		}
+	// Settings saved by admins override the environment; reloaded periodically
+	if err := settings.Load(); err != nil {
+		log.Printf("Failed to load settings, using the environment: %v", err)
+	}
+	settings.StartReloader()
 
-		// Secured Routes (Require Login)
-		secured := api.Group("/")
-		secured.Use(auth.AuthMiddleware())
-		{
-			// Student / Shared Routes
-			secured.POST("/check", handlers.UploadAndCheck)
-			secured.GET("/standards", handlers.GetStandards)
-			secured.GET("/history", handlers.GetHistory)
-			secured.GET("/history/:id", handlers.GetHistoryDetail)
+	// Shared state for running several replicas: REDIS_URL and the
+	// RATE_LIMIT_BACKEND, JOB_BACKEND and PARSE_CACHE_BACKEND switches
+	redis.Init()
+	handlers.ConfigureSharedState()
 
-			// AI Verification
-			secured.POST("/ai/verify/:id", middleware.RateLimitMiddleware(aiLimiter), handlers.VerifyViolationWithAI)
+	// Optional crash reporting (Sentry / GlitchTip), enabled by SENTRY_DSN
+	reporting.Init()
 
-			// Teacher & Admin Routes (Mutating Standards & Teacher History)
-			teacherRoutes := secured.Group("/")
-			teacherRoutes.Use(auth.RequireRole("teacher", "admin"))
-			{
-				teacherRoutes.POST("/standards", handlers.CreateStandard)
-				teacherRoutes.PUT("/standards/:id", handlers.UpdateStandard)
-				teacherRoutes.DELETE("/standards/:id", handlers.DeleteStandard)
-				teacherRoutes.POST("/standards/extract", handlers.ExtractStandardFromDoc)
-				teacherRoutes.GET("/teacher/history", handlers.GetTeacherHistory)
-				teacherRoutes.GET("/teacher/history/:id", handlers.GetTeacherHistoryDetail)
-			}
+	// PDF previews: checks the converter chosen by CONVERTER and logs whether it is available
+	convert.Default()
 
-			// Admin Only Routes
-			adminGroup := secured.Group("/admin")
-			adminGroup.Use(auth.RequireRole("admin"))
-			{
-				adminGroup.GET("/stats", handlers.GetAdminStats)
-				adminGroup.GET("/users", handlers.GetUsers)
-				adminGroup.DELETE("/users/:id", handlers.DeleteUser)
-				adminGroup.PUT("/users/:id/status", handlers.ToggleUserStatus)
-			}
-		}
+	// Periodic removal of temporary and orphaned files in ./uploads
+	handlers.StartUploadJanitor()
 
-		api.GET("/ping", func(c *gin.Context) {
-			c.JSON(200, gin.H{
-				"message": "pong",
-			})
-		})
+	// Batch checks scheduled for off-peak hours
+	handlers.StartCheckScheduler()
 
-		api.GET("/health", func(c *gin.Context) {
-			// Check DB
-			db := database.DB
-			if db == nil || db.Ping() != nil {
-				c.JSON(503, gin.H{"status": "unhealthy", "database": "disconnected"})
-				return
-			}
-			c.JSON(200, gin.H{"status": "healthy", "database": "connected"})
-		})
+	// Users and groups from the registrar's roster, when ROSTER_SCIM_URL or ROSTER_CSV is set
+	handlers.StartRosterSync()
 
-		// Prometheus Metrics Endpoint
-		api.GET("/metrics", gin.WrapH(promhttp.Handler()))
-	}
+	r := server.NewRouter()
 
 	port := os.Getenv("PORT")
 	if port == "" {
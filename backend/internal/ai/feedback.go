@@ -0,0 +1,274 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits on how much of a check is sent to the model.
+const (
+	maxFeedbackExamples   = 3
+	maxFeedbackContext    = 200
+	maxFeedbackRuleGroups = 30
+)
+
+// FeedbackNotice is shown next to generated feedback so that students do not
+// mistake it for the verdict of the rule checker or a teacher.
+const FeedbackNotice = "Рекомендации сгенерированы автоматически языковой моделью и могут содержать неточности. Оценка работы определяется только результатами проверки."
+
+// FeedbackClient generates study advice through an OpenAI-compatible
+// chat completions endpoint.
+type FeedbackClient struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	HTTP    *http.Client
+}
+
+// NewFeedbackClientFromEnv configures the client from LLM_FEEDBACK_URL (the
+// API base URL, e.g. https://api.openai.com/v1), LLM_FEEDBACK_API_KEY,
+// LLM_FEEDBACK_MODEL and LLM_FEEDBACK_TIMEOUT_SECONDS. It returns nil when
+// LLM_FEEDBACK_URL is not set, which disables the feature.
+func NewFeedbackClientFromEnv() *FeedbackClient {
+	baseURL := strings.TrimRight(strings.TrimSpace(os.Getenv("LLM_FEEDBACK_URL")), "/")
+	if baseURL == "" {
+		return nil
+	}
+	model := strings.TrimSpace(os.Getenv("LLM_FEEDBACK_MODEL"))
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	timeout := 60 * time.Second
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("LLM_FEEDBACK_TIMEOUT_SECONDS"))); err == nil && n > 0 {
+		timeout = time.Duration(n) * time.Second
+	}
+	return &FeedbackClient{
+		BaseURL: baseURL,
+		APIKey:  strings.TrimSpace(os.Getenv("LLM_FEEDBACK_API_KEY")),
+		Model:   model,
+		HTTP:    &http.Client{Timeout: timeout},
+	}
+}
+
+// FeedbackItem is one rule type of a check with a few example fragments.
+type FeedbackItem struct {
+	Category    string   `json:"category"`
+	RuleType    string   `json:"rule_type"`
+	Description string   `json:"description"`
+	Expected    string   `json:"expected,omitempty"`
+	Actual      string   `json:"actual,omitempty"`
+	Count       int      `json:"count"`
+	Examples    []string `json:"examples,omitempty"`
+}
+
+// CategoryFeedback is the advice generated for one violation category.
+type CategoryFeedback struct {
+	Category string `json:"category"`
+	Advice   string `json:"advice"`
+}
+
+// FeedbackViolation is the part of a stored violation the prompt is built from.
+type FeedbackViolation struct {
+	RuleType    string
+	Description string
+	Expected    string
+	Actual      string
+	Context     string
+}
+
+// feedbackCategoryPrefixes maps rule type prefixes to the categories used by
+// the report viewer.
+var feedbackCategoryPrefixes = []struct{ prefix, category string }{
+	{"margin_", "margins"},
+	{"header_dist", "margins"},
+	{"footer_dist", "margins"},
+	{"header_", "page_setup"},
+	{"font_", "font"},
+	{"line_spacing", "paragraph"},
+	{"alignment", "paragraph"},
+	{"indent", "paragraph"},
+	{"spacing_", "paragraph"},
+	{"style_", "typography"},
+	{"text_case", "typography"},
+	{"structure_", "structure"},
+	{"heading_", "structure"},
+	{"toc_", "structure"},
+	{"section_", "structure"},
+	{"page_break", "structure"},
+	{"vocabulary", "content"},
+	{"doc_length", "content"},
+	{"intro_length", "content"},
+	{"conclusion_length", "content"},
+	{"page_", "page_setup"},
+	{"table_", "tables"},
+	{"image_", "images"},
+	{"formula_", "formulas"},
+	{"reference", "references"},
+}
+
+// FeedbackCategory returns the category a rule type belongs to.
+func FeedbackCategory(ruleType string) string {
+	for _, p := range feedbackCategoryPrefixes {
+		if strings.HasPrefix(ruleType, p.prefix) {
+			return p.category
+		}
+	}
+	return "other"
+}
+
+// BuildFeedbackItems groups violations by rule type and keeps a few shortened
+// context fragments of each group.
+func BuildFeedbackItems(violations []FeedbackViolation) []FeedbackItem {
+	byRule := map[string]*FeedbackItem{}
+	var order []string
+	for _, v := range violations {
+		item, ok := byRule[v.RuleType]
+		if !ok {
+			item = &FeedbackItem{
+				Category:    FeedbackCategory(v.RuleType),
+				RuleType:    v.RuleType,
+				Description: v.Description,
+				Expected:    v.Expected,
+				Actual:      v.Actual,
+			}
+			byRule[v.RuleType] = item
+			order = append(order, v.RuleType)
+		}
+		item.Count++
+		if ctx := strings.TrimSpace(v.Context); ctx != "" && len(item.Examples) < maxFeedbackExamples {
+			item.Examples = append(item.Examples, shorten(ctx, maxFeedbackContext))
+		}
+	}
+
+	items := make([]FeedbackItem, 0, len(order))
+	for _, ruleType := range order {
+		items = append(items, *byRule[ruleType])
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Count > items[j].Count })
+	if len(items) > maxFeedbackRuleGroups {
+		items = items[:maxFeedbackRuleGroups]
+	}
+	return items
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+const feedbackSystemPrompt = `Ты — преподаватель, который помогает студенту исправить оформление научной работы по результатам автоматического нормоконтроля.
+Для каждой категории нарушений дай короткий практический совет (2–4 предложения): что исправить и как это сделать в Microsoft Word.
+Не придумывай нарушений, которых нет во входных данных, и не меняй оценку работы.
+Ответь ТОЛЬКО в формате JSON, без markdown-разметки:
+{"categories": [{"category": "<ключ категории из входных данных>", "advice": "<совет>"}]}`
+
+// GenerateFeedback asks the model for advice on each category of items.
+func (c *FeedbackClient) GenerateFeedback(ctx context.Context, items []FeedbackItem) ([]CategoryFeedback, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	input, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	reqBody, err := json.Marshal(chatRequest{
+		Model: c.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: feedbackSystemPrompt},
+			{Role: "user", Content: "Нарушения, найденные при проверке:\n" + string(input)},
+		},
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/chat/completions", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("llm api error (%d): %s", resp.StatusCode, shorten(string(body), 500))
+	}
+
+	var chat chatResponse
+	if err := json.Unmarshal(body, &chat); err != nil {
+		return nil, err
+	}
+	if len(chat.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in llm response")
+	}
+	return parseFeedback(chat.Choices[0].Message.Content, items)
+}
+
+// parseFeedback extracts the JSON answer and drops advice for categories that
+// were not part of the request.
+func parseFeedback(raw string, items []FeedbackItem) ([]CategoryFeedback, error) {
+	start := strings.Index(raw, "{")
+	end := strings.LastIndex(raw, "}")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("invalid json in llm response")
+	}
+	var parsed struct {
+		Categories []CategoryFeedback `json:"categories"`
+	}
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &parsed); err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, item := range items {
+		known[item.Category] = true
+	}
+	var out []CategoryFeedback
+	for _, f := range parsed.Categories {
+		f.Advice = strings.TrimSpace(f.Advice)
+		if known[f.Category] && f.Advice != "" {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func shorten(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
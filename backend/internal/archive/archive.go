@@ -0,0 +1,180 @@
+// Package archive unpacks the archives teachers upload with the works of a
+// group. Zip is read natively; other formats, such as rar and 7z, go through
+// an external tool when one is installed. Only documents are unpacked: every
+// entry is checked for its name, type and size, and entries that fail are
+// reported instead of extracted.
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnsupported is returned for an archive format no extractor handles,
+	// e.g. rar without the external tool.
+	ErrUnsupported = errors.New("archives of this format are not supported on this server")
+	// ErrTooLarge is returned when the documents of an archive exceed
+	// Limits.MaxTotalBytes or Limits.MaxFiles.
+	ErrTooLarge = errors.New("the archive holds too many or too large documents")
+)
+
+// Limits bound what one archive may unpack to.
+type Limits struct {
+	MaxFiles      int   // documents
+	MaxFileBytes  int64 // per document; larger ones are skipped
+	MaxTotalBytes int64 // all documents together
+}
+
+// DefaultLimits fit the batch checks: the works of one group.
+var DefaultLimits = Limits{MaxFiles: 200, MaxFileBytes: 20 << 20, MaxTotalBytes: 500 << 20}
+
+// Reasons an entry is skipped.
+const (
+	SkipNotDocument   = "not_document"
+	SkipTooLarge      = "too_large"
+	SkipSystemFile    = "system_file"
+	SkipUnsafePath    = "unsafe_path"
+	SkipNotRegular    = "not_regular"
+	SkipNestedArchive = "nested_archive"
+)
+
+// File is a document unpacked from an archive.
+type File struct {
+	Name string `json:"name"` // base name, used as the document's file name
+	Path string `json:"path"` // path inside the archive
+	Size int64  `json:"size"`
+	// Disk is where the file was unpacked to.
+	Disk string `json:"-"`
+}
+
+// Skipped is an entry that was not unpacked and why, one of the Skip*
+// reasons.
+type Skipped struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Result lists the documents unpacked from an archive and the entries left
+// out.
+type Result struct {
+	Files   []File    `json:"files"`
+	Skipped []Skipped `json:"skipped"`
+}
+
+// Extractor unpacks an archive format.
+type Extractor interface {
+	Name() string
+	// Extract unpacks the documents of src into the empty directory dir.
+	Extract(ctx context.Context, src, dir string, limits Limits) (Result, error)
+}
+
+// DocumentExtensions are the files a batch check accepts from an archive.
+var DocumentExtensions = map[string]bool{".docx": true, ".odt": true, ".pptx": true}
+
+var (
+	mu         sync.RWMutex
+	extractors = map[string]Extractor{}
+)
+
+// Register makes e the extractor of archives with the extension ext, e.g.
+// ".rar", replacing the previous one.
+func Register(ext string, e Extractor) {
+	mu.Lock()
+	defer mu.Unlock()
+	extractors[strings.ToLower(ext)] = e
+}
+
+// IsArchive reports whether a file name has an archive extension, whether
+// or not this server can unpack it.
+func IsArchive(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zip", ".rar", ".7z":
+		return true
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := extractors[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// For returns the extractor of the archive name by its extension.
+func For(name string) (Extractor, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := extractors[strings.ToLower(filepath.Ext(name))]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return e, nil
+}
+
+// Extract unpacks the archive src, named name as uploaded, into dir.
+func Extract(ctx context.Context, name, src, dir string, limits Limits) (Result, error) {
+	e, err := For(name)
+	if err != nil {
+		return Result{}, err
+	}
+	return e.Extract(ctx, src, dir, limits)
+}
+
+// screen checks an entry by its path in the archive and returns the reason
+// to skip it, or "" for a document to unpack.
+func screen(entryPath string) string {
+	// * and ? are wildcards to the external tool; no document is named so.
+	if !utf8.ValidString(entryPath) || strings.ContainsAny(entryPath, "\x00*?") {
+		return SkipUnsafePath
+	}
+	p := strings.ReplaceAll(entryPath, `\`, "/")
+	if path.IsAbs(p) || filepath.VolumeName(p) != "" || strings.HasPrefix(p, "//") {
+		return SkipUnsafePath
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == ".." {
+			return SkipUnsafePath
+		}
+		if part == "__MACOSX" || strings.HasPrefix(part, ".") {
+			return SkipSystemFile
+		}
+	}
+	base := path.Base(p)
+	if strings.HasPrefix(base, "~$") || strings.EqualFold(base, "Thumbs.db") || strings.EqualFold(base, "desktop.ini") {
+		return SkipSystemFile
+	}
+	if IsArchive(base) {
+		return SkipNestedArchive
+	}
+	if !DocumentExtensions[strings.ToLower(path.Ext(base))] {
+		return SkipNotDocument
+	}
+	return ""
+}
+
+// baseName is the name a document from entryPath gets.
+func baseName(entryPath string) string {
+	return path.Base(strings.ReplaceAll(entryPath, `\`, "/"))
+}
+
+// budget tracks an archive's documents against the limits.
+type budget struct {
+	limits Limits
+	files  int
+	bytes  int64
+}
+
+// add accounts for a document of size bytes and fails when the archive goes
+// over its limits.
+func (b *budget) add(size int64) error {
+	b.files++
+	b.bytes += size
+	if b.files > b.limits.MaxFiles || b.bytes > b.limits.MaxTotalBytes {
+		return fmt.Errorf("%w (at most %d documents and %d MiB)", ErrTooLarge, b.limits.MaxFiles, b.limits.MaxTotalBytes>>20)
+	}
+	return nil
+}
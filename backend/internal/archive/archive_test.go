@@ -0,0 +1,397 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestScreen(t *testing.T) {
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{"Иванов.docx", ""},
+		{"ИВТ-21/курсовые/Иванов.DOCX", ""},
+		{`ИВТ-21\Петров.odt`, ""},
+		{"slides/deck.pptx", ""},
+		{"../evil.docx", SkipUnsafePath},
+		{"works/../../evil.docx", SkipUnsafePath},
+		{`..\..\evil.docx`, SkipUnsafePath},
+		{"/etc/evil.docx", SkipUnsafePath},
+		{`\\server\share\evil.docx`, SkipUnsafePath},
+		{"//server/share/evil.docx", SkipUnsafePath},
+		{"bad\xff.docx", SkipUnsafePath},
+		{"nul\x00.docx", SkipUnsafePath},
+		{"*.docx", SkipUnsafePath},
+		{"works/Иванов?.docx", SkipUnsafePath},
+		{"__MACOSX/works/._Иванов.docx", SkipSystemFile},
+		{".git/config.docx", SkipSystemFile},
+		{"works/~$Иванов.docx", SkipSystemFile},
+		{"Thumbs.db", SkipSystemFile},
+		{"works/desktop.ini", SkipSystemFile},
+		{"inner.zip", SkipNestedArchive},
+		{"works/inner.RAR", SkipNestedArchive},
+		{"inner.7z", SkipNestedArchive},
+		{"report.pdf", SkipNotDocument},
+		{"Иванов.doc", SkipNotDocument},
+		{"README", SkipNotDocument},
+	} {
+		if got := screen(tc.path); got != tc.want {
+			t.Errorf("screen(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+// zipEntry is an entry of a test archive. A positive headerSize is written
+// as the uncompressed size in place of the real one.
+type zipEntry struct {
+	name       string
+	body       string
+	mode       fs.FileMode
+	nonUTF8    bool
+	headerSize int64
+}
+
+func writeZip(t *testing.T, entries []zipEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		fh := &zip.FileHeader{Name: e.name, Method: zip.Store, NonUTF8: e.nonUTF8}
+		if e.mode != 0 {
+			fh.SetMode(e.mode)
+		}
+		var out io.Writer
+		var err error
+		if e.headerSize > 0 {
+			fh.CRC32 = crc32.ChecksumIEEE([]byte(e.body))
+			fh.CompressedSize64 = uint64(len(e.body))
+			fh.UncompressedSize64 = uint64(e.headerSize)
+			out, err = w.CreateRaw(fh)
+		} else {
+			out, err = w.CreateHeader(fh)
+		}
+		if err == nil {
+			_, err = out.Write([]byte(e.body))
+		}
+		if err != nil {
+			t.Fatalf("zip entry %q: %v", e.name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "works.zip")
+	if err := os.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func extractZip(t *testing.T, entries []zipEntry, limits Limits) (Result, string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	res, err := Extract(context.Background(), "works.zip", writeZip(t, entries), dir, limits)
+	return res, dir, err
+}
+
+func TestZipExtract(t *testing.T) {
+	cp866, _ := charmap.CodePage866.NewEncoder().String("Сидоров.docx")
+	limits := Limits{MaxFiles: 10, MaxFileBytes: 16, MaxTotalBytes: 100}
+	res, dir, err := extractZip(t, []zipEntry{
+		{name: "ИВТ-21/"},
+		{name: "ИВТ-21/Иванов.docx", body: "ivanov"},
+		{name: "ИВТ-21/курсовые/2024/Петров.odt", body: "petrov"},
+		{name: "ПИ-22/Иванов.docx", body: "other ivanov"},
+		{name: cp866, body: "sidorov", nonUTF8: true},
+		{name: "../../evil.docx", body: "evil"},
+		{name: "/abs/evil.docx", body: "evil"},
+		{name: `..\evil.docx`, body: "evil"},
+		{name: "notes.txt", body: "notes"},
+		{name: "__MACOSX/ИВТ-21/._Иванов.docx", body: "meta"},
+		{name: "inner.zip", body: "PK"},
+		{name: "big.docx", body: strings.Repeat("x", 17)},
+		{name: "link.docx", body: "/etc/passwd", mode: fs.ModeSymlink | 0777},
+	}, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, f := range res.Files {
+		body, err := os.ReadFile(f.Disk)
+		if err != nil {
+			t.Fatalf("%s: %v", f.Path, err)
+		}
+		if filepath.Dir(f.Disk) != dir || f.Size != int64(len(body)) {
+			t.Fatalf("%s unpacked to %s (%d bytes)", f.Path, f.Disk, f.Size)
+		}
+		got = append(got, f.Name+" "+f.Path+" "+string(body))
+	}
+	want := []string{
+		"Иванов.docx ИВТ-21/Иванов.docx ivanov",
+		"Петров.odt ИВТ-21/курсовые/2024/Петров.odt petrov",
+		"Иванов.docx ПИ-22/Иванов.docx other ivanov",
+		"Сидоров.docx Сидоров.docx sidorov",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("files = %q, want %q", got, want)
+	}
+
+	skipped := map[string]string{}
+	for _, s := range res.Skipped {
+		skipped[s.Path] = s.Reason
+	}
+	wantSkipped := map[string]string{
+		"../../evil.docx": SkipUnsafePath,
+		"/abs/evil.docx":  SkipUnsafePath,
+		`..\evil.docx`:    SkipUnsafePath,
+		"notes.txt":       SkipNotDocument,
+		"inner.zip":       SkipNestedArchive,
+		"big.docx":        SkipTooLarge,
+		"link.docx":       SkipNotRegular,
+	}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Fatalf("skipped = %v, want %v", skipped, wantSkipped)
+	}
+
+	// Nothing is written outside dir, and only the documents inside it.
+	for _, p := range []string{"../../evil.docx", "../evil.docx", "/abs/evil.docx"} {
+		if _, err := os.Stat(filepath.Join(dir, p)); err == nil {
+			t.Fatalf("%s was written", p)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != len(want) {
+		t.Fatalf("dir holds %d files, want %d", len(entries), len(want))
+	}
+}
+
+func TestZipExtractLimits(t *testing.T) {
+	docs := []zipEntry{{name: "a.docx", body: "1234"}, {name: "b.docx", body: "5678"}, {name: "c.docx", body: "9"}}
+	for _, tc := range []struct {
+		name   string
+		limits Limits
+		files  int
+		err    error
+	}{
+		{"within", Limits{MaxFiles: 3, MaxFileBytes: 4, MaxTotalBytes: 9}, 3, nil},
+		{"too many files", Limits{MaxFiles: 2, MaxFileBytes: 4, MaxTotalBytes: 100}, 2, ErrTooLarge},
+		{"too many bytes", Limits{MaxFiles: 10, MaxFileBytes: 4, MaxTotalBytes: 8}, 2, ErrTooLarge},
+		{"large files skipped", Limits{MaxFiles: 10, MaxFileBytes: 3, MaxTotalBytes: 100}, 1, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, _, err := extractZip(t, docs, tc.limits)
+			if !errors.Is(err, tc.err) || len(res.Files) != tc.files {
+				t.Fatalf("Extract = %d files, %v; want %d, %v", len(res.Files), err, tc.files, tc.err)
+			}
+		})
+	}
+}
+
+func TestZipExtractDistrustsHeaderSize(t *testing.T) {
+	// The header claims 4 bytes, within the limit; the entry holds 40.
+	res, dir, err := extractZip(t, []zipEntry{
+		{name: "ok.docx", body: "fine"},
+		{name: "bomb.docx", body: strings.Repeat("x", 40), headerSize: 4},
+	}, Limits{MaxFiles: 10, MaxFileBytes: 16, MaxTotalBytes: 100})
+	if err == nil || !strings.Contains(err.Error(), "bomb.docx") {
+		t.Fatalf("Extract error = %v, want the oversized entry", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	if len(res.Files) != 1 || !reflect.DeepEqual(names, []string{"0_ok.docx"}) {
+		t.Fatalf("files = %v, on disk %q; want the partial entry removed", res.Files, names)
+	}
+}
+
+func TestParseSevenZipList(t *testing.T) {
+	listing := strings.Join([]string{
+		"Path = ИВТ-21",
+		"Folder = +",
+		"Size = 0",
+		"",
+		"Path = ИВТ-21/Иванов.docx",
+		"Folder = -",
+		"Size = 12345",
+		"Attributes = A -rw-r--r--",
+		"",
+		"Path = link.docx",
+		"Size = 11",
+		"Attributes = A lrwxrwxrwx",
+		"",
+		"Path = old",
+		"Attributes = D drwxr-xr-x",
+		"Path = weird = name.odt",
+		"Size = x",
+	}, "\n")
+	got := parseSevenZipList([]byte("Size = 1\n" + listing))
+	want := []sevenZipEntry{
+		{path: "ИВТ-21", folder: true},
+		{path: "ИВТ-21/Иванов.docx", size: 12345},
+		{path: "link.docx", size: 11, symlink: true},
+		{path: "old", folder: true},
+		{path: "weird = name.odt"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseSevenZipList = %+v, want %+v", got, want)
+	}
+}
+
+func TestFor(t *testing.T) {
+	if _, err := For("works.tar"); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("For(.tar) = %v, want ErrUnsupported", err)
+	}
+	if e, err := For("WORKS.ZIP"); err != nil || e.Name() != "zip" {
+		t.Fatalf("For(.ZIP) = %v, %v", e, err)
+	}
+	for name, want := range map[string]bool{"a.zip": true, "a.RAR": true, "a.7z": true, "a.docx": false, "zip": false} {
+		if IsArchive(name) != want {
+			t.Fatalf("IsArchive(%q) = %v", name, !want)
+		}
+	}
+	tool := &SevenZip{Binary: "no-such-archiver"}
+	if _, err := tool.Extract(context.Background(), "works.rar", t.TempDir(), DefaultLimits); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Extract without the tool = %v, want ErrUnsupported", err)
+	}
+}
+
+// fakeSevenZip is a stand-in for the 7z tool that lists listing and unpacks
+// the bodies of entries, whatever the listing says about their size. Its
+// arguments are logged to args.
+func fakeSevenZip(t *testing.T, listing string, bodies map[string]string) (*SevenZip, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tool is a shell script")
+	}
+	dir := t.TempDir()
+	for name, body := range bodies {
+		p := filepath.Join(dir, "bodies", filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "listing"), []byte(listing), 0644)
+	script := `#!/bin/sh
+echo "$@" >> "` + dir + `/args"
+case "$1" in
+l) cat "` + dir + `/listing" ;;
+x) for last; do :; done; cat "` + dir + `/bodies/$(cat "${last#@}")" ;;
+esac
+`
+	tool := filepath.Join(dir, "7z")
+	if err := os.WriteFile(tool, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &SevenZip{Binary: tool}, filepath.Join(dir, "args")
+}
+
+func sevenZipListing(sizes map[string]int) string {
+	var b strings.Builder
+	for _, name := range sortedNames(sizes) {
+		fmt.Fprintf(&b, "Path = %s\nFolder = -\nSize = %d\n\n", name, sizes[name])
+	}
+	return b.String()
+}
+
+func sortedNames(m map[string]int) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSevenZipExtract(t *testing.T) {
+	tool, args := fakeSevenZip(t, sevenZipListing(map[string]int{
+		"ИВТ-21/Иванов.docx": 6,
+		"@Петров.odt":        6,
+		"*.docx":             1,
+		"ИВТ-21/?.docx":      1,
+		"big.docx":           17,
+	}), map[string]string{
+		"ИВТ-21/Иванов.docx": "ivanov",
+		"@Петров.odt":        "petrov",
+		"big.docx":           strings.Repeat("x", 17),
+	})
+	dir := t.TempDir()
+	res, err := tool.Extract(context.Background(), "works.7z", dir, Limits{MaxFiles: 10, MaxFileBytes: 16, MaxTotalBytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range res.Files {
+		body, _ := os.ReadFile(f.Disk)
+		got = append(got, f.Path+" "+string(body))
+	}
+	if want := []string{"@Петров.odt petrov", "ИВТ-21/Иванов.docx ivanov"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("files = %q, want %q", got, want)
+	}
+	skipped := map[string]string{}
+	for _, s := range res.Skipped {
+		skipped[s.Path] = s.Reason
+	}
+	if want := map[string]string{"*.docx": SkipUnsafePath, "ИВТ-21/?.docx": SkipUnsafePath, "big.docx": SkipTooLarge}; !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("skipped = %v, want %v", skipped, want)
+	}
+
+	// Each document is unpacked on its own, matched literally.
+	log, _ := os.ReadFile(args)
+	calls := strings.Split(strings.TrimSpace(string(log)), "\n")
+	if len(calls) != 3 {
+		t.Fatalf("tool calls = %q", calls)
+	}
+	for _, call := range calls[1:] {
+		if !strings.HasPrefix(call, "x -so ") || !strings.Contains(call, " -spd -r- -snl- ") {
+			t.Fatalf("unpacked with %q", call)
+		}
+	}
+}
+
+func TestSevenZipDistrustsListedSizes(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		bodies map[string]string
+		err    string
+	}{
+		// Listed as 4 bytes, within the limit; the entry holds 40.
+		{"entry over the file limit", map[string]string{"a.docx": "1234", "b.docx": strings.Repeat("x", 40)}, "b.docx: larger than the archive listing says"},
+		{"entries over the total limit", map[string]string{"a.docx": "12345678", "b.docx": "12345678"}, ErrTooLarge.Error()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tool, _ := fakeSevenZip(t, sevenZipListing(map[string]int{"a.docx": 4, "b.docx": 4}), tc.bodies)
+			dir := t.TempDir()
+			res, err := tool.Extract(context.Background(), "works.7z", dir, Limits{MaxFiles: 10, MaxFileBytes: 16, MaxTotalBytes: 10})
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("Extract error = %v, want %q", err, tc.err)
+			}
+			entries, _ := os.ReadDir(dir)
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			if len(res.Files) != 1 || !reflect.DeepEqual(names, []string{"0_a.docx"}) {
+				t.Fatalf("files = %v, on disk %q; want only the first entry", res.Files, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxOutput bounds the tool output kept for error messages.
+const maxOutput = 2000
+
+func init() {
+	binary := strings.TrimSpace(os.Getenv("ARCHIVE_TOOL"))
+	switch binary {
+	case "none":
+		return
+	case "":
+		binary = "7z"
+	}
+	tool := &SevenZip{Binary: binary}
+	Register(".rar", tool)
+	Register(".7z", tool)
+}
+
+// SevenZip unpacks rar, 7z and the other formats 7-Zip reads with the 7z
+// command line tool (ARCHIVE_TOOL, default "7z"; "none" turns it off). The
+// archive is listed first, so only the documents that pass the checks are
+// unpacked, one at a time through the tool's standard output.
+type SevenZip struct {
+	Binary string
+}
+
+func (s *SevenZip) Name() string { return "7z" }
+
+type sevenZipEntry struct {
+	path    string
+	size    int64
+	folder  bool
+	symlink bool
+}
+
+func (s *SevenZip) Extract(ctx context.Context, src, dir string, limits Limits) (Result, error) {
+	binary, err := exec.LookPath(s.Binary)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	// -p with no password fails on encrypted archives instead of asking.
+	listing, err := output(exec.CommandContext(ctx, binary, "l", "-slt", "-ba", "-p", "-sccUTF-8", src))
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{Files: []File{}, Skipped: []Skipped{}}
+	b := budget{limits: limits}
+	var wanted []string
+	for _, e := range parseSevenZipList(listing) {
+		switch reason := screen(e.path); {
+		case e.folder:
+			continue
+		case e.symlink:
+			res.Skipped = append(res.Skipped, Skipped{Path: e.path, Reason: SkipNotRegular})
+			continue
+		case reason == SkipSystemFile:
+			continue
+		case reason != "":
+			res.Skipped = append(res.Skipped, Skipped{Path: e.path, Reason: reason})
+			continue
+		case e.size > limits.MaxFileBytes:
+			res.Skipped = append(res.Skipped, Skipped{Path: e.path, Reason: SkipTooLarge})
+			continue
+		}
+		if err := b.add(e.size); err != nil {
+			return res, err
+		}
+		wanted = append(wanted, e.path)
+	}
+	if len(wanted) == 0 {
+		return res, nil
+	}
+
+	// The listing is not trusted either: what the tool writes is counted
+	// against the limits as it is written.
+	list := filepath.Join(dir, ".entry")
+	defer os.Remove(list)
+	var total int64
+	for _, p := range wanted {
+		disk := filepath.Join(dir, strconv.Itoa(len(res.Files))+"_"+baseName(p))
+		size, err := unpackEntry(ctx, binary, src, p, list, disk, limits.MaxFileBytes)
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", p, err)
+		}
+		if total += size; total > limits.MaxTotalBytes {
+			os.Remove(disk)
+			return res, fmt.Errorf("%w (at most %d documents and %d MiB)", ErrTooLarge, limits.MaxFiles, limits.MaxTotalBytes>>20)
+		}
+		res.Files = append(res.Files, File{Name: baseName(p), Path: p, Size: size, Disk: disk})
+	}
+	return res, nil
+}
+
+// unpackEntry writes the entry p of src to disk. The name goes through a
+// list file, so a name starting with "@" or "-" is not read as a switch,
+// and -spd matches it literally rather than as a wildcard; -r- and -snl-
+// keep the tool from matching subfolders or restoring links. Writing stops
+// past max bytes, and the tool is stopped with it.
+func unpackEntry(ctx context.Context, binary, src, p, list, disk string, max int64) (int64, error) {
+	if err := os.WriteFile(list, []byte(p+"\n"), 0600); err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, binary, "x", "-so", "-bd", "-p", "-spd", "-r-", "-snl-", "-scsUTF-8", "-sccUTF-8", src, "@"+list)
+	cmd.WaitDelay = 5 * time.Second
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(disk, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		out.Close()
+		os.Remove(disk)
+		return 0, err
+	}
+	n, err := io.Copy(out, io.LimitReader(stdout, max+1))
+	if n > max || err != nil {
+		cancel()
+	}
+	werr := cmd.Wait()
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	switch {
+	case n > max:
+		err = fmt.Errorf("larger than the archive listing says")
+	case err == nil && werr != nil:
+		err = toolError(cmd, werr, stderr.String())
+	}
+	if err != nil {
+		os.Remove(disk)
+		return 0, err
+	}
+	return n, nil
+}
+
+// parseSevenZipList reads the technical listing (-slt) of 7z: one block of
+// "Key = Value" lines per entry, each starting with Path.
+func parseSevenZipList(listing []byte) []sevenZipEntry {
+	var entries []sevenZipEntry
+	sc := bufio.NewScanner(bytes.NewReader(listing))
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), " = ")
+		if !ok {
+			continue
+		}
+		if key == "Path" {
+			entries = append(entries, sevenZipEntry{path: value})
+			continue
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		e := &entries[len(entries)-1]
+		switch key {
+		case "Size":
+			e.size, _ = strconv.ParseInt(value, 10, 64)
+		case "Folder":
+			e.folder = value == "+"
+		case "Attributes":
+			// e.g. "A -rw-r--r--" or "D drwxr-xr-x"; the unix mode follows
+			// the Windows attributes.
+			if i := strings.IndexByte(value, ' '); i >= 0 && strings.HasPrefix(value[i+1:], "l") {
+				e.symlink = true
+			}
+			if strings.HasPrefix(value, "D") {
+				e.folder = true
+			}
+		}
+	}
+	return entries
+}
+
+// output runs the tool and returns its standard output, or an error with the
+// end of what it printed.
+func output(cmd *exec.Cmd) ([]byte, error) {
+	cmd.WaitDelay = 5 * time.Second
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err == nil {
+		return out, nil
+	}
+	text := stderr.String()
+	if strings.TrimSpace(text) == "" {
+		text = string(out)
+	}
+	return nil, toolError(cmd, err, text)
+}
+
+// toolError describes a failed run of the tool with the end of what it
+// printed.
+func toolError(cmd *exec.Cmd, err error, text string) error {
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		return err
+	}
+	text = strings.TrimSpace(text)
+	if len(text) > maxOutput {
+		text = text[len(text)-maxOutput:]
+	}
+	return fmt.Errorf("%s failed: %v: %s", filepath.Base(cmd.Path), err, text)
+}
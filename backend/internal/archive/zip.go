@@ -0,0 +1,107 @@
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func init() {
+	Register(".zip", Zip{})
+}
+
+// Zip reads zip archives natively.
+type Zip struct{}
+
+func (Zip) Name() string { return "zip" }
+
+func (Zip) Extract(ctx context.Context, src, dir string, limits Limits) (Result, error) {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return Result{}, err
+	}
+	defer r.Close()
+
+	res := Result{Files: []File{}, Skipped: []Skipped{}}
+	b := budget{limits: limits}
+	for _, f := range r.File {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		name := zipEntryName(f)
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			res.Skipped = append(res.Skipped, Skipped{Path: name, Reason: SkipNotRegular})
+			continue
+		}
+		if reason := screen(name); reason != "" {
+			if reason != SkipSystemFile {
+				res.Skipped = append(res.Skipped, Skipped{Path: name, Reason: reason})
+			}
+			continue
+		}
+		if f.UncompressedSize64 > uint64(limits.MaxFileBytes) {
+			res.Skipped = append(res.Skipped, Skipped{Path: name, Reason: SkipTooLarge})
+			continue
+		}
+		if err := b.add(int64(f.UncompressedSize64)); err != nil {
+			return res, err
+		}
+
+		disk := filepath.Join(dir, strconv.Itoa(len(res.Files))+"_"+baseName(name))
+		size, err := unzipFile(f, disk, limits.MaxFileBytes)
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", name, err)
+		}
+		res.Files = append(res.Files, File{Name: baseName(name), Path: name, Size: size, Disk: disk})
+	}
+	return res, nil
+}
+
+// zipEntryName decodes the name of an entry. Archives made by Windows
+// Explorer store names in the OEM code page rather than UTF-8, which for
+// Russian names is CP866.
+func zipEntryName(f *zip.File) string {
+	if !f.NonUTF8 || utf8.ValidString(f.Name) {
+		return f.Name
+	}
+	if name, err := charmap.CodePage866.NewDecoder().String(f.Name); err == nil {
+		return name
+	}
+	return f.Name
+}
+
+// unzipFile writes an entry to disk. The size in the header is not trusted:
+// reading stops past max bytes.
+func unzipFile(f *zip.File, disk string, max int64) (int64, error) {
+	in, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(disk, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, io.LimitReader(in, max+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > max {
+		err = fmt.Errorf("larger than its header says")
+	}
+	if err != nil {
+		os.Remove(disk)
+		return 0, err
+	}
+	return n, nil
+}
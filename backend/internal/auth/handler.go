@@ -1,8 +1,8 @@
 package auth
 
 import (
-	"academic-check-sys/internal/database"
-	"academic-check-sys/internal/models"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -67,8 +67,8 @@ func Login(c *gin.Context) {
 	}
 
 	var user models.User
-	row := database.DB.QueryRow("SELECT id, email, password_hash, role, full_name FROM users WHERE email = ?", req.Email)
-	if err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.FullName); err != nil {
+	row := database.DB.QueryRow("SELECT id, COALESCE(uuid, ''), email, password_hash, role, full_name FROM users WHERE email = ?", req.Email)
+	if err := row.Scan(&user.ID, &user.UUID, &user.Email, &user.PasswordHash, &user.Role, &user.FullName); err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
@@ -96,6 +96,7 @@ func Login(c *gin.Context) {
 		"message": "Logged in successfully",
 		"user": gin.H{
 			"id":        user.ID,
+			"uuid":      user.UUID,
 			"full_name": user.FullName,
 			"role":      user.Role,
 		},
@@ -116,17 +117,19 @@ func Me(c *gin.Context) {
 	}
 
 	var user models.User
-	row := database.DB.QueryRow("SELECT id, email, role, full_name FROM users WHERE id = ?", userID)
-	if err := row.Scan(&user.ID, &user.Email, &user.Role, &user.FullName); err != nil {
+	row := database.DB.QueryRow("SELECT id, COALESCE(uuid, ''), email, role, full_name FROM users WHERE id = ?", userID)
+	if err := row.Scan(&user.ID, &user.UUID, &user.Email, &user.Role, &user.FullName); err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"user": gin.H{
-			"id":        user.ID,
-			"full_name": user.FullName,
-			"role":      user.Role,
+			"id":              user.ID,
+			"uuid":            user.UUID,
+			"full_name":       user.FullName,
+			"role":            user.Role,
+			"impersonated_by": impersonatorInfo(c.GetUint("impersonator_id")),
 		},
 	})
 }
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ImpersonationTTL is the lifetime of a "view as user" session. It is short on
+// purpose: the token is meant for reproducing a support request, not for work.
+const ImpersonationTTL = 30 * time.Minute
+
+// GenerateImpersonationToken issues a short-lived token for userID that
+// records adminID as the impersonator.
+func GenerateImpersonationToken(userID uint, role string, adminID uint) (string, time.Time, error) {
+	expirationTime := time.Now().Add(ImpersonationTTL)
+	claims := &Claims{
+		UserID:         userID,
+		Role:           role,
+		ImpersonatorID: adminID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(getSecretKey())
+	return signed, expirationTime, err
+}
+
+// recordImpersonationEvent writes an entry to the impersonation audit log.
+// Failures are logged but do not block the request.
+func recordImpersonationEvent(c *gin.Context, adminID, userID uint, action, detail string) {
+	_, err := database.DB.Exec("INSERT INTO impersonation_audit (admin_id, user_id, action, detail, ip, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		adminID, userID, action, detail, c.ClientIP(), database.Timestamp(time.Now()))
+	if err != nil {
+		fmt.Printf("Auth: failed to record impersonation %s by admin %d as user %d: %v\n", action, adminID, userID, err)
+	}
+}
+
+// ReadOnlyImpersonation rejects mutating requests made with an impersonation
+// token, so support staff can look at a user's data but not act on it.
+// Must be used AFTER AuthMiddleware.
+func ReadOnlyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("impersonator_id"); ok && c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Impersonation is read-only"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+type ImpersonationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// StartImpersonation lets an admin view the application as another user. The
+// admin's session cookie is replaced by a short-lived impersonation token;
+// StopImpersonation restores it.
+func StartImpersonation(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+
+	var req ImpersonationRequest
+	_ = c.ShouldBindJSON(&req) // the reason is optional
+
+	id, err := database.ResolveID("users", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var user models.User
+	var isActive bool
+	row := database.DB.QueryRow("SELECT id, COALESCE(uuid, ''), email, role, full_name, is_active FROM users WHERE id = ?", id)
+	if err := row.Scan(&user.ID, &user.UUID, &user.Email, &user.Role, &user.FullName, &isActive); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.ID == adminID || user.Role == "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Administrators cannot be impersonated"})
+		return
+	}
+	if !isActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is inactive"})
+		return
+	}
+
+	token, expiresAt, err := GenerateImpersonationToken(user.ID, user.Role, adminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	recordImpersonationEvent(c, adminID, user.ID, "start", req.Reason)
+	c.SetCookie("access_token", token, int(ImpersonationTTL.Seconds()), "/", "", false, true)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Impersonation started",
+		"expires_at": database.FormatTimestamp(expiresAt),
+		"user": gin.H{
+			"id":              user.ID,
+			"uuid":            user.UUID,
+			"full_name":       user.FullName,
+			"role":            user.Role,
+			"impersonated_by": impersonatorInfo(adminID),
+		},
+	})
+}
+
+// StopImpersonation ends an impersonation session and signs the admin back in.
+func StopImpersonation(c *gin.Context) {
+	adminID := c.GetUint("impersonator_id")
+	if adminID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not impersonating"})
+		return
+	}
+
+	var admin models.User
+	row := database.DB.QueryRow("SELECT id, COALESCE(uuid, ''), role, full_name FROM users WHERE id = ? AND role = 'admin' AND is_active", adminID)
+	if err := row.Scan(&admin.ID, &admin.UUID, &admin.Role, &admin.FullName); err != nil {
+		// The admin account is gone or was demoted; just end the session.
+		recordImpersonationEvent(c, adminID, c.GetUint("user_id"), "stop", "admin unavailable")
+		c.SetCookie("access_token", "", -1, "/", "", false, true)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin account unavailable"})
+		return
+	}
+
+	token, err := GenerateToken(admin.ID, admin.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	recordImpersonationEvent(c, adminID, c.GetUint("user_id"), "stop", "")
+	c.SetCookie("access_token", token, 3600*24, "/", "", false, true)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Impersonation stopped",
+		"user": gin.H{
+			"id":        admin.ID,
+			"uuid":      admin.UUID,
+			"full_name": admin.FullName,
+			"role":      admin.Role,
+		},
+	})
+}
+
+// impersonatorInfo describes the admin behind an impersonation session for
+// the UI banner. It returns nil for normal sessions.
+func impersonatorInfo(adminID uint) gin.H {
+	if adminID == 0 {
+		return nil
+	}
+	var fullName string
+	_ = database.DB.QueryRow("SELECT full_name FROM users WHERE id = ?", adminID).Scan(&fullName)
+	return gin.H{"id": adminID, "full_name": fullName}
+}
@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -24,6 +26,8 @@ func getSecretKey() []byte {
 type Claims struct {
 	UserID uint   `json:"user_id"`
 	Role   string `json:"role"`
+	// ImpersonatorID is the admin acting as UserID; zero for normal sessions.
+	ImpersonatorID uint `json:"impersonator_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -55,6 +59,60 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// localUser is the account used for token-less requests in local mode, and
+// localSecret the secret of this run they must carry in the local session
+// cookie.
+var (
+	localUser   *Claims
+	localSecret string
+)
+
+const localSessionCookie = "local_session"
+
+// EnableLocalMode makes AuthMiddleware treat requests from the local machine
+// that carry no token but the local session cookie as the given user. The
+// cookie holds secret and is set by LocalSession. It is meant for the
+// single-user desktop build; requests from other hosts still need a token.
+func EnableLocalMode(userID uint, role string, secret string) {
+	localUser = &Claims{UserID: userID, Role: role}
+	localSecret = secret
+}
+
+// LocalSession sets the local session cookie from the secret in the token
+// query parameter and redirects to the app. The link is printed at startup,
+// so only someone at the machine can follow it; the SameSite cookie keeps
+// pages of other sites from acting as the local user.
+func LocalSession(c *gin.Context) {
+	token := c.Query("token")
+	if localUser == nil || !isLocalSecret(token) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid session link"})
+		return
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     localSessionCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	c.Redirect(http.StatusFound, "/")
+}
+
+func isLocalSecret(s string) bool {
+	return s != "" && subtle.ConstantTimeCompare([]byte(s), []byte(localSecret)) == 1
+}
+
+// isLoopback reports whether the TCP peer of the request is the local machine.
+// RemoteAddr is used instead of ClientIP so forwarded headers cannot spoof it.
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := ""
@@ -73,6 +131,15 @@ func AuthMiddleware() gin.HandlerFunc {
 			}
 		}
 
+		if tokenString == "" && localUser != nil && isLoopback(c.Request.RemoteAddr) {
+			if session, err := c.Cookie(localSessionCookie); err == nil && isLocalSecret(session) {
+				c.Set("user_id", localUser.UserID)
+				c.Set("role", localUser.Role)
+				c.Next()
+				return
+			}
+		}
+
 		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
 			c.Abort()
@@ -89,6 +156,10 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		c.Set("user_id", claims.UserID)
 		c.Set("role", claims.Role)
+		if claims.ImpersonatorID != 0 {
+			c.Set("impersonator_id", claims.ImpersonatorID)
+			recordImpersonationEvent(c, claims.ImpersonatorID, claims.UserID, "request", c.Request.Method+" "+c.Request.URL.Path)
+		}
 		c.Next()
 	}
 }
@@ -36,7 +36,7 @@ func RequireRole(allowedRoles ...string) gin.HandlerFunc {
 
 		if !isAllowed {
 			c.JSON(http.StatusForbidden, gin.H{
-				"error": "У вас нет прав для выполнения этого действия", // Access Denied
+				"error": "You do not have permission to perform this action", // Access Denied
 			})
 			c.Abort()
 			return
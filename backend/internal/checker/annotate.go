@@ -0,0 +1,341 @@
+package checker
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAnnotationUnsupported is returned for packages other than DOCX.
+var ErrAnnotationUnsupported = errors.New("annotated copies are only available for DOCX documents")
+
+const (
+	commentsRelType     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments"
+	commentsContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.comments+xml"
+	wordprocessingNS    = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+)
+
+// Annotate writes a copy of the DOCX at filePath with a Word comment for
+// every violation: the description, the expected and actual values and the
+// suggestion. Comments are anchored to the body paragraph of the violation,
+// to the first paragraph of its table, or, for violations of the whole
+// document, to the first paragraph. Comments already in the document are
+// kept. It returns how many comments were added.
+func (s *CheckService) Annotate(filePath string, violations []models.Violation, author string, w io.Writer) (int, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+	r := &zr.Reader
+	if err := checkZipPackage(r.File, s.Parser.Limits); err != nil {
+		return 0, err
+	}
+	docXML := zipEntry(r, "word/document.xml")
+	if docXML == nil {
+		return 0, ErrAnnotationUnsupported
+	}
+	read := func(f *zip.File) ([]byte, error) {
+		return readEntryLimited(f, s.Parser.Limits, s.Parser.Limits.MaxXMLBytes)
+	}
+	data, err := read(docXML)
+	if err != nil {
+		return 0, err
+	}
+
+	parts := map[string][]byte{}
+	commentsPart, rels, err := commentsPartName(r, read)
+	if err != nil {
+		return 0, err
+	}
+	var comments []byte
+	if f := zipEntry(r, commentsPart); f != nil {
+		if comments, err = read(f); err != nil {
+			return 0, err
+		}
+	}
+	firstID := nextCommentID(comments)
+
+	// Comment ids in violation order, grouped by anchor
+	paragraphs, tables := map[int][]int{}, map[int][]int{}
+	texts := make([]string, 0, len(violations))
+	for i, v := range violations {
+		id := firstID + i
+		texts = append(texts, annotationText(v))
+		switch loc := v.Location; {
+		case loc != nil && loc.TableIndex != nil:
+			tables[*loc.TableIndex] = append(tables[*loc.TableIndex], id)
+		case loc != nil && loc.ParagraphIndex != nil:
+			paragraphs[*loc.ParagraphIndex] = append(paragraphs[*loc.ParagraphIndex], id)
+		default:
+			paragraphs[0] = append(paragraphs[0], id)
+		}
+	}
+
+	annotated, anchored, err := anchorComments(data, paragraphs, tables)
+	if err != nil {
+		return 0, err
+	}
+	if len(anchored) == 0 {
+		return 0, writePackage(r, nil, w)
+	}
+	parts[docXML.Name] = annotated
+	parts[commentsPart] = appendComments(comments, firstID, texts, anchored, author)
+	if rels != nil {
+		parts["word/_rels/document.xml.rels"] = rels
+	}
+	if f := zipEntry(r, "[Content_Types].xml"); f != nil {
+		types, err := read(f)
+		if err != nil {
+			return 0, err
+		}
+		if override := `PartName="/` + commentsPart + `"`; !bytes.Contains(types, []byte(override)) {
+			parts[f.Name] = insertBeforeClose(types, "Types",
+				`<Override `+override+` ContentType="`+commentsContentType+`"/>`)
+		}
+	}
+	return len(anchored), writePackage(r, parts, w)
+}
+
+// commentsPartName returns the comments part of the document. When the
+// document has none, the relationships are returned with one added for
+// word/comments.xml.
+func commentsPartName(r *zip.Reader, read func(*zip.File) ([]byte, error)) (string, []byte, error) {
+	f := zipEntry(r, "word/_rels/document.xml.rels")
+	if f == nil {
+		rels := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="` + commentsRelType + `" Target="comments.xml"/></Relationships>`
+		return "word/comments.xml", []byte(rels), nil
+	}
+	data, err := read(f)
+	if err != nil {
+		return "", nil, err
+	}
+	var rels Relationships
+	if err := xml.Unmarshal(data, &rels); err != nil {
+		return "", nil, fmt.Errorf("xml decode error: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, rel := range rels.Rels {
+		if rel.Type == commentsRelType {
+			return packagePartName(rel.Target), nil, nil
+		}
+		ids[rel.ID] = true
+	}
+	id := "rIdComments"
+	for n := 1; ids[id]; n++ {
+		id = "rIdComments" + strconv.Itoa(n)
+	}
+	return "word/comments.xml", insertBeforeClose(data, "Relationships",
+		`<Relationship Id="`+id+`" Type="`+commentsRelType+`" Target="comments.xml"/>`), nil
+}
+
+// nextCommentID returns the first comment id not taken in a comments part.
+func nextCommentID(comments []byte) int {
+	next := 0
+	dec := xml.NewDecoder(bytes.NewReader(comments))
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return next
+		}
+		if t, ok := tok.(xml.StartElement); ok && t.Name.Local == "comment" {
+			for _, a := range t.Attr {
+				if a.Name.Local == "id" {
+					if id, err := strconv.Atoi(a.Value); err == nil && id >= next {
+						next = id + 1
+					}
+				}
+			}
+		}
+	}
+}
+
+// annotationText is the text of the comment on v.
+func annotationText(v models.Violation) string {
+	lines := []string{v.Description}
+	if v.ExpectedValue != "" || v.ActualValue != "" {
+		lines = append(lines, fmt.Sprintf("Ожидается: %s; фактически: %s", v.ExpectedValue, v.ActualValue))
+	}
+	if v.Suggestion != "" {
+		lines = append(lines, v.Suggestion)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// anchorComments marks the anchors of the comments in document.xml: the
+// commented paragraph is wrapped in a comment range followed by the comment
+// reference. It returns the ids of the comments that found their anchor.
+func anchorComments(data []byte, paragraphs, tables map[int][]int) ([]byte, map[int]bool, error) {
+	anchored := map[int]bool{}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	out.Grow(len(data))
+	copied := int64(0)
+	var stack []string
+	paragraph, table := -1, -1
+
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("xml decode error: %v", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 2 && stack[1] == "body" && (t.Name.Local == "p" || t.Name.Local == "tbl") {
+				var ids []int
+				if t.Name.Local == "p" {
+					paragraph++
+					ids = paragraphs[paragraph]
+				} else {
+					table++
+					ids = tables[table]
+				}
+				if len(ids) == 0 {
+					stack = append(stack, t.Name.Local)
+					break
+				}
+				node, err := readXMLNode(dec, t.Copy())
+				if err != nil {
+					return nil, nil, fmt.Errorf("xml decode error: %v", err)
+				}
+				target := node
+				if t.Name.Local == "tbl" {
+					if found := node.descendants("p"); len(found) > 0 {
+						target = found[0]
+					} else {
+						target = nil
+					}
+				}
+				if target != nil {
+					addCommentRange(target, ids)
+					for _, id := range ids {
+						anchored[id] = true
+					}
+				}
+				out.Write(data[copied:offset])
+				node.writeTo(&out)
+				copied = dec.InputOffset()
+				continue
+			}
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	out.Write(data[copied:])
+	return out.Bytes(), anchored, nil
+}
+
+// addCommentRange wraps the content of paragraph p in the ranges of the
+// comments ids and appends their references.
+func addCommentRange(p *xmlNode, ids []int) {
+	prefix := p.start.Name.Space
+	marker := func(local string, id int) *xmlNode {
+		return &xmlNode{elem: true, start: xml.StartElement{
+			Name: xml.Name{Space: prefix, Local: local},
+			Attr: []xml.Attr{{Name: xml.Name{Space: prefix, Local: "id"}, Value: strconv.Itoa(id)}},
+		}}
+	}
+	at := 0
+	for i, c := range p.children {
+		if c.is(prefix, "pPr") {
+			at = i + 1
+			break
+		}
+	}
+	var starts, ends []*xmlNode
+	for _, id := range ids {
+		starts = append(starts, marker("commentRangeStart", id))
+		run := &xmlNode{elem: true, start: xml.StartElement{Name: xml.Name{Space: prefix, Local: "r"}}}
+		run.children = []*xmlNode{marker("commentReference", id)}
+		ends = append(ends, marker("commentRangeEnd", id), run)
+	}
+	children := append([]*xmlNode{}, p.children[:at]...)
+	children = append(children, starts...)
+	children = append(children, p.children[at:]...)
+	p.children = append(children, ends...)
+}
+
+// appendComments adds the comments that found an anchor to a comments part,
+// creating the part when comments is empty. Comment ids start at firstID in
+// the order of texts.
+func appendComments(comments []byte, firstID int, texts []string, anchored map[int]bool, author string) []byte {
+	prefix := "w"
+	if len(comments) > 0 {
+		dec := xml.NewDecoder(bytes.NewReader(comments))
+		for {
+			tok, err := dec.RawToken()
+			if err != nil {
+				break
+			}
+			if t, ok := tok.(xml.StartElement); ok {
+				prefix = t.Name.Space
+				break
+			}
+		}
+	}
+	q := func(local string) string {
+		if prefix == "" {
+			return local
+		}
+		return prefix + ":" + local
+	}
+	escape := func(s string) string {
+		var buf bytes.Buffer
+		xml.EscapeText(&buf, []byte(s))
+		return buf.String()
+	}
+
+	date := time.Now().UTC().Format(time.RFC3339)
+	initials := ""
+	for _, word := range strings.Fields(author) {
+		initials += string([]rune(word)[:1])
+	}
+	var b strings.Builder
+	for i, text := range texts {
+		id := firstID + i
+		if !anchored[id] {
+			continue
+		}
+		fmt.Fprintf(&b, `<%s %s="%d" %s="%s" %s="%s" %s="%s">`, q("comment"), q("id"), id,
+			q("author"), escape(author), q("date"), date, q("initials"), escape(initials))
+		for _, line := range strings.Split(text, "\n") {
+			fmt.Fprintf(&b, `<%s><%s><%s xml:space="preserve">%s</%s></%s></%s>`,
+				q("p"), q("r"), q("t"), escape(line), q("t"), q("r"), q("p"))
+		}
+		b.WriteString("</" + q("comment") + ">")
+	}
+
+	if len(comments) == 0 {
+		return []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<w:comments xmlns:w="` + wordprocessingNS + `">` + b.String() + `</w:comments>`)
+	}
+	return insertBeforeClose(comments, q("comments"), b.String())
+}
+
+// insertBeforeClose inserts markup before the end tag of the root element
+// named qname, or expands the root when it is an empty element.
+func insertBeforeClose(data []byte, qname, markup string) []byte {
+	if i := bytes.LastIndex(data, []byte("</"+qname)); i >= 0 {
+		return append(append(append([]byte{}, data[:i]...), markup...), data[i:]...)
+	}
+	if i := bytes.LastIndex(data, []byte("/>")); i >= 0 {
+		return append(append(append([]byte{}, data[:i]...), ">"+markup+"</"+qname+">"...), data[i+2:]...)
+	}
+	return data
+}
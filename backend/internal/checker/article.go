@@ -0,0 +1,454 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Document types of StructureConfig.DocumentType.
+const (
+	documentTypeCoursework = ""
+	documentTypeArticle    = "article"
+)
+
+// ArticleConfig holds the requirements of a journal to the articles it
+// publishes. They are checked when StructureConfig.DocumentType is "article";
+// the rest of the standard (fonts, margins, references) applies as usual.
+type ArticleConfig struct {
+	RequireUDC         bool   `json:"require_udc"`         // "УДК 004.42" before the title
+	MaxAuthors         int    `json:"max_authors"`         // 0 = any number
+	RequireAffiliation bool   `json:"require_affiliation"` // organization or e-mail of the authors
+	AbstractLanguages  string `json:"abstract_languages"`  // comma-separated: ru, en
+	MinAbstractWords   int    `json:"min_abstract_words"`  // 0 = not checked
+	MaxAbstractWords   int    `json:"max_abstract_words"`  // 0 = not checked
+	RequireKeywords    bool   `json:"require_keywords"`    // in every abstract language
+	MinKeywords        int    `json:"min_keywords"`        // 0 = not checked
+	MaxKeywords        int    `json:"max_keywords"`        // 0 = not checked
+	ReferenceStyle     string `json:"reference_style"`     // gost, apa, vancouver, ieee; empty = not checked
+	Columns            int    `json:"columns"`             // text columns of the main section, 0 = not checked
+}
+
+var articleLanguageNames = map[string]string{
+	"ru": "русском",
+	"en": "английском",
+}
+
+var (
+	articleUDCRegex      = regexp.MustCompile(`^(?i)(?:УДК|UDC)\s*(\S.*)?$`)
+	articleUDCCodeRegex  = regexp.MustCompile(`^\d[\d.:()+\-/\[\]'"«» ]*$`)
+	articleAbstractRegex = regexp.MustCompile(`^(?i)(аннотация|резюме|abstract|summary)(?:\s*[.:—–-]\s*|\s+|$)`)
+	articleKeywordsRegex = regexp.MustCompile(`^(?i)(ключевые\s+слова|keywords|key\s+words)(?:\s*[.:—–-]\s*|\s+|$)`)
+	// "И. И. Иванов" or "Иванов И. И.", in Cyrillic or Latin letters.
+	articleAuthorRegex      = regexp.MustCompile(`(?:\p{Lu}\.\s?){1,2}\s?\p{Lu}\p{Ll}+(?:-\p{Lu}\p{Ll}+)?|\p{Lu}\p{Ll}+(?:-\p{Lu}\p{Ll}+)?\s(?:\p{Lu}\.\s?){1,2}`)
+	articleEmailRegex       = regexp.MustCompile(`[\w.+-]+@[\w-]+\.[\w.-]+`)
+	articleAffiliationRegex = regexp.MustCompile(`(?i)университет|институт|академи|колледж|лаборатори|центр|предприяти|ООО|АО |university|institute|academy|college|laborator|center|centre|ltd|inc\.`)
+)
+
+// articleLabel is an abstract or keywords label with its text: the rest of
+// the paragraph or, for a label on a line of its own, the next paragraph.
+type articleLabel struct {
+	index    int // paragraph of the label
+	language string
+	text     string
+}
+
+// checkArticle checks the front matter of a journal article: the UDC code,
+// the authors and their affiliation, the abstract and keywords in every
+// required language, the reference style and the column layout.
+func checkArticle(doc *ParsedDoc, cfg ArticleConfig, refs ReferencesConfig) ([]models.Violation, int) {
+	var violations []models.Violation
+	totalRules := 0
+	paragraphs := doc.Paragraphs
+	at := func(i int, v models.Violation) models.Violation {
+		if i < 0 || i >= len(paragraphs) {
+			v.PositionInDoc = "Начало статьи"
+			return v
+		}
+		p := paragraphs[i]
+		v.PositionInDoc = fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(strings.TrimSpace(p.Text), 100))
+		v.ContextText = contextSnippet(p.Text)
+		v.Location = paragraphLocation(i, p)
+		return v
+	}
+
+	abstracts, keywords := articleLabels(paragraphs)
+	front := articleFrontMatter(paragraphs, abstracts, keywords)
+
+	if cfg.RequireUDC {
+		totalRules++
+		udc := -1
+		for i := 0; i < front; i++ {
+			if articleUDCRegex.MatchString(strings.TrimSpace(paragraphs[i].Text)) {
+				udc = i
+				break
+			}
+		}
+		if udc < 0 {
+			violations = append(violations, at(-1, models.Violation{
+				RuleType:      "article_udc",
+				Description:   "Не указан индекс УДК",
+				ExpectedValue: "УДК 004.42",
+				ActualValue:   "Не найден",
+				Suggestion:    "Укажите индекс УДК отдельной строкой перед названием статьи",
+				Severity:      "error",
+			}))
+		} else if m := articleUDCRegex.FindStringSubmatch(strings.TrimSpace(paragraphs[udc].Text)); !articleUDCCodeRegex.MatchString(strings.TrimSpace(m[1])) {
+			violations = append(violations, at(udc, models.Violation{
+				RuleType:      "article_udc",
+				Description:   "Индекс УДК указан без кода",
+				ExpectedValue: "УДК 004.42",
+				ActualValue:   truncate(strings.TrimSpace(paragraphs[udc].Text), 40),
+				Suggestion:    "После «УДК» укажите код по классификатору, например «УДК 004.42»",
+				Severity:      "error",
+			}))
+		}
+	}
+
+	if cfg.MaxAuthors > 0 || cfg.RequireAffiliation {
+		authors, firstAuthor := 0, -1
+		affiliation := false
+		for i := 0; i < front; i++ {
+			text := strings.TrimSpace(paragraphs[i].Text)
+			if text == "" || articleUDCRegex.MatchString(text) {
+				continue
+			}
+			if articleEmailRegex.MatchString(text) || articleAffiliationRegex.MatchString(text) {
+				affiliation = true
+				continue
+			}
+			if utf8.RuneCountInString(text) > 200 {
+				continue
+			}
+			if names := articleAuthorRegex.FindAllString(text, -1); len(names) > 0 {
+				if firstAuthor < 0 {
+					firstAuthor = i
+				}
+				authors += len(names)
+			}
+		}
+
+		totalRules++
+		if authors == 0 {
+			violations = append(violations, at(-1, models.Violation{
+				RuleType:      "article_authors",
+				Description:   "Не найдены авторы статьи",
+				ExpectedValue: "Инициалы и фамилии авторов",
+				ActualValue:   "Не найдены",
+				Suggestion:    "Укажите авторов после названия статьи в виде «И. И. Иванов»",
+				Severity:      "error",
+				IsDoubtful:    true,
+			}))
+		} else if cfg.MaxAuthors > 0 && authors > cfg.MaxAuthors {
+			violations = append(violations, at(firstAuthor, models.Violation{
+				RuleType:      "article_authors",
+				Description:   "Слишком много авторов",
+				ExpectedValue: fmt.Sprintf("не более %d", cfg.MaxAuthors),
+				ActualValue:   fmt.Sprintf("%d", authors),
+				Suggestion:    "Журнал ограничивает число авторов одной статьи",
+				Severity:      "error",
+			}))
+		}
+		if cfg.RequireAffiliation {
+			totalRules++
+			if !affiliation {
+				violations = append(violations, at(firstAuthor, models.Violation{
+					RuleType:      "article_affiliation",
+					Description:   "Не указано место работы авторов",
+					ExpectedValue: "Организация, город, e-mail",
+					ActualValue:   "Не найдено",
+					Suggestion:    "Под фамилиями авторов укажите организацию, город и адрес электронной почты",
+					Severity:      "error",
+					IsDoubtful:    true,
+				}))
+			}
+		}
+	}
+
+	for _, lang := range articleLanguages(cfg.AbstractLanguages) {
+		totalRules++
+		abstract, ok := findArticleLabel(abstracts, lang)
+		if !ok {
+			violations = append(violations, at(-1, models.Violation{
+				RuleType:      "article_abstract",
+				Description:   fmt.Sprintf("Нет аннотации на %s языке", articleLanguageNames[lang]),
+				ExpectedValue: map[string]string{"ru": "Аннотация", "en": "Abstract"}[lang],
+				ActualValue:   "Не найдена",
+				Suggestion:    "Начните аннотацию со слова «Аннотация» или «Abstract»",
+				Severity:      "error",
+			}))
+		} else {
+			words := len(strings.Fields(abstract.text))
+			if textLanguage(abstract.text) != lang {
+				violations = append(violations, at(abstract.index, models.Violation{
+					RuleType:      "article_abstract",
+					Description:   fmt.Sprintf("Аннотация должна быть на %s языке", articleLanguageNames[lang]),
+					ExpectedValue: lang,
+					ActualValue:   textLanguage(abstract.text),
+					Suggestion:    "Проверьте, что под заголовком аннотации текст на нужном языке",
+					Severity:      "error",
+				}))
+			} else if (cfg.MinAbstractWords > 0 && words < cfg.MinAbstractWords) || (cfg.MaxAbstractWords > 0 && words > cfg.MaxAbstractWords) {
+				violations = append(violations, at(abstract.index, models.Violation{
+					RuleType:      "article_abstract",
+					Description:   "Объём аннотации не соответствует требованиям журнала",
+					ExpectedValue: wordRange(cfg.MinAbstractWords, cfg.MaxAbstractWords),
+					ActualValue:   fmt.Sprintf("%d слов", words),
+					Severity:      "warning",
+				}))
+			}
+		}
+
+		if !cfg.RequireKeywords {
+			continue
+		}
+		totalRules++
+		kw, ok := findArticleLabel(keywords, lang)
+		if !ok {
+			violations = append(violations, at(abstract.index, models.Violation{
+				RuleType:      "article_keywords",
+				Description:   fmt.Sprintf("Нет ключевых слов на %s языке", articleLanguageNames[lang]),
+				ExpectedValue: map[string]string{"ru": "Ключевые слова: ...", "en": "Keywords: ..."}[lang],
+				ActualValue:   "Не найдены",
+				Suggestion:    "Приведите ключевые слова после аннотации через запятую",
+				Severity:      "error",
+			}))
+			continue
+		}
+		n := 0
+		for _, k := range strings.FieldsFunc(kw.text, func(r rune) bool { return r == ',' || r == ';' }) {
+			if strings.Trim(k, " .") != "" {
+				n++
+			}
+		}
+		if (cfg.MinKeywords > 0 && n < cfg.MinKeywords) || (cfg.MaxKeywords > 0 && n > cfg.MaxKeywords) {
+			violations = append(violations, at(kw.index, models.Violation{
+				RuleType:      "article_keywords",
+				Description:   "Число ключевых слов не соответствует требованиям журнала",
+				ExpectedValue: countRange(cfg.MinKeywords, cfg.MaxKeywords),
+				ActualValue:   fmt.Sprintf("%d", n),
+				Severity:      "warning",
+			}))
+		}
+	}
+
+	if cfg.ReferenceStyle != "" {
+		vs, rules := checkReferenceStyle(paragraphs, cfg.ReferenceStyle, refs)
+		violations = append(violations, vs...)
+		totalRules += rules
+	}
+
+	if cfg.Columns > 0 && len(doc.Sections) > 0 {
+		totalRules++
+		s := doc.Sections[mainSection(doc.Sections)]
+		if s.Columns != cfg.Columns {
+			first := firstTextParagraph(paragraphs, s)
+			violations = append(violations, at(first, models.Violation{
+				RuleType:      "article_columns",
+				Description:   "Число колонок текста не соответствует шаблону журнала",
+				ExpectedValue: fmt.Sprintf("%d", cfg.Columns),
+				ActualValue:   fmt.Sprintf("%d", s.Columns),
+				Suggestion:    "Задайте колонки в «Макет → Колонки»; заголовочную часть статьи отделите разрывом раздела на текущей странице",
+				Severity:      "error",
+			}))
+		}
+	}
+
+	return violations, totalRules
+}
+
+// articleLabels finds the abstract and keywords labels of the article. An
+// English block often closes the article, so the whole text is searched.
+func articleLabels(paragraphs []ParsedParagraph) (abstracts, keywords []articleLabel) {
+	for i, p := range paragraphs {
+		text := strings.TrimSpace(p.Text)
+		if p.Role == "toc" || text == "" {
+			continue
+		}
+		for _, kind := range []struct {
+			re   *regexp.Regexp
+			into *[]articleLabel
+		}{{articleAbstractRegex, &abstracts}, {articleKeywordsRegex, &keywords}} {
+			m := kind.re.FindStringSubmatch(text)
+			if m == nil {
+				continue
+			}
+			label := articleLabel{index: i, language: "en", text: strings.TrimSpace(text[len(m[0]):])}
+			if textLanguage(m[1]) == "ru" {
+				label.language = "ru"
+			}
+			if label.text == "" {
+				label.text = nextText(paragraphs, i)
+			}
+			*kind.into = append(*kind.into, label)
+		}
+	}
+	return abstracts, keywords
+}
+
+// articleFrontMatter returns the index after the title block: the first
+// abstract or keywords label, else the first heading past the title.
+func articleFrontMatter(paragraphs []ParsedParagraph, abstracts, keywords []articleLabel) int {
+	end := len(paragraphs)
+	for _, labels := range [][]articleLabel{abstracts, keywords} {
+		if len(labels) > 0 && labels[0].index < end {
+			end = labels[0].index
+		}
+	}
+	if end == len(paragraphs) {
+		seenText := false
+		for i, p := range paragraphs {
+			if strings.TrimSpace(p.Text) == "" {
+				continue
+			}
+			if seenText && isHeadingParagraph(p) {
+				return i
+			}
+			seenText = true
+		}
+	}
+	return end
+}
+
+func findArticleLabel(labels []articleLabel, lang string) (articleLabel, bool) {
+	for _, l := range labels {
+		if l.language == lang {
+			return l, true
+		}
+	}
+	return articleLabel{index: -1}, false
+}
+
+// articleLanguages splits the configured abstract languages, skipping the
+// ones the checker does not know.
+func articleLanguages(list string) []string {
+	var langs []string
+	for _, l := range strings.Split(list, ",") {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if _, ok := articleLanguageNames[l]; ok {
+			langs = append(langs, l)
+		}
+	}
+	return langs
+}
+
+// textLanguage tells Russian from English text by the letters it has most.
+func textLanguage(text string) string {
+	cyrillic, latin := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	if cyrillic >= latin {
+		return "ru"
+	}
+	return "en"
+}
+
+// nextText returns the text of the first non-empty paragraph after i.
+func nextText(paragraphs []ParsedParagraph, i int) string {
+	for j := i + 1; j < len(paragraphs); j++ {
+		if text := strings.TrimSpace(paragraphs[j].Text); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+func wordRange(min, max int) string {
+	return countRange(min, max) + " слов"
+}
+
+func countRange(min, max int) string {
+	switch {
+	case min > 0 && max > 0:
+		return fmt.Sprintf("%d–%d", min, max)
+	case min > 0:
+		return fmt.Sprintf("не менее %d", min)
+	default:
+		return fmt.Sprintf("не более %d", max)
+	}
+}
+
+// Reference entry patterns of the journal styles other than GOST.
+var (
+	// APA: "Ivanov, I. I. (2020). Title." — unnumbered, the year in brackets after the authors.
+	refAPARegex = regexp.MustCompile(`^\p{Lu}\p{L}+(?:-\p{Lu}\p{L}+)?, (?:\p{Lu}\.\s?)+.*?\((?:19|20)\d{2}[a-z]?\)\.`)
+	// Vancouver: "Ivanov II, Petrov PP. Title. Journal. 2020;12(3):45-50."
+	refVancouverRegex = regexp.MustCompile(`^\p{Lu}\p{L}+(?:-\p{Lu}\p{L}+)? \p{Lu}{1,3}(?:, \p{Lu}\p{L}+(?:-\p{Lu}\p{L}+)? \p{Lu}{1,3})*(?:,? et al| и др)?\. .+(?:19|20)\d{2}`)
+	// IEEE: "[1] I. I. Ivanov, "Title," Journal, vol. 1, pp. 2–3, 2020."
+	refIEEERegex       = regexp.MustCompile(`^(?:\p{Lu}\.\s?)+\p{Lu}\p{L}+.*[“"«].+[”"»].*(?:19|20)\d{2}`)
+	refIEEENumberRegex = regexp.MustCompile(`^\[\d+\]\s*`)
+)
+
+var referenceStyleNames = map[string]string{
+	"gost":      "ГОСТ 7.0.100-2018",
+	"apa":       "APA",
+	"vancouver": "Vancouver",
+	"ieee":      "IEEE",
+}
+
+// checkReferenceStyle checks the bibliography against the style of the
+// journal. GOST entries go through checkReferenceEntries, unless the
+// references section of the standard checks them already; for the other
+// styles every entry is matched against the pattern of the style.
+func checkReferenceStyle(paragraphs []ParsedParagraph, style string, refs ReferencesConfig) ([]models.Violation, int) {
+	if style == "gost" {
+		if refs.CheckEntryFormat {
+			return nil, 0
+		}
+		return checkReferenceEntries(paragraphs, refs)
+	}
+	if _, ok := referenceStyleNames[style]; !ok {
+		return nil, 0
+	}
+
+	var vs []models.Violation
+	rules := 0
+	for _, e := range bibliographyEntries(paragraphs, refs) {
+		rules++
+		body := e.text
+		ok := false
+		switch style {
+		case "apa":
+			ok = refAPARegex.MatchString(body)
+		case "vancouver":
+			if m := refNumberRegex.FindString(body); m != "" {
+				body = body[len(m):]
+			}
+			ok = refVancouverRegex.MatchString(body)
+		case "ieee":
+			m := refIEEENumberRegex.FindString(body)
+			body = body[len(m):]
+			ok = (m != "" || e.para.IsListItem) && refIEEERegex.MatchString(body)
+		}
+		if ok {
+			continue
+		}
+		vs = append(vs, models.Violation{
+			RuleType:      "article_reference_style",
+			Description:   fmt.Sprintf("Источник оформлен не по стилю %s", referenceStyleNames[style]),
+			PositionInDoc: fmt.Sprintf("Page %d, Para %d: %s", e.para.PageNumber, e.index+1, truncate(e.text, 80)),
+			ExpectedValue: referenceStyleExamples[style],
+			ActualValue:   truncate(e.text, 80),
+			Suggestion:    "Оформите источник по образцу из требований журнала",
+			Severity:      "warning",
+			ContextText:   truncate(e.text, 150),
+			Location:      paragraphLocation(e.index, e.para),
+		})
+	}
+	return vs, rules
+}
+
+var referenceStyleExamples = map[string]string{
+	"apa":       "Ivanov, I. I. (2020). Title. Journal, 12(3), 45–50.",
+	"vancouver": "1. Ivanov II, Petrov PP. Title. Journal. 2020;12(3):45-50.",
+	"ieee":      "[1] I. I. Ivanov, \"Title,\" Journal, vol. 12, no. 3, pp. 45–50, 2020.",
+}
@@ -0,0 +1,684 @@
+package checker
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rules the corrector can fix. Each names the violations it removes:
+// "margins" covers margin_top, margin_bottom, margin_left and margin_right,
+// the others are the rule types of body text formatting.
+const (
+	FixMargins     = "margins"
+	FixFontName    = "font_name"
+	FixFontSize    = "font_size"
+	FixLineSpacing = "line_spacing"
+	FixIndent      = "indent"
+	FixAlignment   = "alignment"
+)
+
+// FixableRules lists the rules the corrector can fix, in report order.
+var FixableRules = []string{FixMargins, FixFontName, FixFontSize, FixLineSpacing, FixIndent, FixAlignment}
+
+// ErrAutoFixUnsupported is returned for packages other than DOCX.
+var ErrAutoFixUnsupported = errors.New("automatic correction is only available for DOCX documents")
+
+// FixReport tells what the corrector changed: for margins the number of
+// sections, for the other rules the number of paragraphs.
+type FixReport struct {
+	Fixed map[string]int `json:"fixed"`
+}
+
+// paragraphFix is the formatting a body paragraph is given. Zero values leave
+// the property as it is.
+type paragraphFix struct {
+	FontName    string
+	FontSize    float64 // pt
+	LineSpacing float64 // lines
+	Indent      *float64
+	Alignment   string // w:jc value
+}
+
+// fixPlan is what the corrector changes in word/document.xml. Paragraphs are
+// keyed by their index among the body paragraphs, as in ParsedDoc.Paragraphs.
+type fixPlan struct {
+	Margins    *MarginsConfig
+	Paragraphs map[int]*paragraphFix
+	// MainLandscape is the orientation of the main section; sections turned
+	// the other way get the margins rotated with the page.
+	MainLandscape bool
+}
+
+// ValidateFixRules reports an error when a rule cannot be fixed.
+func ValidateFixRules(rules []string) error {
+	for _, rule := range rules {
+		known := false
+		for _, fixable := range FixableRules {
+			known = known || rule == fixable
+		}
+		if !known {
+			return fmt.Errorf("rule %q cannot be fixed automatically; fixable rules: %s", rule, strings.Join(FixableRules, ", "))
+		}
+	}
+	return nil
+}
+
+// AutoFix checks the DOCX document at filePath against the standard and
+// writes to w a copy of the package in which the deterministic violations
+// are corrected: page margins and the font, size, line spacing, first-line
+// indent and alignment of body paragraphs. Only the given rules are fixed,
+// all fixable rules when none are given. Only what the check reported is
+// changed: the flagged paragraphs and, for margins, the sections. Every other
+// part of the package is copied unchanged.
+func (s *CheckService) AutoFix(ctx context.Context, filePath, contentHash, standardJSON string, rules []string, w io.Writer) (*FixReport, error) {
+	if err := ValidateFixRules(rules); err != nil {
+		return nil, err
+	}
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	if zipEntry(&zr.Reader, "word/document.xml") == nil {
+		return nil, ErrAutoFixUnsupported
+	}
+
+	doc, err := s.ParseCached(ctx, filePath, contentHash)
+	if err != nil {
+		return nil, err
+	}
+	_, violations, err := s.Evaluate(ctx, doc, standardJSON)
+	if err != nil {
+		return nil, err
+	}
+	var config ConfigSchema
+	if err := json.Unmarshal([]byte(standardJSON), &config); err != nil {
+		return nil, fmt.Errorf("invalid standard config: %v", err)
+	}
+
+	parts := documentParts(withHeadingDetection(doc, config.Structure.HeadingDetection).Paragraphs, config.References)
+	plan := planFixes(violations, config, parts, rules)
+	if len(doc.Sections) > 0 {
+		plan.MainLandscape = doc.Sections[mainSection(doc.Sections)].Orientation == "landscape"
+	}
+	var buf bytes.Buffer
+	fixed, err := fixPackage(&zr.Reader, plan, s.Parser.Limits, &buf)
+	if err != nil {
+		return nil, err
+	}
+	// The corrected package must still be readable by the checker.
+	if _, err := s.Parser.ParseReader(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		return nil, fmt.Errorf("corrected document cannot be read: %v", err)
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return &FixReport{Fixed: fixed}, nil
+}
+
+// planFixes turns the violations of the selected rules into changes. Body
+// paragraph violations are recognized by their paragraph location; those in
+// table cells carry a table index and are left alone. A paragraph is fixed
+// to the profile of its part of the document (see documentParts), if any.
+func planFixes(violations []models.Violation, config ConfigSchema, parts []string, rules []string) fixPlan {
+	selected := map[string]bool{}
+	for _, rule := range rules {
+		selected[rule] = true
+	}
+	enabled := func(rule string) bool { return len(rules) == 0 || selected[rule] }
+
+	plan := fixPlan{Paragraphs: map[int]*paragraphFix{}}
+	for _, v := range violations {
+		if strings.HasPrefix(v.RuleType, "margin_") {
+			if enabled(FixMargins) {
+				margins := config.Margins
+				plan.Margins = &margins
+			}
+			continue
+		}
+		if !enabled(v.RuleType) || v.Location == nil || v.Location.ParagraphIndex == nil || v.Location.TableIndex != nil {
+			continue
+		}
+		i := *v.Location.ParagraphIndex
+		fix := plan.Paragraphs[i]
+		if fix == nil {
+			fix = &paragraphFix{}
+		}
+		part := partBody
+		if i < len(parts) {
+			part = parts[i]
+		}
+		font, paragraph := config.Sections.formatting(part, config.Font, config.Paragraph)
+		switch v.RuleType {
+		case FixFontName:
+			fix.FontName = font.Name
+		case FixFontSize:
+			fix.FontSize = font.Size
+		case FixLineSpacing:
+			fix.LineSpacing = paragraph.LineSpacing
+		case FixIndent:
+			indent := paragraph.FirstLineIndent
+			fix.Indent = &indent
+		case FixAlignment:
+			fix.Alignment = normalizeAlignment(paragraph.Alignment)
+			if fix.Alignment == "justify" {
+				fix.Alignment = "both"
+			}
+		default:
+			continue
+		}
+		plan.Paragraphs[i] = fix
+	}
+	return plan
+}
+
+func zipEntry(r *zip.Reader, name string) *zip.File {
+	for _, f := range r.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// fixPackage writes the package with word/document.xml rewritten by plan.
+// The other entries are copied without recompression.
+func fixPackage(r *zip.Reader, plan fixPlan, limits ParserLimits, w io.Writer) (map[string]int, error) {
+	if err := checkZipPackage(r.File, limits); err != nil {
+		return nil, err
+	}
+	docXML := zipEntry(r, "word/document.xml")
+	if docXML == nil {
+		return nil, ErrAutoFixUnsupported
+	}
+	data, err := readEntryLimited(docXML, limits, limits.MaxXMLBytes)
+	if err != nil {
+		return nil, err
+	}
+	fixedXML, fixed, err := rewriteDocumentXML(data, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return fixed, writePackage(r, map[string][]byte{docXML.Name: fixedXML}, w)
+}
+
+// writePackage writes the package with the entries of parts replaced or, for
+// names the package lacks, added at its end. The other entries are copied
+// without recompression.
+func writePackage(r *zip.Reader, parts map[string][]byte, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	write := func(name string, modified time.Time) error {
+		out, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(parts[name])
+		return err
+	}
+	written := map[string]bool{}
+	for _, f := range r.File {
+		if _, ok := parts[f.Name]; !ok {
+			if err := zw.Copy(f); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := write(f.Name, f.Modified); err != nil {
+			return err
+		}
+		written[f.Name] = true
+	}
+	var added []string
+	for name := range parts {
+		if !written[name] {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		if err := write(name, time.Now()); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// rewriteDocumentXML applies plan to document.xml. The markup outside the
+// changed paragraphs and page margins is copied byte for byte.
+func rewriteDocumentXML(data []byte, plan fixPlan) ([]byte, map[string]int, error) {
+	fixed := map[string]int{}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	out.Grow(len(data))
+	copied := int64(0)
+	var stack []string
+	paragraph := -1
+	landscape := false
+
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("xml decode error: %v", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "p" && len(stack) == 2 && stack[1] == "body" {
+				paragraph++
+				fix := plan.Paragraphs[paragraph]
+				if fix == nil && plan.Margins == nil {
+					stack = append(stack, t.Name.Local)
+					break
+				}
+				node, err := readXMLNode(dec, t.Copy())
+				if err != nil {
+					return nil, nil, fmt.Errorf("xml decode error: %v", err)
+				}
+				changed := false
+				if fix != nil {
+					fixParagraph(node, fix, fixed)
+					changed = true
+				}
+				if plan.Margins != nil {
+					for _, sectPr := range node.descendants("sectPr") {
+						if fixSectionMargins(sectPr, plan) {
+							fixed[FixMargins]++
+							changed = true
+						}
+					}
+				}
+				if changed {
+					out.Write(data[copied:offset])
+					node.writeTo(&out)
+					copied = dec.InputOffset()
+				}
+				continue
+			}
+			switch t.Name.Local {
+			case "sectPr":
+				landscape = false
+			case "pgSz":
+				landscape = isLandscape(t.Attr)
+			case "pgMar":
+				if plan.Margins != nil {
+					end := dec.InputOffset()
+					attrs, ok := marginAttrs(t.Attr, t.Name.Space, sectionMargins(t.Attr, t.Name.Space, landscape, plan))
+					if ok {
+						fixed[FixMargins]++
+						out.Write(data[copied:offset])
+						t.Attr = attrs
+						writeStartElement(&out, t, bytes.HasSuffix(bytes.TrimSpace(data[offset:end]), []byte("/>")))
+						copied = end
+					}
+				}
+			}
+			stack = append(stack, t.Name.Local)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	out.Write(data[copied:])
+	return out.Bytes(), fixed, nil
+}
+
+// fixParagraph gives the paragraph and all its runs the formatting of fix.
+func fixParagraph(p *xmlNode, fix *paragraphFix, fixed map[string]int) {
+	prefix := p.start.Name.Space
+	if fix.LineSpacing > 0 || fix.Indent != nil || fix.Alignment != "" {
+		pPr := p.ensureChild(prefix, "pPr", []string{"pPr"})
+		if fix.LineSpacing > 0 {
+			spacing := pPr.ensureChild(prefix, "spacing", pPrOrder)
+			spacing.setAttr(prefix, "line", strconv.Itoa(int(math.Round(fix.LineSpacing*240))))
+			spacing.setAttr(prefix, "lineRule", "auto")
+			fixed[FixLineSpacing]++
+		}
+		if fix.Indent != nil {
+			ind := pPr.ensureChild(prefix, "ind", pPrOrder)
+			ind.setAttr(prefix, "firstLine", strconv.Itoa(mmToTwips(*fix.Indent)))
+			for _, attr := range []string{"hanging", "firstLineChars", "hangingChars"} {
+				ind.removeAttr(prefix, attr)
+			}
+			fixed[FixIndent]++
+		}
+		if fix.Alignment != "" {
+			pPr.ensureChild(prefix, "jc", pPrOrder).setAttr(prefix, "val", fix.Alignment)
+			fixed[FixAlignment]++
+		}
+	}
+
+	if fix.FontName == "" && fix.FontSize <= 0 {
+		return
+	}
+	for _, r := range p.runs(prefix) {
+		rPr := r.ensureChild(prefix, "rPr", []string{"rPr"})
+		if fix.FontName != "" {
+			fonts := rPr.ensureChild(prefix, "rFonts", rPrOrder)
+			for _, attr := range []string{"ascii", "hAnsi", "cs"} {
+				fonts.setAttr(prefix, attr, fix.FontName)
+			}
+			// A theme font takes precedence over the explicit name.
+			for _, attr := range []string{"asciiTheme", "hAnsiTheme", "cstheme"} {
+				fonts.removeAttr(prefix, attr)
+			}
+		}
+		if fix.FontSize > 0 {
+			size := strconv.Itoa(int(math.Round(fix.FontSize * 2))) // half-points
+			rPr.ensureChild(prefix, "sz", rPrOrder).setAttr(prefix, "val", size)
+			rPr.ensureChild(prefix, "szCs", rPrOrder).setAttr(prefix, "val", size)
+		}
+	}
+	if fix.FontName != "" {
+		fixed[FixFontName]++
+	}
+	if fix.FontSize > 0 {
+		fixed[FixFontSize]++
+	}
+}
+
+// fixSectionMargins sets the margins of a section and reports whether they
+// changed.
+func fixSectionMargins(sectPr *xmlNode, plan fixPlan) bool {
+	prefix := sectPr.start.Name.Space
+	pgMar := sectPr.child(prefix, "pgMar")
+	if pgMar == nil {
+		return false
+	}
+	size := sectPr.child(prefix, "pgSz")
+	landscape := size != nil && isLandscape(size.start.Attr)
+	attrs, ok := marginAttrs(pgMar.start.Attr, prefix, sectionMargins(pgMar.start.Attr, prefix, landscape, plan))
+	pgMar.start.Attr = attrs
+	return ok
+}
+
+// sectionMargins returns the margins a section is given. A section turned
+// to the other orientation than the main one gets them rotated with the page,
+// the way that changes fewer of its current margins, as checkRotatedMargins
+// accepts either.
+func sectionMargins(attrs []xml.Attr, prefix string, landscape bool, plan fixPlan) MarginsConfig {
+	target := *plan.Margins
+	if landscape == plan.MainLandscape {
+		return target
+	}
+	clockwise := MarginsConfig{Top: target.Left, Right: target.Top, Bottom: target.Right, Left: target.Bottom}
+	counter := MarginsConfig{Top: target.Right, Right: target.Bottom, Bottom: target.Left, Left: target.Top}
+	node := &xmlNode{elem: true, start: xml.StartElement{Attr: attrs}}
+	changes := func(m MarginsConfig) int {
+		n := 0
+		for attr, mm := range map[string]float64{"top": m.Top, "bottom": m.Bottom, "left": m.Left, "right": m.Right} {
+			if mm > 0 && node.attr(prefix, attr) != strconv.Itoa(mmToTwips(mm)) {
+				n++
+			}
+		}
+		return n
+	}
+	if changes(counter) < changes(clockwise) {
+		return counter
+	}
+	return clockwise
+}
+
+// marginAttrs returns the pgMar attributes with the configured margins and
+// whether any of them changed. Margins not configured are kept.
+func marginAttrs(attrs []xml.Attr, prefix string, margins MarginsConfig) ([]xml.Attr, bool) {
+	node := &xmlNode{elem: true, start: xml.StartElement{Attr: append([]xml.Attr(nil), attrs...)}}
+	changed := false
+	for _, m := range []struct {
+		attr string
+		mm   float64
+	}{{"top", margins.Top}, {"bottom", margins.Bottom}, {"left", margins.Left}, {"right", margins.Right}} {
+		if m.mm <= 0 {
+			continue
+		}
+		value := strconv.Itoa(mmToTwips(m.mm))
+		if node.attr(prefix, m.attr) != value {
+			node.setAttr(prefix, m.attr, value)
+			changed = true
+		}
+	}
+	return node.start.Attr, changed
+}
+
+// isLandscape reports whether pgSz attributes describe a landscape page.
+func isLandscape(attrs []xml.Attr) bool {
+	var w, h int
+	for _, a := range attrs {
+		switch a.Name.Local {
+		case "orient":
+			if a.Value == "landscape" {
+				return true
+			}
+		case "w":
+			w, _ = strconv.Atoi(a.Value)
+		case "h":
+			h, _ = strconv.Atoi(a.Value)
+		}
+	}
+	return w > h && h > 0
+}
+
+func mmToTwips(mm float64) int {
+	return int(math.Round(mm / 25.4 * 1440))
+}
+
+// Child order of w:pPr and w:rPr in the WordprocessingML schema. Word rejects
+// documents whose properties are out of order, so new elements are inserted
+// at their place.
+var (
+	pPrOrder = []string{"pStyle", "keepNext", "keepLines", "pageBreakBefore", "framePr", "widowControl", "numPr",
+		"suppressLineNumbers", "pBdr", "shd", "tabs", "suppressAutoHyphens", "kinsoku", "wordWrap", "overflowPunct",
+		"topLinePunct", "autoSpaceDE", "autoSpaceDN", "bidi", "adjustRightInd", "snapToGrid", "spacing", "ind",
+		"contextualSpacing", "mirrorIndents", "suppressOverlap", "jc", "textDirection", "textAlignment",
+		"textboxTightWrap", "outlineLvl", "divId", "cnfStyle", "rPr", "sectPr", "pPrChange"}
+	rPrOrder = []string{"rStyle", "rFonts", "b", "bCs", "i", "iCs", "caps", "smallCaps", "strike", "dstrike",
+		"outline", "shadow", "emboss", "imprint", "noProof", "snapToGrid", "vanish", "webHidden", "color", "spacing",
+		"w", "kern", "position", "sz", "szCs", "highlight", "u", "effect", "bdr", "shd", "fitText", "vertAlign",
+		"rtl", "cs", "em", "lang", "eastAsianLayout", "specVanish", "oMath", "rPrChange"}
+)
+
+// xmlNode is an element read with its prefixes as written, so that it can be
+// written back without the namespace rewriting of encoding/xml. Tokens other
+// than elements are kept serialized in raw.
+type xmlNode struct {
+	elem     bool
+	start    xml.StartElement
+	raw      []byte
+	children []*xmlNode
+}
+
+// readXMLNode reads the content of start up to its end element.
+func readXMLNode(dec *xml.Decoder, start xml.StartElement) (*xmlNode, error) {
+	n := &xmlNode{elem: true, start: start}
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := readXMLNode(dec, t.Copy())
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		case xml.EndElement:
+			return n, nil
+		default:
+			var buf bytes.Buffer
+			writeToken(&buf, t)
+			n.children = append(n.children, &xmlNode{raw: buf.Bytes()})
+		}
+	}
+}
+
+func (n *xmlNode) is(prefix, local string) bool {
+	return n.elem && n.start.Name.Space == prefix && n.start.Name.Local == local
+}
+
+func (n *xmlNode) child(prefix, local string) *xmlNode {
+	for _, c := range n.children {
+		if c.is(prefix, local) {
+			return c
+		}
+	}
+	return nil
+}
+
+// descendants returns the elements named local at any depth.
+func (n *xmlNode) descendants(local string) []*xmlNode {
+	var found []*xmlNode
+	for _, c := range n.children {
+		if !c.elem {
+			continue
+		}
+		if c.start.Name.Local == local {
+			found = append(found, c)
+		}
+		found = append(found, c.descendants(local)...)
+	}
+	return found
+}
+
+// runs returns the text runs of a paragraph, including those inside
+// hyperlinks, fields and content controls. Paragraphs of text boxes anchored
+// in a run are not entered.
+func (n *xmlNode) runs(prefix string) []*xmlNode {
+	var found []*xmlNode
+	for _, c := range n.children {
+		switch {
+		case !c.elem || c.is(prefix, "pPr"):
+		case c.is(prefix, "r"):
+			found = append(found, c)
+		default:
+			found = append(found, c.runs(prefix)...)
+		}
+	}
+	return found
+}
+
+// ensureChild returns the child element named local, inserting an empty one
+// at its place in order when there is none. Children missing from order are
+// taken to follow all listed ones.
+func (n *xmlNode) ensureChild(prefix, local string, order []string) *xmlNode {
+	if c := n.child(prefix, local); c != nil {
+		return c
+	}
+	rank := func(name string) int {
+		for i, o := range order {
+			if o == name {
+				return i
+			}
+		}
+		return len(order)
+	}
+	want := rank(local)
+	at := len(n.children)
+	for i, c := range n.children {
+		if c.elem && rank(c.start.Name.Local) > want {
+			at = i
+			break
+		}
+	}
+	c := &xmlNode{elem: true, start: xml.StartElement{Name: xml.Name{Space: prefix, Local: local}}}
+	n.children = append(n.children[:at], append([]*xmlNode{c}, n.children[at:]...)...)
+	return c
+}
+
+func (n *xmlNode) attr(prefix, local string) string {
+	for _, a := range n.start.Attr {
+		if a.Name.Space == prefix && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *xmlNode) setAttr(prefix, local, value string) {
+	for i, a := range n.start.Attr {
+		if a.Name.Space == prefix && a.Name.Local == local {
+			n.start.Attr[i].Value = value
+			return
+		}
+	}
+	n.start.Attr = append(n.start.Attr, xml.Attr{Name: xml.Name{Space: prefix, Local: local}, Value: value})
+}
+
+func (n *xmlNode) removeAttr(prefix, local string) {
+	attrs := n.start.Attr[:0]
+	for _, a := range n.start.Attr {
+		if a.Name.Space != prefix || a.Name.Local != local {
+			attrs = append(attrs, a)
+		}
+	}
+	n.start.Attr = attrs
+}
+
+func (n *xmlNode) writeTo(buf *bytes.Buffer) {
+	if !n.elem {
+		buf.Write(n.raw)
+		return
+	}
+	writeStartElement(buf, n.start, len(n.children) == 0)
+	if len(n.children) == 0 {
+		return
+	}
+	for _, c := range n.children {
+		c.writeTo(buf)
+	}
+	buf.WriteString("</" + qualifiedName(n.start.Name) + ">")
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func writeStartElement(buf *bytes.Buffer, start xml.StartElement, selfClosing bool) {
+	buf.WriteString("<" + qualifiedName(start.Name))
+	for _, a := range start.Attr {
+		buf.WriteString(" " + qualifiedName(a.Name) + `="`)
+		xml.EscapeText(buf, []byte(a.Value))
+		buf.WriteString(`"`)
+	}
+	if selfClosing {
+		buf.WriteString("/>")
+	} else {
+		buf.WriteString(">")
+	}
+}
+
+// textEscaper escapes character data. Line breaks and tabs are kept as they
+// are, unlike with xml.EscapeText.
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+
+func writeToken(buf *bytes.Buffer, tok xml.Token) {
+	switch t := tok.(type) {
+	case xml.CharData:
+		buf.WriteString(textEscaper.Replace(string(t)))
+	case xml.Comment:
+		buf.WriteString("<!--" + string(t) + "-->")
+	case xml.ProcInst:
+		buf.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+	case xml.Directive:
+		buf.WriteString("<!" + string(t) + ">")
+	}
+}
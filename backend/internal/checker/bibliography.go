@@ -0,0 +1,324 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// referenceEntry is one paragraph of the bibliography section.
+type referenceEntry struct {
+	index int // paragraph index in the document
+	para  ParsedParagraph
+	text  string
+}
+
+// bibliographyEntries returns the non-empty paragraphs between the
+// bibliography heading (a short line containing title_keyword) and the next
+// heading.
+func bibliographyEntries(paragraphs []ParsedParagraph, cfg ReferencesConfig) []referenceEntry {
+	keyword := cfg.TitleKeyword
+	if keyword == "" {
+		keyword = "Список литературы"
+	}
+	lowerKW := strings.ToLower(keyword)
+
+	var entries []referenceEntry
+	inRefSection := false
+	for i, p := range paragraphs {
+		text := strings.TrimSpace(p.Text)
+		if text == "" {
+			continue
+		}
+
+		// Detect start of bibliography section: short line containing the keyword
+		// (no isHeadingParagraph requirement — students often use plain bold, not H1)
+		if strings.Contains(strings.ToLower(text), lowerKW) && len([]rune(text)) <= 120 {
+			inRefSection = true
+			continue
+		}
+		if !inRefSection {
+			continue
+		}
+		// Stop at the next heading after the bibliography
+		if isHeadingParagraph(p) {
+			break
+		}
+		entries = append(entries, referenceEntry{index: i, para: p, text: text})
+	}
+	return entries
+}
+
+var (
+	// "1. Иванов, И. И. ..." — the number typed by hand.
+	refNumberRegex = regexp.MustCompile(`^(\d+)[.)]\s*`)
+	// An entry starting with a surname followed by initials, in any spacing.
+	refAuthorRegex = regexp.MustCompile(`^(\p{Lu}\p{Ll}+(?:-\p{Lu}\p{Ll}+)?),?\s*(\p{Lu})\.\s*(?:(\p{Lu})\.)?`)
+	// GOST 7.0.100-2018: "Иванов, И. И." — comma after the surname, a space between initials.
+	refAuthorValidRegex = regexp.MustCompile(`^\p{Lu}\p{Ll}+(?:-\p{Lu}\p{Ll}+)?, \p{Lu}\.(?: \p{Lu}\.)?(?:\s|$)`)
+	// "И. И. Иванов" at the start of an entry: initials before the surname.
+	refInitialsFirstRegex = regexp.MustCompile(`^(\p{Lu})\.\s*(?:(\p{Lu})\.\s*)?(\p{Lu}\p{Ll}+)`)
+	// Content type and access medium in any spelling, e.g. "- Текст: электронный".
+	refMediumRegex      = regexp.MustCompile(`(?i)[–—-]?\s*текст\s*:\s*(непосредственный|электронный)`)
+	refMediumValidRegex = regexp.MustCompile(`– Текст : (непосредственный|электронный)`)
+	// Book imprint "Москва : Юрайт, 2021. – 320 с." or journal article
+	// "// Вестник. – 2021. – № 3. – С. 15–20."
+	refBookImprintRegex    = regexp.MustCompile(`\p{Lu}[\p{L}.\- ]*\s:\s[^,]+,\s(?:19|20)\d{2}\.\s[–—-]\s\d+\s?с\.`)
+	refArticleImprintRegex = regexp.MustCompile(`//.*(?:19|20)\d{2}.*[–—-]\s*С\.\s*\d+`)
+	refURLRegex            = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+	refURLLabelRegex       = regexp.MustCompile(`URL:\s*\S`)
+	refAccessDateRegex     = regexp.MustCompile(`\(дата обращения: \d{2}\.\d{2}\.\d{4}\)`)
+)
+
+// checkReferenceEntries checks the bibliography entries against GOST 7.0.100-2018:
+// numbering, author initials, the content type and medium element
+// ("– Текст : непосредственный"), the imprint (place, publisher, year, pages)
+// and, for electronic sources, the URL with the access date.
+func checkReferenceEntries(paragraphs []ParsedParagraph, cfg ReferencesConfig) ([]models.Violation, int) {
+	var vs []models.Violation
+	rules := 0
+
+	for n, e := range bibliographyEntries(paragraphs, cfg) {
+		pos := fmt.Sprintf("Page %d, Para %d: %s", e.para.PageNumber, e.index+1, truncate(e.text, 80))
+		add := func(ruleType, description, expected, actual, suggestion string) {
+			vs = append(vs, models.Violation{
+				RuleType:      ruleType,
+				Description:   description,
+				PositionInDoc: pos,
+				ExpectedValue: expected,
+				ActualValue:   actual,
+				Suggestion:    suggestion,
+				Severity:      "warning",
+				ContextText:   truncate(e.text, 150),
+				Location:      paragraphLocation(e.index, e.para),
+			})
+		}
+
+		// 1. Numbering: automatic list items are numbered by Word, typed
+		// numbers must follow the order of the list.
+		body := e.text
+		rules++
+		if m := refNumberRegex.FindStringSubmatch(e.text); m != nil {
+			body = e.text[len(m[0]):]
+			if num, _ := strconv.Atoi(m[1]); num != n+1 {
+				add("reference_numbering", "Нарушена нумерация списка литературы",
+					fmt.Sprintf("%d.", n+1), m[1]+".",
+					"Нумеруйте источники по порядку арабскими цифрами с точкой")
+			}
+		} else if !e.para.IsListItem {
+			add("reference_numbering", "Источник не пронумерован",
+				fmt.Sprintf("%d. %s", n+1, truncate(e.text, 40)), "Без номера",
+				"Пронумеруйте источники арабскими цифрами с точкой или оформите список как нумерованный")
+		}
+
+		// 2. Author: "Фамилия, И. О." Entries described under the title
+		// (standards, laws, collections) have no author and are skipped.
+		if m := refAuthorRegex.FindStringSubmatch(body); m != nil {
+			rules++
+			if !refAuthorValidRegex.MatchString(body) {
+				add("reference_author_format", "Неверная запись автора", referenceAuthor(m[1], m[2], m[3]),
+					strings.TrimSpace(m[0]), fmt.Sprintf("Запишите автора как «%s»", referenceAuthor(m[1], m[2], m[3])))
+			}
+		} else if m := refInitialsFirstRegex.FindStringSubmatch(body); m != nil {
+			rules++
+			add("reference_author_format", "Инициалы указаны перед фамилией", referenceAuthor(m[3], m[1], m[2]),
+				strings.TrimSpace(m[0]), fmt.Sprintf("В начале записи укажите фамилию, затем инициалы: «%s»", referenceAuthor(m[3], m[1], m[2])))
+		}
+
+		url := refURLRegex.FindString(e.text)
+		medium := "непосредственный"
+		if url != "" {
+			medium = "электронный"
+		}
+
+		// 3. Content type and access medium.
+		rules++
+		if m := refMediumRegex.FindStringSubmatch(e.text); m == nil {
+			add("reference_title_punctuation", "Не указаны вид содержания и средство доступа",
+				"– Текст : "+medium, "Не указано",
+				fmt.Sprintf("Добавьте в конце записи «– Текст : %s.»", medium))
+		} else if !refMediumValidRegex.MatchString(e.text) {
+			add("reference_title_punctuation", "Неверная пунктуация в указании вида содержания",
+				"– Текст : "+strings.ToLower(m[1]), strings.TrimSpace(m[0]),
+				"Отделите элемент тире с пробелами, а двоеточие — пробелами с обеих сторон")
+		}
+
+		// 4. Imprint: place, publisher, year and pages for printed sources.
+		if url == "" {
+			rules++
+			if !refBookImprintRegex.MatchString(e.text) && !refArticleImprintRegex.MatchString(e.text) {
+				add("reference_imprint", "Не найдены выходные данные источника",
+					"Место : Издательство, год. – N с.", "Не распознаны",
+					"Укажите выходные данные: «Москва : Юрайт, 2021. – 320 с.»; для статьи — «// Журнал. – 2021. – № 3. – С. 15–20.»")
+			}
+			continue
+		}
+
+		// 5. Electronic sources: "URL: ... (дата обращения: ДД.ММ.ГГГГ)".
+		rules++
+		var missing []string
+		if !refURLLabelRegex.MatchString(e.text) {
+			missing = append(missing, "«URL:» перед адресом")
+		}
+		if !refAccessDateRegex.MatchString(e.text) {
+			missing = append(missing, "дата обращения")
+		}
+		if len(missing) > 0 {
+			add("reference_url_access_date", "Неполное описание электронного ресурса",
+				"URL: адрес (дата обращения: ДД.ММ.ГГГГ)", "Нет: "+strings.Join(missing, ", "),
+				fmt.Sprintf("Запишите адрес как «URL: %s (дата обращения: ДД.ММ.ГГГГ).»", truncate(url, 60)))
+		}
+	}
+
+	return vs, rules
+}
+
+// referenceAuthor formats a surname and initials as "Иванов, И. И.".
+func referenceAuthor(surname, first, middle string) string {
+	author := surname + ", " + first + "."
+	if middle != "" {
+		author += " " + middle + "."
+	}
+	return author
+}
+
+// maxCitationRange bounds the ranges expanded in citations such as "[3–7]";
+// longer spans are more likely years or intervals than source numbers.
+const maxCitationRange = 50
+
+var (
+	// "[5]", "[12, с. 34]", "[1, 4–6]", "[3; 8, с. 12]"
+	citationRegex       = regexp.MustCompile(`\[([^\[\]]{1,80})\]`)
+	citationNumberRegex = regexp.MustCompile(`^(\d+)(?:\s*[–—-]\s*(\d+))?$`)
+	citationPageRegex   = regexp.MustCompile(`(?i)^(?:с|c|p|pp|s|стр)\.`)
+)
+
+// referenceNumber is the number of the n-th bibliography entry: the typed
+// number if there is one, otherwise its position in an automatic list.
+func referenceNumber(n int, e referenceEntry) int {
+	if m := refNumberRegex.FindStringSubmatch(e.text); m != nil {
+		if num, err := strconv.Atoi(m[1]); err == nil {
+			return num
+		}
+	}
+	return n + 1
+}
+
+// citedNumbers returns the source numbers of the bracket content of a
+// citation. Page numbers ("с. 34") are skipped. Content with a zero, like the
+// interval "[0, 1]", is not a citation and yields nil.
+func citedNumbers(content string) []int {
+	var nums []int
+	for _, group := range strings.Split(content, ";") {
+		for _, part := range strings.Split(group, ",") {
+			part = strings.TrimSpace(part)
+			if citationPageRegex.MatchString(part) {
+				break // the rest of the group are pages
+			}
+			m := citationNumberRegex.FindStringSubmatch(part)
+			if m == nil {
+				continue
+			}
+			from, _ := strconv.Atoi(m[1])
+			to := from
+			if m[2] != "" {
+				to, _ = strconv.Atoi(m[2])
+			}
+			if from == 0 || to == 0 {
+				return nil
+			}
+			if to < from || to-from > maxCitationRange {
+				nums = append(nums, from, to)
+				continue
+			}
+			for n := from; n <= to; n++ {
+				nums = append(nums, n)
+			}
+		}
+	}
+	return nums
+}
+
+// checkCitations matches the in-text citations ("[5]", "[12, с. 34]") with
+// the bibliography: every cited number must be in the list, and every entry
+// of the list must be cited at least once.
+func checkCitations(paragraphs []ParsedParagraph, cfg ReferencesConfig) ([]models.Violation, int) {
+	entries := bibliographyEntries(paragraphs, cfg)
+	if len(entries) == 0 {
+		return nil, 0
+	}
+	listed := map[int]bool{}
+	inList := map[int]bool{}
+	for n, e := range entries {
+		listed[referenceNumber(n, e)] = true
+		inList[e.index] = true
+	}
+
+	var vs []models.Violation
+	rules := 0
+	cited := map[int]bool{}
+	for i, p := range paragraphs {
+		if inList[i] || p.Role == "toc" || p.Role == "formula" || p.HasFormula {
+			continue
+		}
+		reported := map[int]bool{}
+		for _, m := range citationRegex.FindAllStringSubmatch(p.Text, -1) {
+			for _, num := range citedNumbers(m[1]) {
+				rules++
+				cited[num] = true
+				if listed[num] || reported[num] {
+					continue
+				}
+				reported[num] = true
+				text := strings.TrimSpace(p.Text)
+				vs = append(vs, models.Violation{
+					RuleType:      "reference_citation_undefined",
+					Description:   fmt.Sprintf("Ссылка на источник %d, которого нет в списке литературы", num),
+					PositionInDoc: fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(text, 80)),
+					ExpectedValue: fmt.Sprintf("Источник № %d в списке", num),
+					ActualValue:   fmt.Sprintf("В списке %d %s", len(entries), pluralRu(len(entries), "источник", "источника", "источников")),
+					Suggestion:    "Исправьте номер в ссылке или добавьте источник в список литературы",
+					Severity:      "warning",
+					ContextText:   truncate(text, 150),
+					Location:      paragraphLocation(i, p),
+				})
+			}
+		}
+	}
+
+	if len(cited) == 0 {
+		rules++
+		vs = append(vs, models.Violation{
+			RuleType:      "reference_not_cited",
+			Description:   "В тексте нет ссылок на источники из списка литературы",
+			PositionInDoc: "Библиография",
+			ExpectedValue: "Ссылки вида [1] или [1, с. 10]",
+			ActualValue:   "Ссылок не найдено",
+			Suggestion:    "Сошлитесь на каждый источник в тексте, указав его номер в квадратных скобках",
+			Severity:      "warning",
+		})
+		return vs, rules
+	}
+
+	for n, e := range entries {
+		rules++
+		num := referenceNumber(n, e)
+		if cited[num] {
+			continue
+		}
+		vs = append(vs, models.Violation{
+			RuleType:      "reference_not_cited",
+			Description:   fmt.Sprintf("На источник %d нет ссылки в тексте", num),
+			PositionInDoc: fmt.Sprintf("Page %d, Para %d: %s", e.para.PageNumber, e.index+1, truncate(e.text, 80)),
+			ExpectedValue: fmt.Sprintf("Ссылка [%d] в тексте", num),
+			ActualValue:   "Ссылок нет",
+			Suggestion:    fmt.Sprintf("Сошлитесь на источник в тексте («[%d]») или уберите его из списка", num),
+			Severity:      "warning",
+			ContextText:   truncate(e.text, 150),
+			Location:      paragraphLocation(e.index, e.para),
+		})
+	}
+	return vs, rules
+}
@@ -0,0 +1,138 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"sync"
+	"time"
+)
+
+// DocumentCache keeps parsed documents keyed by the content hash of their file.
+// Cached documents are shared between checks and must be treated as read-only.
+type DocumentCache interface {
+	Get(key string) (*ParsedDoc, bool)
+	Put(key string, doc *ParsedDoc)
+}
+
+// ParseCache keeps recently parsed documents keyed by their content hash so that
+// re-uploads of an unchanged file skip the unzip + XML decoding step.
+// Entries are evicted in insertion order once MaxEntries is reached.
+type ParseCache struct {
+	mu         sync.Mutex
+	entries    map[string]*ParsedDoc
+	order      []string
+	MaxEntries int
+}
+
+func NewParseCache(maxEntries int) *ParseCache {
+	if maxEntries <= 0 {
+		maxEntries = 32
+	}
+	return &ParseCache{
+		entries:    make(map[string]*ParsedDoc),
+		MaxEntries: maxEntries,
+	}
+}
+
+// Get returns the cached document for key. Cached documents are shared between
+// checks and must be treated as read-only.
+func (c *ParseCache) Get(key string) (*ParsedDoc, bool) {
+	if c == nil || key == "" {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.entries[key]
+	return doc, ok
+}
+
+func (c *ParseCache) Put(key string, doc *ParsedDoc) {
+	if c == nil || key == "" || doc == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; exists {
+		c.entries[key] = doc
+		return
+	}
+	for len(c.order) >= c.MaxEntries {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = doc
+	c.order = append(c.order, key)
+}
+
+// DefaultParseCache is shared by all CheckService instances in the process.
+var DefaultParseCache DocumentCache = NewParseCache(32)
+
+// BlobStore is a key-value store with expiry shared by several processes,
+// such as Redis. Get fails for a missing key.
+type BlobStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// sharedCacheTimeout bounds a round trip to the store: a slow store must not
+// cost more than parsing the document again.
+const sharedCacheTimeout = time.Second
+
+// SharedParseCache keeps parsed documents in a BlobStore for TTL, so that
+// replicas of the service parse each file once between them. Documents are
+// also kept in Local, which spares decoding the ones this process has seen.
+// An unreachable store only makes documents be parsed again.
+type SharedParseCache struct {
+	Local  *ParseCache
+	Store  BlobStore
+	Prefix string
+	TTL    time.Duration
+	Logf   func(format string, args ...interface{}) // nil drops store errors
+}
+
+func (c *SharedParseCache) Get(key string) (*ParsedDoc, bool) {
+	if key == "" {
+		return nil, false
+	}
+	if doc, ok := c.Local.Get(key); ok {
+		return doc, true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedCacheTimeout)
+	defer cancel()
+	data, err := c.Store.Get(ctx, c.Prefix+key)
+	if err != nil {
+		return nil, false
+	}
+	doc := &ParsedDoc{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(doc); err != nil {
+		c.logf("ParseCache: failed to decode %s: %v\n", key, err)
+		return nil, false
+	}
+	c.Local.Put(key, doc)
+	return doc, true
+}
+
+func (c *SharedParseCache) Put(key string, doc *ParsedDoc) {
+	if key == "" || doc == nil {
+		return
+	}
+	c.Local.Put(key, doc)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(doc); err != nil {
+		c.logf("ParseCache: failed to encode %s: %v\n", key, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedCacheTimeout)
+	defer cancel()
+	if err := c.Store.Set(ctx, c.Prefix+key, buf.Bytes(), c.TTL); err != nil {
+		c.logf("ParseCache: failed to store %s: %v\n", key, err)
+	}
+}
+
+func (c *SharedParseCache) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
@@ -0,0 +1,86 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"math"
+	"strings"
+)
+
+var cellAlignmentNames = map[string]string{"both": "по ширине", "left": "слева", "center": "по центру", "right": "справа"}
+
+// checkTableCells checks the text inside table cells against the font rules
+// of the body text: the font, its size (cfg.CellFontSize when the standard
+// allows a smaller one in tables) and, when cfg.CellAlignment is set, the
+// alignment. Body alignment is not applied: cells are rarely justified.
+func checkTableCells(tables []ParsedTable, font FontConfig, cfg TableConfig, startPage int) ([]models.Violation, int) {
+	var violations []models.Violation
+	totalRules := 0
+	size := font.Size
+	if cfg.CellFontSize > 0 {
+		size = cfg.CellFontSize
+	}
+	expectedAlign := normalizeAlignment(cfg.CellAlignment)
+
+	for idx, t := range tables {
+		if startPage > 1 && t.PageNumber < startPage {
+			continue
+		}
+		for _, cell := range t.Cells {
+			p := cell.Paragraph
+			text := strings.TrimSpace(p.Text)
+			add := func(v models.Violation) {
+				v.PositionInDoc = fmt.Sprintf("Page %d, Table %d, Row %d, Cell %d: %s", t.PageNumber, idx+1, cell.Row, cell.Col, truncate(text, 100))
+				v.ContextText = contextSnippet(p.Text)
+				v.Location = &models.Location{Page: t.PageNumber, TableIndex: intPtr(idx)}
+				violations = append(violations, v)
+			}
+
+			if p.FontName != "" && font.Name != "" {
+				totalRules++
+				if sameFont, isDoubtful := fontsEquivalent(p.FontName, font.Name); !sameFont {
+					severity := "error"
+					if isDoubtful {
+						severity = "warning"
+					}
+					add(models.Violation{
+						RuleType: "font_name", Description: "Неверный шрифт в ячейке таблицы",
+						ExpectedValue: font.Name, ActualValue: p.FontName, Severity: severity, IsDoubtful: isDoubtful,
+					})
+				}
+			}
+			if p.FontSizePt > 0 && size > 0 {
+				totalRules++
+				if math.Abs(p.FontSizePt-size) > font.SizeTolerance {
+					isDoubtful := math.Abs(p.FontSizePt-size) <= 2.0
+					severity := "error"
+					if isDoubtful {
+						severity = "warning"
+					}
+					add(withValues(models.Violation{
+						RuleType: "font_size", Description: "Неверный размер шрифта в ячейке таблицы",
+						Severity: severity, IsDoubtful: isDoubtful,
+					}, models.UnitPoint, size, p.FontSizePt))
+				}
+			}
+			if expectedAlign != "" {
+				totalRules++
+				actual := normalizeAlignment(p.Alignment)
+				if actual == "" {
+					actual = "left"
+				}
+				if actual != expectedAlign {
+					got := cellAlignmentNames[actual]
+					if got == "" {
+						got = actual
+					}
+					add(models.Violation{
+						RuleType: "alignment", Description: "Неверное выравнивание текста в ячейке таблицы",
+						ExpectedValue: cellAlignmentNames[expectedAlign], ActualValue: got, Severity: "warning", IsDoubtful: true,
+					})
+				}
+			}
+		}
+	}
+	return violations, totalRules
+}
@@ -1,25 +1,63 @@
 package checker
 
 import (
-	"academic-check-sys/internal/models"
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/i18n"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
 	"math"
 	"regexp"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // CheckService orchestrates the check
 type CheckService struct {
 	Parser *DocParser
+	Cache  DocumentCache // nil parses every document anew
+	Logf   func(format string, args ...interface{}) // nil disables the per-check log line
+
+	// StudentName is the name the work is submitted under, compared with the
+	// author of the document; empty skips the comparison.
+	StudentName string
+
+	// Dictionaries loads the managed vocabulary dictionaries a standard
+	// refers to in scope.dictionaries; nil ignores the references.
+	Dictionaries func(ids []uint) []VocabularyDictionary
+
+	// Defaults are inherited by standards that leave tolerances and caption
+	// keywords empty; nil uses BuiltinDefaults.
+	Defaults *Defaults
+
+	// FileName is the name the work was uploaded under, checked against
+	// file_name; empty skips the rule.
+	FileName string
+	// FileNameRule replaces the file_name of the standard, e.g. with the
+	// scheme of an assignment; nil keeps the standard's.
+	FileNameRule *FileNameConfig
+}
+
+// RulePanic wraps a panic raised while evaluating a check rule.
+type RulePanic struct {
+	Rule  string
+	Value interface{}
+	Stack []byte
+}
+
+func (p *RulePanic) Error() string {
+	return fmt.Sprintf("panic in rule %s: %v", p.Rule, p.Value)
 }
 
 func NewCheckService() *CheckService {
 	return &CheckService{
 		Parser: NewDocParser(),
+		Cache:  DefaultParseCache,
+		Logf:   func(format string, args ...interface{}) { fmt.Printf(format, args...) },
 	}
 }
 
@@ -33,8 +71,16 @@ var (
 	punctRegex           = regexp.MustCompile(`[^\p{L}\p{N}]+`)
 	tocLineRegex         = regexp.MustCompile(`^(.+?)(?:[\.\_\-\s]{2,}|\t+|\s)(\d{1,3})$`)
 	headingPrefixRegex   = regexp.MustCompile(`^\s*(\d+(?:\.\d+)*)\.?\s+(.+)$`)
-	tableRefRegex        = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(?:таблиц(?:[аеуы]|ей)|табл\.)\s*(?:№|n|no\.?)?\s*[:\.\-–—]?\s*([0-9]+(?:[\.\-][0-9]+)*)`)
-	figureRefRegex       = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(?:рисунк(?:[аеуы]|ом)|рис\.|figure|fig\.)\s*(?:№|n|no\.?)?\s*[:\.\-–—]?\s*([0-9]+(?:[\.\-][0-9]+)*)`)
+	tableRefRegex        = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(?:таблиц(?:ами|ам|ах|ей|[аеуы])|табл\.)\s*(?:№|n|no\.?)?\s*[:\.\-–—]?\s*([0-9]+(?:[\.\-][0-9]+)*)`)
+	formulaRefRegex      = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])формул(?:ами|ам|ах|ой|[аеуы])?\s*\(\s*([0-9]+(?:[\.\-][0-9]+)*)\s*\)`)
+	figureRefRegex       = regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(?:рисун(?:ок|к(?:ами|ам|ах|ом|ов|[аеуи]))|рис\.|figure|fig\.)\s*(?:№|n|no\.?)?\s*[:\.\-–—]?\s*([0-9]+(?:[\.\-][0-9]+)*)`)
+
+	// Continues a reference to several objects: "таблицы 1, 2 и 4", "рисунки 3–5".
+	objectRefListRegex = regexp.MustCompile(`^\s*(,|и|and|[–—])\s*\(?\s*([0-9]+(?:\.[0-9]+)*)\s*\)?`)
+
+	// Matches "Title [dots/spaces/tabs] PageNumber"; 1=title, 2=page number.
+	// Requiring at least 2 separator chars prevents false positives
+	tocEntryLineRegex = regexp.MustCompile(`^(.+?)[\.\_\-\s]{2,}(\d+)$`)
 )
 
 // ConfigSchema defines what the frontend Standard JSON should look like
@@ -54,6 +100,44 @@ type ConfigSchema struct {
 	Images       ImageConfig        `json:"images"`       // New
 	Formulas     FormulaConfig      `json:"formulas"`     // New
 	References   ReferencesConfig   `json:"references"`   // New
+
+	// TypographyRules checks dashes, quotes and spaces in body text.
+	TypographyRules TypographyRulesConfig `json:"typography_rules"`
+	// Units checks numbers and units of measurement in body text.
+	Units UnitsConfig `json:"units"`
+	// Lists checks markers, punctuation, indents and nesting of lists.
+	Lists ListsConfig `json:"lists"`
+	// Footnotes checks the size, numbering and number of footnotes.
+	Footnotes FootnotesConfig `json:"footnotes"`
+	// Integrity flags formatting that inflates the page count, for teachers.
+	Integrity IntegrityConfig `json:"integrity"`
+	// Repetition flags duplicated paragraphs and repeated blocks of text.
+	Repetition RepetitionConfig `json:"repetition"`
+	// Objectives compares the tasks of the introduction with the conclusion.
+	Objectives ObjectivesConfig `json:"objectives"`
+	// DocProperties screens the author and application saved with the document.
+	DocProperties DocPropertiesConfig `json:"doc_properties"`
+	// Revisions flags tracked changes and reviewer comments left in the document.
+	Revisions RevisionsConfig `json:"revisions"`
+	// Presentation checks defense presentations (.pptx) instead of documents.
+	Presentation PresentationConfig `json:"presentation"`
+	// FileName checks the name of the uploaded file.
+	FileName FileNameConfig `json:"file_name"`
+	// Sections replaces the rules of Font and Paragraph in parts of the
+	// document, e.g. the title page.
+	Sections SectionProfilesConfig `json:"sections"`
+	// CustomRules are requirements of the institution given as regular
+	// expressions.
+	CustomRules []CustomRule `json:"custom_rules"`
+	// Modules turns on rule modules registered with RegisterModule, each
+	// with its own settings.
+	Modules map[string]json.RawMessage `json:"modules"`
+
+	// SeverityOverrides maps a rule type to the severity it is reported with,
+	// e.g. {"toc_manual": "warning", "style_italic": "hint"}.
+	SeverityOverrides map[string]string `json:"severity_overrides"`
+	// ManualChecks are requirements checked by hand instead of automatically.
+	ManualChecks []ManualCheck `json:"manual_checks"`
 }
 
 // ReferencesConfig holds settings for the bibliography section check.
@@ -62,6 +146,8 @@ type ReferencesConfig struct {
 	TitleKeyword      string `json:"title_keyword"`        // e.g. "Список литературы"
 	CheckSourceAge    bool   `json:"check_source_age"`     // Enable year-age check
 	MaxSourceAgeYears int    `json:"max_source_age_years"` // 0 = use 5 as default
+	CheckEntryFormat  bool   `json:"check_entry_format"`   // GOST 7.0.100-2018 entry format
+	CheckCitations    bool   `json:"check_citations"`      // [N] citations match the list
 }
 
 type TableConfig struct {
@@ -81,6 +167,13 @@ type TableConfig struct {
 	RequireHeaderRow    bool    `json:"require_header_row"` // first row must be header
 	MinRowHeightMm      float64 `json:"min_row_height_mm"`  // 0 = ignore; ESKD = 8.0
 	MaxWidthPct         int     `json:"max_width_pct"`      // 0 = ignore
+	CheckCellContent    bool    `json:"check_cell_content"` // font rules for the text in cells
+	CellFontSize        float64 `json:"cell_font_size"`     // 0 = body font size
+	CellAlignment       string  `json:"cell_alignment"`     // left, center, right, justify; empty = not checked
+
+	CaptionIndentTolerance float64 `json:"caption_indent_tolerance"` // mm; 0 = deployment default
+
+	defaultKeyword string // inherited when CaptionKeyword is empty
 }
 
 type ImageConfig struct {
@@ -96,6 +189,10 @@ type ImageConfig struct {
 	CheckSequence       bool    `json:"check_sequence"`
 	NumberingMode       string  `json:"numbering_mode"` // auto, plain, section
 	CheckTextReferences bool    `json:"check_text_references"`
+
+	CaptionIndentTolerance float64 `json:"caption_indent_tolerance"` // mm; 0 = deployment default
+
+	defaultKeyword string // inherited when CaptionKeyword is empty
 }
 
 type FormulaConfig struct {
@@ -105,6 +202,10 @@ type FormulaConfig struct {
 	NumberingFormat      string `json:"numbering_format"`       // "(1)", "(1.1)"
 	RequireSpacingAround bool   `json:"require_spacing_around"` // empty line before/after formula
 	CheckWhereNoColon    bool   `json:"check_where_no_colon"`   // «где» after formula must not have colon
+	CheckTextReferences  bool   `json:"check_text_references"`  // every numbered formula is referenced, e.g. «по формуле (1)»
+	CheckVariablesItalic bool   `json:"check_variables_italic"` // Latin and Greek variables in italic
+	CheckFontSize        bool   `json:"check_font_size"`        // formula size matches the body text
+	ForbidImages         bool   `json:"forbid_images"`          // formulas must be typed, not pasted as pictures
 }
 
 type IntroductionConfig struct {
@@ -118,6 +219,12 @@ type ScopeConfig struct {
 	MinPages       int    `json:"min_pages"`
 	MaxPages       int    `json:"max_pages"`
 	ForbiddenWords string `json:"forbidden_words"` // Comma-sep list
+
+	// Vocabulary holds dictionaries of words to avoid in the text. Dictionaries
+	// lists managed dictionaries by id; CheckService.Dictionaries adds them to
+	// Vocabulary before the check.
+	Dictionaries []uint                 `json:"dictionaries"`
+	Vocabulary   []VocabularyDictionary `json:"vocabulary"`
 }
 
 type MarginsConfig struct {
@@ -129,12 +236,18 @@ type MarginsConfig struct {
 }
 
 type PageSetupConfig struct {
-	Orientation string `json:"orientation"` // portrait, landscape
+	Orientation      string `json:"orientation"`       // portrait, landscape
+	OtherOrientation string `json:"other_orientation"` // sections of the other orientation: "" = anywhere, appendices, forbidden
 }
 
 type HeaderFooterConfig struct {
-	HeaderDist float64 `json:"header_dist"`
-	FooterDist float64 `json:"footer_dist"`
+	HeaderDist          float64 `json:"header_dist"`
+	FooterDist          float64 `json:"footer_dist"`
+	RequirePageNumber   bool    `json:"require_page_number"`   // footer must hold a PAGE field
+	PageNumberAlignment string  `json:"page_number_alignment"` // left, center, right; ГОСТ 7.32 = center
+	TitlePageEmpty      bool    `json:"title_page_empty"`      // nothing printed in the title page headers/footers
+	RunningTitle        bool    `json:"running_title"`         // header text must match the section heading
+	Tolerance           float64 `json:"tolerance"`             // mm; 0 = deployment default
 }
 
 type TypographyConfig struct {
@@ -151,11 +264,17 @@ type CodeBlockConfig struct {
 	LineSpacing     float64 `json:"line_spacing"`
 	FirstLineIndent float64 `json:"first_line_indent"`
 	Alignment       string  `json:"alignment"`
+
+	SizeTolerance        float64 `json:"size_tolerance"`         // pt; 0 = deployment default
+	LineSpacingTolerance float64 `json:"line_spacing_tolerance"` // in lines; 0 = deployment default
+	IndentTolerance      float64 `json:"indent_tolerance"`       // mm; 0 = deployment default
 }
 
 type HeadingsConfig struct {
 	Enabled bool                          `json:"enabled"`
 	Levels  map[string]HeadingLevelConfig `json:"levels"`
+
+	SizeTolerance float64 `json:"size_tolerance"` // pt; 0 = that of the body font
 }
 
 type HeadingLevelConfig struct {
@@ -175,17 +294,31 @@ type StructureConfig struct {
 	ListAlignment        string `json:"list_alignment"`
 	VerifyTOC            bool   `json:"verify_toc"`
 	SectionOrder         string `json:"section_order"` // comma-separated expected section names in order
+	// DocumentType is the kind of work: coursework (empty) or "article" for
+	// journal articles, checked against Article as well.
+	DocumentType string        `json:"document_type"`
+	Article      ArticleConfig `json:"article"`
+	// HeadingDetection selects how headings are recognized in the document.
+	HeadingDetection HeadingDetectionConfig `json:"heading_detection"`
+
+	// Pagination
+	RequireWidowControl  bool `json:"require_widow_control"`    // body text keeps widow/orphan control on
+	HeadingKeepNext      bool `json:"heading_keep_next"`        // headings are kept with the next paragraph
+	HeadingNotLastOnPage bool `json:"heading_not_last_on_page"` // no heading at the bottom of a page
 }
 
 type FontConfig struct {
-	Name string  `json:"name"`
-	Size float64 `json:"size"`
+	Name          string  `json:"name"`
+	Size          float64 `json:"size"`
+	SizeTolerance float64 `json:"size_tolerance"` // pt; 0 = deployment default
 }
 
 type ParagraphConfig struct {
-	LineSpacing     float64 `json:"line_spacing"`
-	Alignment       string  `json:"alignment"`
-	FirstLineIndent float64 `json:"first_line_indent"`
+	LineSpacing          float64 `json:"line_spacing"`
+	Alignment            string  `json:"alignment"`
+	FirstLineIndent      float64 `json:"first_line_indent"`
+	LineSpacingTolerance float64 `json:"line_spacing_tolerance"` // in lines; 0 = deployment default
+	IndentTolerance      float64 `json:"indent_tolerance"`       // mm; 0 = deployment default
 }
 
 func isCodeParagraph(p ParsedParagraph) bool {
@@ -243,7 +376,7 @@ func checkCodeParagraph(p ParsedParagraph, config CodeBlockConfig, pos string) (
 			violations = append(violations, models.Violation{
 				RuleType: "code_font_name", Description: "Неверный шрифт блока кода", PositionInDoc: pos,
 				ExpectedValue: config.FontName, ActualValue: p.FontName, Severity: "warning",
-				ContextText: p.Text,
+				ContextText: contextSnippet(p.Text),
 				IsDoubtful:  isDoubtful,
 			})
 		}
@@ -251,36 +384,33 @@ func checkCodeParagraph(p ParsedParagraph, config CodeBlockConfig, pos string) (
 
 	if config.FontSize > 0 && p.FontSizePt > 0 {
 		totalRules++
-		if math.Abs(p.FontSizePt-config.FontSize) > 0.5 {
-			violations = append(violations, models.Violation{
-				RuleType: "code_font_size", Description: "Неверный размер шрифта блока кода", PositionInDoc: pos,
-				ExpectedValue: fmt.Sprintf("%.1f", config.FontSize), ActualValue: fmt.Sprintf("%.1f", p.FontSizePt), Severity: "warning",
-				ContextText: p.Text,
+		if math.Abs(p.FontSizePt-config.FontSize) > config.SizeTolerance {
+			violations = append(violations, withValues(models.Violation{
+				RuleType: "code_font_size", Description: "Неверный размер шрифта блока кода", PositionInDoc: pos, Severity: "warning",
+				ContextText: contextSnippet(p.Text),
 				IsDoubtful:  math.Abs(p.FontSizePt-config.FontSize) <= 2.0,
-			})
+			}, models.UnitPoint, config.FontSize, p.FontSizePt))
 		}
 	}
 
 	if config.LineSpacing > 0 && p.LineSpacing > 0 {
 		totalRules++
-		if math.Abs(p.LineSpacing-config.LineSpacing) > 0.15 {
-			violations = append(violations, models.Violation{
-				RuleType: "code_line_spacing", Description: "Неверный межстрочный интервал блока кода", PositionInDoc: pos,
-				ExpectedValue: fmt.Sprintf("%.2f", config.LineSpacing), ActualValue: fmt.Sprintf("%.2f", p.LineSpacing), Severity: "warning",
-				ContextText: p.Text,
+		if math.Abs(p.LineSpacing-config.LineSpacing) > config.LineSpacingTolerance {
+			violations = append(violations, withValues(models.Violation{
+				RuleType: "code_line_spacing", Description: "Неверный межстрочный интервал блока кода", PositionInDoc: pos, Severity: "warning",
+				ContextText: contextSnippet(p.Text),
 				IsDoubtful:  math.Abs(p.LineSpacing-config.LineSpacing) <= 0.3,
-			})
+			}, models.UnitLines, config.LineSpacing, p.LineSpacing))
 		}
 	}
 
 	totalRules++
-	if math.Abs(p.FirstLineIndentMm-config.FirstLineIndent) > 3.0 {
-		violations = append(violations, models.Violation{
-			RuleType: "code_indent", Description: "Неверный отступ первой строки блока кода", PositionInDoc: pos,
-			ExpectedValue: fmt.Sprintf("%.1f мм", config.FirstLineIndent), ActualValue: fmt.Sprintf("%.1f мм", p.FirstLineIndentMm), Severity: "warning",
-			ContextText: p.Text,
+	if math.Abs(p.FirstLineIndentMm-config.FirstLineIndent) > config.IndentTolerance {
+		violations = append(violations, withValues(models.Violation{
+			RuleType: "code_indent", Description: "Неверный отступ первой строки блока кода", PositionInDoc: pos, Severity: "warning",
+			ContextText: contextSnippet(p.Text),
 			IsDoubtful:  math.Abs(p.FirstLineIndentMm-config.FirstLineIndent) <= 6.0,
-		})
+		}, models.UnitMillimeter, config.FirstLineIndent, p.FirstLineIndentMm))
 	}
 
 	expectedAlign := config.Alignment
@@ -300,7 +430,7 @@ func checkCodeParagraph(p ParsedParagraph, config CodeBlockConfig, pos string) (
 			violations = append(violations, models.Violation{
 				RuleType: "code_alignment", Description: "Неверное выравнивание блока кода", PositionInDoc: pos,
 				ExpectedValue: normExpected, ActualValue: normActual, Severity: "warning",
-				ContextText: p.Text,
+				ContextText: contextSnippet(p.Text),
 				IsDoubtful:  true,
 			})
 		}
@@ -372,6 +502,115 @@ func shouldCheckBodyFormatting(p ParsedParagraph, inReferences bool) bool {
 	}
 }
 
+// checkParagraphFormatting checks the font, line spacing, alignment and
+// first-line indent of a body paragraph. List items keep their own alignment
+// and indents.
+func checkParagraphFormatting(p ParsedParagraph, font FontConfig, paragraph ParagraphConfig, pos string) ([]models.Violation, int) {
+	var violations []models.Violation
+	totalRules := 0
+	// Font Check
+	if p.FontName != "" && font.Name != "" {
+		totalRules++
+		if sameFont, isDoubtful := fontsEquivalent(p.FontName, font.Name); !sameFont {
+			severity := "error"
+			if isDoubtful {
+				severity = "warning"
+			}
+			violations = append(violations, models.Violation{
+				RuleType: "font_name", Description: "Неверный шрифт", PositionInDoc: pos,
+				ExpectedValue: font.Name, ActualValue: p.FontName, Severity: severity,
+				ContextText: contextSnippet(p.Text),
+				IsDoubtful:  isDoubtful,
+			})
+		}
+	}
+	if p.FontSizePt > 0 && font.Size > 0 {
+		totalRules++
+		if math.Abs(p.FontSizePt-font.Size) > font.SizeTolerance {
+			isDoubtful := math.Abs(p.FontSizePt-font.Size) <= font.SizeTolerance+1.25
+			severity := "error"
+			if isDoubtful {
+				severity = "warning"
+			}
+			violations = append(violations, withValues(models.Violation{
+				RuleType: "font_size", Description: "Неверный размер шрифта", PositionInDoc: pos, Severity: severity,
+				ContextText: contextSnippet(p.Text),
+				IsDoubtful:  isDoubtful,
+			}, models.UnitPoint, font.Size, p.FontSizePt))
+		}
+	}
+
+	// Spacing: skip if LineSpacing is 0 (means paragraph inherits from style, can't verify)
+	if paragraph.LineSpacing > 0 && p.LineSpacing > 0 {
+		totalRules++
+		// The tolerance accounts for Word's internal rounding when
+		// storing line spacing in 240ths-of-line units.
+		if math.Abs(p.LineSpacing-paragraph.LineSpacing) > paragraph.LineSpacingTolerance {
+			isDoubtful := math.Abs(p.LineSpacing-paragraph.LineSpacing) <= paragraph.LineSpacingTolerance+0.15
+			violations = append(violations, withValues(models.Violation{
+				RuleType: "line_spacing", Description: "Неверный междустрочный интервал", PositionInDoc: pos, Severity: "warning",
+				ContextText: contextSnippet(p.Text),
+				IsDoubtful:  isDoubtful,
+			}, models.UnitLines, paragraph.LineSpacing, p.LineSpacing))
+		}
+	}
+
+	// Justification — skip list items (they're naturally left-aligned)
+	expectedAlign := paragraph.Alignment
+	if expectedAlign != "" && !p.IsListItem {
+		totalRules++
+		// Normalize expected
+		normExpected := expectedAlign
+		if normExpected == "justify" {
+			normExpected = "both"
+		}
+		// Normalize actual (Word uses "start"/"end" for rtl/ltr)
+		normActual := p.Alignment
+		if normActual == "start" {
+			normActual = "left"
+		} else if normActual == "end" {
+			normActual = "right"
+		}
+		// Empty alignment in para = default left
+		if normActual == "" {
+			normActual = "left"
+		}
+		if normActual != normExpected {
+			readable := map[string]string{"both": "по ширине", "left": "слева", "center": "по центру", "right": "справа"}
+			gotLabel := readable[normActual]
+			if gotLabel == "" {
+				gotLabel = normActual
+			}
+			wantLabel := readable[normExpected]
+			if wantLabel == "" {
+				wantLabel = normExpected
+			}
+			violations = append(violations, models.Violation{
+				RuleType: "alignment", Description: "Неверное выравнивание", PositionInDoc: pos,
+				ExpectedValue: wantLabel, ActualValue: gotLabel, Severity: "warning",
+				ContextText: contextSnippet(p.Text),
+				IsDoubtful:  true, // Alignment is often semantic
+			})
+		}
+	}
+
+	// Indentation — skip list items (they use list indentation, not first-line indent)
+	if paragraph.FirstLineIndent > 0 && !p.IsListItem {
+		totalRules++
+		// Tolerance is intentionally broad: Word stores indent in twips and rounding can cause
+		// small discrepancies (~1-2mm). Also students sometimes set 1.25cm vs 1.27cm.
+		if math.Abs(p.FirstLineIndentMm-paragraph.FirstLineIndent) > paragraph.IndentTolerance {
+			isDoubtful := math.Abs(p.FirstLineIndentMm-paragraph.FirstLineIndent) <= paragraph.IndentTolerance+3
+			violations = append(violations, withValues(models.Violation{
+				RuleType: "indent", Description: "Неверный отступ первой строки", PositionInDoc: pos, Severity: "warning",
+				ContextText: contextSnippet(p.Text),
+				IsDoubtful:  isDoubtful,
+			}, models.UnitMillimeter, paragraph.FirstLineIndent, p.FirstLineIndentMm))
+		}
+	}
+	return violations, totalRules
+}
+
 func isReferenceHeading(text string, cfg ReferencesConfig) bool {
 	keyword := strings.ToLower(strings.TrimSpace(cfg.TitleKeyword))
 	if keyword == "" {
@@ -397,6 +636,9 @@ func normalizeAlignment(value string) string {
 }
 
 func violationPenalty(v models.Violation) float64 {
+	if !models.IsBlocking(v.Severity) {
+		return 0
+	}
 	penalty := 1.0
 	if v.Severity == "warning" {
 		penalty = 0.5
@@ -447,7 +689,8 @@ func checkHeadingParagraph(p ParsedParagraph, config HeadingsConfig, level int,
 
 	violations := []models.Violation{}
 	totalRules := 0
-	isDoubtful := p.HeuristicHeading && !isHeadingStyle(p.StyleID)
+	styled, _ := styledHeading(p)
+	isDoubtful := p.HeuristicHeading && !styled
 	levelLabel := fmt.Sprintf("H%d", level)
 
 	if levelConfig.CheckBold {
@@ -463,7 +706,7 @@ func checkHeadingParagraph(p ParsedParagraph, config HeadingsConfig, level int,
 			violations = append(violations, models.Violation{
 				RuleType: "heading_bold", Description: fmt.Sprintf("Неверное начертание заголовка %s", levelLabel), PositionInDoc: pos,
 				ExpectedValue: expected, ActualValue: actual, Severity: "warning",
-				ContextText: p.Text,
+				ContextText: contextSnippet(p.Text),
 				IsDoubtful:  isDoubtful,
 			})
 		}
@@ -471,13 +714,12 @@ func checkHeadingParagraph(p ParsedParagraph, config HeadingsConfig, level int,
 
 	if levelConfig.CheckFontSize && levelConfig.FontSize > 0 && p.FontSizePt > 0 {
 		totalRules++
-		if math.Abs(p.FontSizePt-levelConfig.FontSize) > 0.75 {
-			violations = append(violations, models.Violation{
-				RuleType: "heading_font_size", Description: fmt.Sprintf("Неверный размер шрифта заголовка %s", levelLabel), PositionInDoc: pos,
-				ExpectedValue: fmt.Sprintf("%.1f", levelConfig.FontSize), ActualValue: fmt.Sprintf("%.1f", p.FontSizePt), Severity: "warning",
-				ContextText: p.Text,
+		if math.Abs(p.FontSizePt-levelConfig.FontSize) > config.SizeTolerance {
+			violations = append(violations, withValues(models.Violation{
+				RuleType: "heading_font_size", Description: fmt.Sprintf("Неверный размер шрифта заголовка %s", levelLabel), PositionInDoc: pos, Severity: "warning",
+				ContextText: contextSnippet(p.Text),
 				IsDoubtful:  isDoubtful || math.Abs(p.FontSizePt-levelConfig.FontSize) <= 2.0,
-			})
+			}, models.UnitPoint, levelConfig.FontSize, p.FontSizePt))
 		}
 	}
 
@@ -497,7 +739,7 @@ func checkHeadingParagraph(p ParsedParagraph, config HeadingsConfig, level int,
 			violations = append(violations, models.Violation{
 				RuleType: "heading_alignment", Description: fmt.Sprintf("Неверное выравнивание заголовка %s", levelLabel), PositionInDoc: pos,
 				ExpectedValue: expected, ActualValue: actual, Severity: "warning",
-				ContextText: p.Text,
+				ContextText: contextSnippet(p.Text),
 				IsDoubtful:  true,
 			})
 		}
@@ -516,7 +758,7 @@ func checkHeadingParagraph(p ParsedParagraph, config HeadingsConfig, level int,
 			violations = append(violations, models.Violation{
 				RuleType: "heading_caps", Description: fmt.Sprintf("Неверный регистр заголовка %s", levelLabel), PositionInDoc: pos,
 				ExpectedValue: expected, ActualValue: actual, Severity: "warning",
-				ContextText: p.Text,
+				ContextText: contextSnippet(p.Text),
 				IsDoubtful:  isDoubtful,
 			})
 		}
@@ -699,7 +941,7 @@ func checkTOCSequence(paragraphs []ParsedParagraph) ([]models.Violation, int) {
 				RuleType: "toc_order_missing", Description: fmt.Sprintf("Раздел из содержания не найден в тексте или идет не по порядку: '%s'", truncate(entry.Title, 40)), PositionInDoc: "Оглавление",
 				ExpectedValue: "Раздел в тексте в том же порядке", ActualValue: "Не найден после предыдущего раздела", Severity: "warning",
 				IsDoubtful:  true,
-				ContextText: entry.Text,
+				ContextText: contextSnippet(entry.Text),
 			})
 			continue
 		}
@@ -709,16 +951,15 @@ func checkTOCSequence(paragraphs []ParsedParagraph) ([]models.Violation, int) {
 			violations = append(violations, models.Violation{
 				RuleType: "toc_number_mismatch", Description: fmt.Sprintf("Номер раздела в содержании не совпадает с текстом: '%s'", truncate(entry.Title, 40)), PositionInDoc: "Оглавление",
 				ExpectedValue: headingNumber, ActualValue: entry.Number, Severity: "warning",
-				ContextText: entry.Text,
+				ContextText: contextSnippet(entry.Text),
 			})
 		}
 		if entry.Page > 0 && headings[foundAt].PageNumber > 0 && entry.Page != headings[foundAt].PageNumber {
-			violations = append(violations, models.Violation{
-				RuleType: "toc_page_mismatch", Description: fmt.Sprintf("Страница раздела в содержании не совпадает с текстом: '%s'", truncate(entry.Title, 40)), PositionInDoc: "Оглавление",
-				ExpectedValue: fmt.Sprintf("Стр. %d", headings[foundAt].PageNumber), ActualValue: fmt.Sprintf("Стр. %d", entry.Page), Severity: "warning",
-				ContextText: entry.Text,
+			violations = append(violations, withValues(models.Violation{
+				RuleType: "toc_page_mismatch", Description: fmt.Sprintf("Страница раздела в содержании не совпадает с текстом: '%s'", truncate(entry.Title, 40)), PositionInDoc: "Оглавление", Severity: "warning",
+				ContextText: contextSnippet(entry.Text),
 				IsDoubtful:  math.Abs(float64(headings[foundAt].PageNumber-entry.Page)) <= 1,
-			})
+			}, models.UnitPage, float64(headings[foundAt].PageNumber), float64(entry.Page)))
 		}
 		cursor = foundAt + 1
 	}
@@ -727,589 +968,176 @@ func checkTOCSequence(paragraphs []ParsedParagraph) ([]models.Violation, int) {
 }
 
 func (s *CheckService) RunCheck(ctx context.Context, filePath string, standardJSON string) (*models.CheckResult, []models.Violation, error) {
+	return s.RunCheckCached(ctx, filePath, "", standardJSON)
+}
+
+// RunCheckCached behaves like RunCheck but reuses a previously parsed document
+// when contentHash is known and present in the parse cache.
+func (s *CheckService) RunCheckCached(ctx context.Context, filePath string, contentHash string, standardJSON string) (*models.CheckResult, []models.Violation, error) {
+	if IsPresentation(filePath) {
+		pres, err := s.ParsePresentation(ctx, filePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s.EvaluatePresentation(ctx, pres, standardJSON)
+	}
+	doc, err := s.ParseCached(ctx, filePath, contentHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.Evaluate(ctx, doc, standardJSON)
+}
+
+// ParseCached parses the document at filePath, consulting the parse cache first
+// when contentHash is known.
+func (s *CheckService) ParseCached(ctx context.Context, filePath string, contentHash string) (*ParsedDoc, error) {
 	// 0. Check Context
 	if ctx.Err() != nil {
-		return nil, nil, ctx.Err()
+		return nil, ctx.Err()
 	}
 
 	// 1. Parse Document
+	if s.Cache != nil {
+		if doc, cached := s.Cache.Get(contentHash); cached {
+			return doc, nil
+		}
+	}
 	doc, err := s.Parser.Parse(filePath)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if s.Cache != nil {
+		s.Cache.Put(contentHash, doc)
 	}
+	return doc, nil
+}
+
+// Evaluate validates an already parsed document against a standard configuration.
+func (s *CheckService) Evaluate(ctx context.Context, doc *ParsedDoc, standardJSON string) (*models.CheckResult, []models.Violation, error) {
+	// clock tracks the check being evaluated and how long each one takes. A panic
+	// is re-raised as *RulePanic carrying the current rule, so crash reports show
+	// which check failed.
+	clock := newRuleClock()
+	clock.Enter("config")
+	defer func() {
+		if r := recover(); r != nil {
+			panic(&RulePanic{Rule: clock.Current(), Value: r, Stack: debug.Stack()})
+		}
+	}()
 
 	// 2. Parse Config
 	var config ConfigSchema
 	if err := json.Unmarshal([]byte(standardJSON), &config); err != nil {
 		return nil, nil, fmt.Errorf("invalid standard config: %v", err)
 	}
+	defaults := BuiltinDefaults
+	if s.Defaults != nil {
+		defaults = *s.Defaults
+	}
+	config.inherit(defaults)
+
+	doc = withHeadingDetection(doc, config.Structure.HeadingDetection)
 
 	// 3. Verify
-	violations := []models.Violation{}
-	totalRules := 0
+	e := &evaluation{s: s, doc: doc, config: config, clock: clock, violations: []models.Violation{}}
+	e.trace = newRuleTrace(&e.violations, &e.totalRules)
+	clock.trace = e.trace
 
 	// Check Context before heavy logic
 	if ctx.Err() != nil {
 		return nil, nil, ctx.Err()
 	}
 
-	// Check Margins
-	vListMargins := checkMargins(doc.Margins, config.Margins)
-	// Count only configured margin fields
-	if config.Margins.Top > 0 {
-		totalRules++
-	}
-	if config.Margins.Bottom > 0 {
-		totalRules++
-	}
-	if config.Margins.Left > 0 {
-		totalRules++
-	}
-	if config.Margins.Right > 0 {
-		totalRules++
-	}
-	violations = append(violations, vListMargins...)
-
-	// Check Page Setup
-	if config.PageSetup.Orientation != "" && doc.PageSize.Orientation != "" {
-		totalRules++
-		if config.PageSetup.Orientation != doc.PageSize.Orientation {
-			violations = append(violations, models.Violation{
-				RuleType: "page_orientation", Description: "Incorrect Page Orientation",
-				ExpectedValue: config.PageSetup.Orientation, ActualValue: doc.PageSize.Orientation, Severity: "error",
-			})
-		}
-	}
-
-	// Check Header/Footer
-	if config.HeaderFooter.HeaderDist > 0 && math.Abs(doc.Margins.HeaderMm-config.HeaderFooter.HeaderDist) > 2.0 {
-		totalRules++
-		violations = append(violations, models.Violation{
-			RuleType: "header_dist", Description: "Incorrect Header Distance", Severity: "error",
-			ExpectedValue: fmt.Sprintf("%.1f mm", config.HeaderFooter.HeaderDist), ActualValue: fmt.Sprintf("%.1f mm", doc.Margins.HeaderMm),
-		})
-	} else if config.HeaderFooter.HeaderDist > 0 {
-		totalRules++
-	}
-
-	if config.HeaderFooter.FooterDist > 0 && math.Abs(doc.Margins.FooterMm-config.HeaderFooter.FooterDist) > 2.0 {
-		totalRules++
-		violations = append(violations, models.Violation{
-			RuleType: "footer_dist", Description: "Incorrect Footer Distance", Severity: "error",
-			ExpectedValue: fmt.Sprintf("%.1f mm", config.HeaderFooter.FooterDist), ActualValue: fmt.Sprintf("%.1f mm", doc.Margins.FooterMm),
-		})
-	} else if config.HeaderFooter.FooterDist > 0 {
-		totalRules++
+	e.runModules()
+	// The caller has given up on a check that outlasted its deadline.
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
 	}
 
-	// Check Tables
-	tblViolations, tblRules := checkTables(doc.Tables, doc.Paragraphs, config.Tables)
-	violations = append(violations, tblViolations...)
-	totalRules += tblRules
+	clock.Enter("observations")
+	e.trace.read("observations", "stats", doc.Stats)
+	e.violations = append(e.violations, documentObservations(doc)...)
+	applySeverityOverrides(e.violations, e.config.SeverityOverrides)
+	violations := applyManualChecks(e.violations, e.config.ManualChecks)
+	totalRules := e.totalRules
 
-	// Check Images
-	imgViolations, imgRules := checkImages(doc.Images, doc.Paragraphs, config.Images)
-	violations = append(violations, imgViolations...)
-	totalRules += imgRules
+	clock.trace = nil
+	ruleTrace := e.trace.Stop()
 
-	// Check Formulas (pass paragraphs for spacing/где checks)
-	fmViolations, fmRules := checkFormulas(doc.Formulas, doc.Paragraphs, config.Formulas)
-	violations = append(violations, fmViolations...)
-	totalRules += fmRules
+	clock.Enter("score")
+	res := scoreResult(violations, totalRules)
+	res.RuleTrace = ruleTrace
 
-	// Check References (bibliography age)
-	if config.References.Required || config.References.CheckSourceAge {
-		refViolations, refRules := checkReferences(doc.Paragraphs, config.References)
-		violations = append(violations, refViolations...)
-		totalRules += refRules
+	if s.Logf != nil {
+		s.Logf("📊 Checker: TotalRules=%d, Violations=%d, PassedRules=%d, Score=%.2f\n", totalRules, len(violations), res.PassedRules, res.OverallScore)
 	}
 
-	if config.Structure.VerifyTOC {
-		tocViolations, tocRules := checkTOCSequence(doc.Paragraphs)
-		violations = append(violations, tocViolations...)
-		totalRules += tocRules
+	// Serialize Content for View
+	clock.Enter("serialize")
+	if contentBytes, err := json.Marshal(doc); err == nil {
+		res.ContentJSON = string(contentBytes)
 	}
 
-	// Check Paragraphs
-	lastHeadingLevel := 0
-	inReferencesSection := false
-	for i, p := range doc.Paragraphs {
-		// Skip blank paragraphs (empty text or whitespace only)
-		trimmed := strings.TrimSpace(p.Text)
-		if trimmed == "" {
-			continue
-		}
-
-		// Page Scope Filter
-		if config.Scope.StartPage > 1 && p.PageNumber < config.Scope.StartPage {
-			// Skip checks for this paragraph as it is out of scope (e.g. title page)
-			continue
-		}
-
-		// ID for Violation
-		pos := fmt.Sprintf("Page %d, Para %d: %s...", p.PageNumber, i+1, truncate(trimmed, 100))
-
-		isHeading := isHeadingParagraph(p)
-		headingLevel := 0
-		if isHeading {
-			if isHeadingStyle(p.StyleID) {
-				headingLevel = headingLevelFromStyle(p.StyleID)
-			} else if p.HeuristicHeading {
-				headingLevel = p.HeuristicLevel
-			}
-		}
-
-		if isReferenceHeading(trimmed, config.References) {
-			inReferencesSection = true
-		} else if inReferencesSection && isHeading {
-			inReferencesSection = false
-		}
-
-		if isHeading && headingLevel > 0 && p.Role != "toc" {
-			headingViolations, headingRules := checkHeadingParagraph(p, config.Headings, headingLevel, pos)
-			violations = append(violations, headingViolations...)
-			totalRules += headingRules
-		}
-
-		// --- Structure Rules ---
-
-		// 1. Heading 1 starts new page
-		if config.Structure.Heading1StartNewPage && headingLevel == 1 && p.Role == "heading" && i > 0 {
-			// Check if ANY of these conditions hold, which indicate a new page:
-			// a) StartsPageBreak = explicit <w:br type="page"> in runs
-			// b) The paragraph itself has PageBreakBefore PPr
-			// c) It's on a different page than the previous heading (page tracker)
-			// We check (a) and (b) via StartsPageBreak flag already.
-			// Additionally check that the heading is not the very first paragraph on its page.
-			prevNonEmpty := -1
-			for j := i - 1; j >= 0; j-- {
-				if strings.TrimSpace(doc.Paragraphs[j].Text) != "" {
-					prevNonEmpty = j
-					break
-				}
-			}
-			// Only flag if there's a non-empty para before this heading AND it's on the same page AND no break
-			if prevNonEmpty >= 0 && !p.StartsPageBreak && doc.Paragraphs[prevNonEmpty].PageNumber == p.PageNumber {
-				violations = append(violations, models.Violation{
-					RuleType: "structure_break", Description: "Заголовок 1 уровня должен начинаться с новой страницы", PositionInDoc: pos,
-					ExpectedValue: "Разрыв страницы", ActualValue: "Предыдущий текст на той же странице", Severity: "warning",
-				})
-			}
-		}
-
-		// 2. Heading Hierarchy (1 -> 2 -> 3)
-		if config.Structure.HeadingHierarchy && isHeading && p.Role == "heading" && headingLevel > 0 {
-			if headingLevel > lastHeadingLevel+1 {
-				violations = append(violations, models.Violation{
-					RuleType: "structure_hierarchy", Description: fmt.Sprintf("Пропущен уровень заголовка: H%d после H%d", headingLevel, lastHeadingLevel), PositionInDoc: pos,
-					ExpectedValue: fmt.Sprintf("Заголовок %d", lastHeadingLevel+1), ActualValue: fmt.Sprintf("Заголовок %d", headingLevel), Severity: "error",
-				})
-			}
-			lastHeadingLevel = headingLevel
-		}
-		if !isHeading {
-			// Reset hierarchy check? No, body text doesn't reset level.
-		}
-
-		// --- TOC Verification ---
-		if config.Structure.VerifyTOC {
-			text := strings.TrimSpace(p.Text)
-
-			// Skip empty or very short TOC entries
-			if len(text) >= 3 {
-				isTOCStyle := strings.HasPrefix(strings.ToLower(p.StyleID), "toc") || strings.HasPrefix(strings.ToLower(p.StyleID), "table of contents") || strings.HasPrefix(strings.ToLower(p.StyleID), "оглавление")
-
-				// Enhanced regex pattern to extract title and page number
-				// Matches: "Title [dots/spaces/tabs] PageNumber"
-				// Captures: 1=title, 2=page number. Requiring at least 2 separator chars prevents false positives
-				tocPattern := `^(.+?)[\.\_\-\s]{2,}(\d+)$`
-				re := regexp.MustCompile(tocPattern)
-				matches := re.FindStringSubmatch(text)
-
-				// It's a TOC entry if it has a TOC style, OR if it neatly matches the Title .... Page pattern
-				if isTOCStyle || len(matches) >= 3 {
-					if len(matches) >= 3 {
-						titlePart := strings.TrimSpace(matches[1])
-						pagePart := matches[2]
-
-						// Clean up title: remove trailing dots, underscores, dashes, spaces
-						titlePart = strings.TrimRight(titlePart, " ._-")
-
-						if tocPage, err := strconv.Atoi(pagePart); err == nil {
-							// Normalized title for fuzzy matching
-							normTitle := normalizeForTOC(titlePart)
-
-							// Build heading map once per document for efficiency
-							headingMap := make(map[string]int)
-							for _, targetP := range doc.Paragraphs {
-								t := strings.TrimSpace(targetP.Text)
-								if t != "" && isHeadingParagraph(targetP) {
-									headingMap[normalizeForTOC(t)] = targetP.PageNumber
-								}
-							}
-
-							if actualPage, found := headingMap[normTitle]; found {
-								if actualPage != tocPage {
-									isDoubtful := math.Abs(float64(actualPage-tocPage)) <= 1.0 // Only 1 page difference is doubtful
-									violations = append(violations, models.Violation{
-										RuleType: "toc_page_mismatch", Description: fmt.Sprintf("Несовпадение страниц в оглавлении для '%s'", truncate(titlePart, 20)), PositionInDoc: "Оглавление",
-										ExpectedValue: fmt.Sprintf("Стр. %d", actualPage), ActualValue: fmt.Sprintf("Стр. %d", tocPage), Severity: "error",
-										IsDoubtful:  isDoubtful,
-										ContextText: text,
-									})
-								}
-							} else {
-								violations = append(violations, models.Violation{
-									RuleType: "toc_missing_heading", Description: fmt.Sprintf("Раздел из оглавления не найден в тексте: '%s'", truncate(titlePart, 30)), PositionInDoc: "Оглавление",
-									ExpectedValue: "Наличие раздела в тексте", ActualValue: "Раздел не найден", Severity: "error",
-									IsDoubtful:  true, // Always doubtful if it's a naming mismatch
-									ContextText: text,
-								})
-							}
-						}
-					}
-				}
-			}
-		}
-
-		// --- Formatting Rules (Skip for Headings usually, but user might want strictness) ---
-		// We usually apply "Body" rules only to normal paragraphs (no style or Normal)
-
-		if !isHeading && shouldCheckBodyFormatting(p, inReferencesSection) {
-			isCodeBlock := config.CodeBlocks.Enabled && isCodeParagraph(p)
-			if isCodeBlock {
-				codeViolations, codeRules := checkCodeParagraph(p, config.CodeBlocks, pos)
-				violations = append(violations, codeViolations...)
-				totalRules += codeRules
-				continue
-			}
-
-			if p.IsListItem && config.Structure.ListAlignment != "" {
-				totalRules++
-				expected := normalizeAlignment(config.Structure.ListAlignment)
-				actual := normalizeAlignment(p.Alignment)
-				if actual == "" {
-					actual = "left"
-				}
-				if actual != expected {
-					violations = append(violations, models.Violation{
-						RuleType:      "list_alignment",
-						Description:   "Неверное выравнивание элемента списка",
-						PositionInDoc: pos,
-						ExpectedValue: expected,
-						ActualValue:   actual,
-						Severity:      "warning",
-						ContextText:   p.Text,
-						IsDoubtful:    true,
-					})
-				}
-			}
-
-			// --- Vocabulary Check (only for body text, not headings) ---
-			if config.Scope.ForbiddenWords != "" {
-				words := strings.Split(config.Scope.ForbiddenWords, ",")
-				lowerText := strings.ToLower(p.Text)
-				for _, w := range words {
-					w = strings.TrimSpace(strings.ToLower(w))
-					if w == "" {
-						continue
-					}
-					// Use Unicode word-boundary matching: \P{L} matches any non-letter
-					// character (space, punctuation, start/end of string). This prevents
-					// "мы" from matching inside "мыться".
-					// Pattern: (^|\P{L})word($|\P{L})
-					escapedW := regexp.QuoteMeta(w)
-					pattern := `(?i)(^|\P{L})` + escapedW + `($|\P{L})`
-					re, err := regexp.Compile(pattern)
-					if err == nil && re.MatchString(lowerText) {
-						violations = append(violations, models.Violation{
-							RuleType: "vocabulary", Description: fmt.Sprintf("Запрещённое слово: '%s'", w), PositionInDoc: pos,
-							ExpectedValue: "Не должно быть", ActualValue: "Присутствует", Severity: "error",
-							ContextText: p.Text,
-						})
-					}
-				}
-			}
-
-			// Font Check
-			if p.FontName != "" && config.Font.Name != "" {
-				totalRules++
-				if sameFont, isDoubtful := fontsEquivalent(p.FontName, config.Font.Name); !sameFont {
-					severity := "error"
-					if isDoubtful {
-						severity = "warning"
-					}
-					violations = append(violations, models.Violation{
-						RuleType: "font_name", Description: "Неверный шрифт", PositionInDoc: pos,
-						ExpectedValue: config.Font.Name, ActualValue: p.FontName, Severity: severity,
-						ContextText: p.Text,
-						IsDoubtful:  isDoubtful,
-					})
-				}
-			}
-			if p.FontSizePt > 0 && config.Font.Size > 0 {
-				totalRules++
-				if math.Abs(p.FontSizePt-config.Font.Size) > 0.75 {
-					isDoubtful := math.Abs(p.FontSizePt-config.Font.Size) <= 2.0
-					severity := "error"
-					if isDoubtful {
-						severity = "warning"
-					}
-					violations = append(violations, models.Violation{
-						RuleType: "font_size", Description: "Неверный размер шрифта", PositionInDoc: pos,
-						ExpectedValue: fmt.Sprintf("%.1f", config.Font.Size), ActualValue: fmt.Sprintf("%.1f", p.FontSizePt), Severity: severity,
-						ContextText: p.Text,
-						IsDoubtful:  isDoubtful,
-					})
-				}
-			}
-
-			// Spacing: skip if LineSpacing is 0 (means paragraph inherits from style, can't verify)
-			if config.Paragraph.LineSpacing > 0 && p.LineSpacing > 0 {
-				totalRules++
-				// Allow a wider tolerance to account for Word's internal
-				// rounding when storing line spacing in 240ths-of-line units.
-				if math.Abs(p.LineSpacing-config.Paragraph.LineSpacing) > 0.2 {
-					isDoubtful := math.Abs(p.LineSpacing-config.Paragraph.LineSpacing) <= 0.35
-					violations = append(violations, models.Violation{
-						RuleType: "line_spacing", Description: "Неверный междустрочный интервал", PositionInDoc: pos,
-						ExpectedValue: fmt.Sprintf("%.2f", config.Paragraph.LineSpacing), ActualValue: fmt.Sprintf("%.2f", p.LineSpacing), Severity: "warning",
-						ContextText: p.Text,
-						IsDoubtful:  isDoubtful,
-					})
-				}
-			}
-
-			// Justification — skip list items (they're naturally left-aligned)
-			expectedAlign := config.Paragraph.Alignment
-			if expectedAlign != "" && !p.IsListItem {
-				totalRules++
-				// Normalize expected
-				normExpected := expectedAlign
-				if normExpected == "justify" {
-					normExpected = "both"
-				}
-				// Normalize actual (Word uses "start"/"end" for rtl/ltr)
-				normActual := p.Alignment
-				if normActual == "start" {
-					normActual = "left"
-				} else if normActual == "end" {
-					normActual = "right"
-				}
-				// Empty alignment in para = default left
-				if normActual == "" {
-					normActual = "left"
-				}
-				if normActual != normExpected {
-					readable := map[string]string{"both": "по ширине", "left": "слева", "center": "по центру", "right": "справа"}
-					gotLabel := readable[normActual]
-					if gotLabel == "" {
-						gotLabel = normActual
-					}
-					wantLabel := readable[normExpected]
-					if wantLabel == "" {
-						wantLabel = normExpected
-					}
-					violations = append(violations, models.Violation{
-						RuleType: "alignment", Description: "Неверное выравнивание", PositionInDoc: pos,
-						ExpectedValue: wantLabel, ActualValue: gotLabel, Severity: "warning",
-						ContextText: p.Text,
-						IsDoubtful:  true, // Alignment is often semantic
-					})
-				}
-			}
+	res.Summary = Summarize(violations)
+	if fp, err := json.Marshal(StructuralFingerprint(doc)); err == nil {
+		res.Fingerprint = string(fp)
+	}
+	res.RuleTimings = clock.Stop()
 
-			// Indentation — skip list items (they use list indentation, not first-line indent)
-			if config.Paragraph.FirstLineIndent > 0 && !p.IsListItem {
-				totalRules++
-				// Tolerance is intentionally broad: Word stores indent in twips and rounding can cause
-				// small discrepancies (~1-2mm). Also students sometimes set 1.25cm vs 1.27cm.
-				if math.Abs(p.FirstLineIndentMm-config.Paragraph.FirstLineIndent) > 4.0 {
-					isDoubtful := math.Abs(p.FirstLineIndentMm-config.Paragraph.FirstLineIndent) <= 7.0
-					violations = append(violations, models.Violation{
-						RuleType: "indent", Description: "Неверный отступ первой строки", PositionInDoc: pos,
-						ExpectedValue: fmt.Sprintf("%.1f мм", config.Paragraph.FirstLineIndent), ActualValue: fmt.Sprintf("%.1f мм", p.FirstLineIndentMm), Severity: "warning",
-						ContextText: p.Text,
-						IsDoubtful:  isDoubtful,
-					})
-				}
-			}
+	return res, violations, nil
+}
 
-			// Advanced Typography Controls
-			if config.Typography.ForbidBold {
-				totalRules++
-				if p.IsBold {
-					violations = append(violations, models.Violation{
-						RuleType: "style_bold", Description: "Жирный шрифт запрещен в основном тексте", PositionInDoc: pos,
-						ExpectedValue: "Обычный", ActualValue: "Жирный", Severity: "error",
-						ContextText: p.Text,
-					})
-				}
-			}
-			if config.Typography.ForbidItalic {
-				totalRules++
-				if p.IsItalic {
-					violations = append(violations, models.Violation{
-						RuleType: "style_italic", Description: "Курсив запрещен в основном тексте", PositionInDoc: pos,
-						ExpectedValue: "Обычный", ActualValue: "Курсив", Severity: "error",
-						ContextText: p.Text,
-					})
-				}
-			}
-			if config.Typography.ForbidUnderline {
-				totalRules++
-				if p.IsUnderline {
-					violations = append(violations, models.Violation{
-						RuleType: "style_underline", Description: "Подчеркивание запрещено", PositionInDoc: pos,
-						ExpectedValue: "Обычный", ActualValue: "Подчеркнутый", Severity: "error",
-						ContextText: p.Text,
-					})
-				}
-			}
-			if config.Typography.ForbidAllCaps {
-				totalRules++
-				if p.IsAllCaps {
-					violations = append(violations, models.Violation{
-						RuleType: "style_caps", Description: "ВСЕ ЗАГЛАВНЫЕ запрещены", PositionInDoc: pos,
-						ExpectedValue: "Обычный", ActualValue: "ВСЕ ЗАГЛАВНЫЕ", Severity: "error",
-						ContextText: p.Text,
-					})
-				}
-			}
-		}
+// ParsePresentation parses the presentation at filePath. Presentations are
+// small and not cached.
+func (s *CheckService) ParsePresentation(ctx context.Context, filePath string) (*ParsedPresentation, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
+	return (&PptxParser{Limits: s.Parser.Limits}).Parse(filePath)
+}
 
-	// Check Doc Limits
-	if config.Scope.MinPages > 0 && doc.Stats.TotalPages < config.Scope.MinPages {
-		violations = append(violations, models.Violation{
-			RuleType: "doc_length", Description: "Документ слишком короткий", PositionInDoc: "Глобально",
-			ExpectedValue: fmt.Sprintf("Мин. %d стр.", config.Scope.MinPages), ActualValue: fmt.Sprintf("%d стр.", doc.Stats.TotalPages), Severity: "error",
-		})
-	}
-	if config.Scope.MaxPages > 0 && doc.Stats.TotalPages > config.Scope.MaxPages {
-		violations = append(violations, models.Violation{
-			RuleType: "doc_length", Description: "Документ слишком длинный", PositionInDoc: "Глобально",
-			ExpectedValue: fmt.Sprintf("Макс. %d стр.", config.Scope.MaxPages), ActualValue: fmt.Sprintf("%d стр.", doc.Stats.TotalPages), Severity: "error",
-		})
+// EvaluatePresentation validates a parsed presentation against the
+// presentation rules of a standard configuration.
+func (s *CheckService) EvaluatePresentation(ctx context.Context, pres *ParsedPresentation, standardJSON string) (*models.CheckResult, []models.Violation, error) {
+	var config ConfigSchema
+	if err := json.Unmarshal([]byte(standardJSON), &config); err != nil {
+		return nil, nil, fmt.Errorf("invalid standard config: %v", err)
 	}
-
-	// Check Introduction Pages
-	if config.Introduction.MinPages > 0 || config.Introduction.MaxPages > 0 || config.Introduction.VerifyPageCountDeclaration {
-		startPage := -1
-		endPage := -1
-		var introductionText strings.Builder // Collect all intro text for declaration check
-
-		for _, p := range doc.Paragraphs {
-			// Use isHeadingParagraph to also catch heuristic headings
-			if isHeadingParagraph(p) {
-				text := strings.ToLower(strings.TrimSpace(p.Text))
-				if startPage == -1 && (strings.Contains(text, "введение") || strings.Contains(text, "introduction")) {
-					startPage = p.PageNumber
-				} else if startPage != -1 && endPage == -1 {
-					endPage = p.PageNumber
-					break
-				}
-			}
-
-			// Collect intro text for declaration verification
-			if startPage != -1 && endPage == -1 {
-				introductionText.WriteString(p.Text)
-				introductionText.WriteString(" ")
-			}
-		}
-
-		// If endPage is not found but startPage is found, assume it goes to the end of document
-		if startPage != -1 && endPage == -1 {
-			endPage = doc.Stats.TotalPages
-			// If total pages is the same as start page, we still count as 1
-			if endPage < startPage {
-				endPage = startPage
-			}
-		}
-
-		if startPage != -1 {
-			// Correct calculation: if intro starts at page 5 and next section at page 8,
-			// intro occupies pages 5,6,7 = 3 pages (endPage - startPage)
-			// But if intro is alone until end, we need +1
-			pCount := endPage - startPage
-			if pCount == 0 {
-				pCount = 1
-			}
-
-			if config.Introduction.MinPages > 0 && pCount < config.Introduction.MinPages {
-				violations = append(violations, models.Violation{
-					RuleType: "intro_length", Description: "Введение слишком короткое", PositionInDoc: fmt.Sprintf("Стр. %d-%d", startPage, endPage),
-					ExpectedValue: fmt.Sprintf("Мин. %d стр.", config.Introduction.MinPages), ActualValue: fmt.Sprintf("%d стр.", pCount), Severity: "error",
-				})
-			}
-			if config.Introduction.MaxPages > 0 && pCount > config.Introduction.MaxPages {
-				violations = append(violations, models.Violation{
-					RuleType: "intro_length", Description: "Введение слишком длинное", PositionInDoc: fmt.Sprintf("Стр. %d-%d", startPage, endPage),
-					ExpectedValue: fmt.Sprintf("Макс. %d стр.", config.Introduction.MaxPages), ActualValue: fmt.Sprintf("%d стр.", pCount), Severity: "error",
-				})
-			}
-
-			// NEW: Verify page count declaration if enabled
-			if config.Introduction.VerifyPageCountDeclaration {
-				// Look for patterns like:
-				// "Введение содержит 3 страницы"
-				// "данный раздел занимает 2 страницы"
-				// "Introduction spans 4 pages"
-				introText := strings.ToLower(introductionText.String())
-
-				// Regex patterns to find declared page counts
-				// Russian: "содержит X страниц", "занимает X страниц"
-				// English: "contains X pages", "spans X pages"
-				patterns := []string{
-					`содержит\s+(\d+)\s+страниц`,
-					`занимает\s+(\d+)\s+страниц`,
-					`содержит\s+(\d+)\s+стр`,
-					`занимает\s+(\d+)\s+стр`,
-					`contains\s+(\d+)\s+pages?`,
-					`spans\s+(\d+)\s+pages?`,
-				}
-
-				declaredPages := -1
-
-				for _, pattern := range patterns {
-					re := regexp.MustCompile(pattern)
-					matches := re.FindStringSubmatch(introText)
-					if len(matches) > 1 {
-						// Found a match, extract the number
-						if num, err := strconv.Atoi(matches[1]); err == nil {
-							declaredPages = num
-							break
-						}
-					}
-				}
-
-				// If we found a declaration, verify it
-				if declaredPages > 0 && declaredPages != pCount {
-					violations = append(violations, models.Violation{
-						RuleType:      "intro_page_declaration_mismatch",
-						Description:   "Несовпадение заявленного и фактического количества страниц Введения",
-						PositionInDoc: fmt.Sprintf("Введение (Стр. %d-%d)", startPage, endPage),
-						ExpectedValue: fmt.Sprintf("Фактически: %d стр.", pCount),
-						ActualValue:   fmt.Sprintf("Заявлено в тексте: %d стр.", declaredPages),
-						Severity:      "warning", // Warning, not error, as declaration might be optional
-						ContextText:   truncate(introductionText.String(), 200),
-					})
-				}
-			}
-		}
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
 	}
 
-	// Check Section Order
-	if config.Structure.SectionOrder != "" {
-		sectionViolations := checkSectionOrder(doc.Paragraphs, config.Structure.SectionOrder)
-		violations = append(violations, sectionViolations...)
-		for _, s := range strings.Split(config.Structure.SectionOrder, ",") {
-			if strings.TrimSpace(s) != "" {
-				totalRules++
-			}
-		}
+	violations := []models.Violation{}
+	totalRules := 0
+	trace := newRuleTrace(&violations, &totalRules)
+	trace.enter("presentation")
+	trace.applied("presentation", "presentation", config.Presentation)
+	trace.read("presentation", "slides", len(pres.Slides))
+	presViolations, presRules := checkPresentation(pres, config.Presentation)
+	violations = append(violations, presViolations...)
+	totalRules += presRules
+	trace.enter("file_name")
+	vFileName, fileNameRules := s.fileNameViolations(trace, config.FileName)
+	violations = append(violations, vFileName...)
+	totalRules += fileNameRules
+	ruleTrace := trace.Stop()
+
+	applySeverityOverrides(violations, config.SeverityOverrides)
+	violations = applyManualChecks(violations, config.ManualChecks)
+	res := scoreResult(violations, totalRules)
+	res.RuleTrace = ruleTrace
+	if s.Logf != nil {
+		s.Logf("📊 Checker: presentation, TotalRules=%d, Violations=%d, Score=%.2f\n", totalRules, len(violations), res.OverallScore)
+	}
+	if contentBytes, err := json.Marshal(pres); err == nil {
+		res.ContentJSON = string(contentBytes)
 	}
+	res.Summary = Summarize(violations)
+	return res, violations, nil
+}
 
+// scoreResult scores the violations found by totalRules checked rules.
+func scoreResult(violations []models.Violation, totalRules int) *models.CheckResult {
 	score := 0.0
 	passedRules := totalRules
 	if totalRules > 0 {
@@ -1326,22 +1154,20 @@ func (s *CheckService) RunCheck(ctx context.Context, filePath string, standardJS
 		}
 		score = math.Max(0, ((float64(totalRules)-penalty)/float64(totalRules))*100.0)
 	}
-
-	res := &models.CheckResult{
-		OverallScore: score,
-		TotalRules:   totalRules,
-		FailedRules:  len(violations),
-		PassedRules:  passedRules,
-	}
-
-	fmt.Printf("📊 Checker: TotalRules=%d, Violations=%d, PassedRules=%d, Score=%.2f\n", totalRules, len(violations), passedRules, score)
-
-	// Serialize Content for View
-	if contentBytes, err := json.Marshal(doc); err == nil {
-		res.ContentJSON = string(contentBytes)
+
+	failedRules := 0
+	for _, v := range violations {
+		if models.IsBlocking(v.Severity) {
+			failedRules++
+		}
 	}
 
-	return res, violations, nil
+	return &models.CheckResult{
+		OverallScore: score,
+		TotalRules:   totalRules,
+		FailedRules:  failedRules,
+		PassedRules:  passedRules,
+	}
 }
 
 // isHeadingStyle returns true if the Word style ID represents a heading, in any locale.
@@ -1371,7 +1197,47 @@ func isHeadingStyle(styleID string) bool {
 // isHeadingParagraph returns true if the paragraph is a heading either via explicit style
 // or via heuristic detection (bold + large font + short line).
 func isHeadingParagraph(p ParsedParagraph) bool {
-	return isHeadingStyle(p.StyleID) || p.HeuristicHeading
+	styled, _ := styledHeading(p)
+	return styled || p.HeuristicHeading
+}
+
+// styledHeading reports whether the paragraph is a heading by its style, and
+// its level: by the built-in heading styles unless the standard configures
+// heading detection.
+func styledHeading(p ParsedParagraph) (bool, int) {
+	if p.HeadingDetected {
+		return p.StyledHeading, p.StyledLevel
+	}
+	return builtinHeading(p)
+}
+
+// builtinHeading recognizes headings by the built-in heading style IDs and by
+// the outline level (w:outlineLvl) of the paragraph or its style. The outline
+// level does not depend on the language or the names of the styles, so it
+// also finds the headings of templates with their own style names.
+func builtinHeading(p ParsedParagraph) (bool, int) {
+	if isHeadingStyle(p.StyleID) {
+		if level := headingLevelFromStyle(p.StyleID); level > 0 {
+			return true, level
+		}
+		return true, p.OutlineLevel
+	}
+	if p.OutlineLevel > 0 {
+		return true, p.OutlineLevel
+	}
+	return false, 0
+}
+
+// paragraphHeadingLevel returns the level of a heading paragraph, or 0 if it
+// is not a heading or its level is unknown.
+func paragraphHeadingLevel(p ParsedParagraph) int {
+	if styled, level := styledHeading(p); styled {
+		return level
+	}
+	if p.HeuristicHeading {
+		return p.HeuristicLevel
+	}
+	return 0
 }
 
 // normalizeForTOC strips all whitespace and converts to lowercase to enable
@@ -1410,8 +1276,8 @@ func checkMargins(actual Margins, target MarginsConfig) []models.Violation {
 	vs := []models.Violation{}
 	tol := target.Tolerance
 	if tol == 0 {
-		tol = 2.0
-	} // Default 2mm tolerance
+		tol = BuiltinDefaults.MarginToleranceMm
+	}
 
 	addMarginViolation := func(ruleType, description string, expected, actualValue float64) {
 		if expected <= 0 {
@@ -1426,11 +1292,10 @@ func checkMargins(actual Margins, target MarginsConfig) []models.Violation {
 		if isDoubtful {
 			severity = "warning"
 		}
-		vs = append(vs, models.Violation{
+		vs = append(vs, withValues(models.Violation{
 			RuleType: ruleType, Description: description, Severity: severity,
-			ExpectedValue: fmt.Sprintf("%.1f мм", expected), ActualValue: fmt.Sprintf("%.1f мм", actualValue),
 			IsDoubtful: isDoubtful,
-		})
+		}, models.UnitMillimeter, expected, actualValue))
 	}
 
 	addMarginViolation("margin_top", "Неверный верхний отступ", target.Top, actual.TopMm)
@@ -1440,14 +1305,244 @@ func checkMargins(actual Margins, target MarginsConfig) []models.Violation {
 	return vs
 }
 
+// checkHeadersFooters checks the content of headers and footers: the page
+// number field, an empty title page and running titles.
+func checkHeadersFooters(doc *ParsedDoc, config HeaderFooterConfig) ([]models.Violation, int) {
+	vs := []models.Violation{}
+	rules := 0
+
+	if config.RequirePageNumber {
+		rules++
+		numbered := map[int]bool{}
+		sections := []int{}
+		for _, f := range doc.Footers {
+			if f.Type != "default" {
+				continue
+			}
+			if _, seen := numbered[f.Section]; !seen {
+				sections = append(sections, f.Section)
+			}
+			numbered[f.Section] = f.HasPageNumber
+		}
+		actual := "нижний колонтитул отсутствует"
+		for _, h := range doc.Headers {
+			if h.HasPageNumber {
+				actual = "номер страницы в верхнем колонтитуле"
+				break
+			}
+		}
+		if len(sections) == 0 {
+			vs = append(vs, models.Violation{
+				RuleType: "page_numbering", Description: "Нет номера страницы в нижнем колонтитуле",
+				ExpectedValue: "поле номера страницы внизу страницы", ActualValue: actual, Severity: "error",
+			})
+		}
+		for _, s := range sections {
+			if numbered[s] {
+				continue
+			}
+			vs = append(vs, models.Violation{
+				RuleType: "page_numbering", Description: "Нет номера страницы в нижнем колонтитуле",
+				PositionInDoc: fmt.Sprintf("Раздел %d", s+1),
+				ExpectedValue: "поле номера страницы внизу страницы", ActualValue: "нижний колонтитул без номера страницы",
+				Severity: "error",
+			})
+		}
+	}
+
+	if config.PageNumberAlignment != "" {
+		expected := normalizeAlignment(config.PageNumberAlignment)
+		for _, f := range doc.Footers {
+			if f.Type != "default" || !f.HasPageNumber {
+				continue
+			}
+			rules++
+			actual := normalizeAlignment(f.PageNumberAlignment)
+			if actual == "" {
+				actual = "left"
+			}
+			if actual != expected {
+				vs = append(vs, models.Violation{
+					RuleType: "page_number_alignment", Description: "Неверное положение номера страницы",
+					PositionInDoc: fmt.Sprintf("Раздел %d", f.Section+1),
+					ExpectedValue: expected, ActualValue: actual, Severity: "warning",
+				})
+			}
+		}
+	}
+
+	if config.TitlePageEmpty {
+		rules++
+		// Without w:titlePg the first page shows the section's default parts.
+		titleType := "default"
+		if doc.TitlePage {
+			titleType = "first"
+		}
+		for _, part := range []struct {
+			name  string
+			items []ParsedHeaderFooter
+		}{{"верхний", doc.Headers}, {"нижний", doc.Footers}} {
+			for _, hf := range part.items {
+				if hf.Section != 0 || hf.Type != titleType || hf.IsEmpty() {
+					continue
+				}
+				actual := truncate(hf.Text, 50)
+				if actual == "" {
+					actual = "номер страницы"
+				}
+				vs = append(vs, models.Violation{
+					RuleType: "header_title_page", Description: fmt.Sprintf("На титульном листе есть %s колонтитул", part.name),
+					PositionInDoc: "Титульный лист", ExpectedValue: "пустой колонтитул", ActualValue: actual,
+					Severity: "warning", Location: &models.Location{Page: 1},
+				})
+			}
+		}
+	}
+
+	if config.RunningTitle {
+		for _, h := range doc.Headers {
+			if h.Type != "default" || strings.TrimSpace(h.Text) == "" {
+				continue
+			}
+			headings := sectionHeadings(doc.Paragraphs, h.ParagraphStart, h.ParagraphEnd)
+			if len(headings) == 0 {
+				continue
+			}
+			rules++
+			title := normalizeForTOC(h.Text)
+			matched := false
+			for _, heading := range headings {
+				if title != "" && strings.HasPrefix(normalizeForTOC(heading), title) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				loc := &models.Location{}
+				if h.ParagraphStart < len(doc.Paragraphs) {
+					loc = paragraphLocation(h.ParagraphStart, doc.Paragraphs[h.ParagraphStart])
+				}
+				vs = append(vs, models.Violation{
+					RuleType: "header_running_title", Description: "Колонтитул не совпадает с названием раздела",
+					PositionInDoc: fmt.Sprintf("Раздел %d", h.Section+1),
+					ExpectedValue: truncate(headings[0], 50), ActualValue: truncate(h.Text, 50),
+					Severity: "warning", IsDoubtful: true, Location: loc,
+				})
+			}
+		}
+	}
+
+	return vs, rules
+}
+
+// sectionHeadings returns the texts of the level 1 headings in paragraphs
+// [start, end). A section that continues a chapter has none; the nearest
+// heading before it is returned instead.
+func sectionHeadings(paragraphs []ParsedParagraph, start, end int) []string {
+	level1 := func(p ParsedParagraph) bool {
+		if strings.TrimSpace(p.Text) == "" || !isHeadingParagraph(p) {
+			return false
+		}
+		return paragraphHeadingLevel(p) == 1
+	}
+
+	if end > len(paragraphs) {
+		end = len(paragraphs)
+	}
+	var headings []string
+	for i := start; i < end; i++ {
+		if level1(paragraphs[i]) {
+			headings = append(headings, strings.TrimSpace(paragraphs[i].Text))
+		}
+	}
+	if len(headings) == 0 {
+		for i := start - 1; i >= 0 && i < len(paragraphs); i-- {
+			if level1(paragraphs[i]) {
+				return []string{strings.TrimSpace(paragraphs[i].Text)}
+			}
+		}
+	}
+	return headings
+}
+
+// maxContextLen bounds the document text copied into a violation; the snippet
+// is only used to locate the violation and as context for AI verification.
+const maxContextLen = 300
+
+func contextSnippet(text string) string {
+	return truncate(text, maxContextLen)
+}
+
+// withValues attaches the numeric expected/actual values of a measurement to v
+// and renders their text in the default language.
+func withValues(v models.Violation, unit string, expected, actual float64) models.Violation {
+	v.Unit = unit
+	v.ExpectedNum, v.ActualNum = &expected, &actual
+	i18n.Render(&v, i18n.DefaultLang)
+	return v
+}
+
+// paragraphLocation anchors a violation at the i-th parsed paragraph.
+func paragraphLocation(i int, p ParsedParagraph) *models.Location {
+	return &models.Location{Page: p.PageNumber, ParagraphIndex: intPtr(i), ParagraphID: p.ID}
+}
+
+// anchorViolations sets loc on the violations that do not carry a more
+// precise location yet.
+func anchorViolations(vs []models.Violation, loc *models.Location) {
+	for i := range vs {
+		if vs[i].Location == nil {
+			l := *loc
+			vs[i].Location = &l
+		}
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// withBound is withValues for a limit (models.BoundMin/BoundMax) instead of an
+// exact expected value.
+func withBound(v models.Violation, unit, bound string, limit, actual float64) models.Violation {
+	v.ExpectedBound = bound
+	return withValues(v, unit, limit, actual)
+}
+
+// tocHeadingPages maps normalized heading titles to their page numbers.
+func tocHeadingPages(paragraphs []ParsedParagraph) map[string]int {
+	headingMap := make(map[string]int)
+	for _, p := range paragraphs {
+		t := strings.TrimSpace(p.Text)
+		if t != "" && isHeadingParagraph(p) {
+			headingMap[normalizeForTOC(t)] = p.PageNumber
+		}
+	}
+	return headingMap
+}
+
+// truncate shortens s to at most n characters (runes, not bytes, so Cyrillic
+// text is never cut mid-character), ending a shortened string with an ellipsis.
 func truncate(s string, n int) string {
-	if len(s) > n {
-		return s[:n]
+	if n <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := 0
+	for i := range s {
+		if runes == n-1 {
+			return strings.TrimRightFunc(s[:i], unicode.IsSpace) + "…"
+		}
+		runes++
 	}
 	return s
 }
 
-func checkTables(tables []ParsedTable, paragraphs []ParsedParagraph, config TableConfig) ([]models.Violation, int) {
+// checkTables validates tables and their captions. Tables placed before
+// startPage (e.g. on the title page) are out of scope, like paragraphs.
+func checkTables(tables []ParsedTable, paragraphs []ParsedParagraph, config TableConfig, startPage int) ([]models.Violation, int) {
 	vs := []models.Violation{}
 	rules := 0
 
@@ -1459,13 +1554,15 @@ func checkTables(tables []ParsedTable, paragraphs []ParsedParagraph, config Tabl
 		return vs, 0
 	}
 
-	captionKw := config.CaptionKeyword
-	if captionKw == "" {
-		captionKw = "Таблица"
-	}
+	captionKw := captionKeyword(config.CaptionKeyword, config.defaultKeyword, BuiltinDefaults.TableCaptionKeyword)
 
 	for idx, t := range tables {
+		if startPage > 1 && t.PageNumber > 0 && t.PageNumber < startPage {
+			continue
+		}
 		pos := fmt.Sprintf("Таблица %d", idx+1)
+		loc := &models.Location{Page: t.PageNumber, TableIndex: intPtr(idx)}
+		start := len(vs)
 
 		// 1. Alignment
 		if config.Alignment != "" {
@@ -1576,14 +1673,12 @@ func checkTables(tables []ParsedTable, paragraphs []ParsedParagraph, config Tabl
 			// width value in pct is stored as 50ths of percent in OOXML (5000 = 100%)
 			actualPct := t.WidthValue / 50
 			if actualPct > config.MaxWidthPct {
-				vs = append(vs, models.Violation{
+				vs = append(vs, withValues(models.Violation{
 					RuleType:      "table_width",
 					Description:   "Таблица шире допустимого",
 					PositionInDoc: pos,
-					ExpectedValue: fmt.Sprintf("%d%%", config.MaxWidthPct),
-					ActualValue:   fmt.Sprintf("%d%%", actualPct),
 					Severity:      "warning",
-				})
+				}, models.UnitPercent, float64(config.MaxWidthPct), float64(actualPct)))
 			}
 		}
 
@@ -1623,24 +1718,22 @@ func checkTables(tables []ParsedTable, paragraphs []ParsedParagraph, config Tabl
 						ExpectedValue: expected,
 						ActualValue:   actual,
 						Severity:      "warning",
-						ContextText:   t.CaptionText,
+						ContextText:   contextSnippet(t.CaptionText),
 						IsDoubtful:    true,
 					})
 				}
 			}
 
 			rules++
-			if math.Abs(t.CaptionIndentMm-config.CaptionIndentMm) > 2.0 {
-				vs = append(vs, models.Violation{
+			if math.Abs(t.CaptionIndentMm-config.CaptionIndentMm) > config.CaptionIndentTolerance {
+				vs = append(vs, withValues(models.Violation{
 					RuleType:      "table_caption_indent",
 					Description:   "Неверный отступ первой строки подписи таблицы",
 					PositionInDoc: pos,
-					ExpectedValue: fmt.Sprintf("%.1f мм", config.CaptionIndentMm),
-					ActualValue:   fmt.Sprintf("%.1f мм", t.CaptionIndentMm),
 					Severity:      "warning",
-					ContextText:   t.CaptionText,
+					ContextText:   contextSnippet(t.CaptionText),
 					IsDoubtful:    math.Abs(t.CaptionIndentMm-config.CaptionIndentMm) <= 4.0,
-				})
+				}, models.UnitMillimeter, config.CaptionIndentMm, t.CaptionIndentMm))
 			}
 
 			if config.CaptionMaxSpacingPt >= 0 {
@@ -1654,7 +1747,7 @@ func checkTables(tables []ParsedTable, paragraphs []ParsedParagraph, config Tabl
 						ExpectedValue: fmt.Sprintf("не больше %.1f pt до/после", maxSpacing),
 						ActualValue:   fmt.Sprintf("%.1f pt до, %.1f pt после", t.CaptionBeforePt, t.CaptionAfterPt),
 						Severity:      "warning",
-						ContextText:   t.CaptionText,
+						ContextText:   contextSnippet(t.CaptionText),
 						IsDoubtful:    true,
 					})
 				}
@@ -1669,16 +1762,15 @@ func checkTables(tables []ParsedTable, paragraphs []ParsedParagraph, config Tabl
 				// Heights not explicitly set — rows may be auto-sized (cannot verify)
 				// Do nothing: we can only flag rows that are explicitly too small
 			} else if t.MinRowHeightMm < config.MinRowHeightMm {
-				vs = append(vs, models.Violation{
+				vs = append(vs, withBound(models.Violation{
 					RuleType:      "table_row_height",
 					Description:   "Высота строки таблицы меньше допустимой",
 					PositionInDoc: pos,
-					ExpectedValue: fmt.Sprintf("≥ %.1f мм", config.MinRowHeightMm),
-					ActualValue:   fmt.Sprintf("%.1f мм", t.MinRowHeightMm),
 					Severity:      "warning",
-				})
+				}, models.UnitMillimeter, models.BoundMin, config.MinRowHeightMm, t.MinRowHeightMm))
 			}
 		}
+		anchorViolations(vs[start:], loc)
 	}
 	if config.CheckSequence {
 		captionItems := captionNumbersFromParagraphs(paragraphs, "table_caption", tableCaptionNumberRe)
@@ -1694,14 +1786,22 @@ func checkTables(tables []ParsedTable, paragraphs []ParsedParagraph, config Tabl
 		if len(captions) == 0 {
 			captions = tableCaptionNumberSet(tables)
 		}
-		refViolations, refRules := checkObjectTextReferences("table", captions, paragraphs, tableRefRegex)
+		refViolations, refRules := checkObjectTextReferences("table", captions, len(tables), paragraphs, tableRefRegex)
 		vs = append(vs, refViolations...)
 		rules += refRules
+
+		captionItems := captionNumbersFromParagraphs(paragraphs, "table_caption", tableCaptionNumberRe)
+		if len(captionItems) == 0 {
+			captionItems = tableCaptionNumbers(tables)
+		}
+		unrefViolations, unrefRules := checkUnreferencedObjects("table", captionItems, paragraphs, tableRefRegex)
+		vs = append(vs, unrefViolations...)
+		rules += unrefRules
 	}
 	return vs, rules
 }
 
-func checkImages(images []ParsedImage, paragraphs []ParsedParagraph, config ImageConfig) ([]models.Violation, int) {
+func checkImages(images []ParsedImage, paragraphs []ParsedParagraph, config ImageConfig, startPage int) ([]models.Violation, int) {
 	vs := []models.Violation{}
 	rules := 0
 
@@ -1712,13 +1812,15 @@ func checkImages(images []ParsedImage, paragraphs []ParsedParagraph, config Imag
 		return vs, rules
 	}
 
-	keyword := strings.TrimSpace(config.CaptionKeyword)
-	if keyword == "" {
-		keyword = "Рисунок"
-	}
+	keyword := captionKeyword(config.CaptionKeyword, config.defaultKeyword, BuiltinDefaults.FigureCaptionKeyword)
 
 	for i, img := range images {
+		if startPage > 1 && img.PageNumber < startPage {
+			continue
+		}
 		pos := fmt.Sprintf("Рисунок %d, страница %d", i+1, img.PageNumber)
+		loc := &models.Location{Page: img.PageNumber, ParagraphIndex: intPtr(img.ParagraphIndex), ParagraphID: img.ParagraphID, ImageIndex: intPtr(i)}
+		start := len(vs)
 
 		if config.Alignment != "" {
 			rules++
@@ -1743,12 +1845,16 @@ func checkImages(images []ParsedImage, paragraphs []ParsedParagraph, config Imag
 		if config.RequireCaption {
 			rules++
 			if !img.HasCaption {
+				actual := "Подпись не найдена рядом с рисунком"
+				if img.NextParagraphIndex >= 0 && img.NextParagraphIndex < len(paragraphs) {
+					actual = "После рисунка: " + truncate(strings.TrimSpace(paragraphs[img.NextParagraphIndex].Text), 50)
+				}
 				vs = append(vs, models.Violation{
 					RuleType:      "image_caption_missing",
 					Description:   "У рисунка отсутствует подпись",
 					PositionInDoc: pos,
 					ExpectedValue: keyword,
-					ActualValue:   "Подпись не найдена рядом с рисунком",
+					ActualValue:   actual,
 					Severity:      "warning",
 					IsDoubtful:    true,
 				})
@@ -1765,7 +1871,7 @@ func checkImages(images []ParsedImage, paragraphs []ParsedParagraph, config Imag
 					ExpectedValue: keyword,
 					ActualValue:   truncate(img.CaptionText, 50),
 					Severity:      "warning",
-					ContextText:   img.CaptionText,
+					ContextText:   contextSnippet(img.CaptionText),
 					IsDoubtful:    true,
 				})
 			}
@@ -1788,7 +1894,7 @@ func checkImages(images []ParsedImage, paragraphs []ParsedParagraph, config Imag
 					ExpectedValue: expected,
 					ActualValue:   actual,
 					Severity:      "warning",
-					ContextText:   img.CaptionText,
+					ContextText:   contextSnippet(img.CaptionText),
 				})
 			}
 		}
@@ -1803,7 +1909,7 @@ func checkImages(images []ParsedImage, paragraphs []ParsedParagraph, config Imag
 					ExpectedValue: "Рисунок N – Название",
 					ActualValue:   truncate(img.CaptionText, 50),
 					Severity:      "warning",
-					ContextText:   img.CaptionText,
+					ContextText:   contextSnippet(img.CaptionText),
 				})
 			}
 		}
@@ -1824,24 +1930,22 @@ func checkImages(images []ParsedImage, paragraphs []ParsedParagraph, config Imag
 						ExpectedValue: expected,
 						ActualValue:   actual,
 						Severity:      "warning",
-						ContextText:   img.CaptionText,
+						ContextText:   contextSnippet(img.CaptionText),
 						IsDoubtful:    true,
 					})
 				}
 			}
 
 			rules++
-			if math.Abs(img.CaptionIndentMm-config.CaptionIndentMm) > 2.0 {
-				vs = append(vs, models.Violation{
+			if math.Abs(img.CaptionIndentMm-config.CaptionIndentMm) > config.CaptionIndentTolerance {
+				vs = append(vs, withValues(models.Violation{
 					RuleType:      "image_caption_indent",
 					Description:   "Неверный отступ первой строки подписи рисунка",
 					PositionInDoc: pos,
-					ExpectedValue: fmt.Sprintf("%.1f мм", config.CaptionIndentMm),
-					ActualValue:   fmt.Sprintf("%.1f мм", img.CaptionIndentMm),
 					Severity:      "warning",
-					ContextText:   img.CaptionText,
+					ContextText:   contextSnippet(img.CaptionText),
 					IsDoubtful:    math.Abs(img.CaptionIndentMm-config.CaptionIndentMm) <= 4.0,
-				})
+				}, models.UnitMillimeter, config.CaptionIndentMm, img.CaptionIndentMm))
 			}
 
 			if config.CaptionMaxSpacingPt >= 0 {
@@ -1854,12 +1958,13 @@ func checkImages(images []ParsedImage, paragraphs []ParsedParagraph, config Imag
 						ExpectedValue: fmt.Sprintf("не больше %.1f pt до/после", config.CaptionMaxSpacingPt),
 						ActualValue:   fmt.Sprintf("%.1f pt до, %.1f pt после", img.CaptionBeforePt, img.CaptionAfterPt),
 						Severity:      "warning",
-						ContextText:   img.CaptionText,
+						ContextText:   contextSnippet(img.CaptionText),
 						IsDoubtful:    true,
 					})
 				}
 			}
 		}
+		anchorViolations(vs[start:], loc)
 	}
 	if config.CheckSequence {
 		captionItems := captionNumbersFromParagraphs(paragraphs, "figure_caption", figureCaptionNumberRe)
@@ -1875,26 +1980,36 @@ func checkImages(images []ParsedImage, paragraphs []ParsedParagraph, config Imag
 		if len(captions) == 0 {
 			captions = imageCaptionNumberSet(images)
 		}
-		refViolations, refRules := checkObjectTextReferences("image", captions, paragraphs, figureRefRegex)
+		refViolations, refRules := checkObjectTextReferences("image", captions, len(images), paragraphs, figureRefRegex)
 		vs = append(vs, refViolations...)
 		rules += refRules
+
+		captionItems := captionNumbersFromParagraphs(paragraphs, "figure_caption", figureCaptionNumberRe)
+		if len(captionItems) == 0 {
+			captionItems = imageCaptionNumbers(images)
+		}
+		unrefViolations, unrefRules := checkUnreferencedObjects("image", captionItems, paragraphs, figureRefRegex)
+		vs = append(vs, unrefViolations...)
+		rules += unrefRules
 	}
 
 	return vs, rules
 }
 
 type objectCaptionNumber struct {
-	Number  string
-	Text    string
-	Ordinal int
-	Page    int
+	Number   string
+	Text     string
+	Ordinal  int
+	Page     int
+	Location *models.Location
 }
 
 func tableCaptionNumbers(tables []ParsedTable) []objectCaptionNumber {
 	items := []objectCaptionNumber{}
 	for i, t := range tables {
 		if t.HasCaption {
-			items = append(items, objectCaptionNumber{Number: normalizeObjectNumber(t.CaptionNumber), Text: t.CaptionText, Ordinal: i + 1})
+			items = append(items, objectCaptionNumber{Number: normalizeObjectNumber(t.CaptionNumber), Text: t.CaptionText, Ordinal: i + 1,
+				Location: &models.Location{TableIndex: intPtr(i)}})
 		}
 	}
 	return items
@@ -1904,7 +2019,8 @@ func imageCaptionNumbers(images []ParsedImage) []objectCaptionNumber {
 	items := []objectCaptionNumber{}
 	for i, img := range images {
 		if img.HasCaption {
-			items = append(items, objectCaptionNumber{Number: normalizeObjectNumber(img.CaptionNumber), Text: img.CaptionText, Ordinal: i + 1, Page: img.PageNumber})
+			items = append(items, objectCaptionNumber{Number: normalizeObjectNumber(img.CaptionNumber), Text: img.CaptionText, Ordinal: i + 1, Page: img.PageNumber,
+				Location: &models.Location{Page: img.PageNumber, ParagraphIndex: intPtr(img.ParagraphIndex), ParagraphID: img.ParagraphID, ImageIndex: intPtr(i)}})
 		}
 	}
 	return items
@@ -1932,7 +2048,7 @@ func imageCaptionNumberSet(images []ParsedImage) map[string]bool {
 
 func captionNumbersFromParagraphs(paragraphs []ParsedParagraph, role string, re *regexp.Regexp) []objectCaptionNumber {
 	items := []objectCaptionNumber{}
-	for _, p := range paragraphs {
+	for i, p := range paragraphs {
 		if p.Role != role {
 			continue
 		}
@@ -1941,10 +2057,11 @@ func captionNumbersFromParagraphs(paragraphs []ParsedParagraph, role string, re
 			continue
 		}
 		items = append(items, objectCaptionNumber{
-			Number:  normalizeObjectNumber(extractCaptionNumber(text, re)),
-			Text:    text,
-			Ordinal: len(items) + 1,
-			Page:    p.PageNumber,
+			Number:   normalizeObjectNumber(extractCaptionNumber(text, re)),
+			Text:     text,
+			Ordinal:  len(items) + 1,
+			Page:     p.PageNumber,
+			Location: paragraphLocation(i, p),
 		})
 	}
 	return items
@@ -2033,10 +2150,11 @@ func checkObjectCaptionSequence(kind string, items []objectCaptionNumber, reques
 				RuleType:      rulePrefix + "_caption_number_missing",
 				Description:   "Не удалось определить номер " + label + " из подписи",
 				PositionInDoc: position,
+				Location:      item.Location,
 				ExpectedValue: "Номер в подписи",
 				ActualValue:   truncate(item.Text, 80),
 				Severity:      "warning",
-				ContextText:   item.Text,
+				ContextText:   contextSnippet(item.Text),
 				IsDoubtful:    true,
 			})
 			continue
@@ -2046,10 +2164,11 @@ func checkObjectCaptionSequence(kind string, items []objectCaptionNumber, reques
 				RuleType:      rulePrefix + "_caption_number_duplicate",
 				Description:   "Повторяется номер " + label,
 				PositionInDoc: position,
+				Location:      item.Location,
 				ExpectedValue: "Уникальный номер",
 				ActualValue:   fmt.Sprintf("%s уже был у объекта %d", item.Number, prev),
 				Severity:      "error",
-				ContextText:   item.Text,
+				ContextText:   contextSnippet(item.Text),
 			})
 			continue
 		}
@@ -2061,10 +2180,11 @@ func checkObjectCaptionSequence(kind string, items []objectCaptionNumber, reques
 				RuleType:      rulePrefix + "_caption_number_format",
 				Description:   "Номер " + label + " записан в непонятном формате",
 				PositionInDoc: position,
+				Location:      item.Location,
 				ExpectedValue: "1, 2, 3 или 3.1, 3.2",
 				ActualValue:   item.Number,
 				Severity:      "warning",
-				ContextText:   item.Text,
+				ContextText:   contextSnippet(item.Text),
 				IsDoubtful:    true,
 			})
 			continue
@@ -2098,10 +2218,11 @@ func checkObjectCaptionSequence(kind string, items []objectCaptionNumber, reques
 				RuleType:      rulePrefix + "_caption_sequence",
 				Description:   "Нарушена последовательность нумерации " + label,
 				PositionInDoc: position,
+				Location:      item.Location,
 				ExpectedValue: expected,
 				ActualValue:   item.Number,
 				Severity:      "warning",
-				ContextText:   item.Text,
+				ContextText:   contextSnippet(item.Text),
 				IsDoubtful:    mode == "section",
 			})
 		}
@@ -2117,48 +2238,154 @@ func checkObjectCaptionSequence(kind string, items []objectCaptionNumber, reques
 
 func captionViolationPosition(label string, item objectCaptionNumber) string {
 	if item.Page > 0 {
-		return fmt.Sprintf("Page %d: %s...", item.Page, truncate(item.Text, 80))
+		return fmt.Sprintf("Page %d: %s", item.Page, truncate(item.Text, 80))
 	}
-	return fmt.Sprintf("%s %d: %s...", label, item.Ordinal, truncate(item.Text, 80))
+	return fmt.Sprintf("%s %d: %s", label, item.Ordinal, truncate(item.Text, 80))
 }
 
-func checkObjectTextReferences(kind string, captions map[string]bool, paragraphs []ParsedParagraph, re *regexp.Regexp) ([]models.Violation, int) {
+// checkObjectTextReferences flags references in the text to tables, figures
+// and formulas the document does not have: "рисунок 7" with no caption
+// numbered 7. Every number of an enumeration or a range is checked, and the
+// violation points at the number in the paragraph. objects is how many
+// objects of the kind the document has; when there are some but none of their
+// numbers is recognized, the references cannot be checked.
+func checkObjectTextReferences(kind string, captions map[string]bool, objects int, paragraphs []ParsedParagraph, re *regexp.Regexp) ([]models.Violation, int) {
 	vs := []models.Violation{}
 	rules := 0
 	rulePrefix := "table"
 	label := "таблицу"
-	if kind == "image" {
+	missing, expected, actual := "такой подписи не найдено", "Существующая подпись ", "Ссылка без найденной подписи"
+	switch kind {
+	case "image":
 		rulePrefix = "image"
 		label = "рисунок"
+	case "formula":
+		rulePrefix = "formula"
+		label = "формулу"
+		missing, expected, actual = "формулы с таким номером нет", "Существующая формула ", "Ссылка на отсутствующий номер"
 	}
-	if len(captions) == 0 {
+	if len(captions) == 0 && objects > 0 {
 		return vs, rules
 	}
+	if len(captions) == 0 {
+		actual = "Ссылка на отсутствующий объект"
+	}
+	for _, m := range objectMentions(paragraphs, re) {
+		rules++
+		if captions[m.Number] {
+			continue
+		}
+		p := paragraphs[m.Paragraph]
+		text := strings.ReplaceAll(p.Text, "\u00a0", " ")
+		loc := paragraphLocation(m.Paragraph, p)
+		loc.CharStart = intPtr(utf8.RuneCountInString(text[:m.Start]))
+		loc.CharEnd = intPtr(utf8.RuneCountInString(text[:m.End]))
+		vs = append(vs, models.Violation{
+			RuleType:      rulePrefix + "_text_reference_missing",
+			Description:   "В тексте есть ссылка на " + label + " " + m.Number + ", но " + missing,
+			PositionInDoc: fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, m.Paragraph+1, truncate(strings.TrimSpace(p.Text), 80)),
+			Location:      loc,
+			ExpectedValue: expected + m.Number,
+			ActualValue:   actual,
+			Severity:      "warning",
+			ContextText:   contextSnippet(p.Text),
+			IsDoubtful:    true,
+		})
+	}
+	return vs, rules
+}
+
+// objectMention is a number the text refers to, with the byte offsets of
+// where it is written in the paragraph text (non-breaking spaces replaced).
+type objectMention struct {
+	Number     string
+	Paragraph  int
+	Start, End int
+}
+
+// objectMentions returns the object numbers the text refers to with re,
+// including the rest of enumerations and ranges such as "таблицы 1, 2 и 4"
+// or "рисунки 3–5". The numbers inside a range are placed at its end.
+func objectMentions(paragraphs []ParsedParagraph, re *regexp.Regexp) []objectMention {
+	var mentions []objectMention
 	for i, p := range paragraphs {
-		if p.Role == "toc" || p.Role == "table_caption" || p.Role == "figure_caption" || strings.TrimSpace(p.Text) == "" {
+		if p.Role == "toc" || p.Role == "table_caption" || p.Role == "figure_caption" {
 			continue
 		}
-		matches := re.FindAllStringSubmatch(strings.ReplaceAll(p.Text, "\u00a0", " "), -1)
-		for _, match := range matches {
-			if len(match) < 2 {
-				continue
-			}
-			rules++
-			number := normalizeObjectNumber(match[1])
-			if !captions[number] {
-				vs = append(vs, models.Violation{
-					RuleType:      rulePrefix + "_text_reference_missing",
-					Description:   "В тексте есть ссылка на " + label + ", но такой подписи не найдено",
-					PositionInDoc: fmt.Sprintf("Page %d, Para %d: %s...", p.PageNumber, i+1, truncate(strings.TrimSpace(p.Text), 80)),
-					ExpectedValue: "Существующая подпись " + number,
-					ActualValue:   "Ссылка без найденной подписи",
-					Severity:      "warning",
-					ContextText:   p.Text,
-					IsDoubtful:    true,
-				})
+		text := strings.ReplaceAll(p.Text, "\u00a0", " ")
+		for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+			last := normalizeObjectNumber(text[m[2]:m[3]])
+			mentions = append(mentions, objectMention{Number: last, Paragraph: i, Start: m[2], End: m[3]})
+			offset := m[1]
+			for {
+				next := objectRefListRegex.FindStringSubmatchIndex(text[offset:])
+				if next == nil {
+					break
+				}
+				start, end := offset+next[4], offset+next[5]
+				number := normalizeObjectNumber(text[start:end])
+				if sep := text[offset+next[2] : offset+next[3]]; sep == "–" || sep == "—" {
+					from, errFrom := strconv.Atoi(last)
+					to, errTo := strconv.Atoi(number)
+					if errFrom == nil && errTo == nil && from < to && to-from <= 100 {
+						for n := from + 1; n < to; n++ {
+							mentions = append(mentions, objectMention{Number: strconv.Itoa(n), Paragraph: i, Start: start, End: end})
+						}
+					}
+				}
+				mentions = append(mentions, objectMention{Number: number, Paragraph: i, Start: start, End: end})
+				last = number
+				offset += next[1]
 			}
 		}
 	}
+	return mentions
+}
+
+// referencedObjectNumbers returns the set of object numbers the text refers
+// to with re.
+func referencedObjectNumbers(paragraphs []ParsedParagraph, re *regexp.Regexp) map[string]bool {
+	referenced := map[string]bool{}
+	for _, m := range objectMentions(paragraphs, re) {
+		referenced[m.Number] = true
+	}
+	return referenced
+}
+
+// checkUnreferencedObjects flags numbered tables, figures and formulas the
+// text never refers to: ГОСТ 7.32 requires a reference to each of them.
+func checkUnreferencedObjects(kind string, items []objectCaptionNumber, paragraphs []ParsedParagraph, re *regexp.Regexp) ([]models.Violation, int) {
+	vs := []models.Violation{}
+	rules := 0
+	rulePrefix, label, objectLabel := "table", "таблицу", "Таблица"
+	switch kind {
+	case "image":
+		rulePrefix, label, objectLabel = "image", "рисунок", "Рисунок"
+	case "formula":
+		rulePrefix, label, objectLabel = "formula", "формулу", "Формула"
+	}
+
+	referenced := referencedObjectNumbers(paragraphs, re)
+	for _, item := range items {
+		if item.Number == "" {
+			continue
+		}
+		rules++
+		if referenced[item.Number] {
+			continue
+		}
+		vs = append(vs, models.Violation{
+			RuleType:      rulePrefix + "_not_referenced",
+			Description:   fmt.Sprintf("В тексте нет ссылки на %s %s", label, item.Number),
+			PositionInDoc: captionViolationPosition(objectLabel, item),
+			Location:      item.Location,
+			ExpectedValue: "Ссылка в тексте",
+			ActualValue:   "Ссылка не найдена",
+			Severity:      "warning",
+			ContextText:   contextSnippet(item.Text),
+			IsDoubtful:    true,
+		})
+	}
 	return vs, rules
 }
 
@@ -2167,7 +2394,7 @@ func checkFormulas(formulas []ParsedFormula, paragraphs []ParsedParagraph, confi
 	rules := 0
 
 	hasAnyConfig := config.Alignment != "" || config.RequireNumbering ||
-		config.RequireSpacingAround || config.CheckWhereNoColon
+		config.RequireSpacingAround || config.CheckWhereNoColon || config.CheckTextReferences
 	if !hasAnyConfig {
 		return vs, 0
 	}
@@ -2183,8 +2410,10 @@ func checkFormulas(formulas []ParsedFormula, paragraphs []ParsedParagraph, confi
 		return strings.TrimSpace(p.Text) == "" || p.SpacingAfterPt >= 6 || p.SpacingBeforePt >= 6
 	}
 
-	for _, f := range formulas {
+	for fi, f := range formulas {
 		pos := fmt.Sprintf("Формула %s", f.ID)
+		loc := &models.Location{ParagraphID: f.WrapperID, FormulaIndex: intPtr(fi)}
+		start := len(vs)
 
 		// 1. Alignment
 		if config.Alignment != "" {
@@ -2291,10 +2520,101 @@ func checkFormulas(formulas []ParsedFormula, paragraphs []ParsedParagraph, confi
 				}
 			}
 		}
+		anchorViolations(vs[start:], loc)
+	}
+
+	// 5. References in the text, in both directions
+	if config.CheckTextReferences {
+		numbers := map[string]bool{}
+		items := []objectCaptionNumber{}
+		for fi, f := range formulas {
+			number := normalizeObjectNumber(f.Number)
+			if number == "" || numbers[number] {
+				continue
+			}
+			numbers[number] = true
+			item := objectCaptionNumber{Number: number, Ordinal: fi + 1,
+				Location: &models.Location{ParagraphID: f.WrapperID, FormulaIndex: intPtr(fi)}}
+			if idx, found := paraIndexByID[f.WrapperID]; found {
+				item.Text = strings.TrimSpace(paragraphs[idx].Text)
+				item.Page = paragraphs[idx].PageNumber
+				item.Location = paragraphLocation(idx, paragraphs[idx])
+				item.Location.FormulaIndex = intPtr(fi)
+			}
+			items = append(items, item)
+		}
+		refViolations, refRules := checkObjectTextReferences("formula", numbers, len(formulas), paragraphs, formulaRefRegex)
+		vs = append(vs, refViolations...)
+		rules += refRules
+		unrefViolations, unrefRules := checkUnreferencedObjects("formula", items, paragraphs, formulaRefRegex)
+		vs = append(vs, unrefViolations...)
+		rules += unrefRules
 	}
 	return vs, rules
 }
 
+// documentObservations reports non-blocking findings about the document as a
+// whole. They are informational and do not count as rules.
+func documentObservations(doc *ParsedDoc) []models.Violation {
+	var vs []models.Violation
+
+	for i, p := range doc.Paragraphs {
+		if p.Role == "toc" {
+			if !doc.Stats.HasTOCField {
+				vs = append(vs, models.Violation{
+					RuleType:      "toc_manual",
+					Description:   "Содержание набрано вручную, а не собрано автоматически",
+					PositionInDoc: "Оглавление",
+					ExpectedValue: "Автоматическое оглавление",
+					ActualValue:   "Набрано вручную",
+					Suggestion:    "Соберите оглавление через «Ссылки → Оглавление», чтобы номера страниц обновлялись сами",
+					Severity:      models.SeverityInfo,
+					Location:      paragraphLocation(i, p),
+				})
+			}
+			break
+		}
+	}
+
+	missingAlt := 0
+	for _, img := range doc.Images {
+		if img.AltText == "" {
+			missingAlt++
+		}
+	}
+	if missingAlt > 0 {
+		vs = append(vs, models.Violation{
+			RuleType:      "image_alt_text_missing",
+			Description:   fmt.Sprintf("Рисунков без замещающего текста: %d", missingAlt),
+			PositionInDoc: "Глобально",
+			ExpectedValue: "Замещающий текст у всех рисунков",
+			ActualValue:   fmt.Sprintf("Без текста: %d из %d", missingAlt, len(doc.Images)),
+			Suggestion:    "Добавьте описание через «Формат рисунка → Замещающий текст»",
+			Severity:      models.SeverityInfo,
+		})
+	}
+	return vs
+}
+
+// applySeverityOverrides replaces the severity of violations whose rule type is
+// listed in the standard's severity_overrides. Unknown severities are ignored.
+func applySeverityOverrides(vs []models.Violation, overrides map[string]string) {
+	if len(overrides) == 0 {
+		return
+	}
+	for i := range vs {
+		if vs[i].Severity == models.SeverityIntegrity {
+			continue
+		}
+		if severity, ok := overrides[vs[i].RuleType]; ok {
+			severity = strings.ToLower(strings.TrimSpace(severity))
+			if models.IsValidSeverity(severity) {
+				vs[i].Severity = severity
+			}
+		}
+	}
+}
+
 // checkSectionOrder verifies that document headings appear in the expected order.
 // Expected sections are comma-separated, case-insensitive, and matched against heading
 // text with leading numeric prefixes stripped (e.g. "1.", "1.1.", "I.") so users don't
@@ -2451,6 +2771,18 @@ func checkReferences(paragraphs []ParsedParagraph, cfg ReferencesConfig) ([]mode
 		rules += ageRules
 	}
 
+	if cfg.CheckEntryFormat && found {
+		formatViolations, formatRules := checkReferenceEntries(paragraphs, cfg)
+		violations = append(violations, formatViolations...)
+		rules += formatRules
+	}
+
+	if cfg.CheckCitations && found {
+		citationViolations, citationRules := checkCitations(paragraphs, cfg)
+		violations = append(violations, citationViolations...)
+		rules += citationRules
+	}
+
 	return violations, rules
 }
 
@@ -2461,10 +2793,6 @@ func checkReferencesAge(paragraphs []ParsedParagraph, cfg ReferencesConfig) ([]m
 	var vs []models.Violation
 	rules := 0
 
-	keyword := cfg.TitleKeyword
-	if keyword == "" {
-		keyword = "\u0421\u043f\u0438\u0441\u043e\u043a \u043b\u0438\u0442\u0435\u0440\u0430\u0442\u0443\u0440\u044b"
-	}
 	maxAge := cfg.MaxSourceAgeYears
 	if maxAge <= 0 {
 		maxAge = 5
@@ -2475,30 +2803,8 @@ func checkReferencesAge(paragraphs []ParsedParagraph, cfg ReferencesConfig) ([]m
 	// 4-digit year pattern (1900-2099)
 	yearRe := regexp.MustCompile(`\b(19\d{2}|20\d{2})\b`)
 
-	inRefSection := false
-	for i, p := range paragraphs {
-		text := strings.TrimSpace(p.Text)
-		if text == "" {
-			continue
-		}
-
-		// Detect start of bibliography section: short line containing the keyword
-		// (no isHeadingParagraph requirement — students often use plain bold, not H1)
-		lowerText := strings.ToLower(text)
-		lowerKW := strings.ToLower(keyword)
-		if strings.Contains(lowerText, lowerKW) && len([]rune(text)) <= 120 {
-			inRefSection = true
-			continue
-		}
-
-		// Stop at the next heading of equal or higher level after the bibliography
-		if inRefSection && isHeadingParagraph(p) {
-			break
-		}
-
-		if !inRefSection {
-			continue
-		}
+	for _, e := range bibliographyEntries(paragraphs, cfg) {
+		i, p, text := e.index, e.para, e.text
 
 		// Check any paragraph in the ref section that contains a year
 		// (numbered entries like "1. ..." as well as entries with URLs etc.)
@@ -2511,7 +2817,7 @@ func checkReferencesAge(paragraphs []ParsedParagraph, cfg ReferencesConfig) ([]m
 				continue
 			}
 			if year < oldestAllowed {
-				pos := fmt.Sprintf("Page %d, Para %d: %s...", p.PageNumber, i+1, truncate(text, 80))
+				pos := fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(text, 80))
 				lowerEntry := strings.ToLower(text)
 				isStableSource := strings.Contains(lowerEntry, "гост") || strings.Contains(lowerEntry, "iso") ||
 					strings.Contains(lowerEntry, "закон") || strings.Contains(lowerEntry, "кодекс") ||
@@ -2525,6 +2831,7 @@ func checkReferencesAge(paragraphs []ParsedParagraph, cfg ReferencesConfig) ([]m
 					Severity:      "warning",
 					ContextText:   truncate(text, 150),
 					IsDoubtful:    isStableSource,
+					Location:      paragraphLocation(i, p),
 				})
 				break // one violation per reference entry
 			}
@@ -0,0 +1,244 @@
+package checker
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Benchmarks run over synthetic theses that exercise every rule group. Compare
+// runs with:
+//
+//	go test -run '^$' -bench . -benchmem -count 6 ./internal/checker > new.txt
+//	go run ./cmd/benchgate old.txt new.txt
+
+const benchStandard = `{
+	"margins": {"top": 20, "bottom": 20, "left": 30, "right": 10, "tolerance": 2.5},
+	"font": {"name": "Times New Roman", "size": 14},
+	"paragraph": {"line_spacing": 1.5, "alignment": "justify", "first_line_indent": 12.5},
+	"typography": {"forbid_bold": true, "forbid_italic": true},
+	"headings": {"enabled": true, "levels": {"1": {"check_bold": true, "require_bold": true, "check_all_caps": true, "require_all_caps": true}}},
+	"structure": {"heading_1_start_new_page": true, "heading_hierarchy": true, "verify_toc": true, "section_order": "Введение,Заключение,Список литературы"},
+	"scope": {"forbidden_words": "мы,наш,очевидно"},
+	"introduction": {"min_pages": 2, "verify_page_count_declaration": true},
+	"tables": {"caption_position": "top", "alignment": "center", "require_caption": true, "caption_dash_format": true, "check_sequence": true, "check_text_references": true, "require_borders": true, "require_header_row": true},
+	"images": {"caption_position": "bottom", "alignment": "center", "require_caption": true, "check_sequence": true, "check_text_references": true},
+	"formulas": {"alignment": "center", "require_numbering": true, "require_spacing_around": true, "check_where_no_colon": true},
+	"references": {"required": true, "title_keyword": "Список литературы", "check_source_age": true}
+}`
+
+var benchSizes = []struct {
+	paragraphs int
+	tables     int
+}{
+	{50, 3},
+	{200, 10},
+	{1000, 30},
+}
+
+func benchParagraph(text string, style string, bold bool) string {
+	var b strings.Builder
+	b.WriteString(`<w:p><w:pPr>`)
+	if style != "" {
+		b.WriteString(`<w:pStyle w:val="` + style + `"/>`)
+	}
+	b.WriteString(`<w:spacing w:line="360" w:lineRule="auto"/><w:ind w:firstLine="709"/><w:jc w:val="both"/></w:pPr><w:r><w:rPr><w:rFonts w:ascii="Times New Roman" w:hAnsi="Times New Roman"/><w:sz w:val="28"/>`)
+	if bold {
+		b.WriteString(`<w:b/>`)
+	}
+	b.WriteString(`</w:rPr><w:t xml:space="preserve">` + text + `</w:t></w:r></w:p>`)
+	return b.String()
+}
+
+func benchTable(n int) string {
+	var b strings.Builder
+	b.WriteString(benchParagraph(fmt.Sprintf("Таблица %d – Результаты измерений", n), "", false))
+	b.WriteString(`<w:tbl><w:tblPr><w:jc w:val="center"/><w:tblBorders><w:top w:val="single"/><w:bottom w:val="single"/><w:insideH w:val="single"/></w:tblBorders><w:tblLook w:firstRow="1"/></w:tblPr>`)
+	for r := 0; r < 5; r++ {
+		b.WriteString(`<w:tr>`)
+		for c := 0; c < 4; c++ {
+			b.WriteString(`<w:tc>` + benchParagraph(fmt.Sprintf("Ячейка %d.%d", r, c), "", false) + `</w:tc>`)
+		}
+		b.WriteString(`</w:tr>`)
+	}
+	b.WriteString(`</w:tbl>`)
+	return b.String()
+}
+
+// benchDocumentXML builds a document.xml with a TOC, numbered sections, body
+// text with table/figure references, tables, formulas and a bibliography.
+func benchDocumentXML(paragraphs, tables int) string {
+	sections := paragraphs / 25
+	if sections < 2 {
+		sections = 2
+	}
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:m="http://schemas.openxmlformats.org/officeDocument/2006/math"><w:body>`)
+
+	b.WriteString(benchParagraph("СОДЕРЖАНИЕ", "", true))
+	b.WriteString(benchParagraph("Введение........3", "TOC1", false))
+	for s := 1; s <= sections; s++ {
+		b.WriteString(benchParagraph(fmt.Sprintf("%d Раздел номер %d........%d", s, s, s*3+2), "TOC1", false))
+	}
+	b.WriteString(benchParagraph("Заключение........90", "TOC1", false))
+
+	written, table := 0, 0
+	for s := 0; s <= sections+1 && written < paragraphs; s++ {
+		switch {
+		case s == 0:
+			b.WriteString(benchParagraph("ВВЕДЕНИЕ", "Heading1", true))
+		case s == sections+1:
+			b.WriteString(benchParagraph("ЗАКЛЮЧЕНИЕ", "Heading1", true))
+		default:
+			b.WriteString(benchParagraph(fmt.Sprintf("%d РАЗДЕЛ НОМЕР %d", s, s), "Heading1", true))
+			b.WriteString(benchParagraph(fmt.Sprintf("%d.1 Подраздел", s), "Heading2", true))
+		}
+		for i := 0; i < paragraphs/(sections+2) && written < paragraphs; i++ {
+			text := fmt.Sprintf("Абзац %d основного текста работы, в котором рассматриваются результаты, приведённые в таблице %d и на рисунке %d, а также обсуждается их значение для дальнейших исследований.", written, table+1, s+1)
+			if i%9 == 4 {
+				text += " Очевидно, мы получили ожидаемый результат."
+			}
+			b.WriteString(benchParagraph(text, "", i%17 == 3))
+			written++
+			if i%12 == 6 && table < tables {
+				table++
+				b.WriteString(benchTable(table))
+			}
+			if i%15 == 10 {
+				b.WriteString(`<w:p><w:pPr><w:jc w:val="center"/></w:pPr><m:oMathPara><m:oMath><m:r><m:t>E=mc^2</m:t></m:r></m:oMath></m:oMathPara><w:r><w:t>(` + fmt.Sprint(written) + `)</w:t></w:r></w:p>`)
+				b.WriteString(benchParagraph("где E – энергия:", "", false))
+			}
+		}
+	}
+	for table < tables {
+		table++
+		b.WriteString(benchTable(table))
+	}
+
+	b.WriteString(benchParagraph("СПИСОК ЛИТЕРАТУРЫ", "Heading1", true))
+	for i := 1; i <= 20; i++ {
+		b.WriteString(benchParagraph(fmt.Sprintf("%d. Иванов И. И. Название источника номер %d. – М.: Наука, %d. – 120 с.", i, i, 2005+i), "", false))
+	}
+
+	b.WriteString(`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1134" w:right="567" w:bottom="1134" w:left="1701" w:header="709" w:footer="709"/></w:sectPr></w:body></w:document>`)
+	return b.String()
+}
+
+func writeBenchDocx(b *testing.B, paragraphs, tables int) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), fmt.Sprintf("bench_%d.docx", paragraphs))
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("word/document.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := w.Write([]byte(benchDocumentXML(paragraphs, tables))); err != nil {
+		b.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+func parseBenchDoc(b *testing.B, paragraphs, tables int) *ParsedDoc {
+	b.Helper()
+	doc, err := NewDocParser().Parse(writeBenchDocx(b, paragraphs, tables))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return doc
+}
+
+// silenceStdout drops the checker's progress output for the rest of the
+// benchmark so it does not interleave with the result lines.
+func silenceStdout(b *testing.B) {
+	b.Helper()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	b.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("paragraphs=%d", size.paragraphs), func(b *testing.B) {
+			path := writeBenchDocx(b, size.paragraphs, size.tables)
+			parser := NewDocParser()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.Parse(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("paragraphs=%d", size.paragraphs), func(b *testing.B) {
+			doc := parseBenchDoc(b, size.paragraphs, size.tables)
+			svc := NewCheckService()
+			silenceStdout(b)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := svc.Evaluate(context.Background(), doc, benchStandard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRuleGroups times the rule groups that live outside Evaluate's
+// paragraph loop individually.
+func BenchmarkRuleGroups(b *testing.B) {
+	var config ConfigSchema
+	if err := json.Unmarshal([]byte(benchStandard), &config); err != nil {
+		b.Fatal(err)
+	}
+
+	groups := []struct {
+		name string
+		run  func(doc *ParsedDoc)
+	}{
+		{"tables", func(doc *ParsedDoc) { checkTables(doc.Tables, doc.Paragraphs, config.Tables, config.Scope.StartPage) }},
+		{"images", func(doc *ParsedDoc) { checkImages(doc.Images, doc.Paragraphs, config.Images, config.Scope.StartPage) }},
+		{"formulas", func(doc *ParsedDoc) { checkFormulas(doc.Formulas, doc.Paragraphs, config.Formulas) }},
+		{"references", func(doc *ParsedDoc) { checkReferences(doc.Paragraphs, config.References) }},
+		{"toc_sequence", func(doc *ParsedDoc) { checkTOCSequence(doc.Paragraphs) }},
+		{"section_order", func(doc *ParsedDoc) { checkSectionOrder(doc.Paragraphs, config.Structure.SectionOrder) }},
+	}
+
+	for _, size := range benchSizes {
+		doc := parseBenchDoc(b, size.paragraphs, size.tables)
+		for _, g := range groups {
+			b.Run(fmt.Sprintf("%s/paragraphs=%d", g.name, size.paragraphs), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					g.run(doc)
+				}
+			})
+		}
+	}
+}
@@ -1,8 +1,22 @@
 package checker
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/i18n"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 func TestInferNumberingModePrefersSectionWhenAnyDottedNumberExists(t *testing.T) {
@@ -57,7 +71,7 @@ func TestFigureReferenceToMissingDottedCaptionIsDetectedFromParagraphCaptions(t
 	}
 
 	captions := captionNumberSetFromParagraphs(paragraphs, "figure_caption", figureCaptionNumberRe)
-	violations, rules := checkObjectTextReferences("image", captions, paragraphs, figureRefRegex)
+	violations, rules := checkObjectTextReferences("image", captions, 0, paragraphs, figureRefRegex)
 
 	if rules != 2 {
 		t.Fatalf("expected 2 checked references, got %d", rules)
@@ -70,6 +84,31 @@ func TestFigureReferenceToMissingDottedCaptionIsDetectedFromParagraphCaptions(t
 	}
 }
 
+func TestReferencesToMissingObjectsPointAtTheMention(t *testing.T) {
+	paragraphs := []ParsedParagraph{
+		{Text: "Результаты показаны на рисунках 1, 2 и 7.", Role: "body", PageNumber: 2},
+		{Text: "Рисунок 1 – Схема", Role: "figure_caption", PageNumber: 2},
+		{Text: "Рисунок 2 – Диаграмма", Role: "figure_caption", PageNumber: 3},
+	}
+	captions := captionNumberSetFromParagraphs(paragraphs, "figure_caption", figureCaptionNumberRe)
+	violations, rules := checkObjectTextReferences("image", captions, 2, paragraphs, figureRefRegex)
+	if rules != 3 || len(violations) != 1 {
+		t.Fatalf("expected 3 checked references and 1 violation, got %d and %+v", rules, violations)
+	}
+	loc := violations[0].Location
+	if !strings.Contains(violations[0].Description, "рисунок 7") || *loc.ParagraphIndex != 0 || *loc.CharStart != 39 || *loc.CharEnd != 40 {
+		t.Fatalf("unexpected violation: %q at %+v", violations[0].Description, loc)
+	}
+
+	text := []ParsedParagraph{{Text: "Сводные данные приведены в таблице 4.", Role: "body", PageNumber: 1}}
+	if violations, _ := checkObjectTextReferences("table", map[string]bool{}, 0, text, tableRefRegex); len(violations) != 1 || violations[0].ActualValue != "Ссылка на отсутствующий объект" {
+		t.Fatalf("a reference in a document without tables must be reported, got %+v", violations)
+	}
+	if violations, rules := checkObjectTextReferences("table", map[string]bool{}, 2, text, tableRefRegex); len(violations) != 0 || rules != 0 {
+		t.Fatalf("tables without recognized captions cannot be checked, got %d rules and %+v", rules, violations)
+	}
+}
+
 func TestSectionSequenceUsesCaptionParagraphsEvenWithoutParsedImages(t *testing.T) {
 	paragraphs := []ParsedParagraph{
 		{Text: "Рисунок 1.1 – Диаграмма прецедентов", Role: "figure_caption", PageNumber: 1},
@@ -86,3 +125,2377 @@ func TestSectionSequenceUsesCaptionParagraphsEvenWithoutParsedImages(t *testing.
 		t.Fatalf("unexpected sequence violation: expected=%q actual=%q", violations[0].ExpectedValue, violations[0].ActualValue)
 	}
 }
+
+func TestXMLComplexityRejectsTooManyParagraphs(t *testing.T) {
+	xmlDoc := `<w:document xmlns:w="w"><w:body>` + strings.Repeat(`<w:p><w:r><w:t>x</w:t></w:r></w:p>`, 4) + `</w:body></w:document>`
+
+	if err := checkXMLComplexity(strings.NewReader(xmlDoc), ParserLimits{MaxParagraphs: 4}); err != nil {
+		t.Fatalf("expected 4 paragraphs to be within the limit, got %v", err)
+	}
+
+	err := checkXMLComplexity(strings.NewReader(xmlDoc), ParserLimits{MaxParagraphs: 3})
+	ce, ok := err.(*ComplexityError)
+	if !ok || ce.Limit != "paragraphs" {
+		t.Fatalf("expected a paragraphs ComplexityError, got %v", err)
+	}
+}
+
+func TestXMLComplexityRejectsDeepNesting(t *testing.T) {
+	xmlDoc := strings.Repeat(`<w:tbl><w:tr><w:tc>`, 20) + strings.Repeat(`</w:tc></w:tr></w:tbl>`, 20)
+
+	err := checkXMLComplexity(strings.NewReader(xmlDoc), ParserLimits{MaxXMLDepth: 30})
+	ce, ok := err.(*ComplexityError)
+	if !ok || ce.Limit != "xml_depth" {
+		t.Fatalf("expected an xml_depth ComplexityError, got %v", err)
+	}
+}
+
+func TestReadXMLLimitedRejectsOversizedPart(t *testing.T) {
+	if _, err := readXMLLimited(strings.NewReader(strings.Repeat("a", 11)), 10); err == nil {
+		t.Fatal("expected an xml_size error for 11 bytes with a 10 byte limit")
+	}
+	if data, err := readXMLLimited(strings.NewReader("abc"), 10); err != nil || string(data) != "abc" {
+		t.Fatalf("unexpected result: %q, %v", data, err)
+	}
+}
+
+func buildZip(t *testing.T, entries map[string]string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range entries {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestZipPackageLimitsRejectBombs(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"word/document.xml": strings.Repeat("a", 4096),
+		"word/styles.xml":   "<w:styles/>",
+	})
+
+	if err := checkZipPackage(r.File, ParserLimits{MaxZipEntries: 1}); err == nil || err.(*ComplexityError).Limit != "zip_entries" {
+		t.Fatalf("expected zip_entries error, got %v", err)
+	}
+	if err := checkZipPackage(r.File, ParserLimits{MaxEntryBytes: 1024}); err == nil || err.(*ComplexityError).Limit != "zip_entry_size" {
+		t.Fatalf("expected zip_entry_size error, got %v", err)
+	}
+	if err := checkZipPackage(r.File, ParserLimits{MaxTotalBytes: 4100}); err == nil || err.(*ComplexityError).Limit != "zip_total_size" {
+		t.Fatalf("expected zip_total_size error, got %v", err)
+	}
+
+	for _, f := range r.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		_, err := readEntryLimited(f, ParserLimits{MaxEntryBytes: 1024}, 0)
+		if ce, ok := err.(*ComplexityError); !ok || ce.Limit != "zip_entry_size" {
+			t.Fatalf("expected reading to stop at the entry limit, got %v", err)
+		}
+	}
+}
+
+func TestRuleClockAccumulatesRepeatedRules(t *testing.T) {
+	clock := newRuleClock()
+	for i := 0; i < 3; i++ {
+		clock.Enter("paragraphs")
+		clock.Enter("body_formatting")
+	}
+	clock.Enter("score")
+	if clock.Current() != "score" {
+		t.Fatalf("expected current rule score, got %q", clock.Current())
+	}
+
+	timings := clock.Stop()
+	for _, rule := range []string{"paragraphs", "body_formatting", "score"} {
+		if _, ok := timings[rule]; !ok {
+			t.Fatalf("missing timing for %s in %v", rule, timings)
+		}
+	}
+	if len(timings) != 3 {
+		t.Fatalf("expected 3 rule groups, got %v", timings)
+	}
+}
+
+func TestForbiddenWordsMatchWholeWordsOnly(t *testing.T) {
+	words := compileForbiddenWords(" Мы , ,очевидно")
+	if len(words) != 2 {
+		t.Fatalf("expected 2 compiled words, got %d", len(words))
+	}
+	if len(words[:1].find("нам нужно мыться")) != 1 {
+		t.Fatal("'мы' should match its form 'нам'")
+	}
+	if len(words[:1].find("нужно мыться")) != 0 {
+		t.Fatal("'мы' must not match inside 'мыться'")
+	}
+	if len(words[:1].find("здесь мы видим")) != 1 {
+		t.Fatal("'мы' should match as a separate word")
+	}
+}
+
+func TestVocabularyMatchesRussianWordForms(t *testing.T) {
+	for word, key := range map[string]string{
+		"работа": "работ", "работой": "работ", "работами": "работ",
+		"нами": "мы", "Нас": "мы", "нашими": "наш", "мною": "я",
+		"очевидно": "очевидн", "очевидный": "очевидн", "ценностей": "ценност",
+	} {
+		if got := wordFormKey(word); got != key {
+			t.Errorf("wordFormKey(%q) = %q, want %q", word, got, key)
+		}
+	}
+
+	words := compileForbiddenWords("мы, в принципе, =типа")
+	text := "по нашим данным нами, в принципах работы, получен тип решения типа того"
+	var found []string
+	for _, m := range words.find(text) {
+		found = append(found, m.word.word+"="+text[m.start:m.end])
+	}
+	want := []string{"мы=нами", "в принципе=в принципах", "типа=типа"}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("expected %v, got %v", want, found)
+	}
+
+	exact := compileVocabulary(ScopeConfig{Vocabulary: []VocabularyDictionary{{Morphology: true,
+		Words: []VocabularyWord{{Word: "типа", Exact: true}}}}})
+	if m := exact.find("получен тип решения"); len(m) != 0 {
+		t.Errorf("an exact entry must not match other forms: %v", m)
+	}
+}
+
+func TestVocabularyDictionariesCarrySeverityAndReplacements(t *testing.T) {
+	doc := &ParsedDoc{Paragraphs: []ParsedParagraph{
+		{ID: "p-1", Text: "Короче, в нашей работе мы рассмотрим метод.", PageNumber: 1, Role: "body"},
+	}}
+	svc := NewCheckService()
+	svc.Dictionaries = func(ids []uint) []VocabularyDictionary {
+		if len(ids) != 1 || ids[0] != 7 {
+			t.Fatalf("unexpected dictionary ids %v", ids)
+		}
+		return []VocabularyDictionary{{Category: VocabularyFirstPerson, Severity: "warning", Morphology: true,
+			Words: []VocabularyWord{{Word: "наш", Replacements: []string{"данный"}}, {Word: "мы"}}}}
+	}
+	_, violations, err := svc.Evaluate(context.Background(), doc, `{"scope": {"forbidden_words": "мы", "dictionaries": [7],
+		"vocabulary": [{"category": "colloquialism", "severity": "hint", "words": [{"word": "Короче", "replacements": ["итак", "таким образом"]}]}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]models.Violation{}
+	for _, v := range violations {
+		if v.RuleType == "vocabulary" {
+			got[v.Description] = v
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 vocabulary violations, got %v", got)
+	}
+	if v := got["Разговорное слово: 'короче'"]; v.Severity != "hint" || v.Suggestion != "Замените на: «итак», «таким образом»" {
+		t.Errorf("unexpected colloquialism violation: %+v", v)
+	}
+	if v := got["Запрещённое слово: 'мы'"]; v.Severity != "error" {
+		t.Errorf("the legacy list must win over a later dictionary listing the same word: %+v", v)
+	}
+	if v := got["Местоимение первого лица: 'наш'"]; v.Severity != "warning" || v.ActualValue != "Присутствует: «нашей»" || v.Suggestion != "Замените на: «данный»" {
+		t.Errorf("unexpected first-person violation: %+v", v)
+	}
+}
+
+func TestRepetitionFindsDuplicatedParagraphsAndBlocks(t *testing.T) {
+	var block []string
+	for i := 1; i <= 60; i++ {
+		block = append(block, fmt.Sprintf("термин%d", i))
+	}
+	para := "Метод позволяет повысить точность расчёта на величину до десяти процентов при тех же затратах."
+	doc := &ParsedDoc{Paragraphs: []ParsedParagraph{
+		{Text: "Титульный лист " + para, PageNumber: 1, Role: "body"},
+		{Text: para, PageNumber: 2, Role: "body"},
+		{Text: strings.Join(block, " ") + ".", PageNumber: 2, Role: "body"},
+		{Text: "Короткий абзац.", PageNumber: 2, Role: "body"},
+		{Text: "Глава 2", PageNumber: 3, Role: "heading"},
+		{Text: "Метод позволяет повысить точность расчёта — на величину до десяти процентов, при тех же затратах", PageNumber: 3, Role: "body"},
+		{Text: "Короткий абзац", PageNumber: 3, Role: "body"},
+		{Text: "Как отмечалось, " + strings.Join(block[:30], " "), PageNumber: 4, Role: "body"},
+		{Text: strings.Join(block[30:], ", ") + " и далее.", PageNumber: 4, Role: "body"},
+	}}
+	vs, rules := checkRepetition(doc, RepetitionConfig{Enabled: true}, 2)
+	if rules != 1 {
+		t.Errorf("expected 1 rule, got %d", rules)
+	}
+	if len(vs) != 2 {
+		t.Fatalf("expected 2 violations, got %+v", vs)
+	}
+	if v := vs[0]; v.RuleType != "duplicate_paragraph" || *v.Location.ParagraphIndex != 5 || v.ActualValue != "Повторяет абзац 2 (стр. 2), 14 слов" {
+		t.Errorf("unexpected duplicate: %+v", v)
+	}
+	if v := vs[1]; v.RuleType != "repeated_block" || *v.Location.ParagraphIndex != 7 || v.ActualValue != "60 слов повторяют абзац 3 (стр. 2)" {
+		t.Errorf("unexpected block: %+v", v)
+	}
+
+	vs, _ = checkRepetition(doc, RepetitionConfig{Enabled: true, MinBlockWords: 61}, 2)
+	if len(vs) != 1 {
+		t.Errorf("a block shorter than min_block_words must not be reported: %+v", vs)
+	}
+}
+
+func TestTruncateIsRuneSafe(t *testing.T) {
+	text := "Введение в теорию автоматической проверки документов"
+
+	got := truncate(text, 10)
+	if !utf8.ValidString(got) {
+		t.Fatalf("truncate produced invalid UTF-8: %q", got)
+	}
+	if got != "Введение…" {
+		t.Fatalf("truncate(text, 10) = %q, want %q", got, "Введение…")
+	}
+	if n := utf8.RuneCountInString(got); n > 10 {
+		t.Fatalf("truncate returned %d runes, want at most 10", n)
+	}
+
+	if got := truncate("Глава", 5); got != "Глава" {
+		t.Fatalf("short text must be returned unchanged, got %q", got)
+	}
+
+	for n := 1; n < utf8.RuneCountInString(text); n++ {
+		if got := truncate(text, n); !utf8.ValidString(got) {
+			t.Fatalf("truncate(text, %d) produced invalid UTF-8: %q", n, got)
+		}
+	}
+}
+
+func TestViolationValuesAreStoredSeparatelyFromText(t *testing.T) {
+	v := withBound(models.Violation{RuleType: "doc_length"}, models.UnitPages, models.BoundMin, 30, 24)
+	if v.ExpectedNum == nil || *v.ExpectedNum != 30 || v.ActualNum == nil || *v.ActualNum != 24 {
+		t.Fatalf("numeric values not recorded: %+v", v)
+	}
+	if v.ExpectedValue != "не менее 30 стр." || v.ActualValue != "24 стр." {
+		t.Fatalf("unexpected default rendering: %q / %q", v.ExpectedValue, v.ActualValue)
+	}
+
+	i18n.Render(&v, i18n.Lang("en-US"))
+	if v.ExpectedValue != "at least 30 pages" || v.ActualValue != "24 pages" {
+		t.Fatalf("unexpected English rendering: %q / %q", v.ExpectedValue, v.ActualValue)
+	}
+}
+
+func TestVocabularyViolationCarriesStructuredLocation(t *testing.T) {
+	doc := &ParsedDoc{Paragraphs: []ParsedParagraph{
+		{ID: "p-1", Text: "", PageNumber: 1},
+		{ID: "p-2", Text: "Здесь мы видим результат", PageNumber: 2, Role: "body"},
+	}}
+	_, violations, err := NewCheckService().Evaluate(context.Background(), doc, `{"scope": {"forbidden_words": "мы"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range violations {
+		if v.RuleType != "vocabulary" {
+			continue
+		}
+		loc := v.Location
+		if loc == nil || loc.Page != 2 || loc.ParagraphID != "p-2" || loc.ParagraphIndex == nil || *loc.ParagraphIndex != 1 {
+			t.Fatalf("unexpected location: %+v", loc)
+		}
+		if loc.CharStart == nil || loc.CharEnd == nil || *loc.CharStart != 6 || *loc.CharEnd != 8 {
+			t.Fatalf("expected rune range [6,8), got %v-%v", loc.CharStart, loc.CharEnd)
+		}
+		return
+	}
+	t.Fatal("expected a vocabulary violation")
+}
+
+func TestInfoSeverityDoesNotAffectScore(t *testing.T) {
+	doc := &ParsedDoc{
+		Paragraphs: []ParsedParagraph{{ID: "p-0", Text: "Введение........3", Role: "toc", PageNumber: 1}},
+		Images:     []ParsedImage{{ID: "img-1"}, {ID: "img-2", AltText: "Схема"}},
+	}
+	res, violations, err := NewCheckService().Evaluate(context.Background(), doc, `{"severity_overrides": {"image_alt_text_missing": "hint"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	severities := map[string]string{}
+	for _, v := range violations {
+		severities[v.RuleType] = v.Severity
+	}
+	if severities["toc_manual"] != models.SeverityInfo {
+		t.Fatalf("expected an info toc_manual observation, got %q", severities["toc_manual"])
+	}
+	if severities["image_alt_text_missing"] != models.SeverityHint {
+		t.Fatalf("severity override not applied, got %q", severities["image_alt_text_missing"])
+	}
+	if res.FailedRules != 0 {
+		t.Fatalf("observations must not count as failed rules, got %d", res.FailedRules)
+	}
+}
+
+func TestManualChecksReplaceAutomatedRules(t *testing.T) {
+	doc := &ParsedDoc{
+		Images: []ParsedImage{{ID: "img-1"}},
+	}
+	config := `{"manual_checks": [
+		{"rule_type": "image_alt_text_missing", "title": "Замещающий текст рисунков", "justification": "Проверяется по бумажному экземпляру"},
+		{"title": "Соответствие темы приказу"}
+	]}`
+	res, violations, err := NewCheckService().Evaluate(context.Background(), doc, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manual := map[string]models.Violation{}
+	for _, v := range violations {
+		if v.Severity == models.SeverityManual {
+			manual[v.RuleType] = v
+		} else if v.RuleType == "image_alt_text_missing" {
+			t.Fatalf("violation of a manual rule reported: %+v", v)
+		}
+	}
+	if len(manual) != 2 {
+		t.Fatalf("expected 2 manual entries, got %+v", manual)
+	}
+	if m := manual["image_alt_text_missing"]; m.Description != "Замещающий текст рисунков" || m.Suggestion != "Проверяется по бумажному экземпляру" {
+		t.Fatalf("unexpected manual entry %+v", m)
+	}
+	if _, ok := manual[manualCheckRule]; !ok {
+		t.Fatal("expected a manual entry for the check without a rule type")
+	}
+	if res.FailedRules != 0 {
+		t.Fatalf("manual checks must not count as failed rules, got %d", res.FailedRules)
+	}
+}
+
+func TestSummarizeNamesMainProblems(t *testing.T) {
+	violations := []models.Violation{
+		{RuleType: "margin_left", Severity: models.SeverityError},
+		{RuleType: "table_caption_missing", Severity: models.SeverityError},
+		{RuleType: "table_caption_missing", Severity: models.SeverityError},
+		{RuleType: "table_caption_missing", Severity: models.SeverityError},
+		{RuleType: "toc_manual", Severity: models.SeverityInfo},
+	}
+	want := "Основные проблемы: отсутствуют подписи у 3 таблиц, поле слева."
+	if got := Summarize(violations); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := Summarize(violations[4:]); got != "Существенных нарушений оформления не найдено." {
+		t.Fatalf("observations must not be summarized, got %q", got)
+	}
+}
+
+func TestStyleSheetResolvesInheritedFormatting(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"word/styles.xml": `<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:docDefaults>
+				<w:rPrDefault><w:rPr><w:rFonts w:asciiTheme="minorHAnsi"/><w:sz w:val="22"/></w:rPr></w:rPrDefault>
+				<w:pPrDefault><w:pPr><w:spacing w:line="259"/></w:pPr></w:pPrDefault>
+			</w:docDefaults>
+			<w:style w:type="paragraph" w:default="1" w:styleId="Normal">
+				<w:pPr><w:jc w:val="both"/><w:spacing w:line="360"/></w:pPr>
+				<w:rPr><w:rFonts w:ascii="Times New Roman"/><w:sz w:val="28"/></w:rPr>
+			</w:style>
+			<w:style w:type="paragraph" w:styleId="Heading1">
+				<w:basedOn w:val="Normal"/>
+				<w:pPr><w:jc w:val="center"/></w:pPr>
+				<w:rPr><w:b/></w:rPr>
+			</w:style>
+			<w:style w:type="paragraph" w:styleId="Plain">
+				<w:rPr><w:sz w:val="24"/></w:rPr>
+			</w:style>
+		</w:styles>`,
+		"word/theme/theme1.xml": `<a:theme xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">
+			<a:themeElements><a:fontScheme>
+				<a:majorFont><a:latin typeface="Calibri Light"/></a:majorFont>
+				<a:minorFont><a:latin typeface="Calibri"/></a:minorFont>
+			</a:fontScheme></a:themeElements>
+		</a:theme>`,
+	})
+	styles := (&DocParser{}).parseStyleSheet(r)
+
+	var body ParsedParagraph
+	styles.apply(&body, nil)
+	if body.FontName != "Times New Roman" || body.FontSizePt != 14 || body.LineSpacing != 1.5 || body.Alignment != "both" {
+		t.Fatalf("paragraph without style should get Normal, got %+v", body)
+	}
+
+	heading := ParsedParagraph{StyleID: "Heading1"}
+	styles.apply(&heading, &RPr{Sz: &Val{Val: "32"}})
+	if heading.FontName != "Times New Roman" || heading.FontSizePt != 16 || !heading.IsBold || heading.Alignment != "center" {
+		t.Fatalf("heading should inherit from Normal and keep run size, got %+v", heading)
+	}
+
+	plain := ParsedParagraph{StyleID: "Plain"}
+	styles.apply(&plain, nil)
+	if plain.FontName != "Calibri" || plain.FontSizePt != 12 || plain.LineSpacing != 259.0/240.0 {
+		t.Fatalf("style without basedOn should start from docDefaults, got %+v", plain)
+	}
+}
+
+func TestBodyKeepsBlockOrderForTablePlacement(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+			<w:p><w:r><w:t>Титульный лист</w:t><w:br w:type="page"/></w:r></w:p>
+			<w:p><w:r><w:t>Таблица 1 – Исходные данные</w:t></w:r></w:p>
+			<w:tbl><w:tr><w:tc><w:p/></w:tc></w:tr></w:tbl>
+			<w:p><w:r><w:t>Текст после таблицы</w:t></w:r></w:p>
+			<w:sectPr/>
+		</w:body></w:document>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Tables) != 1 {
+		t.Fatalf("expected one table, got %d", len(doc.Tables))
+	}
+	tbl := doc.Tables[0]
+	if !tbl.HasCaption || !tbl.CaptionAbove || tbl.ParagraphIndex != 2 || tbl.PageNumber != 2 {
+		t.Fatalf("unexpected table placement: %+v", tbl)
+	}
+
+	vs, _ := checkTables(doc.Tables, doc.Paragraphs, TableConfig{Alignment: "center"}, 3)
+	if len(vs) != 0 {
+		t.Fatalf("table before start page should be out of scope, got %+v", vs)
+	}
+}
+
+func TestOdtParserMapsContentAndStyles(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"mimetype": "application/vnd.oasis.opendocument.text",
+		"styles.xml": `<office:document-styles xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+				xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+				xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"
+				xmlns:svg="urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0"
+				xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+			<office:font-face-decls><style:font-face style:name="Times New Roman" svg:font-family="'Times New Roman'"/></office:font-face-decls>
+			<office:styles>
+				<style:default-style style:family="paragraph"><style:text-properties fo:font-size="12pt"/></style:default-style>
+				<style:style style:name="Standard" style:family="paragraph">
+					<style:paragraph-properties fo:text-align="justify" fo:text-indent="1.25cm" fo:line-height="150%"/>
+					<style:text-properties style:font-name="Times New Roman" fo:font-size="14pt"/>
+				</style:style>
+				<style:style style:name="Heading_20_1" style:family="paragraph" style:parent-style-name="Standard">
+					<style:paragraph-properties fo:text-align="center" fo:text-indent="0cm"/>
+					<style:text-properties fo:font-weight="bold"/>
+				</style:style>
+				<style:style style:name="Footer" style:family="paragraph" style:parent-style-name="Standard">
+					<style:paragraph-properties fo:text-align="center" fo:text-indent="0cm"/>
+				</style:style>
+			</office:styles>
+			<office:automatic-styles>
+				<style:page-layout style:name="pm1">
+					<style:page-layout-properties fo:page-width="21cm" fo:page-height="29.7cm" style:print-orientation="portrait"
+						fo:margin-top="2cm" fo:margin-bottom="2cm" fo:margin-left="3cm" fo:margin-right="1cm"/>
+				</style:page-layout>
+			</office:automatic-styles>
+			<office:master-styles><style:master-page style:name="Standard" style:page-layout-name="pm1">
+				<style:footer><text:p text:style-name="Footer"><text:page-number text:select-page="current">1</text:page-number></text:p></style:footer>
+			</style:master-page></office:master-styles>
+		</office:document-styles>`,
+		"content.xml": `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+				xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+				xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+				xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+				xmlns:draw="urn:oasis:names:tc:opendocument:xmlns:drawing:1.0"
+				xmlns:svg="urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0"
+				xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0">
+			<office:automatic-styles>
+				<style:style style:name="P1" style:family="paragraph" style:parent-style-name="Standard">
+					<style:paragraph-properties fo:break-before="page"/>
+				</style:style>
+				<style:style style:name="T1" style:family="text"><style:text-properties fo:font-weight="bold"/></style:style>
+				<style:style style:name="Tbl1" style:family="table"><style:table-properties table:align="center"/></style:style>
+			</office:automatic-styles>
+			<office:body><office:text>
+				<text:h text:style-name="Heading_20_1" text:outline-level="1">Введение</text:h>
+				<text:p text:style-name="P1">Обычный<text:s/>текст и <text:span text:style-name="T1">жирный</text:span></text:p>
+				<text:p text:style-name="Standard">Таблица 1 – Исходные данные</text:p>
+				<table:table table:style-name="Tbl1">
+					<table:table-column table:number-columns-repeated="2"/>
+					<table:table-header-rows><table:table-row><table:table-cell><text:p>A</text:p></table:table-cell><table:table-cell><text:p>B</text:p></table:table-cell></table:table-row></table:table-header-rows>
+					<table:table-row><table:table-cell><text:p>1</text:p></table:table-cell><table:table-cell><text:p>2</text:p></table:table-cell></table:table-row>
+				</table:table>
+				<text:list><text:list-item><text:p text:style-name="Standard">пункт списка</text:p></text:list-item></text:list>
+				<text:p text:style-name="Standard"><draw:frame draw:name="Схема"><draw:image/><svg:desc>Схема установки</svg:desc></draw:frame></text:p>
+			</office:text></office:body>
+		</office:document-content>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(doc.Margins.LeftMm-30) > 0.1 || math.Abs(doc.Margins.RightMm-10) > 0.1 || math.Abs(doc.PageSize.WidthMm-210) > 0.1 {
+		t.Fatalf("unexpected page setup: %+v %+v", doc.Margins, doc.PageSize)
+	}
+	if len(doc.Paragraphs) != 5 {
+		t.Fatalf("expected 5 body paragraphs, got %d", len(doc.Paragraphs))
+	}
+
+	heading, body := doc.Paragraphs[0], doc.Paragraphs[1]
+	if heading.StyleID != "Heading1" || !heading.IsBold || heading.Alignment != "center" || heading.FontSizePt != 14 {
+		t.Fatalf("unexpected heading: %+v", heading)
+	}
+	if body.Text != "Обычный текст и жирный" || body.FontName != "Times New Roman" || body.FontSizePt != 14 ||
+		body.LineSpacing != 1.5 || body.Alignment != "both" || math.Abs(body.FirstLineIndentMm-12.5) > 0.1 ||
+		!body.StartsPageBreak || body.IsBold || body.BoldRatio == 0 {
+		t.Fatalf("unexpected body paragraph: %+v", body)
+	}
+	if !doc.Paragraphs[3].IsListItem {
+		t.Fatalf("list item not detected: %+v", doc.Paragraphs[3])
+	}
+
+	if len(doc.Tables) != 1 {
+		t.Fatalf("expected one table, got %d", len(doc.Tables))
+	}
+	tbl := doc.Tables[0]
+	if !tbl.HasCaption || !tbl.CaptionAbove || !tbl.HasHeaderRow || tbl.Alignment != "center" || tbl.RowCount != 2 || tbl.ColCount != 2 {
+		t.Fatalf("unexpected table: %+v", tbl)
+	}
+	if len(doc.Images) != 1 || doc.Images[0].AltText != "Схема установки" {
+		t.Fatalf("unexpected images: %+v", doc.Images)
+	}
+	if len(doc.Footers) != 1 || !doc.Footers[0].HasPageNumber || doc.Footers[0].Text != "" || doc.Footers[0].PageNumberAlignment != "center" {
+		t.Fatalf("unexpected footers: %+v", doc.Footers)
+	}
+}
+
+func TestHeadersFootersParsedAndChecked(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+			<w:p><w:pPr><w:sectPr>
+				<w:headerReference w:type="default" r:id="rId1"/>
+				<w:footerReference w:type="default" r:id="rId2"/>
+				<w:footerReference w:type="first" r:id="rId3"/>
+				<w:titlePg/>
+			</w:sectPr></w:pPr><w:r><w:t>Титульный лист</w:t></w:r></w:p>
+			<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>1 Введение</w:t></w:r></w:p>
+			<w:p><w:r><w:t>Текст введения</w:t></w:r></w:p>
+			<w:sectPr><w:headerReference w:type="default" r:id="rId4"/></w:sectPr>
+		</w:body></w:document>`,
+		"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId1" Type="header" Target="header1.xml"/>
+			<Relationship Id="rId2" Type="footer" Target="footer1.xml"/>
+			<Relationship Id="rId3" Type="footer" Target="/word/footer2.xml"/>
+			<Relationship Id="rId4" Type="header" Target="header2.xml"/>
+		</Relationships>`,
+		"word/header1.xml": `<w:hdr ` + ns + `><w:p><w:r><w:t>Введение</w:t></w:r></w:p></w:hdr>`,
+		"word/header2.xml": `<w:hdr ` + ns + `><w:p><w:r><w:t>Заключение</w:t></w:r></w:p></w:hdr>`,
+		"word/footer1.xml": `<w:ftr ` + ns + `><w:sdt><w:sdtContent><w:p><w:pPr><w:jc w:val="center"/></w:pPr>
+			<w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText> PAGE </w:instrText></w:r>
+			<w:r><w:fldChar w:fldCharType="separate"/></w:r><w:r><w:t>2</w:t></w:r><w:r><w:fldChar w:fldCharType="end"/></w:r>
+		</w:p></w:sdtContent></w:sdt></w:ftr>`,
+		"word/footer2.xml": `<w:ftr ` + ns + `><w:p/></w:ftr>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !doc.TitlePage || len(doc.Headers) != 2 || len(doc.Footers) != 3 {
+		t.Fatalf("unexpected headers/footers: title=%v %+v %+v", doc.TitlePage, doc.Headers, doc.Footers)
+	}
+	footer := doc.Footers[0]
+	if footer.Type != "default" || !footer.HasPageNumber || footer.Text != "" || footer.PageNumberAlignment != "center" {
+		t.Fatalf("unexpected page number footer: %+v", footer)
+	}
+	if h := doc.Headers[1]; h.Section != 1 || h.Text != "Заключение" || h.ParagraphStart != 1 || h.ParagraphEnd != 3 {
+		t.Fatalf("unexpected second section header: %+v", h)
+	}
+
+	config := HeaderFooterConfig{RequirePageNumber: true, PageNumberAlignment: "center", TitlePageEmpty: true, RunningTitle: true}
+	vs, _ := checkHeadersFooters(doc, config)
+	if len(vs) != 1 || vs[0].RuleType != "header_running_title" || vs[0].ExpectedValue != "1 Введение" {
+		t.Fatalf("expected one running title violation, got %+v", vs)
+	}
+
+	// Without w:titlePg the title page shows the default header and page number.
+	doc.TitlePage = false
+	vs, _ = checkHeadersFooters(doc, HeaderFooterConfig{TitlePageEmpty: true})
+	if len(vs) != 2 || vs[0].RuleType != "header_title_page" {
+		t.Fatalf("expected title page violations, got %+v", vs)
+	}
+}
+
+func TestImageCaptionInOwnParagraphAndFollowingParagraph(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+			<w:p><w:r><w:drawing/></w:r><w:r><w:br/><w:t>Рисунок 1 – Схема установки</w:t></w:r></w:p>
+			<w:p><w:r><w:drawing/></w:r></w:p>
+			<w:p/>
+			<w:p><w:r><w:t>Текст после рисунка</w:t></w:r></w:p>
+			<w:sectPr/>
+		</w:body></w:document>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Images) != 2 {
+		t.Fatalf("expected two images, got %d", len(doc.Images))
+	}
+	first, second := doc.Images[0], doc.Images[1]
+	if !first.HasCaption || !first.CaptionBelow || first.CaptionParagraphIndex != 0 || first.CaptionNumber != "1" {
+		t.Fatalf("caption in the image paragraph not detected: %+v", first)
+	}
+	if second.HasCaption || second.CaptionParagraphIndex != -1 || second.NextParagraphIndex != 3 {
+		t.Fatalf("unexpected second image: %+v", second)
+	}
+
+	vs, _ := checkImages(doc.Images, doc.Paragraphs, ImageConfig{RequireCaption: true}, 0)
+	if len(vs) != 1 || vs[0].RuleType != "image_caption_missing" || vs[0].ActualValue != "После рисунка: Текст после рисунка" {
+		t.Fatalf("expected one missing caption violation, got %+v", vs)
+	}
+}
+
+func TestUnreferencedTablesAllowListsAndRanges(t *testing.T) {
+	paragraphs := []ParsedParagraph{
+		{Text: "Исходные данные приведены в таблицах 1–3.", Role: "body", PageNumber: 1},
+		{Text: "Таблица 1 – Исходные данные", Role: "table_caption", PageNumber: 1},
+		{Text: "Таблица 2 – Параметры", Role: "table_caption", PageNumber: 1},
+		{Text: "Таблица 3 – Режимы", Role: "table_caption", PageNumber: 2},
+		{Text: "Таблица 4 – Итоги", Role: "table_caption", PageNumber: 2},
+		{Text: "Сравнение см. в таблицах 5 и 6.", Role: "body", PageNumber: 3},
+		{Text: "Таблица 5 – Сравнение", Role: "table_caption", PageNumber: 3},
+		{Text: "Таблица 6 – Выводы", Role: "table_caption", PageNumber: 3},
+	}
+
+	items := captionNumbersFromParagraphs(paragraphs, "table_caption", tableCaptionNumberRe)
+	violations, rules := checkUnreferencedObjects("table", items, paragraphs, tableRefRegex)
+
+	if rules != 6 {
+		t.Fatalf("expected 6 checked tables, got %d", rules)
+	}
+	if len(violations) != 1 || violations[0].RuleType != "table_not_referenced" {
+		t.Fatalf("expected one table_not_referenced violation, got %+v", violations)
+	}
+	if !strings.Contains(violations[0].Description, "таблицу 4") {
+		t.Fatalf("expected table 4 to be unreferenced, got %q", violations[0].Description)
+	}
+}
+
+func TestFormulaReferencesAreCheckedInBothDirections(t *testing.T) {
+	paragraphs := []ParsedParagraph{
+		{ID: "p1", Text: "Скорость вычисляется по формуле (1).", Role: "body", PageNumber: 1},
+		{ID: "p2", Text: "\t(1)", Role: "formula", PageNumber: 1},
+		{ID: "p3", Text: "\t(2)", Role: "formula", PageNumber: 1},
+		{ID: "p4", Text: "Подставив значения в формулу (3), получим результат.", Role: "body", PageNumber: 1},
+	}
+	formulas := []ParsedFormula{
+		{ID: "f1", WrapperID: "p2", HasNumbering: true, Number: formulaNumber(paragraphs[1].Text)},
+		{ID: "f2", WrapperID: "p3", HasNumbering: true, Number: formulaNumber(paragraphs[2].Text)},
+	}
+
+	violations, _ := checkFormulas(formulas, paragraphs, FormulaConfig{CheckTextReferences: true})
+
+	found := map[string]string{}
+	for _, v := range violations {
+		found[v.RuleType] = v.Description
+	}
+	if !strings.Contains(found["formula_not_referenced"], "формулу 2") {
+		t.Fatalf("expected formula 2 to be reported as unreferenced, got %+v", violations)
+	}
+	if _, ok := found["formula_text_reference_missing"]; !ok {
+		t.Fatalf("expected the reference to formula (3) to be reported, got %+v", violations)
+	}
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %d: %+v", len(violations), violations)
+	}
+}
+
+func TestReferenceEntriesFollowGOST70100(t *testing.T) {
+	paragraphs := []ParsedParagraph{
+		{Text: "Список литературы", Role: "references_heading", PageNumber: 10},
+		{Text: "1. Иванов, И. И. Основы программирования : учебник / И. И. Иванов. – Москва : Юрайт, 2021. – 320 с. – Текст : непосредственный.", Role: "body", PageNumber: 10},
+		{Text: "2. Петров П.П. Базы данных. – Санкт-Петербург : Питер, 2020. – 288 с. – Текст : непосредственный.", Role: "body", PageNumber: 10},
+		{Text: "4. Сидоров, С. С. Сети ЭВМ / С. С. Сидоров // Вестник МГТУ. – 2022. – № 3. – С. 15–20. - Текст: непосредственный.", Role: "body", PageNumber: 10},
+		{Text: "Python documentation. – URL: https://docs.python.org/3/ (дата обращения: 01.09.2024). – Текст : электронный.", Role: "body", PageNumber: 11},
+		{Text: "ГОСТ 7.32-2017. Отчёт о научно-исследовательской работе. – https://docs.cntd.ru/document/1200157208", Role: "body", PageNumber: 11},
+	}
+
+	violations, _ := checkReferenceEntries(paragraphs, ReferencesConfig{})
+
+	got := map[string][]int{}
+	for _, v := range violations {
+		got[v.RuleType] = append(got[v.RuleType], *v.Location.ParagraphIndex)
+		if v.Suggestion == "" {
+			t.Errorf("%s violation has no suggestion", v.RuleType)
+		}
+	}
+	want := map[string][]int{
+		"reference_author_format":     {2},
+		"reference_numbering":         {3, 4, 5},
+		"reference_title_punctuation": {3, 5},
+		"reference_url_access_date":   {5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected violations by paragraph: got %v, want %v", got, want)
+	}
+}
+
+func TestCitationsMatchBibliography(t *testing.T) {
+	paragraphs := []ParsedParagraph{
+		{Text: "Методы описаны в работах [1, с. 34; 3] и [5].", Role: "body", PageNumber: 2},
+		{Text: "Функция определена на отрезке [0, 1].", Role: "body", PageNumber: 2},
+		{Text: "Список литературы", Role: "references_heading", PageNumber: 10},
+		{Text: "Иванов, И. И. Основы программирования.", Role: "list", IsListItem: true, PageNumber: 10},
+		{Text: "Петров, П. П. Базы данных.", Role: "list", IsListItem: true, PageNumber: 10},
+		{Text: "Сидоров, С. С. Сети ЭВМ.", Role: "list", IsListItem: true, PageNumber: 10},
+	}
+
+	violations, rules := checkCitations(paragraphs, ReferencesConfig{})
+
+	if rules != 6 {
+		t.Fatalf("expected 3 citations and 3 entries to be checked, got %d", rules)
+	}
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %d: %+v", len(violations), violations)
+	}
+	if violations[0].RuleType != "reference_citation_undefined" || !strings.Contains(violations[0].Description, "5") {
+		t.Fatalf("expected citation [5] to be reported as undefined, got %+v", violations[0])
+	}
+	if violations[1].RuleType != "reference_not_cited" || *violations[1].Location.ParagraphIndex != 4 {
+		t.Fatalf("expected entry 2 to be reported as uncited, got %+v", violations[1])
+	}
+}
+
+func TestTypographyRulesPointAtOffendingCharacters(t *testing.T) {
+	text := `Метод "быстрой" сортировки - это  алгоритм , описанный И. И. Ивановым; файл весит 12 МБ.`
+	cfg := TypographyRulesConfig{CheckDashes: true, CheckQuotes: true, CheckDoubleSpaces: true, CheckSpaceBeforePunctuation: true, CheckNonBreakingSpaces: true}
+
+	violations, rules := checkTypographyRules(text, cfg, "Стр. 1", &models.Location{Page: 1})
+
+	if rules != 7 {
+		t.Fatalf("expected 7 rules to be checked, got %d", rules)
+	}
+	type match struct {
+		rule       string
+		start, end int
+	}
+	var got []match
+	for _, v := range violations {
+		got = append(got, match{v.RuleType, *v.Location.CharStart, *v.Location.CharEnd})
+		if v.ContextText == "" {
+			t.Errorf("%s violation has no context", v.RuleType)
+		}
+	}
+	want := []match{
+		{"typography_dash", 26, 29},
+		{"typography_quotes", 6, 15},
+		{"typography_double_space", 32, 34},
+		{"typography_space_before_punctuation", 42, 43},
+		{"typography_nbsp", 84, 85},
+		{"typography_nbsp", 57, 58},
+		{"typography_nbsp", 60, 61},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected matches: got %v, want %v", got, want)
+	}
+}
+
+func TestNumbersAndUnitsFollowGOST8417(t *testing.T) {
+	text := "Длина 10мм, масса 2.5 кг, время 3 сек, диапазон 10-20 В по ГОСТ 7.32-2017 и ГОСТ 19-78, дата 2020-01-01, формат А4 мм, размер 1.2."
+	cfg := UnitsConfig{CheckUnitSpacing: true, CheckRanges: true, CheckDecimalSeparator: true, CheckUnitNames: true}
+
+	violations, rules := checkNumbersAndUnits(text, cfg, unitDictionary(""), "Стр. 1", &models.Location{Page: 1})
+
+	if rules != 4 {
+		t.Fatalf("expected 4 rules to be checked, got %d", rules)
+	}
+	var got []string
+	for _, v := range violations {
+		got = append(got, v.RuleType+" "+v.ActualValue+" -> "+v.ExpectedValue)
+	}
+	want := []string{
+		"unit_spacing 10мм -> 10 мм",
+		"decimal_separator 2.5 -> 2,5",
+		"unit_name сек -> с",
+		"number_range_dash 10-20 -> 10–20",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected violations:\n got %q\nwant %q", got, want)
+	}
+
+	// A standard's own list replaces the ГОСТ 8.417 designations.
+	violations, _ = checkNumbersAndUnits("Объём 5 ГБ и 3 Гбайт", UnitsConfig{CheckUnitNames: true}, unitDictionary("ГБ, МБ"), "Стр. 1", nil)
+	if len(violations) != 1 || violations[0].ActualValue != "Гбайт" {
+		t.Fatalf("expected only «Гбайт» to be reported, got %+v", violations)
+	}
+}
+
+func TestListsUseMarkersFromNumbering(t *testing.T) {
+	lists := newNumbering(NumberingDoc{
+		AbstractNums: []AbstractNum{
+			{ID: "0", Levels: []NumLevel{
+				{Ilvl: "0", NumFmt: &Val{Val: "bullet"}, LvlText: &Val{Val: ""}, PPr: &PPr{Ind: &Ind{Left: "1077", Hanging: "360"}}},
+				{Ilvl: "1", NumFmt: &Val{Val: "russianLower"}, LvlText: &Val{Val: "%2)"}},
+			}},
+		},
+		Nums: []Num{{ID: "5", AbstractNumID: &Val{Val: "0"}}},
+	})
+	bullet, ok := lists.level("5", 0)
+	if !ok || bullet.Marker != "•" {
+		t.Fatalf("expected the Symbol bullet to become «•», got %+v", bullet)
+	}
+	if nested, _ := lists.level("5", 1); nested.Marker != "а)" {
+		t.Fatalf("expected «а)» for the nested level, got %q", nested.Marker)
+	}
+	indent := markerPositionMm(bullet.Ind, nil)
+
+	item := func(text string, level int, format, marker string) ParsedParagraph {
+		return ParsedParagraph{Text: text, PageNumber: 3, IsListItem: true, ListLevel: level,
+			ListFormat: format, ListMarker: marker, ListIndentMm: indent}
+	}
+	paragraphs := []ParsedParagraph{
+		{Text: "Система выполняет:", PageNumber: 3},
+		item("Сбор данных;", 0, "bullet", "•"),
+		item("обработку SQL-запросов:", 0, "bullet", "•"),
+		item("выборку;", 1, "russianLower", "а)"),
+		item("сортировку", 1, "russianLower", "а)"),
+	}
+	cfg := ListsConfig{Enabled: true, MarkerType: "dash", ItemPunctuation: ";", ItemCase: "lower", IndentMm: 12.5, MaxDepth: 1, IndentTolerance: 2}
+
+	violations, _ := checkLists(paragraphs, cfg, ReferencesConfig{}, 0)
+
+	var got []string
+	for _, v := range violations {
+		got = append(got, fmt.Sprintf("%s %d", v.RuleType, *v.Location.ParagraphIndex))
+	}
+	want := []string{
+		"list_marker 1",
+		"list_case 1",
+		"list_depth 3",
+		"list_punctuation 4",
+		"list_depth 4",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected violations:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestListNumberingFromStylesAndOverrides(t *testing.T) {
+	lists := newNumbering(NumberingDoc{
+		AbstractNums: []AbstractNum{
+			{ID: "0", Levels: []NumLevel{{Ilvl: "0", NumFmt: &Val{Val: "decimal"}, LvlText: &Val{Val: "%1."}}}},
+		},
+		Nums: []Num{
+			{ID: "1", AbstractNumID: &Val{Val: "0"}},
+			{ID: "2", AbstractNumID: &Val{Val: "0"}, LvlOverrides: []LvlOverride{
+				{Ilvl: "0", Lvl: &NumLevel{Ilvl: "0", NumFmt: &Val{Val: "bullet"}, LvlText: &Val{Val: "*"}}},
+			}},
+		},
+	})
+	listStyle := resolvedStyle{NumID: "1"}
+
+	var styled, removed, overridden ParsedParagraph
+	lists.apply(&styled, nil, listStyle)
+	lists.apply(&removed, &PPr{NumPr: &NumPr{NumId: &Val{Val: "0"}}}, listStyle)
+	lists.apply(&overridden, &PPr{NumPr: &NumPr{NumId: &Val{Val: "2"}}}, resolvedStyle{})
+
+	if !styled.IsListItem || styled.ListMarker != "1." {
+		t.Fatalf("expected the list style to number the paragraph with «1.», got %+v", styled)
+	}
+	if removed.IsListItem {
+		t.Fatal("numId 0 should remove the numbering of the style")
+	}
+	if listMarkerType(overridden) != "bullet" || overridden.ListMarker != "*" {
+		t.Fatalf("expected the lvlOverride to make an «*» bullet, got %+v", overridden)
+	}
+
+	styled.Text, styled.PageNumber = "первый пункт;", 2
+	violations, _ := checkLists([]ParsedParagraph{styled}, ListsConfig{NumberFormat: "1)"}, ReferencesConfig{}, 0)
+	if len(violations) != 1 || violations[0].RuleType != "list_number_format" || violations[0].ActualValue != "«1.»" {
+		t.Fatalf("expected «1.» to be reported against «1)», got %+v", violations)
+	}
+}
+
+func TestFootnotesParsedAndChecked(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+			<w:p><w:r><w:t>Первое утверждение</w:t></w:r><w:r><w:footnoteReference w:id="1"/></w:r></w:p>
+			<w:p><w:r><w:t>Второе</w:t></w:r><w:r><w:footnoteReference w:id="2"/></w:r>
+				<w:r><w:t>и третье</w:t></w:r><w:r><w:footnoteReference w:customMarkFollows="1" w:id="3"/><w:t>*</w:t></w:r></w:p>
+			<w:p><w:r><w:t>Вывод</w:t></w:r><w:r><w:endnoteReference w:id="1"/></w:r></w:p>
+			<w:sectPr><w:footnotePr><w:numRestart w:val="eachPage"/></w:footnotePr></w:sectPr>
+		</w:body></w:document>`,
+		"word/styles.xml": `<w:styles ` + ns + `>
+			<w:style w:type="paragraph" w:styleId="FootnoteText"><w:rPr><w:sz w:val="20"/></w:rPr></w:style>
+		</w:styles>`,
+		"word/footnotes.xml": `<w:footnotes ` + ns + `>
+			<w:footnote w:type="separator" w:id="-1"><w:p><w:r><w:separator/></w:r></w:p></w:footnote>
+			<w:footnote w:id="1"><w:p><w:pPr><w:pStyle w:val="FootnoteText"/></w:pPr><w:r><w:footnoteRef/></w:r><w:r><w:t>Источник первый.</w:t></w:r></w:p></w:footnote>
+			<w:footnote w:id="2"><w:p><w:pPr><w:pStyle w:val="FootnoteText"/></w:pPr><w:r><w:rPr><w:sz w:val="28"/></w:rPr><w:t>Источник второй.</w:t></w:r></w:p></w:footnote>
+			<w:footnote w:id="3"><w:p><w:pPr><w:pStyle w:val="FootnoteText"/></w:pPr><w:r><w:t>Примечание.</w:t></w:r></w:p></w:footnote>
+		</w:footnotes>`,
+		"word/endnotes.xml": `<w:endnotes ` + ns + `>
+			<w:endnote w:id="1"><w:p><w:r><w:t>Концевая сноска.</w:t></w:r></w:p></w:endnote>
+		</w:endnotes>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Footnotes) != 3 || len(doc.Endnotes) != 1 || doc.FootnoteRestart != footnotesEachPage {
+		t.Fatalf("unexpected notes: %+v %+v restart=%q", doc.Footnotes, doc.Endnotes, doc.FootnoteRestart)
+	}
+	if n := doc.Footnotes[0]; n.Text != "Источник первый." || n.FontSizePt != 10 || n.ParagraphIndex != 0 {
+		t.Fatalf("unexpected first footnote: %+v", n)
+	}
+	if n := doc.Footnotes[2]; !n.CustomMark || n.ParagraphIndex != 1 {
+		t.Fatalf("expected a custom mark footnote in the second paragraph, got %+v", n)
+	}
+
+	config := FootnotesConfig{FontSizePt: 10, Numbering: footnotesContinuous, ForbidEndnotes: true, MaxPerPage: 2}
+	vs, _ := checkFootnotes(doc, config, 0)
+	got := map[string]int{}
+	for _, v := range vs {
+		got[v.RuleType]++
+	}
+	want := map[string]int{"footnote_font_size": 1, "footnote_numbering": 2, "endnotes": 1, "footnotes_per_page": 1}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v: %+v", want, got, vs)
+	}
+}
+
+func TestIntegrityFlagsPageInflation(t *testing.T) {
+	var pic bytes.Buffer
+	if err := png.Encode(&pic, image.NewGray(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	body := strings.Repeat(`<w:p><w:r><w:t>Обычный абзац основного текста работы, набранный как положено.</w:t></w:r></w:p>`, 5)
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+			<w:p><w:r><w:t>Титульный лист</w:t></w:r></w:p>
+			<w:p><w:r><w:br w:type="page"/><w:t>Введение</w:t></w:r></w:p>` + body + `
+			<w:p><w:pPr><w:spacing w:after="1440"/></w:pPr><w:r><w:t>Абзац с огромным интервалом после.</w:t></w:r></w:p>
+			<w:p><w:r><w:rPr><w:sz w:val="40"/></w:rPr><w:t>Этот абзац набран кеглем двадцать, чтобы занять побольше места.</w:t></w:r></w:p>
+			<w:p><w:r><w:t>Видимый текст</w:t></w:r><w:r><w:rPr><w:color w:val="FFFFFF"/></w:rPr><w:t>невидимый белый текст для объёма работы</w:t></w:r></w:p>
+			<w:p/><w:p/><w:p/><w:p/><w:p/>
+			<w:p><w:r><w:drawing><wp:inline><wp:extent cx="5400000" cy="1800000"/><wp:docPr id="1" name="pic"/>
+				<a:graphic><a:graphicData><pic:pic><pic:blipFill><a:blip r:embed="rId9"/></pic:blipFill></pic:pic></a:graphicData></a:graphic>
+			</wp:inline></w:drawing></w:r></w:p>
+			<w:p><w:pPr><w:sectPr><w:pgMar w:top="1134" w:bottom="1134" w:left="4000" w:right="567"/></w:sectPr></w:pPr><w:r><w:t>Конец раздела с широкими полями</w:t></w:r></w:p>` + body + body + body + body + `
+			<w:sectPr><w:pgMar w:top="1134" w:bottom="1134" w:left="1701" w:right="567"/></w:sectPr>
+		</w:body></w:document>`,
+		"word/styles.xml": `<w:styles ` + ns + `><w:docDefaults><w:rPrDefault><w:rPr><w:sz w:val="28"/></w:rPr></w:rPrDefault></w:docDefaults></w:styles>`,
+		"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId9" Type="image" Target="media/image1.png"/>
+		</Relationships>`,
+		"word/media/image1.png": pic.String(),
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Images) != 1 || doc.Images[0].PixelWidth != 40 || math.Abs(doc.Images[0].WidthMm-150) > 0.1 {
+		t.Fatalf("unexpected image: %+v", doc.Images)
+	}
+
+	vs := checkIntegrity(doc, 0)
+	got := map[string]int{}
+	for _, v := range vs {
+		if v.Severity != models.SeverityIntegrity {
+			t.Fatalf("expected integrity severity, got %+v", v)
+		}
+		got[v.RuleType]++
+	}
+	want := map[string]int{
+		"integrity_spacing": 1, "integrity_font_size": 1, "integrity_invisible_text": 1,
+		"integrity_empty_paragraphs": 1, "integrity_image_scale": 1, "integrity_section_margins": 1,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v: %+v", want, got, vs)
+	}
+	if violationPenalty(vs[0]) != 0 {
+		t.Fatal("integrity findings must not affect the score")
+	}
+}
+
+func TestSectionsCheckedSeparately(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	const portrait = `<w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1134" w:bottom="1134" w:left="1701" w:right="567"/>`
+	text := strings.Repeat(`<w:p><w:r><w:t>Текст основной части работы.</w:t></w:r></w:p>`, 4)
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+			<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>1 Основная часть</w:t></w:r></w:p>` + text + `
+			<w:p><w:pPr><w:sectPr>` + portrait + `</w:sectPr></w:pPr></w:p>
+			<w:p><w:pPr><w:sectPr><w:pgSz w:w="16838" w:h="11906" w:orient="landscape"/>
+				<w:pgMar w:top="1701" w:right="1134" w:bottom="567" w:left="1134"/></w:sectPr></w:pPr><w:r><w:t>Таблица 1 – Широкая таблица</w:t></w:r></w:p>
+			<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>ПРИЛОЖЕНИЕ А</w:t></w:r></w:p>
+			<w:p><w:pPr><w:sectPr>` + portrait + `</w:sectPr></w:pPr><w:r><w:t>Схема на следующей странице</w:t></w:r></w:p>
+			<w:p><w:r><w:t>Рисунок А.1 – Схема</w:t></w:r></w:p>
+			<w:sectPr><w:pgSz w:w="16838" w:h="11906" w:orient="landscape"/>
+				<w:pgMar w:top="567" w:right="1134" w:bottom="1701" w:left="1134"/></w:sectPr>
+		</w:body></w:document>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Sections) != 4 || doc.Sections[3].ParagraphStart != 9 || doc.Sections[3].Orientation != "landscape" {
+		t.Fatalf("unexpected sections: %+v", doc.Sections)
+	}
+	if doc.PageSize.Orientation != "portrait" || math.Abs(doc.Margins.LeftMm-30) > 0.1 {
+		t.Fatalf("page setup must come from the main section, got %+v %+v", doc.PageSize, doc.Margins)
+	}
+
+	margins := MarginsConfig{Top: 20, Bottom: 20, Left: 30, Right: 10}
+	vs, rules := checkSectionPages(doc, margins, PageSetupConfig{Orientation: "portrait", OtherOrientation: "appendices"})
+	if len(vs) != 1 || vs[0].RuleType != "section_orientation" || vs[0].Location.Page == 0 || *vs[0].Location.ParagraphIndex != 6 {
+		t.Fatalf("expected the landscape table outside appendices, got %+v", vs)
+	}
+	if rules != 3*4+2 {
+		t.Fatalf("expected 14 rules, got %d", rules)
+	}
+
+	vs, _ = checkSectionPages(doc, margins, PageSetupConfig{OtherOrientation: "forbidden"})
+	if len(vs) != 2 {
+		t.Fatalf("expected both landscape sections, got %+v", vs)
+	}
+
+	doc.Sections[3].Margins.LeftMm = 35
+	vs, _ = checkSectionPages(doc, margins, PageSetupConfig{})
+	if len(vs) != 1 || vs[0].RuleType != "margin_left" || *vs[0].Location.ParagraphIndex != 9 {
+		t.Fatalf("expected the appendix margin, got %+v", vs)
+	}
+}
+
+func TestPagesEstimatedWithoutRenderedBreaks(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	// 1000 characters of 14 pt Times New Roman at 1.5 spacing take about 14
+	// lines; an A4 page with ГОСТ margins holds about 30.
+	para := `<w:p><w:pPr><w:spacing w:line="360" w:lineRule="auto"/><w:ind w:firstLine="709"/></w:pPr><w:r><w:t>` +
+		strings.Repeat("Текст абзаца. ", 72) + `</w:t></w:r></w:p>`
+	body := `<w:p><w:r><w:t>Титульный лист</w:t></w:r><w:r><w:br w:type="page"/></w:r></w:p>` + strings.Repeat(para, 40) +
+		`<w:p><w:pPr><w:pageBreakBefore/></w:pPr><w:r><w:t>Заключение</w:t></w:r></w:p>`
+	styles := `<w:styles ` + ns + `><w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Times New Roman"/><w:sz w:val="28"/></w:rPr></w:rPrDefault></w:docDefaults></w:styles>`
+	sect := `<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1134" w:bottom="1134" w:left="1701" w:right="567"/></w:sectPr>`
+
+	doc, err := (&DocParser{}).parseZip(buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>` + body + sect + `</w:body></w:document>`,
+		"word/styles.xml":   styles,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !doc.Stats.PagesEstimated {
+		t.Fatal("expected estimated pages")
+	}
+	if doc.Stats.TotalPages < 18 || doc.Stats.TotalPages > 23 {
+		t.Fatalf("expected about 21 pages, got %d", doc.Stats.TotalPages)
+	}
+	if doc.Paragraphs[1].PageNumber != 2 {
+		t.Fatalf("the page break must end the title page, got page %d", doc.Paragraphs[1].PageNumber)
+	}
+	for i := 1; i < len(doc.Paragraphs); i++ {
+		if doc.Paragraphs[i].PageNumber < doc.Paragraphs[i-1].PageNumber {
+			t.Fatalf("page numbers go back at paragraph %d", i)
+		}
+	}
+	last := doc.Paragraphs[len(doc.Paragraphs)-1]
+	if last.PageNumber != doc.Stats.TotalPages || last.PageNumber == doc.Paragraphs[len(doc.Paragraphs)-2].PageNumber {
+		t.Fatalf("page break before must start a new page: %d of %d", last.PageNumber, doc.Stats.TotalPages)
+	}
+
+	// Breaks rendered by Word are trusted as they are
+	doc, err = (&DocParser{}).parseZip(buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>` + para + `<w:p><w:r><w:lastRenderedPageBreak/><w:t>Дальше</w:t></w:r></w:p>` + para + sect + `</w:body></w:document>`,
+		"word/styles.xml":   styles,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Stats.PagesEstimated || doc.Stats.TotalPages != 2 {
+		t.Fatalf("expected rendered breaks to be used, got %+v", doc.Stats)
+	}
+}
+
+func TestStructuralFingerprintComparesStructure(t *testing.T) {
+	build := func(headings []string, lengths ...int) *ParsedDoc {
+		doc := &ParsedDoc{Tables: make([]ParsedTable, 2), Images: make([]ParsedImage, 3)}
+		doc.Paragraphs = append(doc.Paragraphs, ParsedParagraph{Text: "Содержание", Role: "heading", StyleID: "Heading1"},
+			ParsedParagraph{Text: "1 Введение 3", Role: "toc"})
+		for k, h := range headings {
+			style := "Heading2"
+			if k%3 == 0 {
+				style = "Heading1"
+			}
+			doc.Paragraphs = append(doc.Paragraphs, ParsedParagraph{Text: h, Role: "heading", StyleID: style})
+			for _, n := range lengths {
+				doc.Paragraphs = append(doc.Paragraphs, ParsedParagraph{Text: strings.Repeat("а", n), Role: "body"})
+			}
+		}
+		return doc
+	}
+	headings := []string{"1 Введение", "1.1 Анализ предметной области", "1.2 Бизнес-процессы", "2 Проектирование", "2.1 Схема данных", "2.2 Интерфейс", "Заключение"}
+
+	a := StructuralFingerprint(build(headings, 120, 300, 650))
+	if len(a.Headings) != 8 || len(a.Sections) != 4 || a.Sections[1] != "введение" || a.Paragraphs != 21 || a.Figures != 3 {
+		t.Fatalf("unexpected fingerprint: %+v", a)
+	}
+	if a.ParagraphLengths[2] != 7 || a.ParagraphLengths[3] != 7 || a.ParagraphLengths[4] != 7 {
+		t.Fatalf("unexpected length histogram: %v", a.ParagraphLengths)
+	}
+
+	// Renumbered headings and slightly different text lengths: still the same work
+	renumbered := []string{"Введение", "1.1. Анализ предметной области", "1.2. Бизнес-процессы", "2. Проектирование", "2.1. Схема данных", "2.2. Интерфейс", "Заключение"}
+	if m := CompareFingerprints(a, StructuralFingerprint(build(renumbered, 130, 310, 640))); m.Similarity < 0.99 {
+		t.Fatalf("expected a near-identical structure, got %+v", m)
+	}
+
+	other := []string{"Введение", "1 Обзор литературы", "1.1 Методы", "2 Эксперимент", "2.1 Установка", "2.2 Результаты", "Заключение"}
+	m := CompareFingerprints(a, StructuralFingerprint(build(other, 40, 90, 900, 900)))
+	if m.Similarity >= 0.9 || m.Headings > 0.5 {
+		t.Fatalf("expected a different structure, got %+v", m)
+	}
+}
+
+func TestArticleFrontMatter(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	para := func(text string) string { return `<w:p><w:r><w:t>` + text + `</w:t></w:r></w:p>` }
+	body := strings.Repeat(para("Основной текст статьи, набранный в две колонки."), 6)
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>` +
+			para("УДК") +
+			para("Метод проверки оформления научных статей") +
+			para("И. И. Иванов, П. П. Петров, С. С. Сидоров") +
+			`<w:p><w:pPr><w:sectPr><w:type w:val="continuous"/></w:sectPr></w:pPr></w:p>` +
+			para("Аннотация. В статье описан метод автоматической проверки оформления научных статей по требованиям журнала.") +
+			para("Ключевые слова: нормоконтроль, оформление") + body +
+			para("Список литературы") +
+			para("1. Ivanov II, Petrov PP. Document checking. Journal of Tests. 2020;12(3):45-50.") +
+			para("2. Иванов, И. И. Нормоконтроль. – Москва : Наука, 2020. – 100 с.") +
+			`<w:sectPr><w:cols w:num="2"/></w:sectPr></w:body></w:document>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Sections) != 2 || doc.Sections[1].Columns != 2 {
+		t.Fatalf("unexpected sections: %+v", doc.Sections)
+	}
+
+	cfg := ArticleConfig{
+		RequireUDC: true, MaxAuthors: 2, RequireAffiliation: true,
+		AbstractLanguages: "ru, en", RequireKeywords: true, MinKeywords: 3,
+		ReferenceStyle: "vancouver", Columns: 2,
+	}
+	vs, rules := checkArticle(doc, cfg, ReferencesConfig{})
+	got := map[string]int{}
+	for _, v := range vs {
+		got[v.RuleType]++
+	}
+	want := map[string]int{
+		"article_udc": 1, "article_authors": 1, "article_affiliation": 1,
+		"article_abstract": 1, "article_keywords": 2, "article_reference_style": 1,
+	}
+	for rule, n := range want {
+		if got[rule] != n {
+			t.Errorf("%s: expected %d violations, got %d (%+v)", rule, n, got[rule], vs)
+		}
+	}
+	if got["article_columns"] != 0 {
+		t.Errorf("two columns expected to pass: %+v", vs)
+	}
+	if rules != 10 {
+		t.Errorf("expected 10 rules, got %d", rules)
+	}
+}
+
+func TestTableCellContentChecked(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	cell := func(rpr, jc, text string) string {
+		return `<w:tc><w:p><w:pPr>` + jc + `</w:pPr><w:r><w:rPr>` + rpr + `</w:rPr><w:t>` + text + `</w:t></w:r></w:p></w:tc>`
+	}
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+			<w:p><w:r><w:t>Таблица 1 – Результаты</w:t></w:r></w:p>
+			<w:tbl><w:tr>` +
+			cell(`<w:sz w:val="24"/>`, `<w:jc w:val="center"/>`, "Показатель") +
+			cell(`<w:rFonts w:ascii="Arial"/><w:sz w:val="16"/>`, `<w:jc w:val="center"/>`, "Значение") +
+			`</w:tr><w:tr>` +
+			cell(`<w:sz w:val="24"/>`, `<w:jc w:val="left"/>`, "Скорость") +
+			`<w:tc><w:p/></w:tc></w:tr></w:tbl>
+			<w:p><w:r><w:t>Текст после таблицы.</w:t></w:r></w:p>
+		</w:body></w:document>`,
+		"word/styles.xml": `<w:styles ` + ns + `><w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Times New Roman"/><w:sz w:val="28"/></w:rPr></w:rPrDefault></w:docDefaults></w:styles>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Tables) != 1 || len(doc.Tables[0].Cells) != 3 {
+		t.Fatalf("unexpected cells: %+v", doc.Tables)
+	}
+	if c := doc.Tables[0].Cells[2]; c.Row != 2 || c.Col != 1 || c.Paragraph.FontSizePt != 12 {
+		t.Fatalf("unexpected cell: %+v", c)
+	}
+
+	font := FontConfig{Name: "Times New Roman", Size: 14}
+	vs, rules := checkTableCells(doc.Tables, font, TableConfig{CellFontSize: 12, CellAlignment: "center"}, 0)
+	got := map[string]int{}
+	for _, v := range vs {
+		got[v.RuleType]++
+		if !strings.Contains(v.PositionInDoc, "Table 1, Row") || v.Location == nil || v.Location.TableIndex == nil {
+			t.Errorf("expected a cell position, got %+v", v)
+		}
+	}
+	if got["font_name"] != 1 || got["font_size"] != 1 || got["alignment"] != 1 || rules != 9 {
+		t.Fatalf("unexpected violations (%d rules): %+v", rules, vs)
+	}
+
+	_, violations, err := NewCheckService().Evaluate(context.Background(), doc,
+		`{"font": {"name": "Times New Roman", "size": 14}, "tables": {"check_cell_content": true}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := 0
+	for _, v := range violations {
+		if v.RuleType == "font_size" {
+			sizes++
+		}
+	}
+	if sizes != 3 {
+		t.Fatalf("expected the body size in all cells, got %+v", violations)
+	}
+}
+
+func TestPresentationChecked(t *testing.T) {
+	const ns = `xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+	shape := func(ph, body string) string {
+		return `<p:sp><p:nvSpPr><p:nvPr>` + ph + `</p:nvPr></p:nvSpPr><p:txBody>` + body + `</p:txBody></p:sp>`
+	}
+	slide := func(extra, shapes string) string {
+		return `<p:sld ` + ns + extra + `><p:cSld><p:spTree>` + shapes + `</p:spTree></p:cSld></p:sld>`
+	}
+	toLayout := `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="slideLayout" Target="../slideLayouts/slideLayout1.xml"/></Relationships>`
+	r := buildZip(t, map[string]string{
+		"ppt/presentation.xml": `<p:presentation ` + ns + `><p:sldIdLst><p:sldId id="256" r:id="rId2"/><p:sldId id="257" r:id="rId3"/><p:sldId id="258" r:id="rId4"/><p:sldId id="259" r:id="rId5"/></p:sldIdLst><p:sldSz cx="12192000" cy="6858000"/></p:presentation>`,
+		"ppt/_rels/presentation.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId2" Type="slide" Target="slides/slide1.xml"/><Relationship Id="rId3" Type="slide" Target="slides/slide2.xml"/>
+			<Relationship Id="rId4" Type="slide" Target="slides/slide3.xml"/><Relationship Id="rId5" Type="slide" Target="slides/slide4.xml"/></Relationships>`,
+		"ppt/slides/slide1.xml": slide("", shape(`<p:ph type="ctrTitle"/>`, `<a:p><a:r><a:t>Система нормоконтроля</a:t></a:r></a:p>`)+
+			shape(`<p:ph type="subTitle" idx="1"/>`, `<a:p><a:r><a:t>Выполнил: студент И. И. Иванов</a:t></a:r></a:p>`)),
+		"ppt/slides/slide2.xml": slide("", shape(`<p:ph type="title"/>`, `<a:p><a:r><a:t>Цель работы</a:t></a:r></a:p>`)+
+			shape(`<p:ph idx="1"/>`, `<a:bodyPr><a:normAutofit fontScale="50000"/></a:bodyPr><a:p><a:r><a:t>Автоматизировать проверку</a:t></a:r></a:p>`)+
+			shape(`<p:ph type="sldNum" idx="12"/>`, `<a:p><a:fld type="slidenum"><a:t>2</a:t></a:fld></a:p>`)),
+		"ppt/slides/slide3.xml":            slide("", shape(``, `<a:p><a:r><a:rPr sz="1000"/><a:t>Мелкая сноска</a:t></a:r></a:p>`)),
+		"ppt/slides/slide4.xml":            slide(` show="0"`, shape(``, `<a:p><a:r><a:t>Скрытый слайд</a:t></a:r></a:p>`)),
+		"ppt/slides/_rels/slide1.xml.rels": toLayout,
+		"ppt/slides/_rels/slide2.xml.rels": toLayout,
+		"ppt/slides/_rels/slide3.xml.rels": toLayout,
+		"ppt/slides/_rels/slide4.xml.rels": toLayout,
+		"ppt/slideLayouts/slideLayout1.xml": `<p:sldLayout ` + ns + `><p:cSld><p:spTree>` +
+			shape(`<p:ph type="ctrTitle"/>`, `<a:lstStyle><a:lvl1pPr><a:defRPr sz="4400"/></a:lvl1pPr></a:lstStyle>`) + `</p:spTree></p:cSld></p:sldLayout>`,
+		"ppt/slideLayouts/_rels/slideLayout1.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="slideMaster" Target="../slideMasters/slideMaster1.xml"/></Relationships>`,
+		"ppt/slideMasters/slideMaster1.xml": `<p:sldMaster ` + ns + `><p:cSld><p:spTree/></p:cSld><p:txStyles>
+			<p:titleStyle><a:lvl1pPr><a:defRPr sz="3200"/></a:lvl1pPr></p:titleStyle>
+			<p:bodyStyle><a:lvl1pPr><a:defRPr sz="2800"/></a:lvl1pPr></p:bodyStyle>
+			<p:otherStyle><a:lvl1pPr><a:defRPr sz="1800"/></a:lvl1pPr></p:otherStyle></p:txStyles></p:sldMaster>`,
+	})
+	pres, err := (&PptxParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pres.Slides) != 4 || !pres.Slides[3].Hidden || math.Abs(pres.SlideWidthMm-338.7) > 0.1 {
+		t.Fatalf("unexpected presentation: %+v", pres)
+	}
+	sizes := []float64{pres.Slides[0].Paragraphs[0].FontSizePt, pres.Slides[0].Paragraphs[1].FontSizePt, pres.Slides[1].Paragraphs[1].FontSizePt, pres.Slides[2].Paragraphs[0].FontSizePt}
+	if sizes[0] != 44 || sizes[1] != 28 || sizes[2] != 14 || sizes[3] != 10 {
+		t.Fatalf("unexpected font sizes: %v", sizes)
+	}
+	if pres.Slides[1].Title != "Цель работы" || !pres.Slides[1].HasSlideNumber || pres.Slides[2].HasSlideNumber {
+		t.Fatalf("unexpected slides: %+v", pres.Slides)
+	}
+
+	cfg := PresentationConfig{MinSlides: 5, TitleSlideFields: "Выполнил, Руководитель", MinFontSizePt: 18, RequireSlideNumbers: true, RequireSlideTitles: true}
+	vs, rules := checkPresentation(pres, cfg)
+	got := map[string]int{}
+	for _, v := range vs {
+		got[v.RuleType]++
+	}
+	want := map[string]int{"presentation_slide_count": 1, "presentation_title_slide": 1, "presentation_font_size": 2, "presentation_slide_title": 1, "presentation_slide_number": 1}
+	for rule, n := range want {
+		if got[rule] != n {
+			t.Errorf("%s: expected %d violations, got %d (%+v)", rule, n, got[rule], vs)
+		}
+	}
+	if rules != 1+2+3+3+1 {
+		t.Errorf("unexpected rule count %d", rules)
+	}
+}
+
+func TestFormulaContentParsed(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:m="http://schemas.openxmlformats.org/officeDocument/2006/math" xmlns:o="urn:schemas-microsoft-com:office:office"`
+	mr := func(rpr, text string) string {
+		return `<m:r>` + rpr + `<m:t>` + text + `</m:t></m:r>`
+	}
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+			<w:p><m:oMathPara><m:oMath>` +
+			mr(`<w:rPr><w:sz w:val="28"/></w:rPr>`, "E=m") +
+			`<m:sSup><m:e>` + mr("", "c") + `</m:e><m:sup>` + mr("", "2") + `</m:sup></m:sSup>` +
+			`</m:oMath></m:oMathPara></w:p>
+			<w:p><m:oMath>` +
+			`<m:f><m:num>` + mr(`<m:rPr><m:sty m:val="p"/></m:rPr><w:rPr><w:sz w:val="20"/></w:rPr>`, "a+b") + `</m:num><m:den>` + mr("", "2") + `</m:den></m:f>` +
+			`<m:func><m:fName>` + mr(`<m:rPr><m:sty m:val="p"/></m:rPr>`, "sin") + `</m:fName><m:e>` + mr("", "x") + `</m:e></m:func>` +
+			`</m:oMath><w:r><w:t>(2)</w:t></w:r></w:p>
+			<w:p><w:r><w:object><o:OLEObject ProgID="Equation.3"/></w:object></w:r><w:r><w:t>(3)</w:t></w:r></w:p>
+		</w:body></w:document>`,
+		"word/styles.xml": `<w:styles ` + ns + `><w:docDefaults><w:rPrDefault><w:rPr><w:sz w:val="28"/></w:rPr></w:rPrDefault></w:docDefaults></w:styles>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Formulas) != 3 {
+		t.Fatalf("expected 3 formulas, got %+v", doc.Formulas)
+	}
+	if f := doc.Formulas[0]; f.Text != "E=mc^(2)" || f.FontSizePt != 14 || len(f.UprightVariables) != 0 {
+		t.Errorf("unexpected formula: %+v", f)
+	}
+	if f := doc.Formulas[1]; f.Text != "(a+b)/(2)sin x" || f.FontSizePt != 10 || strings.Join(f.UprightVariables, ",") != "a,b" {
+		t.Errorf("unexpected formula: %+v", f)
+	}
+	if f := doc.Formulas[2]; !f.IsImage || f.Number != "3" {
+		t.Errorf("expected an embedded object, got %+v", f)
+	}
+
+	vs, rules := checkFormulaContent(doc.Formulas, doc.Paragraphs, FormulaConfig{CheckVariablesItalic: true, CheckFontSize: true, ForbidImages: true}, FontConfig{Size: 14, SizeTolerance: 0.75})
+	got := map[string]int{}
+	for _, v := range vs {
+		got[v.RuleType]++
+	}
+	if got["formula_image"] != 1 || got["formula_variable_style"] != 1 || got["formula_font_size"] != 1 || rules != 7 {
+		t.Fatalf("unexpected violations (%d rules): %+v", rules, vs)
+	}
+}
+
+func TestRuleTraceExplainsGroups(t *testing.T) {
+	doc := &ParsedDoc{
+		Margins:  Margins{TopMm: 20, BottomMm: 20, LeftMm: 25, RightMm: 10},
+		PageSize: PageSize{Orientation: "portrait"},
+		Paragraphs: []ParsedParagraph{
+			{ID: "p1", Text: "Текст работы.", PageNumber: 1, FontName: "Times New Roman", FontSizePt: 14},
+		},
+	}
+	res, violations, err := NewCheckService().Evaluate(context.Background(), doc,
+		`{"margins": {"top": 20, "bottom": 20, "left": 30, "right": 10}, "page_setup": {"orientation": "portrait"}, "tables": {"require_caption": true}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := map[string]models.RuleTrace{}
+	for _, g := range res.RuleTrace {
+		groups[g.Rule] = g
+	}
+
+	m := groups["margins"]
+	if m.Status != models.TraceFailed || m.Rules != 4 || m.Failed["margin_left"] != 1 || len(m.Evidence) != 1 || m.Read["margins_mm"] == nil {
+		t.Errorf("unexpected margins trace: %+v", m)
+	}
+	if g := groups["page_setup"]; g.Status != models.TracePassed || g.Read["orientation"] != "portrait" {
+		t.Errorf("unexpected page setup trace: %+v", g)
+	}
+	if g := groups["tables"]; g.Status != models.TraceSkipped || g.Reason != "В документе нет таблиц" {
+		t.Errorf("unexpected tables trace: %+v", g)
+	}
+	if g := groups["footnotes"]; g.Status != models.TraceSkipped || g.Reason == "" {
+		t.Errorf("unexpected footnotes trace: %+v", g)
+	}
+	if _, ok := groups["score"]; ok {
+		t.Errorf("scoring is not a rule group: %+v", res.RuleTrace)
+	}
+
+	traced := 0
+	for _, g := range res.RuleTrace {
+		traced += g.Violations
+	}
+	if traced != len(violations) {
+		t.Fatalf("trace covers %d of %d violations", traced, len(violations))
+	}
+}
+
+func TestHeadingDetectionStrategies(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	para := func(style, text string) string {
+		return `<w:p><w:pPr><w:pStyle w:val="` + style + `"/></w:pPr><w:r><w:t>` + text + `</w:t></w:r></w:p>`
+	}
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>` +
+			para("Heading1", "1 Введение") +
+			para("a5", "2 Основная часть") +
+			para("a7", "2.1 Анализ") +
+			para("Normal", "Текст работы.") +
+			`</w:body></w:document>`,
+		"word/styles.xml": `<w:styles ` + ns + `>
+			<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+			<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:pPr><w:outlineLvl w:val="0"/></w:pPr></w:style>
+			<w:style w:type="paragraph" w:styleId="a5"><w:name w:val="Заголовок раздела"/></w:style>
+			<w:style w:type="paragraph" w:styleId="a7"><w:name w:val="Подраздел"/><w:pPr><w:outlineLvl w:val="1"/></w:pPr></w:style>
+		</w:styles>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := doc.Paragraphs[2]; p.StyleName != "Подраздел" || p.OutlineLevel != 2 {
+		t.Fatalf("unexpected style of the paragraph: %+v", p)
+	}
+
+	levels := func(cfg HeadingDetectionConfig) []int {
+		var out []int
+		for _, p := range withHeadingDetection(doc, cfg).Paragraphs {
+			if isHeadingParagraph(p) {
+				out = append(out, paragraphHeadingLevel(p))
+			} else {
+				out = append(out, -1)
+			}
+		}
+		return out
+	}
+	for _, c := range []struct {
+		cfg  HeadingDetectionConfig
+		want []int
+	}{
+		{HeadingDetectionConfig{Strategy: "styles"}, []int{1, -1, -1, -1}},
+		{HeadingDetectionConfig{Strategy: "outline"}, []int{1, -1, 2, -1}},
+		{HeadingDetectionConfig{Strategy: "custom", Styles: map[string]int{"заголовок  раздела": 1}}, []int{-1, 1, -1, -1}},
+		{HeadingDetectionConfig{Styles: map[string]int{"Заголовок раздела": 1, "a7": 2}}, []int{1, 1, 2, -1}},
+		{HeadingDetectionConfig{Strategy: "heuristics", Styles: map[string]int{"Заголовок раздела": 1}}, []int{1, 1, 2, -1}},
+	} {
+		if got := levels(c.cfg); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("%+v: got heading levels %v, want %v", c.cfg, got, c.want)
+		}
+	}
+	if withHeadingDetection(doc, HeadingDetectionConfig{}) != doc {
+		t.Error("the default detection must not copy the document")
+	}
+	if p := withHeadingDetection(doc, HeadingDetectionConfig{Strategy: "custom"}).Paragraphs[0]; p.Role != "body" || doc.Paragraphs[0].Role != "heading" {
+		t.Errorf("expected the copy re-classified and the parsed document kept, got %q", p.Role)
+	}
+}
+
+func TestPaginationChecks(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	text := `<w:p><w:r><w:t>Текст раздела.</w:t></w:r></w:p>`
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+			<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>1 Введение</w:t></w:r></w:p>` + text + `
+			<w:p><w:pPr><w:widowControl w:val="1"/></w:pPr><w:r><w:t>Абзац с включённым запретом.</w:t></w:r></w:p>
+			<w:p><w:pPr><w:pStyle w:val="Heading2"/></w:pPr><w:r><w:t>1.1 Анализ</w:t><w:br w:type="page"/></w:r></w:p>` + text + `
+		</w:body></w:document>`,
+		"word/styles.xml": `<w:styles ` + ns + `>
+			<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:pPr><w:widowControl w:val="0"/></w:pPr></w:style>
+			<w:style w:type="paragraph" w:styleId="Heading1"><w:basedOn w:val="Normal"/><w:pPr><w:keepNext/></w:pPr></w:style>
+			<w:style w:type="paragraph" w:styleId="Heading2"><w:basedOn w:val="Heading1"/><w:pPr><w:keepNext w:val="0"/></w:pPr></w:style>
+		</w:styles>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !doc.Paragraphs[0].KeepNext || doc.Paragraphs[0].WidowControl || doc.Paragraphs[3].KeepNext || !doc.Paragraphs[2].WidowControl {
+		t.Fatalf("pagination settings must be inherited from styles: %+v", doc.Paragraphs)
+	}
+
+	vs, rules := checkPagination(doc, StructureConfig{RequireWidowControl: true, HeadingKeepNext: true, HeadingNotLastOnPage: true}, 0)
+	got := map[string]int{}
+	for _, v := range vs {
+		got[v.RuleType]++
+	}
+	if got["widow_control"] != 1 || got["heading_keep_next"] != 1 || got["heading_last_on_page"] != 1 || rules != 5 {
+		t.Fatalf("unexpected violations (%d rules): %+v", rules, vs)
+	}
+	for _, v := range vs {
+		if v.RuleType == "widow_control" && v.ActualValue != "Отключён в 2 абз." {
+			t.Errorf("expected both body paragraphs of the Normal style, got %q", v.ActualValue)
+		}
+		if v.RuleType == "heading_last_on_page" && !strings.Contains(v.PositionInDoc, "1.1 Анализ") {
+			t.Errorf("expected the second heading, got %q", v.PositionInDoc)
+		}
+	}
+}
+
+func TestOutlineLevelHeadings(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+			<w:p><w:pPr><w:pStyle w:val="a5"/></w:pPr><w:r><w:t>1 Основная часть</w:t></w:r></w:p>
+			<w:p><w:r><w:t>Текст раздела.</w:t></w:r></w:p>
+			<w:p><w:pPr><w:outlineLvl w:val="2"/></w:pPr><w:r><w:t>1.1.1 Пункт</w:t></w:r></w:p>
+			<w:p><w:pPr><w:pStyle w:val="a5"/><w:outlineLvl w:val="9"/></w:pPr><w:r><w:t>Обычный абзац в стиле раздела.</w:t></w:r></w:p>
+		</w:body></w:document>`,
+		"word/styles.xml": `<w:styles ` + ns + `>
+			<w:style w:type="paragraph" w:styleId="a5"><w:name w:val="Раздел"/><w:pPr><w:outlineLvl w:val="0"/></w:pPr></w:style>
+		</w:styles>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var levels []int
+	for _, p := range doc.Paragraphs {
+		levels = append(levels, paragraphHeadingLevel(p))
+	}
+	if fmt.Sprint(levels) != "[1 0 3 0]" || doc.Paragraphs[0].Role != "heading" || doc.Paragraphs[3].Role == "heading" {
+		t.Fatalf("unexpected heading levels %v: %+v", levels, doc.Paragraphs)
+	}
+
+	_, violations, err := NewCheckService().Evaluate(context.Background(), doc, `{"structure": {"heading_hierarchy": true}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hierarchy := 0
+	for _, v := range violations {
+		if v.RuleType == "structure_hierarchy" {
+			hierarchy++
+		}
+	}
+	if hierarchy != 1 {
+		t.Fatalf("expected the skipped level 2, got %+v", violations)
+	}
+}
+
+func TestHyphenationChecks(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+			<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>1 Проек</w:t></w:r><w:r><w:softHyphen/></w:r><w:r><w:t>тирование</w:t></w:r></w:p>
+			<w:p><w:r><w:t>Текст раздела.</w:t></w:r></w:p>
+			<w:p><w:pPr><w:pStyle w:val="Heading2"/></w:pPr><w:r><w:t>1.1 Анализ</w:t></w:r></w:p>
+			<w:p><w:pPr><w:pStyle w:val="Heading2"/><w:suppressAutoHyphens/></w:pPr><w:r><w:t>1.2 Выводы</w:t></w:r></w:p>
+		</w:body></w:document>`,
+		"word/settings.xml": `<w:settings ` + ns + `><w:autoHyphenation/></w:settings>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !doc.AutoHyphenation || doc.Paragraphs[0].SoftHyphens != 1 || !doc.Paragraphs[3].SuppressAutoHyphens {
+		t.Fatalf("hyphenation settings not parsed: auto=%v %+v", doc.AutoHyphenation, doc.Paragraphs)
+	}
+
+	vs, rules := checkHyphenation(doc, TypographyRulesConfig{ForbidAutoHyphenation: true, ForbidHeadingHyphenation: true}, 0)
+	got := map[string]int{}
+	for _, v := range vs {
+		got[v.RuleType]++
+		if v.RuleType == "heading_hyphenation" && v.IsDoubtful && v.ActualValue != "Переносы разрешены в 1 заг." {
+			t.Errorf("only the second heading is left to automatic hyphenation, got %q", v.ActualValue)
+		}
+	}
+	if got["hyphenation_auto"] != 1 || got["heading_hyphenation"] != 2 || rules != 4 {
+		t.Fatalf("unexpected violations (%d rules): %+v", rules, vs)
+	}
+
+	prep, _ := checkTypographyRules("Она пришла в дом и к\u00a0соседям, а В 2020 году — нет.", TypographyRulesConfig{CheckHangingPrepositions: true}, "", nil)
+	if len(prep) != 4 {
+		t.Fatalf("expected «в», «и», «а» and «В» but not «к» before a non-breaking space, got %+v", prep)
+	}
+}
+
+func TestDocPropertiesChecks(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body><w:p><w:r><w:t>Текст</w:t></w:r></w:p></w:body></w:document>`,
+		"docProps/core.xml": `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/">
+			<dc:creator>Петров П. П.</dc:creator><cp:lastModifiedBy>Иванов</cp:lastModifiedBy>
+			<dcterms:created>2025-03-01T10:00:00Z</dcterms:created></cp:coreProperties>`,
+		"docProps/app.xml": `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties">
+			<Application>Aspose.Words for .NET</Application><TotalTime>3</TotalTime><Pages>42</Pages><Words>9000</Words></Properties>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := doc.Properties
+	if props.Author != "Петров П. П." || props.Pages != 42 || props.Words != 9000 || props.EditingMinutes != 3 || props.Created.Year() != 2025 {
+		t.Fatalf("properties not parsed: %+v", props)
+	}
+
+	cfg := DocPropertiesConfig{CheckAuthor: true, ForbidConverters: true, MinEditingMinutes: 30}
+	got := map[string]int{}
+	for _, v := range checkDocProperties(doc, cfg, "Иванов Иван Иванович") {
+		got[v.RuleType]++
+		if v.Severity != models.SeverityIntegrity {
+			t.Errorf("%s must be an integrity finding, got %q", v.RuleType, v.Severity)
+		}
+	}
+	if got["doc_properties_author"] != 1 || got["doc_properties_converter"] != 1 || got["doc_properties_editing_time"] != 1 {
+		t.Fatalf("unexpected violations: %v", got)
+	}
+	if !authorMatches("Иванов И.И.", "Иванов Иван Иванович") || authorMatches("Петров И.И.", "Иванов Иван Иванович") {
+		t.Error("the surname must decide whether the author matches")
+	}
+	if isoDurationMinutes("P1DT2H5M30S") != 24*60+125 {
+		t.Errorf("unexpected duration: %d", isoDurationMinutes("P1DT2H5M30S"))
+	}
+}
+
+func TestRevisionChecks(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:w14="http://schemas.microsoft.com/office/word/2010/wordml" xmlns:w15="http://schemas.microsoft.com/office/word/2012/wordml"`
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+			<w:p><w:r><w:t xml:space="preserve">Первый </w:t></w:r><w:ins w:author="Петров" w:date="2025-05-01T10:00:00Z"><w:r><w:t>вставленный</w:t></w:r></w:ins><w:del w:author="Петров"><w:r><w:delText>удалённый</w:delText></w:r></w:del><w:r><w:commentReference w:id="0"/></w:r></w:p>
+			<w:p><w:r><w:rPr><w:b/><w:rPrChange w:author="Сидоров"><w:rPr/></w:rPrChange></w:rPr><w:t>Второй</w:t></w:r><w:r><w:commentReference w:id="1"/></w:r></w:p>
+		</w:body></w:document>`,
+		"word/comments.xml": `<w:comments ` + ns + `>
+			<w:comment w:id="0" w:author="Петров"><w:p w14:paraId="0A"><w:r><w:t>Уточните формулировку</w:t></w:r></w:p></w:comment>
+			<w:comment w:id="1" w:author="Петров"><w:p w14:paraId="0B"><w:r><w:t>Исправлено</w:t></w:r></w:p></w:comment>
+		</w:comments>`,
+		"word/commentsExtended.xml": `<w15:commentsEx ` + ns + `><w15:commentEx w15:paraId="0B" w15:done="1"/></w15:commentsEx>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Paragraphs[0].Text != "Первый вставленный" {
+		t.Errorf("inserted text must be part of the paragraph, got %q", doc.Paragraphs[0].Text)
+	}
+	kinds := map[string]int{}
+	for _, rev := range doc.Revisions {
+		kinds[rev.Kind]++
+	}
+	if kinds[revisionInsertion] != 1 || kinds[revisionDeletion] != 1 || kinds[revisionFormatting] != 1 {
+		t.Fatalf("unexpected revisions: %+v", doc.Revisions)
+	}
+	if len(doc.Comments) != 2 || doc.Comments[0].Resolved || !doc.Comments[1].Resolved || doc.Comments[1].ParagraphIndex != 1 {
+		t.Fatalf("unexpected comments: %+v", doc.Comments)
+	}
+
+	vs, rules := checkRevisions(doc, RevisionsConfig{ForbidTrackedChanges: true, ForbidComments: true})
+	if rules != 2 {
+		t.Errorf("expected 2 rules, got %d", rules)
+	}
+	var changes, open, resolved int
+	for _, v := range vs {
+		switch {
+		case v.RuleType == "tracked_changes":
+			changes++
+			if !strings.Contains(v.ActualValue, "Петров, Сидоров") {
+				t.Errorf("authors missing: %q", v.ActualValue)
+			}
+		case v.RuleType == "review_comments" && v.Severity == "error":
+			open++
+		case v.RuleType == "review_comments" && v.Severity == "warning":
+			resolved++
+		}
+	}
+	if changes != 1 || open != 1 || resolved != 1 {
+		t.Fatalf("unexpected violations: %+v", vs)
+	}
+}
+
+func TestThemeFontsOfRunsAreResolved(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+			<w:p><w:r><w:rPr><w:rFonts w:asciiTheme="minorHAnsi" w:hAnsiTheme="minorHAnsi"/></w:rPr><w:t>Текст темы</w:t></w:r></w:p>
+			<w:p><w:r><w:rPr><w:rFonts w:ascii="Times New Roman" w:asciiTheme="majorHAnsi"/></w:rPr><w:t>Заголовок</w:t></w:r></w:p>
+			<w:p><w:r><w:rPr><w:rFonts w:ascii="Times New Roman" w:hAnsiTheme="minorHAnsi"/></w:rPr><w:t>Явный шрифт</w:t></w:r></w:p>
+		</w:body></w:document>`,
+		"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/theme" Target="theme/theme2.xml"/>
+		</Relationships>`,
+		"word/theme/theme2.xml": `<a:theme xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">
+			<a:themeElements><a:fontScheme>
+				<a:majorFont><a:latin typeface="Cambria"/></a:majorFont>
+				<a:minorFont><a:latin typeface=""/><a:font script="Cyrl" typeface="Arial"/></a:minorFont>
+			</a:fontScheme></a:themeElements>
+		</a:theme>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"Arial", "Cambria", "Times New Roman"} {
+		if got := doc.Paragraphs[i].FontName; got != want {
+			t.Errorf("paragraph %d: expected font %q, got %q", i+1, want, got)
+		}
+	}
+}
+
+func TestAutoFixCorrectsReportedFormatting(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	var pkg bytes.Buffer
+	zw := zip.NewWriter(&pkg)
+	for name, content := range map[string]string{
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document ` + ns + `><w:body>
+<w:p><w:pPr><w:jc w:val="left"/><w:ind w:hanging="200"/></w:pPr><w:r><w:rPr><w:rFonts w:ascii="Arial"/><w:sz w:val="28"/></w:rPr><w:t xml:space="preserve">Текст  с ошибками &amp; лишним.</w:t></w:r><w:hyperlink w:id="rId9"><w:r><w:t>ссылка</w:t></w:r></w:hyperlink></w:p>
+<w:p><w:pPr><w:spacing w:before="120" w:after="0" w:line="240" w:lineRule="auto"/><w:jc w:val="both"/><w:ind w:firstLine="709"/><w:sectPr><w:pgSz w:w="16838" w:h="11906" w:orient="landscape"/><w:pgMar w:top="850" w:right="850" w:bottom="850" w:left="850"/></w:sectPr></w:pPr><w:r><w:rPr><w:rFonts w:ascii="Times New Roman" w:hAnsi="Times New Roman"/><w:sz w:val="28"/></w:rPr><w:t>Альбомный раздел с одним интервалом.</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:rPr><w:rFonts w:ascii="Arial"/></w:rPr><w:t>Ячейка</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+` + strings.Repeat(`<w:p><w:pPr><w:spacing w:line="360" w:lineRule="auto"/><w:jc w:val="both"/><w:ind w:firstLine="709"/></w:pPr><w:r><w:rPr><w:rFonts w:ascii="Times New Roman" w:hAnsi="Times New Roman"/><w:sz w:val="28"/></w:rPr><w:t>Основной текст.</w:t></w:r></w:p>
+`, 3) + `<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="567" w:right="567" w:bottom="567" w:left="567" w:header="709"/></w:sectPr>
+</w:body></w:document>`,
+		"word/styles.xml": `<w:styles ` + ns + `/>`,
+	} {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := t.TempDir() + "/work.docx"
+	if err := os.WriteFile(path, pkg.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const standard = `{"margins": {"top": 20, "bottom": 20, "left": 30, "right": 15},
+		"font": {"name": "Times New Roman", "size": 14},
+		"paragraph": {"line_spacing": 1.5, "alignment": "justify", "first_line_indent": 12.5}}`
+	svc := NewCheckService()
+	var fixed bytes.Buffer
+	report, err := svc.AutoFix(context.Background(), path, "", standard, []string{FixMargins, FixFontName, FixLineSpacing, FixIndent, FixAlignment}, &fixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{FixMargins: 2, FixFontName: 1, FixLineSpacing: 1, FixIndent: 1, FixAlignment: 1}
+	if !reflect.DeepEqual(report.Fixed, want) {
+		t.Errorf("expected fixes %v, got %v", want, report.Fixed)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(fixed.Bytes()), int64(fixed.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, violations, err := svc.Evaluate(context.Background(), doc, standard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range violations {
+		if v.Location == nil || v.Location.TableIndex == nil {
+			t.Errorf("expected the violation to be fixed: %s %s", v.RuleType, v.PositionInDoc)
+		}
+	}
+
+	data, err := readEntryLimited(zipEntry(r, "word/document.xml"), ParserLimits{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, kept := range []string{
+		`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`,
+		`<w:pgMar w:top="1701" w:right="1134" w:bottom="850" w:left="1134"/>`,
+		`<w:pgMar w:top="1134" w:right="850" w:bottom="1134" w:left="1701" w:header="709"/>`,
+		`<w:rFonts w:ascii="Arial"/>`,
+		`<w:spacing w:before="120" w:after="0" w:line="360" w:lineRule="auto"/>`,
+		`Текст  с ошибками &amp; лишним.`,
+		`<w:pPr><w:jc w:val="both"/><w:ind w:firstLine="709"/></w:pPr>`,
+	} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("expected %q in the corrected document:\n%s", kept, out)
+		}
+	}
+	if strings.Count(out, `w:ascii="Times New Roman" w:hAnsi="Times New Roman" w:cs="Times New Roman"`) != 2 {
+		t.Errorf("expected the font to be set on both runs of the first paragraph, including the hyperlink:\n%s", out)
+	}
+}
+
+func TestAnnotateAnchorsCommentsToViolations(t *testing.T) {
+	const ns = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+	var pkg bytes.Buffer
+	zw := zip.NewWriter(&pkg)
+	for name, content := range map[string]string{
+		"[Content_Types].xml": `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="xml" ContentType="application/xml"/></Types>`,
+		"word/_rels/document.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`,
+		"word/document.xml": `<w:document ` + ns + `><w:body>
+<w:p><w:r><w:t>Титульный лист</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Ячейка</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+<w:p><w:pPr><w:jc w:val="left"/></w:pPr><w:r><w:t>Основной текст</w:t></w:r></w:p>
+<w:sectPr/></w:body></w:document>`,
+	} {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := t.TempDir() + "/work.docx"
+	if err := os.WriteFile(path, pkg.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	violations := []models.Violation{
+		{RuleType: "margin_left", Description: "Неверный левый отступ", ExpectedValue: "30.0 мм", ActualValue: "25.0 мм"},
+		{RuleType: "alignment", Description: "Неверное выравнивание", ExpectedValue: "justify", ActualValue: "left",
+			Location: &models.Location{ParagraphIndex: intPtr(1)}},
+		{RuleType: "table_font", Description: "Неверный шрифт в таблице", Suggestion: "Используйте Times New Roman",
+			Location: &models.Location{TableIndex: intPtr(0)}},
+		{RuleType: "font_name", Description: "Абзац вне документа", Location: &models.Location{ParagraphIndex: intPtr(5)}},
+	}
+	var out bytes.Buffer
+	n, err := NewCheckService().Annotate(path, violations, "Нормо Контроль", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 anchored comments, got %d", n)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&DocParser{}).parseZip(r); err != nil {
+		t.Fatalf("annotated document cannot be parsed: %v", err)
+	}
+	read := func(name string) string {
+		f := zipEntry(r, name)
+		if f == nil {
+			t.Fatalf("missing %s", name)
+		}
+		data, err := readEntryLimited(f, ParserLimits{}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	for name, want := range map[string][]string{
+		"word/document.xml": {
+			`<w:p><w:commentRangeStart w:id="0"/><w:r><w:t>Титульный лист</w:t></w:r><w:commentRangeEnd w:id="0"/><w:r><w:commentReference w:id="0"/></w:r></w:p>`,
+			`<w:tc><w:p><w:commentRangeStart w:id="2"/>`,
+			`<w:pPr><w:jc w:val="left"/></w:pPr><w:commentRangeStart w:id="1"/>`,
+		},
+		"word/comments.xml": {
+			`w:author="Нормо Контроль"`, `w:initials="НК"`,
+			`<w:t xml:space="preserve">Ожидается: justify; фактически: left</w:t>`,
+			`<w:t xml:space="preserve">Используйте Times New Roman</w:t>`,
+		},
+		"word/_rels/document.xml.rels": {`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="comments.xml"`},
+		"[Content_Types].xml":          {`<Override PartName="/word/comments.xml"`},
+	} {
+		got := read(name)
+		for _, s := range want {
+			if !strings.Contains(got, s) {
+				t.Errorf("expected %q in %s:\n%s", s, name, got)
+			}
+		}
+	}
+	if comments := read("word/comments.xml"); strings.Contains(comments, "Абзац вне документа") {
+		t.Errorf("a comment without an anchor must be left out:\n%s", comments)
+	}
+}
+
+type mapBlobStore map[string][]byte
+
+func (m mapBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, fmt.Errorf("missing %s", key)
+	}
+	return data, nil
+}
+
+func (m mapBlobStore) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	m[key] = value
+	return nil
+}
+
+func TestSharedParseCacheServesDocumentsParsedByAnotherProcess(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+			<w:p><w:r><w:t>Титульный лист</w:t><w:br w:type="page"/></w:r></w:p>
+			<w:p><w:r><w:t>Таблица 1 – Исходные данные</w:t></w:r></w:p>
+			<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Ячейка</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+			<w:p><w:pPr><w:jc w:val="left"/></w:pPr><w:r><w:t>Текст после таблицы</w:t></w:r></w:p>
+			<w:sectPr/>
+		</w:body></w:document>`,
+	})
+	doc, err := (&DocParser{}).parseZip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := mapBlobStore{}
+	(&SharedParseCache{Local: NewParseCache(1), Store: store, Prefix: "parsed:"}).Put("hash", doc)
+	if _, ok := store["parsed:hash"]; !ok {
+		t.Fatalf("expected the document in the store, got keys %v", reflect.ValueOf(store).MapKeys())
+	}
+
+	other := &SharedParseCache{Local: NewParseCache(1), Store: store, Prefix: "parsed:"}
+	cached, ok := other.Get("hash")
+	if !ok {
+		t.Fatal("expected a hit for a document stored by another process")
+	}
+	if local, ok := other.Local.Get("hash"); !ok || local != cached {
+		t.Fatal("a document loaded from the store should be kept locally")
+	}
+	if _, ok := other.Get("unknown"); ok {
+		t.Fatal("expected a miss for an unknown hash")
+	}
+
+	standard := `{"formatting":{"alignment":"both"},"tables":{"alignment":"center"}}`
+	_, want, err := NewCheckService().Evaluate(context.Background(), doc, standard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, got, err := NewCheckService().Evaluate(context.Background(), cached, standard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) == 0 || !reflect.DeepEqual(got, want) {
+		t.Fatalf("the cached document should check like the parsed one:\nwant %+v\ngot  %+v", want, got)
+	}
+}
+
+func TestStandardsInheritDeploymentDefaults(t *testing.T) {
+	doc := &ParsedDoc{
+		Paragraphs: []ParsedParagraph{
+			{Text: "Основной текст работы с немного уменьшенным шрифтом.", Role: "body", FontName: "Times New Roman", FontSizePt: 13.5, PageNumber: 1},
+			{Text: "Табл. 1 – Исходные данные", Role: "table_caption", PageNumber: 1},
+		},
+		Tables: []ParsedTable{{HasCaption: true, CaptionText: "Табл. 1 – Исходные данные", CaptionAbove: true, PageNumber: 1, ParagraphIndex: 2}},
+	}
+	count := func(svc *CheckService, standard string) map[string]int {
+		t.Helper()
+		_, violations, err := svc.Evaluate(context.Background(), doc, standard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]int{}
+		for _, v := range violations {
+			got[v.RuleType]++
+		}
+		return got
+	}
+	standard := `{"font": {"size": 14}, "tables": {"require_caption": true}}`
+
+	if got := count(NewCheckService(), standard); got["font_size"] != 0 || got["table_caption_keyword"] != 1 {
+		t.Fatalf("builtin defaults: 0.5 pt is within tolerance and «Табл.» is not «Таблица», got %v", got)
+	}
+
+	svc := NewCheckService()
+	defaults := BuiltinDefaults
+	defaults.FontSizeTolerancePt = 0.25
+	defaults.TableCaptionKeyword = "Табл."
+	svc.Defaults = &defaults
+	if got := count(svc, standard); got["font_size"] != 1 || got["table_caption_keyword"] != 0 {
+		t.Fatalf("deployment defaults should apply, got %v", got)
+	}
+	if got := count(svc, `{"font": {"size": 14, "size_tolerance": 1}, "tables": {"require_caption": true, "caption_keyword": "Таблица"}}`); got["font_size"] != 0 || got["table_caption_keyword"] != 1 {
+		t.Fatalf("the standard's own values should win, got %v", got)
+	}
+
+	if err := defaults.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	defaults.IndentToleranceMm = 0
+	if err := defaults.Validate(); err == nil || !strings.Contains(err.Error(), "indent_tolerance_mm") {
+		t.Fatalf("expected a zero tolerance to be rejected, got %v", err)
+	}
+}
+
+func TestDeploymentDefaultsReachEveryTolerance(t *testing.T) {
+	doc := &ParsedDoc{
+		Margins:    Margins{HeaderMm: 13.5},
+		Paragraphs: []ParsedParagraph{{Text: "Текст со сноской.", Role: "body", PageNumber: 1}},
+		Footnotes:  []ParsedNote{{Text: "Сноска", FontSizePt: 10.7, PageNumber: 1}},
+	}
+	count := func(svc *CheckService) map[string]int {
+		t.Helper()
+		_, violations, err := svc.Evaluate(context.Background(), doc, `{"header_footer": {"header_dist": 15}, "footnotes": {"enabled": true, "font_size_pt": 10}}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]int{}
+		for _, v := range violations {
+			got[v.RuleType]++
+		}
+		return got
+	}
+	if got := count(NewCheckService()); got["header_dist"] != 0 || got["footnote_font_size"] != 1 {
+		t.Fatalf("builtin defaults: 1.5 mm is within 2 mm, 0.7 pt is over 0.5 pt, got %v", got)
+	}
+	svc := NewCheckService()
+	defaults := BuiltinDefaults
+	defaults.HeaderFooterToleranceMm = 1
+	defaults.FootnoteSizeTolerancePt = 1
+	svc.Defaults = &defaults
+	if got := count(svc); got["header_dist"] != 1 || got["footnote_font_size"] != 0 {
+		t.Fatalf("deployment defaults should apply, got %v", got)
+	}
+
+	d := Defaults{FontSizeTolerancePt: 1.1, HeaderFooterToleranceMm: 1.2, CaptionIndentToleranceMm: 1.3, ListIndentToleranceMm: 1.4,
+		FootnoteSizeTolerancePt: 1.5, CodeSizeTolerancePt: 1.6, CodeLineSpacingTolerance: 1.7, CodeIndentToleranceMm: 1.8}
+	var config ConfigSchema
+	config.inherit(d)
+	got := []float64{config.Headings.SizeTolerance, config.HeaderFooter.Tolerance, config.Tables.CaptionIndentTolerance,
+		config.Images.CaptionIndentTolerance, config.Lists.IndentTolerance, config.Footnotes.SizeTolerance,
+		config.CodeBlocks.SizeTolerance, config.CodeBlocks.LineSpacingTolerance, config.CodeBlocks.IndentTolerance}
+	want := []float64{1.1, 1.2, 1.3, 1.3, 1.4, 1.5, 1.6, 1.7, 1.8}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the tolerances %v to be inherited, got %v", want, got)
+	}
+}
+
+func TestFileNameRule(t *testing.T) {
+	doc := &ParsedDoc{}
+	config := `{"file_name": {"pattern": "[А-Я]+-\\d+_[А-ЯЁ][а-яё-]+_ВКР\\.docx", "example": "ИВТ-21_Иванов_ВКР.docx"}}`
+	for name, want := range map[string]bool{
+		"ИВТ-21_Иванов_ВКР.docx":      true,
+		"ВКР Иванов.docx":             false,
+		"x ИВТ-21_Иванов_ВКР.docx":    false, // the whole name must match
+		"ИВТ-21_Иванов_ВКР.docx.docx": false,
+	} {
+		svc := NewCheckService()
+		svc.FileName = name
+		res, violations, err := svc.Evaluate(context.Background(), doc, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found *models.Violation
+		for i := range violations {
+			if violations[i].RuleType == "file_name" {
+				found = &violations[i]
+			}
+		}
+		if want != (found == nil) {
+			t.Fatalf("%s: expected match=%v, got violation %+v", name, want, found)
+		}
+		if found != nil && (found.ExpectedValue != "ИВТ-21_Иванов_ВКР.docx" || found.ActualValue != name) {
+			t.Fatalf("%s: unexpected violation %+v", name, found)
+		}
+		if res.TotalRules == 0 {
+			t.Fatalf("%s: the rule was not counted", name)
+		}
+	}
+
+	// The scheme of an assignment replaces the standard's.
+	svc := NewCheckService()
+	svc.FileName = "ВКР Иванов.docx"
+	svc.FileNameRule = &FileNameConfig{Pattern: `(?i)вкр .+\.docx`}
+	_, violations, err := svc.Evaluate(context.Background(), doc, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range violations {
+		if v.RuleType == "file_name" {
+			t.Fatalf("the assignment's scheme was not applied: %+v", v)
+		}
+	}
+
+	if (FileNameConfig{Pattern: "(unclosed"}).Matches("any.docx") != true {
+		t.Fatal("a broken pattern must not reject names")
+	}
+}
+
+func TestObjectivesComparedWithConclusion(t *testing.T) {
+	heading := func(text string) ParsedParagraph {
+		return ParsedParagraph{Text: text, StyleID: "Heading1", Role: "heading"}
+	}
+	body := func(text string) ParsedParagraph { return ParsedParagraph{Text: text, Role: "body"} }
+	item := func(text string) ParsedParagraph { return ParsedParagraph{Text: text, Role: "body", IsListItem: true} }
+	intro := []ParsedParagraph{
+		{Text: "Введение\t3", Role: "toc"},
+		heading("ВВЕДЕНИЕ"),
+		body("Цель работы — разработка системы проверки оформления."),
+		body("Для достижения цели поставлены следующие задачи:"),
+		item("изучить требования ГОСТ 7.32;"),
+		item("проанализировать существующие решения;"),
+		item("разработать алгоритм проверки;"),
+		body("Объект исследования — текстовые документы."),
+		heading("1 Анализ предметной области"),
+		body("Текст главы."),
+	}
+	check := func(conclusion ...ParsedParagraph) []models.Violation {
+		paragraphs := append(append([]ParsedParagraph{}, intro...), heading("ЗАКЛЮЧЕНИЕ"))
+		paragraphs = append(paragraphs, conclusion...)
+		var violations []models.Violation
+		var totalRules int
+		return checkObjectives(append(paragraphs, heading("СПИСОК ЛИТЕРАТУРЫ")), newRuleTrace(&violations, &totalRules))
+	}
+
+	vs := check(body("В ходе работы изучены требования ГОСТ 7.32. Проанализированы существующие решения. Текст без результата."))
+	if len(vs) != 1 {
+		t.Fatalf("expected 1 finding, got %+v", vs)
+	}
+	if v := vs[0]; v.RuleType != "objectives_conclusion_mismatch" || v.Severity != models.SeverityInfo || v.ActualValue != "2" ||
+		*v.Location.ParagraphIndex != 10 || !strings.Contains(v.ExpectedValue, "3") {
+		t.Errorf("unexpected finding: %+v", v)
+	}
+
+	if vs := check(body("В ходе работы:"), item("изучены требования;"), item("проанализированы решения;"), item("разработан алгоритм.")); len(vs) != 0 {
+		t.Errorf("a result per task must pass: %+v", vs)
+	}
+	if vs := check(body("Получены следующие результаты: 1) изучены требования; 2) проанализированы решения; 3) разработан алгоритм.")); len(vs) != 0 {
+		t.Errorf("an inline enumeration must count its items: %+v", vs)
+	}
+
+	intro[3] = body("Задачи работы: изучить требования; проанализировать решения; разработать алгоритм; испытать его.")
+	intro[4], intro[5], intro[6] = body("Текст."), body("Текст."), body("Текст.")
+	if vs := check(body("1) изучены требования;"), body("2) проанализированы решения;"), body("3) разработан алгоритм.")); len(vs) != 1 || vs[0].ActualValue != "3" {
+		t.Errorf("expected 4 tasks against 3 results: %+v", vs)
+	}
+}
+
+func TestSectionProfilesReplaceBodyRules(t *testing.T) {
+	para := func(text string, page int, align string, size float64) ParsedParagraph {
+		return ParsedParagraph{Text: text, Role: "body", PageNumber: page, Alignment: align, FontName: "Times New Roman", FontSizePt: size, FirstLineIndentMm: 12.5}
+	}
+	heading := func(text string, page int) ParsedParagraph {
+		return ParsedParagraph{Text: text, Role: "heading", StyleID: "Heading1", PageNumber: page, Alignment: "center"}
+	}
+	doc := &ParsedDoc{Paragraphs: []ParsedParagraph{
+		para("Министерство науки и высшего образования", 1, "center", 14),
+		para("КУРСОВАЯ РАБОТА", 1, "center", 16),
+		heading("СОДЕРЖАНИЕ", 2),
+		{Text: "Введение\t3", Role: "toc", PageNumber: 2, FontSizePt: 14},
+		heading("ВВЕДЕНИЕ", 3),
+		para("Основной текст работы.", 3, "both", 14),
+		heading("СПИСОК ЛИТЕРАТУРЫ", 4),
+		para("1. Иванов И. И. Книга. – Москва, 2020. – 100 с.", 4, "left", 12),
+		heading("ПРИЛОЖЕНИЕ А", 5),
+		para("Текст приложения.", 5, "left", 12),
+	}}
+	wantParts := []string{partTitlePage, partTitlePage, partTOC, partTOC, partBody, partBody, partBibliography, partBibliography, partAppendices, partAppendices}
+	if parts := documentParts(doc.Paragraphs, ReferencesConfig{}); !reflect.DeepEqual(parts, wantParts) {
+		t.Fatalf("expected parts %v, got %v", wantParts, parts)
+	}
+
+	flagged := func(config string) map[int][]string {
+		_, violations, err := NewCheckService().Evaluate(context.Background(), doc, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := map[int][]string{}
+		for _, v := range violations {
+			if v.Location != nil && v.Location.ParagraphIndex != nil && (v.RuleType == "font_size" || v.RuleType == "alignment") {
+				out[*v.Location.ParagraphIndex] = append(out[*v.Location.ParagraphIndex], v.RuleType)
+			}
+		}
+		return out
+	}
+	base := `"font": {"size": 14}, "paragraph": {"alignment": "justify"}, "scope": {"start_page": 2}`
+	if got := flagged(`{` + base + `}`); !reflect.DeepEqual(got, map[int][]string{9: {"font_size", "alignment"}}) {
+		t.Errorf("without profiles only the appendix must be flagged, got %v", got)
+	}
+	got := flagged(`{` + base + `, "sections": {
+		"title_page": {"font": {"size": 14}, "paragraph": {"alignment": "center"}},
+		"toc": {"font": {"size": 14}},
+		"bibliography": {"font": {"size": 14}, "paragraph": {"alignment": "left"}},
+		"appendices": {"font": {"size": 12}, "paragraph": {"alignment": "left"}}}}`)
+	if want := map[int][]string{1: {"font_size"}, 7: {"font_size"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v with profiles, got %v", want, got)
+	}
+}
+
+func TestCustomRules(t *testing.T) {
+	doc := &ParsedDoc{Paragraphs: []ParsedParagraph{
+		{Text: "1 Анализ", Role: "heading", StyleID: "Heading1", PageNumber: 2},
+		{Text: "Метод выбран, т.к. он проще, т. к. быстрее.", Role: "body", PageNumber: 2},
+		{Text: "Обзор решений", Role: "heading", StyleID: "Heading1", PageNumber: 3},
+		{Text: "Таблица 1 – Т.к. в подписи", Role: "table_caption", PageNumber: 3},
+		{Text: "СПИСОК ЛИТЕРАТУРЫ", Role: "heading", StyleID: "Heading1", PageNumber: 4},
+		{Text: "1. Иванов И. И. Книга. – М., 2020.", Role: "body", PageNumber: 4},
+	}}
+	config := `{"custom_rules": [
+		{"name": "tk", "pattern": "(?i)т\\.\\s?к\\.", "message": "Сокращение «т. к.» не допускается"},
+		{"name": "numbered_headings", "pattern": "^\\d+(\\.\\d+)*\\s", "scope": "headings", "require": true, "severity": "error"},
+		{"name": "city", "pattern": "– М\\.,", "scope": "references", "severity": "hint"}]}`
+	_, violations, err := NewCheckService().Evaluate(context.Background(), doc, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range violations {
+		if strings.HasPrefix(v.RuleType, customRulePrefix) {
+			got = append(got, fmt.Sprintf("%s@%d:%s:%s", v.RuleType, *v.Location.ParagraphIndex, v.Severity, v.ActualValue))
+		}
+	}
+	want := []string{
+		"custom:tk@1:warning:«т.к.»",
+		"custom:tk@1:warning:«т. к.»",
+		"custom:numbered_headings@2:error:Не соответствует",
+		"custom:numbered_headings@4:error:Не соответствует",
+		"custom:city@5:hint:«– М.,»",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	for _, rules := range [][]CustomRule{
+		{{Name: "a", Pattern: "(unclosed"}},
+		{{Name: "a", Pattern: "x"}, {Name: "a", Pattern: "y"}},
+		{{Name: "a", Pattern: "x", Scope: "captions"}},
+		{{Name: "a", Pattern: "x", Severity: "fatal"}},
+		{{Pattern: "x"}},
+	} {
+		if ValidateCustomRules(rules) == nil {
+			t.Errorf("%+v must be refused", rules)
+		}
+	}
+	if err := ValidateCustomRules([]CustomRule{{Name: "a", Pattern: "x", Scope: "all", Severity: "info"}}); err != nil {
+		t.Errorf("a valid rule was refused: %v", err)
+	}
+}
+
+// headingLimitModule is a rule module counting two rules: the headings of
+// the document and the length of each.
+type headingLimitModule struct {
+	Max    int `json:"max"`
+	MaxLen int `json:"max_len"`
+}
+
+func (m *headingLimitModule) Name() string { return "heading_limit" }
+
+func (m *headingLimitModule) Configure(config json.RawMessage) error {
+	if err := json.Unmarshal(config, m); err != nil {
+		return err
+	}
+	if m.Max <= 0 {
+		return fmt.Errorf("max must be positive")
+	}
+	return nil
+}
+
+func (m *headingLimitModule) Check(doc *ParsedDoc) []models.Violation {
+	var vs []models.Violation
+	headings := 0
+	for _, p := range doc.Paragraphs {
+		if p.Role != "heading" {
+			continue
+		}
+		headings++
+		if m.MaxLen > 0 && utf8.RuneCountInString(p.Text) > m.MaxLen {
+			vs = append(vs, models.Violation{RuleType: "heading_limit_length", ActualValue: p.Text, Severity: "error"})
+		}
+	}
+	if headings > m.Max {
+		vs = append(vs, models.Violation{ActualValue: fmt.Sprint(headings)})
+	}
+	return vs
+}
+
+func (m *headingLimitModule) Rules() int { return 2 }
+
+func TestRuleModules(t *testing.T) {
+	RegisterModule(func() RuleModule { return &headingLimitModule{} })
+	for _, factory := range []func() RuleModule{
+		func() RuleModule { return &headingLimitModule{} },
+		func() RuleModule { return moduleNamed("margins") },
+		func() RuleModule { return moduleNamed("") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q must panic", factory().Name())
+				}
+			}()
+			RegisterModule(factory)
+		}()
+	}
+
+	doc := &ParsedDoc{Paragraphs: []ParsedParagraph{
+		{Text: "Введение", Role: "heading", StyleID: "Heading1"},
+		{Text: "Текст", Role: "body"},
+		{Text: "Анализ существующих решений в предметной области", Role: "heading", StyleID: "Heading1"},
+	}}
+	config := `{"modules": {"heading_limit": {"max": 1, "max_len": 20}, "missing": {}},
+		"severity_overrides": {"heading_limit": "hint"}}`
+	res, violations, err := NewCheckService().Evaluate(context.Background(), doc, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range violations {
+		if strings.HasPrefix(v.RuleType, "heading_limit") {
+			got = append(got, v.RuleType+":"+v.Severity+":"+v.ActualValue)
+		}
+	}
+	want := []string{"heading_limit_length:error:Анализ существующих решений в предметной области", "heading_limit:hint:2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	groups := map[string]models.RuleTrace{}
+	for _, g := range res.RuleTrace {
+		groups[g.Rule] = g
+	}
+	if g := groups["heading_limit"]; g.Status != models.TraceFailed || g.Rules != 2 || g.Violations != 2 {
+		t.Errorf("unexpected trace of the module: %+v", g)
+	}
+	if g := groups["missing"]; g.Status != models.TraceSkipped || g.Reason == "" {
+		t.Errorf("an unknown module must be skipped: %+v", g)
+	}
+
+	res, _, err = NewCheckService().Evaluate(context.Background(), doc, `{"modules": {"heading_limit": {"max": 0}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.TotalRules != 0 {
+		t.Errorf("a module refusing its settings must not count rules, got %d", res.TotalRules)
+	}
+	if names := Modules(); names[0] != "margins" || names[len(names)-1] != "heading_limit" {
+		t.Errorf("built-in modules must run first, custom ones after them: %v", names)
+	}
+
+	// Built-in groups are modules too: a standard turns them off or gives
+	// one group settings of its own.
+	doc.Margins = Margins{LeftMm: 20, RightMm: 15}
+	margins := func(config string) (int, models.RuleTrace) {
+		res, violations, err := NewCheckService().Evaluate(context.Background(), doc, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, v := range violations {
+			if v.RuleType == "margin_left" {
+				n++
+			}
+		}
+		for _, g := range res.RuleTrace {
+			if g.Rule == "margins" {
+				return n, g
+			}
+		}
+		return n, models.RuleTrace{}
+	}
+	if n, _ := margins(`{"margins": {"left": 30, "tolerance": 1}}`); n != 1 {
+		t.Errorf("expected the left margin to be flagged, got %d", n)
+	}
+	if n, g := margins(`{"margins": {"left": 30, "tolerance": 1}, "modules": {"margins": false}}`); n != 0 || g.Status != models.TraceSkipped {
+		t.Errorf("a module turned off must not run: %d violations, trace %+v", n, g)
+	}
+	if n, _ := margins(`{"modules": {"margins": {"margins": {"left": 30, "tolerance": 1}}}}`); n != 1 {
+		t.Errorf("the settings of a built-in module must apply to its group, got %d", n)
+	}
+	if n, _ := margins(`{"margins": {"left": 30, "tolerance": 1}, "modules": {"margins": {"margins": {"left": 20}}}}`); n != 0 {
+		t.Errorf("the settings of a built-in module must replace those of the standard, got %d", n)
+	}
+
+	for _, modules := range []string{`{"missing": {}}`, `{"heading_limit": {"max": 0}}`, `{"heading_limit": {"max": "x"}}`, `{"margins": {"margins": []}}`} {
+		var config map[string]json.RawMessage
+		json.Unmarshal([]byte(modules), &config)
+		if ValidateModules(config) == nil {
+			t.Errorf("%s must be refused", modules)
+		}
+	}
+	if err := ValidateModules(map[string]json.RawMessage{"heading_limit": json.RawMessage(`{"max": 3}`), "repetition": json.RawMessage(`false`)}); err != nil {
+		t.Errorf("a valid module was refused: %v", err)
+	}
+}
+
+// moduleNamed is a rule module that only has a name.
+type moduleNamed string
+
+func (m moduleNamed) Name() string                            { return string(m) }
+func (m moduleNamed) Configure(config json.RawMessage) error  { return nil }
+func (m moduleNamed) Check(doc *ParsedDoc) []models.Violation { return nil }
@@ -0,0 +1,150 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// CustomRule is a requirement of the institution described by a regular
+// expression instead of code, e.g. «в тексте не используется "т.к."» or
+// «заголовки разделов начинаются с номера».
+type CustomRule struct {
+	Name    string `json:"name"`    // reported as the rule type "custom:<name>"
+	Pattern string `json:"pattern"` // Go regular expression, e.g. `(?i)т\.\s?к\.`
+	// Scope is the paragraphs the rule applies to: body (default), headings,
+	// references or all.
+	Scope    string `json:"scope"`
+	Severity string `json:"severity"` // default warning
+	Message  string `json:"message"`  // shown as the description
+	// Require reports the paragraphs that do not match instead of the
+	// matches.
+	Require bool `json:"require"`
+}
+
+// Scopes of custom rules.
+const (
+	customScopeBody       = "body"
+	customScopeHeadings   = "headings"
+	customScopeReferences = "references"
+	customScopeAll        = "all"
+)
+
+// customRulePrefix starts the rule type of custom rule violations.
+const customRulePrefix = "custom:"
+
+// ValidateCustomRules reports the first rule a check could not apply: one
+// without a name or with a repeated one, or with a pattern, scope or
+// severity that is not valid.
+func ValidateCustomRules(rules []CustomRule) error {
+	seen := map[string]bool{}
+	for i, r := range rules {
+		name := strings.TrimSpace(r.Name)
+		if name == "" {
+			return fmt.Errorf("custom rule %d has no name", i+1)
+		}
+		if seen[name] {
+			return fmt.Errorf("custom rule %q is defined twice", name)
+		}
+		seen[name] = true
+		if strings.TrimSpace(r.Pattern) == "" {
+			return fmt.Errorf("custom rule %q has no pattern", name)
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("custom rule %q: invalid pattern: %v", name, err)
+		}
+		switch r.Scope {
+		case "", customScopeBody, customScopeHeadings, customScopeReferences, customScopeAll:
+		default:
+			return fmt.Errorf("custom rule %q: unknown scope %q (body, headings, references, all)", name, r.Scope)
+		}
+		if r.Severity != "" && !models.IsValidSeverity(r.Severity) {
+			return fmt.Errorf("custom rule %q: unknown severity %q", name, r.Severity)
+		}
+	}
+	return nil
+}
+
+// checkCustomRules applies the custom rules to the paragraphs in their scope
+// from startPage on. Each rule counts once per paragraph it is applied to.
+// Rules that cannot be applied are skipped and noted in the trace.
+func checkCustomRules(paragraphs []ParsedParagraph, rules []CustomRule, refs ReferencesConfig, startPage int, trace *ruleTrace) ([]models.Violation, int) {
+	var vs []models.Violation
+	totalRules := 0
+	parts := documentParts(paragraphs, refs)
+	for _, r := range rules {
+		name := strings.TrimSpace(r.Name)
+		re, err := regexp.Compile(r.Pattern)
+		if name == "" || err != nil || strings.TrimSpace(r.Pattern) == "" {
+			trace.skip("custom_rules", fmt.Sprintf("Правило «%s» не применено: шаблон с ошибкой", name))
+			continue
+		}
+		severity := r.Severity
+		if !models.IsValidSeverity(severity) {
+			severity = models.SeverityWarning
+		}
+		description := strings.TrimSpace(r.Message)
+		if description == "" {
+			description = "Нарушено правило «" + name + "»"
+		}
+
+		for i, p := range paragraphs {
+			text := strings.TrimSpace(p.Text)
+			if text == "" || (startPage > 1 && p.PageNumber < startPage) || !inCustomScope(p, parts[i], r.Scope) {
+				continue
+			}
+			totalRules++
+			v := models.Violation{
+				RuleType:      customRulePrefix + name,
+				Description:   description,
+				Severity:      severity,
+				PositionInDoc: fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(text, 100)),
+				ContextText:   contextSnippet(p.Text),
+				Location:      paragraphLocation(i, p),
+			}
+			if r.Require {
+				if re.MatchString(p.Text) {
+					continue
+				}
+				v.ExpectedValue = "Соответствие правилу «" + name + "»"
+				v.ActualValue = "Не соответствует"
+				vs = append(vs, v)
+				continue
+			}
+			for _, m := range re.FindAllStringIndex(p.Text, -1) {
+				if m[0] == m[1] {
+					continue // empty matches find nothing to point at
+				}
+				mv := v
+				loc := *v.Location
+				loc.CharStart = intPtr(utf8.RuneCountInString(p.Text[:m[0]]))
+				loc.CharEnd = intPtr(utf8.RuneCountInString(p.Text[:m[1]]))
+				mv.Location = &loc
+				mv.ExpectedValue = "Без совпадений с правилом «" + name + "»"
+				mv.ActualValue = "«" + truncate(p.Text[m[0]:m[1]], 50) + "»"
+				vs = append(vs, mv)
+			}
+		}
+	}
+	return vs, totalRules
+}
+
+// inCustomScope reports whether a paragraph of a part of the document is in
+// the scope of a custom rule. Body text leaves out the headings, the table
+// of contents, captions, formulas and the bibliography; references are the
+// entries of the bibliography.
+func inCustomScope(p ParsedParagraph, part, scope string) bool {
+	heading := p.Role != "toc" && isHeadingParagraph(p)
+	switch scope {
+	case customScopeAll:
+		return true
+	case customScopeHeadings:
+		return heading
+	case customScopeReferences:
+		return part == partBibliography && !heading
+	default:
+		return !heading && part != partBibliography && part != partTitlePage && shouldCheckBodyFormatting(p, false)
+	}
+}
@@ -0,0 +1,132 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Defaults are the tolerances and caption keywords a standard inherits for the
+// settings it leaves empty. The server keeps deployment-wide values edited by
+// administrators; BuiltinDefaults applies when none are set.
+type Defaults struct {
+	MarginToleranceMm    float64 `json:"margin_tolerance_mm"`
+	FontSizeTolerancePt  float64 `json:"font_size_tolerance_pt"`
+	LineSpacingTolerance float64 `json:"line_spacing_tolerance"` // in lines
+	IndentToleranceMm    float64 `json:"indent_tolerance_mm"`
+	TableCaptionKeyword  string  `json:"table_caption_keyword"`
+	FigureCaptionKeyword string  `json:"figure_caption_keyword"`
+
+	HeaderFooterToleranceMm  float64 `json:"header_footer_tolerance_mm"`
+	CaptionIndentToleranceMm float64 `json:"caption_indent_tolerance_mm"`
+	ListIndentToleranceMm    float64 `json:"list_indent_tolerance_mm"`
+	FootnoteSizeTolerancePt  float64 `json:"footnote_size_tolerance_pt"`
+	CodeSizeTolerancePt      float64 `json:"code_size_tolerance_pt"`
+	CodeLineSpacingTolerance float64 `json:"code_line_spacing_tolerance"` // in lines
+	CodeIndentToleranceMm    float64 `json:"code_indent_tolerance_mm"`
+}
+
+// BuiltinDefaults absorb the rounding of values Word stores in twips and
+// 240ths of a line, and the usual 1.25 cm vs 1.27 cm indent.
+var BuiltinDefaults = Defaults{
+	MarginToleranceMm:    2.0,
+	FontSizeTolerancePt:  0.75,
+	LineSpacingTolerance: 0.2,
+	IndentToleranceMm:    4.0,
+	TableCaptionKeyword:  "Таблица",
+	FigureCaptionKeyword: "Рисунок",
+
+	HeaderFooterToleranceMm:  2.0,
+	CaptionIndentToleranceMm: 2.0,
+	ListIndentToleranceMm:    2.0,
+	FootnoteSizeTolerancePt:  0.5,
+	CodeSizeTolerancePt:      0.5,
+	CodeLineSpacingTolerance: 0.15,
+	CodeIndentToleranceMm:    3.0,
+}
+
+// Validate checks that the tolerances are positive and within sane bounds
+// and that the keywords are set.
+func (d Defaults) Validate() error {
+	for _, t := range []struct {
+		name  string
+		value float64
+		max   float64
+	}{
+		{"margin_tolerance_mm", d.MarginToleranceMm, 20},
+		{"font_size_tolerance_pt", d.FontSizeTolerancePt, 10},
+		{"line_spacing_tolerance", d.LineSpacingTolerance, 2},
+		{"indent_tolerance_mm", d.IndentToleranceMm, 20},
+		{"header_footer_tolerance_mm", d.HeaderFooterToleranceMm, 20},
+		{"caption_indent_tolerance_mm", d.CaptionIndentToleranceMm, 20},
+		{"list_indent_tolerance_mm", d.ListIndentToleranceMm, 20},
+		{"footnote_size_tolerance_pt", d.FootnoteSizeTolerancePt, 10},
+		{"code_size_tolerance_pt", d.CodeSizeTolerancePt, 10},
+		{"code_line_spacing_tolerance", d.CodeLineSpacingTolerance, 2},
+		{"code_indent_tolerance_mm", d.CodeIndentToleranceMm, 20},
+	} {
+		if t.value <= 0 || t.value > t.max {
+			return fmt.Errorf("%s must be greater than 0 and at most %g", t.name, t.max)
+		}
+	}
+	for name, keyword := range map[string]string{
+		"table_caption_keyword":  d.TableCaptionKeyword,
+		"figure_caption_keyword": d.FigureCaptionKeyword,
+	} {
+		if strings.TrimSpace(keyword) == "" || utf8.RuneCountInString(keyword) > 50 {
+			return fmt.Errorf("%s must be 1 to 50 characters", name)
+		}
+	}
+	return nil
+}
+
+// inherit fills the tolerances and caption keywords the standard leaves
+// empty from d. Caption keywords are kept apart from the configured ones,
+// since a configured keyword turns the figure checks on.
+func (config *ConfigSchema) inherit(d Defaults) {
+	if config.Margins.Tolerance == 0 {
+		config.Margins.Tolerance = d.MarginToleranceMm
+	}
+	if config.Font.SizeTolerance == 0 {
+		config.Font.SizeTolerance = d.FontSizeTolerancePt
+	}
+	if config.Paragraph.LineSpacingTolerance == 0 {
+		config.Paragraph.LineSpacingTolerance = d.LineSpacingTolerance
+	}
+	if config.Paragraph.IndentTolerance == 0 {
+		config.Paragraph.IndentTolerance = d.IndentToleranceMm
+	}
+	if config.Headings.SizeTolerance == 0 {
+		config.Headings.SizeTolerance = config.Font.SizeTolerance
+	}
+	inheritTolerance(&config.HeaderFooter.Tolerance, d.HeaderFooterToleranceMm)
+	inheritTolerance(&config.Tables.CaptionIndentTolerance, d.CaptionIndentToleranceMm)
+	inheritTolerance(&config.Images.CaptionIndentTolerance, d.CaptionIndentToleranceMm)
+	inheritTolerance(&config.Lists.IndentTolerance, d.ListIndentToleranceMm)
+	inheritTolerance(&config.Footnotes.SizeTolerance, d.FootnoteSizeTolerancePt)
+	inheritTolerance(&config.CodeBlocks.SizeTolerance, d.CodeSizeTolerancePt)
+	inheritTolerance(&config.CodeBlocks.LineSpacingTolerance, d.CodeLineSpacingTolerance)
+	inheritTolerance(&config.CodeBlocks.IndentTolerance, d.CodeIndentToleranceMm)
+	config.Sections.inherit(config.Font, config.Paragraph)
+	config.Tables.defaultKeyword = d.TableCaptionKeyword
+	config.Images.defaultKeyword = d.FigureCaptionKeyword
+}
+
+// inheritTolerance sets a tolerance the standard leaves empty to d.
+func inheritTolerance(tolerance *float64, d float64) {
+	if *tolerance == 0 {
+		*tolerance = d
+	}
+}
+
+// captionKeyword returns the configured keyword, else the inherited one, else
+// builtin.
+func captionKeyword(configured, inherited, builtin string) string {
+	if keyword := strings.TrimSpace(configured); keyword != "" {
+		return keyword
+	}
+	if inherited != "" {
+		return inherited
+	}
+	return builtin
+}
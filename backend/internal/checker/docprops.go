@@ -0,0 +1,244 @@
+package checker
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DocProperties are the properties an editor saves with the document:
+// docProps/core.xml and docProps/app.xml of DOCX, meta.xml of ODT. Every field
+// is optional; converters often leave them empty.
+type DocProperties struct {
+	Title          string
+	Author         string // who created the document
+	LastModifiedBy string
+	Created        time.Time // zero when unknown
+	Modified       time.Time
+	Application    string // e.g. "Microsoft Office Word", "LibreOffice/7.6.4.1$Linux_X86_64"
+	AppVersion     string
+	EditingMinutes int // total editing time
+	// Statistics the editor saved with the file; 0 when missing
+	Pages      int
+	Words      int
+	Characters int
+}
+
+// corePropsXML is docProps/core.xml. Names are matched by local name, as the
+// part mixes the dc, dcterms and cp namespaces.
+type corePropsXML struct {
+	Title          string `xml:"title"`
+	Creator        string `xml:"creator"`
+	LastModifiedBy string `xml:"lastModifiedBy"`
+	Created        string `xml:"created"`
+	Modified       string `xml:"modified"`
+}
+
+// appPropsXML is docProps/app.xml.
+type appPropsXML struct {
+	Application string `xml:"Application"`
+	AppVersion  string `xml:"AppVersion"`
+	TotalTime   int    `xml:"TotalTime"` // minutes
+	Pages       int    `xml:"Pages"`
+	Words       int    `xml:"Words"`
+	Characters  int    `xml:"Characters"`
+}
+
+// parseProperties fills pd.Properties from docProps. Missing or broken parts
+// leave the fields empty.
+func (p *DocParser) parseProperties(r *zip.Reader, pd *ParsedDoc) {
+	for _, f := range r.File {
+		switch f.Name {
+		case "docProps/core.xml":
+			var core corePropsXML
+			if data, err := readEntryLimited(f, p.Limits, 0); err == nil && xml.Unmarshal(data, &core) == nil {
+				pd.Properties.Title = strings.TrimSpace(core.Title)
+				pd.Properties.Author = strings.TrimSpace(core.Creator)
+				pd.Properties.LastModifiedBy = strings.TrimSpace(core.LastModifiedBy)
+				pd.Properties.Created = parsePropertyTime(core.Created)
+				pd.Properties.Modified = parsePropertyTime(core.Modified)
+			}
+		case "docProps/app.xml":
+			var app appPropsXML
+			if data, err := readEntryLimited(f, p.Limits, 0); err == nil && xml.Unmarshal(data, &app) == nil {
+				pd.Properties.Application = strings.TrimSpace(app.Application)
+				pd.Properties.AppVersion = strings.TrimSpace(app.AppVersion)
+				pd.Properties.EditingMinutes = app.TotalTime
+				pd.Properties.Pages = app.Pages
+				pd.Properties.Words = app.Words
+				pd.Properties.Characters = app.Characters
+			}
+		}
+	}
+}
+
+// odtProperties reads the office:meta element of meta.xml.
+func odtProperties(root *odfNode) DocProperties {
+	meta := root.child("meta")
+	if meta == nil {
+		return DocProperties{}
+	}
+	stats := meta.child("document-statistic")
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	return DocProperties{
+		Title:          meta.child("title").textContent(),
+		Author:         meta.child("initial-creator").textContent(),
+		LastModifiedBy: meta.child("creator").textContent(),
+		Created:        parsePropertyTime(meta.child("creation-date").textContent()),
+		Modified:       parsePropertyTime(meta.child("date").textContent()),
+		Application:    meta.child("generator").textContent(),
+		EditingMinutes: isoDurationMinutes(meta.child("editing-duration").textContent()),
+		Pages:          atoi(stats.attr("page-count")),
+		Words:          atoi(stats.attr("word-count")),
+		Characters:     atoi(stats.attr("character-count")),
+	}
+}
+
+// parsePropertyTime parses the W3CDTF dates of core.xml and the zone-less
+// dates of ODF meta.xml; it returns the zero time for anything else.
+func parsePropertyTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var isoDurationRe = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)(?:\.\d+)?S)?)?$`)
+
+// isoDurationMinutes converts an ISO 8601 duration such as "PT1H25M10S" to
+// whole minutes; unknown forms give 0.
+func isoDurationMinutes(s string) int {
+	m := isoDurationRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0
+	}
+	n := func(i int) int {
+		v, _ := strconv.Atoi(m[i])
+		return v
+	}
+	return n(1)*24*60 + n(2)*60 + n(3) + n(4)/60
+}
+
+// DocPropertiesConfig enables the rules on the document properties. They
+// screen for works that were not written by the student in an editor, so
+// findings have models.SeverityIntegrity like the other integrity rules.
+type DocPropertiesConfig struct {
+	// CheckAuthor compares the author of the document with the name of the
+	// student who submits it; a check without a student name skips it.
+	CheckAuthor bool `json:"check_author"`
+	// ForbidConverters flags documents created by online and PDF converters.
+	ForbidConverters bool `json:"forbid_converters"`
+	// Converters are more application names to flag, added to the built-in
+	// list; they match a part of the name, ignoring case.
+	Converters []string `json:"converters"`
+	// MinEditingMinutes flags a total editing time below it; 0 disables.
+	MinEditingMinutes int `json:"min_editing_minutes"`
+}
+
+// converterApplications are parts of application and author names written by
+// converters from PDF and online conversion services.
+var converterApplications = []string{
+	"aspose", "docx4j", "ilovepdf", "smallpdf", "convertio", "cloudconvert",
+	"zamzar", "online2pdf", "pdf2go", "pdf2docx", "pdf to word", "pdfelement",
+	"wondershare", "abbyy", "finereader", "solid converter", "nitro pro",
+	"foxit", "adobe acrobat", "readiris",
+}
+
+// checkDocProperties checks the properties of the document against cfg.
+// studentName is the name the work is submitted under.
+func checkDocProperties(doc *ParsedDoc, cfg DocPropertiesConfig, studentName string) []models.Violation {
+	var vs []models.Violation
+	props := doc.Properties
+	add := func(v models.Violation) {
+		v.PositionInDoc = "Свойства документа"
+		v.Severity = models.SeverityIntegrity
+		vs = append(vs, v)
+	}
+
+	if cfg.CheckAuthor && strings.TrimSpace(studentName) != "" && props.Author != "" && !authorMatches(props.Author, studentName) {
+		actual := props.Author
+		if props.LastModifiedBy != "" && props.LastModifiedBy != props.Author {
+			actual += fmt.Sprintf(" (последним изменял: %s)", props.LastModifiedBy)
+		}
+		add(models.Violation{
+			RuleType:      "doc_properties_author",
+			Description:   "Автор документа не совпадает с обучающимся",
+			ExpectedValue: studentName,
+			ActualValue:   actual,
+			Suggestion:    "Документ создан под другим именем; уточните у обучающегося происхождение работы",
+		})
+	}
+
+	if cfg.ForbidConverters {
+		names := append(append([]string{}, converterApplications...), cfg.Converters...)
+		for _, field := range []string{props.Application, props.Author, props.LastModifiedBy} {
+			if name := matchConverter(field, names); name != "" {
+				add(models.Violation{
+					RuleType:      "doc_properties_converter",
+					Description:   "Документ создан конвертером",
+					ExpectedValue: "Текстовый редактор",
+					ActualValue:   field,
+					Suggestion:    "Документ получен преобразованием из PDF или онлайн-сервисом; форматирование может быть искажено, а работа — заимствована",
+				})
+				break
+			}
+		}
+	}
+
+	if cfg.MinEditingMinutes > 0 && props.Application != "" && props.EditingMinutes < cfg.MinEditingMinutes {
+		add(models.Violation{
+			RuleType:      "doc_properties_editing_time",
+			Description:   "Слишком малое время редактирования документа",
+			ExpectedValue: fmt.Sprintf("не менее %d мин", cfg.MinEditingMinutes),
+			ActualValue:   fmt.Sprintf("%d мин", props.EditingMinutes),
+			Suggestion:    "Текст мог быть вставлен целиком из другого файла; время редактирования сбрасывается и при «Сохранить как», поэтому это лишь повод присмотреться",
+			IsDoubtful:    true,
+		})
+	}
+	return vs
+}
+
+// authorMatches reports whether author shares a name with studentName:
+// "Иванов И. И." matches "Иванов Иван Иванович". Initials are not compared.
+func authorMatches(author, studentName string) bool {
+	words := func(s string) []string {
+		s = strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+		return strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
+	}
+	authorWords := map[string]bool{}
+	for _, w := range words(author) {
+		authorWords[w] = true
+	}
+	for _, w := range words(studentName) {
+		if len([]rune(w)) > 2 && authorWords[w] {
+			return true
+		}
+	}
+	return false
+}
+
+// matchConverter returns the entry of names found in value, ignoring case.
+func matchConverter(value string, names []string) string {
+	value = strings.ToLower(value)
+	if value == "" {
+		return ""
+	}
+	for _, name := range names {
+		if n := strings.ToLower(strings.TrimSpace(name)); n != "" && strings.Contains(value, n) {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,761 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// evaluation is the state of one check of a parsed document: the standard
+// it is checked against and what the rule groups found so far.
+type evaluation struct {
+	s      *CheckService
+	doc    *ParsedDoc
+	config ConfigSchema
+	trace  *ruleTrace
+	clock  *ruleClock
+
+	violations []models.Violation
+	totalRules int
+}
+
+// ruleGroups are the built-in rule groups in the order a check runs them,
+// each registered as a RuleModule of the same name. A group adds its
+// violations and the number of rules it checked; the clock and the trace
+// attribute both to the group's name.
+var ruleGroups = []struct {
+	name  string
+	check func(*evaluation)
+}{
+	{"margins", (*evaluation).margins},
+	{"page_setup", (*evaluation).pageSetup},
+	{"header_footer", (*evaluation).headerFooter},
+	{"tables", (*evaluation).tables},
+	{"images", (*evaluation).images},
+	{"formulas", (*evaluation).formulas},
+	{"references", (*evaluation).references},
+	{"toc_sequence", (*evaluation).tocSequence},
+	{"lists", (*evaluation).lists},
+	{"footnotes", (*evaluation).footnotes},
+	{"paragraphs", (*evaluation).paragraphs},
+	{"doc_length", (*evaluation).docLength},
+	{"introduction", (*evaluation).introduction},
+	{"section_order", (*evaluation).sectionOrder},
+	{"pagination", (*evaluation).pagination},
+	{"hyphenation", (*evaluation).hyphenation},
+	{"revisions", (*evaluation).revisions},
+	{"article", (*evaluation).article},
+	{"repetition", (*evaluation).repetition},
+	{"custom_rules", (*evaluation).customRules},
+	{"objectives", (*evaluation).objectives},
+	{"integrity", (*evaluation).integrity},
+	{"doc_properties", (*evaluation).docProperties},
+	{"file_name", (*evaluation).fileName},
+}
+
+// margins checks the page margins, per section when the document has several.
+func (e *evaluation) margins() {
+	e.trace.applied("margins", "margins", e.config.Margins)
+	e.trace.read("margins", "margins_mm", e.doc.Margins)
+	e.trace.read("margins", "sections", len(e.doc.Sections))
+	if len(e.doc.Sections) > 1 {
+		vSections, sectionRules := checkSectionPages(e.doc, e.config.Margins, e.config.PageSetup)
+		e.violations = append(e.violations, vSections...)
+		e.totalRules += sectionRules
+	} else {
+		e.violations = append(e.violations, checkMargins(e.doc.Margins, e.config.Margins)...)
+		// Count only configured margin fields
+		e.totalRules += configuredMargins(e.config.Margins)
+	}
+}
+
+// pageSetup checks the page orientation.
+func (e *evaluation) pageSetup() {
+	e.trace.applied("page_setup", "page_setup", e.config.PageSetup)
+	if e.config.PageSetup.Orientation != "" && e.doc.PageSize.Orientation == "" {
+		e.trace.skip("page_setup", "Ориентация страницы не найдена в документе")
+	}
+	if e.config.PageSetup.Orientation != "" && e.doc.PageSize.Orientation != "" {
+		e.trace.read("page_setup", "orientation", e.doc.PageSize.Orientation)
+		e.totalRules++
+		if e.config.PageSetup.Orientation != e.doc.PageSize.Orientation {
+			e.violations = append(e.violations, models.Violation{
+				RuleType: "page_orientation", Description: "Incorrect Page Orientation",
+				ExpectedValue: e.config.PageSetup.Orientation, ActualValue: e.doc.PageSize.Orientation, Severity: "error",
+			})
+		}
+	}
+}
+
+// headerFooter checks the header and footer distances and contents.
+func (e *evaluation) headerFooter() {
+	e.trace.applied("header_footer", "header_footer", e.config.HeaderFooter)
+	if e.config.HeaderFooter.HeaderDist > 0 || e.config.HeaderFooter.FooterDist > 0 {
+		e.trace.read("header_footer", "header_mm", e.doc.Margins.HeaderMm)
+		e.trace.read("header_footer", "footer_mm", e.doc.Margins.FooterMm)
+	}
+	if e.config.HeaderFooter.HeaderDist > 0 && math.Abs(e.doc.Margins.HeaderMm-e.config.HeaderFooter.HeaderDist) > e.config.HeaderFooter.Tolerance {
+		e.totalRules++
+		e.violations = append(e.violations, withValues(models.Violation{
+			RuleType: "header_dist", Description: "Incorrect Header Distance", Severity: "error",
+		}, models.UnitMillimeter, e.config.HeaderFooter.HeaderDist, e.doc.Margins.HeaderMm))
+	} else if e.config.HeaderFooter.HeaderDist > 0 {
+		e.totalRules++
+	}
+
+	if e.config.HeaderFooter.FooterDist > 0 && math.Abs(e.doc.Margins.FooterMm-e.config.HeaderFooter.FooterDist) > e.config.HeaderFooter.Tolerance {
+		e.totalRules++
+		e.violations = append(e.violations, withValues(models.Violation{
+			RuleType: "footer_dist", Description: "Incorrect Footer Distance", Severity: "error",
+		}, models.UnitMillimeter, e.config.HeaderFooter.FooterDist, e.doc.Margins.FooterMm))
+	} else if e.config.HeaderFooter.FooterDist > 0 {
+		e.totalRules++
+	}
+
+	hfViolations, hfRules := checkHeadersFooters(e.doc, e.config.HeaderFooter)
+	e.violations = append(e.violations, hfViolations...)
+	e.totalRules += hfRules
+}
+
+// tables checks the tables, their captions and, when asked, the text of their cells.
+func (e *evaluation) tables() {
+	e.trace.applied("tables", "tables", e.config.Tables)
+	e.trace.read("tables", "tables", len(e.doc.Tables))
+	if len(e.doc.Tables) == 0 {
+		e.trace.skip("tables", "В документе нет таблиц")
+	}
+	tblViolations, tblRules := checkTables(e.doc.Tables, e.doc.Paragraphs, e.config.Tables, e.config.Scope.StartPage)
+	e.violations = append(e.violations, tblViolations...)
+	e.totalRules += tblRules
+	if e.config.Tables.CheckCellContent {
+		cellViolations, cellRules := checkTableCells(e.doc.Tables, e.config.Font, e.config.Tables, e.config.Scope.StartPage)
+		e.violations = append(e.violations, cellViolations...)
+		e.totalRules += cellRules
+	}
+}
+
+// images checks the figures and their captions.
+func (e *evaluation) images() {
+	e.trace.applied("images", "images", e.config.Images)
+	e.trace.read("images", "images", len(e.doc.Images))
+	if len(e.doc.Images) == 0 {
+		e.trace.skip("images", "В документе нет рисунков")
+	}
+	imgViolations, imgRules := checkImages(e.doc.Images, e.doc.Paragraphs, e.config.Images, e.config.Scope.StartPage)
+	e.violations = append(e.violations, imgViolations...)
+	e.totalRules += imgRules
+}
+
+// formulas checks the formulas, their numbering and the text around them.
+func (e *evaluation) formulas() {
+	e.trace.applied("formulas", "formulas", e.config.Formulas)
+	e.trace.applied("formulas", "body_font_size_pt", e.config.Font.Size)
+	e.trace.read("formulas", "formulas", len(e.doc.Formulas))
+	if len(e.doc.Formulas) == 0 {
+		e.trace.skip("formulas", "В документе нет формул")
+	}
+	fmViolations, fmRules := checkFormulas(e.doc.Formulas, e.doc.Paragraphs, e.config.Formulas)
+	e.violations = append(e.violations, fmViolations...)
+	e.totalRules += fmRules
+	fcViolations, fcRules := checkFormulaContent(e.doc.Formulas, e.doc.Paragraphs, e.config.Formulas, e.config.Font)
+	e.violations = append(e.violations, fcViolations...)
+	e.totalRules += fcRules
+}
+
+// references checks the bibliography.
+func (e *evaluation) references() {
+	if e.config.References.Required || e.config.References.CheckSourceAge || e.config.References.CheckEntryFormat || e.config.References.CheckCitations {
+		e.trace.applied("references", "references", e.config.References)
+		refViolations, refRules := checkReferences(e.doc.Paragraphs, e.config.References)
+		e.violations = append(e.violations, refViolations...)
+		e.totalRules += refRules
+	}
+}
+
+// tocSequence checks the table of contents against the headings.
+func (e *evaluation) tocSequence() {
+	if e.config.Structure.VerifyTOC {
+		e.trace.read("toc_sequence", "pages_estimated", e.doc.Stats.PagesEstimated)
+		tocViolations, tocRules := checkTOCSequence(e.doc.Paragraphs)
+		// Estimated page numbers may be off by a few pages
+		for i := range tocViolations {
+			if e.doc.Stats.PagesEstimated && tocViolations[i].RuleType == "toc_page_mismatch" {
+				tocViolations[i].IsDoubtful = true
+			}
+		}
+		e.violations = append(e.violations, tocViolations...)
+		e.totalRules += tocRules
+	}
+}
+
+// lists checks the markers, punctuation and indents of lists.
+func (e *evaluation) lists() {
+	if e.config.Lists.Enabled {
+		e.trace.applied("lists", "lists", e.config.Lists)
+		listViolations, listRules := checkLists(e.doc.Paragraphs, e.config.Lists, e.config.References, e.config.Scope.StartPage)
+		e.violations = append(e.violations, listViolations...)
+		e.totalRules += listRules
+	}
+}
+
+// footnotes checks the footnotes.
+func (e *evaluation) footnotes() {
+	if e.config.Footnotes.Enabled {
+		e.trace.applied("footnotes", "footnotes", e.config.Footnotes)
+		noteViolations, noteRules := checkFootnotes(e.doc, e.config.Footnotes, e.config.Scope.StartPage)
+		e.violations = append(e.violations, noteViolations...)
+		e.totalRules += noteRules
+	}
+}
+
+// paragraphs checks each paragraph in scope: headings, structure, table of
+// contents entries and the formatting of body text.
+func (e *evaluation) paragraphs() {
+	if len(e.config.Scope.Dictionaries) > 0 && e.s.Dictionaries != nil {
+		e.config.Scope.Vocabulary = append(e.config.Scope.Vocabulary, e.s.Dictionaries(e.config.Scope.Dictionaries)...)
+	}
+	forbiddenWords := compileVocabulary(e.config.Scope)
+	allowedUnits := unitDictionary(e.config.Units.AllowedUnits)
+	var headingMap map[string]int // heading title -> page, built on the first TOC entry
+	lastHeadingLevel := 0
+	inReferencesSection := false
+	checkedParagraphs, outOfScope, headings := 0, 0, 0
+	parts := documentParts(e.doc.Paragraphs, e.config.References)
+	profiled := 0 // paragraphs outside the body rules checked by a profile
+	for i, p := range e.doc.Paragraphs {
+		e.clock.Enter("paragraphs")
+		// Skip blank paragraphs (empty text or whitespace only)
+		trimmed := strings.TrimSpace(p.Text)
+		if trimmed == "" {
+			continue
+		}
+
+		// Page Scope Filter
+		if e.config.Scope.StartPage > 1 && p.PageNumber < e.config.Scope.StartPage {
+			// Skip checks for this paragraph as it is out of scope (e.g. title page),
+			// except the profile of its part
+			outOfScope++
+			if profile := e.config.Sections.profile(parts[i]); profile != nil && (parts[i] == partTitlePage || !isHeadingParagraph(p)) {
+				e.clock.Enter("body_formatting")
+				pos := fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(trimmed, 100))
+				formatViolations, formatRules := checkParagraphFormatting(p, profile.Font, profile.Paragraph, pos)
+				anchorViolations(formatViolations, paragraphLocation(i, p))
+				e.violations = append(e.violations, formatViolations...)
+				e.totalRules += formatRules
+				profiled++
+			}
+			continue
+		}
+		checkedParagraphs++
+
+		// ID for Violation
+		pos := fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(trimmed, 100))
+		loc := paragraphLocation(i, p)
+		start := len(e.violations)
+
+		isHeading := isHeadingParagraph(p)
+		headingLevel := paragraphHeadingLevel(p)
+
+		if headingLevel > 0 {
+			headings++
+		}
+
+		if isReferenceHeading(trimmed, e.config.References) {
+			inReferencesSection = true
+		} else if inReferencesSection && isHeading {
+			inReferencesSection = false
+		}
+
+		e.clock.Enter("headings")
+		if isHeading && headingLevel > 0 && p.Role != "toc" {
+			headingViolations, headingRules := checkHeadingParagraph(p, e.config.Headings, headingLevel, pos)
+			e.violations = append(e.violations, headingViolations...)
+			e.totalRules += headingRules
+		}
+
+		// --- Structure Rules ---
+		e.clock.Enter("structure")
+
+		// 1. Heading 1 starts new page
+		if e.config.Structure.Heading1StartNewPage && headingLevel == 1 && p.Role == "heading" && i > 0 {
+			// Check if ANY of these conditions hold, which indicate a new page:
+			// a) StartsPageBreak = explicit <w:br type="page"> in runs
+			// b) The paragraph itself has PageBreakBefore PPr
+			// c) It's on a different page than the previous heading (page tracker)
+			// We check (a) and (b) via StartsPageBreak flag already.
+			// Additionally check that the heading is not the very first paragraph on its page.
+			prevNonEmpty := -1
+			for j := i - 1; j >= 0; j-- {
+				if strings.TrimSpace(e.doc.Paragraphs[j].Text) != "" {
+					prevNonEmpty = j
+					break
+				}
+			}
+			// Only flag if there's a non-empty para before this heading AND it's on the same page AND no break
+			if prevNonEmpty >= 0 && !p.StartsPageBreak && e.doc.Paragraphs[prevNonEmpty].PageNumber == p.PageNumber {
+				e.violations = append(e.violations, models.Violation{
+					RuleType: "structure_break", Description: "Заголовок 1 уровня должен начинаться с новой страницы", PositionInDoc: pos,
+					ExpectedValue: "Разрыв страницы", ActualValue: "Предыдущий текст на той же странице", Severity: "warning",
+				})
+			}
+		}
+
+		// 2. Heading Hierarchy (1 -> 2 -> 3)
+		if e.config.Structure.HeadingHierarchy && isHeading && p.Role == "heading" && headingLevel > 0 {
+			if headingLevel > lastHeadingLevel+1 {
+				e.violations = append(e.violations, models.Violation{
+					RuleType: "structure_hierarchy", Description: fmt.Sprintf("Пропущен уровень заголовка: H%d после H%d", headingLevel, lastHeadingLevel), PositionInDoc: pos,
+					ExpectedValue: fmt.Sprintf("Заголовок %d", lastHeadingLevel+1), ActualValue: fmt.Sprintf("Заголовок %d", headingLevel), Severity: "error",
+				})
+			}
+			lastHeadingLevel = headingLevel
+		}
+		if !isHeading {
+			// Reset hierarchy check? No, body text doesn't reset level.
+		}
+
+		// --- TOC Verification ---
+		e.clock.Enter("toc_entries")
+		if e.config.Structure.VerifyTOC {
+			text := strings.TrimSpace(p.Text)
+
+			// Skip empty or very short TOC entries
+			if len(text) >= 3 {
+				isTOCStyle := strings.HasPrefix(strings.ToLower(p.StyleID), "toc") || strings.HasPrefix(strings.ToLower(p.StyleID), "table of contents") || strings.HasPrefix(strings.ToLower(p.StyleID), "оглавление")
+
+				matches := tocEntryLineRegex.FindStringSubmatch(text)
+
+				// It's a TOC entry if it has a TOC style, OR if it neatly matches the Title .... Page pattern
+				if isTOCStyle || len(matches) >= 3 {
+					if len(matches) >= 3 {
+						titlePart := strings.TrimSpace(matches[1])
+						pagePart := matches[2]
+
+						// Clean up title: remove trailing dots, underscores, dashes, spaces
+						titlePart = strings.TrimRight(titlePart, " ._-")
+
+						if tocPage, err := strconv.Atoi(pagePart); err == nil {
+							// Normalized title for fuzzy matching
+							normTitle := normalizeForTOC(titlePart)
+
+							// Build heading map once per document for efficiency
+							if headingMap == nil {
+								headingMap = tocHeadingPages(e.doc.Paragraphs)
+							}
+
+							if actualPage, found := headingMap[normTitle]; found {
+								if actualPage != tocPage {
+									isDoubtful := math.Abs(float64(actualPage-tocPage)) <= 1.0 // Only 1 page difference is doubtful
+									e.violations = append(e.violations, withValues(models.Violation{
+										RuleType: "toc_page_mismatch", Description: fmt.Sprintf("Несовпадение страниц в оглавлении для '%s'", truncate(titlePart, 20)), PositionInDoc: "Оглавление", Severity: "error",
+										IsDoubtful:  isDoubtful,
+										ContextText: contextSnippet(text),
+									}, models.UnitPage, float64(actualPage), float64(tocPage)))
+								}
+							} else {
+								e.violations = append(e.violations, models.Violation{
+									RuleType: "toc_missing_heading", Description: fmt.Sprintf("Раздел из оглавления не найден в тексте: '%s'", truncate(titlePart, 30)), PositionInDoc: "Оглавление",
+									ExpectedValue: "Наличие раздела в тексте", ActualValue: "Раздел не найден", Severity: "error",
+									IsDoubtful:  true, // Always doubtful if it's a naming mismatch
+									ContextText: contextSnippet(text),
+								})
+							}
+						}
+					}
+				}
+			}
+		}
+
+		// --- Formatting Rules (Skip for Headings usually, but user might want strictness) ---
+		e.clock.Enter("body_formatting")
+		// We usually apply "Body" rules only to normal paragraphs (no style or Normal)
+
+		if !isHeading && shouldCheckBodyFormatting(p, inReferencesSection) {
+			isCodeBlock := e.config.CodeBlocks.Enabled && isCodeParagraph(p)
+			if isCodeBlock {
+				codeViolations, codeRules := checkCodeParagraph(p, e.config.CodeBlocks, pos)
+				e.violations = append(e.violations, codeViolations...)
+				e.totalRules += codeRules
+				anchorViolations(e.violations[start:], loc)
+				continue
+			}
+
+			if p.IsListItem && e.config.Structure.ListAlignment != "" {
+				e.totalRules++
+				expected := normalizeAlignment(e.config.Structure.ListAlignment)
+				actual := normalizeAlignment(p.Alignment)
+				if actual == "" {
+					actual = "left"
+				}
+				if actual != expected {
+					e.violations = append(e.violations, models.Violation{
+						RuleType:      "list_alignment",
+						Description:   "Неверное выравнивание элемента списка",
+						PositionInDoc: pos,
+						ExpectedValue: expected,
+						ActualValue:   actual,
+						Severity:      "warning",
+						ContextText:   contextSnippet(p.Text),
+						IsDoubtful:    true,
+					})
+				}
+			}
+
+			// --- Vocabulary Check (only for body text, not headings) ---
+			if len(forbiddenWords) > 0 {
+				lowerText := strings.ToLower(p.Text)
+				for _, m := range forbiddenWords.find(lowerText) {
+					wordLoc := *loc
+					wordLoc.CharStart = intPtr(utf8.RuneCountInString(lowerText[:m.start]))
+					wordLoc.CharEnd = intPtr(utf8.RuneCountInString(lowerText[:m.end]))
+					e.violations = append(e.violations, m.word.violation(lowerText[m.start:m.end], pos, contextSnippet(p.Text), &wordLoc))
+				}
+			}
+
+			// --- Typography Rules (dashes, quotes, spaces) ---
+			typoViolations, typoRules := checkTypographyRules(p.Text, e.config.TypographyRules, pos, loc)
+			e.violations = append(e.violations, typoViolations...)
+			e.totalRules += typoRules
+
+			// --- Numbers and Units (ГОСТ 8.417) ---
+			unitViolations, unitRules := checkNumbersAndUnits(p.Text, e.config.Units, allowedUnits, pos, loc)
+			e.violations = append(e.violations, unitViolations...)
+			e.totalRules += unitRules
+
+			// Font, spacing, alignment and indent, by the profile of the part
+			font, paragraph := e.config.Sections.formatting(parts[i], e.config.Font, e.config.Paragraph)
+			formatViolations, formatRules := checkParagraphFormatting(p, font, paragraph, pos)
+			e.violations = append(e.violations, formatViolations...)
+			e.totalRules += formatRules
+
+			// Advanced Typography Controls
+			if e.config.Typography.ForbidBold {
+				e.totalRules++
+				if p.IsBold {
+					e.violations = append(e.violations, models.Violation{
+						RuleType: "style_bold", Description: "Жирный шрифт запрещен в основном тексте", PositionInDoc: pos,
+						ExpectedValue: "Обычный", ActualValue: "Жирный", Severity: "error",
+						ContextText: contextSnippet(p.Text),
+					})
+				}
+			}
+			if e.config.Typography.ForbidItalic {
+				e.totalRules++
+				if p.IsItalic {
+					e.violations = append(e.violations, models.Violation{
+						RuleType: "style_italic", Description: "Курсив запрещен в основном тексте", PositionInDoc: pos,
+						ExpectedValue: "Обычный", ActualValue: "Курсив", Severity: "error",
+						ContextText: contextSnippet(p.Text),
+					})
+				}
+			}
+			if e.config.Typography.ForbidUnderline {
+				e.totalRules++
+				if p.IsUnderline {
+					e.violations = append(e.violations, models.Violation{
+						RuleType: "style_underline", Description: "Подчеркивание запрещено", PositionInDoc: pos,
+						ExpectedValue: "Обычный", ActualValue: "Подчеркнутый", Severity: "error",
+						ContextText: contextSnippet(p.Text),
+					})
+				}
+			}
+			if e.config.Typography.ForbidAllCaps {
+				e.totalRules++
+				if p.IsAllCaps {
+					e.violations = append(e.violations, models.Violation{
+						RuleType: "style_caps", Description: "ВСЕ ЗАГЛАВНЫЕ запрещены", PositionInDoc: pos,
+						ExpectedValue: "Обычный", ActualValue: "ВСЕ ЗАГЛАВНЫЕ", Severity: "error",
+						ContextText: contextSnippet(p.Text),
+					})
+				}
+			}
+		} else if profile := e.config.Sections.profile(parts[i]); profile != nil && parts[i] != partBody && parts[i] != partAppendices && (parts[i] == partTitlePage || !isHeading) {
+			// The title page, the table of contents and the bibliography are
+			// left out of the body rules; a profile of their own applies
+			formatViolations, formatRules := checkParagraphFormatting(p, profile.Font, profile.Paragraph, pos)
+			e.violations = append(e.violations, formatViolations...)
+			e.totalRules += formatRules
+			profiled++
+		}
+		anchorViolations(e.violations[start:], loc)
+	}
+
+	e.trace.applied("paragraphs", "scope", e.config.Scope)
+	e.trace.read("paragraphs", "paragraphs", len(e.doc.Paragraphs))
+	e.trace.read("paragraphs", "checked", checkedParagraphs)
+	e.trace.read("paragraphs", "out_of_scope", outOfScope)
+	e.trace.applied("headings", "headings", e.config.Headings)
+	e.trace.read("headings", "headings", headings)
+	if headings == 0 {
+		e.trace.skip("headings", "В документе не найдены заголовки")
+	}
+	if e.config.Structure.Heading1StartNewPage || e.config.Structure.HeadingHierarchy {
+		e.trace.applied("structure", "heading1_start_new_page", e.config.Structure.Heading1StartNewPage)
+		e.trace.applied("structure", "heading_hierarchy", e.config.Structure.HeadingHierarchy)
+		e.trace.read("structure", "headings", headings)
+	}
+	if e.config.Structure.VerifyTOC {
+		e.trace.read("toc_entries", "pages_estimated", e.doc.Stats.PagesEstimated)
+	}
+	e.trace.applied("body_formatting", "font", e.config.Font)
+	e.trace.applied("body_formatting", "paragraph", e.config.Paragraph)
+	e.trace.applied("body_formatting", "typography", e.config.Typography)
+	e.trace.applied("body_formatting", "sections", e.config.Sections)
+	e.trace.read("body_formatting", "paragraphs", checkedParagraphs-headings)
+	if profiled > 0 {
+		e.trace.read("body_formatting", "profiled", profiled)
+	}
+}
+
+// docLength checks the page count of the document.
+func (e *evaluation) docLength() {
+	if e.config.Scope.MinPages > 0 || e.config.Scope.MaxPages > 0 {
+		e.trace.applied("doc_length", "min_pages", e.config.Scope.MinPages)
+		e.trace.applied("doc_length", "max_pages", e.config.Scope.MaxPages)
+		e.trace.read("doc_length", "total_pages", e.doc.Stats.TotalPages)
+		e.trace.read("doc_length", "pages_estimated", e.doc.Stats.PagesEstimated)
+	}
+	if e.config.Scope.MinPages > 0 && e.doc.Stats.TotalPages < e.config.Scope.MinPages {
+		e.violations = append(e.violations, withBound(models.Violation{
+			RuleType: "doc_length", Description: "Документ слишком короткий", PositionInDoc: "Глобально", Severity: "error",
+		}, models.UnitPages, models.BoundMin, float64(e.config.Scope.MinPages), float64(e.doc.Stats.TotalPages)))
+	}
+	if e.config.Scope.MaxPages > 0 && e.doc.Stats.TotalPages > e.config.Scope.MaxPages {
+		e.violations = append(e.violations, withBound(models.Violation{
+			RuleType: "doc_length", Description: "Документ слишком длинный", PositionInDoc: "Глобально", Severity: "error",
+		}, models.UnitPages, models.BoundMax, float64(e.config.Scope.MaxPages), float64(e.doc.Stats.TotalPages)))
+	}
+}
+
+// introduction checks the length of the introduction and the page count it
+// declares.
+func (e *evaluation) introduction() {
+	if e.config.Introduction.MinPages > 0 || e.config.Introduction.MaxPages > 0 || e.config.Introduction.VerifyPageCountDeclaration {
+		startPage := -1
+		endPage := -1
+		var introductionText strings.Builder // Collect all intro text for declaration check
+
+		for _, p := range e.doc.Paragraphs {
+			// Use isHeadingParagraph to also catch heuristic headings
+			if isHeadingParagraph(p) {
+				text := strings.ToLower(strings.TrimSpace(p.Text))
+				if startPage == -1 && (strings.Contains(text, "введение") || strings.Contains(text, "introduction")) {
+					startPage = p.PageNumber
+				} else if startPage != -1 && endPage == -1 {
+					endPage = p.PageNumber
+					break
+				}
+			}
+
+			// Collect intro text for declaration verification
+			if startPage != -1 && endPage == -1 {
+				introductionText.WriteString(p.Text)
+				introductionText.WriteString(" ")
+			}
+		}
+
+		// If endPage is not found but startPage is found, assume it goes to the end of document
+		if startPage != -1 && endPage == -1 {
+			endPage = e.doc.Stats.TotalPages
+			// If total pages is the same as start page, we still count as 1
+			if endPage < startPage {
+				endPage = startPage
+			}
+		}
+
+		e.trace.applied("introduction", "introduction", e.config.Introduction)
+		if startPage == -1 {
+			e.trace.skip("introduction", "Заголовок «Введение» не найден")
+		} else {
+			e.trace.read("introduction", "start_page", startPage)
+			e.trace.read("introduction", "end_page", endPage)
+		}
+		if startPage != -1 {
+			// Correct calculation: if intro starts at page 5 and next section at page 8,
+			// intro occupies pages 5,6,7 = 3 pages (endPage - startPage)
+			// But if intro is alone until end, we need +1
+			pCount := endPage - startPage
+			if pCount == 0 {
+				pCount = 1
+			}
+
+			if e.config.Introduction.MinPages > 0 && pCount < e.config.Introduction.MinPages {
+				e.violations = append(e.violations, withBound(models.Violation{
+					RuleType: "intro_length", Description: "Введение слишком короткое", PositionInDoc: fmt.Sprintf("Стр. %d-%d", startPage, endPage), Severity: "error",
+				}, models.UnitPages, models.BoundMin, float64(e.config.Introduction.MinPages), float64(pCount)))
+			}
+			if e.config.Introduction.MaxPages > 0 && pCount > e.config.Introduction.MaxPages {
+				e.violations = append(e.violations, withBound(models.Violation{
+					RuleType: "intro_length", Description: "Введение слишком длинное", PositionInDoc: fmt.Sprintf("Стр. %d-%d", startPage, endPage), Severity: "error",
+				}, models.UnitPages, models.BoundMax, float64(e.config.Introduction.MaxPages), float64(pCount)))
+			}
+
+			// NEW: Verify page count declaration if enabled
+			if e.config.Introduction.VerifyPageCountDeclaration {
+				// Look for patterns like:
+				// "Введение содержит 3 страницы"
+				// "данный раздел занимает 2 страницы"
+				// "Introduction spans 4 pages"
+				introText := strings.ToLower(introductionText.String())
+
+				// Regex patterns to find declared page counts
+				// Russian: "содержит X страниц", "занимает X страниц"
+				// English: "contains X pages", "spans X pages"
+				patterns := []string{
+					`содержит\s+(\d+)\s+страниц`,
+					`занимает\s+(\d+)\s+страниц`,
+					`содержит\s+(\d+)\s+стр`,
+					`занимает\s+(\d+)\s+стр`,
+					`contains\s+(\d+)\s+pages?`,
+					`spans\s+(\d+)\s+pages?`,
+				}
+
+				declaredPages := -1
+
+				for _, pattern := range patterns {
+					re := regexp.MustCompile(pattern)
+					matches := re.FindStringSubmatch(introText)
+					if len(matches) > 1 {
+						// Found a match, extract the number
+						if num, err := strconv.Atoi(matches[1]); err == nil {
+							declaredPages = num
+							break
+						}
+					}
+				}
+
+				// If we found a declaration, verify it
+				if declaredPages > 0 && declaredPages != pCount {
+					e.violations = append(e.violations, models.Violation{
+						RuleType:      "intro_page_declaration_mismatch",
+						Description:   "Несовпадение заявленного и фактического количества страниц Введения",
+						PositionInDoc: fmt.Sprintf("Введение (Стр. %d-%d)", startPage, endPage),
+						ExpectedValue: fmt.Sprintf("Фактически: %d стр.", pCount),
+						ActualValue:   fmt.Sprintf("Заявлено в тексте: %d стр.", declaredPages),
+						Severity:      "warning", // Warning, not error, as declaration might be optional
+						ContextText:   truncate(introductionText.String(), 200),
+					})
+				}
+			}
+		}
+	}
+}
+
+// sectionOrder checks the order of the sections.
+func (e *evaluation) sectionOrder() {
+	if e.config.Structure.SectionOrder != "" {
+		e.trace.applied("section_order", "section_order", e.config.Structure.SectionOrder)
+		sectionViolations := checkSectionOrder(e.doc.Paragraphs, e.config.Structure.SectionOrder)
+		e.violations = append(e.violations, sectionViolations...)
+		for _, s := range strings.Split(e.config.Structure.SectionOrder, ",") {
+			if strings.TrimSpace(s) != "" {
+				e.totalRules++
+			}
+		}
+	}
+}
+
+// pagination checks widow control and headings left at the end of a page.
+func (e *evaluation) pagination() {
+	if e.config.Structure.RequireWidowControl || e.config.Structure.HeadingKeepNext || e.config.Structure.HeadingNotLastOnPage {
+		e.trace.applied("pagination", "require_widow_control", e.config.Structure.RequireWidowControl)
+		e.trace.applied("pagination", "heading_keep_next", e.config.Structure.HeadingKeepNext)
+		e.trace.applied("pagination", "heading_not_last_on_page", e.config.Structure.HeadingNotLastOnPage)
+		e.trace.read("pagination", "pages_estimated", e.doc.Stats.PagesEstimated)
+		pageViolations, pageRules := checkPagination(e.doc, e.config.Structure, e.config.Scope.StartPage)
+		e.violations = append(e.violations, pageViolations...)
+		e.totalRules += pageRules
+	}
+}
+
+// hyphenation checks automatic hyphenation.
+func (e *evaluation) hyphenation() {
+	if e.config.TypographyRules.ForbidAutoHyphenation || e.config.TypographyRules.ForbidHeadingHyphenation {
+		e.trace.applied("hyphenation", "forbid_auto_hyphenation", e.config.TypographyRules.ForbidAutoHyphenation)
+		e.trace.applied("hyphenation", "forbid_heading_hyphenation", e.config.TypographyRules.ForbidHeadingHyphenation)
+		e.trace.read("hyphenation", "auto_hyphenation", e.doc.AutoHyphenation)
+		hyphenViolations, hyphenRules := checkHyphenation(e.doc, e.config.TypographyRules, e.config.Scope.StartPage)
+		e.violations = append(e.violations, hyphenViolations...)
+		e.totalRules += hyphenRules
+	}
+}
+
+// revisions flags tracked changes and comments left in the document.
+func (e *evaluation) revisions() {
+	if e.config.Revisions.ForbidTrackedChanges || e.config.Revisions.ForbidComments {
+		e.trace.applied("revisions", "revisions", e.config.Revisions)
+		e.trace.read("revisions", "revisions", len(e.doc.Revisions))
+		e.trace.read("revisions", "comments", len(e.doc.Comments))
+		revisionViolations, revisionRules := checkRevisions(e.doc, e.config.Revisions)
+		e.violations = append(e.violations, revisionViolations...)
+		e.totalRules += revisionRules
+	}
+}
+
+// article checks the parts of a journal article.
+func (e *evaluation) article() {
+	if e.config.Structure.DocumentType == documentTypeArticle {
+		e.trace.applied("article", "article", e.config.Structure.Article)
+		articleViolations, articleRules := checkArticle(e.doc, e.config.Structure.Article, e.config.References)
+		e.violations = append(e.violations, articleViolations...)
+		e.totalRules += articleRules
+	}
+}
+
+// repetition flags duplicated paragraphs and blocks of text.
+func (e *evaluation) repetition() {
+	if e.config.Repetition.Enabled {
+		e.trace.applied("repetition", "repetition", e.config.Repetition)
+		e.trace.read("repetition", "paragraphs", len(e.doc.Paragraphs))
+		repetitionViolations, repetitionRules := checkRepetition(e.doc, e.config.Repetition, e.config.Scope.StartPage)
+		e.violations = append(e.violations, repetitionViolations...)
+		e.totalRules += repetitionRules
+	}
+}
+
+// customRules applies the regular expression rules of the standard.
+func (e *evaluation) customRules() {
+	if len(e.config.CustomRules) > 0 {
+		e.trace.applied("custom_rules", "custom_rules", e.config.CustomRules)
+		customViolations, customRules := checkCustomRules(e.doc.Paragraphs, e.config.CustomRules, e.config.References, e.config.Scope.StartPage, e.trace)
+		e.violations = append(e.violations, customViolations...)
+		e.totalRules += customRules
+	}
+}
+
+// objectives compares the tasks of the introduction with the conclusion.
+func (e *evaluation) objectives() {
+	if e.config.Objectives.Enabled {
+		e.trace.applied("objectives", "objectives", e.config.Objectives)
+		e.violations = append(e.violations, checkObjectives(e.doc.Paragraphs, e.trace)...)
+	}
+}
+
+// integrity flags formatting that inflates the page count.
+func (e *evaluation) integrity() {
+	if e.config.Integrity.Enabled {
+		e.trace.read("integrity", "pages", e.doc.Stats.TotalPages)
+		e.violations = append(e.violations, checkIntegrity(e.doc, e.config.Scope.StartPage)...)
+	}
+}
+
+// docProperties screens the author and application saved with the document.
+func (e *evaluation) docProperties() {
+	if props := e.config.DocProperties; props.CheckAuthor || props.ForbidConverters || props.MinEditingMinutes > 0 {
+		e.trace.applied("doc_properties", "doc_properties", props)
+		e.trace.read("doc_properties", "properties", e.doc.Properties)
+		e.trace.read("doc_properties", "student_name", e.s.StudentName)
+		e.violations = append(e.violations, checkDocProperties(e.doc, props, e.s.StudentName)...)
+	}
+}
+
+// fileName checks the name of the uploaded file.
+func (e *evaluation) fileName() {
+	vFileName, fileNameRules := e.s.fileNameViolations(e.trace, e.config.FileName)
+	e.violations = append(e.violations, vFileName...)
+	e.totalRules += fileNameRules
+}
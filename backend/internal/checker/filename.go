@@ -0,0 +1,88 @@
+package checker
+
+import (
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"regexp"
+	"strings"
+)
+
+// FileNameConfig is the naming scheme of uploaded files many departments
+// require, e.g. "Группа_Фамилия_ВКР.docx".
+type FileNameConfig struct {
+	// Pattern is a regular expression the whole file name, extension
+	// included, must match, e.g. `^[А-Я]+-\d+_[А-ЯЁ][а-яё-]+_ВКР\.docx$`;
+	// empty disables the rule.
+	Pattern string `json:"pattern"`
+	Example string `json:"example"` // a valid name shown to students
+	// Reject refuses uploads with another name instead of reporting a
+	// violation.
+	Reject bool `json:"reject"`
+}
+
+// Enabled reports whether a naming scheme is set.
+func (c FileNameConfig) Enabled() bool {
+	return strings.TrimSpace(c.Pattern) != ""
+}
+
+// Compile compiles the pattern; the whole name must match it even when the
+// pattern is not anchored.
+func (c FileNameConfig) Compile() (*regexp.Regexp, error) {
+	// The pattern is compiled alone first so errors quote it as written.
+	if _, err := regexp.Compile(c.Pattern); err != nil {
+		return nil, err
+	}
+	return regexp.Compile(`^(?:` + c.Pattern + `)$`)
+}
+
+// Expected describes a valid name for messages: the example when there is
+// one, the pattern otherwise.
+func (c FileNameConfig) Expected() string {
+	if example := strings.TrimSpace(c.Example); example != "" {
+		return example
+	}
+	return c.Pattern
+}
+
+// Matches reports whether name follows the scheme. A scheme that does not
+// compile matches every name.
+func (c FileNameConfig) Matches(name string) bool {
+	if !c.Enabled() {
+		return true
+	}
+	re, err := c.Compile()
+	return err != nil || re.MatchString(name)
+}
+
+// checkFileName reports a file name that does not follow the scheme.
+func checkFileName(name string, cfg FileNameConfig) ([]models.Violation, int) {
+	if cfg.Matches(name) {
+		return nil, 1
+	}
+	return []models.Violation{{
+		RuleType:      "file_name",
+		Description:   "Имя файла не соответствует требованиям кафедры",
+		ExpectedValue: cfg.Expected(),
+		ActualValue:   name,
+		Severity:      "error",
+		PositionInDoc: "Имя файла",
+		Suggestion:    "Переименуйте файл по образцу и загрузите его снова",
+	}}, 1
+}
+
+// fileNameViolations checks the uploaded name against the scheme of the
+// assignment, or else of the standard.
+func (s *CheckService) fileNameViolations(trace *ruleTrace, cfg FileNameConfig) ([]models.Violation, int) {
+	if s.FileNameRule != nil {
+		cfg = *s.FileNameRule
+	}
+	if !cfg.Enabled() || s.FileName == "" {
+		return nil, 0
+	}
+	trace.applied("file_name", "file_name", cfg)
+	trace.read("file_name", "name", s.FileName)
+	if _, err := cfg.Compile(); err != nil {
+		trace.skip("file_name", "Шаблон имени файла с ошибкой: "+err.Error())
+		return nil, 0
+	}
+	return checkFileName(s.FileName, cfg)
+}
@@ -0,0 +1,151 @@
+package checker
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// paragraphLengthBounds split the lengths of body paragraphs, in characters,
+// into the buckets of Fingerprint.ParagraphLengths.
+var paragraphLengthBounds = []int{50, 100, 200, 400, 800}
+
+// Fingerprint is the structure of a document without its wording: the order
+// of its sections and headings, how many tables, figures and formulas it has
+// and how long its paragraphs are. Two works written independently to the
+// same assignment share the required sections but rarely all of it.
+type Fingerprint struct {
+	Sections         []string `json:"sections"` // level 1 headings, normalized
+	Headings         []string `json:"headings"` // all headings, normalized
+	Tables           int      `json:"tables"`
+	Figures          int      `json:"figures"`
+	Formulas         int      `json:"formulas"`
+	Paragraphs       int      `json:"paragraphs"`        // body paragraphs with text
+	ParagraphLengths []int    `json:"paragraph_lengths"` // histogram over paragraphLengthBounds
+}
+
+// FingerprintMatch is the similarity of two fingerprints, overall and by part,
+// each from 0 (nothing in common) to 1 (identical).
+type FingerprintMatch struct {
+	Similarity float64 `json:"similarity"`
+	Sections   float64 `json:"sections"`
+	Headings   float64 `json:"headings"`
+	Counts     float64 `json:"counts"`
+	Lengths    float64 `json:"lengths"`
+}
+
+// MinFingerprintParagraphs is the size below which a fingerprint says too
+// little to be compared: every short document looks alike.
+const MinFingerprintParagraphs = 10
+
+// StructuralFingerprint computes the fingerprint of a parsed document. The
+// table of contents is left out: it repeats the headings.
+func StructuralFingerprint(doc *ParsedDoc) Fingerprint {
+	fp := Fingerprint{
+		Tables:           len(doc.Tables),
+		Figures:          len(doc.Images),
+		Formulas:         len(doc.Formulas),
+		ParagraphLengths: make([]int, len(paragraphLengthBounds)+1),
+	}
+	for _, p := range doc.Paragraphs {
+		text := strings.TrimSpace(p.Text)
+		if text == "" || p.Role == "toc" {
+			continue
+		}
+		if isHeadingParagraph(p) {
+			heading := normalizeForTOC(text)
+			fp.Headings = append(fp.Headings, heading)
+			if paragraphHeadingLevel(p) == 1 {
+				fp.Sections = append(fp.Sections, heading)
+			}
+			continue
+		}
+		if p.Role != "body" && p.Role != "list" {
+			continue
+		}
+		fp.Paragraphs++
+		n := utf8.RuneCountInString(text)
+		bucket := 0
+		for bucket < len(paragraphLengthBounds) && n >= paragraphLengthBounds[bucket] {
+			bucket++
+		}
+		fp.ParagraphLengths[bucket]++
+	}
+	return fp
+}
+
+// CompareFingerprints weighs the headings most: their wording is the least
+// likely part to coincide by chance.
+func CompareFingerprints(a, b Fingerprint) FingerprintMatch {
+	m := FingerprintMatch{
+		Sections: sequenceSimilarity(a.Sections, b.Sections),
+		Headings: sequenceSimilarity(a.Headings, b.Headings),
+		Counts: (countSimilarity(a.Tables, b.Tables) + countSimilarity(a.Figures, b.Figures) +
+			countSimilarity(a.Formulas, b.Formulas) + countSimilarity(a.Paragraphs, b.Paragraphs)) / 4,
+		Lengths: histogramSimilarity(a.ParagraphLengths, b.ParagraphLengths),
+	}
+	m.Similarity = 0.2*m.Sections + 0.4*m.Headings + 0.2*m.Counts + 0.2*m.Lengths
+	return m
+}
+
+// sequenceSimilarity is the share of items two sequences have in common in
+// the same order: twice their longest common subsequence over both lengths.
+func sequenceSimilarity(a, b []string) float64 {
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return 2 * float64(prev[len(b)]) / float64(len(a)+len(b))
+}
+
+func countSimilarity(a, b int) float64 {
+	if a == b {
+		return 1
+	}
+	return 1 - math.Abs(float64(a-b))/math.Max(float64(a), float64(b))
+}
+
+// histogramSimilarity compares the shapes of two histograms: one minus half
+// the distance between their normalized buckets.
+func histogramSimilarity(a, b []int) float64 {
+	total := func(h []int) float64 {
+		sum := 0
+		for _, n := range h {
+			sum += n
+		}
+		return float64(sum)
+	}
+	ta, tb := total(a), total(b)
+	if ta == 0 || tb == 0 {
+		if ta == tb {
+			return 1
+		}
+		return 0
+	}
+	distance := 0.0
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y float64
+		if i < len(a) {
+			x = float64(a[i]) / ta
+		}
+		if i < len(b) {
+			y = float64(b[i]) / tb
+		}
+		distance += math.Abs(x - y)
+	}
+	return 1 - distance/2
+}
@@ -0,0 +1,140 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"math"
+	"strings"
+)
+
+// FootnotesConfig holds the rules for footnotes (ГОСТ 7.32 п. 6.12): the
+// font size of the note text, how the notes are numbered, whether endnotes
+// are allowed and how many footnotes a page may carry.
+type FootnotesConfig struct {
+	Enabled        bool    `json:"enabled"`
+	FontSizePt     float64 `json:"font_size_pt"`    // 0 = not checked
+	Numbering      string  `json:"numbering"`       // continuous, each_page, each_section; empty = not checked
+	ForbidEndnotes bool    `json:"forbid_endnotes"` // notes go at the foot of the page, not at the end
+	MaxPerPage     int     `json:"max_per_page"`    // 0 = not checked
+	SizeTolerance  float64 `json:"size_tolerance"`  // pt; 0 = deployment default
+}
+
+var footnoteNumberingNames = map[string]string{
+	footnotesContinuous:  "сквозная",
+	footnotesEachPage:    "на каждой странице",
+	footnotesEachSection: "в каждом разделе",
+}
+
+// checkFootnotes checks the footnotes and endnotes of the document. Notes
+// referenced before the start page of the check are skipped.
+func checkFootnotes(doc *ParsedDoc, cfg FootnotesConfig, startPage int) ([]models.Violation, int) {
+	var violations []models.Violation
+	totalRules := 0
+
+	inScope := func(n ParsedNote) bool { return startPage <= 1 || n.PageNumber >= startPage }
+	add := func(n ParsedNote, v models.Violation) {
+		p := doc.Paragraphs[n.ParagraphIndex]
+		v.PositionInDoc = fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, n.ParagraphIndex+1, truncate(strings.TrimSpace(p.Text), 100))
+		v.ContextText = contextSnippet(n.Text)
+		v.Location = paragraphLocation(n.ParagraphIndex, p)
+		if v.Severity == "" {
+			v.Severity = "warning"
+		}
+		violations = append(violations, v)
+	}
+
+	var footnotes []ParsedNote
+	for _, n := range doc.Footnotes {
+		if inScope(n) {
+			footnotes = append(footnotes, n)
+		}
+	}
+
+	if cfg.FontSizePt > 0 {
+		for _, n := range footnotes {
+			if n.FontSizePt == 0 {
+				continue
+			}
+			totalRules++
+			if math.Abs(n.FontSizePt-cfg.FontSizePt) > cfg.SizeTolerance {
+				add(n, withValues(models.Violation{
+					RuleType:    "footnote_font_size",
+					Description: "Неверный размер шрифта сноски",
+					Suggestion:  "Измените стиль «Текст сноски»: он задаёт шрифт всех сносок",
+				}, models.UnitPoint, cfg.FontSizePt, n.FontSizePt))
+			}
+		}
+	}
+
+	if cfg.Numbering != "" && len(footnotes) > 0 {
+		totalRules++
+		if doc.FootnoteRestart != cfg.Numbering {
+			add(footnotes[0], models.Violation{
+				RuleType:      "footnote_numbering",
+				Description:   "Неверная нумерация сносок",
+				ExpectedValue: footnoteNumberingNames[cfg.Numbering],
+				ActualValue:   footnoteNumberingNames[doc.FootnoteRestart],
+				Suggestion:    "Задайте нумерацию в «Ссылки → Сноски → Формат сноски → Нумерация»",
+			})
+		}
+		// A mark typed by hand drops out of the automatic numbering
+		for _, n := range footnotes {
+			totalRules++
+			if n.CustomMark {
+				add(n, models.Violation{
+					RuleType:      "footnote_numbering",
+					Description:   "Знак сноски введён вручную",
+					ExpectedValue: "автоматический номер",
+					ActualValue:   "другой знак",
+					Suggestion:    "Вставьте сноску через «Ссылки → Вставить сноску» с нумерацией по порядку",
+				})
+			}
+		}
+	}
+
+	if cfg.ForbidEndnotes {
+		var endnotes []ParsedNote
+		for _, n := range doc.Endnotes {
+			if inScope(n) {
+				endnotes = append(endnotes, n)
+			}
+		}
+		totalRules++
+		if len(endnotes) > 0 {
+			add(endnotes[0], models.Violation{
+				RuleType:      "endnotes",
+				Description:   "Концевые сноски не допускаются",
+				ExpectedValue: "сноски внизу страницы",
+				ActualValue:   fmt.Sprintf("концевых сносок: %d", len(endnotes)),
+				Suggestion:    "Преобразуйте концевые сноски в обычные: «Ссылки → Сноски → Преобразовать»",
+			})
+		}
+	}
+
+	if cfg.MaxPerPage > 0 {
+		perPage := map[int]int{}
+		for _, n := range footnotes {
+			if perPage[n.PageNumber] == 0 {
+				totalRules++
+			}
+			perPage[n.PageNumber]++
+			// Reported once per page, at the first footnote over the limit
+			if perPage[n.PageNumber] == cfg.MaxPerPage+1 {
+				count := 0
+				for _, other := range footnotes {
+					if other.PageNumber == n.PageNumber {
+						count++
+					}
+				}
+				add(n, models.Violation{
+					RuleType:      "footnotes_per_page",
+					Description:   "Слишком много сносок на странице",
+					ExpectedValue: fmt.Sprintf("не более %d", cfg.MaxPerPage),
+					ActualValue:   fmt.Sprintf("%d", count),
+					Suggestion:    "Перенесите часть пояснений в текст или в приложение",
+				})
+			}
+		}
+	}
+	return violations, totalRules
+}
@@ -0,0 +1,287 @@
+package checker
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ParsedHeaderFooter is a header or footer (колонтитул) as used by one
+// section of the document.
+type ParsedHeaderFooter struct {
+	Section             int    // 0-based section index
+	Type                string // default, first, even
+	ParagraphStart      int    // first body paragraph of the section
+	ParagraphEnd        int    // index after the last body paragraph of the section
+	Text                string // static text; field results such as the page number are left out
+	Alignment           string // alignment of the first paragraph with text
+	HasPageNumber       bool   // contains a PAGE field
+	PageNumberAlignment string // alignment of the paragraph holding the PAGE field
+}
+
+// IsEmpty reports whether nothing is printed in the header or footer.
+func (hf ParsedHeaderFooter) IsEmpty() bool {
+	return strings.TrimSpace(hf.Text) == "" && !hf.HasPageNumber
+}
+
+var pageFieldRe = regexp.MustCompile(`^\s*PAGE\b`)
+
+// docSection is a range of body paragraphs sharing section properties.
+type docSection struct {
+	props      *SectPr
+	start, end int
+}
+
+// bodySections splits the body at paragraphs carrying w:sectPr. The last
+// section uses the body-level w:sectPr.
+func bodySections(doc Document) []docSection {
+	var sections []docSection
+	start := 0
+	for i, para := range doc.Body.Paragraphs {
+		if para.PPr != nil && para.PPr.SectPr != nil {
+			sections = append(sections, docSection{props: para.PPr.SectPr, start: start, end: i + 1})
+			start = i + 1
+		}
+	}
+	return append(sections, docSection{props: doc.Body.SectPr, start: start, end: len(doc.Body.Paragraphs)})
+}
+
+// ParsedSection is the page setup of one section of the document.
+type ParsedSection struct {
+	ParagraphStart int // first body paragraph of the section
+	ParagraphEnd   int // index after the last body paragraph of the section
+	Margins        Margins
+	PageSize       PageSize // zero when the section sets no w:pgSz
+	Orientation    string   // portrait, landscape
+	Columns        int      // text columns, 1 when the section sets none
+}
+
+// mainSection returns the index of the section holding most paragraphs: the
+// page setup of the main text. Ties go to the earlier section.
+func mainSection(sections []ParsedSection) int {
+	main := 0
+	for i, s := range sections {
+		if s.ParagraphEnd-s.ParagraphStart > sections[main].ParagraphEnd-sections[main].ParagraphStart {
+			main = i
+		}
+	}
+	return main
+}
+
+func parsedSections(doc Document) []ParsedSection {
+	var sections []ParsedSection
+	for _, sect := range bodySections(doc) {
+		ps := ParsedSection{ParagraphStart: sect.start, ParagraphEnd: sect.end, Orientation: "portrait", Columns: 1}
+		if sect.props != nil && sect.props.Cols != nil && sect.props.Cols.Num > 1 {
+			ps.Columns = sect.props.Cols.Num
+		}
+		if sect.props != nil && sect.props.PgMar != nil {
+			m := sect.props.PgMar
+			ps.Margins = Margins{
+				TopMm: twipsToMm(m.Top), BottomMm: twipsToMm(m.Bottom),
+				LeftMm: twipsToMm(m.Left), RightMm: twipsToMm(m.Right),
+				HeaderMm: twipsToMm(m.Header), FooterMm: twipsToMm(m.Footer),
+			}
+		}
+		if sect.props != nil && sect.props.PgSz != nil {
+			sz := sect.props.PgSz
+			if sz.Orient == "landscape" || (sz.Orient == "" && twipsToMm(sz.W) > twipsToMm(sz.H)) {
+				ps.Orientation = "landscape"
+			}
+			ps.PageSize = PageSize{WidthMm: twipsToMm(sz.W), HeightMm: twipsToMm(sz.H), Orientation: ps.Orientation}
+		}
+		sections = append(sections, ps)
+	}
+	return sections
+}
+
+// documentTargets maps the relationship ids of word/document.xml to the zip
+// entries they point to.
+func (p *DocParser) documentTargets(files map[string]*zip.File) map[string]string {
+	targets := map[string]string{}
+	if f := files["word/_rels/document.xml.rels"]; f != nil {
+		if data, err := readEntryLimited(f, p.Limits, 0); err == nil {
+			var rels Relationships
+			if xml.Unmarshal(data, &rels) == nil {
+				for _, rel := range rels.Rels {
+					targets[rel.ID] = packagePartName(rel.Target)
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// parseHeadersFooters fills pd.Headers and pd.Footers from the parts the
+// sections reference. A section without a reference of some type inherits it
+// from the previous section, as in Word; first-page parts only count when the
+// section sets w:titlePg. Missing or broken parts are skipped.
+func (p *DocParser) parseHeadersFooters(r *zip.Reader, doc Document, styles *styleSheet, pd *ParsedDoc) {
+	files := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+
+	targets := p.documentTargets(files)
+
+	parts := map[string]*ParsedHeaderFooter{}
+	load := func(id string) *ParsedHeaderFooter {
+		name, ok := targets[id]
+		if !ok {
+			return nil
+		}
+		if hf, ok := parts[name]; ok {
+			return hf
+		}
+		var hf *ParsedHeaderFooter
+		if f := files[name]; f != nil {
+			if data, err := readEntryLimited(f, p.Limits, 0); err == nil {
+				var part HdrFtr
+				if xml.Unmarshal(data, &part) == nil {
+					content := newParsedHeaderFooter(part, styles)
+					hf = &content
+				}
+			}
+		}
+		parts[name] = hf
+		return hf
+	}
+
+	headerRefs, footerRefs := map[string]string{}, map[string]string{}
+	for i, sect := range bodySections(doc) {
+		titlePg := false
+		if sect.props != nil {
+			titlePg = onOffEnabled(sect.props.TitlePg)
+			for _, ref := range sect.props.HeaderReferences {
+				headerRefs[hdrFtrType(ref.Type)] = ref.ID
+			}
+			for _, ref := range sect.props.FooterReferences {
+				footerRefs[hdrFtrType(ref.Type)] = ref.ID
+			}
+		}
+		if i == 0 {
+			pd.TitlePage = titlePg
+		}
+
+		for _, typ := range []string{"default", "first", "even"} {
+			if typ == "first" && !titlePg {
+				continue
+			}
+			if hf := load(headerRefs[typ]); hf != nil {
+				pd.Headers = append(pd.Headers, placeHeaderFooter(*hf, i, typ, sect))
+			}
+			if hf := load(footerRefs[typ]); hf != nil {
+				pd.Footers = append(pd.Footers, placeHeaderFooter(*hf, i, typ, sect))
+			}
+		}
+	}
+}
+
+func placeHeaderFooter(hf ParsedHeaderFooter, section int, typ string, sect docSection) ParsedHeaderFooter {
+	hf.Section = section
+	hf.Type = typ
+	hf.ParagraphStart = sect.start
+	hf.ParagraphEnd = sect.end
+	return hf
+}
+
+func hdrFtrType(t string) string {
+	if t == "" {
+		return "default"
+	}
+	return t
+}
+
+// packagePartName turns a relationship target of word/document.xml into the
+// name of the zip entry.
+func packagePartName(target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(target, "/")
+	}
+	return path.Join("word", target)
+}
+
+// newParsedHeaderFooter summarises the content of a header or footer part.
+// styles may be nil when the formatting is already direct (ODT).
+func newParsedHeaderFooter(part HdrFtr, styles *styleSheet) ParsedHeaderFooter {
+	paras := append([]Paragraph{}, part.Paragraphs...)
+	for _, sdt := range part.Sdts {
+		paras = append(paras, sdt.Content.Paragraphs...)
+	}
+	for _, tbl := range part.Tbls {
+		for _, tr := range tbl.Trs {
+			for _, tc := range tr.Tcs {
+				paras = append(paras, tc.P...)
+			}
+		}
+	}
+
+	var hf ParsedHeaderFooter
+	var lines []string
+	for _, para := range paras {
+		text, hasPage := headerFooterText(para)
+		align := ""
+		if para.PPr != nil && para.PPr.Jc != nil {
+			align = para.PPr.Jc.Val
+		} else {
+			styleID := ""
+			if para.PPr != nil && para.PPr.PStyle != nil {
+				styleID = para.PPr.PStyle.Val
+			}
+			align = styles.resolve(styleID).Alignment
+		}
+
+		if text = strings.TrimSpace(text); text != "" {
+			lines = append(lines, text)
+			if hf.Alignment == "" {
+				hf.Alignment = align
+			}
+		}
+		if hasPage && !hf.HasPageNumber {
+			hf.HasPageNumber = true
+			hf.PageNumberAlignment = align
+		}
+	}
+	hf.Text = strings.Join(lines, "\n")
+	return hf
+}
+
+// headerFooterText returns the static text of a header or footer paragraph
+// and whether it holds a PAGE field. Field results are left out: they are the
+// values Word rendered last time, not part of the running title.
+func headerFooterText(para Paragraph) (string, bool) {
+	var sb strings.Builder
+	hasPage := false
+	inField := false
+
+	runs := append([]Run{}, para.R...)
+	for _, h := range para.Hyperlinks {
+		runs = append(runs, h.R...)
+	}
+	for _, run := range runs {
+		if run.FldChar != nil {
+			inField = run.FldChar.Type != "end"
+			continue
+		}
+		if run.InstrText != nil && pageFieldRe.MatchString(run.InstrText.Content) {
+			hasPage = true
+		}
+		if inField {
+			continue
+		}
+		if run.Text != nil {
+			sb.WriteString(run.Text.Content)
+		}
+		if run.Tab != nil {
+			sb.WriteString("\t")
+		}
+	}
+	for _, f := range para.FldSimples {
+		if pageFieldRe.MatchString(f.Instr) {
+			hasPage = true
+		}
+	}
+	return sb.String(), hasPage
+}
@@ -0,0 +1,80 @@
+package checker
+
+import (
+	"strings"
+)
+
+// Heading detection strategies of HeadingDetectionConfig.
+const (
+	headingDetectHeuristics = "heuristics" // heading styles, outline levels and formatting heuristics (default)
+	headingDetectStyles     = "styles"     // heading styles only
+	headingDetectCustom     = "custom"     // only the styles listed in the standard
+	headingDetectOutline    = "outline"    // outline level of the paragraph or its style
+)
+
+// HeadingDetectionConfig selects how headings are recognized. Templates differ:
+// some use Word heading styles, some numeric style IDs, some their own styles
+// or only outline levels, and students often format headings by hand.
+type HeadingDetectionConfig struct {
+	// Strategy is "heuristics" (empty), "styles", "custom" or "outline".
+	Strategy string `json:"strategy"`
+	// Styles maps style names or IDs to heading levels, e.g.
+	// {"Заголовок раздела": 1}. They are headings with every strategy and the
+	// only ones with "custom".
+	Styles map[string]int `json:"styles"`
+}
+
+// withHeadingDetection returns doc with headings recognized as cfg says. The
+// parser marks headings by the built-in heading styles, outline levels and the
+// formatting heuristics, which is the default; other settings re-mark a copy of the
+// paragraphs, since parsed documents are shared through the parse cache.
+func withHeadingDetection(doc *ParsedDoc, cfg HeadingDetectionConfig) *ParsedDoc {
+	heuristics := cfg.Strategy == "" || cfg.Strategy == headingDetectHeuristics
+	if heuristics && len(cfg.Styles) == 0 {
+		return doc
+	}
+	custom := make(map[string]int, len(cfg.Styles))
+	for name, level := range cfg.Styles {
+		custom[normalizeStyleName(name)] = level
+	}
+
+	out := *doc
+	out.Paragraphs = make([]ParsedParagraph, len(doc.Paragraphs))
+	for i, p := range doc.Paragraphs {
+		wasHeading := isHeadingParagraph(p)
+
+		styled, level := false, 0
+		if lvl, ok := custom[normalizeStyleName(p.StyleName)]; ok && p.StyleName != "" {
+			styled, level = true, lvl
+		} else if lvl, ok := custom[normalizeStyleName(p.StyleID)]; ok && p.StyleID != "" {
+			styled, level = true, lvl
+		} else {
+			switch cfg.Strategy {
+			case headingDetectOutline:
+				styled, level = p.OutlineLevel > 0, p.OutlineLevel
+			case headingDetectStyles:
+				styled, level = isHeadingStyle(p.StyleID), headingLevelFromStyle(p.StyleID)
+			case headingDetectCustom:
+			default:
+				styled, level = builtinHeading(p)
+			}
+		}
+		p.HeadingDetected, p.StyledHeading, p.StyledLevel = true, styled, level
+		// A styled heading takes its level from the style
+		if !heuristics || styled {
+			p.HeuristicHeading, p.HeuristicLevel = false, 0
+		}
+
+		if isHeadingParagraph(p) != wasHeading {
+			p.Role = classifyParagraphRole(p)
+		}
+		out.Paragraphs[i] = p
+	}
+	return &out
+}
+
+// normalizeStyleName makes style names comparable: Word shows "heading 1" as
+// "Heading 1", and IDs drop the spaces of names.
+func normalizeStyleName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), ""))
+}
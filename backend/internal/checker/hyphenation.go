@@ -0,0 +1,79 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"strings"
+)
+
+// checkHyphenation checks the document-wide automatic hyphenation and
+// hyphens in headings. Optional hyphens typed into a heading always break it
+// where the line ends; automatic hyphenation only may, depending on the line
+// width, so a heading left to it is doubtful.
+func checkHyphenation(doc *ParsedDoc, cfg TypographyRulesConfig, startPage int) ([]models.Violation, int) {
+	var vs []models.Violation
+	rules := 0
+
+	if cfg.ForbidAutoHyphenation {
+		rules++
+		if doc.AutoHyphenation {
+			vs = append(vs, models.Violation{
+				RuleType:      "hyphenation_auto",
+				Description:   "Включена автоматическая расстановка переносов",
+				PositionInDoc: "Глобально",
+				ExpectedValue: "Переносы не расставляются",
+				ActualValue:   "Автоматическая расстановка",
+				Suggestion:    "Выберите «Макет → Расстановка переносов → Нет»",
+				Severity:      "error",
+			})
+		}
+	}
+
+	if !cfg.ForbidHeadingHyphenation {
+		return vs, rules
+	}
+	autoFirst, autoCount := -1, 0
+	for i, p := range doc.Paragraphs {
+		text := strings.TrimSpace(p.Text)
+		if text == "" || p.Role != "heading" || !isHeadingParagraph(p) || (startPage > 1 && p.PageNumber < startPage) {
+			continue
+		}
+		rules++
+		if p.SoftHyphens > 0 {
+			vs = append(vs, models.Violation{
+				RuleType:      "heading_hyphenation",
+				Description:   "Перенос в заголовке",
+				PositionInDoc: fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(text, 100)),
+				ExpectedValue: "Заголовок без переносов",
+				ActualValue:   fmt.Sprintf("Мягких переносов: %d", p.SoftHyphens),
+				Suggestion:    "Удалите мягкие переносы из заголовка (они видны в режиме «Отобразить все знаки» как «¬»)",
+				Severity:      "error",
+				ContextText:   contextSnippet(p.Text),
+				Location:      paragraphLocation(i, p),
+			})
+			continue
+		}
+		if doc.AutoHyphenation && !p.SuppressAutoHyphens {
+			if autoFirst < 0 {
+				autoFirst = i
+			}
+			autoCount++
+		}
+	}
+	if autoCount > 0 {
+		p := doc.Paragraphs[autoFirst]
+		vs = append(vs, models.Violation{
+			RuleType:      "heading_hyphenation",
+			Description:   "Заголовки могут быть перенесены автоматически",
+			PositionInDoc: fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, autoFirst+1, truncate(strings.TrimSpace(p.Text), 100)),
+			ExpectedValue: "Запрет автоматического переноса в заголовках",
+			ActualValue:   fmt.Sprintf("Переносы разрешены в %d заг.", autoCount),
+			Suggestion:    "Включите «Абзац → Положение на странице → Запретить автоматический перенос слов» в стилях заголовков",
+			Severity:      "warning",
+			IsDoubtful:    true,
+			ContextText:   contextSnippet(p.Text),
+			Location:      paragraphLocation(autoFirst, p),
+		})
+	}
+	return vs, rules
+}
@@ -0,0 +1,219 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// IntegrityConfig enables the search for formatting that inflates the page
+// count of a work: huge spacing, oversized fonts on single paragraphs,
+// invisible text, widened margins of single sections and stretched images.
+// Findings have models.SeverityIntegrity: teachers see them, students do
+// not, and the score is not affected.
+type IntegrityConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Thresholds of the integrity rules. They are deliberately far from any
+// requirement of ГОСТ 7.32 so that only evident tricks are flagged.
+const (
+	integrityMaxSpacingPt     = 36.0 // spacing before or after a text paragraph
+	integrityMaxEmptyRun      = 4    // empty paragraphs in a row
+	integrityFontGainPt       = 4.0  // over the body font size
+	integrityMinFillerRun     = 20   // spaces in a row
+	integrityMinHiddenChars   = 20   // hidden or white characters in a paragraph
+	integrityMinZeroWidth     = 5    // zero-width characters in a paragraph
+	integrityMarginGainMm     = 10.0 // over the margins of the main section
+	integrityMinImageDPI      = 50.0 // a picture shown at a lower resolution is stretched
+	integrityMinImageWidthMm  = 50.0 // smaller pictures are not checked for stretching
+	integrityMaxAspectChange  = 0.2  // relative change of the picture proportions
+	integrityMinParagraphText = 40   // characters of a paragraph checked for its font size
+)
+
+// zeroWidthChars are characters printed with no width.
+var zeroWidthChars = []rune{'\u200b', '\u200c', '\u200d', '\u2060', '\ufeff'}
+
+// checkIntegrity looks for formatting tricks that inflate the page count. The
+// title page (page 1) and the table of contents are skipped, as are pages
+// before the start page of the check.
+func checkIntegrity(doc *ParsedDoc, startPage int) []models.Violation {
+	var violations []models.Violation
+	firstPage := 2
+	if startPage > firstPage {
+		firstPage = startPage
+	}
+	add := func(i int, v models.Violation) {
+		p := doc.Paragraphs[i]
+		v.PositionInDoc = fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(strings.TrimSpace(p.Text), 100))
+		v.ContextText = contextSnippet(p.Text)
+		v.Location = paragraphLocation(i, p)
+		v.Severity = models.SeverityIntegrity
+		violations = append(violations, v)
+	}
+
+	bodySize := modalFontSize(doc.Paragraphs)
+	emptyRun := 0
+	for i, p := range doc.Paragraphs {
+		if p.PageNumber < firstPage || p.Role == "toc" {
+			emptyRun = 0
+			continue
+		}
+		text := strings.TrimSpace(p.Text)
+		if text == "" {
+			emptyRun++
+			if emptyRun == integrityMaxEmptyRun+1 {
+				add(i, models.Violation{
+					RuleType:      "integrity_empty_paragraphs",
+					Description:   "Много пустых абзацев подряд",
+					ExpectedValue: fmt.Sprintf("не более %d", integrityMaxEmptyRun),
+					ActualValue:   fmt.Sprintf("более %d", integrityMaxEmptyRun),
+					Suggestion:    "Пустые абзацы увеличивают объём работы; новый раздел начинают разрывом страницы",
+				})
+			}
+			continue
+		}
+		emptyRun = 0
+
+		if !isHeadingParagraph(p) {
+			if spacing := math.Max(p.SpacingBeforePt, p.SpacingAfterPt); spacing > integrityMaxSpacingPt {
+				add(i, withValues(models.Violation{
+					RuleType:    "integrity_spacing",
+					Description: "Завышенный интервал перед абзацем или после него",
+					Suggestion:  "Интервал такой величины увеличивает объём работы без текста",
+				}, models.UnitPoint, integrityMaxSpacingPt, spacing))
+			}
+			if bodySize > 0 && p.FontSizePt >= bodySize+integrityFontGainPt && utf8.RuneCountInString(text) >= integrityMinParagraphText {
+				add(i, withValues(models.Violation{
+					RuleType:    "integrity_font_size",
+					Description: "Абзац текста набран крупнее основного текста",
+					Suggestion:  "Отдельные абзацы крупным шрифтом увеличивают объём работы",
+				}, models.UnitPoint, bodySize, p.FontSizePt))
+			}
+		}
+
+		zeroWidth := 0
+		for _, r := range zeroWidthChars {
+			zeroWidth += strings.Count(p.Text, string(r))
+		}
+		filler := longestSpaceRun(p.Text)
+		if p.HiddenChars >= integrityMinHiddenChars || zeroWidth >= integrityMinZeroWidth || filler >= integrityMinFillerRun {
+			var found []string
+			if p.HiddenChars >= integrityMinHiddenChars {
+				found = append(found, fmt.Sprintf("скрытый или белый текст: %d симв.", p.HiddenChars))
+			}
+			if zeroWidth >= integrityMinZeroWidth {
+				found = append(found, fmt.Sprintf("символы нулевой ширины: %d", zeroWidth))
+			}
+			if filler >= integrityMinFillerRun {
+				found = append(found, fmt.Sprintf("пробелы подряд: %d", filler))
+			}
+			add(i, models.Violation{
+				RuleType:      "integrity_invisible_text",
+				Description:   "Невидимые символы в абзаце",
+				ExpectedValue: "только видимый текст",
+				ActualValue:   strings.Join(found, "; "),
+				Suggestion:    "Удалите скрытый текст и заполнители из пробелов",
+			})
+		}
+	}
+
+	checkSectionMargins(doc, firstPage, add)
+
+	for _, img := range doc.Images {
+		if img.PageNumber < firstPage || img.PixelWidth == 0 || img.PixelHeight == 0 || img.WidthMm < integrityMinImageWidthMm || img.HeightMm == 0 {
+			continue
+		}
+		dpi := float64(img.PixelWidth) / (img.WidthMm / 25.4)
+		aspectChange := math.Abs((img.WidthMm/img.HeightMm)/(float64(img.PixelWidth)/float64(img.PixelHeight)) - 1)
+		if dpi >= integrityMinImageDPI && aspectChange <= integrityMaxAspectChange {
+			continue
+		}
+		actual := fmt.Sprintf("%.0f×%.0f мм при %d×%d пикс.", img.WidthMm, img.HeightMm, img.PixelWidth, img.PixelHeight)
+		if aspectChange > integrityMaxAspectChange {
+			actual += ", пропорции изменены"
+		}
+		add(img.ParagraphIndex, models.Violation{
+			RuleType:      "integrity_image_scale",
+			Description:   "Рисунок сильно растянут",
+			ExpectedValue: fmt.Sprintf("не менее %.0f точек на дюйм, исходные пропорции", integrityMinImageDPI),
+			ActualValue:   actual,
+			Suggestion:    "Растянутый рисунок увеличивает объём работы; вставьте его в исходном размере",
+		})
+	}
+	return violations
+}
+
+// checkSectionMargins compares the margins of every section with those of
+// the main section, the one holding most paragraphs. Sections of another
+// orientation are skipped: their margins are turned with the page.
+func checkSectionMargins(doc *ParsedDoc, firstPage int, add func(int, models.Violation)) {
+	if len(doc.Sections) < 2 {
+		return
+	}
+	main := doc.Sections[mainSection(doc.Sections)]
+
+	for _, s := range doc.Sections {
+		if s.Orientation != main.Orientation {
+			continue
+		}
+		// A section opening on the title page is reported at its first
+		// paragraph past it
+		at := s.ParagraphStart
+		for at < s.ParagraphEnd && at < len(doc.Paragraphs) && doc.Paragraphs[at].PageNumber < firstPage {
+			at++
+		}
+		if at >= s.ParagraphEnd || at >= len(doc.Paragraphs) {
+			continue
+		}
+		gain := math.Max(math.Max(s.Margins.LeftMm-main.Margins.LeftMm, s.Margins.RightMm-main.Margins.RightMm),
+			math.Max(s.Margins.TopMm-main.Margins.TopMm, s.Margins.BottomMm-main.Margins.BottomMm))
+		if gain <= integrityMarginGainMm {
+			continue
+		}
+		add(at, models.Violation{
+			RuleType:    "integrity_section_margins",
+			Description: "Поля раздела шире полей основного текста",
+			ExpectedValue: fmt.Sprintf("%.0f/%.0f/%.0f/%.0f мм", main.Margins.LeftMm, main.Margins.RightMm,
+				main.Margins.TopMm, main.Margins.BottomMm),
+			ActualValue: fmt.Sprintf("%.0f/%.0f/%.0f/%.0f мм", s.Margins.LeftMm, s.Margins.RightMm,
+				s.Margins.TopMm, s.Margins.BottomMm),
+			Suggestion: "Широкие поля отдельного раздела увеличивают объём работы; задайте поля как у всего документа",
+		})
+	}
+}
+
+// modalFontSize is the font size of most of the text outside headings.
+func modalFontSize(paragraphs []ParsedParagraph) float64 {
+	chars := map[float64]int{}
+	for _, p := range paragraphs {
+		if p.FontSizePt > 0 && !isHeadingParagraph(p) {
+			chars[p.FontSizePt] += utf8.RuneCountInString(p.Text)
+		}
+	}
+	size, most := 0.0, 0
+	for s, n := range chars {
+		if n > most || (n == most && s < size) {
+			size, most = s, n
+		}
+	}
+	return size
+}
+
+// longestSpaceRun returns the longest run of spaces and no-break spaces.
+func longestSpaceRun(s string) int {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == ' ' || r == '\u00a0' {
+			run++
+			if run > longest {
+				longest = run
+			}
+			continue
+		}
+		run = 0
+	}
+	return longest
+}
@@ -0,0 +1,360 @@
+package checker
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The page layout estimate places paragraphs and tables on pages when the
+// document carries no page breaks rendered by the editor (w:lastRenderedPageBreak,
+// text:soft-page-break): LibreOffice and Google Docs do not write them into
+// .docx files. It is an estimate: glyph widths are averaged per font and
+// hyphenation, floating objects and footnotes are ignored.
+
+// Word's page setup when a section sets none: A4 with its default margins.
+const (
+	defaultPageWidthMm    = 210.0
+	defaultPageHeightMm   = 297.0
+	defaultMarginTopMm    = 25.4
+	defaultMarginBottomMm = 25.4
+	defaultMarginSideMm   = 31.75
+
+	defaultFontSizePt = 11.0 // docDefaults of a blank Word document
+	lineHeightEm      = 1.15 // single line height of common text fonts
+	tableCellPadMm    = 1.0  // cell margins and borders of a table row
+
+	ptToMm = 25.4 / 72.0
+)
+
+// fontWidthEm is the average advance width of a character of running text,
+// Cyrillic and Latin mixed, in ems.
+var fontWidthEm = map[string]float64{
+	"times new roman":  0.45,
+	"liberation serif": 0.45,
+	"pt serif":         0.48,
+	"georgia":          0.5,
+	"cambria":          0.47,
+	"arial":            0.52,
+	"liberation sans":  0.52,
+	"pt sans":          0.5,
+	"calibri":          0.46,
+	"verdana":          0.58,
+	"tahoma":           0.52,
+	"courier new":      0.6,
+	"consolas":         0.55,
+}
+
+const defaultFontWidthEm = 0.5
+
+// textArea is the part of a page between the margins.
+type textArea struct {
+	widthMm, heightMm float64
+}
+
+func sectionTextArea(sect *SectPr) textArea {
+	width, height := defaultPageWidthMm, defaultPageHeightMm
+	top, bottom, left, right := defaultMarginTopMm, defaultMarginBottomMm, defaultMarginSideMm, defaultMarginSideMm
+	if sect != nil && sect.PgSz != nil {
+		if w := twipsToMm(sect.PgSz.W); w > 0 {
+			width = w
+		}
+		if h := twipsToMm(sect.PgSz.H); h > 0 {
+			height = h
+		}
+	}
+	if sect != nil && sect.PgMar != nil {
+		top, bottom = math.Abs(twipsToMm(sect.PgMar.Top)), math.Abs(twipsToMm(sect.PgMar.Bottom))
+		left, right = twipsToMm(sect.PgMar.Left), twipsToMm(sect.PgMar.Right)
+	}
+	area := textArea{widthMm: width - left - right, heightMm: height - top - bottom}
+	// A broken page setup must not stall the layout
+	if area.widthMm < 20 {
+		area.widthMm = 20
+	}
+	if area.heightMm < 20 {
+		area.heightMm = 20
+	}
+	return area
+}
+
+// pageLayout is the running state of the estimate.
+type pageLayout struct {
+	page   int
+	usedMm float64 // height taken on the current page
+	area   textArea
+}
+
+func (l *pageLayout) newPage() {
+	l.page++
+	l.usedMm = 0
+}
+
+// place puts the lines of a paragraph on the pages and returns the page of
+// the first line. A paragraph that does not fit moves to the next page whole
+// when it is kept together or fewer than two of its lines would stay behind;
+// otherwise it flows over. Spacing before is suppressed at the top of a page.
+func (l *pageLayout) place(beforeMm float64, lines int, lineMm, afterMm float64, keep bool) int {
+	if l.usedMm > 0 && l.usedMm+beforeMm+float64(lines)*lineMm > l.area.heightMm {
+		if fit := int((l.area.heightMm - l.usedMm - beforeMm) / lineMm); keep || fit < 2 {
+			l.newPage()
+		}
+	}
+	if l.usedMm > 0 {
+		l.usedMm += beforeMm
+	}
+	start := 0
+	for remaining := lines; remaining > 0; {
+		fit := int((l.area.heightMm - l.usedMm) / lineMm)
+		if fit < 1 && l.usedMm > 0 {
+			l.newPage()
+			continue
+		}
+		if fit < 1 {
+			fit = 1 // a line higher than the page still takes a page
+		}
+		if fit > remaining {
+			fit = remaining
+		}
+		if start == 0 {
+			start = l.page
+		}
+		l.usedMm += float64(fit) * lineMm
+		remaining -= fit
+		if remaining > 0 {
+			l.newPage()
+		}
+	}
+	l.usedMm = math.Min(l.usedMm+afterMm, l.area.heightMm)
+	return start
+}
+
+// placeHeight puts an unbreakable block, such as a picture or a table row.
+func (l *pageLayout) placeHeight(heightMm float64) int {
+	if l.usedMm > 0 && l.usedMm+heightMm > l.area.heightMm {
+		l.newPage()
+	}
+	l.usedMm = math.Min(l.usedMm+heightMm, l.area.heightMm)
+	return l.page
+}
+
+// estimatePages sets PageNumber of the paragraphs and pictures and
+// Stats.TotalPages from the page layout estimate.
+func estimatePages(doc Document, styles *styleSheet, pd *ParsedDoc) {
+	sections := bodySections(doc)
+	sectionAt := make([]int, len(doc.Body.Paragraphs))
+	for k, s := range sections {
+		for i := s.start; i < s.end && i < len(sectionAt); i++ {
+			sectionAt[i] = k
+		}
+	}
+
+	l := &pageLayout{page: 1, area: sectionTextArea(sections[0].props)}
+	currentSection := 0
+	bodySize := modalFontSize(pd.Paragraphs)
+	if bodySize == 0 {
+		bodySize = defaultFontSizePt
+	}
+
+	blocks := doc.Body.Blocks
+	if len(blocks) == 0 {
+		for i := range doc.Body.Paragraphs {
+			blocks = append(blocks, BodyBlock{Kind: BlockParagraph, Index: i})
+		}
+	}
+	for _, block := range blocks {
+		switch block.Kind {
+		case BlockParagraph:
+			i := block.Index
+			if i < 0 || i >= len(pd.Paragraphs) || i >= len(doc.Body.Paragraphs) {
+				continue
+			}
+			if k := sectionAt[i]; k != currentSection {
+				currentSection = k
+				l.area = sectionTextArea(sections[k].props)
+				if !continuousSection(sections[k].props) && l.usedMm > 0 {
+					l.newPage()
+				}
+			}
+			pd.Paragraphs[i].PageNumber = l.layoutParagraph(doc.Body.Paragraphs[i], pd.Paragraphs[i])
+		case BlockTable:
+			if block.Index >= 0 && block.Index < len(doc.Body.Tbls) {
+				l.layoutTable(doc.Body.Tbls[block.Index], styles, bodySize)
+			}
+		}
+	}
+
+	for i := range pd.Images {
+		if idx := pd.Images[i].ParagraphIndex; idx >= 0 && idx < len(pd.Paragraphs) {
+			pd.Images[i].PageNumber = pd.Paragraphs[idx].PageNumber
+		}
+	}
+	pd.Stats.TotalPages = l.page
+}
+
+func continuousSection(sect *SectPr) bool {
+	return sect != nil && sect.Type != nil && sect.Type.Val == "continuous"
+}
+
+// layoutParagraph places a body paragraph and returns the page it starts
+// on. Page breaks inside the paragraph split it into parts laid out on
+// successive pages.
+func (l *pageLayout) layoutParagraph(para Paragraph, pp ParsedParagraph) int {
+	if para.PPr != nil && para.PPr.PageBreakBefore != nil && l.usedMm > 0 {
+		l.newPage()
+	}
+	sizePt := pp.FontSizePt
+	if sizePt == 0 {
+		sizePt = defaultFontSizePt
+	}
+	lineMm := lineHeightMm(para.PPr, sizePt, pp.LineSpacing)
+	widthMm := l.area.widthMm
+	if para.PPr != nil && para.PPr.Ind != nil {
+		widthMm -= twipsToMm(para.PPr.Ind.Left) + twipsToMm(para.PPr.Ind.Right)
+	}
+	charMm := sizePt * ptToMm * fontWidth(pp.FontName)
+	perLine := int(math.Max(widthMm, 20) / charMm)
+	indentChars := int(pp.FirstLineIndentMm / charMm)
+
+	// Text parts between page breaks, each a list of lines broken by hand
+	parts := [][]string{{""}}
+	var drawingsMm float64
+	for _, r := range paragraphRuns(para) {
+		if r.Br != nil && r.Br.Type == "page" {
+			parts = append(parts, []string{""})
+		} else if r.Br != nil {
+			parts[len(parts)-1] = append(parts[len(parts)-1], "")
+		}
+		part := parts[len(parts)-1]
+		if r.Text != nil {
+			part[len(part)-1] += r.Text.Content
+		}
+		if r.Tab != nil {
+			part[len(part)-1] += "    "
+		}
+		if r.Drawing != nil {
+			if pl := r.Drawing.placement(); pl != nil && pl.Extent != nil {
+				drawingsMm += emuToMm(pl.Extent.Cy)
+			}
+		}
+	}
+
+	start := 0
+	for n, part := range parts {
+		if n > 0 {
+			l.newPage()
+		}
+		lines := 0
+		for k, text := range part {
+			chars := utf8.RuneCountInString(text)
+			if k == 0 {
+				chars += indentChars
+			}
+			lines += int(math.Max(1, math.Ceil(float64(chars)/float64(perLine))))
+		}
+		// A picture takes a line of its own height; the text beside it, if
+		// any, follows
+		if n == 0 && drawingsMm > 0 {
+			start = l.placeHeight(drawingsMm)
+			if len(part) == 1 && strings.TrimSpace(part[0]) == "" {
+				l.usedMm = math.Min(l.usedMm+pp.SpacingAfterPt*ptToMm, l.area.heightMm)
+				continue
+			}
+			l.place(0, lines, lineMm, pp.SpacingAfterPt*ptToMm, pp.KeepLines)
+			continue
+		}
+		if pp.KeepNext && l.usedMm > 0 && l.usedMm+float64(lines+2)*lineMm > l.area.heightMm {
+			// A heading kept with the next paragraph does not stay alone at the
+			// foot of a page
+			l.newPage()
+		}
+		page := l.place(pp.SpacingBeforePt*ptToMm, lines, lineMm, pp.SpacingAfterPt*ptToMm, pp.KeepLines)
+		if n == 0 {
+			start = page
+		}
+	}
+	return start
+}
+
+// layoutTable places a table row by row; a row is not split between pages.
+func (l *pageLayout) layoutTable(tbl Tbl, styles *styleSheet, bodySize float64) {
+	columns := 0
+	if tbl.TblGrid != nil {
+		columns = len(tbl.TblGrid.GridCols)
+	}
+	for _, tr := range tbl.Trs {
+		cols := columns
+		if cols < len(tr.Tcs) {
+			cols = len(tr.Tcs)
+		}
+		rowMm := 0.0
+		for _, tc := range tr.Tcs {
+			cellMm := l.area.widthMm / float64(cols)
+			if tc.TcPr != nil && tc.TcPr.TcW != nil && (tc.TcPr.TcW.Type == "dxa" || tc.TcPr.TcW.Type == "") {
+				if w := twipsToMm(tc.TcPr.TcW.W); w > 0 {
+					cellMm = w
+				}
+			}
+			heightMm := tableCellPadMm
+			for _, para := range tc.P {
+				styleID := ""
+				if para.PPr != nil && para.PPr.PStyle != nil {
+					styleID = para.PPr.PStyle.Val
+				}
+				rs := styles.resolve(styleID)
+				runs := paragraphRuns(para)
+				if len(runs) > 0 {
+					styles.overlayRun(&rs, runs[0].RPr)
+				}
+				sizePt := rs.FontSizePt
+				if sizePt == 0 {
+					sizePt = bodySize
+				}
+				var text strings.Builder
+				for _, r := range runs {
+					if r.Text != nil {
+						text.WriteString(r.Text.Content)
+					}
+				}
+				perLine := math.Max(1, math.Floor((cellMm-2*tableCellPadMm)/(sizePt*ptToMm*defaultFontWidthEm)))
+				lines := math.Max(1, math.Ceil(float64(utf8.RuneCountInString(text.String()))/perLine))
+				heightMm += lines * lineHeightMm(para.PPr, sizePt, rs.LineSpacing)
+			}
+			rowMm = math.Max(rowMm, heightMm)
+		}
+		if tr.TrPr != nil && tr.TrPr.TrHeight != nil {
+			if h := twipsToMm(tr.TrPr.TrHeight.Val); h > 0 && (tr.TrPr.TrHeight.HRule == "exact" || h > rowMm) {
+				rowMm = h
+			}
+		}
+		l.placeHeight(math.Min(rowMm, l.area.heightMm))
+	}
+}
+
+// lineHeightMm is the height of one line of a paragraph. Exact and at-least
+// line spacing set the height in twips; otherwise it is a multiple of the
+// single line height of the font.
+func lineHeightMm(ppr *PPr, sizePt, multiple float64) float64 {
+	single := sizePt * lineHeightEm * ptToMm
+	if ppr != nil && ppr.Spacing != nil && ppr.Spacing.Line != "" && (ppr.Spacing.LineRule == "exact" || ppr.Spacing.LineRule == "atLeast") {
+		if twips, err := strconv.Atoi(ppr.Spacing.Line); err == nil && twips > 0 {
+			h := float64(twips) / 20 * ptToMm
+			if ppr.Spacing.LineRule == "atLeast" {
+				h = math.Max(h, single)
+			}
+			return h
+		}
+	}
+	if multiple <= 0 {
+		multiple = 1
+	}
+	return single * multiple
+}
+
+func fontWidth(name string) float64 {
+	if w, ok := fontWidthEm[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return w
+	}
+	return defaultFontWidthEm
+}
@@ -0,0 +1,206 @@
+package checker
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParserLimits bounds the DOCX package and the document.xml the parser is
+// willing to decode. A zero value disables the corresponding limit.
+type ParserLimits struct {
+	MaxXMLBytes   int64
+	MaxXMLDepth   int
+	MaxParagraphs int
+	MaxTables     int
+
+	// Zip-bomb protection for the package itself
+	MaxZipEntries int
+	MaxEntryBytes int64 // decompressed size of a single entry
+	MaxTotalBytes int64 // decompressed size of all entries together
+}
+
+// BuiltinParserLimits returns the default limits without consulting the
+// environment.
+func BuiltinParserLimits() ParserLimits {
+	return ParserLimits{
+		MaxXMLBytes:   50 << 20,
+		MaxXMLDepth:   256,
+		MaxParagraphs: 50000,
+		MaxTables:     2000,
+		MaxZipEntries: 2000,
+		MaxEntryBytes: 100 << 20,
+		MaxTotalBytes: 500 << 20,
+	}
+}
+
+// DefaultParserLimits reads the limits from PARSER_MAX_XML_MB,
+// PARSER_MAX_XML_DEPTH, PARSER_MAX_PARAGRAPHS, PARSER_MAX_TABLES,
+// PARSER_MAX_ZIP_ENTRIES, PARSER_MAX_ENTRY_MB and PARSER_MAX_TOTAL_MB,
+// falling back to BuiltinParserLimits.
+func DefaultParserLimits() ParserLimits {
+	d := BuiltinParserLimits()
+	return ParserLimits{
+		MaxXMLBytes:   int64(envInt("PARSER_MAX_XML_MB", int(d.MaxXMLBytes>>20))) << 20,
+		MaxXMLDepth:   envInt("PARSER_MAX_XML_DEPTH", d.MaxXMLDepth),
+		MaxParagraphs: envInt("PARSER_MAX_PARAGRAPHS", d.MaxParagraphs),
+		MaxTables:     envInt("PARSER_MAX_TABLES", d.MaxTables),
+		MaxZipEntries: envInt("PARSER_MAX_ZIP_ENTRIES", d.MaxZipEntries),
+		MaxEntryBytes: int64(envInt("PARSER_MAX_ENTRY_MB", int(d.MaxEntryBytes>>20))) << 20,
+		MaxTotalBytes: int64(envInt("PARSER_MAX_TOTAL_MB", int(d.MaxTotalBytes>>20))) << 20,
+	}
+}
+
+func envInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key))); err == nil && n >= 0 {
+		return n
+	}
+	return fallback
+}
+
+// ComplexityError is returned by the parser when a document exceeds one of
+// the configured ParserLimits.
+type ComplexityError struct {
+	Limit  string // what was exceeded, e.g. "paragraphs"
+	Actual int64
+	Max    int64
+}
+
+func (e *ComplexityError) Error() string {
+	return fmt.Sprintf("document too complex: %s exceeds the limit of %d (found at least %d)", e.Limit, e.Max, e.Actual)
+}
+
+// Violation describes the exceeded limit for the check report.
+func (e *ComplexityError) Violation() models.Violation {
+	return models.Violation{
+		RuleType:      "document_complexity",
+		Description:   "Документ слишком сложный для автоматической проверки",
+		PositionInDoc: "Глобально",
+		ExpectedValue: fmt.Sprintf("%s: не более %d", complexityLabels[e.Limit], e.Max),
+		ActualValue:   fmt.Sprintf("%s: %d", complexityLabels[e.Limit], e.Actual),
+		Severity:      "critical",
+	}
+}
+
+var complexityLabels = map[string]string{
+	"xml_size":       "Размер разметки (байт)",
+	"xml_depth":      "Вложенность разметки",
+	"paragraphs":     "Абзацев",
+	"tables":         "Таблиц",
+	"zip_entries":    "Файлов в архиве DOCX",
+	"zip_entry_size": "Распакованный размер файла архива (байт)",
+	"zip_total_size": "Распакованный размер архива (байт)",
+}
+
+// ComplexityResult builds the check result reported for a document that was
+// rejected by the parser limits.
+func ComplexityResult(err *ComplexityError) (*models.CheckResult, []models.Violation) {
+	violations := []models.Violation{err.Violation()}
+	return &models.CheckResult{
+		OverallScore: 0,
+		TotalRules:   1,
+		FailedRules:  1,
+		ContentJSON:  "{}",
+		Summary:      Summarize(violations),
+	}, violations
+}
+
+// checkZipPackage validates the entry count and the declared decompressed sizes
+// of a DOCX package before anything is extracted. The declared sizes can lie, so
+// entries are additionally read through readEntryLimited.
+func checkZipPackage(files []*zip.File, limits ParserLimits) error {
+	if limits.MaxZipEntries > 0 && len(files) > limits.MaxZipEntries {
+		return &ComplexityError{Limit: "zip_entries", Actual: int64(len(files)), Max: int64(limits.MaxZipEntries)}
+	}
+	var total uint64
+	for _, f := range files {
+		if limits.MaxEntryBytes > 0 && f.UncompressedSize64 > uint64(limits.MaxEntryBytes) {
+			return &ComplexityError{Limit: "zip_entry_size", Actual: int64(f.UncompressedSize64), Max: limits.MaxEntryBytes}
+		}
+		total += f.UncompressedSize64
+		if limits.MaxTotalBytes > 0 && total > uint64(limits.MaxTotalBytes) {
+			return &ComplexityError{Limit: "zip_total_size", Actual: int64(total), Max: limits.MaxTotalBytes}
+		}
+	}
+	return nil
+}
+
+// readEntryLimited reads a package entry, never decompressing more than the
+// per-entry limit or xmlLimit, whichever is smaller.
+func readEntryLimited(f *zip.File, limits ParserLimits, xmlLimit int64) ([]byte, error) {
+	limit, label := xmlLimit, "xml_size"
+	if limits.MaxEntryBytes > 0 && (limit <= 0 || limits.MaxEntryBytes < limit) {
+		limit, label = limits.MaxEntryBytes, "zip_entry_size"
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := readXMLLimited(rc, limit)
+	if ce, ok := err.(*ComplexityError); ok {
+		ce.Limit = label
+	}
+	return data, err
+}
+
+// checkXMLComplexity streams through document.xml and stops at the first
+// exceeded structural limit, before the document is decoded into memory.
+func checkXMLComplexity(r io.Reader, limits ParserLimits) error {
+	dec := xml.NewDecoder(r)
+	depth, paragraphs, tables := 0, 0, 0
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("xml decode error: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if limits.MaxXMLDepth > 0 && depth > limits.MaxXMLDepth {
+				return &ComplexityError{Limit: "xml_depth", Actual: int64(depth), Max: int64(limits.MaxXMLDepth)}
+			}
+			switch t.Name.Local {
+			case "p":
+				paragraphs++
+				if limits.MaxParagraphs > 0 && paragraphs > limits.MaxParagraphs {
+					return &ComplexityError{Limit: "paragraphs", Actual: int64(paragraphs), Max: int64(limits.MaxParagraphs)}
+				}
+			case "tbl":
+				tables++
+				if limits.MaxTables > 0 && tables > limits.MaxTables {
+					return &ComplexityError{Limit: "tables", Actual: int64(tables), Max: int64(limits.MaxTables)}
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// readXMLLimited reads an XML part, failing with a ComplexityError when it is
+// larger than maxBytes (0 means unlimited).
+func readXMLLimited(r io.Reader, maxBytes int64) ([]byte, error) {
+	if maxBytes <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, &ComplexityError{Limit: "xml_size", Actual: int64(len(data)), Max: maxBytes}
+	}
+	return data, nil
+}
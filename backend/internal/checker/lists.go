@@ -0,0 +1,243 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// ListsConfig holds the rules for enumerations (ГОСТ 7.32 п. 6.2.4 and
+// ГОСТ 2.105): the marker, the punctuation and case of the items, the indent
+// and the nesting depth.
+type ListsConfig struct {
+	Enabled         bool    `json:"enabled"`
+	MarkerType      string  `json:"marker_type"`      // dash, bullet, numbered; empty = any
+	NumberFormat    string  `json:"number_format"`    // marker of numbered lists as printed: "1)", "1.", "а)"; empty = any
+	ItemPunctuation string  `json:"item_punctuation"` // ";" (the last item ends with "."), "."; empty = not checked
+	ItemCase        string  `json:"item_case"`        // lower, upper: first letter of an item; empty = not checked
+	IndentMm        float64 `json:"indent_mm"`        // marker position of first-level items; 0 = not checked
+	MaxDepth        int     `json:"max_depth"`        // nesting levels; 0 = not checked
+	IndentTolerance float64 `json:"indent_tolerance"` // mm; 0 = deployment default
+}
+
+var listMarkerTypeNames = map[string]string{
+	"dash":     "тире",
+	"bullet":   "маркер",
+	"numbered": "нумерация",
+}
+
+// listMarkerType classifies the marker of a list item as dash, bullet or
+// numbered; "" when numbering.xml does not define it.
+func listMarkerType(p ParsedParagraph) string {
+	switch p.ListFormat {
+	case "", "none":
+		return ""
+	case "bullet":
+		switch strings.TrimSpace(p.ListMarker) {
+		case "–", "—", "-", "−", "‒":
+			return "dash"
+		}
+		return "bullet"
+	}
+	return "numbered"
+}
+
+// checkLists checks the list items of the document. Consecutive list items
+// form one list; the bibliography, whose entries are usually a numbered list,
+// and the table of contents are skipped.
+func checkLists(paragraphs []ParsedParagraph, cfg ListsConfig, refCfg ReferencesConfig, startPage int) ([]models.Violation, int) {
+	var violations []models.Violation
+	totalRules := 0
+
+	var lists [][]int
+	var current []int
+	inReferences := false
+	for i, p := range paragraphs {
+		if strings.TrimSpace(p.Text) == "" {
+			continue
+		}
+		if isReferenceHeading(p.Text, refCfg) {
+			inReferences = true
+		} else if inReferences && isHeadingParagraph(p) {
+			inReferences = false
+		}
+		if !p.IsListItem || inReferences || p.Role == "toc" || (startPage > 1 && p.PageNumber < startPage) {
+			if current != nil {
+				lists = append(lists, current)
+				current = nil
+			}
+			continue
+		}
+		current = append(current, i)
+	}
+	if current != nil {
+		lists = append(lists, current)
+	}
+
+	for _, items := range lists {
+		first := paragraphs[items[0]]
+		pos := func(i int) string {
+			p := paragraphs[i]
+			return fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(strings.TrimSpace(p.Text), 100))
+		}
+		add := func(i int, v models.Violation) {
+			v.PositionInDoc = pos(i)
+			v.ContextText = contextSnippet(paragraphs[i].Text)
+			v.Location = paragraphLocation(i, paragraphs[i])
+			if v.Severity == "" {
+				v.Severity = "warning"
+			}
+			violations = append(violations, v)
+		}
+
+		// The marker is reported once per list
+		if cfg.MarkerType != "" {
+			if actual := listMarkerType(first); actual != "" {
+				totalRules++
+				if actual != cfg.MarkerType {
+					add(items[0], models.Violation{
+						RuleType:      "list_marker",
+						Description:   "Неверный тип маркера списка",
+						ExpectedValue: listMarkerTypeNames[cfg.MarkerType],
+						ActualValue:   fmt.Sprintf("%s «%s»", listMarkerTypeNames[actual], first.ListMarker),
+						Suggestion:    "Измените маркер списка через «Главная → Маркеры» или «Нумерация»",
+					})
+				}
+			}
+		}
+
+		// The number format applies to first-level items of numbered lists
+		if cfg.NumberFormat != "" {
+			for _, i := range items {
+				p := paragraphs[i]
+				if p.ListLevel != 0 || listMarkerType(p) != "numbered" {
+					continue
+				}
+				totalRules++
+				if strings.TrimSpace(p.ListMarker) != cfg.NumberFormat {
+					add(i, models.Violation{
+						RuleType:      "list_number_format",
+						Description:   "Неверный формат номера списка",
+						ExpectedValue: fmt.Sprintf("«%s»", cfg.NumberFormat),
+						ActualValue:   fmt.Sprintf("«%s»", p.ListMarker),
+						Suggestion:    "Задайте формат номера в «Нумерация → Определить новый формат номера»",
+					})
+				}
+				break
+			}
+		}
+
+		for k, i := range items {
+			p := paragraphs[i]
+			text := strings.TrimSpace(p.Text)
+			last := k == len(items)-1
+			// An item introducing a nested list ends with a colon
+			introducesNested := !last && paragraphs[items[k+1]].ListLevel > p.ListLevel
+
+			if cfg.ItemPunctuation != "" {
+				totalRules++
+				expected := cfg.ItemPunctuation
+				if last {
+					expected = "."
+				}
+				end, _ := lastRune(text)
+				if string(end) != expected && !(introducesNested && end == ':') {
+					add(i, models.Violation{
+						RuleType:      "list_punctuation",
+						Description:   "Неверный знак в конце элемента списка",
+						ExpectedValue: fmt.Sprintf("«%s»", expected),
+						ActualValue:   fmt.Sprintf("«%s»", string(end)),
+						Suggestion:    listPunctuationHint(cfg.ItemPunctuation),
+					})
+				}
+			}
+
+			if cfg.ItemCase != "" {
+				if word := firstWord(text); word != "" && !isAbbreviation(word) {
+					totalRules++
+					r := []rune(word)[0]
+					if (cfg.ItemCase == "lower" && unicode.IsUpper(r)) || (cfg.ItemCase == "upper" && unicode.IsLower(r)) {
+						expected, actual := "со строчной буквы", "с прописной буквы"
+						if cfg.ItemCase == "upper" {
+							expected, actual = actual, expected
+						}
+						add(i, models.Violation{
+							RuleType:      "list_case",
+							Description:   "Неверный регистр первой буквы элемента списка",
+							ExpectedValue: expected,
+							ActualValue:   actual,
+							Suggestion:    fmt.Sprintf("Начните элемент списка %s", expected),
+							IsDoubtful:    cfg.ItemCase == "lower", // proper names keep the capital
+						})
+					}
+				}
+			}
+
+			if cfg.MaxDepth > 0 {
+				totalRules++
+				if p.ListLevel+1 > cfg.MaxDepth {
+					add(i, models.Violation{
+						RuleType:      "list_depth",
+						Description:   "Слишком глубокая вложенность списка",
+						ExpectedValue: fmt.Sprintf("не более %d", cfg.MaxDepth),
+						ActualValue:   fmt.Sprintf("%d", p.ListLevel+1),
+						Suggestion:    "Сократите вложенность списка или оформите его как отдельные пункты текста",
+					})
+				}
+			}
+
+			if cfg.IndentMm > 0 && p.ListLevel == 0 && p.ListFormat != "" {
+				totalRules++
+				if math.Abs(p.ListIndentMm-cfg.IndentMm) > cfg.IndentTolerance {
+					add(i, withValues(models.Violation{
+						RuleType:    "list_indent",
+						Description: "Неверный отступ элемента списка",
+						Suggestion:  "Задайте положение маркера в «Абзац → Отступ» или «Изменить отступы в списке»",
+					}, models.UnitMillimeter, cfg.IndentMm, p.ListIndentMm))
+				}
+			}
+		}
+	}
+	return violations, totalRules
+}
+
+func listPunctuationHint(punctuation string) string {
+	if punctuation == ";" {
+		return "Элементы списка заканчиваются точкой с запятой, последний — точкой"
+	}
+	return "Каждый элемент списка заканчивается точкой"
+}
+
+func lastRune(s string) (rune, bool) {
+	r := []rune(s)
+	if len(r) == 0 {
+		return 0, false
+	}
+	return r[len(r)-1], true
+}
+
+// firstWord returns the first word of an item, skipping quotes and other
+// leading punctuation.
+func firstWord(s string) string {
+	s = strings.TrimLeftFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end]
+}
+
+// isAbbreviation reports whether word is written in capitals ("SQL", "ГОСТ"),
+// which keeps its case at the start of an item.
+func isAbbreviation(word string) bool {
+	letters := 0
+	for _, r := range word {
+		if unicode.IsLower(r) {
+			return false
+		}
+		letters++
+	}
+	return letters > 1
+}
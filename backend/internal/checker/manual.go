@@ -0,0 +1,61 @@
+package checker
+
+import (
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"strings"
+)
+
+// ManualCheck is a requirement of the standard that the normocontroller
+// checks by hand, e.g. because it cannot be automated yet or the automated
+// rule is unreliable for this kind of work. The violations of RuleType are
+// not reported; instead the check is listed with models.SeverityManual so
+// the report still covers the whole checklist of the методичка.
+type ManualCheck struct {
+	RuleType      string `json:"rule_type"`     // automated rule it replaces; empty when there is none
+	Title         string `json:"title"`         // requirement as printed in the report
+	Justification string `json:"justification"` // why it is checked by hand
+}
+
+// manualCheckRule is the rule type of manual checks that replace no
+// automated rule.
+const manualCheckRule = "manual_check"
+
+// applyManualChecks removes the violations of the rules checked by hand and
+// appends one manual entry per check.
+func applyManualChecks(vs []models.Violation, checks []ManualCheck) []models.Violation {
+	if len(checks) == 0 {
+		return vs
+	}
+	manual := map[string]bool{}
+	for _, m := range checks {
+		if rule := strings.TrimSpace(m.RuleType); rule != "" {
+			manual[rule] = true
+		}
+	}
+	kept := vs[:0]
+	for _, v := range vs {
+		// Integrity findings are for teachers and stay regardless.
+		if !manual[v.RuleType] || v.Severity == models.SeverityIntegrity {
+			kept = append(kept, v)
+		}
+	}
+	for _, m := range checks {
+		rule := strings.TrimSpace(m.RuleType)
+		if rule == "" {
+			rule = manualCheckRule
+		}
+		title := strings.TrimSpace(m.Title)
+		if title == "" {
+			title = "Правило «" + rule + "» проверяется вручную"
+		}
+		kept = append(kept, models.Violation{
+			RuleType:      rule,
+			Description:   title,
+			Severity:      models.SeverityManual,
+			PositionInDoc: "Весь документ",
+			ActualValue:   "Требуется проверка нормоконтролёром",
+			Suggestion:    strings.TrimSpace(m.Justification),
+		})
+	}
+	return kept
+}
@@ -0,0 +1,337 @@
+package checker
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MathNode is an element of an OMML formula (m:oMath). The tree is kept
+// generic: formulas nest fractions, scripts, radicals and delimiters to any
+// depth, and mathText reads only the elements it knows.
+type MathNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Text    string     `xml:",chardata"`
+	Nodes   []MathNode `xml:",any"`
+}
+
+// child returns the first child element with the given local name.
+func (n MathNode) child(name string) (MathNode, bool) {
+	for _, c := range n.Nodes {
+		if c.XMLName.Local == name {
+			return c, true
+		}
+	}
+	return MathNode{}, false
+}
+
+// val returns the val attribute of the child element name, e.g. the begChr
+// of m:dPr.
+func (n MathNode) val(name string) (string, bool) {
+	c, ok := n.child(name)
+	if !ok {
+		return "", false
+	}
+	for _, a := range c.Attrs {
+		if a.Name.Local == "val" {
+			return a.Value, true
+		}
+	}
+	return "", true
+}
+
+// mathFunctionNames are names typed upright by convention: functions,
+// operators and the common abbreviations of indices.
+var mathFunctionNames = map[string]bool{
+	"sin": true, "cos": true, "tg": true, "ctg": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctg": true, "arcctg": true, "arctan": true,
+	"sh": true, "ch": true, "th": true, "cth": true, "sinh": true, "cosh": true, "tanh": true,
+	"ln": true, "lg": true, "log": true, "exp": true, "lim": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "deg": true, "arg": true, "sgn": true, "const": true,
+	"grad": true, "div": true, "rot": true, "mod": true, "d": true,
+}
+
+// mathWordRe splits the text of a math run into words of letters.
+var mathWordRe = regexp.MustCompile(`\p{L}+`)
+
+// mathText renders an OMML formula as linear text, e.g. "E=mc^(2)" or
+// "(a+b)/(2)", and collects the properties of its runs on the way.
+type mathText struct {
+	b          strings.Builder
+	fontSizePt float64  // size of the first run setting one
+	upright    []string // Latin and Greek words typed upright
+}
+
+// formulaText renders the formulas of a paragraph.
+func formulaText(nodes []MathNode) mathText {
+	var m mathText
+	for _, n := range nodes {
+		m.walk(n, false)
+	}
+	return m
+}
+
+// render returns the text of n alone, keeping what was found in its runs.
+func (m *mathText) render(n MathNode, inFunc bool) string {
+	var sub mathText
+	sub.walk(n, inFunc)
+	if m.fontSizePt == 0 {
+		m.fontSizePt = sub.fontSizePt
+	}
+	m.upright = append(m.upright, sub.upright...)
+	return sub.b.String()
+}
+
+// renderChild renders the child element name, or "" when it is absent.
+func (m *mathText) renderChild(n MathNode, name string, inFunc bool) string {
+	c, ok := n.child(name)
+	if !ok {
+		return ""
+	}
+	return m.render(c, inFunc)
+}
+
+func (m *mathText) walk(n MathNode, inFunc bool) {
+	name := n.XMLName.Local
+	switch {
+	case name == "r":
+		m.run(n, inFunc)
+	case name == "f":
+		fmt.Fprintf(&m.b, "(%s)/(%s)", m.renderChild(n, "num", inFunc), m.renderChild(n, "den", inFunc))
+	case name == "sSup":
+		fmt.Fprintf(&m.b, "%s^(%s)", m.renderChild(n, "e", inFunc), m.renderChild(n, "sup", inFunc))
+	case name == "sSub":
+		fmt.Fprintf(&m.b, "%s_(%s)", m.renderChild(n, "e", inFunc), m.renderChild(n, "sub", inFunc))
+	case name == "sSubSup":
+		fmt.Fprintf(&m.b, "%s_(%s)^(%s)", m.renderChild(n, "e", inFunc), m.renderChild(n, "sub", inFunc), m.renderChild(n, "sup", inFunc))
+	case name == "sPre":
+		fmt.Fprintf(&m.b, "_(%s)^(%s)%s", m.renderChild(n, "sub", inFunc), m.renderChild(n, "sup", inFunc), m.renderChild(n, "e", inFunc))
+	case name == "rad":
+		if deg := m.renderChild(n, "deg", inFunc); deg != "" {
+			fmt.Fprintf(&m.b, "√[%s](%s)", deg, m.renderChild(n, "e", inFunc))
+		} else {
+			fmt.Fprintf(&m.b, "√(%s)", m.renderChild(n, "e", inFunc))
+		}
+	case name == "d":
+		beg, end, sep := "(", ")", "|"
+		if pr, ok := n.child("dPr"); ok {
+			if v, ok := pr.val("begChr"); ok {
+				beg = v
+			}
+			if v, ok := pr.val("endChr"); ok {
+				end = v
+			}
+			if v, ok := pr.val("sepChr"); ok {
+				sep = v
+			}
+		}
+		var parts []string
+		for _, c := range n.Nodes {
+			if c.XMLName.Local == "e" {
+				parts = append(parts, m.render(c, inFunc))
+			}
+		}
+		m.b.WriteString(beg + strings.Join(parts, sep) + end)
+	case name == "nary":
+		chr := "∫"
+		if pr, ok := n.child("naryPr"); ok {
+			if v, ok := pr.val("chr"); ok && v != "" {
+				chr = v
+			}
+		}
+		m.b.WriteString(chr)
+		if sub := m.renderChild(n, "sub", inFunc); sub != "" {
+			fmt.Fprintf(&m.b, "_(%s)", sub)
+		}
+		if sup := m.renderChild(n, "sup", inFunc); sup != "" {
+			fmt.Fprintf(&m.b, "^(%s)", sup)
+		}
+		m.b.WriteString(m.renderChild(n, "e", inFunc))
+	case name == "func":
+		fmt.Fprintf(&m.b, "%s %s", m.renderChild(n, "fName", true), m.renderChild(n, "e", inFunc))
+	case name == "m":
+		var rows []string
+		for _, row := range n.Nodes {
+			if row.XMLName.Local != "mr" {
+				continue
+			}
+			var cells []string
+			for _, c := range row.Nodes {
+				if c.XMLName.Local == "e" {
+					cells = append(cells, m.render(c, inFunc))
+				}
+			}
+			rows = append(rows, strings.Join(cells, ","))
+		}
+		m.b.WriteString("[" + strings.Join(rows, ";") + "]")
+	case name == "eqArr":
+		var rows []string
+		for _, c := range n.Nodes {
+			if c.XMLName.Local == "e" {
+				rows = append(rows, m.render(c, inFunc))
+			}
+		}
+		m.b.WriteString(strings.Join(rows, "; "))
+	case strings.HasSuffix(name, "Pr"):
+		// properties: nothing to print
+	default:
+		// oMath, e, num, den, acc, bar, box, groupChr, limLow, limUpp, ...
+		for _, c := range n.Nodes {
+			m.walk(c, inFunc)
+		}
+	}
+}
+
+// run adds a math run. Math runs are italic unless m:sty sets a plain
+// style or m:nor marks normal text without w:i; function names are upright
+// anyway.
+func (m *mathText) run(n MathNode, inFunc bool) {
+	var text strings.Builder
+	italic := true
+	normal, wordItalic := false, false
+	for _, c := range n.Nodes {
+		switch c.XMLName.Local {
+		case "t":
+			text.WriteString(c.Text)
+		case "rPr":
+			if sty, ok := c.val("sty"); ok {
+				italic = sty == "i" || sty == "bi"
+			}
+			if _, ok := c.child("nor"); ok {
+				normal = true
+			}
+			if v, ok := c.val("i"); ok && v != "0" && v != "false" {
+				wordItalic = true
+			}
+			if v, ok := c.val("sz"); ok && m.fontSizePt == 0 {
+				if half, err := strconv.Atoi(v); err == nil && half > 0 {
+					m.fontSizePt = float64(half) / 2
+				}
+			}
+		}
+	}
+	if normal {
+		italic = wordItalic
+	}
+	t := text.String()
+	m.b.WriteString(t)
+	if italic || inFunc {
+		return
+	}
+	for _, w := range mathWordRe.FindAllString(t, -1) {
+		if isVariableWord(w) && !mathFunctionNames[strings.ToLower(w)] {
+			m.upright = append(m.upright, w)
+		}
+	}
+}
+
+// isVariableWord reports whether w is written in Latin or Greek letters,
+// which denote quantities. Russian indices ("ср", "max") are upright.
+func isVariableWord(w string) bool {
+	for _, r := range w {
+		if !unicode.In(r, unicode.Latin, unicode.Greek) {
+			return false
+		}
+	}
+	return true
+}
+
+// formulaNumberOnlyRe matches a paragraph holding nothing but a formula
+// number: with a picture, it is a formula pasted as an image.
+var formulaNumberOnlyRe = regexp.MustCompile(`^\s*\(\s*[\dА-Яа-яA-Za-z]+(?:\.\d+)*\s*\)\s*$`)
+
+// formulaObjectRe matches the ProgID of Equation Editor 3.0 and MathType
+// objects, shown in the document as pictures.
+var formulaObjectRe = regexp.MustCompile(`(?i)^(equation|mathtype)`)
+
+// checkFormulaContent checks what formulas are made of: editable formulas
+// rather than pictures, variables in italic, the size of the body text.
+func checkFormulaContent(formulas []ParsedFormula, paragraphs []ParsedParagraph, config FormulaConfig, body FontConfig) ([]models.Violation, int) {
+	var vs []models.Violation
+	rules := 0
+	if !config.ForbidImages && !config.CheckVariablesItalic && !config.CheckFontSize {
+		return vs, 0
+	}
+	paraIndexByID := make(map[string]int, len(paragraphs))
+	for i, p := range paragraphs {
+		paraIndexByID[p.ID] = i
+	}
+
+	for fi, f := range formulas {
+		pos := fmt.Sprintf("Формула %s", f.ID)
+		loc := &models.Location{ParagraphID: f.WrapperID, FormulaIndex: intPtr(fi)}
+		if idx, found := paraIndexByID[f.WrapperID]; found {
+			loc = paragraphLocation(idx, paragraphs[idx])
+			loc.FormulaIndex = intPtr(fi)
+		}
+		add := func(v models.Violation) {
+			v.PositionInDoc = pos
+			v.ContextText = contextSnippet(f.Text)
+			v.Location = loc
+			vs = append(vs, v)
+		}
+
+		if config.ForbidImages {
+			rules++
+			if f.IsImage {
+				add(models.Violation{
+					RuleType:      "formula_image",
+					Description:   "Формула вставлена рисунком или объектом, а не набрана в редакторе формул",
+					ExpectedValue: "Формула редактора Word",
+					ActualValue:   "Рисунок или объект",
+					Suggestion:    "Наберите формулу через «Вставка → Уравнение»: рисунок нельзя проверить и отредактировать",
+					Severity:      "error",
+				})
+			}
+		}
+		if f.IsImage || f.Text == "" {
+			continue
+		}
+		if config.CheckVariablesItalic {
+			rules++
+			if len(f.UprightVariables) > 0 {
+				add(models.Violation{
+					RuleType:      "formula_variable_style",
+					Description:   "Обозначения величин в формуле набраны прямым шрифтом",
+					ExpectedValue: "Латинские и греческие буквы курсивом",
+					ActualValue:   truncate(strings.Join(f.UprightVariables, ", "), 60),
+					Suggestion:    "Уберите «Обычный текст» и прямое начертание у переменных; прямо пишутся только функции (sin, ln) и русские индексы",
+					Severity:      "warning",
+					IsDoubtful:    true,
+				})
+			}
+		}
+		if config.CheckFontSize && f.FontSizePt > 0 && body.Size > 0 {
+			rules++
+			if math.Abs(f.FontSizePt-body.Size) > body.SizeTolerance {
+				add(withValues(models.Violation{
+					RuleType:    "formula_font_size",
+					Description: "Размер шрифта формулы отличается от основного текста",
+					Severity:    "warning",
+				}, models.UnitPoint, body.Size, f.FontSizePt))
+			}
+		}
+	}
+	return vs, rules
+}
+
+// setContent fills the text, size and run styles of f from its OMML tree.
+func (f *ParsedFormula) setContent(maths []OMath, paraSizePt float64) {
+	var nodes []MathNode
+	for _, m := range maths {
+		nodes = append(nodes, m.Nodes...)
+	}
+	m := formulaText(nodes)
+	f.Text = strings.TrimSpace(m.b.String())
+	f.UprightVariables = m.upright
+	f.FontSizePt = m.fontSizePt
+	if f.FontSizePt == 0 {
+		f.FontSizePt = paraSizePt
+	}
+}
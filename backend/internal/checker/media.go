@@ -0,0 +1,42 @@
+package checker
+
+import (
+	"archive/zip"
+	"image"
+	_ "image/gif" // formats whose size image.DecodeConfig reads
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// parseImagePixels reads the pixel size of the pictures behind pd.Images from
+// their headers. Pictures in other formats (EMF, WMF, SVG) keep a zero size.
+func (p *DocParser) parseImagePixels(r *zip.Reader, pd *ParsedDoc) {
+	if len(pd.Images) == 0 {
+		return
+	}
+	files := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+	targets := p.documentTargets(files)
+
+	sizes := map[string]image.Config{}
+	for i := range pd.Images {
+		img := &pd.Images[i]
+		name, ok := targets[img.embed]
+		if !ok {
+			continue
+		}
+		cfg, seen := sizes[name]
+		if !seen {
+			if f := files[name]; f != nil {
+				if rc, err := f.Open(); err == nil {
+					cfg, _, _ = image.DecodeConfig(rc)
+					rc.Close()
+				}
+			}
+			sizes[name] = cfg
+		}
+		img.PixelWidth, img.PixelHeight = cfg.Width, cfg.Height
+	}
+}
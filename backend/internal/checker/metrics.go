@@ -0,0 +1,55 @@
+package checker
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var ruleDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "checker_rule_duration_seconds",
+	Help:    "Time spent in each rule group per document check.",
+	Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10), // 0.1ms .. ~26s
+}, []string{"rule"})
+
+// ruleClock attributes the elapsed time of a check to the rule group being
+// evaluated. Rule groups entered repeatedly (e.g. once per paragraph) accumulate.
+type ruleClock struct {
+	current string
+	started time.Time
+	totals  map[string]time.Duration
+	trace   *ruleTrace // follows the groups entered, if set
+}
+
+func newRuleClock() *ruleClock {
+	return &ruleClock{totals: make(map[string]time.Duration)}
+}
+
+// Enter closes the running rule group and starts timing rule.
+func (c *ruleClock) Enter(rule string) {
+	now := time.Now()
+	if c.current != "" {
+		c.totals[c.current] += now.Sub(c.started)
+	}
+	c.current, c.started = rule, now
+	if c.trace != nil {
+		c.trace.enter(rule)
+	}
+}
+
+func (c *ruleClock) Current() string {
+	return c.current
+}
+
+// Stop closes the running rule group, exports the totals to Prometheus and
+// returns them in milliseconds.
+func (c *ruleClock) Stop() map[string]float64 {
+	c.Enter("")
+	ms := make(map[string]float64, len(c.totals))
+	for rule, d := range c.totals {
+		ruleDuration.WithLabelValues(rule).Observe(d.Seconds())
+		ms[rule] = float64(d.Microseconds()) / 1000
+	}
+	return ms
+}
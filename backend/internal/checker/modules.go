@@ -0,0 +1,267 @@
+package checker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"sort"
+	"sync"
+)
+
+// RuleModule is a group of rules the checker runs over a parsed document.
+// The built-in groups (margins, tables, paragraphs...) are modules, and
+// other packages add their own with RegisterModule, e.g. a requirement of
+// one institution that the built-in rules do not cover. Standards configure
+// modules under "modules" by name:
+//
+//	"modules": {"title_page_stamp": {"position": "bottom"}, "repetition": false}
+//
+// Each check gets a fresh module from the registered factory, configures it
+// and runs it on the parsed document, in the order the modules were
+// registered. Severity overrides and manual checks apply to the violations
+// of every module.
+type RuleModule interface {
+	// Name is the key of the module under "modules" and the name of its
+	// group in the rule trace.
+	Name() string
+	// Configure applies the settings of the module in the standard. An
+	// error skips the module in the check.
+	Configure(config json.RawMessage) error
+	// Check returns the violations found in the document. Violations
+	// without a rule type are reported under the module's name.
+	Check(doc *ParsedDoc) []models.Violation
+}
+
+// RuleCounter is implemented by modules that check more than one rule, so
+// the score weighs their violations against the right number of rules. A
+// module without it counts as one rule.
+type RuleCounter interface {
+	// Rules is the number of rules the last Check applied.
+	Rules() int
+}
+
+// registeredModule is an entry of the module registry. Built-in modules run
+// in every check unless the standard turns them off; the others only when
+// the standard names them.
+type registeredModule struct {
+	name    string
+	factory func() RuleModule
+	builtin bool
+}
+
+var (
+	modulesMu sync.RWMutex
+	registry  []registeredModule
+)
+
+func init() {
+	for _, g := range ruleGroups {
+		g := g
+		registerModule(func() RuleModule { return &builtinModule{name: g.name, check: g.check} }, true)
+	}
+}
+
+// RegisterModule makes a rule module available to standards under the name
+// of the modules its factory returns. Like database/sql drivers, modules are
+// registered once from an init function; they run after the built-in ones.
+// A name that is empty or registered before, built-in ones included, panics.
+func RegisterModule(factory func() RuleModule) {
+	registerModule(factory, false)
+}
+
+func registerModule(factory func() RuleModule, builtin bool) {
+	if factory == nil {
+		panic("checker: RegisterModule factory is nil")
+	}
+	name := factory().Name()
+	if name == "" {
+		panic("checker: RegisterModule module has no name")
+	}
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	for _, r := range registry {
+		if r.name == name {
+			panic("checker: RegisterModule called twice for module " + name)
+		}
+	}
+	registry = append(registry, registeredModule{name: name, factory: factory, builtin: builtin})
+}
+
+// Modules lists the names of the registered rule modules in the order checks
+// run them, built-in ones first.
+func Modules() []string {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+	names := make([]string, len(registry))
+	for i, r := range registry {
+		names[i] = r.name
+	}
+	return names
+}
+
+func registeredModules() []registeredModule {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+	return append([]registeredModule(nil), registry...)
+}
+
+func lookupModule(name string) (registeredModule, bool) {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+	for _, r := range registry {
+		if r.name == name {
+			return r, true
+		}
+	}
+	return registeredModule{}, false
+}
+
+// moduleDisabled reports whether the settings of a module turn it off.
+func moduleDisabled(config json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(config), []byte("false"))
+}
+
+// ValidateModules reports the first module of a standard a check could not
+// run: one that is not registered or that refuses its settings.
+func ValidateModules(config map[string]json.RawMessage) error {
+	for _, name := range sortedKeys(config) {
+		r, ok := lookupModule(name)
+		if !ok {
+			return fmt.Errorf("module %q is not registered", name)
+		}
+		if moduleDisabled(config[name]) {
+			continue
+		}
+		if err := r.factory().Configure(moduleSettings(config[name])); err != nil {
+			return fmt.Errorf("module %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// moduleSettings returns the settings a module is configured with; a module
+// turned on without settings gets an empty object.
+func moduleSettings(config json.RawMessage) json.RawMessage {
+	if len(bytes.TrimSpace(config)) == 0 || bytes.Equal(bytes.TrimSpace(config), []byte("true")) {
+		return json.RawMessage("{}")
+	}
+	return config
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runModules runs the registered modules over the document: the built-in
+// ones unless the standard turns them off, the others when it names them. A
+// module that refuses its settings is skipped and noted in the trace, as is
+// one the standard names but this server does not have; a panic in one names
+// the module.
+func (e *evaluation) runModules() {
+	for _, r := range registeredModules() {
+		config, named := e.config.Modules[r.name]
+		if !named && !r.builtin {
+			continue
+		}
+		e.clock.Enter(r.name)
+		if moduleDisabled(config) {
+			e.trace.skip(r.name, "Модуль отключён в стандарте")
+			continue
+		}
+		m := r.factory()
+		if b, ok := m.(*builtinModule); ok {
+			b.e = e
+		}
+		if err := m.Configure(moduleSettings(config)); err != nil {
+			e.trace.skip(r.name, "Настройки модуля не приняты: "+err.Error())
+			continue
+		}
+		if named && !r.builtin {
+			e.trace.applied(r.name, r.name, config)
+		}
+		for _, v := range m.Check(e.doc) {
+			if !r.builtin {
+				if v.RuleType == "" {
+					v.RuleType = r.name
+				}
+				if !models.IsValidSeverity(v.Severity) {
+					v.Severity = models.SeverityWarning
+				}
+			}
+			e.violations = append(e.violations, v)
+		}
+		if counter, ok := m.(RuleCounter); ok {
+			e.totalRules += counter.Rules()
+		} else {
+			e.totalRules++
+		}
+	}
+	for _, name := range sortedKeys(e.config.Modules) {
+		if _, ok := lookupModule(name); !ok {
+			e.clock.Enter(name)
+			e.trace.skip(name, "Модуль не подключён к этому серверу")
+		}
+	}
+}
+
+// builtinModule runs a built-in rule group. It reads its settings from the
+// sections of the standard, e.g. "tables"; settings under its name in
+// "modules" are a partial standard that replaces them for this group only:
+//
+//	"modules": {"tables": {"tables": {"require_caption": false}}}
+type builtinModule struct {
+	name  string
+	check func(*evaluation)
+
+	e        *evaluation // the check the module runs in
+	override json.RawMessage
+	rules    int
+}
+
+func (m *builtinModule) Name() string { return m.name }
+
+func (m *builtinModule) Configure(config json.RawMessage) error {
+	var probe ConfigSchema
+	if err := json.Unmarshal(config, &probe); err != nil {
+		return err
+	}
+	if !bytes.Equal(bytes.TrimSpace(config), []byte("{}")) {
+		m.override = config
+	}
+	return nil
+}
+
+func (m *builtinModule) Check(doc *ParsedDoc) []models.Violation {
+	if m.e == nil {
+		return nil
+	}
+	group := &evaluation{s: m.e.s, doc: doc, config: m.e.config, trace: m.e.trace, clock: m.e.clock}
+	if m.override != nil {
+		// A copy, so the override does not reach the maps and slices the
+		// standard shares with the other groups.
+		var config ConfigSchema
+		data, err := json.Marshal(m.e.config)
+		if err == nil {
+			err = json.Unmarshal(data, &config)
+		}
+		if err == nil {
+			err = json.Unmarshal(m.override, &config)
+		}
+		if err != nil {
+			m.e.trace.skip(m.name, "Настройки модуля не приняты: "+err.Error())
+			return nil
+		}
+		group.config = config
+	}
+	m.check(group)
+	m.rules = group.totalRules
+	return group.violations
+}
+
+func (m *builtinModule) Rules() int { return m.rules }
@@ -0,0 +1,187 @@
+package checker
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Russian word forms are compared by stem: the Snowball stemmer for Russian
+// cuts the inflectional endings, so «работа», «работы» and «работой» share
+// the stem «работ». Pronouns change their root in declension («мы» — «нас»,
+// «нами»); their forms are looked up in russianPronounForms instead.
+
+// russianPronounForms maps the oblique forms of personal and possessive
+// pronouns to their nominative.
+var russianPronounForms = func() map[string]string {
+	paradigms := map[string]string{
+		"я":    "я меня мне мной мною",
+		"мы":   "мы нас нам нами",
+		"ты":   "ты тебя тебе тобой тобою",
+		"вы":   "вы вас вам вами",
+		"мой":  "мой моя мое мои моего моей моему моим моими моих мою моем",
+		"наш":  "наш наша наше наши нашего нашей нашему нашим нашими наших нашу нашем",
+		"твой": "твой твоя твое твои твоего твоей твоему твоим твоими твоих твою твоем",
+		"ваш":  "ваш ваша ваше ваши вашего вашей вашему вашим вашими ваших вашу вашем",
+	}
+	forms := map[string]string{}
+	for lemma, list := range paradigms {
+		for _, form := range strings.Fields(list) {
+			forms[form] = lemma
+		}
+	}
+	return forms
+}()
+
+// wordFormKey returns what all forms of a word have in common: the
+// nominative of a pronoun or the stem of any other word.
+func wordFormKey(word string) string {
+	word = strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+	if lemma, ok := russianPronounForms[word]; ok {
+		return lemma
+	}
+	return russianStem(word)
+}
+
+var (
+	perfectiveGerund1 = []string{"вшись", "вши", "в"}
+	perfectiveGerund2 = []string{"ившись", "ывшись", "ивши", "ывши", "ив", "ыв"}
+	adjectiveEndings  = []string{"ими", "ыми", "его", "ого", "ему", "ому", "ее", "ие", "ые", "ое", "ей", "ий", "ый",
+		"ой", "ем", "им", "ым", "ом", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"}
+	participle1      = []string{"ем", "нн", "вш", "ющ", "щ"}
+	participle2      = []string{"ивш", "ывш", "ующ"}
+	reflexiveEndings = []string{"ся", "сь"}
+	verbEndings1     = []string{"ете", "йте", "ешь", "нно", "ла", "на", "ли", "ем", "ло", "но", "ет", "ют", "ны", "ть", "й", "л", "н"}
+	verbEndings2     = []string{"ейте", "уйте", "ила", "ыла", "ена", "ите", "или", "ыли", "ило", "ыло", "ено", "ует", "уют",
+		"ены", "ить", "ыть", "ишь", "ей", "уй", "ил", "ыл", "им", "ым", "ен", "ят", "ит", "ыт", "ую", "ю"}
+	nounEndings = []string{"иями", "ями", "ами", "ией", "иям", "ием", "иях", "ев", "ов", "ие", "ье", "еи", "ии", "ей",
+		"ой", "ий", "ям", "ем", "ам", "ом", "ах", "ях", "ию", "ью", "ия", "ья", "а", "е", "и", "й", "о", "у", "ы", "ь",
+		"ю", "я"}
+	superlativeEndings  = []string{"ейше", "ейш"}
+	derivationalEndings = []string{"ость", "ост"}
+)
+
+// russianStem is the Snowball stemmer for Russian. The word is expected in
+// lower case with ё written as е.
+func russianStem(word string) string {
+	w := []rune(word)
+	isVowel := func(r rune) bool { return strings.ContainsRune("аеиоуыэюя", r) }
+	// RV is the region after the first vowel; R2 is the region after the
+	// second vowel–consonant pair.
+	rv := len(w)
+	for i, r := range w {
+		if isVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	region := func(from int) int {
+		for i := from + 1; i < len(w); i++ {
+			if !isVowel(w[i]) && isVowel(w[i-1]) {
+				return i + 1
+			}
+		}
+		return len(w)
+	}
+	r2 := region(region(0))
+
+	// endsWith reports the longest ending of list the word has inside RV.
+	endsWith := func(list []string, min int) int {
+		best := 0
+		for _, e := range list {
+			n := len([]rune(e))
+			if n > best && len(w)-n >= min && string(w[len(w)-n:]) == e {
+				best = n
+			}
+		}
+		return best
+	}
+	// removeGroup removes the longest ending of group1, which must follow «а»
+	// or «я», or of group2.
+	removeGroup := func(group1, group2 []string) bool {
+		n1 := endsWith(group1, rv)
+		if n1 > 0 && len(w)-n1-1 >= rv && (w[len(w)-n1-1] == 'а' || w[len(w)-n1-1] == 'я') {
+			if n2 := endsWith(group2, rv); n2 <= n1 {
+				w = w[:len(w)-n1]
+				return true
+			}
+		}
+		if n2 := endsWith(group2, rv); n2 > 0 {
+			w = w[:len(w)-n2]
+			return true
+		}
+		return false
+	}
+
+	// Step 1
+	if !removeGroup(perfectiveGerund1, perfectiveGerund2) {
+		if n := endsWith(reflexiveEndings, rv); n > 0 {
+			w = w[:len(w)-n]
+		}
+		if n := endsWith(adjectiveEndings, rv); n > 0 {
+			w = w[:len(w)-n]
+			removeGroup(participle1, participle2)
+		} else if !removeGroup(verbEndings1, verbEndings2) {
+			if n := endsWith(nounEndings, rv); n > 0 {
+				w = w[:len(w)-n]
+			}
+		}
+	}
+	// Step 2
+	if len(w) > rv && w[len(w)-1] == 'и' {
+		w = w[:len(w)-1]
+	}
+	// Step 3
+	if n := endsWith(derivationalEndings, r2); n > 0 {
+		w = w[:len(w)-n]
+	}
+	// Step 4
+	if n := endsWith(superlativeEndings, rv); n > 0 {
+		w = w[:len(w)-n]
+	}
+	switch {
+	case len(w)-2 >= rv && string(w[len(w)-2:]) == "нн":
+		w = w[:len(w)-1]
+	case len(w) > rv && w[len(w)-1] == 'ь':
+		w = w[:len(w)-1]
+	}
+	return string(w)
+}
+
+// textWord is a word of a paragraph with its byte offsets.
+type textWord struct {
+	key        string
+	start, end int
+}
+
+// splitWords splits text into words: runs of letters, joined by hyphens as
+// in «как-то».
+func splitWords(text string) []textWord {
+	var words []textWord
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			words = append(words, textWord{key: wordFormKey(text[start:end]), start: start, end: end})
+			start = -1
+		}
+	}
+	prevLetter := false
+	for i, r := range text {
+		switch {
+		case unicode.IsLetter(r):
+			if start < 0 {
+				start = i
+			}
+			prevLetter = true
+			continue
+		case r == '-' && prevLetter:
+			if next, _ := utf8.DecodeRuneInString(text[i+1:]); unicode.IsLetter(next) {
+				continue
+			}
+		}
+		prevLetter = false
+		flush(i)
+	}
+	flush(len(text))
+	return words
+}
@@ -0,0 +1,145 @@
+package checker
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"strings"
+)
+
+// ParsedNote is a footnote or endnote, placed at the body paragraph that
+// references it.
+type ParsedNote struct {
+	ID             string
+	Text           string
+	FontSizePt     float64 // size of the note text; 0 when set nowhere
+	ParagraphIndex int     // body paragraph holding the reference
+	PageNumber     int
+	CustomMark     bool // the reference shows a mark typed by hand instead of the number
+}
+
+// Footnote numbering restarts, in the vocabulary of FootnotesConfig.
+const (
+	footnotesContinuous  = "continuous"
+	footnotesEachPage    = "each_page"
+	footnotesEachSection = "each_section"
+)
+
+// parseNotes fills pd.Footnotes and pd.Endnotes in the order they are
+// referenced from the body, and pd.FootnoteRestart from the footnote
+// properties of word/settings.xml and the last section. Missing or broken
+// parts leave the notes empty. The document-wide hyphenation setting is read
+// from word/settings.xml along the way.
+func (p *DocParser) parseNotes(r *zip.Reader, doc Document, styles *styleSheet, pd *ParsedDoc) {
+	var footnotes, endnotes map[string]ParsedNote
+	var settings SettingsDoc
+	for _, f := range r.File {
+		switch f.Name {
+		case "word/footnotes.xml":
+			footnotes = p.readNotes(f, styles)
+		case "word/endnotes.xml":
+			endnotes = p.readNotes(f, styles)
+		case "word/settings.xml":
+			if data, err := readEntryLimited(f, p.Limits, 0); err == nil {
+				xml.Unmarshal(data, &settings)
+			}
+		}
+	}
+
+	pd.AutoHyphenation = onOffEnabled(settings.AutoHyphenation)
+
+	pd.FootnoteRestart = footnotesContinuous
+	for _, pr := range []*FootnotePr{settings.FootnotePr, sectFootnotePr(doc.Body.SectPr)} {
+		if pr == nil || pr.NumRestart == nil {
+			continue
+		}
+		switch pr.NumRestart.Val {
+		case "eachPage":
+			pd.FootnoteRestart = footnotesEachPage
+		case "eachSect":
+			pd.FootnoteRestart = footnotesEachSection
+		default:
+			pd.FootnoteRestart = footnotesContinuous
+		}
+	}
+
+	if len(footnotes) == 0 && len(endnotes) == 0 {
+		return
+	}
+	for i, para := range doc.Body.Paragraphs {
+		if i >= len(pd.Paragraphs) {
+			break
+		}
+		for _, run := range paragraphRuns(para) {
+			if ref := run.FootnoteReference; ref != nil {
+				if note, ok := footnotes[ref.ID]; ok {
+					pd.Footnotes = append(pd.Footnotes, placeNote(note, ref, i, pd.Paragraphs[i]))
+				}
+			}
+			if ref := run.EndnoteReference; ref != nil {
+				if note, ok := endnotes[ref.ID]; ok {
+					pd.Endnotes = append(pd.Endnotes, placeNote(note, ref, i, pd.Paragraphs[i]))
+				}
+			}
+		}
+	}
+}
+
+func sectFootnotePr(sect *SectPr) *FootnotePr {
+	if sect == nil {
+		return nil
+	}
+	return sect.FootnotePr
+}
+
+func placeNote(note ParsedNote, ref *NoteRef, i int, p ParsedParagraph) ParsedNote {
+	note.ParagraphIndex = i
+	note.PageNumber = p.PageNumber
+	note.CustomMark = ref.CustomMarkFollows != "" && onOffEnabled(&OnOff{Val: ref.CustomMarkFollows})
+	return note
+}
+
+// readNotes reads the notes of a footnotes or endnotes part by id, skipping
+// the separators. The font size of a note is that of its first text run.
+func (p *DocParser) readNotes(f *zip.File, styles *styleSheet) map[string]ParsedNote {
+	data, err := readEntryLimited(f, p.Limits, 0)
+	if err != nil {
+		return nil
+	}
+	var part NotesDoc
+	if err := xml.Unmarshal(data, &part); err != nil {
+		return nil
+	}
+
+	notes := map[string]ParsedNote{}
+	for _, n := range append(part.Footnotes, part.Endnotes...) {
+		if n.Type != "" && n.Type != "normal" {
+			continue
+		}
+		note := ParsedNote{ID: n.ID}
+		var lines []string
+		for _, para := range n.Paragraphs {
+			if text, _ := headerFooterText(para); strings.TrimSpace(text) != "" {
+				lines = append(lines, strings.TrimSpace(text))
+			}
+			if note.FontSizePt != 0 {
+				continue
+			}
+			styleID := ""
+			if para.PPr != nil && para.PPr.PStyle != nil {
+				styleID = para.PPr.PStyle.Val
+			}
+			for _, run := range paragraphRuns(para) {
+				if run.Text == nil || strings.TrimSpace(run.Text.Content) == "" {
+					continue
+				}
+				rs := styles.resolve(styleID)
+				styles.overlayRun(&rs, run.RPr)
+				note.FontSizePt = rs.FontSizePt
+				break
+			}
+		}
+		note.Text = strings.Join(lines, " ")
+		notes[n.ID] = note
+	}
+	return notes
+}
@@ -0,0 +1,202 @@
+package checker
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// listLevel is one level of a list definition in word/numbering.xml.
+type listLevel struct {
+	Format string // numFmt: bullet, decimal, lowerLetter, russianLower, ...
+	Marker string // the marker as printed: "–", "•", "1.", "а)"
+	Ind    *Ind   // indents of the level, overridable by the paragraph
+}
+
+// numbering resolves the numId and ilvl of a list paragraph to the level of
+// its list definition.
+type numbering map[string]map[int]listLevel
+
+// parseNumbering loads word/numbering.xml. Without it (or when it is broken)
+// list paragraphs keep an unknown marker.
+func (p *DocParser) parseNumbering(r *zip.Reader) numbering {
+	for _, f := range r.File {
+		if f.Name != "word/numbering.xml" {
+			continue
+		}
+		data, err := readEntryLimited(f, p.Limits, 0)
+		if err != nil {
+			return nil
+		}
+		var doc NumberingDoc
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return nil
+		}
+		return newNumbering(doc)
+	}
+	return nil
+}
+
+func newNumbering(doc NumberingDoc) numbering {
+	abstract := make(map[string]map[int]listLevel, len(doc.AbstractNums))
+	for _, an := range doc.AbstractNums {
+		levels := make(map[int]listLevel, len(an.Levels))
+		for _, lvl := range an.Levels {
+			ilvl, err := strconv.Atoi(lvl.Ilvl)
+			if err != nil {
+				continue
+			}
+			levels[ilvl] = newListLevel(lvl, listLevel{})
+		}
+		abstract[an.ID] = levels
+	}
+
+	n := make(numbering, len(doc.Nums))
+	for _, num := range doc.Nums {
+		if num.AbstractNumID == nil {
+			continue
+		}
+		levels, ok := abstract[num.AbstractNumID.Val]
+		if !ok {
+			continue
+		}
+		if len(num.LvlOverrides) > 0 {
+			own := make(map[int]listLevel, len(levels))
+			for ilvl, l := range levels {
+				own[ilvl] = l
+			}
+			for _, o := range num.LvlOverrides {
+				ilvl, err := strconv.Atoi(o.Ilvl)
+				if err != nil || o.Lvl == nil {
+					continue // a startOverride only restarts the numbering
+				}
+				own[ilvl] = newListLevel(*o.Lvl, own[ilvl])
+			}
+			levels = own
+		}
+		n[num.ID] = levels
+	}
+	return n
+}
+
+// newListLevel reads a level definition over base, so an override that only
+// changes the marker keeps the indents of the abstract level.
+func newListLevel(lvl NumLevel, base listLevel) listLevel {
+	l := base
+	if lvl.NumFmt != nil {
+		l.Format = lvl.NumFmt.Val
+	}
+	if lvl.LvlText != nil {
+		l.Marker = listMarker(l.Format, lvl.LvlText.Val)
+	}
+	if lvl.PPr != nil && lvl.PPr.Ind != nil {
+		l.Ind = lvl.PPr.Ind
+	}
+	return l
+}
+
+// level returns the definition of level ilvl of list numID.
+func (n numbering) level(numID string, ilvl int) (listLevel, bool) {
+	l, ok := n[numID][ilvl]
+	return l, ok
+}
+
+// apply marks pp as a list item when it is numbered, directly (own, may be
+// nil) or through its paragraph style, and sets its marker. numId 0 removes
+// the numbering a style would add.
+func (n numbering) apply(pp *ParsedParagraph, own *PPr, style resolvedStyle) {
+	numID, ilvl := style.NumID, style.NumIlvl
+	numbered := numID != ""
+	var ownInd *Ind
+	if own != nil {
+		ownInd = own.Ind
+		if own.NumPr != nil {
+			numbered = true
+			if own.NumPr.NumId != nil {
+				numID = own.NumPr.NumId.Val
+			}
+			if own.NumPr.Ilvl != nil {
+				ilvl, _ = strconv.Atoi(own.NumPr.Ilvl.Val)
+			}
+		}
+	}
+	if !numbered || numID == "0" {
+		return
+	}
+
+	pp.IsListItem = true
+	pp.ListLevel = ilvl
+	pp.ListNumID = numID
+	if lvl, ok := n.level(numID, ilvl); ok {
+		pp.ListFormat = lvl.Format
+		pp.ListMarker = lvl.Marker
+		pp.ListIndentMm = markerPositionMm(lvl.Ind, ownInd)
+	}
+}
+
+// listMarker renders the lvlText of a level as it is printed: "%1." of a
+// decimal list becomes "1.", and bullets of the Symbol and Wingdings fonts,
+// which Word stores in the private use area, become their visible form.
+func listMarker(format, text string) string {
+	if format == "bullet" {
+		var b strings.Builder
+		for _, r := range text {
+			switch {
+			case r == 0xF0B7:
+				b.WriteRune('•')
+			case r == 0xF0A7:
+				b.WriteRune('▪')
+			case r > 0xF000 && r <= 0xF0FF:
+				b.WriteRune(r - 0xF000)
+			case r == 'o':
+				b.WriteRune('◦') // Courier New "o"
+			default:
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	}
+
+	sample := "1"
+	switch format {
+	case "lowerLetter":
+		sample = "a"
+	case "upperLetter":
+		sample = "A"
+	case "russianLower":
+		sample = "а"
+	case "russianUpper":
+		sample = "А"
+	case "lowerRoman":
+		sample = "i"
+	case "upperRoman":
+		sample = "I"
+	case "decimalZero":
+		sample = "01"
+	}
+	for i := 1; i <= 9; i++ {
+		text = strings.ReplaceAll(text, "%"+strconv.Itoa(i), sample)
+	}
+	return text
+}
+
+// markerPositionMm returns where the marker of a list paragraph starts,
+// measured from the left margin: the left indent minus the hanging indent
+// (or plus the first-line indent). The paragraph's own indents override
+// those of its list level.
+func markerPositionMm(level, own *Ind) float64 {
+	var ind Ind
+	if level != nil {
+		ind = *level
+	}
+	if own != nil {
+		if own.Left != "" {
+			ind.Left = own.Left
+		}
+		if own.FirstLine != "" || own.Hanging != "" {
+			ind.FirstLine, ind.Hanging = own.FirstLine, own.Hanging
+		}
+	}
+	return twipsToMm(ind.Left) - twipsToMm(ind.Hanging) + twipsToMm(ind.FirstLine)
+}
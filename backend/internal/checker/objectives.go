@@ -0,0 +1,174 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"regexp"
+	"strings"
+)
+
+// ObjectivesConfig compares the задачи set in the introduction with the
+// results stated in the conclusion: a conclusion with fewer results than
+// there are tasks is pointed out to the supervisor.
+type ObjectivesConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+var (
+	// objectivesLead is the sentence announcing the tasks, e.g. «Для
+	// достижения цели были поставлены следующие задачи:» or «Задачи
+	// исследования:».
+	objectivesLead = regexp.MustCompile(`(?i)(?:^|[^\p{L}])задач(?:и|ами)(?:[^\p{L}]|$)`)
+	// enumerationMarker is a marker typed by hand at the start of an item:
+	// «1)», «2.», «а)», a dash or a bullet.
+	enumerationMarker = regexp.MustCompile(`^\s*(?:\d{1,2}[.)]|[а-яa-z]\)|[-–—•])\s*\S`)
+	// inlineNumber is an item number inside a paragraph: «1) …; 2) …».
+	inlineNumber = regexp.MustCompile(`(?:^|[\s:;,])\d{1,2}\)\s`)
+	// resultStatement is a verb form reporting a result: «разработан»,
+	// «проведён анализ», «были изучены».
+	resultStatement = regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:разработа|провед[её]|выполн|изуч|проанализирова|определ[её]|созда|реализова|рассмотр|сформулирова|выяв|получ|предлож|обоснова|исследова|описа|установл|составл|спроектирова|протестирова|показа|сравн|систематизирова|обобщ)\p{L}*`)
+	sentenceEnd     = regexp.MustCompile(`[.!?…]+(?:\s+|$)`)
+)
+
+// checkObjectives counts the tasks enumerated in the introduction and the
+// results stated in the conclusion. The results are the items of the
+// conclusion's enumerations when it has any, else its sentences reporting
+// a result. The finding is informational: the count is a hint, not proof.
+func checkObjectives(paragraphs []ParsedParagraph, trace *ruleTrace) []models.Violation {
+	intro, introBody := sectionParagraphs(paragraphs, "введение", "introduction")
+	if intro < 0 {
+		trace.skip("objectives", "Заголовок «Введение» не найден")
+		return nil
+	}
+	lead, tasks := objectiveTasks(paragraphs, introBody)
+	if tasks < 2 {
+		trace.skip("objectives", "Перечень задач во введении не найден")
+		return nil
+	}
+	trace.read("objectives", "tasks", tasks)
+	conclusion, conclusionBody := sectionParagraphs(paragraphs, "заключение", "conclusion")
+	if conclusion < 0 {
+		trace.skip("objectives", "Заголовок «Заключение» не найден")
+		return nil
+	}
+	results := conclusionResults(paragraphs, conclusionBody)
+	trace.read("objectives", "results", results)
+	if results >= tasks {
+		return nil
+	}
+
+	p := paragraphs[conclusion]
+	return []models.Violation{{
+		RuleType:      "objectives_conclusion_mismatch",
+		Description:   "В заключении меньше результатов, чем задач во введении",
+		ExpectedValue: fmt.Sprintf("Не менее %d результатов — по числу задач", tasks),
+		ActualValue:   fmt.Sprintf("%d", results),
+		Severity:      models.SeverityInfo,
+		PositionInDoc: fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, conclusion+1, truncate(strings.TrimSpace(p.Text), 100)),
+		ContextText:   contextSnippet(paragraphs[lead].Text),
+		Location:      paragraphLocation(conclusion, p),
+		Suggestion:    "Проверьте, что в заключении подведён итог по каждой задаче из введения",
+	}}
+}
+
+// sectionParagraphs finds the first heading outside the table of contents
+// that starts with one of the titles, ignoring a section number, and returns
+// its index and the indexes of the paragraphs up to the next heading.
+// heading is -1 when there is no such section.
+func sectionParagraphs(paragraphs []ParsedParagraph, titles ...string) (heading int, body []int) {
+	isHeading := func(p ParsedParagraph) bool { return p.Role != "toc" && isHeadingParagraph(p) }
+	for i, p := range paragraphs {
+		if !isHeading(p) {
+			continue
+		}
+		text := strings.ToLower(strings.TrimLeft(strings.TrimSpace(p.Text), "0123456789. "))
+		for _, title := range titles {
+			if !strings.HasPrefix(text, title) {
+				continue
+			}
+			for j := i + 1; j < len(paragraphs) && !isHeading(paragraphs[j]); j++ {
+				if strings.TrimSpace(paragraphs[j].Text) != "" {
+					body = append(body, j)
+				}
+			}
+			return i, body
+		}
+	}
+	return -1, nil
+}
+
+// objectiveTasks finds the paragraph announcing the tasks in the
+// introduction and counts them: the items of the list following it, or the
+// numbered or «;»-separated parts after its colon when they are in the same
+// paragraph. The count is 0 when the introduction does not list tasks.
+func objectiveTasks(paragraphs []ParsedParagraph, body []int) (lead, tasks int) {
+	for k, i := range body {
+		text := strings.TrimSpace(paragraphs[i].Text)
+		colon := strings.Index(text, ":")
+		if colon < 0 || !objectivesLead.MatchString(text[:colon]) {
+			continue
+		}
+		if n := inlineItems(text[colon+1:]); n >= 2 {
+			return i, n
+		}
+		return i, enumeratedItems(paragraphs, body[k+1:], true)
+	}
+	return -1, 0
+}
+
+// conclusionResults counts the results stated in the conclusion.
+func conclusionResults(paragraphs []ParsedParagraph, body []int) int {
+	items := enumeratedItems(paragraphs, body, false)
+	for _, i := range body {
+		if n := len(inlineNumber.FindAllString(paragraphs[i].Text, -1)); n >= 2 {
+			items += n
+		}
+	}
+	if items > 0 {
+		return items
+	}
+	statements := 0
+	for _, i := range body {
+		for _, sentence := range sentenceEnd.Split(paragraphs[i].Text, -1) {
+			if resultStatement.MatchString(sentence) {
+				statements++
+			}
+		}
+	}
+	return statements
+}
+
+// enumeratedItems counts the top-level list items among the paragraphs:
+// Word list items and paragraphs starting with a typed marker. When leading
+// is set only the items at the start count, up to the first other paragraph.
+func enumeratedItems(paragraphs []ParsedParagraph, body []int, leading bool) int {
+	n := 0
+	for _, i := range body {
+		p := paragraphs[i]
+		if !p.IsListItem && !enumerationMarker.MatchString(p.Text) {
+			if leading {
+				break
+			}
+			continue
+		}
+		if p.ListLevel == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// inlineItems counts the items listed in one paragraph: «1) …; 2) …» or
+// «изучить …; разработать …; оценить …».
+func inlineItems(text string) int {
+	if n := len(inlineNumber.FindAllString(text, -1)); n >= 2 {
+		return n
+	}
+	n := 0
+	for _, part := range strings.Split(text, ";") {
+		if strings.TrimSpace(strings.Trim(part, ".")) != "" {
+			n++
+		}
+	}
+	return n
+}
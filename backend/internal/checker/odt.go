@@ -0,0 +1,909 @@
+package checker
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// odtMimeType is the content of the "mimetype" entry of an OpenDocument text file.
+const odtMimeType = "application/vnd.oasis.opendocument.text"
+
+// OdtParser reads OpenDocument text files (.odt, as saved by LibreOffice).
+//
+// ODF styles are resolved into direct formatting and the content is translated
+// into the same XML model DocParser decodes from word/document.xml, so both
+// formats go through one conversion and get identical rule coverage.
+type OdtParser struct {
+	Limits ParserLimits
+}
+
+func NewOdtParser() *OdtParser {
+	return &OdtParser{Limits: DefaultParserLimits()}
+}
+
+func (o *OdtParser) Parse(filePath string) (*ParsedDoc, error) {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return o.parseZip(&r.Reader)
+}
+
+// ParseReader parses an ODT package of the given size read from r.
+func (o *OdtParser) ParseReader(r io.ReaderAt, size int64) (*ParsedDoc, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return o.parseZip(zr)
+}
+
+// isODT reports whether the package is an OpenDocument text file.
+func isODT(r *zip.Reader) bool {
+	for _, f := range r.File {
+		if f.Name == "mimetype" {
+			data, err := readEntryLimited(f, ParserLimits{}, int64(len(odtMimeType)+16))
+			return err == nil && strings.TrimSpace(string(data)) == odtMimeType
+		}
+	}
+	return false
+}
+
+func (o *OdtParser) parseZip(r *zip.Reader) (*ParsedDoc, error) {
+	if err := checkZipPackage(r.File, o.Limits); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+	contentFile := files["content.xml"]
+	if contentFile == nil {
+		return nil, fmt.Errorf("invalid odt: missing content.xml")
+	}
+
+	contentData, err := readEntryLimited(contentFile, o.Limits, o.Limits.MaxXMLBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkXMLComplexity(bytes.NewReader(contentData), o.Limits); err != nil {
+		return nil, err
+	}
+	content, err := parseODFTree(contentData)
+	if err != nil {
+		return nil, fmt.Errorf("xml decode error: %v", err)
+	}
+
+	// styles.xml and the manifest are optional: without them the document is
+	// checked with the formatting found in content.xml only.
+	var stylesRoot, manifest *odfNode
+	if f := files["styles.xml"]; f != nil {
+		if data, err := readEntryLimited(f, o.Limits, 0); err == nil {
+			stylesRoot, _ = parseODFTree(data)
+		}
+	}
+	if f := files["META-INF/manifest.xml"]; f != nil {
+		if data, err := readEntryLimited(f, o.Limits, 0); err == nil {
+			manifest, _ = parseODFTree(data)
+		}
+	}
+
+	t := newOdtTranslator(stylesRoot, content, manifest)
+	doc := t.translate(content)
+
+	// ODF styles are already resolved into direct formatting.
+	pd := (&DocParser{Limits: o.Limits}).convert(doc, nil, nil)
+	t.headersFooters(pd)
+	pd.Revisions, pd.Comments = odtRevisions(content)
+	if f := files["meta.xml"]; f != nil {
+		if data, err := readEntryLimited(f, o.Limits, 0); err == nil {
+			if meta, err := parseODFTree(data); err == nil {
+				pd.Properties = odtProperties(meta)
+			}
+		}
+	}
+	return pd, nil
+}
+
+// --- Generic XML tree ---
+
+// odfNode is an element of an ODF part, or character data when name is "".
+// Element and attribute names are matched by local name; ODF names do not
+// collide across the namespaces the parser looks at.
+type odfNode struct {
+	name     string
+	attrs    map[string]string
+	children []*odfNode
+	text     string
+}
+
+func parseODFTree(data []byte) (*odfNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	root := &odfNode{}
+	stack := []*odfNode{root}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &odfNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, a := range t.Attr {
+				n.attrs[a.Name.Local] = a.Value
+			}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 1 {
+				parent.children = append(parent.children, &odfNode{text: string(t)})
+			}
+		}
+	}
+	if len(root.children) == 0 {
+		return nil, fmt.Errorf("empty document")
+	}
+	return root.children[0], nil
+}
+
+func (n *odfNode) child(name string) *odfNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *odfNode) attr(name string) string {
+	if n == nil {
+		return ""
+	}
+	return n.attrs[name]
+}
+
+// textContent returns all character data below n.
+func (n *odfNode) textContent() string {
+	if n == nil {
+		return ""
+	}
+	var sb strings.Builder
+	var walk func(*odfNode)
+	walk = func(n *odfNode) {
+		if n.name == "" {
+			sb.WriteString(n.text)
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(sb.String())
+}
+
+// --- Styles ---
+
+// odfStyles resolves ODF style properties: the default style of the family,
+// then the parent-style-name chain, then the style itself. Properties of all
+// <style:*-properties> children are merged into one map keyed by local name.
+type odfStyles struct {
+	styles    map[string]map[string]*odfNode // family -> name -> style:style
+	automatic map[*odfNode]bool
+	defaults  map[string]*odfNode // family -> style:default-style
+	fonts     map[string]string   // font-face name -> font family
+	layouts   map[string]*odfNode // page layout name -> style:page-layout
+	masters   []*odfNode          // style:master-page in document order
+	resolved  map[string]map[string]string
+}
+
+func newOdfStyles() *odfStyles {
+	return &odfStyles{
+		styles:    make(map[string]map[string]*odfNode),
+		automatic: make(map[*odfNode]bool),
+		defaults:  make(map[string]*odfNode),
+		fonts:     make(map[string]string),
+		layouts:   make(map[string]*odfNode),
+		resolved:  make(map[string]map[string]string),
+	}
+}
+
+func (s *odfStyles) collect(root *odfNode) {
+	if root == nil {
+		return
+	}
+	for _, section := range root.children {
+		switch section.name {
+		case "font-face-decls":
+			for _, f := range section.children {
+				if f.name == "font-face" {
+					family := strings.Trim(f.attr("font-family"), `'"`)
+					if family == "" {
+						family = f.attr("name")
+					}
+					s.fonts[f.attr("name")] = family
+				}
+			}
+		case "styles", "automatic-styles":
+			for _, st := range section.children {
+				switch st.name {
+				case "style":
+					family := st.attr("family")
+					if s.styles[family] == nil {
+						s.styles[family] = make(map[string]*odfNode)
+					}
+					s.styles[family][st.attr("name")] = st
+					s.automatic[st] = section.name == "automatic-styles"
+				case "default-style":
+					s.defaults[st.attr("family")] = st
+				case "page-layout":
+					s.layouts[st.attr("name")] = st
+				}
+			}
+		case "master-styles":
+			for _, m := range section.children {
+				if m.name == "master-page" {
+					s.masters = append(s.masters, m)
+				}
+			}
+		}
+	}
+}
+
+// props returns the effective properties of the named style of family.
+func (s *odfStyles) props(family, name string) map[string]string {
+	key := family + "\x00" + name
+	if p, ok := s.resolved[key]; ok {
+		return p
+	}
+	p := s.overlay(mergeODFProps(nil, s.defaults[family]), family, name)
+	s.resolved[key] = p
+	return p
+}
+
+// overlay applies the named style of family and its ancestors to base.
+func (s *odfStyles) overlay(base map[string]string, family, name string) map[string]string {
+	p := base
+	chain := []*odfNode{}
+	seen := map[string]bool{}
+	for cur := name; cur != "" && !seen[cur]; {
+		seen[cur] = true
+		st := s.styles[family][cur]
+		if st == nil {
+			break
+		}
+		chain = append(chain, st)
+		cur = st.attr("parent-style-name")
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		p = mergeODFProps(p, chain[i])
+	}
+	return p
+}
+
+// styleID returns the Word-like id of a paragraph style: automatic styles
+// ("P1") are replaced by the named style they derive from, and ODF name
+// escapes are removed ("Heading_20_1" becomes "Heading1").
+func (s *odfStyles) styleID(name string) string {
+	seen := map[string]bool{}
+	for name != "" && !seen[name] {
+		seen[name] = true
+		st := s.styles["paragraph"][name]
+		if st == nil || !s.automatic[st] {
+			break
+		}
+		name = st.attr("parent-style-name")
+	}
+	id := odfNameEscapeRe.ReplaceAllStringFunc(name, func(m string) string {
+		code, err := strconv.ParseUint(m[1:3], 16, 8)
+		if err != nil || code == 0x20 {
+			return ""
+		}
+		return string(rune(code))
+	})
+	id = strings.ReplaceAll(id, " ", "")
+	// LibreOffice names table of contents entries "Contents N"
+	if strings.HasPrefix(id, "Contents") {
+		id = "TOC" + strings.TrimPrefix(id, "Contents")
+	}
+	return id
+}
+
+var odfNameEscapeRe = regexp.MustCompile(`_[0-9a-fA-F]{2}_`)
+
+// mergeODFProps overlays the *-properties of style on base. Relative font
+// sizes ("120%") are resolved against the inherited size.
+func mergeODFProps(base map[string]string, style *odfNode) map[string]string {
+	p := make(map[string]string, len(base)+8)
+	for k, v := range base {
+		p[k] = v
+	}
+	if style == nil {
+		return p
+	}
+	for _, c := range style.children {
+		if !strings.HasSuffix(c.name, "-properties") {
+			continue
+		}
+		for k, v := range c.attrs {
+			p[k] = v
+		}
+	}
+	return resolveRelativeFontSize(base, p)
+}
+
+func resolveRelativeFontSize(base, p map[string]string) map[string]string {
+	size := p["font-size"]
+	if !strings.HasSuffix(size, "%") {
+		return p
+	}
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(size, "%"), 64)
+	parentPt := odfLengthPt(base["font-size"])
+	if err != nil || parentPt == 0 {
+		delete(p, "font-size")
+		return p
+	}
+	p["font-size"] = strconv.FormatFloat(parentPt*pct/100, 'f', -1, 64) + "pt"
+	return p
+}
+
+func (s *odfStyles) fontName(p map[string]string) string {
+	if name := p["font-name"]; name != "" {
+		if family := s.fonts[name]; family != "" {
+			return family
+		}
+		return name
+	}
+	return strings.Trim(p["font-family"], `'"`)
+}
+
+// --- Units ---
+
+// odfLengthMm converts an ODF length ("2cm", "12.5mm", "0.5in", "14pt") to
+// millimetres. Unknown units and percentages give 0.
+func odfLengthMm(v string) float64 {
+	v = strings.TrimSpace(v)
+	units := []struct {
+		suffix string
+		mm     float64
+	}{
+		{"mm", 1}, {"cm", 10}, {"in", 25.4}, {"pt", 25.4 / 72}, {"pc", 25.4 / 6}, {"px", 25.4 / 96},
+	}
+	for _, u := range units {
+		if strings.HasSuffix(v, u.suffix) {
+			f, err := strconv.ParseFloat(strings.TrimSuffix(v, u.suffix), 64)
+			if err != nil {
+				return 0
+			}
+			return f * u.mm
+		}
+	}
+	return 0
+}
+
+func odfLengthPt(v string) float64 {
+	return odfLengthMm(v) * 72 / 25.4
+}
+
+// odfTwips converts an ODF length to twips as used by the OOXML model.
+func odfTwips(v string) string {
+	return strconv.Itoa(int(math.Round(odfLengthMm(v) * 1440 / 25.4)))
+}
+
+// --- Translation into the OOXML model ---
+
+type odtTranslator struct {
+	styles         *odfStyles
+	formulaObjects map[string]bool // embedded objects that are formulas
+	doc            Document
+	tocPending     bool // the next paragraph opens a table of contents
+}
+
+func newOdtTranslator(stylesRoot, content, manifest *odfNode) *odtTranslator {
+	t := &odtTranslator{styles: newOdfStyles(), formulaObjects: make(map[string]bool)}
+	t.styles.collect(stylesRoot)
+	t.styles.collect(content)
+	if manifest != nil {
+		for _, e := range manifest.children {
+			if e.name == "file-entry" && e.attr("media-type") == "application/vnd.oasis.opendocument.formula" {
+				t.formulaObjects[strings.Trim(e.attr("full-path"), "/")] = true
+			}
+		}
+	}
+	return t
+}
+
+func (t *odtTranslator) translate(content *odfNode) Document {
+	t.doc.Body.SectPr = t.sectPr()
+	if text := content.child("body").child("text"); text != nil {
+		t.blocks(text.children, 0)
+	}
+	t.doc.Body.Blocks = append(t.doc.Body.Blocks, BodyBlock{Kind: BlockSectPr, Index: -1})
+	return t.doc
+}
+
+// sectPr builds the page setup from the page layout of the first master page.
+// ODF measures the top/bottom margin to the header/footer, OOXML to the body,
+// so the header and footer heights are added to the margins.
+func (t *odtTranslator) sectPr() *SectPr {
+	if len(t.styles.masters) == 0 {
+		return nil
+	}
+	master := t.styles.masters[0]
+	layout := t.styles.layouts[master.attr("page-layout-name")]
+	page := layout.child("page-layout-properties")
+	if page == nil {
+		return nil
+	}
+
+	top, bottom := odfLengthMm(page.attr("margin-top")), odfLengthMm(page.attr("margin-bottom"))
+	headerDist, footerDist := top, bottom
+	if master.child("header") != nil {
+		hf := layout.child("header-style").child("header-footer-properties")
+		top += odfLengthMm(hf.attr("min-height")) + odfLengthMm(hf.attr("margin-bottom"))
+	}
+	if master.child("footer") != nil {
+		hf := layout.child("footer-style").child("header-footer-properties")
+		bottom += odfLengthMm(hf.attr("min-height")) + odfLengthMm(hf.attr("margin-top"))
+	}
+	mm := func(v float64) string { return odfTwips(strconv.FormatFloat(v, 'f', -1, 64) + "mm") }
+
+	return &SectPr{
+		PgMar: &PgMar{
+			Top:    mm(top),
+			Bottom: mm(bottom),
+			Left:   odfTwips(page.attr("margin-left")),
+			Right:  odfTwips(page.attr("margin-right")),
+			Header: mm(headerDist),
+			Footer: mm(footerDist),
+		},
+		PgSz: &PgSz{
+			W:      odfTwips(page.attr("page-width")),
+			H:      odfTwips(page.attr("page-height")),
+			Orient: page.attr("print-orientation"),
+		},
+	}
+}
+
+// headersFooters fills the headers and footers of pd from the first master
+// page. header-first/footer-first (ODF 1.3) are the title page variants.
+func (t *odtTranslator) headersFooters(pd *ParsedDoc) {
+	if len(t.styles.masters) == 0 {
+		return
+	}
+	master := t.styles.masters[0]
+	add := func(list *[]ParsedHeaderFooter, name, typ string) {
+		n := master.child(name)
+		if n == nil || n.attr("display") == "false" {
+			return
+		}
+		var part HdrFtr
+		for _, c := range n.children {
+			if c.name == "p" || c.name == "h" {
+				para, _ := t.convertParagraph(c, 0, false)
+				part.Paragraphs = append(part.Paragraphs, para)
+			}
+		}
+		hf := newParsedHeaderFooter(part, nil)
+		hf.Type = typ
+		hf.ParagraphEnd = len(pd.Paragraphs)
+		*list = append(*list, hf)
+	}
+	add(&pd.Headers, "header", "default")
+	add(&pd.Headers, "header-first", "first")
+	add(&pd.Footers, "footer", "default")
+	add(&pd.Footers, "footer-first", "first")
+	pd.TitlePage = master.child("header-first") != nil || master.child("footer-first") != nil
+}
+
+// blocks translates body-level content. listLevel is the nesting depth of the
+// enclosing lists (0 outside lists).
+func (t *odtTranslator) blocks(nodes []*odfNode, listLevel int) {
+	for _, n := range nodes {
+		switch n.name {
+		case "p", "h":
+			t.paragraph(n, listLevel)
+		case "list":
+			for _, item := range n.children {
+				if item.name == "list-item" || item.name == "list-header" {
+					t.blocks(item.children, listLevel+1)
+				}
+			}
+		case "table":
+			t.table(n)
+		case "soft-page-break":
+			// LibreOffice's record of where a page ended when the file was saved,
+			// like Word's lastRenderedPageBreak: the break follows the last paragraph.
+			if last := len(t.doc.Body.Paragraphs) - 1; last >= 0 {
+				p := &t.doc.Body.Paragraphs[last]
+				p.R = append(p.R, Run{LastRenderedPageBreak: &Empty{}})
+			}
+		case "table-of-content":
+			t.tocPending = true
+			t.blocks(n.children, listLevel)
+		case "section", "index-body", "index-title":
+			t.blocks(n.children, listLevel)
+		}
+	}
+}
+
+func (t *odtTranslator) addParagraph(p Paragraph) {
+	t.doc.Body.Paragraphs = append(t.doc.Body.Paragraphs, p)
+	t.doc.Body.Blocks = append(t.doc.Body.Blocks, BodyBlock{Kind: BlockParagraph, Index: len(t.doc.Body.Paragraphs) - 1})
+}
+
+// paragraph translates text:p / text:h. Paragraphs of text frames (e.g. an
+// image with its caption) follow the paragraph the frame is anchored in.
+func (t *odtTranslator) paragraph(n *odfNode, listLevel int) {
+	p, frames := t.convertParagraph(n, listLevel, n.name == "h")
+	t.addParagraph(p)
+	for _, fp := range frames {
+		t.addParagraph(fp)
+	}
+}
+
+func (t *odtTranslator) convertParagraph(n *odfNode, listLevel int, heading bool) (Paragraph, []Paragraph) {
+	styleName := n.attr("style-name")
+	props := t.styles.props("paragraph", styleName)
+
+	ppr := t.paragraphProps(props)
+	if heading {
+		level := n.attr("outline-level")
+		if level == "" {
+			level = "1"
+		}
+		ppr.PStyle = &Val{Val: "Heading" + level}
+		if n, err := strconv.Atoi(level); err == nil && n > 0 {
+			ppr.OutlineLvl = &Val{Val: strconv.Itoa(n - 1)}
+		}
+	} else if id := t.styles.styleID(styleName); id != "" {
+		ppr.PStyle = &Val{Val: id}
+	}
+	if listLevel > 0 && !heading {
+		ppr.NumPr = &NumPr{Ilvl: &Val{Val: strconv.Itoa(listLevel - 1)}, NumId: &Val{Val: "1"}}
+	}
+
+	para := Paragraph{PPr: ppr}
+	if t.tocPending {
+		para.R = append(para.R, Run{InstrText: &Text{Content: `TOC \o "1-3"`}})
+		t.tocPending = false
+	}
+
+	var frames []Paragraph
+	t.inline(n.children, props, &para, &frames)
+	return para, frames
+}
+
+// paragraphProps maps resolved ODF paragraph properties to pPr.
+func (t *odtTranslator) paragraphProps(props map[string]string) *PPr {
+	ppr := &PPr{}
+	switch props["text-align"] {
+	case "start", "left":
+		ppr.Jc = &Jc{Val: "left"}
+	case "end", "right":
+		ppr.Jc = &Jc{Val: "right"}
+	case "center":
+		ppr.Jc = &Jc{Val: "center"}
+	case "justify":
+		ppr.Jc = &Jc{Val: "both"}
+	}
+
+	if indent := odfLengthMm(props["text-indent"]); indent > 0 {
+		ppr.Ind = &Ind{FirstLine: odfTwips(props["text-indent"])}
+	}
+
+	spacing := &Spacing{}
+	if v := props["margin-top"]; v != "" {
+		spacing.Before = odfTwips(v)
+	}
+	if v := props["margin-bottom"]; v != "" {
+		spacing.After = odfTwips(v)
+	}
+	if lh := props["line-height"]; strings.HasSuffix(lh, "%") {
+		if pct, err := strconv.ParseFloat(strings.TrimSuffix(lh, "%"), 64); err == nil {
+			spacing.Line = strconv.Itoa(int(math.Round(pct / 100 * 240)))
+			spacing.LineRule = "auto"
+		}
+	} else if lh != "" && lh != "normal" {
+		spacing.Line = odfTwips(lh)
+		spacing.LineRule = "exact"
+	} else if v := props["line-height-at-least"]; v != "" {
+		spacing.Line = odfTwips(v)
+		spacing.LineRule = "atLeast"
+	}
+	if *spacing != (Spacing{}) {
+		ppr.Spacing = spacing
+	}
+
+	if props["break-before"] == "page" {
+		ppr.PageBreakBefore = &Empty{}
+	}
+	if props["keep-together"] == "always" {
+		ppr.KeepLines = &OnOff{}
+	}
+	if props["keep-with-next"] == "always" {
+		ppr.KeepNext = &OnOff{}
+	}
+	if props["widows"] == "0" || props["orphans"] == "0" {
+		ppr.WidowControl = &WidowControl{Val: "0"}
+	}
+	return ppr
+}
+
+// runProps maps resolved ODF text properties to rPr.
+func (t *odtTranslator) runProps(props map[string]string) *RPr {
+	rpr := &RPr{}
+	if font := t.styles.fontName(props); font != "" {
+		rpr.RFonts = &RFonts{Ascii: font, HAnsi: font}
+	}
+	if pt := odfLengthPt(props["font-size"]); pt > 0 {
+		rpr.Sz = &Val{Val: strconv.Itoa(int(math.Round(pt * 2)))}
+	}
+	if w := props["font-weight"]; w == "bold" || w == "600" || w == "700" || w == "800" || w == "900" {
+		rpr.B = &OnOff{}
+	}
+	if props["font-style"] == "italic" || props["font-style"] == "oblique" {
+		rpr.I = &OnOff{}
+	}
+	if u := props["text-underline-style"]; u != "" && u != "none" {
+		rpr.U = &Val{Val: "single"}
+	}
+	if props["text-transform"] == "uppercase" {
+		rpr.Caps = &OnOff{}
+	}
+	if s := props["text-line-through-style"]; s != "" && s != "none" {
+		rpr.Strike = &OnOff{}
+	}
+	return rpr
+}
+
+// inline appends the runs of paragraph content to para. props are the text
+// properties in effect; spans override them.
+func (t *odtTranslator) inline(nodes []*odfNode, props map[string]string, para *Paragraph, frames *[]Paragraph) {
+	rpr := t.runProps(props)
+	for _, n := range nodes {
+		switch n.name {
+		case "":
+			if text := collapseODFSpace(n.text); text != "" {
+				para.R = append(para.R, Run{RPr: rpr, Text: &Text{Content: text}})
+			}
+		case "s":
+			count, err := strconv.Atoi(n.attr("c"))
+			if err != nil || count < 1 {
+				count = 1
+			}
+			para.R = append(para.R, Run{RPr: rpr, Text: &Text{Content: strings.Repeat(" ", count)}})
+		case "tab":
+			para.R = append(para.R, Run{RPr: rpr, Tab: &Empty{}})
+		case "line-break":
+			para.R = append(para.R, Run{RPr: rpr, Br: &Br{Type: "textWrapping"}})
+		case "soft-page-break":
+			para.R = append(para.R, Run{LastRenderedPageBreak: &Empty{}})
+		case "span":
+			spanProps := props
+			if name := n.attr("style-name"); name != "" {
+				spanProps = t.styles.overlay(props, "text", name)
+			}
+			t.inline(n.children, spanProps, para, frames)
+		case "frame":
+			t.frame(n, rpr, para, frames)
+		case "page-number", "page-count":
+			// Kept as fields so header/footer checks can tell them from text.
+			instr := "PAGE"
+			if n.name == "page-count" {
+				instr = "NUMPAGES"
+			}
+			field := Paragraph{}
+			t.inline(n.children, props, &field, frames)
+			para.FldSimples = append(para.FldSimples, FldSimple{Instr: instr, R: field.R})
+		case "note", "annotation", "bookmark", "bookmark-start", "bookmark-end",
+			"reference-mark", "reference-mark-start", "reference-mark-end":
+			// Footnotes and comments are not part of the paragraph text.
+		default:
+			// Hyperlinks, fields, caption sequence numbers, ...
+			t.inline(n.children, props, para, frames)
+		}
+	}
+}
+
+// frame translates draw:frame: images become drawings, embedded formula
+// objects become formulas, and text boxes contribute their own paragraphs.
+func (t *odtTranslator) frame(n *odfNode, rpr *RPr, para *Paragraph, frames *[]Paragraph) {
+	for _, c := range n.children {
+		switch c.name {
+		case "image":
+			para.R = append(para.R, Run{RPr: rpr, Drawing: &Drawing{Inline: &DrawingPlacement{DocPr: &DocPr{
+				Name:  n.attr("name"),
+				Descr: n.child("desc").textContent(),
+				Title: n.child("title").textContent(),
+			}}}})
+			return
+		case "object":
+			if t.formulaObjects[strings.TrimPrefix(strings.Trim(c.attr("href"), "/"), "./")] {
+				para.OMaths = append(para.OMaths, OMath{})
+			}
+			return
+		case "text-box":
+			for _, inner := range c.children {
+				if inner.name != "p" && inner.name != "h" {
+					continue
+				}
+				p, nested := t.convertParagraph(inner, 0, inner.name == "h")
+				*frames = append(*frames, splitFrameCaption(p)...)
+				*frames = append(*frames, nested...)
+			}
+			return
+		}
+	}
+}
+
+// splitFrameCaption separates an image from the caption typed next to it in
+// the same frame paragraph (LibreOffice's "Insert Caption" layout), so the
+// caption is found as the paragraph below the image, as in Word documents.
+func splitFrameCaption(p Paragraph) []Paragraph {
+	var drawings, rest []Run
+	hasText := false
+	for _, r := range p.R {
+		if r.Drawing != nil {
+			drawings = append(drawings, r)
+			continue
+		}
+		rest = append(rest, r)
+		if r.Text != nil && strings.TrimSpace(r.Text.Content) != "" {
+			hasText = true
+		}
+	}
+	if len(drawings) == 0 || !hasText {
+		return []Paragraph{p}
+	}
+	image := p
+	image.R = drawings
+	caption := p
+	caption.R = rest
+	return []Paragraph{image, caption}
+}
+
+// collapseODFSpace applies ODF white-space handling: runs of spaces, tabs and
+// newlines in character data are a single space (text:s encodes the others).
+func collapseODFSpace(s string) string {
+	if strings.TrimSpace(s) == "" {
+		if s == "" {
+			return ""
+		}
+		return " "
+	}
+	fields := strings.Fields(s)
+	out := strings.Join(fields, " ")
+	if s[0] == ' ' || s[0] == '\n' || s[0] == '\t' || s[0] == '\r' {
+		out = " " + out
+	}
+	if last := s[len(s)-1]; last == ' ' || last == '\n' || last == '\t' || last == '\r' {
+		out += " "
+	}
+	return out
+}
+
+// table translates table:table with its column, row and first-cell styles.
+func (t *odtTranslator) table(n *odfNode) {
+	props := t.styles.props("table", n.attr("style-name"))
+	tbl := Tbl{TblPr: &TblPr{}}
+
+	switch props["align"] {
+	case "center", "margins":
+		// "margins" stretches the table over the text area, which is centred.
+		tbl.TblPr.Jc = &Jc{Val: "center"}
+	case "left":
+		tbl.TblPr.Jc = &Jc{Val: "left"}
+	case "right":
+		tbl.TblPr.Jc = &Jc{Val: "right"}
+	}
+	if rel := props["rel-width"]; strings.HasSuffix(rel, "%") {
+		if pct, err := strconv.ParseFloat(strings.TrimSuffix(rel, "%"), 64); err == nil {
+			tbl.TblPr.TblW = &TblW{Type: "pct", W: strconv.Itoa(int(math.Round(pct * 50)))}
+		}
+	} else if w := props["width"]; w != "" {
+		tbl.TblPr.TblW = &TblW{Type: "dxa", W: odfTwips(w)}
+	}
+
+	grid := &TblGrid{}
+	var rows func(nodes []*odfNode, header bool)
+	rows = func(nodes []*odfNode, header bool) {
+		for _, c := range nodes {
+			switch c.name {
+			case "table-column":
+				repeat, err := strconv.Atoi(c.attr("number-columns-repeated"))
+				if err != nil || repeat < 1 {
+					repeat = 1
+				}
+				colProps := t.styles.props("table-column", c.attr("style-name"))
+				for i := 0; i < repeat && len(grid.GridCols) < 1000; i++ {
+					grid.GridCols = append(grid.GridCols, GridCol{W: odfTwips(colProps["column-width"])})
+				}
+			case "table-columns", "table-column-group":
+				rows(c.children, header)
+			case "table-header-rows":
+				rows(c.children, true)
+			case "table-rows", "table-row-group":
+				rows(c.children, header)
+			case "table-row":
+				tbl.Trs = append(tbl.Trs, t.tableRow(c, header, len(tbl.Trs) == 0))
+			}
+		}
+	}
+	rows(n.children, false)
+	if len(grid.GridCols) > 0 {
+		tbl.TblGrid = grid
+	}
+
+	t.doc.Body.Tbls = append(t.doc.Body.Tbls, tbl)
+	t.doc.Body.Blocks = append(t.doc.Body.Blocks, BodyBlock{Kind: BlockTable, Index: len(t.doc.Body.Tbls) - 1})
+}
+
+// tableRow translates table:table-row. ODF keeps borders on cells; the borders
+// of the first cell stand for the table, as DocParser's tcBorders fallback.
+func (t *odtTranslator) tableRow(n *odfNode, header, firstRow bool) Tr {
+	tr := Tr{}
+	rowProps := t.styles.props("table-row", n.attr("style-name"))
+	if header || rowProps["row-height"] != "" || rowProps["min-row-height"] != "" {
+		tr.TrPr = &TrPr{}
+		if header {
+			tr.TrPr.TblHeader = &Empty{}
+		}
+		if h := rowProps["row-height"]; h != "" {
+			tr.TrPr.TrHeight = &TrHeight{Val: odfTwips(h), HRule: "exact"}
+		} else if h := rowProps["min-row-height"]; h != "" {
+			tr.TrPr.TrHeight = &TrHeight{Val: odfTwips(h), HRule: "atLeast"}
+		}
+	}
+	for _, c := range n.children {
+		if c.name != "table-cell" && c.name != "covered-table-cell" {
+			continue
+		}
+		tc := Tc{}
+		for _, inner := range c.children {
+			if inner.name == "p" || inner.name == "h" {
+				p, _ := t.convertParagraph(inner, 0, false)
+				tc.P = append(tc.P, p)
+			}
+		}
+		if firstRow && len(tr.Tcs) == 0 {
+			tc.TcPr = &TcPr{TcBorders: t.cellBorders(c.attr("style-name"))}
+		}
+		tr.Tcs = append(tr.Tcs, tc)
+	}
+	return tr
+}
+
+func (t *odtTranslator) cellBorders(styleName string) *TblBorders {
+	props := t.styles.props("table-cell", styleName)
+	side := func(name string) *BorderVal {
+		v := props["border-"+name]
+		if v == "" {
+			v = props["border"]
+		}
+		if v == "" || v == "none" || strings.HasPrefix(v, "0pt") || strings.HasPrefix(v, "0cm") {
+			return nil
+		}
+		return &BorderVal{Val: "single"}
+	}
+	return &TblBorders{Top: side("top"), Bottom: side("bottom"), Left: side("left"), Right: side("right")}
+}
@@ -0,0 +1,108 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"strings"
+)
+
+// checkPagination checks how paragraphs break across pages: widow control on
+// body text, headings kept with the next paragraph and no heading left alone
+// at the bottom of a page. Page numbers of documents without rendered page
+// breaks are estimated, so a heading found at the bottom of such a page is
+// doubtful.
+func checkPagination(doc *ParsedDoc, cfg StructureConfig, startPage int) ([]models.Violation, int) {
+	var vs []models.Violation
+	rules := 0
+	if !cfg.RequireWidowControl && !cfg.HeadingKeepNext && !cfg.HeadingNotLastOnPage {
+		return vs, 0
+	}
+
+	paras := doc.Paragraphs
+	inScope := func(p ParsedParagraph) bool {
+		return strings.TrimSpace(p.Text) != "" && (startPage <= 1 || p.PageNumber >= startPage)
+	}
+
+	if cfg.RequireWidowControl {
+		rules++
+		first, count := -1, 0
+		for i, p := range paras {
+			if !inScope(p) || p.Role != "body" || p.WidowControl {
+				continue
+			}
+			if first < 0 {
+				first = i
+			}
+			count++
+		}
+		if count > 0 {
+			p := paras[first]
+			vs = append(vs, models.Violation{
+				RuleType:      "widow_control",
+				Description:   "Отключён запрет висячих строк",
+				PositionInDoc: fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, first+1, truncate(strings.TrimSpace(p.Text), 100)),
+				ExpectedValue: "Запрет висячих строк включён",
+				ActualValue:   fmt.Sprintf("Отключён в %d абз.", count),
+				Suggestion:    "Выделите текст и включите «Абзац → Положение на странице → Запрет висячих строк», лучше в стиле «Обычный»",
+				Severity:      "warning",
+				ContextText:   contextSnippet(p.Text),
+				Location:      paragraphLocation(first, p),
+			})
+		}
+	}
+
+	for i, p := range paras {
+		if !inScope(p) || p.Role != "heading" || !isHeadingParagraph(p) {
+			continue
+		}
+		styled, _ := styledHeading(p)
+		pos := fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(strings.TrimSpace(p.Text), 100))
+		add := func(v models.Violation) {
+			v.PositionInDoc = pos
+			v.ContextText = contextSnippet(p.Text)
+			v.Location = paragraphLocation(i, p)
+			vs = append(vs, v)
+		}
+
+		if cfg.HeadingKeepNext {
+			rules++
+			if !p.KeepNext {
+				add(models.Violation{
+					RuleType:      "heading_keep_next",
+					Description:   "Заголовок может оторваться от следующего абзаца",
+					ExpectedValue: "Не отрывать от следующего",
+					ActualValue:   "Не задано",
+					Suggestion:    "Включите «Абзац → Положение на странице → Не отрывать от следующего» в стиле заголовка",
+					Severity:      "warning",
+					IsDoubtful:    !styled,
+				})
+			}
+		}
+
+		if cfg.HeadingNotLastOnPage {
+			next := -1
+			for j := i + 1; j < len(paras); j++ {
+				if strings.TrimSpace(paras[j].Text) != "" {
+					next = j
+					break
+				}
+			}
+			if next < 0 {
+				continue
+			}
+			rules++
+			if paras[next].PageNumber > p.PageNumber {
+				add(models.Violation{
+					RuleType:      "heading_last_on_page",
+					Description:   "Заголовок в конце страницы, текст раздела начинается на следующей",
+					ExpectedValue: "Заголовок на одной странице с текстом",
+					ActualValue:   fmt.Sprintf("Заголовок на стр. %d, текст на стр. %d", p.PageNumber, paras[next].PageNumber),
+					Suggestion:    "Перенесите заголовок на следующую страницу или включите «Не отрывать от следующего»",
+					Severity:      "error",
+					IsDoubtful:    doc.Stats.PagesEstimated || !styled,
+				})
+			}
+		}
+	}
+	return vs, rules
+}
@@ -2,19 +2,24 @@ package checker
 
 import (
 	"archive/zip"
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-// DocParser handles the unzip and XML parsing
-type DocParser struct{}
+// DocParser handles the unzip and XML parsing. OpenDocument text files are
+// recognised by their mimetype and handed to OdtParser.
+type DocParser struct {
+	Limits ParserLimits
+}
 
 func NewDocParser() *DocParser {
-	return &DocParser{}
+	return &DocParser{Limits: DefaultParserLimits()}
 }
 
 type DocStats struct {
@@ -22,6 +27,10 @@ type DocStats struct {
 	ImagesCount   int
 	FormulasCount int
 	TotalPages    int
+	HasTOCField   bool // the table of contents is a Word TOC field rather than typed by hand
+	// PagesEstimated is set when the document carries no page breaks rendered
+	// by the editor and page numbers come from the layout estimate
+	PagesEstimated bool
 }
 
 // ParsedDoc represents a simplified, flat view of the document for easier checking
@@ -32,7 +41,23 @@ type ParsedDoc struct {
 	Tables     []ParsedTable
 	Images     []ParsedImage
 	Formulas   []ParsedFormula
+	Headers    []ParsedHeaderFooter
+	Footers    []ParsedHeaderFooter
+	TitlePage  bool // the first section has its own first-page header/footer (w:titlePg)
 	Stats      DocStats
+
+	Footnotes       []ParsedNote
+	Endnotes        []ParsedNote
+	FootnoteRestart string // continuous, each_page or each_section
+
+	AutoHyphenation bool // automatic hyphenation is on in word/settings.xml
+
+	Properties DocProperties // author, dates, application and statistics saved by the editor
+
+	Revisions []ParsedRevision // tracked changes neither accepted nor rejected
+	Comments  []ParsedComment  // reviewer comments
+
+	Sections []ParsedSection // page setup of every section; the last one is the body-level w:sectPr. Margins and PageSize are those of the main section
 }
 
 type ParsedTable struct {
@@ -49,6 +74,8 @@ type ParsedTable struct {
 	RowCount         int
 	ColCount         int
 	MinRowHeightMm   float64 // smallest explicit row height found (0 if no heights set)
+	ParagraphIndex   int     // index of the paragraph following the table (= paragraphs before it)
+	PageNumber       int
 	HasCaption       bool
 	CaptionText      string
 	CaptionNumber    string
@@ -58,9 +85,18 @@ type ParsedTable struct {
 	CaptionBeforePt  float64
 	CaptionAfterPt   float64
 	CaptionAlignment string
+	Cells            []ParsedCell // cell paragraphs with text, row by row
+}
+
+// ParsedCell is a paragraph inside a table cell. Row and Col count from 1.
+type ParsedCell struct {
+	Row       int
+	Col       int
+	Paragraph ParsedParagraph
 }
 
 type tableCaptionInfo struct {
+	Index     int // paragraph index of the caption
 	Text      string
 	IndentMm  float64
 	BeforePt  float64
@@ -74,6 +110,7 @@ type ParsedImage struct {
 	ParagraphIndex   int
 	PageNumber       int
 	Alignment        string
+	AltText          string
 	HasCaption       bool
 	CaptionText      string
 	CaptionNumber    string
@@ -83,6 +120,18 @@ type ParsedImage struct {
 	CaptionBeforePt  float64
 	CaptionAfterPt   float64
 	CaptionAlignment string
+
+	WidthMm     float64 // displayed size
+	HeightMm    float64
+	PixelWidth  int // size of the picture itself; 0 when unknown
+	PixelHeight int
+	embed       string // relationship id of the picture part
+
+	// NextParagraphIndex is the first non-empty paragraph after the image and
+	// CaptionParagraphIndex the paragraph holding its caption (the image's own
+	// paragraph when the caption follows a line break); -1 if there is none.
+	NextParagraphIndex    int
+	CaptionParagraphIndex int
 }
 
 type ParsedFormula struct {
@@ -90,6 +139,12 @@ type ParsedFormula struct {
 	WrapperID    string // Paragraph ID containing it
 	Alignment    string // center, left, right (from paragraph jc OR oMathPara jc)
 	HasNumbering bool   // paragraph text contains (N) or (N.N) after formula
+	Number       string // the number without parentheses, e.g. "2.1"; empty if not numbered
+
+	Text             string   // linear text of the formula, e.g. "(a+b)/(2)"; empty for images
+	FontSizePt       float64  // size set on the formula runs, or the paragraph's
+	UprightVariables []string // Latin and Greek letters typed upright
+	IsImage          bool     // a picture or an Equation Editor/MathType object
 }
 
 type Margins struct {
@@ -109,7 +164,7 @@ type PageSize struct {
 
 type ParsedParagraph struct {
 	Text              string
-	Role              string  // body, heading, list, toc, table_caption, figure_caption, formula
+	Role              string  // body, heading, list, toc, table_caption, figure_caption, formula; table_cell in ParsedTable.Cells
 	Alignment         string  // left, center, right, both
 	LineSpacing       float64 // Generic multiplier (e.g. 1.5)
 	FirstLineIndentMm float64
@@ -128,6 +183,8 @@ type ParsedParagraph struct {
 	// Structure
 	ID               string // specific ID e.g. "p-1", "p-2"
 	StyleID          string // e.g. "Heading1"
+	StyleName        string // display name of the style, e.g. "heading 1" or "Мой заголовок"
+	OutlineLevel     int    // outline level of the paragraph or its style, 1-9; 0 = body text
 	IsListItem       bool   // true if numPr exists
 	ListLevel        int    // ilvl
 	StartsPageBreak  bool   // if explicit break is found
@@ -135,6 +192,18 @@ type ParsedParagraph struct {
 	HeuristicHeading bool   // true if detected as a heading by visual/text heuristics
 	HeuristicLevel   int    // estimated level: 1 = largest, 2, 3 …
 
+	// Headings by the heading detection of the standard, set when it differs
+	// from the built-in heading styles (see withHeadingDetection)
+	HeadingDetected bool
+	StyledHeading   bool // a heading by its style or outline level
+	StyledLevel     int  // its level, 0 if the style carries none
+
+	// List marker from numbering.xml; empty when the list is not defined there
+	ListNumID    string  // numId: items of one list share it
+	ListFormat   string  // numFmt of the level: bullet, decimal, russianLower, ...
+	ListMarker   string  // marker as printed, e.g. "–", "•", "1."
+	ListIndentMm float64 // marker position from the left margin
+
 	// Page Scope
 	PageNumber int // Estimated page number
 
@@ -142,13 +211,31 @@ type ParsedParagraph struct {
 	KeepLines    bool
 	KeepNext     bool
 	WidowControl bool // true if on (default usually on in Word)
+
+	// Hyphenation
+	SuppressAutoHyphens bool // automatic hyphenation is off for the paragraph
+	SoftHyphens         int  // optional hyphens typed into the text
+
+	HiddenChars int // characters in hidden (w:vanish) or white runs
 }
 
 // formulaNumberingRe matches "(1)", "(1.1)", "(А.1)" etc. anywhere in the line
 // ESKD formulas often have the number in the same paragraph separated by a tab stop
 var formulaNumberingRe = regexp.MustCompile(`\(\s*[\dА-Яа-яA-Za-z]+[.\d]*\s*\)`)
+
+// formulaNumber returns the number of a formula from the text of its
+// paragraph: the last "(N)" label, which stands at the right margin.
+func formulaNumber(text string) string {
+	matches := formulaNumberingRe.FindAllString(text, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(strings.Trim(matches[len(matches)-1], "()"))
+}
+
 var headingNumberingRe = regexp.MustCompile(`^\s*(\d+(?:\.\d+){0,5})\.?\s+\S+`)
 var tocEntryRe = regexp.MustCompile(`^.+[\._\-\s]{2,}\d+$`)
+var tocFieldRe = regexp.MustCompile(`^\s*TOC\b`)
 var tableCaptionRe = regexp.MustCompile(`(?i)^\s*(таблица|табл\.|table)\s*(?:№|n|no\.?)?\s*[:\.\-–—]?\s*[\dа-яa-z]+`)
 var figureCaptionRe = regexp.MustCompile(`(?i)^\s*(рисунок|рис\.|figure|fig\.)\s*(?:№|n|no\.?)?\s*[:\.\-–—]?\s*[\dа-яa-z]+`)
 var tableCaptionNumberRe = regexp.MustCompile(`(?i)^\s*(?:таблица|табл\.|table)\s*(?:№|n|no\.?)?\s*[:\.\-–—]?\s*([0-9]+(?:[\.\-][0-9]+)*)`)
@@ -160,6 +247,23 @@ func (p *DocParser) Parse(filePath string) (*ParsedDoc, error) {
 		return nil, err
 	}
 	defer r.Close()
+	return p.parseZip(&r.Reader)
+}
+
+// ParseReader parses a DOCX (or ODT) package of the given size read from r.
+func (p *DocParser) ParseReader(r io.ReaderAt, size int64) (*ParsedDoc, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseZip(zr)
+}
+
+func (p *DocParser) parseZip(r *zip.Reader) (*ParsedDoc, error) {
+	// 0. Reject zip bombs before extracting anything
+	if err := checkZipPackage(r.File, p.Limits); err != nil {
+		return nil, err
+	}
 
 	// 1. Find and Open word/document.xml
 	var docXMLFile *zip.File
@@ -170,144 +274,65 @@ func (p *DocParser) Parse(filePath string) (*ParsedDoc, error) {
 		}
 	}
 	if docXMLFile == nil {
+		if isODT(r) {
+			return (&OdtParser{Limits: p.Limits}).parseZip(r)
+		}
 		return nil, fmt.Errorf("invalid docx: missing word/document.xml")
 	}
 
-	rc, err := docXMLFile.Open()
+	// 2. Enforce complexity limits, then decode XML
+	xmlData, err := readEntryLimited(docXMLFile, p.Limits, p.Limits.MaxXMLBytes)
 	if err != nil {
 		return nil, err
 	}
-	defer rc.Close()
+	if err := checkXMLComplexity(bytes.NewReader(xmlData), p.Limits); err != nil {
+		return nil, err
+	}
 
-	// 2. Decode XML
 	var doc Document
-	bytes, _ := io.ReadAll(rc)
-	if err := xml.Unmarshal(bytes, &doc); err != nil {
+	if err := xml.Unmarshal(xmlData, &doc); err != nil {
 		return nil, fmt.Errorf("xml decode error: %v", err)
 	}
 
-	styles := p.parseStyles(r)
+	styles := p.parseStyleSheet(r)
+	lists := p.parseNumbering(r)
 
-	return p.convert(doc, styles), nil
+	pd := p.convert(doc, styles, lists)
+	p.parseHeadersFooters(r, doc, styles, pd)
+	p.parseNotes(r, doc, styles, pd)
+	p.parseProperties(r, pd)
+	p.parseRevisions(r, doc, pd)
+	p.parseImagePixels(r, pd)
+	return pd, nil
 }
 
-func (p *DocParser) parseStyles(r *zip.ReadCloser) map[string]Style {
-	styles := make(map[string]Style)
-	var stylesFile *zip.File
-	for _, f := range r.File {
-		if f.Name == "word/styles.xml" {
-			stylesFile = f
-			break
-		}
-	}
-	if stylesFile == nil {
-		return styles
-	}
-
-	rc, err := stylesFile.Open()
-	if err != nil {
-		return styles
-	}
-	defer rc.Close()
+// interner deduplicates equal strings within one parsed document.
+type interner map[string]string
 
-	var doc StylesDoc
-	bytes, err := io.ReadAll(rc)
-	if err != nil || xml.Unmarshal(bytes, &doc) != nil {
-		return styles
-	}
-	for _, style := range doc.Styles {
-		if style.StyleID != "" {
-			styles[style.StyleID] = style
-		}
-	}
-	return styles
+func newInterner() interner {
+	return make(interner)
 }
 
-func (p *DocParser) applyStyleDefaults(pp *ParsedParagraph, styles map[string]Style, seen map[string]bool) {
-	if pp.StyleID == "" || len(styles) == 0 {
-		return
+func (in interner) Intern(s string) string {
+	if s == "" {
+		return s
 	}
-	if seen == nil {
-		seen = make(map[string]bool)
+	if v, ok := in[s]; ok {
+		return v
 	}
-	style, ok := styles[pp.StyleID]
-	if !ok || seen[pp.StyleID] {
-		return
-	}
-	seen[pp.StyleID] = true
-
-	if style.BasedOn != nil && style.BasedOn.Val != "" {
-		parent := *pp
-		parent.StyleID = style.BasedOn.Val
-		p.applyStyleDefaults(&parent, styles, seen)
-		fillMissingParagraphProps(pp, parent)
-	}
-
-	if style.PPr != nil {
-		if pp.Alignment == "" && style.PPr.Jc != nil {
-			pp.Alignment = style.PPr.Jc.Val
-		}
-		if pp.FirstLineIndentMm == 0 && style.PPr.Ind != nil && style.PPr.Ind.FirstLine != "" {
-			pp.FirstLineIndentMm = twipsToMm(style.PPr.Ind.FirstLine)
-		}
-		if pp.LineSpacing == 0 && style.PPr.Spacing != nil && style.PPr.Spacing.Line != "" {
-			if val, err := strconv.Atoi(style.PPr.Spacing.Line); err == nil {
-				pp.LineSpacing = float64(val) / 240.0
-			}
-		}
-	}
-	if style.RPr != nil {
-		applyRunDefaults(pp, style.RPr)
-	}
-}
-
-func fillMissingParagraphProps(target *ParsedParagraph, source ParsedParagraph) {
-	if target.Alignment == "" {
-		target.Alignment = source.Alignment
-	}
-	if target.LineSpacing == 0 {
-		target.LineSpacing = source.LineSpacing
-	}
-	if target.FirstLineIndentMm == 0 {
-		target.FirstLineIndentMm = source.FirstLineIndentMm
-	}
-	if target.FontName == "" {
-		target.FontName = source.FontName
-	}
-	if target.FontSizePt == 0 {
-		target.FontSizePt = source.FontSizePt
-	}
-	target.IsBold = target.IsBold || source.IsBold
-	target.IsItalic = target.IsItalic || source.IsItalic
-	target.IsUnderline = target.IsUnderline || source.IsUnderline
-	target.IsAllCaps = target.IsAllCaps || source.IsAllCaps
-}
-
-func applyRunDefaults(pp *ParsedParagraph, rpr *RPr) {
-	if rpr == nil {
-		return
-	}
-	if pp.FontName == "" && rpr.RFonts != nil {
-		pp.FontName = firstNonEmpty(rpr.RFonts.Ascii, rpr.RFonts.HAnsi, rpr.RFonts.Cs, rpr.RFonts.EastAsia)
-	}
-	if pp.FontSizePt == 0 && rpr.Sz != nil && rpr.Sz.Val != "" {
-		if val, err := strconv.Atoi(rpr.Sz.Val); err == nil {
-			pp.FontSizePt = float64(val) / 2.0
-		}
-	}
-	pp.IsBold = pp.IsBold || onOffEnabled(rpr.B)
-	pp.IsItalic = pp.IsItalic || onOffEnabled(rpr.I)
-	pp.IsUnderline = pp.IsUnderline || (rpr.U != nil && rpr.U.Val != "none")
-	pp.IsAllCaps = pp.IsAllCaps || onOffEnabled(rpr.Caps)
+	in[s] = s
+	return s
 }
 
 // Convert internal XML model to simplified Check Model
-func (p *DocParser) convert(doc Document, styles map[string]Style) *ParsedDoc {
+func (p *DocParser) convert(doc Document, styles *styleSheet, lists numbering) *ParsedDoc {
 	pd := &ParsedDoc{
+		Paragraphs: make([]ParsedParagraph, 0, len(doc.Body.Paragraphs)),
 		Stats: DocStats{
 			TablesCount: len(doc.Body.Tbls),
 		},
 	}
+	strs := newInterner()
 
 	// Pre-scan to find modal (body) font size for heuristic heading detection
 	bodyFontSize := p.detectBodyFontSize(doc)
@@ -430,6 +455,21 @@ func (p *DocParser) convert(doc Document, styles map[string]Style) *ParsedDoc {
 			}
 		}
 
+		for r, row := range tbl.Trs {
+			for c, cell := range row.Tcs {
+				for _, pXML := range cell.P {
+					cp := p.cellParagraph(pXML, styles)
+					if strings.TrimSpace(cp.Text) == "" {
+						continue
+					}
+					cp.StyleID = strs.Intern(cp.StyleID)
+					cp.FontName = strs.Intern(cp.FontName)
+					cp.Alignment = strs.Intern(cp.Alignment)
+					pt.Cells = append(pt.Cells, ParsedCell{Row: r + 1, Col: c + 1, Paragraph: cp})
+				}
+			}
+		}
+
 		pd.Tables = append(pd.Tables, pt)
 	}
 
@@ -463,7 +503,21 @@ func (p *DocParser) convert(doc Document, styles map[string]Style) *ParsedDoc {
 		}
 	}
 
+	// With several sections the page setup of the main text is that of the
+	// main section, not of the last one, which is often a landscape appendix
+	pd.Sections = parsedSections(doc)
+	if len(pd.Sections) > 1 {
+		main := pd.Sections[mainSection(pd.Sections)]
+		if main.Margins != (Margins{}) {
+			pd.Margins = main.Margins
+		}
+		if main.PageSize != (PageSize{}) {
+			pd.PageSize = main.PageSize
+		}
+	}
+
 	currentPage := 1
+	renderedBreaks := false
 
 	// Track captions for tables. A paragraph is a caption if its text starts with
 	// "Таблица" / "Table" (followed by a number). We allow any number of blank
@@ -481,14 +535,40 @@ func (p *DocParser) convert(doc Document, styles map[string]Style) *ParsedDoc {
 
 		// Page break tracking
 		hasDrawing := false
+		hasFormulaObject := false
+		altText := ""
+		var placement *DrawingPlacement
 		for _, r := range runs {
+			if r.Object != nil && r.Object.OLEObject != nil && formulaObjectRe.MatchString(r.Object.OLEObject.ProgID) {
+				hasFormulaObject = true
+			}
 			if r.Drawing != nil {
 				pd.Stats.ImagesCount++
 				hasDrawing = true
+				if altText == "" {
+					altText = r.Drawing.AltText()
+				}
+				if placement == nil {
+					placement = r.Drawing.placement()
+				}
+			}
+			if r.Text != nil && r.RPr != nil && (onOffEnabled(r.RPr.Vanish) || (r.RPr.Color != nil && strings.EqualFold(r.RPr.Color.Val, "FFFFFF"))) {
+				pp.HiddenChars += utf8.RuneCountInString(strings.TrimSpace(r.Text.Content))
+			}
+			if r.InstrText != nil && tocFieldRe.MatchString(r.InstrText.Content) {
+				pd.Stats.HasTOCField = true
 			}
 			if (r.Br != nil && r.Br.Type == "page") || r.LastRenderedPageBreak != nil {
 				currentPage++
 			}
+			if r.LastRenderedPageBreak != nil {
+				renderedBreaks = true
+			}
+		}
+		for _, f := range pXML.FldSimples {
+			if tocFieldRe.MatchString(f.Instr) {
+				pd.Stats.HasTOCField = true
+			}
 		}
 
 		if pXML.PPr != nil {
@@ -514,17 +594,6 @@ func (p *DocParser) convert(doc Document, styles map[string]Style) *ParsedDoc {
 				}
 			}
 
-			pp.KeepLines = pXML.PPr.KeepLines != nil
-			pp.KeepNext = pXML.PPr.KeepNext != nil
-			if pXML.PPr.WidowControl != nil {
-				pp.WidowControl = (pXML.PPr.WidowControl.Val == "on" || pXML.PPr.WidowControl.Val == "")
-				if pXML.PPr.WidowControl.Val == "off" || pXML.PPr.WidowControl.Val == "0" || pXML.PPr.WidowControl.Val == "false" {
-					pp.WidowControl = false
-				}
-			} else {
-				pp.WidowControl = true
-			}
-
 			if pXML.PPr.PageBreakBefore != nil {
 				pp.StartsPageBreak = true
 				currentPage++
@@ -533,64 +602,42 @@ func (p *DocParser) convert(doc Document, styles map[string]Style) *ParsedDoc {
 			if pXML.PPr.PStyle != nil {
 				pp.StyleID = pXML.PPr.PStyle.Val
 			}
-			if pXML.PPr.NumPr != nil {
-				pp.IsListItem = true
-				if pXML.PPr.NumPr.Ilvl != nil {
-					lvl, _ := strconv.Atoi(pXML.PPr.NumPr.Ilvl.Val)
-					pp.ListLevel = lvl
-				}
-			}
-		} else {
-			pp.WidowControl = true
 		}
+		lists.apply(&pp, pXML.PPr, styles.resolve(pp.StyleID))
 
-		p.applyStyleDefaults(&pp, styles, nil)
-
-		// Font
-		if len(runs) > 0 && runs[0].RPr != nil {
-			rpr := runs[0].RPr
-			if rpr.RFonts != nil {
-				pp.FontName = rpr.RFonts.Ascii
-			}
-			if rpr.Sz != nil {
-				val, _ := strconv.Atoi(rpr.Sz.Val)
-				pp.FontSizePt = float64(val) / 2.0
-			}
-			pp.IsBold = onOffEnabled(rpr.B)
-			pp.IsItalic = onOffEnabled(rpr.I)
-			pp.IsUnderline = rpr.U != nil && rpr.U.Val != "none"
-			pp.IsAllCaps = onOffEnabled(rpr.Caps)
-		}
-		if pp.FontName == "" {
-			for _, r := range runs {
-				if r.RPr != nil && r.RPr.RFonts != nil && r.RPr.RFonts.Ascii != "" {
-					pp.FontName = r.RPr.RFonts.Ascii
-					break
-				}
-			}
-		}
-		if pp.FontName == "" && len(runs) > 0 && runs[0].RPr != nil && runs[0].RPr.RFonts != nil {
-			pp.FontName = runs[0].RPr.RFonts.HAnsi
-		}
-		if pp.FontSizePt == 0 {
-			for _, r := range runs {
-				if r.RPr != nil && r.RPr.Sz != nil && r.RPr.Sz.Val != "" {
-					val, _ := strconv.Atoi(r.RPr.Sz.Val)
-					pp.FontSizePt = float64(val) / 2.0
-					break
-				}
-			}
+		// Font: the paragraph style, overridden by direct formatting of the first run
+		var firstRPr *RPr
+		if len(runs) > 0 {
+			firstRPr = runs[0].RPr
 		}
+		styles.apply(&pp, firstRPr)
+		flowProperties(&pp, pXML.PPr)
+		pp.SoftHyphens = countSoftHyphens(runs)
+		runFont(&pp, runs, styles)
 		pp.BoldRatio = calculateBoldRatio(runs)
 
 		if hasDrawing {
-			pd.Images = append(pd.Images, ParsedImage{
+			img := ParsedImage{
 				ID:             fmt.Sprintf("img-%d", len(pd.Images)+1),
 				ParagraphID:    pp.ID,
 				ParagraphIndex: i,
 				PageNumber:     pp.PageNumber,
 				Alignment:      pp.Alignment,
-			})
+				AltText:        altText,
+
+				NextParagraphIndex:    -1,
+				CaptionParagraphIndex: -1,
+			}
+			if placement != nil {
+				if placement.Extent != nil {
+					img.WidthMm = emuToMm(placement.Extent.Cx)
+					img.HeightMm = emuToMm(placement.Extent.Cy)
+				}
+				if placement.Blip != nil {
+					img.embed = placement.Blip.Embed
+				}
+			}
+			pd.Images = append(pd.Images, img)
 		}
 
 		// Check for formulas (oMath directly in paragraph)
@@ -598,14 +645,17 @@ func (p *DocParser) convert(doc Document, styles map[string]Style) *ParsedDoc {
 			pp.HasFormula = true
 			align := pp.Alignment
 			// Check for (N) numbering in paragraph text
-			hasNum := formulaNumberingRe.MatchString(strings.TrimSpace(pp.Text))
+			number := formulaNumber(pp.Text)
 			for k := range pXML.OMaths {
-				pd.Formulas = append(pd.Formulas, ParsedFormula{
+				f := ParsedFormula{
 					ID:           fmt.Sprintf("%s-omath-%d", pp.ID, k),
 					WrapperID:    pp.ID,
 					Alignment:    align,
-					HasNumbering: hasNum,
-				})
+					HasNumbering: number != "",
+					Number:       number,
+				}
+				f.setContent(pXML.OMaths[k:k+1], pp.FontSizePt)
+				pd.Formulas = append(pd.Formulas, f)
 				pd.Stats.FormulasCount++
 			}
 		}
@@ -620,19 +670,42 @@ func (p *DocParser) convert(doc Document, styles map[string]Style) *ParsedDoc {
 					align = "center"
 				}
 			}
-			hasNum := formulaNumberingRe.MatchString(strings.TrimSpace(pp.Text))
-			pd.Formulas = append(pd.Formulas, ParsedFormula{
+			number := formulaNumber(pp.Text)
+			f := ParsedFormula{
 				ID:           fmt.Sprintf("%s-omathpara-%d", pp.ID, k),
 				WrapperID:    pp.ID,
 				Alignment:    align,
-				HasNumbering: hasNum,
+				HasNumbering: number != "",
+				Number:       number,
+			}
+			if omp.OMath != nil {
+				f.setContent([]OMath{*omp.OMath}, pp.FontSizePt)
+			}
+			pd.Formulas = append(pd.Formulas, f)
+			pd.Stats.FormulasCount++
+		}
+		// Formulas pasted as an OLE object, or as a picture followed only by
+		// its number
+		if !pp.HasFormula && (hasFormulaObject || (hasDrawing && formulaNumberOnlyRe.MatchString(pp.Text))) {
+			number := formulaNumber(pp.Text)
+			pd.Formulas = append(pd.Formulas, ParsedFormula{
+				ID:           fmt.Sprintf("%s-object-0", pp.ID),
+				WrapperID:    pp.ID,
+				Alignment:    pp.Alignment,
+				HasNumbering: number != "",
+				Number:       number,
+				FontSizePt:   pp.FontSizePt,
+				IsImage:      true,
 			})
 			pd.Stats.FormulasCount++
 		}
 
+		if pXML.PPr != nil && pXML.PPr.OutlineLvl != nil {
+			pp.OutlineLevel = outlineLevel(pXML.PPr.OutlineLvl.Val)
+		}
 		// Heuristic heading detection for documents where students typed headings
 		// manually instead of using Word heading styles.
-		if !isHeadingStyle(pp.StyleID) && strings.TrimSpace(pp.Text) != "" {
+		if styled, _ := builtinHeading(pp); !styled && strings.TrimSpace(pp.Text) != "" {
 			if ok, level := detectHeuristicHeading(pp, bodyFontSize); ok {
 				pp.HeuristicHeading = true
 				pp.HeuristicLevel = level
@@ -641,28 +714,112 @@ func (p *DocParser) convert(doc Document, styles map[string]Style) *ParsedDoc {
 
 		pp.Role = classifyParagraphRole(pp)
 
+		// Style, font and alignment values repeat across thousands of paragraphs;
+		// interning lets a cached document keep one copy of each.
+		pp.StyleID = strs.Intern(pp.StyleID)
+		pp.FontName = strs.Intern(pp.FontName)
+		pp.Alignment = strs.Intern(pp.Alignment)
+		pp.ListNumID = strs.Intern(pp.ListNumID)
+		pp.ListFormat = strs.Intern(pp.ListFormat)
+		pp.ListMarker = strs.Intern(pp.ListMarker)
+
 		pd.Paragraphs = append(pd.Paragraphs, pp)
 	}
 
 	p.assignObjectCaptions(doc, pd, tableCaptionRe, figureCaptionRe)
 
-	// NOTE: We intentionally do NOT fill in font defaults for paragraphs without run-level
-	// font properties. Such paragraphs inherit their font from their paragraph style (e.g.
-	// "Normal"), which we don't fully resolve here. Filling in fake defaults (e.g. TNR 12pt)
-	// would cause false-positive font violations. The checker guards against FontName=="" and
-	// FontSizePt==0 by skipping those paragraphs.
+	// NOTE: Paragraphs whose font is set neither directly nor by any style (no
+	// styles.xml, no docDefaults) keep FontName=="" / FontSizePt==0. We do not fill in
+	// fake defaults (e.g. TNR 12pt); the checker skips such paragraphs instead.
 
 	pd.Stats.TotalPages = currentPage
+	if !renderedBreaks {
+		estimatePages(doc, styles, pd)
+		pd.Stats.PagesEstimated = true
+	}
+	placeTables(doc, pd)
 	return pd
 }
 
+// runFont fills the font a style left unset from the runs of the paragraph.
+// Theme references of the runs are resolved through styles, which may be nil.
+func runFont(pp *ParsedParagraph, runs []Run, styles *styleSheet) {
+	if pp.FontName == "" {
+		for _, r := range runs {
+			if r.RPr == nil {
+				continue
+			}
+			if name := styles.fontName(r.RPr.RFonts); name != "" {
+				pp.FontName = name
+				break
+			}
+		}
+	}
+	if pp.FontSizePt == 0 {
+		for _, r := range runs {
+			if r.RPr != nil && r.RPr.Sz != nil && r.RPr.Sz.Val != "" {
+				val, _ := strconv.Atoi(r.RPr.Sz.Val)
+				pp.FontSizePt = float64(val) / 2.0
+				break
+			}
+		}
+	}
+}
+
+// cellParagraph parses a paragraph of a table cell: its text, alignment and
+// font, as for a body paragraph. Page flow, lists and roles are left out.
+func (p *DocParser) cellParagraph(pXML Paragraph, styles *styleSheet) ParsedParagraph {
+	pp := ParsedParagraph{Text: p.extractText(pXML), Role: "table_cell"}
+	if pXML.PPr != nil {
+		if pXML.PPr.Jc != nil {
+			pp.Alignment = pXML.PPr.Jc.Val
+		}
+		if pXML.PPr.PStyle != nil {
+			pp.StyleID = pXML.PPr.PStyle.Val
+		}
+	}
+	runs := paragraphRuns(pXML)
+	var firstRPr *RPr
+	if len(runs) > 0 {
+		firstRPr = runs[0].RPr
+	}
+	styles.apply(&pp, firstRPr)
+	runFont(&pp, runs, styles)
+	pp.BoldRatio = calculateBoldRatio(runs)
+	return pp
+}
+
+// placeTables records where each table sits in the paragraph flow. A table
+// takes the page of the paragraph that follows it: that is where the page
+// counter stood when the table started.
+func placeTables(doc Document, pd *ParsedDoc) {
+	next := 0
+	for _, block := range doc.Body.Blocks {
+		switch block.Kind {
+		case BlockParagraph:
+			next = block.Index + 1
+		case BlockTable:
+			if block.Index < 0 || block.Index >= len(pd.Tables) {
+				continue
+			}
+			t := &pd.Tables[block.Index]
+			t.ParagraphIndex = next
+			t.PageNumber = pd.Stats.TotalPages
+			if next < len(pd.Paragraphs) {
+				t.PageNumber = pd.Paragraphs[next].PageNumber
+			}
+		}
+	}
+}
+
 func (p *DocParser) assignObjectCaptions(doc Document, pd *ParsedDoc, tableCaptionRe, figureCaptionRe *regexp.Regexp) {
 	paragraphInfo := func(idx int) tableCaptionInfo {
 		if idx < 0 || idx >= len(pd.Paragraphs) {
-			return tableCaptionInfo{}
+			return tableCaptionInfo{Index: -1}
 		}
 		pp := pd.Paragraphs[idx]
 		return tableCaptionInfo{
+			Index:     idx,
 			Text:      pp.Text,
 			IndentMm:  pp.FirstLineIndentMm,
 			BeforePt:  pp.SpacingBeforePt,
@@ -674,10 +831,10 @@ func (p *DocParser) assignObjectCaptions(doc Document, pd *ParsedDoc, tableCapti
 	findPrevCaption := func(blockPos int, re *regexp.Regexp) (tableCaptionInfo, bool) {
 		for i := blockPos - 1; i >= 0; i-- {
 			block := doc.Body.Blocks[i]
-			if block.Kind == "tbl" {
+			if block.Kind == BlockTable {
 				return tableCaptionInfo{}, false
 			}
-			if block.Kind != "p" || block.Index < 0 || block.Index >= len(pd.Paragraphs) {
+			if block.Kind != BlockParagraph || block.Index < 0 || block.Index >= len(pd.Paragraphs) {
 				continue
 			}
 			text := strings.TrimSpace(pd.Paragraphs[block.Index].Text)
@@ -695,10 +852,10 @@ func (p *DocParser) assignObjectCaptions(doc Document, pd *ParsedDoc, tableCapti
 	findNextCaption := func(blockPos int, re *regexp.Regexp) (tableCaptionInfo, bool) {
 		for i := blockPos + 1; i < len(doc.Body.Blocks); i++ {
 			block := doc.Body.Blocks[i]
-			if block.Kind == "tbl" {
+			if block.Kind == BlockTable {
 				return tableCaptionInfo{}, false
 			}
-			if block.Kind != "p" || block.Index < 0 || block.Index >= len(pd.Paragraphs) {
+			if block.Kind != BlockParagraph || block.Index < 0 || block.Index >= len(pd.Paragraphs) {
 				continue
 			}
 			text := strings.TrimSpace(pd.Paragraphs[block.Index].Text)
@@ -717,9 +874,9 @@ func (p *DocParser) assignObjectCaptions(doc Document, pd *ParsedDoc, tableCapti
 	imageBlockPos := map[int]int{}
 	for blockPos, block := range doc.Body.Blocks {
 		switch block.Kind {
-		case "tbl":
+		case BlockTable:
 			tableBlockPos[block.Index] = blockPos
-		case "p":
+		case BlockParagraph:
 			if block.Index >= 0 && block.Index < len(pd.Paragraphs) {
 				for _, img := range pd.Images {
 					if img.ParagraphIndex == block.Index {
@@ -760,33 +917,57 @@ func (p *DocParser) assignObjectCaptions(doc Document, pd *ParsedDoc, tableCapti
 		}
 	}
 
+	setImageCaption := func(img *ParsedImage, info tableCaptionInfo, below bool) {
+		img.HasCaption = true
+		img.CaptionParagraphIndex = info.Index
+		img.CaptionText = info.Text
+		img.CaptionNumber = extractCaptionNumber(info.Text, figureCaptionNumberRe)
+		img.CaptionBelow = below
+		img.CaptionHasDash = hasFlexibleDash(info.Text)
+		img.CaptionIndentMm = info.IndentMm
+		img.CaptionBeforePt = info.BeforePt
+		img.CaptionAfterPt = info.AfterPt
+		img.CaptionAlignment = info.Alignment
+	}
+
+	// A caption belongs to one image, and a paragraph holding an image can
+	// only caption that image.
+	claimed := map[int]bool{}
+	imageParagraphs := map[int]bool{}
+	for _, img := range pd.Images {
+		imageParagraphs[img.ParagraphIndex] = true
+	}
+	available := func(info tableCaptionInfo) bool {
+		return !claimed[info.Index] && !imageParagraphs[info.Index]
+	}
+
 	for i := range pd.Images {
-		blockPos, ok := imageBlockPos[pd.Images[i].ParagraphIndex]
+		img := &pd.Images[i]
+		for next := img.ParagraphIndex + 1; next < len(pd.Paragraphs); next++ {
+			if strings.TrimSpace(pd.Paragraphs[next].Text) != "" {
+				img.NextParagraphIndex = next
+				break
+			}
+		}
+
+		// "Рисунок 1 – ..." typed after a line break in the image's own paragraph.
+		if own := paragraphInfo(img.ParagraphIndex); figureCaptionRe.MatchString(strings.TrimSpace(own.Text)) {
+			setImageCaption(img, own, true)
+			claimed[own.Index] = true
+			continue
+		}
+		blockPos, ok := imageBlockPos[img.ParagraphIndex]
 		if !ok {
 			continue
 		}
-		if info, found := findNextCaption(blockPos, figureCaptionRe); found {
-			pd.Images[i].HasCaption = true
-			pd.Images[i].CaptionText = info.Text
-			pd.Images[i].CaptionNumber = extractCaptionNumber(info.Text, figureCaptionNumberRe)
-			pd.Images[i].CaptionBelow = true
-			pd.Images[i].CaptionHasDash = hasFlexibleDash(info.Text)
-			pd.Images[i].CaptionIndentMm = info.IndentMm
-			pd.Images[i].CaptionBeforePt = info.BeforePt
-			pd.Images[i].CaptionAfterPt = info.AfterPt
-			pd.Images[i].CaptionAlignment = info.Alignment
+		if info, found := findNextCaption(blockPos, figureCaptionRe); found && available(info) {
+			setImageCaption(img, info, true)
+			claimed[info.Index] = true
 			continue
 		}
-		if info, found := findPrevCaption(blockPos, figureCaptionRe); found {
-			pd.Images[i].HasCaption = true
-			pd.Images[i].CaptionText = info.Text
-			pd.Images[i].CaptionNumber = extractCaptionNumber(info.Text, figureCaptionNumberRe)
-			pd.Images[i].CaptionBelow = false
-			pd.Images[i].CaptionHasDash = hasFlexibleDash(info.Text)
-			pd.Images[i].CaptionIndentMm = info.IndentMm
-			pd.Images[i].CaptionBeforePt = info.BeforePt
-			pd.Images[i].CaptionAfterPt = info.AfterPt
-			pd.Images[i].CaptionAlignment = info.Alignment
+		if info, found := findPrevCaption(blockPos, figureCaptionRe); found && available(info) {
+			setImageCaption(img, info, false)
+			claimed[info.Index] = true
 		}
 	}
 }
@@ -838,6 +1019,19 @@ func paragraphRuns(para Paragraph) []Run {
 	for _, f := range para.FldSimples {
 		runs = append(runs, f.R...)
 	}
+	// Text inserted or moved here with track changes on is shown, and kept
+	// when the changes are accepted; deleted text is neither.
+	for _, rev := range para.Ins {
+		runs = append(runs, rev.R...)
+	}
+	for _, rev := range para.MoveTo {
+		runs = append(runs, rev.R...)
+	}
+	for i := range runs {
+		if runs[i].Drawing == nil {
+			runs[i].Drawing = runs[i].ChoiceDrawing
+		}
+	}
 	return runs
 }
 
@@ -883,6 +1077,32 @@ func (pd *ParsedDoc) ExtractConfig() map[string]interface{} {
 		"orientation": pd.PageSize.Orientation,
 	}
 
+	headerFooter := map[string]interface{}{
+		"header_dist": pd.Margins.HeaderMm,
+		"footer_dist": pd.Margins.FooterMm,
+	}
+	for _, f := range pd.Footers {
+		if f.Type == "default" && f.HasPageNumber {
+			headerFooter["require_page_number"] = true
+			align := normalizeAlignment(f.PageNumberAlignment)
+			if align == "" {
+				align = "left"
+			}
+			headerFooter["page_number_alignment"] = align
+			break
+		}
+	}
+	if pd.TitlePage {
+		titleEmpty := true
+		for _, hf := range append(append([]ParsedHeaderFooter{}, pd.Headers...), pd.Footers...) {
+			if hf.Section == 0 && hf.Type == "first" && !hf.IsEmpty() {
+				titleEmpty = false
+			}
+		}
+		headerFooter["title_page_empty"] = titleEmpty
+	}
+	config["header_footer"] = headerFooter
+
 	// 2. Statistical Analysis of body text
 	fontCounts := make(map[string]int)
 	sizeCounts := make(map[float64]int)
@@ -1130,25 +1350,17 @@ func (pd *ParsedDoc) ExtractConfig() map[string]interface{} {
 	}
 
 	config["formulas"] = map[string]interface{}{
-		"alignment":          fmAlign,
-		"require_numbering":  requireNumbering,
-		"numbering_position": "right",
-		"numbering_format":   "(1)",
+		"alignment":             fmAlign,
+		"require_numbering":     requireNumbering,
+		"numbering_position":    "right",
+		"numbering_format":      "(1)",
+		"check_text_references": false,
 	}
 
 	return config
 }
 
 // Helpers
-func firstNonEmpty(values ...string) string {
-	for _, value := range values {
-		if value != "" {
-			return value
-		}
-	}
-	return ""
-}
-
 func classifyParagraphRole(p ParsedParagraph) string {
 	text := strings.TrimSpace(p.Text)
 	if text == "" {
@@ -1186,6 +1398,41 @@ func hasFlexibleDash(s string) bool {
 	return strings.Contains(s, "—") || strings.Contains(s, "–") || regexp.MustCompile(`\s-\s`).MatchString(s)
 }
 
+// flowProperties overrides the pagination settings pp inherits from its
+// style with the ones set directly on the paragraph.
+func flowProperties(pp *ParsedParagraph, ppr *PPr) {
+	if ppr == nil {
+		return
+	}
+	if ppr.KeepLines != nil {
+		pp.KeepLines = onOffEnabled(ppr.KeepLines)
+	}
+	if ppr.KeepNext != nil {
+		pp.KeepNext = onOffEnabled(ppr.KeepNext)
+	}
+	if ppr.WidowControl != nil {
+		pp.WidowControl = onOffEnabled(&OnOff{Val: ppr.WidowControl.Val})
+	}
+	if ppr.SuppressAutoHyphens != nil {
+		pp.SuppressAutoHyphens = onOffEnabled(ppr.SuppressAutoHyphens)
+	}
+}
+
+// countSoftHyphens counts the optional hyphens of runs: w:softHyphen elements
+// and U+00AD characters in the text.
+func countSoftHyphens(runs []Run) int {
+	n := 0
+	for _, run := range runs {
+		if run.SoftHyphen != nil {
+			n++
+		}
+		if run.Text != nil {
+			n += strings.Count(run.Text.Content, "\u00ad")
+		}
+	}
+	return n
+}
+
 func onOffEnabled(v *OnOff) bool {
 	if v == nil {
 		return false
@@ -1290,3 +1537,8 @@ func twipsToMm(twipsStr string) float64 {
 	}
 	return float64(val) * 25.4 / 1440.0
 }
+
+// emuToMm converts English Metric Units of DrawingML (914400 per inch).
+func emuToMm(emu int64) float64 {
+	return float64(emu) * 25.4 / 914400.0
+}
@@ -0,0 +1,522 @@
+package checker
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PresentationConfig holds the rules for defense presentations (.pptx). A
+// standard may set them next to the document rules, so one standard covers
+// the whole defense package.
+type PresentationConfig struct {
+	MinSlides           int     `json:"min_slides"`            // 0 = not checked; hidden slides are not counted
+	MaxSlides           int     `json:"max_slides"`            // 0 = not checked
+	TitleSlideFields    string  `json:"title_slide_fields"`    // comma-separated words expected on the first slide
+	MinFontSizePt       float64 `json:"min_font_size_pt"`      // 0 = not checked
+	RequireSlideNumbers bool    `json:"require_slide_numbers"` // on every slide but the first
+	RequireSlideTitles  bool    `json:"require_slide_titles"`
+}
+
+// ParsedPresentation is the slide-level content of a .pptx file.
+type ParsedPresentation struct {
+	SlideWidthMm  float64
+	SlideHeightMm float64
+	Slides        []ParsedSlide
+}
+
+// ParsedSlide is one slide in show order. Number counts every slide, hidden
+// ones included, as PowerPoint numbers them.
+type ParsedSlide struct {
+	Number         int
+	Hidden         bool
+	Title          string
+	HasSlideNumber bool // a slide number placeholder or field
+	Paragraphs     []ParsedSlideParagraph
+}
+
+// ParsedSlideParagraph is a paragraph of text on a slide. FontSizePt is the
+// smallest size of its runs as shown, autofit shrinking included; 0 when the
+// size is set nowhere.
+type ParsedSlideParagraph struct {
+	Text        string
+	FontSizePt  float64
+	Placeholder string // title, ctrTitle, subTitle, body, ...; empty for text boxes
+}
+
+// IsPresentation reports whether the file is a presentation, checked by
+// EvaluatePresentation instead of Evaluate. Files are told by extension.
+func IsPresentation(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(filePath), ".pptx")
+}
+
+// Package model of PresentationML, just what the checks need.
+type pptxPresentation struct {
+	SldSz *struct {
+		Cx int64 `xml:"cx,attr"`
+		Cy int64 `xml:"cy,attr"`
+	} `xml:"sldSz"`
+	SldIDs []struct {
+		RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sldIdLst>sldId"`
+}
+
+type pptxSlide struct {
+	Show   string        `xml:"show,attr"` // "0" for a hidden slide
+	Shapes pptxShapeTree `xml:"cSld>spTree"`
+}
+
+type pptxShapeTree struct {
+	Shapes []pptxShape     `xml:"sp"`
+	Groups []pptxShapeTree `xml:"grpSp"`
+}
+
+type pptxShape struct {
+	Placeholder *struct {
+		Type string `xml:"type,attr"`
+		Idx  string `xml:"idx,attr"`
+	} `xml:"nvSpPr>nvPr>ph"`
+	Autofit *struct {
+		FontScale string `xml:"fontScale,attr"` // thousandths of a percent
+	} `xml:"txBody>bodyPr>normAutofit"`
+	ListStyle  pptxTextStyle   `xml:"txBody>lstStyle"`
+	Paragraphs []pptxParagraph `xml:"txBody>p"`
+}
+
+type pptxParagraph struct {
+	PPr *struct {
+		Lvl int `xml:"lvl,attr"`
+	} `xml:"pPr"`
+	Runs   []pptxRun `xml:"r"`
+	Fields []struct {
+		Type string   `xml:"type,attr"`
+		RPr  *pptxRPr `xml:"rPr"`
+		Text string   `xml:"t"`
+	} `xml:"fld"`
+}
+
+type pptxRun struct {
+	RPr  *pptxRPr `xml:"rPr"`
+	Text string   `xml:"t"`
+}
+
+type pptxRPr struct {
+	Sz int `xml:"sz,attr"` // hundredths of a point
+}
+
+// pptxTextStyle is a list of level styles, a:lvl1pPr to a:lvl9pPr.
+type pptxTextStyle struct {
+	Levels []struct {
+		XMLName xml.Name
+		DefRPr  *pptxRPr `xml:"defRPr"`
+	} `xml:",any"`
+}
+
+// size returns the font size of level lvl (0-based) in hundredths of a point, or 0.
+func (s pptxTextStyle) size(lvl int) int {
+	name := fmt.Sprintf("lvl%dpPr", lvl+1)
+	for _, l := range s.Levels {
+		if l.XMLName.Local == name && l.DefRPr != nil {
+			return l.DefRPr.Sz
+		}
+	}
+	return 0
+}
+
+type pptxMaster struct {
+	Title  pptxTextStyle `xml:"txStyles>titleStyle"`
+	Body   pptxTextStyle `xml:"txStyles>bodyStyle"`
+	Other  pptxTextStyle `xml:"txStyles>otherStyle"`
+	Shapes pptxShapeTree `xml:"cSld>spTree"`
+}
+
+// PptxParser reads PowerPoint presentations (.pptx).
+type PptxParser struct {
+	Limits ParserLimits
+}
+
+func (x *PptxParser) Parse(filePath string) (*ParsedPresentation, error) {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return x.parseZip(&r.Reader)
+}
+
+func (x *PptxParser) parseZip(r *zip.Reader) (*ParsedPresentation, error) {
+	if err := checkZipPackage(r.File, x.Limits); err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+
+	var pres pptxPresentation
+	if err := x.decode(files, "ppt/presentation.xml", &pres); err != nil {
+		return nil, fmt.Errorf("invalid pptx: %v", err)
+	}
+	pp := &ParsedPresentation{}
+	if pres.SldSz != nil {
+		pp.SlideWidthMm = emuToMm(pres.SldSz.Cx)
+		pp.SlideHeightMm = emuToMm(pres.SldSz.Cy)
+	}
+
+	slideTargets := x.partTargets(files, "ppt/presentation.xml")
+	templates := pptxTemplates{layouts: map[string]pptxShapeTree{}, masters: map[string]pptxMaster{}}
+	for n, id := range pres.SldIDs {
+		part := slideTargets[id.RID]
+		var slide pptxSlide
+		if err := x.decode(files, part, &slide); err != nil {
+			return nil, fmt.Errorf("invalid pptx: %v", err)
+		}
+		layout, master := x.slideTemplates(files, part, templates)
+		pp.Slides = append(pp.Slides, parseSlide(n+1, slide, layout, master))
+	}
+	return pp, nil
+}
+
+// decode reads and decodes the XML part name into v within the limits.
+func (x *PptxParser) decode(files map[string]*zip.File, name string, v interface{}) error {
+	f := files[name]
+	if f == nil {
+		return fmt.Errorf("missing %s", name)
+	}
+	data, err := readEntryLimited(f, x.Limits, x.Limits.MaxXMLBytes)
+	if err != nil {
+		return err
+	}
+	if err := checkXMLComplexity(bytes.NewReader(data), x.Limits); err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("xml decode error in %s: %v", name, err)
+	}
+	return nil
+}
+
+// partTargets maps the relationship ids of a part to the parts they point to.
+func (x *PptxParser) partTargets(files map[string]*zip.File, part string) map[string]string {
+	targets := map[string]string{}
+	f := files[path.Join(path.Dir(part), "_rels", path.Base(part)+".rels")]
+	if f == nil {
+		return targets
+	}
+	data, err := readEntryLimited(f, x.Limits, 0)
+	if err != nil {
+		return targets
+	}
+	var rels Relationships
+	if xml.Unmarshal(data, &rels) != nil {
+		return targets
+	}
+	for _, rel := range rels.Rels {
+		if strings.HasPrefix(rel.Target, "/") {
+			targets[rel.ID] = strings.TrimPrefix(rel.Target, "/")
+		} else {
+			targets[rel.ID] = path.Join(path.Dir(part), rel.Target)
+		}
+	}
+	return targets
+}
+
+// pptxTemplates keeps the layouts and masters already decoded, by part name:
+// most slides share a few of them.
+type pptxTemplates struct {
+	layouts map[string]pptxShapeTree
+	masters map[string]pptxMaster
+}
+
+// slideTemplates returns the layout and the master of a slide. Missing or
+// broken parts come back empty: the sizes they set are then unknown.
+func (x *PptxParser) slideTemplates(files map[string]*zip.File, slidePart string, cache pptxTemplates) (pptxShapeTree, pptxMaster) {
+	layoutPart := relationshipOfType(x.partTargets(files, slidePart), "/slideLayouts/")
+	if layoutPart == "" {
+		return pptxShapeTree{}, pptxMaster{}
+	}
+	layout, ok := cache.layouts[layoutPart]
+	if !ok {
+		var part struct {
+			Shapes pptxShapeTree `xml:"cSld>spTree"`
+		}
+		_ = x.decode(files, layoutPart, &part)
+		layout = part.Shapes
+		cache.layouts[layoutPart] = layout
+	}
+	masterPart := relationshipOfType(x.partTargets(files, layoutPart), "/slideMasters/")
+	if masterPart == "" {
+		return layout, pptxMaster{}
+	}
+	master, ok := cache.masters[masterPart]
+	if !ok {
+		_ = x.decode(files, masterPart, &master)
+		cache.masters[masterPart] = master
+	}
+	return layout, master
+}
+
+// relationshipOfType returns the first target inside the given folder.
+func relationshipOfType(targets map[string]string, folder string) string {
+	for _, t := range targets {
+		if strings.Contains("/"+t, folder) {
+			return t
+		}
+	}
+	return ""
+}
+
+// parseSlide collects the text of a slide with the font sizes it is shown
+// in. A size not set on the run comes from the list style of the shape, then
+// of the matching layout and master placeholders, then from the text styles
+// of the master.
+func parseSlide(number int, slide pptxSlide, layout pptxShapeTree, master pptxMaster) ParsedSlide {
+	ps := ParsedSlide{Number: number, Hidden: slide.Show == "0" || slide.Show == "false"}
+	for _, sh := range slide.Shapes.all() {
+		phType, phIdx := "", ""
+		if sh.Placeholder != nil {
+			phType, phIdx = sh.Placeholder.Type, sh.Placeholder.Idx
+			if phType == "" {
+				phType = "body" // a placeholder without a type is a content placeholder
+			}
+		}
+		if phType == "sldNum" {
+			ps.HasSlideNumber = true
+		}
+		scale := 1.0
+		if sh.Autofit != nil && sh.Autofit.FontScale != "" {
+			if v, err := strconv.Atoi(sh.Autofit.FontScale); err == nil && v > 0 {
+				scale = float64(v) / 100000
+			}
+		}
+		var layoutStyle, masterPlaceholder pptxTextStyle
+		if sh.Placeholder != nil {
+			layoutStyle = layout.placeholder(phType, phIdx).ListStyle
+			masterPlaceholder = master.Shapes.placeholder(phType, "").ListStyle
+		}
+		masterStyle := master.Other
+		switch phType {
+		case "title", "ctrTitle":
+			masterStyle = master.Title
+		case "body", "subTitle", "obj":
+			masterStyle = master.Body
+		}
+
+		var title []string
+		for _, p := range sh.Paragraphs {
+			lvl := 0
+			if p.PPr != nil {
+				lvl = p.PPr.Lvl
+			}
+			inherited := sh.ListStyle.size(lvl)
+			if inherited == 0 {
+				inherited = layoutStyle.size(lvl)
+			}
+			if inherited == 0 {
+				inherited = masterPlaceholder.size(lvl)
+			}
+			if inherited == 0 {
+				inherited = masterStyle.size(lvl)
+			}
+
+			var text strings.Builder
+			smallest := 0
+			use := func(rpr *pptxRPr, t string) {
+				text.WriteString(t)
+				if strings.TrimSpace(t) == "" {
+					return
+				}
+				sz := inherited
+				if rpr != nil && rpr.Sz > 0 {
+					sz = rpr.Sz
+				}
+				if sz > 0 && (smallest == 0 || sz < smallest) {
+					smallest = sz
+				}
+			}
+			for _, r := range p.Runs {
+				use(r.RPr, r.Text)
+			}
+			for _, f := range p.Fields {
+				if f.Type == "slidenum" {
+					ps.HasSlideNumber = true
+				}
+				use(f.RPr, f.Text)
+			}
+			t := strings.TrimSpace(text.String())
+			if t == "" {
+				continue
+			}
+			ps.Paragraphs = append(ps.Paragraphs, ParsedSlideParagraph{
+				Text:        t,
+				FontSizePt:  float64(smallest) / 100 * scale,
+				Placeholder: phType,
+			})
+			if phType == "title" || phType == "ctrTitle" {
+				title = append(title, t)
+			}
+		}
+		if ps.Title == "" && len(title) > 0 {
+			ps.Title = strings.Join(title, " ")
+		}
+	}
+	return ps
+}
+
+// all returns the shapes of the tree, groups flattened.
+func (t pptxShapeTree) all() []pptxShape {
+	shapes := append([]pptxShape{}, t.Shapes...)
+	for _, g := range t.Groups {
+		shapes = append(shapes, g.all()...)
+	}
+	return shapes
+}
+
+// placeholder finds the layout placeholder a slide placeholder inherits
+// from: the one with the same index, else the same type.
+func (t pptxShapeTree) placeholder(phType, idx string) pptxShape {
+	var byType *pptxShape
+	for _, sh := range t.all() {
+		if sh.Placeholder == nil {
+			continue
+		}
+		if idx != "" && sh.Placeholder.Idx == idx {
+			return sh
+		}
+		if byType == nil && sh.Placeholder.Type == phType {
+			s := sh
+			byType = &s
+		}
+	}
+	if byType != nil {
+		return *byType
+	}
+	return pptxShape{}
+}
+
+// checkPresentation checks a presentation against the presentation rules of
+// the standard. Hidden slides are not shown at the defense and are skipped.
+func checkPresentation(pres *ParsedPresentation, cfg PresentationConfig) ([]models.Violation, int) {
+	var violations []models.Violation
+	totalRules := 0
+	var slides []ParsedSlide
+	for _, s := range pres.Slides {
+		if !s.Hidden {
+			slides = append(slides, s)
+		}
+	}
+	at := func(s ParsedSlide, v models.Violation) models.Violation {
+		v.PositionInDoc = fmt.Sprintf("Slide %d", s.Number)
+		if s.Title != "" {
+			v.PositionInDoc += ": " + truncate(s.Title, 100)
+		}
+		v.Location = &models.Location{Page: s.Number}
+		return v
+	}
+
+	if cfg.MinSlides > 0 || cfg.MaxSlides > 0 {
+		totalRules++
+		n := len(slides)
+		if (cfg.MinSlides > 0 && n < cfg.MinSlides) || (cfg.MaxSlides > 0 && n > cfg.MaxSlides) {
+			violations = append(violations, models.Violation{
+				RuleType:      "presentation_slide_count",
+				Description:   "Число слайдов не соответствует требованиям",
+				PositionInDoc: "Презентация",
+				ExpectedValue: countRange(cfg.MinSlides, cfg.MaxSlides),
+				ActualValue:   fmt.Sprintf("%d", n),
+				Suggestion:    "Скрытые слайды не учитываются",
+				Severity:      "error",
+			})
+		}
+	}
+	if len(slides) == 0 {
+		return violations, totalRules
+	}
+
+	if fields := strings.Split(cfg.TitleSlideFields, ","); strings.TrimSpace(cfg.TitleSlideFields) != "" {
+		var text []string
+		for _, p := range slides[0].Paragraphs {
+			text = append(text, p.Text)
+		}
+		lower := strings.ToLower(strings.Join(text, "\n"))
+		for _, f := range fields {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			totalRules++
+			if !strings.Contains(lower, strings.ToLower(f)) {
+				violations = append(violations, at(slides[0], models.Violation{
+					RuleType:      "presentation_title_slide",
+					Description:   fmt.Sprintf("На титульном слайде нет поля «%s»", f),
+					ExpectedValue: f,
+					ActualValue:   "Не найдено",
+					Suggestion:    "Титульный слайд должен содержать тему работы, автора и руководителя",
+					Severity:      "error",
+				}))
+			}
+		}
+	}
+
+	var unnumbered []string
+	for i, s := range slides {
+		if cfg.MinFontSizePt > 0 {
+			totalRules++
+			smallest := 0.0
+			var sample string
+			for _, p := range s.Paragraphs {
+				if p.FontSizePt > 0 && p.FontSizePt < cfg.MinFontSizePt-0.25 && (smallest == 0 || p.FontSizePt < smallest) {
+					smallest, sample = p.FontSizePt, p.Text
+				}
+			}
+			if smallest > 0 {
+				v := at(s, withBound(models.Violation{
+					RuleType:    "presentation_font_size",
+					Description: "Слишком мелкий шрифт на слайде",
+					Suggestion:  "Мелкий текст не читается из зала: сократите текст или разбейте слайд",
+					Severity:    "warning",
+					ContextText: contextSnippet(sample),
+				}, models.UnitPoint, models.BoundMin, cfg.MinFontSizePt, smallest))
+				violations = append(violations, v)
+			}
+		}
+		if cfg.RequireSlideTitles {
+			totalRules++
+			if s.Title == "" {
+				violations = append(violations, at(s, models.Violation{
+					RuleType:      "presentation_slide_title",
+					Description:   "Слайд без заголовка",
+					ExpectedValue: "Заголовок слайда",
+					ActualValue:   "Нет",
+					Suggestion:    "Введите заголовок в поле заголовка макета слайда",
+					Severity:      "warning",
+				}))
+			}
+		}
+		if i > 0 && !s.HasSlideNumber {
+			unnumbered = append(unnumbered, strconv.Itoa(s.Number))
+		}
+	}
+
+	if cfg.RequireSlideNumbers && len(slides) > 1 {
+		totalRules++
+		if len(unnumbered) > 0 {
+			violations = append(violations, models.Violation{
+				RuleType:      "presentation_slide_number",
+				Description:   "Слайды без номера",
+				PositionInDoc: "Презентация",
+				ExpectedValue: "Номер на каждом слайде, кроме титульного",
+				ActualValue:   "Нет номера на слайдах: " + strings.Join(unnumbered, ", "),
+				Suggestion:    "Включите «Вставка → Номер слайда» для всех слайдов, кроме титульного",
+				Severity:      "warning",
+			})
+		}
+	}
+	return violations, totalRules
+}
@@ -0,0 +1,120 @@
+package checker
+
+import (
+	"strings"
+)
+
+// The parts of a document a standard may give its own formatting rules.
+const (
+	partTitlePage    = "title_page"
+	partTOC          = "toc"
+	partBody         = "body"
+	partBibliography = "bibliography"
+	partAppendices   = "appendices"
+)
+
+// SectionProfile holds the font and paragraph rules of one part of the
+// document. They replace the rules of the standard there as a whole: an
+// empty value is not checked in that part, except the tolerances, which are
+// inherited when 0.
+type SectionProfile struct {
+	Font      FontConfig      `json:"font"`
+	Paragraph ParagraphConfig `json:"paragraph"`
+}
+
+// SectionProfilesConfig gives parts of the document their own rules, e.g. a
+// centered title page in 14 pt or a bibliography without first-line indent.
+// A part without a profile follows the rules of the standard; the title page,
+// the table of contents and the bibliography are then not checked.
+type SectionProfilesConfig struct {
+	TitlePage    *SectionProfile `json:"title_page"`
+	TOC          *SectionProfile `json:"toc"`
+	Body         *SectionProfile `json:"body"`
+	Bibliography *SectionProfile `json:"bibliography"`
+	Appendices   *SectionProfile `json:"appendices"`
+}
+
+// profile returns the profile of a part, or nil.
+func (c SectionProfilesConfig) profile(part string) *SectionProfile {
+	switch part {
+	case partTitlePage:
+		return c.TitlePage
+	case partTOC:
+		return c.TOC
+	case partBody:
+		return c.Body
+	case partBibliography:
+		return c.Bibliography
+	case partAppendices:
+		return c.Appendices
+	}
+	return nil
+}
+
+// formatting returns the font and paragraph rules of a part.
+func (c SectionProfilesConfig) formatting(part string, font FontConfig, paragraph ParagraphConfig) (FontConfig, ParagraphConfig) {
+	if p := c.profile(part); p != nil {
+		return p.Font, p.Paragraph
+	}
+	return font, paragraph
+}
+
+// inherit fills the tolerances the profiles leave empty from the rules of
+// the standard.
+func (c *SectionProfilesConfig) inherit(font FontConfig, paragraph ParagraphConfig) {
+	for _, p := range []*SectionProfile{c.TitlePage, c.TOC, c.Body, c.Bibliography, c.Appendices} {
+		if p == nil {
+			continue
+		}
+		if p.Font.SizeTolerance == 0 {
+			p.Font.SizeTolerance = font.SizeTolerance
+		}
+		if p.Paragraph.LineSpacingTolerance == 0 {
+			p.Paragraph.LineSpacingTolerance = paragraph.LineSpacingTolerance
+		}
+		if p.Paragraph.IndentTolerance == 0 {
+			p.Paragraph.IndentTolerance = paragraph.IndentTolerance
+		}
+	}
+}
+
+// documentParts assigns each paragraph to a part of the document by the
+// headings recognized in it. The title page is what precedes the table of
+// contents and the first heading on the first page; the table of contents
+// runs from its title over the entries; the bibliography from its heading to
+// the next one; the appendices from the first appendix heading to the end.
+// Everything else is the body.
+func documentParts(paragraphs []ParsedParagraph, refs ReferencesConfig) []string {
+	parts := make([]string, len(paragraphs))
+	part := partTitlePage
+	for i, p := range paragraphs {
+		text := strings.TrimSpace(p.Text)
+		heading := p.Role != "toc" && isHeadingParagraph(p)
+		if part == partTitlePage && (heading || p.Role == "toc" || isTOCTitle(text) || p.PageNumber > paragraphs[0].PageNumber) {
+			part = partBody
+		}
+		if part != partTitlePage && part != partAppendices && text != "" {
+			switch {
+			case isTOCTitle(text) || p.Role == "toc" || (part == partTOC && !heading && isTOCParagraph(p)):
+				part = partTOC
+			case heading && strings.HasPrefix(strings.ToLower(text), "приложение"):
+				part = partAppendices
+			case heading && isReferenceHeading(text, refs):
+				part = partBibliography
+			case heading || part == partTOC:
+				part = partBody
+			}
+		}
+		parts[i] = part
+	}
+	return parts
+}
+
+// isTOCTitle reports whether text is the title of the table of contents.
+func isTOCTitle(text string) bool {
+	switch strings.ToLower(strings.Trim(text, " .:")) {
+	case "содержание", "оглавление", "contents", "table of contents":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"strings"
+	"unicode"
+)
+
+// RepetitionConfig flags copy-paste padding: body paragraphs repeated word
+// for word and longer blocks of text that occur twice in the document.
+type RepetitionConfig struct {
+	Enabled bool `json:"enabled"`
+	// MinParagraphWords is the length of the shortest paragraph reported as a
+	// verbatim duplicate, in words; 0 means 10.
+	MinParagraphWords int `json:"min_paragraph_words"`
+	// MinBlockWords is the length of the shortest repeated block of text,
+	// which may span paragraphs, in words; 0 means 50.
+	MinBlockWords int `json:"min_block_words"`
+}
+
+const (
+	defaultRepetitionParagraphWords = 10
+	defaultRepetitionBlockWords     = 50
+	// repetitionShingleWords is the length of the word shingles indexed to
+	// find repeated blocks; a block shorter than a shingle is never found.
+	repetitionShingleWords = 8
+	// maxReportedRepetitions bounds the repetitions reported one by one.
+	maxReportedRepetitions = 20
+)
+
+// repetitionWord is a normalized word of the body text and its paragraph.
+type repetitionWord struct {
+	text      string
+	paragraph int
+}
+
+// checkRepetition looks for duplicated paragraphs and repeated blocks in the
+// body text. Headings, captions, lists and the table of contents are left
+// out: they repeat by nature. Each repetition is reported at its later copy.
+func checkRepetition(doc *ParsedDoc, cfg RepetitionConfig, startPage int) ([]models.Violation, int) {
+	minParagraph := cfg.MinParagraphWords
+	if minParagraph <= 0 {
+		minParagraph = defaultRepetitionParagraphWords
+	}
+	minBlock := cfg.MinBlockWords
+	if minBlock <= 0 {
+		minBlock = defaultRepetitionBlockWords
+	}
+	if minBlock < repetitionShingleWords {
+		minBlock = repetitionShingleWords
+	}
+
+	var vs []models.Violation
+	report := func(i int, v models.Violation) {
+		p := doc.Paragraphs[i]
+		v.PositionInDoc = fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, i+1, truncate(strings.TrimSpace(p.Text), 100))
+		v.ContextText = contextSnippet(p.Text)
+		v.Location = paragraphLocation(i, p)
+		v.Severity = models.SeverityWarning
+		vs = append(vs, v)
+	}
+
+	// Verbatim duplicates; their later copies are left out of the block search
+	firstCopy := map[string]int{}
+	var words []repetitionWord
+	for i, p := range doc.Paragraphs {
+		if p.PageNumber < startPage || p.Role != "body" || isHeadingParagraph(p) {
+			continue
+		}
+		tokens := repetitionTokens(p.Text)
+		if len(tokens) == 0 {
+			continue
+		}
+		key := strings.Join(tokens, " ")
+		if first, ok := firstCopy[key]; ok && len(tokens) >= minParagraph {
+			report(i, models.Violation{
+				RuleType:      "duplicate_paragraph",
+				Description:   "Абзац повторяет текст другого абзаца дословно",
+				ExpectedValue: "Без повторов",
+				ActualValue:   fmt.Sprintf("Повторяет абзац %d (стр. %d), %d %s", first+1, doc.Paragraphs[first].PageNumber, len(tokens), pluralRu(len(tokens), "слово", "слова", "слов")),
+				Suggestion:    "Удалите повтор или перескажите мысль, сославшись на сказанное ранее",
+			})
+			continue
+		}
+		if _, ok := firstCopy[key]; !ok {
+			firstCopy[key] = i
+		}
+		for _, t := range tokens {
+			words = append(words, repetitionWord{text: t, paragraph: i})
+		}
+	}
+
+	// Repeated blocks: every shingle is indexed at its first occurrence; a
+	// shingle seen before starts a block, which is extended word by word
+	// while both copies agree and do not overlap.
+	shingles := map[string]int{}
+	for j := 0; j+repetitionShingleWords <= len(words); {
+		key := shingleKey(words[j : j+repetitionShingleWords])
+		i, seen := shingles[key]
+		if !seen {
+			shingles[key] = j
+			j++
+			continue
+		}
+		n := 0
+		for j+n < len(words) && i+n < j && words[i+n].text == words[j+n].text {
+			n++
+		}
+		if n < minBlock {
+			j++
+			continue
+		}
+		for k := j; k+repetitionShingleWords <= j+n; k++ {
+			if _, ok := shingles[shingleKey(words[k:k+repetitionShingleWords])]; !ok {
+				shingles[shingleKey(words[k:k+repetitionShingleWords])] = k
+			}
+		}
+		source := doc.Paragraphs[words[i].paragraph]
+		report(words[j].paragraph, models.Violation{
+			RuleType:      "repeated_block",
+			Description:   "Фрагмент текста повторяется в документе",
+			ExpectedValue: fmt.Sprintf("Без повторов от %d слов", minBlock),
+			ActualValue:   fmt.Sprintf("%d %s повторяют абзац %d (стр. %d)", n, pluralRu(n, "слово", "слова", "слов"), words[i].paragraph+1, source.PageNumber),
+			Suggestion:    "Удалите повторяющийся фрагмент или перескажите его, сославшись на сказанное ранее",
+		})
+		j += n
+	}
+
+	if len(vs) > maxReportedRepetitions {
+		rest := len(vs) - maxReportedRepetitions
+		vs = append(vs[:maxReportedRepetitions], models.Violation{
+			RuleType:      "repeated_block",
+			Description:   "Повторов в документе больше, чем показано",
+			PositionInDoc: "Глобально",
+			ExpectedValue: "Без повторов",
+			ActualValue:   fmt.Sprintf("Ещё %d %s", rest, pluralRu(rest, "повтор", "повтора", "повторов")),
+			Severity:      models.SeverityWarning,
+		})
+	}
+	return vs, 1
+}
+
+// repetitionTokens splits text into lower-cased words of letters and digits,
+// so that punctuation and spacing do not hide a copy.
+func repetitionTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func shingleKey(words []repetitionWord) string {
+	var sb strings.Builder
+	for i, w := range words {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(w.text)
+	}
+	return sb.String()
+}
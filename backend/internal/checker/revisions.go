@@ -0,0 +1,378 @@
+package checker
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"sort"
+	"strings"
+)
+
+// Kinds of tracked changes.
+const (
+	revisionInsertion  = "insertion"
+	revisionDeletion   = "deletion"
+	revisionMove       = "move"
+	revisionFormatting = "formatting"
+)
+
+// ParsedRevision is a tracked change that was neither accepted nor rejected.
+type ParsedRevision struct {
+	Kind   string // insertion, deletion, move or formatting
+	Author string
+	Date   string
+	Text   string // inserted, deleted or moved text; empty for formatting
+	// ParagraphIndex is the body paragraph holding the change, TableIndex the
+	// table when it is in a cell (ParagraphIndex is then -1). Both are -1
+	// when the place is unknown, as in ODT.
+	ParagraphIndex int
+	TableIndex     int
+}
+
+// ParsedComment is a reviewer comment left in the document.
+type ParsedComment struct {
+	ID             string
+	Author         string
+	Date           string
+	Text           string
+	Resolved       bool // marked done in Word or LibreOffice
+	ParagraphIndex int  // body paragraph holding the reference; -1 when unknown
+}
+
+// paragraphRevisions returns the tracked changes of para. A paragraph with
+// several formatting changes counts as one, and a changed paragraph mark only
+// counts when nothing else in the paragraph changed.
+func paragraphRevisions(para Paragraph) []ParsedRevision {
+	var revs []ParsedRevision
+	add := func(kind string, rev Revision) {
+		var sb strings.Builder
+		for _, run := range rev.R {
+			if run.Text != nil {
+				sb.WriteString(run.Text.Content)
+			}
+			if run.DelText != nil {
+				sb.WriteString(run.DelText.Content)
+			}
+		}
+		revs = append(revs, ParsedRevision{Kind: kind, Author: rev.Author, Date: rev.Date, Text: sb.String(), ParagraphIndex: -1, TableIndex: -1})
+	}
+	for _, rev := range para.Ins {
+		add(revisionInsertion, rev)
+	}
+	for _, rev := range para.Del {
+		add(revisionDeletion, rev)
+	}
+	// A move is recorded at both ends; the destination holds the same text.
+	for _, rev := range para.MoveTo {
+		add(revisionMove, rev)
+	}
+	if len(para.MoveTo) == 0 {
+		for _, rev := range para.MoveFrom {
+			add(revisionMove, rev)
+		}
+	}
+
+	var format *Revision
+	if para.PPr != nil && para.PPr.Change != nil {
+		format = para.PPr.Change
+	}
+	for _, run := range paragraphRuns(para) {
+		if format == nil && run.RPr != nil && run.RPr.Change != nil {
+			format = run.RPr.Change
+		}
+	}
+	if format != nil {
+		add(revisionFormatting, Revision{Author: format.Author, Date: format.Date})
+	}
+
+	if len(revs) == 0 && para.PPr != nil && para.PPr.RPr != nil {
+		if mark := para.PPr.RPr.MarkIns; mark != nil {
+			add(revisionInsertion, *mark)
+		} else if mark := para.PPr.RPr.MarkDel; mark != nil {
+			add(revisionDeletion, *mark)
+		}
+	}
+	return revs
+}
+
+// parseRevisions fills pd.Revisions from the body and its tables, and
+// pd.Comments from word/comments.xml and word/commentsExtended.xml. Missing or
+// broken parts leave the comments empty.
+func (p *DocParser) parseRevisions(r *zip.Reader, doc Document, pd *ParsedDoc) {
+	for i, para := range doc.Body.Paragraphs {
+		if i >= len(pd.Paragraphs) {
+			break
+		}
+		for _, rev := range paragraphRevisions(para) {
+			rev.ParagraphIndex = i
+			pd.Revisions = append(pd.Revisions, rev)
+		}
+	}
+	for t, tbl := range doc.Body.Tbls {
+		if t >= len(pd.Tables) {
+			break
+		}
+		for _, tr := range tbl.Trs {
+			for _, tc := range tr.Tcs {
+				for _, para := range tc.P {
+					for _, rev := range paragraphRevisions(para) {
+						rev.TableIndex = t
+						pd.Revisions = append(pd.Revisions, rev)
+					}
+				}
+			}
+		}
+	}
+
+	var comments CommentsDoc
+	var commentsEx CommentsExDoc
+	for _, f := range r.File {
+		switch f.Name {
+		case "word/comments.xml":
+			if data, err := readEntryLimited(f, p.Limits, 0); err == nil {
+				xml.Unmarshal(data, &comments)
+			}
+		case "word/commentsExtended.xml":
+			if data, err := readEntryLimited(f, p.Limits, 0); err == nil {
+				xml.Unmarshal(data, &commentsEx)
+			}
+		}
+	}
+	if len(comments.Comments) == 0 {
+		return
+	}
+
+	done := map[string]bool{}
+	for _, c := range commentsEx.Comments {
+		done[c.ParaID] = c.Done == "1" || c.Done == "true"
+	}
+	anchors := map[string]int{}
+	for i, para := range doc.Body.Paragraphs {
+		for _, run := range paragraphRuns(para) {
+			if ref := run.CommentReference; ref != nil {
+				if _, ok := anchors[ref.ID]; !ok {
+					anchors[ref.ID] = i
+				}
+			}
+		}
+	}
+
+	for _, c := range comments.Comments {
+		pc := ParsedComment{ID: c.ID, Author: c.Author, Date: c.Date, ParagraphIndex: -1}
+		var texts []string
+		for _, para := range c.Paragraphs {
+			if text := strings.TrimSpace(p.extractText(para)); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		pc.Text = strings.Join(texts, " ")
+		if n := len(c.Paragraphs); n > 0 {
+			pc.Resolved = done[c.Paragraphs[n-1].ParaID]
+		}
+		if i, ok := anchors[c.ID]; ok && i < len(pd.Paragraphs) {
+			pc.ParagraphIndex = i
+		}
+		pd.Comments = append(pd.Comments, pc)
+	}
+}
+
+// odtRevisions reads the tracked changes (text:changed-region) and the
+// annotations of an ODT content.xml. LibreOffice 7.2 and later mark resolved
+// annotations with loext:resolved.
+func odtRevisions(content *odfNode) ([]ParsedRevision, []ParsedComment) {
+	var revs []ParsedRevision
+	var comments []ParsedComment
+	var walk func(n *odfNode)
+	walk = func(n *odfNode) {
+		switch n.name {
+		case "changed-region":
+			for _, change := range n.children {
+				var kind string
+				switch change.name {
+				case "insertion":
+					kind = revisionInsertion
+				case "deletion":
+					kind = revisionDeletion
+				case "format-change":
+					kind = revisionFormatting
+				default:
+					continue
+				}
+				info := change.child("change-info")
+				rev := ParsedRevision{
+					Kind:           kind,
+					Author:         info.child("creator").textContent(),
+					Date:           info.child("date").textContent(),
+					ParagraphIndex: -1,
+					TableIndex:     -1,
+				}
+				if kind == revisionDeletion {
+					for _, c := range change.children {
+						if c.name == "p" || c.name == "h" {
+							rev.Text = strings.TrimSpace(rev.Text + " " + c.textContent())
+						}
+					}
+				}
+				revs = append(revs, rev)
+			}
+			return
+		case "annotation":
+			pc := ParsedComment{
+				ID:             n.attr("name"),
+				Author:         n.child("creator").textContent(),
+				Date:           n.child("date").textContent(),
+				Resolved:       n.attr("resolved") == "true",
+				ParagraphIndex: -1,
+			}
+			var texts []string
+			for _, c := range n.children {
+				if c.name == "p" {
+					if text := c.textContent(); text != "" {
+						texts = append(texts, text)
+					}
+				}
+			}
+			pc.Text = strings.Join(texts, " ")
+			comments = append(comments, pc)
+			return
+		}
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(content)
+	return revs, comments
+}
+
+// RevisionsConfig flags review leftovers that final versions must not have.
+type RevisionsConfig struct {
+	// ForbidTrackedChanges flags changes that were neither accepted nor rejected.
+	ForbidTrackedChanges bool `json:"forbid_tracked_changes"`
+	// ForbidComments flags reviewer comments; resolved comments left in the
+	// document are reported as a warning.
+	ForbidComments bool `json:"forbid_comments"`
+}
+
+// maxReportedComments bounds the comments reported one by one; the rest are
+// counted in one more violation.
+const maxReportedComments = 20
+
+// checkRevisions checks the document for tracked changes and comments.
+func checkRevisions(doc *ParsedDoc, cfg RevisionsConfig) ([]models.Violation, int) {
+	var vs []models.Violation
+	rules := 0
+	place := func(paragraph, table int) (string, *models.Location, string) {
+		switch {
+		case paragraph >= 0 && paragraph < len(doc.Paragraphs):
+			p := doc.Paragraphs[paragraph]
+			text := strings.TrimSpace(p.Text)
+			return fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, paragraph+1, truncate(text, 100)), paragraphLocation(paragraph, p), contextSnippet(p.Text)
+		case table >= 0 && table < len(doc.Tables):
+			return fmt.Sprintf("Page %d, Таблица %d", doc.Tables[table].PageNumber, table+1), nil, ""
+		}
+		return "Глобально", nil, ""
+	}
+
+	if cfg.ForbidTrackedChanges {
+		rules++
+		if len(doc.Revisions) > 0 {
+			counts := map[string]int{}
+			authors := map[string]bool{}
+			for _, rev := range doc.Revisions {
+				counts[rev.Kind]++
+				if rev.Author != "" {
+					authors[rev.Author] = true
+				}
+			}
+			var parts []string
+			for _, k := range []struct{ kind, label string }{
+				{revisionInsertion, "вставок"},
+				{revisionDeletion, "удалений"},
+				{revisionMove, "перемещений"},
+				{revisionFormatting, "изменений формата"},
+			} {
+				if counts[k.kind] > 0 {
+					parts = append(parts, fmt.Sprintf("%s: %d", k.label, counts[k.kind]))
+				}
+			}
+			actual := fmt.Sprintf("Исправлений: %d (%s)", len(doc.Revisions), strings.Join(parts, ", "))
+			if len(authors) > 0 {
+				names := make([]string, 0, len(authors))
+				for name := range authors {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				actual += "; авторы: " + strings.Join(names, ", ")
+			}
+			first := doc.Revisions[0]
+			position, location, context := place(first.ParagraphIndex, first.TableIndex)
+			vs = append(vs, models.Violation{
+				RuleType:      "tracked_changes",
+				Description:   "В документе остались непринятые исправления",
+				PositionInDoc: position,
+				ExpectedValue: "Исправления приняты или отклонены",
+				ActualValue:   actual,
+				Suggestion:    "Выберите «Рецензирование → Принять → Принять все исправления» и отключите запись исправлений",
+				Severity:      "error",
+				ContextText:   context,
+				Location:      location,
+			})
+		}
+	}
+
+	if cfg.ForbidComments {
+		rules++
+		reported, hidden, resolved := 0, 0, 0
+		for _, c := range doc.Comments {
+			if c.Resolved {
+				resolved++
+				continue
+			}
+			if reported == maxReportedComments {
+				hidden++
+				continue
+			}
+			reported++
+			position, location, context := place(c.ParagraphIndex, -1)
+			actual := truncate(c.Text, 200)
+			if c.Author != "" {
+				actual = c.Author + ": " + actual
+			}
+			vs = append(vs, models.Violation{
+				RuleType:      "review_comments",
+				Description:   "Примечание рецензента",
+				PositionInDoc: position,
+				ExpectedValue: "Без примечаний",
+				ActualValue:   actual,
+				Suggestion:    "Исправьте замечание и удалите примечание («Рецензирование → Удалить»)",
+				Severity:      "error",
+				ContextText:   context,
+				Location:      location,
+			})
+		}
+		if hidden > 0 {
+			vs = append(vs, models.Violation{
+				RuleType:      "review_comments",
+				Description:   "Примечания рецензента",
+				PositionInDoc: "Глобально",
+				ExpectedValue: "Без примечаний",
+				ActualValue:   fmt.Sprintf("Ещё примечаний: %d", hidden),
+				Suggestion:    "Исправьте замечания и удалите все примечания («Рецензирование → Удалить → Удалить все примечания в документе»)",
+				Severity:      "error",
+			})
+		}
+		if resolved > 0 {
+			vs = append(vs, models.Violation{
+				RuleType:      "review_comments",
+				Description:   "В документе остались решённые примечания",
+				PositionInDoc: "Глобально",
+				ExpectedValue: "Без примечаний",
+				ActualValue:   fmt.Sprintf("Решённых примечаний: %d", resolved),
+				Suggestion:    "Удалите все примечания («Рецензирование → Удалить → Удалить все примечания в документе»)",
+				Severity:      "warning",
+			})
+		}
+	}
+	return vs, rules
+}
@@ -0,0 +1,146 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"math"
+	"strings"
+)
+
+// Where sections turned to the other orientation are allowed, in the
+// vocabulary of PageSetupConfig.OtherOrientation.
+const (
+	otherOrientationAnywhere   = ""
+	otherOrientationAppendices = "appendices"
+	otherOrientationForbidden  = "forbidden"
+)
+
+var orientationNames = map[string]string{
+	"portrait":  "книжная",
+	"landscape": "альбомная",
+}
+
+// checkSectionPages checks the page setup of a document with several
+// sections. The main section (see mainSection) is checked against the
+// margins like a single-section document; other sections only when their
+// margins differ from it. A section turned to the other orientation is
+// checked against the margins rotated with the page, either way, and
+// against setup.OtherOrientation.
+func checkSectionPages(doc *ParsedDoc, margins MarginsConfig, setup PageSetupConfig) ([]models.Violation, int) {
+	var violations []models.Violation
+	totalRules := 0
+	mainIdx := mainSection(doc.Sections)
+	main := doc.Sections[mainIdx]
+	orientation := setup.Orientation
+	if orientation == "" {
+		orientation = main.Orientation
+	}
+	appendix := appendixStart(doc.Paragraphs)
+
+	for i, s := range doc.Sections {
+		// A section of a single table has no text paragraph: the one
+		// closing it marks its place
+		first := firstTextParagraph(doc.Paragraphs, s)
+		if first < 0 {
+			first = s.ParagraphStart
+		}
+		at := func(vs []models.Violation) []models.Violation {
+			if first >= len(doc.Paragraphs) {
+				return vs
+			}
+			p := doc.Paragraphs[first]
+			for j := range vs {
+				vs[j].PositionInDoc = fmt.Sprintf("Page %d, Para %d: %s", p.PageNumber, first+1, truncate(strings.TrimSpace(p.Text), 100))
+				vs[j].Location = paragraphLocation(first, p)
+			}
+			return vs
+		}
+
+		rotated := s.Orientation != main.Orientation
+		if i == mainIdx || (s.Margins != (Margins{}) && (rotated || !sameMargins(s.Margins, main.Margins))) {
+			vs := checkMargins(s.Margins, margins)
+			if rotated {
+				vs = checkRotatedMargins(s.Margins, margins)
+			}
+			totalRules += configuredMargins(margins)
+			violations = append(violations, at(vs)...)
+		}
+
+		if i == mainIdx || s.Orientation == orientation || setup.OtherOrientation == otherOrientationAnywhere {
+			continue
+		}
+		totalRules++
+		if setup.OtherOrientation == otherOrientationAppendices && appendix >= 0 && s.ParagraphStart >= appendix {
+			continue
+		}
+		expected := "только " + orientationNames[orientation]
+		suggestion := "Верните разделу ориентацию основного текста"
+		if setup.OtherOrientation == otherOrientationAppendices {
+			expected = orientationNames[orientation] + " вне приложений"
+			suggestion = "Другая ориентация допускается только в приложениях: перенесите таблицу или рисунок в приложение"
+		}
+		violations = append(violations, at([]models.Violation{{
+			RuleType:      "section_orientation",
+			Description:   "Раздел с другой ориентацией страниц",
+			ExpectedValue: expected,
+			ActualValue:   orientationNames[s.Orientation],
+			Suggestion:    suggestion,
+			Severity:      "error",
+		}})...)
+	}
+	return violations, totalRules
+}
+
+// checkRotatedMargins checks the margins of a page turned to the other
+// orientation. Word moves the margins with the page, clockwise or not, so the
+// turn giving fewer violations is taken.
+func checkRotatedMargins(actual Margins, target MarginsConfig) []models.Violation {
+	clockwise := MarginsConfig{Top: target.Left, Right: target.Top, Bottom: target.Right, Left: target.Bottom, Tolerance: target.Tolerance}
+	counter := MarginsConfig{Top: target.Right, Right: target.Bottom, Bottom: target.Left, Left: target.Top, Tolerance: target.Tolerance}
+	vs := checkMargins(actual, clockwise)
+	if other := checkMargins(actual, counter); len(other) < len(vs) {
+		vs = other
+	}
+	return vs
+}
+
+// configuredMargins counts the margins the standard sets.
+func configuredMargins(target MarginsConfig) int {
+	n := 0
+	for _, v := range []float64{target.Top, target.Bottom, target.Left, target.Right} {
+		if v > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func sameMargins(a, b Margins) bool {
+	return math.Abs(a.TopMm-b.TopMm) < 0.5 && math.Abs(a.BottomMm-b.BottomMm) < 0.5 &&
+		math.Abs(a.LeftMm-b.LeftMm) < 0.5 && math.Abs(a.RightMm-b.RightMm) < 0.5
+}
+
+// appendixStart returns the index of the first appendix heading outside the
+// table of contents, or -1.
+func appendixStart(paragraphs []ParsedParagraph) int {
+	for i, p := range paragraphs {
+		if p.Role == "toc" || !isHeadingParagraph(p) {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(p.Text)), "приложение") {
+			return i
+		}
+	}
+	return -1
+}
+
+// firstTextParagraph returns the first paragraph of the section with text, or
+// -1 for a section holding none.
+func firstTextParagraph(paragraphs []ParsedParagraph, s ParsedSection) int {
+	for i := s.ParagraphStart; i < s.ParagraphEnd && i < len(paragraphs); i++ {
+		if strings.TrimSpace(paragraphs[i].Text) != "" {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,296 @@
+package checker
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// resolvedStyle is the effective formatting of a paragraph style after the
+// document defaults and the basedOn chain have been applied.
+type resolvedStyle struct {
+	Name              string // display name of the style itself, not inherited
+	OutlineLevel      int    // 1-9; 0 = body text
+	FontName          string
+	FontSizePt        float64
+	LineSpacing       float64
+	Alignment         string
+	FirstLineIndentMm float64
+	Bold              bool
+	Italic            bool
+	Underline         bool
+	AllCaps           bool
+
+	// Pagination; widow control is on unless a style turns it off, as in Word
+	KeepNext     bool
+	KeepLines    bool
+	WidowControl bool
+
+	SuppressAutoHyphens bool
+
+	// List numbering of list styles such as "List Bullet"; NumID is empty
+	// when the style does not number its paragraphs.
+	NumID   string
+	NumIlvl int
+}
+
+// styleSheet resolves the formatting a paragraph inherits from word/styles.xml:
+// the document defaults (w:docDefaults), the default paragraph style (usually
+// "Normal") and the basedOn chain of the paragraph's own style. Theme font
+// references (w:asciiTheme) are resolved through the document theme.
+type styleSheet struct {
+	styles           map[string]Style
+	defaultParagraph string
+	themeFonts       map[string]string // "major"/"minor" -> Latin typeface
+	defaults         resolvedStyle
+	resolved         map[string]resolvedStyle
+}
+
+func newStyleSheet(doc StylesDoc, theme ThemeDoc) *styleSheet {
+	s := &styleSheet{
+		styles:   make(map[string]Style, len(doc.Styles)),
+		resolved: make(map[string]resolvedStyle),
+		defaults: resolvedStyle{WidowControl: true},
+		themeFonts: map[string]string{
+			"major": theme.Elements.FontScheme.Major.typeface(),
+			"minor": theme.Elements.FontScheme.Minor.typeface(),
+		},
+	}
+	for _, style := range doc.Styles {
+		if style.StyleID == "" {
+			continue
+		}
+		s.styles[style.StyleID] = style
+		if style.Type == "paragraph" && (style.Default == "1" || style.Default == "true") && s.defaultParagraph == "" {
+			s.defaultParagraph = style.StyleID
+		}
+	}
+	if d := doc.DocDefaults; d != nil {
+		if d.PPrDefault != nil {
+			s.overlayParagraph(&s.defaults, d.PPrDefault.PPr)
+		}
+		if d.RPrDefault != nil {
+			s.overlayRun(&s.defaults, d.RPrDefault.RPr)
+		}
+	}
+	return s
+}
+
+// parseStyleSheet loads word/styles.xml and the document theme: the part the
+// theme relationship of word/document.xml points to, word/theme/theme1.xml
+// without one. A missing or broken part leaves the corresponding formatting
+// unresolved.
+func (p *DocParser) parseStyleSheet(r *zip.Reader) *styleSheet {
+	files := make(map[string]*zip.File, len(r.File))
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+
+	var styles StylesDoc
+	if f := files["word/styles.xml"]; f != nil {
+		if data, err := readEntryLimited(f, p.Limits, 0); err == nil {
+			_ = xml.Unmarshal(data, &styles)
+		}
+	}
+
+	themePart := "word/theme/theme1.xml"
+	if f := files["word/_rels/document.xml.rels"]; f != nil {
+		if data, err := readEntryLimited(f, p.Limits, 0); err == nil {
+			var rels Relationships
+			if xml.Unmarshal(data, &rels) == nil {
+				for _, rel := range rels.Rels {
+					if strings.HasSuffix(rel.Type, "/theme") {
+						themePart = packagePartName(rel.Target)
+						break
+					}
+				}
+			}
+		}
+	}
+	var theme ThemeDoc
+	if f := files[themePart]; f != nil {
+		if data, err := readEntryLimited(f, p.Limits, 0); err == nil {
+			_ = xml.Unmarshal(data, &theme)
+		}
+	}
+	return newStyleSheet(styles, theme)
+}
+
+// resolve returns the effective formatting of styleID. An empty or unknown
+// style resolves to the default paragraph style, as in Word.
+func (s *styleSheet) resolve(styleID string) resolvedStyle {
+	if s == nil {
+		return resolvedStyle{WidowControl: true}
+	}
+	if _, ok := s.styles[styleID]; !ok {
+		styleID = s.defaultParagraph
+	}
+	return s.resolveChain(styleID, map[string]bool{})
+}
+
+func (s *styleSheet) resolveChain(styleID string, seen map[string]bool) resolvedStyle {
+	if rs, ok := s.resolved[styleID]; ok {
+		return rs
+	}
+	style, ok := s.styles[styleID]
+	if !ok || seen[styleID] {
+		return s.defaults
+	}
+	seen[styleID] = true
+
+	rs := s.defaults
+	if style.BasedOn != nil && style.BasedOn.Val != "" {
+		rs = s.resolveChain(style.BasedOn.Val, seen)
+	}
+	s.overlayParagraph(&rs, style.PPr)
+	s.overlayRun(&rs, style.RPr)
+	rs.Name = ""
+	if style.Name != nil {
+		rs.Name = style.Name.Val
+	}
+
+	s.resolved[styleID] = rs
+	return rs
+}
+
+func (s *styleSheet) overlayParagraph(rs *resolvedStyle, ppr *PPr) {
+	if ppr == nil {
+		return
+	}
+	if ppr.Jc != nil && ppr.Jc.Val != "" {
+		rs.Alignment = ppr.Jc.Val
+	}
+	if ppr.Ind != nil && ppr.Ind.FirstLine != "" {
+		rs.FirstLineIndentMm = twipsToMm(ppr.Ind.FirstLine)
+	}
+	if ppr.NumPr != nil {
+		if ppr.NumPr.NumId != nil {
+			rs.NumID = ppr.NumPr.NumId.Val
+		}
+		if ppr.NumPr.Ilvl != nil {
+			rs.NumIlvl, _ = strconv.Atoi(ppr.NumPr.Ilvl.Val)
+		}
+	}
+	if ppr.Spacing != nil && ppr.Spacing.Line != "" {
+		if val, err := strconv.Atoi(ppr.Spacing.Line); err == nil {
+			rs.LineSpacing = float64(val) / 240.0
+		}
+	}
+	if ppr.OutlineLvl != nil {
+		rs.OutlineLevel = outlineLevel(ppr.OutlineLvl.Val)
+	}
+	if ppr.KeepNext != nil {
+		rs.KeepNext = onOffEnabled(ppr.KeepNext)
+	}
+	if ppr.KeepLines != nil {
+		rs.KeepLines = onOffEnabled(ppr.KeepLines)
+	}
+	if ppr.WidowControl != nil {
+		rs.WidowControl = onOffEnabled(&OnOff{Val: ppr.WidowControl.Val})
+	}
+	if ppr.SuppressAutoHyphens != nil {
+		rs.SuppressAutoHyphens = onOffEnabled(ppr.SuppressAutoHyphens)
+	}
+}
+
+// outlineLevel converts w:outlineLvl (0-8 for headings, 9 for body text) to
+// a heading level 1-9, or 0 for body text.
+func outlineLevel(val string) int {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 || n > 8 {
+		return 0
+	}
+	return n + 1
+}
+
+func (s *styleSheet) overlayRun(rs *resolvedStyle, rpr *RPr) {
+	if rpr == nil {
+		return
+	}
+	if name := s.fontName(rpr.RFonts); name != "" {
+		rs.FontName = name
+	}
+	if rpr.Sz != nil && rpr.Sz.Val != "" {
+		if val, err := strconv.Atoi(rpr.Sz.Val); err == nil {
+			rs.FontSizePt = float64(val) / 2.0
+		}
+	}
+	if rpr.B != nil {
+		rs.Bold = onOffEnabled(rpr.B)
+	}
+	if rpr.I != nil {
+		rs.Italic = onOffEnabled(rpr.I)
+	}
+	if rpr.U != nil {
+		rs.Underline = rpr.U.Val != "none"
+	}
+	if rpr.Caps != nil {
+		rs.AllCaps = onOffEnabled(rpr.Caps)
+	}
+}
+
+// fontName returns the Latin font of rFonts, resolving theme references such
+// as w:asciiTheme="minorHAnsi". As in Word, a theme reference takes precedence
+// over the font named in the same slot (w:asciiTheme over w:ascii, w:hAnsiTheme
+// over w:hAnsi), which is kept only as a fallback. It returns "" when no font
+// is set.
+func (s *styleSheet) fontName(rf *RFonts) string {
+	if rf == nil {
+		return ""
+	}
+	if name := s.themeFont(rf.AsciiTheme); name != "" {
+		return name
+	}
+	if rf.Ascii != "" {
+		return rf.Ascii
+	}
+	if name := s.themeFont(rf.HAnsiTheme); name != "" {
+		return name
+	}
+	return rf.HAnsi
+}
+
+// themeFont returns the typeface of a theme font reference such as
+// "minorHAnsi" or "majorBidi", or "" when it is unknown.
+func (s *styleSheet) themeFont(ref string) string {
+	if s == nil {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(ref, "major"):
+		return s.themeFonts["major"]
+	case strings.HasPrefix(ref, "minor"):
+		return s.themeFonts["minor"]
+	}
+	return ""
+}
+
+// apply sets the effective formatting of pp: its paragraph style, overridden
+// by the direct formatting of the first run (rpr, may be nil). Paragraph
+// properties set directly on pp are kept.
+func (s *styleSheet) apply(pp *ParsedParagraph, rpr *RPr) {
+	rs := s.resolve(pp.StyleID)
+	s.overlayRun(&rs, rpr)
+	if pp.Alignment == "" {
+		pp.Alignment = rs.Alignment
+	}
+	if pp.FirstLineIndentMm == 0 {
+		pp.FirstLineIndentMm = rs.FirstLineIndentMm
+	}
+	if pp.LineSpacing == 0 {
+		pp.LineSpacing = rs.LineSpacing
+	}
+	pp.StyleName = rs.Name
+	pp.OutlineLevel = rs.OutlineLevel
+	pp.FontName = rs.FontName
+	pp.FontSizePt = rs.FontSizePt
+	pp.IsBold = rs.Bold
+	pp.IsItalic = rs.Italic
+	pp.IsUnderline = rs.Underline
+	pp.IsAllCaps = rs.AllCaps
+	pp.KeepNext = rs.KeepNext
+	pp.KeepLines = rs.KeepLines
+	pp.WidowControl = rs.WidowControl
+	pp.SuppressAutoHyphens = rs.SuppressAutoHyphens
+}
@@ -0,0 +1,203 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSummaryItems is how many problem groups the summary names.
+const maxSummaryItems = 5
+
+// summaryPhrase renders one problem group of the summary from its violation count.
+type summaryPhrase func(n int) string
+
+func fixedPhrase(text string) summaryPhrase {
+	return func(int) string { return text }
+}
+
+func countedPhrase(format string, one, few, many string) summaryPhrase {
+	return func(n int) string { return fmt.Sprintf(format, n, pluralRu(n, one, few, many)) }
+}
+
+// summaryPhrases names the rule types the summary knows how to describe. Other
+// rule types fall back to their violation description.
+var summaryPhrases = map[string]summaryPhrase{
+	"margin_left":   fixedPhrase("поле слева"),
+	"margin_right":  fixedPhrase("поле справа"),
+	"margin_top":    fixedPhrase("поле сверху"),
+	"margin_bottom": fixedPhrase("поле снизу"),
+	"header_dist":   fixedPhrase("расстояние до верхнего колонтитула"),
+	"footer_dist":   fixedPhrase("расстояние до нижнего колонтитула"),
+
+	"page_numbering":       fixedPhrase("нет номеров страниц"),
+	"header_title_page":    fixedPhrase("колонтитул на титульном листе"),
+	"header_running_title": countedPhrase("колонтитулы не совпадают с названием раздела (%d %s)", "раздел", "раздела", "разделов"),
+
+	"font_name":    countedPhrase("шрифт основного текста в %d %s", "абзаце", "абзацах", "абзацах"),
+	"font_size":    countedPhrase("размер шрифта в %d %s", "абзаце", "абзацах", "абзацах"),
+	"line_spacing": countedPhrase("междустрочный интервал в %d %s", "абзаце", "абзацах", "абзацах"),
+	"indent":       countedPhrase("абзацный отступ в %d %s", "абзаце", "абзацах", "абзацах"),
+	"alignment":    countedPhrase("выравнивание в %d %s", "абзаце", "абзацах", "абзацах"),
+
+	"table_caption_missing":          countedPhrase("отсутствуют подписи у %d %s", "таблицы", "таблиц", "таблиц"),
+	"image_caption_missing":          countedPhrase("отсутствуют подписи у %d %s", "рисунка", "рисунков", "рисунков"),
+	"formula_numbering_missing":      countedPhrase("не пронумерованы %d %s", "формула", "формулы", "формул"),
+	"table_not_referenced":           countedPhrase("нет ссылок в тексте на %d %s", "таблицу", "таблицы", "таблиц"),
+	"image_not_referenced":           countedPhrase("нет ссылок в тексте на %d %s", "рисунок", "рисунка", "рисунков"),
+	"formula_not_referenced":         countedPhrase("нет ссылок в тексте на %d %s", "формулу", "формулы", "формул"),
+	"table_text_reference_missing":   countedPhrase("%d %s на несуществующие таблицы", "ссылка", "ссылки", "ссылок"),
+	"image_text_reference_missing":   countedPhrase("%d %s на несуществующие рисунки", "ссылка", "ссылки", "ссылок"),
+	"formula_text_reference_missing": countedPhrase("%d %s на несуществующие формулы", "ссылка", "ссылки", "ссылок"),
+	"reference_age":                  countedPhrase("%d %s старше допустимого срока", "источник", "источника", "источников"),
+	"references_missing":             fixedPhrase("нет списка литературы"),
+	"vocabulary":                     countedPhrase("запрещённые слова (%d %s)", "случай", "случая", "случаев"),
+	"duplicate_paragraph":            countedPhrase("%d %s повторяют другие дословно", "абзац", "абзаца", "абзацев"),
+	"repeated_block":                 countedPhrase("повторяющиеся фрагменты текста (%d %s)", "случай", "случая", "случаев"),
+
+	"reference_numbering":          countedPhrase("нумерация списка литературы (%d %s)", "источник", "источника", "источников"),
+	"reference_author_format":      countedPhrase("запись автора в %d %s", "источнике", "источниках", "источниках"),
+	"reference_title_punctuation":  countedPhrase("не указан вид содержания в %d %s", "источнике", "источниках", "источниках"),
+	"reference_imprint":            countedPhrase("нет выходных данных у %d %s", "источника", "источников", "источников"),
+	"reference_url_access_date":    countedPhrase("неполное описание %d %s", "электронного ресурса", "электронных ресурсов", "электронных ресурсов"),
+	"reference_citation_undefined": countedPhrase("ссылки на отсутствующие источники (%d %s)", "случай", "случая", "случаев"),
+	"reference_not_cited":          countedPhrase("нет ссылок в тексте на %d %s", "источник", "источника", "источников"),
+
+	"typography_dash":                     countedPhrase("дефис вместо тире (%d %s)", "случай", "случая", "случаев"),
+	"typography_quotes":                   countedPhrase("прямые кавычки (%d %s)", "случай", "случая", "случаев"),
+	"typography_double_space":             countedPhrase("лишние пробелы (%d %s)", "случай", "случая", "случаев"),
+	"typography_space_before_punctuation": countedPhrase("пробелы перед знаками препинания (%d %s)", "случай", "случая", "случаев"),
+	"typography_nbsp":                     countedPhrase("нет неразрывного пробела (%d %s)", "случай", "случая", "случаев"),
+
+	"unit_spacing":      countedPhrase("число слитно с единицей измерения (%d %s)", "случай", "случая", "случаев"),
+	"unit_name":         countedPhrase("обозначения единиц измерения (%d %s)", "случай", "случая", "случаев"),
+	"decimal_separator": countedPhrase("десятичная точка вместо запятой (%d %s)", "случай", "случая", "случаев"),
+	"number_range_dash": countedPhrase("дефис в диапазонах чисел (%d %s)", "случай", "случая", "случаев"),
+
+	"list_marker":        countedPhrase("тип маркера (%d %s)", "список", "списка", "списков"),
+	"list_number_format": countedPhrase("формат номера (%d %s)", "список", "списка", "списков"),
+	"list_punctuation":   countedPhrase("знаки в конце элементов списков (%d %s)", "элемент", "элемента", "элементов"),
+	"list_case":          countedPhrase("регистр элементов списков (%d %s)", "элемент", "элемента", "элементов"),
+	"list_indent":        countedPhrase("отступы элементов списков (%d %s)", "элемент", "элемента", "элементов"),
+	"list_depth":         countedPhrase("вложенность списков (%d %s)", "элемент", "элемента", "элементов"),
+
+	"footnote_font_size": countedPhrase("размер шрифта сносок (%d %s)", "сноска", "сноски", "сносок"),
+	"footnote_numbering": fixedPhrase("нумерация сносок"),
+	"endnotes":           fixedPhrase("концевые сноски"),
+	"footnotes_per_page": countedPhrase("много сносок на %d %s", "странице", "страницах", "страницах"),
+
+	"toc_page_mismatch":   countedPhrase("номера страниц в оглавлении (%d %s)", "расхождение", "расхождения", "расхождений"),
+	"structure_break":     countedPhrase("%d %s 1 уровня не с новой страницы", "заголовок", "заголовка", "заголовков"),
+	"structure_hierarchy": fixedPhrase("пропущены уровни заголовков"),
+	"section_order":       fixedPhrase("порядок разделов"),
+	"section_missing":     fixedPhrase("отсутствуют обязательные разделы"),
+	"doc_length":          fixedPhrase("объём работы"),
+	"intro_length":        fixedPhrase("объём введения"),
+}
+
+type summaryGroup struct {
+	ruleType    string
+	description string
+	count       int
+	weight      float64
+}
+
+// Summarize turns the violations of a check into a short conclusion such as
+// "Основные проблемы: поле слева, отсутствуют подписи у 3 таблиц, ...".
+// Non-blocking observations are not mentioned.
+func Summarize(violations []models.Violation) string {
+	groups := map[string]*summaryGroup{}
+	var order []string
+	for _, v := range violations {
+		if !models.IsBlocking(v.Severity) {
+			continue
+		}
+		g, ok := groups[v.RuleType]
+		if !ok {
+			g = &summaryGroup{ruleType: v.RuleType, description: v.Description}
+			groups[v.RuleType] = g
+			order = append(order, v.RuleType)
+		}
+		g.count++
+		g.weight += severityWeight(v.Severity)
+	}
+	if len(groups) == 0 {
+		return "Существенных нарушений оформления не найдено."
+	}
+
+	sorted := make([]*summaryGroup, 0, len(order))
+	for _, ruleType := range order {
+		sorted = append(sorted, groups[ruleType])
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].weight > sorted[j].weight })
+
+	items := make([]string, 0, maxSummaryItems)
+	for _, g := range sorted {
+		if len(items) == maxSummaryItems {
+			break
+		}
+		items = append(items, g.phrase())
+	}
+
+	summary := "Основные проблемы: " + strings.Join(items, ", ")
+	if rest := len(sorted) - len(items); rest > 0 {
+		summary += fmt.Sprintf(" и ещё %d %s", rest, pluralRu(rest, "тип нарушений", "типа нарушений", "типов нарушений"))
+	}
+	return summary + "."
+}
+
+func (g *summaryGroup) phrase() string {
+	if phrase, ok := summaryPhrases[g.ruleType]; ok {
+		return phrase(g.count)
+	}
+	text := lowerFirst(strings.TrimSpace(g.description))
+	if text == "" {
+		text = g.ruleType
+	}
+	if g.count > 1 {
+		text += fmt.Sprintf(" (%d)", g.count)
+	}
+	return text
+}
+
+func severityWeight(severity string) float64 {
+	switch severity {
+	case models.SeverityCritical:
+		return 4
+	case models.SeverityError:
+		return 2
+	case models.SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// pluralRu picks the Russian plural form for n: 1 таблица, 2 таблицы, 5 таблиц.
+func pluralRu(n int, one, few, many string) string {
+	n %= 100
+	if n >= 11 && n <= 14 {
+		return many
+	}
+	switch n % 10 {
+	case 1:
+		return one
+	case 2, 3, 4:
+		return few
+	}
+	return many
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	// Keep abbreviations such as "ВСЕ ЗАГЛАВНЫЕ" intact.
+	if next, _ := utf8.DecodeRuneInString(s[size:]); unicode.IsUpper(next) {
+		return s
+	}
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -0,0 +1,114 @@
+package checker
+
+import (
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+)
+
+// maxTraceEvidence is the number of violations quoted per rule group.
+const maxTraceEvidence = 5
+
+// ruleTrace records how each rule group of a check was evaluated. It follows
+// the ruleClock: entering a group closes the previous one and attributes the
+// rules and violations added meanwhile to it. Checks add what they read from
+// the document, the settings they applied and why they were skipped.
+type ruleTrace struct {
+	violations *[]models.Violation
+	totalRules *int
+
+	groups  map[string]*models.RuleTrace
+	order   []string
+	current string
+	startV  int
+	startR  int
+}
+
+func newRuleTrace(violations *[]models.Violation, totalRules *int) *ruleTrace {
+	return &ruleTrace{violations: violations, totalRules: totalRules, groups: make(map[string]*models.RuleTrace)}
+}
+
+func (t *ruleTrace) group(rule string) *models.RuleTrace {
+	g, ok := t.groups[rule]
+	if !ok {
+		g = &models.RuleTrace{Rule: rule}
+		t.groups[rule] = g
+		t.order = append(t.order, rule)
+	}
+	return g
+}
+
+// enter closes the running group and starts rule; "" only closes.
+func (t *ruleTrace) enter(rule string) {
+	if t.current != "" {
+		g := t.group(t.current)
+		g.Rules += *t.totalRules - t.startR
+		vs := *t.violations
+		if t.startV > len(vs) {
+			t.startV = len(vs)
+		}
+		for _, v := range vs[t.startV:] {
+			g.Violations++
+			if g.Failed == nil {
+				g.Failed = make(map[string]int)
+			}
+			g.Failed[v.RuleType]++
+			if len(g.Evidence) < maxTraceEvidence {
+				g.Evidence = append(g.Evidence, models.RuleEvidence{
+					RuleType: v.RuleType,
+					Position: v.PositionInDoc,
+					Expected: v.ExpectedValue,
+					Actual:   v.ActualValue,
+				})
+			}
+		}
+	}
+	t.current = rule
+	t.startV, t.startR = len(*t.violations), *t.totalRules
+}
+
+// read records a document value the rules of group were evaluated on.
+func (t *ruleTrace) read(group, key string, value interface{}) {
+	g := t.group(group)
+	if g.Read == nil {
+		g.Read = make(map[string]interface{})
+	}
+	g.Read[key] = value
+}
+
+// applied records a setting of the standard used by group.
+func (t *ruleTrace) applied(group, key string, value interface{}) {
+	g := t.group(group)
+	if g.Thresholds == nil {
+		g.Thresholds = make(map[string]interface{})
+	}
+	g.Thresholds[key] = value
+}
+
+// skip records why group did not run, e.g. missing data in the document.
+func (t *ruleTrace) skip(group, reason string) {
+	t.group(group).Reason = reason
+}
+
+// Stop closes the running group and returns the groups in the order they were
+// first entered. A group failed when it produced violations; it passed when it
+// counted rules or read the document without finding any; otherwise it was
+// skipped because the standard does not enable it.
+func (t *ruleTrace) Stop() []models.RuleTrace {
+	t.enter("")
+	out := make([]models.RuleTrace, 0, len(t.order))
+	for _, rule := range t.order {
+		g := t.groups[rule]
+		switch {
+		case g.Violations > 0:
+			g.Status = models.TraceFailed
+		case g.Reason != "":
+			g.Status = models.TraceSkipped
+		case g.Rules > 0 || len(g.Read) > 0:
+			g.Status = models.TracePassed
+		default:
+			g.Status = models.TraceSkipped
+			g.Reason = "Правила группы не включены в стандарте"
+		}
+		out = append(out, *g)
+	}
+	return out
+}
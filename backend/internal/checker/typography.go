@@ -0,0 +1,206 @@
+package checker
+
+import (
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TypographyRulesConfig enables the punctuation, spacing and hyphenation
+// rules of Russian typography.
+type TypographyRulesConfig struct {
+	CheckDashes                 bool `json:"check_dashes"`                   // " - " instead of a dash
+	CheckQuotes                 bool `json:"check_quotes"`                   // "..." instead of «...»
+	CheckDoubleSpaces           bool `json:"check_double_spaces"`            // two or more spaces in a row
+	CheckSpaceBeforePunctuation bool `json:"check_space_before_punctuation"` // "слово ,"
+	CheckNonBreakingSpaces      bool `json:"check_non_breaking_spaces"`      // before units, in initials
+	CheckHangingPrepositions    bool `json:"check_hanging_prepositions"`     // "в доме" that may break after "в"
+
+	ForbidAutoHyphenation    bool `json:"forbid_auto_hyphenation"`    // automatic hyphenation of the document
+	ForbidHeadingHyphenation bool `json:"forbid_heading_hyphenation"` // hyphens in headings
+}
+
+// maxTypographyMatches bounds the violations of one rule in one paragraph;
+// a paragraph typed without any typography would otherwise flood the report.
+const maxTypographyMatches = 10
+
+// typographyContextRunes is how much text around a match goes into the
+// violation's context.
+const typographyContextRunes = 30
+
+// typographyRule is one pattern; its first group marks the offending
+// characters.
+type typographyRule struct {
+	ruleType    string
+	description string
+	expected    string
+	suggestion  string
+	enabled     func(TypographyRulesConfig) bool
+	re          *regexp.Regexp
+	accept      func(text string, matchStart, end int) bool // nil accepts every match
+}
+
+var typographyRules = []typographyRule{
+	{
+		ruleType:    "typography_dash",
+		description: "Дефис вместо тире",
+		expected:    "Тире «–» или «—» с пробелами",
+		suggestion:  "Замените дефис на тире (Ctrl + минус на цифровой клавиатуре)",
+		enabled:     func(c TypographyRulesConfig) bool { return c.CheckDashes },
+		re:          regexp.MustCompile(`\S( -{1,2} )\S`),
+	},
+	{
+		ruleType:    "typography_quotes",
+		description: "Прямые кавычки вместо «ёлочек»",
+		expected:    "«…»",
+		suggestion:  "Используйте кавычки-«ёлочки»; для кавычек внутри кавычек — „лапки“",
+		enabled:     func(c TypographyRulesConfig) bool { return c.CheckQuotes },
+		re:          regexp.MustCompile(`("[^"]{0,300}"|")`),
+	},
+	{
+		ruleType:    "typography_double_space",
+		description: "Несколько пробелов подряд",
+		expected:    "Один пробел",
+		suggestion:  "Оставьте один пробел; для отступов используйте абзацный отступ или табуляцию",
+		enabled:     func(c TypographyRulesConfig) bool { return c.CheckDoubleSpaces },
+		re:          regexp.MustCompile(`\S( {2,})\S`),
+	},
+	{
+		ruleType:    "typography_space_before_punctuation",
+		description: "Пробел перед знаком препинания",
+		expected:    "Знак препинания сразу после слова",
+		suggestion:  "Уберите пробел перед знаком препинания",
+		enabled:     func(c TypographyRulesConfig) bool { return c.CheckSpaceBeforePunctuation },
+		re:          regexp.MustCompile(`\S( +)[,.;:!?](?:\s|$)`),
+	},
+	{
+		ruleType:    "typography_nbsp",
+		description: "Обычный пробел между числом и единицей измерения",
+		expected:    "Неразрывный пробел",
+		suggestion:  "Поставьте неразрывный пробел (Ctrl + Shift + Пробел), чтобы число и единица не разрывались переносом строки",
+		enabled:     func(c TypographyRulesConfig) bool { return c.CheckNonBreakingSpaces },
+		re:          regexp.MustCompile(`\d( )(?:мм|см|км|кг|мг|мл|мин|сут|кВт|Вт|кГц|МГц|ГГц|Гц|кПа|МПа|Па|кДж|Дж|Ом|бит|Кбайт|Мбайт|Гбайт|байт|КБ|МБ|ГБ|ТБ|млн|млрд|руб\.|тыс\.|шт\.|гг\.|г\.|м|г|ч)(?:[^\p{L}]|$)`),
+	},
+	{
+		ruleType:    "typography_nbsp",
+		description: "Обычный пробел в инициалах",
+		expected:    "Неразрывный пробел",
+		suggestion:  "Поставьте неразрывный пробел (Ctrl + Shift + Пробел) после инициала, чтобы инициалы не отрывались от фамилии",
+		enabled:     func(c TypographyRulesConfig) bool { return c.CheckNonBreakingSpaces },
+		re:          regexp.MustCompile(`\p{Lu}\.( )\p{Lu}`),
+		accept: func(text string, matchStart, _ int) bool {
+			// The initial is a word of its own, not the end of "ТАБЛ." or "Рис."
+			r, _ := utf8.DecodeLastRuneInString(text[:matchStart])
+			return matchStart == 0 || !unicode.IsLetter(r)
+		},
+	},
+	{
+		ruleType:    "typography_nbsp",
+		description: "Обычный пробел между фамилией и инициалами",
+		expected:    "Неразрывный пробел",
+		suggestion:  "Поставьте неразрывный пробел (Ctrl + Shift + Пробел) между фамилией и инициалами",
+		enabled:     func(c TypographyRulesConfig) bool { return c.CheckNonBreakingSpaces },
+		re:          regexp.MustCompile(`\p{Lu}\p{Ll}+( )\p{Lu}\.\s?\p{Lu}\.`),
+	},
+	{
+		ruleType:    "typography_hanging_preposition",
+		description: "Обычный пробел после короткого предлога или союза",
+		expected:    "Неразрывный пробел",
+		suggestion:  "Поставьте неразрывный пробел (Ctrl + Shift + Пробел) после предлога, чтобы он не остался в конце строки",
+		enabled:     func(c TypographyRulesConfig) bool { return c.CheckHangingPrepositions },
+		re:          regexp.MustCompile(`(?i)(?:а|в|во|и|к|ко|на|о|об|с|со|у)( )[\p{L}\p{N}«(]`),
+		accept: func(text string, matchStart, _ int) bool {
+			// A word of its own, not the end of "она" or "все"
+			r, _ := utf8.DecodeLastRuneInString(text[:matchStart])
+			return matchStart == 0 || !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		},
+	},
+}
+
+// checkTypographyRules reports every match of the enabled typography rules
+// in the text of one paragraph. Each violation points at the offending
+// characters within loc.
+func checkTypographyRules(text string, cfg TypographyRulesConfig, pos string, loc *models.Location) ([]models.Violation, int) {
+	var vs []models.Violation
+	rules := 0
+	for _, rule := range typographyRules {
+		if !rule.enabled(cfg) {
+			continue
+		}
+		rules++
+		// The initials rule needs overlapping matches ("И. И. Иванов"), so
+		// the search resumes right after each marked group.
+		found := 0
+		for offset := 0; offset < len(text) && found < maxTypographyMatches; {
+			m := rule.re.FindStringSubmatchIndex(text[offset:])
+			if m == nil {
+				break
+			}
+			matchStart, start, end := offset+m[0], offset+m[2], offset+m[3]
+			offset = end
+			if rule.accept != nil && !rule.accept(text, matchStart, end) {
+				continue
+			}
+			found++
+			vs = append(vs, textViolation(text, start, end, pos, loc, models.Violation{
+				RuleType:      rule.ruleType,
+				Description:   rule.description,
+				ExpectedValue: rule.expected,
+				ActualValue:   visibleSpaces(text[start:end]),
+				Suggestion:    rule.suggestion,
+				Severity:      "warning",
+			}))
+		}
+	}
+	return vs, rules
+}
+
+// textViolation places v at text[start:end] of the paragraph: the position,
+// the surrounding text and the character range within loc.
+func textViolation(text string, start, end int, pos string, loc *models.Location, v models.Violation) models.Violation {
+	v.PositionInDoc = pos
+	v.ContextText = matchContext(text, start, end)
+	if loc != nil {
+		matchLoc := *loc
+		matchLoc.CharStart = intPtr(utf8.RuneCountInString(text[:start]))
+		matchLoc.CharEnd = intPtr(utf8.RuneCountInString(text[:end]))
+		v.Location = &matchLoc
+	}
+	return v
+}
+
+// matchContext returns the text around text[start:end], marking cut ends
+// with an ellipsis.
+func matchContext(text string, start, end int) string {
+	from := start
+	for n := 0; from > 0 && n < typographyContextRunes; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:from])
+		from -= size
+	}
+	to := end
+	for n := 0; to < len(text) && n < typographyContextRunes; n++ {
+		_, size := utf8.DecodeRuneInString(text[to:])
+		to += size
+	}
+	context := text[from:to]
+	if from > 0 {
+		context = "…" + context
+	}
+	if to < len(text) {
+		context += "…"
+	}
+	return context
+}
+
+// visibleSpaces renders a match so that its spaces can be counted, e.g.
+// "··" for two spaces.
+func visibleSpaces(s string) string {
+	out := []rune(s)
+	for i, r := range out {
+		if r == ' ' {
+			out[i] = '·'
+		}
+	}
+	return string(out)
+}
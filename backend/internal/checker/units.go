@@ -0,0 +1,169 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// UnitsConfig enables the checks of numbers and units of measurement
+// (ГОСТ 8.417).
+type UnitsConfig struct {
+	CheckUnitSpacing      bool   `json:"check_unit_spacing"`      // "10 мм", not "10мм"
+	CheckRanges           bool   `json:"check_ranges"`            // "10–20", not "10-20"
+	CheckDecimalSeparator bool   `json:"check_decimal_separator"` // "2,5 мм", not "2.5 мм"
+	CheckUnitNames        bool   `json:"check_unit_names"`        // "с", not "сек"
+	AllowedUnits          string `json:"allowed_units"`           // Comma-sep list; empty = ГОСТ 8.417 designations
+}
+
+// unitCatalog maps the ways units are written after a number to their
+// designation by ГОСТ 8.417. Designations map to themselves; the rest are
+// common misspellings and Latin forms.
+var unitCatalog = map[string]string{
+	"нм": "нм", "мкм": "мкм", "мм": "мм", "см": "см", "дм": "дм", "м": "м", "км": "км",
+	"мг": "мг", "г": "г", "кг": "кг", "т": "т",
+	"нс": "нс", "мкс": "мкс", "мс": "мс", "с": "с", "мин": "мин", "ч": "ч", "сут": "сут",
+	"Гц": "Гц", "кГц": "кГц", "МГц": "МГц", "ГГц": "ГГц",
+	"мВ": "мВ", "В": "В", "кВ": "кВ", "мА": "мА", "А": "А", "кА": "кА",
+	"Вт": "Вт", "кВт": "кВт", "МВт": "МВт", "Ом": "Ом", "кОм": "кОм", "МОм": "МОм",
+	"Дж": "Дж", "кДж": "кДж", "Н": "Н", "кН": "кН", "Па": "Па", "кПа": "кПа", "МПа": "МПа",
+	"К": "К", "мл": "мл", "л": "л", "дБ": "дБ", "%": "%",
+	"бит": "бит", "байт": "байт", "Кбайт": "Кбайт", "Мбайт": "Мбайт", "Гбайт": "Гбайт", "Тбайт": "Тбайт",
+	"бит/с": "бит/с", "Кбит/с": "Кбит/с", "Мбит/с": "Мбит/с", "Гбит/с": "Гбит/с",
+	"м/с": "м/с", "км/ч": "км/ч", "об/мин": "об/мин",
+
+	"сек": "с", "мсек": "мс", "час": "ч", "гр": "г", "кгр": "кг", "Кг": "кг", "КГ": "кг",
+	"гц": "Гц", "ГЦ": "Гц", "КГц": "кГц", "Мгц": "МГц", "Ггц": "ГГц",
+	"вт": "Вт", "квт": "кВт", "КВт": "кВт", "ом": "Ом", "кв": "кВ",
+	"Кб": "Кбайт", "кб": "Кбайт", "КБ": "Кбайт", "Мб": "Мбайт", "мб": "Мбайт", "МБ": "Мбайт",
+	"Гб": "Гбайт", "гб": "Гбайт", "ГБ": "Гбайт", "Тб": "Тбайт", "ТБ": "Тбайт",
+	"mm": "мм", "cm": "см", "km": "км", "kg": "кг", "ms": "мс", "Hz": "Гц", "kHz": "кГц", "MHz": "МГц", "GHz": "ГГц",
+	"KB": "Кбайт", "MB": "Мбайт", "GB": "Гбайт", "TB": "Тбайт",
+}
+
+// numberUnitRegex matches a number and the word or sign right after it.
+var numberUnitRegex = regexp.MustCompile(`(\d+(?:[.,]\d+)*)([ \x{00A0}]?)(%|\p{L}+(?:/\p{L}+)?)`)
+
+// numberRangeRegex matches two numbers joined by a hyphen.
+var numberRangeRegex = regexp.MustCompile(`\d+(?:[.,]\d+)?(-)\d+(?:[.,]\d+)?`)
+
+// designationRegex matches the designation of a standard or specification
+// right before a number, as in "ГОСТ 7.32-2017", where the hyphen is part of
+// the designation.
+var designationRegex = regexp.MustCompile(`(?:ГОСТ|ОСТ|СТО|СП|СНиП|РД|ТУ|ISO|ИСО|IEC|МЭК|№)(?: Р)?\s*$`)
+
+// unitDictionary returns the allowed unit designations: the comma-separated
+// list of the standard or, when it is empty, the ГОСТ 8.417 designations.
+func unitDictionary(list string) map[string]bool {
+	allowed := map[string]bool{}
+	for _, u := range strings.Split(list, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			allowed[u] = true
+		}
+	}
+	if len(allowed) == 0 {
+		for _, designation := range unitCatalog {
+			allowed[designation] = true
+		}
+	}
+	return allowed
+}
+
+// checkNumbersAndUnits checks the numbers of one paragraph against ГОСТ 8.417:
+// a space between a number and its unit, an en dash in ranges, a decimal
+// comma and the unit designations. A decimal point is only reported before
+// a unit, since "2.1" alone is usually a section or figure number.
+func checkNumbersAndUnits(text string, cfg UnitsConfig, allowed map[string]bool, pos string, loc *models.Location) ([]models.Violation, int) {
+	var vs []models.Violation
+	rules := 0
+	add := func(ruleType, description, expected, actual, suggestion string, start, end int) {
+		vs = append(vs, textViolation(text, start, end, pos, loc, models.Violation{
+			RuleType:      ruleType,
+			Description:   description,
+			ExpectedValue: expected,
+			ActualValue:   actual,
+			Suggestion:    suggestion,
+			Severity:      "warning",
+		}))
+	}
+
+	if cfg.CheckUnitSpacing || cfg.CheckDecimalSeparator || cfg.CheckUnitNames {
+		for _, enabled := range []bool{cfg.CheckUnitSpacing, cfg.CheckDecimalSeparator, cfg.CheckUnitNames} {
+			if enabled {
+				rules++
+			}
+		}
+		found := 0
+		for _, m := range numberUnitRegex.FindAllStringSubmatchIndex(text, -1) {
+			if found >= maxTypographyMatches {
+				break
+			}
+			number, sep, unit := text[m[2]:m[3]], text[m[4]:m[5]], text[m[6]:m[7]]
+			// "А4", "x86": the number is part of a word
+			if r, _ := utf8.DecodeLastRuneInString(text[:m[0]]); m[0] > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				continue
+			}
+			designation, known := unitCatalog[unit]
+			if !known && !allowed[unit] {
+				continue
+			}
+			if designation == "" {
+				designation = unit
+			}
+
+			if cfg.CheckUnitSpacing && sep == "" {
+				found++
+				add("unit_spacing", "Нет пробела между числом и единицей измерения",
+					number+" "+unit, number+unit,
+					"Отделите единицу от числа неразрывным пробелом (Ctrl + Shift + Пробел)", m[3], m[6])
+			}
+			if cfg.CheckDecimalSeparator && strings.Contains(number, ".") && strings.Count(number, ".") == 1 && !strings.Contains(number, ",") {
+				found++
+				dot := m[2] + strings.Index(number, ".")
+				add("decimal_separator", "Десятичная точка вместо запятой",
+					strings.Replace(number, ".", ",", 1), number,
+					"В русском тексте целую и дробную части разделяет запятая", dot, dot+1)
+			}
+			if cfg.CheckUnitNames && !allowed[unit] {
+				found++
+				expected, suggestion := designation, fmt.Sprintf("Замените «%s» на «%s»", unit, designation)
+				if designation == unit || !allowed[designation] {
+					expected, suggestion = "Обозначение из списка стандарта", "Используйте обозначения единиц, перечисленные в стандарте"
+				}
+				add("unit_name", fmt.Sprintf("Недопустимое обозначение единицы «%s»", unit),
+					expected, unit, suggestion, m[6], m[7])
+			}
+		}
+	}
+
+	if cfg.CheckRanges {
+		rules++
+		found := 0
+		for _, m := range numberRangeRegex.FindAllStringSubmatchIndex(text, -1) {
+			if found >= maxTypographyMatches {
+				break
+			}
+			// Dates, phone numbers and codes have more parts or are glued to
+			// letters: "2020-01-01", "8-800-...", "Ту-154".
+			before, _ := utf8.DecodeLastRuneInString(text[:m[0]])
+			after, _ := utf8.DecodeRuneInString(text[m[1]:])
+			if m[0] > 0 && (unicode.IsLetter(before) || strings.ContainsRune("-–./", before)) {
+				continue
+			}
+			if m[1] < len(text) && strings.ContainsRune("-–/", after) {
+				continue
+			}
+			if designationRegex.MatchString(text[:m[0]]) {
+				continue
+			}
+			found++
+			add("number_range_dash", "Дефис в диапазоне чисел",
+				strings.Replace(text[m[0]:m[1]], "-", "–", 1), text[m[0]:m[1]],
+				"Диапазон значений пишется через тире без пробелов: 10–20", m[2], m[3])
+		}
+	}
+	return vs, rules
+}
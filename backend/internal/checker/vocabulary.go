@@ -0,0 +1,195 @@
+package checker
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"regexp"
+	"strings"
+)
+
+// Vocabulary dictionary categories.
+const (
+	VocabularyColloquialism = "colloquialism" // разговорные слова: «короче», «типа»
+	VocabularyFirstPerson   = "first_person"  // местоимения первого лица: «я», «мы», «наш»
+	VocabularyFiller        = "filler"        // слова-паразиты и пустые слова: «как бы», «в принципе»
+)
+
+// VocabularyCategories lists the known dictionary categories; dictionaries of
+// other categories are checked all the same under the generic description.
+var VocabularyCategories = []string{VocabularyColloquialism, VocabularyFirstPerson, VocabularyFiller}
+
+var vocabularyDescriptions = map[string]string{
+	VocabularyColloquialism: "Разговорное слово",
+	VocabularyFirstPerson:   "Местоимение первого лица",
+	VocabularyFiller:        "Слово-паразит",
+}
+
+// VocabularyDictionary is a list of words to avoid in the text, e.g. the
+// colloquialisms of a department. Its violations carry its severity and the
+// suggested replacements of the word found.
+type VocabularyDictionary struct {
+	Name       string           `json:"name"`
+	Category   string           `json:"category"`   // see Vocabulary* constants
+	Severity   string           `json:"severity"`   // "error" when empty
+	Morphology bool             `json:"morphology"` // also match other forms of the words, see wordFormKey
+	Words      []VocabularyWord `json:"words"`
+}
+
+// VocabularyWord is a dictionary entry: a word or a phrase and what to write
+// instead. Exact turns off the matching of other word forms for the entry,
+// e.g. for «типа», whose stem is that of the noun «тип».
+type VocabularyWord struct {
+	Word         string   `json:"word"`
+	Replacements []string `json:"replacements,omitempty"`
+	Exact        bool     `json:"exact,omitempty"`
+}
+
+// forbiddenWord is a forbidden vocabulary entry with its compiled matcher:
+// keys are the word form keys of a phrase matched in any form, re matches
+// the exact form.
+type forbiddenWord struct {
+	word         string
+	keys         []string
+	re           *regexp.Regexp
+	category     string
+	severity     string
+	replacements []string
+}
+
+// vocabulary is the compiled vocabulary of a check.
+type vocabulary []forbiddenWord
+
+// vocabularyMatch is the first occurrence of an entry in a paragraph, by
+// byte offsets.
+type vocabularyMatch struct {
+	word       forbiddenWord
+	start, end int
+}
+
+// compileForbiddenWords compiles the comma-separated forbidden word list once
+// per check instead of once per paragraph. Words are matched in any form; a
+// word written as «=типа» only as it is written.
+func compileForbiddenWords(list string) vocabulary {
+	var words vocabulary
+	for _, w := range strings.Split(list, ",") {
+		w = strings.TrimSpace(w)
+		exact := strings.HasPrefix(w, "=")
+		if fw, ok := compileForbiddenWord(strings.TrimPrefix(w, "="), !exact); ok {
+			words = append(words, fw)
+		}
+	}
+	return words
+}
+
+// compileVocabulary compiles the legacy forbidden word list and the
+// vocabulary dictionaries of scope. A word listed twice is matched once, with
+// the entry that comes first.
+func compileVocabulary(scope ScopeConfig) vocabulary {
+	words := compileForbiddenWords(scope.ForbiddenWords)
+	seen := map[string]bool{}
+	for _, fw := range words {
+		seen[fw.word] = true
+	}
+	for _, dict := range scope.Vocabulary {
+		severity := dict.Severity
+		if severity == "" {
+			severity = models.SeverityError
+		}
+		for _, entry := range dict.Words {
+			fw, ok := compileForbiddenWord(entry.Word, dict.Morphology && !entry.Exact)
+			if !ok || seen[fw.word] {
+				continue
+			}
+			seen[fw.word] = true
+			fw.category, fw.severity, fw.replacements = dict.Category, severity, entry.Replacements
+			words = append(words, fw)
+		}
+	}
+	return words
+}
+
+func compileForbiddenWord(w string, morphology bool) (forbiddenWord, bool) {
+	w = strings.Join(strings.Fields(strings.ToLower(w)), " ")
+	if w == "" {
+		return forbiddenWord{}, false
+	}
+	if morphology {
+		var keys []string
+		for _, word := range splitWords(w) {
+			keys = append(keys, word.key)
+		}
+		if len(keys) > 0 {
+			return forbiddenWord{word: w, keys: keys, severity: models.SeverityError}, true
+		}
+	}
+	// Use Unicode word-boundary matching: \P{L} matches any non-letter
+	// character (space, punctuation, start/end of string). This prevents
+	// "мы" from matching inside "мыться".
+	// Pattern: (^|\P{L})word($|\P{L})
+	re, err := regexp.Compile(`(?i)(^|\P{L})(` + regexp.QuoteMeta(w) + `)($|\P{L})`)
+	if err != nil {
+		return forbiddenWord{}, false
+	}
+	return forbiddenWord{word: w, re: re, severity: models.SeverityError}, true
+}
+
+// find returns the first occurrence of every entry in lower-cased text. The
+// text is split into words only when some entry is matched in any form.
+func (v vocabulary) find(text string) []vocabularyMatch {
+	var matches []vocabularyMatch
+	var words []textWord
+	split := false
+	for _, fw := range v {
+		if fw.re != nil {
+			if m := fw.re.FindStringSubmatchIndex(text); m != nil {
+				matches = append(matches, vocabularyMatch{word: fw, start: m[4], end: m[5]})
+			}
+			continue
+		}
+		if !split {
+			words, split = splitWords(text), true
+		}
+		if start, end, ok := fw.findForms(words); ok {
+			matches = append(matches, vocabularyMatch{word: fw, start: start, end: end})
+		}
+	}
+	return matches
+}
+
+// findForms finds the phrase of fw, in any form, among the words of a text.
+func (fw forbiddenWord) findForms(words []textWord) (int, int, bool) {
+	for i := 0; i+len(fw.keys) <= len(words); i++ {
+		found := true
+		for j, key := range fw.keys {
+			if words[i+j].key != key {
+				found = false
+				break
+			}
+		}
+		if found {
+			return words[i].start, words[i+len(fw.keys)-1].end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// violation reports the entry found as form in a paragraph.
+func (fw forbiddenWord) violation(form, pos, context string, loc *models.Location) models.Violation {
+	description := fmt.Sprintf("Запрещённое слово: '%s'", fw.word)
+	if label, ok := vocabularyDescriptions[fw.category]; ok {
+		description = fmt.Sprintf("%s: '%s'", label, fw.word)
+	}
+	v := models.Violation{
+		RuleType: "vocabulary", Description: description, PositionInDoc: pos,
+		ExpectedValue: "Не должно быть", ActualValue: "Присутствует", Severity: fw.severity,
+		ContextText: context,
+		Location:    loc,
+	}
+	if form != fw.word {
+		v.ActualValue = fmt.Sprintf("Присутствует: «%s»", form)
+	}
+	if len(fw.replacements) > 0 {
+		v.Suggestion = "Замените на: «" + strings.Join(fw.replacements, "», «") + "»"
+	}
+	return v
+}
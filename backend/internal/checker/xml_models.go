@@ -1,6 +1,9 @@
 package checker
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"strings"
+)
 
 // OpenXML Structures for parsing word/document.xml
 
@@ -9,6 +12,9 @@ type Document struct {
 	Body    Body     `xml:"body"`
 }
 
+// Body keeps paragraphs and tables in separate slices for the checks that only
+// need one kind, and Blocks records the document order of all block elements
+// so captions and positions can be resolved against their real neighbours.
 type Body struct {
 	SectPr     *SectPr     `xml:"sectPr"`
 	Paragraphs []Paragraph `xml:"p"`
@@ -16,6 +22,16 @@ type Body struct {
 	Blocks     []BodyBlock
 }
 
+// Kinds of BodyBlock.
+const (
+	BlockParagraph = "p"
+	BlockTable     = "tbl"
+	BlockSectPr    = "sectPr"
+)
+
+// BodyBlock is one block element of the body in document order. Index points
+// into Body.Paragraphs or Body.Tbls; for BlockSectPr it is -1 (the final
+// section properties are Body.SectPr).
 type BodyBlock struct {
 	Kind  string
 	Index int
@@ -37,20 +53,21 @@ func (b *Body) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 					return err
 				}
 				b.Paragraphs = append(b.Paragraphs, p)
-				b.Blocks = append(b.Blocks, BodyBlock{Kind: "p", Index: len(b.Paragraphs) - 1})
+				b.Blocks = append(b.Blocks, BodyBlock{Kind: BlockParagraph, Index: len(b.Paragraphs) - 1})
 			case "tbl":
 				var tbl Tbl
 				if err := d.DecodeElement(&tbl, &t); err != nil {
 					return err
 				}
 				b.Tbls = append(b.Tbls, tbl)
-				b.Blocks = append(b.Blocks, BodyBlock{Kind: "tbl", Index: len(b.Tbls) - 1})
+				b.Blocks = append(b.Blocks, BodyBlock{Kind: BlockTable, Index: len(b.Tbls) - 1})
 			case "sectPr":
 				var sect SectPr
 				if err := d.DecodeElement(&sect, &t); err != nil {
 					return err
 				}
 				b.SectPr = &sect
+				b.Blocks = append(b.Blocks, BodyBlock{Kind: BlockSectPr, Index: -1})
 			default:
 				var skip struct{}
 				if err := d.DecodeElement(&skip, &t); err != nil {
@@ -74,6 +91,23 @@ type Paragraph struct {
 	OMaths     []OMath `xml:"oMath"` // Check for formulas
 	// Block-level formula paragraph container
 	OMathParas []OMathPara `xml:"oMathPara"`
+
+	// Tracked changes: runs inserted, deleted or moved with track changes on
+	Ins      []Revision `xml:"ins"`
+	Del      []Revision `xml:"del"`
+	MoveFrom []Revision `xml:"moveFrom"`
+	MoveTo   []Revision `xml:"moveTo"`
+
+	ParaID string `xml:"paraId,attr"` // w14:paraId; links comments to commentsExtended.xml
+}
+
+// Revision is a tracked change: w:ins, w:del, w:moveFrom or w:moveTo around
+// runs, w:ins or w:del of a paragraph mark, or w:rPrChange and w:pPrChange
+// holding the formatting before a change.
+type Revision struct {
+	Author string `xml:"author,attr"`
+	Date   string `xml:"date,attr"`
+	R      []Run  `xml:"r"`
 }
 
 type Hyperlink struct {
@@ -81,17 +115,41 @@ type Hyperlink struct {
 }
 
 type FldSimple struct {
-	R []Run `xml:"r"`
+	Instr string `xml:"instr,attr"`
+	R     []Run  `xml:"r"`
 }
 
 type Run struct {
 	RPr                   *RPr     `xml:"rPr"`
 	Text                  *Text    `xml:"t"`
 	InstrText             *Text    `xml:"instrText"`
+	FldChar               *FldChar `xml:"fldChar"`
 	Tab                   *Empty   `xml:"tab"`
 	Br                    *Br      `xml:"br"`                    // Explicit breaks
 	Drawing               *Drawing `xml:"drawing"`               // Images
 	LastRenderedPageBreak *Empty   `xml:"lastRenderedPageBreak"` // Soft breaks
+
+	// Images wrapped in mc:AlternateContent, as LibreOffice writes them;
+	// paragraphRuns moves them to Drawing
+	ChoiceDrawing *Drawing `xml:"AlternateContent>Choice>drawing"`
+
+	FootnoteReference *NoteRef `xml:"footnoteReference"`
+	EndnoteReference  *NoteRef `xml:"endnoteReference"`
+
+	Object *EmbeddedObject `xml:"object"` // OLE objects: Equation Editor, MathType
+
+	SoftHyphen *Empty `xml:"softHyphen"` // optional hyphen typed with Ctrl + hyphen
+
+	DelText          *Text    `xml:"delText"`          // text of a tracked deletion
+	CommentReference *NoteRef `xml:"commentReference"` // anchor of a comment
+}
+
+type EmbeddedObject struct {
+	OLEObject *OLEObject `xml:"OLEObject"`
+}
+
+type OLEObject struct {
+	ProgID string `xml:"ProgID,attr"`
 }
 
 // --- Table Structures ---
@@ -227,13 +285,63 @@ type MJc struct {
 }
 
 type OMath struct {
-	XMLName xml.Name `xml:"oMath"`
+	XMLName xml.Name   `xml:"oMath"`
+	Nodes   []MathNode `xml:",any"` // the formula tree, rendered by formulaText
 }
 
 // --- Other Run-Level Elements ---
 
 type Drawing struct {
-	XMLName xml.Name `xml:"drawing"`
+	XMLName xml.Name          `xml:"drawing"`
+	Inline  *DrawingPlacement `xml:"inline"`
+	Anchor  *DrawingPlacement `xml:"anchor"`
+}
+
+// DrawingPlacement is wp:inline or wp:anchor; docPr carries the alt text.
+type DrawingPlacement struct {
+	DocPr  *DocPr  `xml:"docPr"`
+	Extent *Extent `xml:"extent"`
+	Blip   *Blip   `xml:"graphic>graphicData>pic>blipFill>blip"`
+}
+
+// Extent is the displayed size of a drawing in EMU (914400 per inch).
+type Extent struct {
+	Cx int64 `xml:"cx,attr"`
+	Cy int64 `xml:"cy,attr"`
+}
+
+// Blip points to the picture part through its relationship id.
+type Blip struct {
+	Embed string `xml:"embed,attr"`
+}
+
+type DocPr struct {
+	Name  string `xml:"name,attr"`
+	Descr string `xml:"descr,attr"` // alt text
+	Title string `xml:"title,attr"`
+}
+
+// placement returns the inline or anchored placement of the drawing.
+func (d *Drawing) placement() *DrawingPlacement {
+	if d.Inline != nil {
+		return d.Inline
+	}
+	return d.Anchor
+}
+
+// AltText returns the alternative text of the drawing, if any.
+func (d *Drawing) AltText() string {
+	for _, placement := range []*DrawingPlacement{d.Inline, d.Anchor} {
+		if placement != nil && placement.DocPr != nil {
+			if alt := strings.TrimSpace(placement.DocPr.Descr); alt != "" {
+				return alt
+			}
+			if alt := strings.TrimSpace(placement.DocPr.Title); alt != "" {
+				return alt
+			}
+		}
+	}
+	return ""
 }
 
 type Text struct {
@@ -249,12 +357,17 @@ type PPr struct {
 	Ind             *Ind          `xml:"ind"`
 	SectPr          *SectPr       `xml:"sectPr"`
 	RPr             *RPr          `xml:"rPr"`
-	KeepLines       *Empty        `xml:"keepLines"`
-	KeepNext        *Empty        `xml:"keepNext"`
+	KeepLines       *OnOff        `xml:"keepLines"`
+	KeepNext        *OnOff        `xml:"keepNext"`
 	WidowControl    *WidowControl `xml:"widowControl"`
 	PageBreakBefore *Empty        `xml:"pageBreakBefore"` // Page break before paragraph
 	PStyle          *Val          `xml:"pStyle"`          // Style ID (Heading 1, etc.)
 	NumPr           *NumPr        `xml:"numPr"`           // List properties
+	OutlineLvl      *Val          `xml:"outlineLvl"`      // 0-8 = heading levels 1-9, 9 = body text
+
+	SuppressAutoHyphens *OnOff `xml:"suppressAutoHyphens"` // no automatic hyphenation in this paragraph
+
+	Change *Revision `xml:"pPrChange"` // tracked change of the paragraph formatting
 }
 
 type NumPr struct {
@@ -274,11 +387,63 @@ type RPr struct {
 	U      *Val    `xml:"u"`
 	Caps   *OnOff  `xml:"caps"`
 	Strike *OnOff  `xml:"strike"`
+
+	Vanish *OnOff `xml:"vanish"` // hidden text
+	Color  *Val   `xml:"color"`  // hex RGB or "auto"
+
+	Change *Revision `xml:"rPrChange"` // tracked change of the run formatting
+	// Tracked insertion or deletion of the paragraph mark (in w:pPr/w:rPr)
+	MarkIns *Revision `xml:"ins"`
+	MarkDel *Revision `xml:"del"`
 }
 
 type SectPr struct {
-	PgMar *PgMar `xml:"pgMar"`
-	PgSz  *PgSz  `xml:"pgSz"`
+	PgMar            *PgMar      `xml:"pgMar"`
+	PgSz             *PgSz       `xml:"pgSz"`
+	HeaderReferences []HdrFtrRef `xml:"headerReference"`
+	FooterReferences []HdrFtrRef `xml:"footerReference"`
+	TitlePg          *OnOff      `xml:"titlePg"` // separate header/footer on the first page
+	Type             *Val        `xml:"type"`    // how the section starts: nextPage (default), continuous, evenPage, oddPage
+	Cols             *Cols       `xml:"cols"`
+
+	FootnotePr *FootnotePr `xml:"footnotePr"`
+}
+
+// HdrFtrRef points a section to a header or footer part through its
+// relationship id. Type is "default", "first" or "even".
+type HdrFtrRef struct {
+	Type string `xml:"type,attr"`
+	ID   string `xml:"id,attr"`
+}
+
+// FldChar marks the begin, separator and end of a complex field; the runs
+// between "separate" and "end" hold the field result.
+type FldChar struct {
+	Type string `xml:"fldCharType,attr"`
+}
+
+// HdrFtr is the root of a word/header*.xml or word/footer*.xml part.
+type HdrFtr struct {
+	Paragraphs []Paragraph `xml:"p"`
+	Tbls       []Tbl       `xml:"tbl"`
+	Sdts       []Sdt       `xml:"sdt"` // Word wraps page number blocks in content controls
+}
+
+type Sdt struct {
+	Content struct {
+		Paragraphs []Paragraph `xml:"p"`
+	} `xml:"sdtContent"`
+}
+
+// Relationships is word/_rels/document.xml.rels.
+type Relationships struct {
+	Rels []Relationship `xml:"Relationship"`
+}
+
+type Relationship struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
 }
 
 // Attributes
@@ -296,6 +461,7 @@ type Spacing struct {
 
 type Ind struct {
 	FirstLine string `xml:"firstLine,attr"`
+	Hanging   string `xml:"hanging,attr"`
 	Left      string `xml:"left,attr"`
 	Right     string `xml:"right,attr"`
 }
@@ -305,10 +471,12 @@ type WidowControl struct {
 }
 
 type RFonts struct {
-	Ascii    string `xml:"ascii,attr"`
-	HAnsi    string `xml:"hAnsi,attr"`
-	Cs       string `xml:"cs,attr"`
-	EastAsia string `xml:"eastAsia,attr"`
+	Ascii      string `xml:"ascii,attr"`
+	HAnsi      string `xml:"hAnsi,attr"`
+	Cs         string `xml:"cs,attr"`
+	EastAsia   string `xml:"eastAsia,attr"`
+	AsciiTheme string `xml:"asciiTheme,attr"`
+	HAnsiTheme string `xml:"hAnsiTheme,attr"`
 }
 
 type Val struct {
@@ -330,6 +498,11 @@ type PgSz struct {
 	Orient string `xml:"orient,attr"`
 }
 
+// Cols is the column layout of a section; Num is absent for one column.
+type Cols struct {
+	Num int `xml:"num,attr"`
+}
+
 type Empty struct{}
 
 type OnOff struct {
@@ -340,12 +513,24 @@ type OnOff struct {
 // formatting inherited from paragraph styles instead of trusting only run-level
 // formatting in document.xml.
 type StylesDoc struct {
-	Styles []Style `xml:"style"`
+	DocDefaults *DocDefaults `xml:"docDefaults"`
+	Styles      []Style      `xml:"style"`
+}
+
+// DocDefaults holds the formatting every style starts from.
+type DocDefaults struct {
+	RPrDefault *struct {
+		RPr *RPr `xml:"rPr"`
+	} `xml:"rPrDefault"`
+	PPrDefault *struct {
+		PPr *PPr `xml:"pPr"`
+	} `xml:"pPrDefault"`
 }
 
 type Style struct {
 	Type    string     `xml:"type,attr"`
 	StyleID string     `xml:"styleId,attr"`
+	Default string     `xml:"default,attr"`
 	Name    *StyleName `xml:"name"`
 	BasedOn *Val       `xml:"basedOn"`
 	PPr     *PPr       `xml:"pPr"`
@@ -355,3 +540,130 @@ type Style struct {
 type StyleName struct {
 	Val string `xml:"val,attr"`
 }
+
+// ThemeDoc is the font scheme of word/theme/theme1.xml. Styles often refer to
+// theme fonts (w:asciiTheme="minorHAnsi") instead of naming a font.
+type ThemeDoc struct {
+	Elements struct {
+		FontScheme struct {
+			Major ThemeFont `xml:"majorFont"`
+			Minor ThemeFont `xml:"minorFont"`
+		} `xml:"fontScheme"`
+	} `xml:"themeElements"`
+}
+
+type ThemeFont struct {
+	Latin struct {
+		Typeface string `xml:"typeface,attr"`
+	} `xml:"latin"`
+	// Fonts of particular scripts (a:font script="Cyrl")
+	Fonts []struct {
+		Script   string `xml:"script,attr"`
+		Typeface string `xml:"typeface,attr"`
+	} `xml:"font"`
+}
+
+// typeface returns the Latin typeface of the theme font, or the Cyrillic one
+// when a theme leaves the Latin typeface empty.
+func (f ThemeFont) typeface() string {
+	if f.Latin.Typeface != "" {
+		return f.Latin.Typeface
+	}
+	for _, font := range f.Fonts {
+		if font.Script == "Cyrl" {
+			return font.Typeface
+		}
+	}
+	return ""
+}
+
+// NumberingDoc is a subset of word/numbering.xml: the list definitions that
+// the numPr of a paragraph refers to. A w:num points to a w:abstractNum,
+// whose levels hold the number format, the marker text and the indents.
+type NumberingDoc struct {
+	AbstractNums []AbstractNum `xml:"abstractNum"`
+	Nums         []Num         `xml:"num"`
+}
+
+type AbstractNum struct {
+	ID     string     `xml:"abstractNumId,attr"`
+	Levels []NumLevel `xml:"lvl"`
+}
+
+type NumLevel struct {
+	Ilvl    string `xml:"ilvl,attr"`
+	NumFmt  *Val   `xml:"numFmt"`  // bullet, decimal, lowerLetter, russianLower, ...
+	LvlText *Val   `xml:"lvlText"` // "%1.", "–" or a Symbol-font bullet
+	PPr     *PPr   `xml:"pPr"`
+}
+
+type Num struct {
+	ID            string        `xml:"numId,attr"`
+	AbstractNumID *Val          `xml:"abstractNumId"`
+	LvlOverrides  []LvlOverride `xml:"lvlOverride"`
+}
+
+// LvlOverride redefines one level of an abstract list for a single list.
+type LvlOverride struct {
+	Ilvl string    `xml:"ilvl,attr"`
+	Lvl  *NumLevel `xml:"lvl"`
+}
+
+// NoteRef is the place of a footnote or endnote in the text. With
+// w:customMarkFollows the note has a mark typed by hand instead of its number.
+type NoteRef struct {
+	ID                string `xml:"id,attr"`
+	CustomMarkFollows string `xml:"customMarkFollows,attr"`
+}
+
+// NotesDoc is the root of word/footnotes.xml (w:footnote elements) or
+// word/endnotes.xml (w:endnote elements).
+type NotesDoc struct {
+	Footnotes []Note `xml:"footnote"`
+	Endnotes  []Note `xml:"endnote"`
+}
+
+// Note is one footnote or endnote. Separators have a type; notes written by
+// the author have none or "normal".
+type Note struct {
+	ID         string      `xml:"id,attr"`
+	Type       string      `xml:"type,attr"`
+	Paragraphs []Paragraph `xml:"p"`
+}
+
+// FootnotePr holds the numbering of footnotes, in w:sectPr or
+// word/settings.xml.
+type FootnotePr struct {
+	NumRestart *Val `xml:"numRestart"` // continuous (default), eachSect, eachPage
+	NumStart   *Val `xml:"numStart"`
+}
+
+// SettingsDoc is the subset of word/settings.xml the checker reads.
+type SettingsDoc struct {
+	FootnotePr      *FootnotePr `xml:"footnotePr"`
+	AutoHyphenation *OnOff      `xml:"autoHyphenation"`
+}
+
+// CommentsDoc is the root of word/comments.xml.
+type CommentsDoc struct {
+	Comments []Comment `xml:"comment"`
+}
+
+// Comment is a reviewer comment; its w:commentReference in the body is a
+// run with the same id.
+type Comment struct {
+	ID         string      `xml:"id,attr"`
+	Author     string      `xml:"author,attr"`
+	Date       string      `xml:"date,attr"`
+	Paragraphs []Paragraph `xml:"p"`
+}
+
+// CommentsExDoc is the root of word/commentsExtended.xml, where Word 2013 and
+// later mark comments as resolved. An entry refers to the last paragraph of a
+// comment by its paraId.
+type CommentsExDoc struct {
+	Comments []struct {
+		ParaID string `xml:"paraId,attr"`
+		Done   string `xml:"done,attr"`
+	} `xml:"commentEx"`
+}
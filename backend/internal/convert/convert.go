@@ -0,0 +1,225 @@
+// Package convert turns stored documents into PDF for the preview. The
+// conversion runs on a bounded pool under a timeout, failed attempts are
+// retried, and the converter's health is watched, so a missing or hanging
+// converter costs checks neither workers nor time.
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDisabled is returned when no converter is configured (CONVERTER=none).
+	ErrDisabled = errors.New("pdf conversion is disabled")
+	// ErrUnavailable is returned while the last health check failed.
+	ErrUnavailable = errors.New("pdf converter is unavailable")
+)
+
+// Converter writes the PDF of a document.
+type Converter interface {
+	Name() string
+	// Convert writes the PDF of src to dst.
+	Convert(ctx context.Context, src, dst string) error
+	// Check reports whether the converter can be used, e.g. that its binary
+	// runs or its service answers.
+	Check(ctx context.Context) error
+}
+
+// Service converts documents with a Converter on at most Workers conversions
+// at once. Each attempt is bounded by Timeout; failed attempts are retried
+// Retries times.
+type Service struct {
+	converter Converter
+	slots     chan struct{}
+	timeout   time.Duration
+	retries   int
+
+	mu        sync.Mutex
+	healthErr error
+	checkedAt time.Time
+}
+
+// Health describes the converter for the health endpoint.
+type Health struct {
+	Converter string    `json:"converter"`
+	Available bool      `json:"available"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// healthRecheck is how long a failed health check keeps the converter
+// unavailable before a conversion checks it again.
+const healthRecheck = time.Minute
+
+// NewService returns a service with the given limits; workers and retries
+// below their minimum are raised to 1 and 0.
+func NewService(c Converter, workers int, timeout time.Duration, retries int) *Service {
+	if workers < 1 {
+		workers = 1
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	return &Service{converter: c, slots: make(chan struct{}, workers), timeout: timeout, retries: retries}
+}
+
+// FromEnv configures the service from CONVERTER (soffice, unoconv, gotenberg
+// or none; default soffice), SOFFICE_PATH, UNOCONV_PATH, GOTENBERG_URL,
+// CONVERT_WORKERS (default 2), CONVERT_TIMEOUT_SECONDS (default 60) and
+// CONVERT_RETRIES (default 1). A nil service means conversion is disabled.
+func FromEnv() (*Service, error) {
+	var c Converter
+	switch name := strings.TrimSpace(os.Getenv("CONVERTER")); name {
+	case "", "soffice":
+		c = &Soffice{Binary: envOrDefault("SOFFICE_PATH", "soffice")}
+	case "unoconv":
+		c = &Unoconv{Binary: envOrDefault("UNOCONV_PATH", "unoconv")}
+	case "gotenberg":
+		url := strings.TrimRight(strings.TrimSpace(os.Getenv("GOTENBERG_URL")), "/")
+		if url == "" {
+			return nil, errors.New("CONVERTER=gotenberg requires GOTENBERG_URL")
+		}
+		c = NewGotenberg(url)
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown CONVERTER %q, expected soffice, unoconv, gotenberg or none", name)
+	}
+	timeout := time.Duration(envInt("CONVERT_TIMEOUT_SECONDS", 60)) * time.Second
+	return NewService(c, envInt("CONVERT_WORKERS", 2), timeout, envInt("CONVERT_RETRIES", 1)), nil
+}
+
+var (
+	defaultService *Service
+	defaultOnce    sync.Once
+)
+
+// Default returns the process-wide service configured from the environment
+// on first use, or nil when conversion is disabled or misconfigured.
+func Default() *Service {
+	defaultOnce.Do(func() {
+		s, err := FromEnv()
+		if err != nil {
+			log.Printf("PDF conversion disabled: %v", err)
+			return
+		}
+		if s == nil {
+			log.Println("PDF conversion disabled")
+			return
+		}
+		defaultService = s
+		h := s.CheckHealth(context.Background())
+		if h.Available {
+			log.Printf("PDF conversion: %s", h.Converter)
+		} else {
+			log.Printf("PDF conversion: %s is unavailable: %s", h.Converter, h.Error)
+		}
+	})
+	return defaultService
+}
+
+// Convert writes the PDF of src to dst. It waits for a free worker as long
+// as ctx allows, and fails at once with ErrUnavailable while the converter
+// is known to be broken. A nil service returns ErrDisabled.
+func (s *Service) Convert(ctx context.Context, src, dst string) error {
+	if s == nil {
+		return ErrDisabled
+	}
+	if err := s.available(ctx); err != nil {
+		return err
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for a free converter: %w", ctx.Err())
+	}
+	defer func() { <-s.slots }()
+
+	var err error
+	for attempt := 0; attempt <= s.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-ctx.Done():
+				return fmt.Errorf("%s: %w (last error: %v)", s.converter.Name(), ctx.Err(), err)
+			}
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		err = s.converter.Convert(attemptCtx, src, dst)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return fmt.Errorf("%s: %w", s.converter.Name(), err)
+}
+
+// available returns ErrUnavailable while the last health check failed and
+// is recent; an older failure is checked again.
+func (s *Service) available(ctx context.Context) error {
+	s.mu.Lock()
+	healthErr, checkedAt := s.healthErr, s.checkedAt
+	s.mu.Unlock()
+	if healthErr == nil {
+		return nil
+	}
+	if time.Since(checkedAt) >= healthRecheck {
+		if h := s.CheckHealth(ctx); h.Available {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrUnavailable, healthErr)
+}
+
+// CheckHealth runs the converter's check and remembers its outcome.
+func (s *Service) CheckHealth(ctx context.Context) Health {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	err := s.converter.Check(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthErr, s.checkedAt = err, time.Now()
+	return s.health()
+}
+
+// Health returns the outcome of the last health check.
+func (s *Service) Health() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.health()
+}
+
+func (s *Service) health() Health {
+	h := Health{Converter: s.converter.Name(), Available: s.healthErr == nil, CheckedAt: s.checkedAt}
+	if s.healthErr != nil {
+		h.Error = s.healthErr.Error()
+	}
+	return h
+}
+
+func envOrDefault(name, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envInt(name string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n >= 0 {
+		return n
+	}
+	return fallback
+}
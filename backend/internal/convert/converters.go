@@ -0,0 +1,208 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxOutput bounds the converter output kept for error messages.
+const maxOutput = 2000
+
+// Soffice converts with a local LibreOffice in headless mode. Every run gets
+// its own user profile: runs sharing one wait for each other or fail.
+type Soffice struct {
+	Binary string
+}
+
+func (s *Soffice) Name() string { return "soffice" }
+
+func (s *Soffice) Convert(ctx context.Context, src, dst string) error {
+	work, err := os.MkdirTemp("", "soffice-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+
+	profile := (&url.URL{Scheme: "file", Path: filepath.Join(work, "profile")}).String()
+	cmd := exec.CommandContext(ctx, s.Binary, "-env:UserInstallation="+profile,
+		"--headless", "--norestore", "--convert-to", "pdf", "--outdir", work, src)
+	if err := run(cmd); err != nil {
+		return err
+	}
+	base := filepath.Base(src)
+	return moveFile(filepath.Join(work, strings.TrimSuffix(base, filepath.Ext(base))+".pdf"), dst)
+}
+
+func (s *Soffice) Check(ctx context.Context) error {
+	path, err := exec.LookPath(s.Binary)
+	if err != nil {
+		return err
+	}
+	return run(exec.CommandContext(ctx, path, "--headless", "--version"))
+}
+
+// Unoconv converts through unoconv, which talks to a running LibreOffice
+// listener.
+type Unoconv struct {
+	Binary string
+}
+
+func (u *Unoconv) Name() string { return "unoconv" }
+
+func (u *Unoconv) Convert(ctx context.Context, src, dst string) error {
+	tmp := dst + ".tmp.pdf"
+	defer os.Remove(tmp)
+	if err := run(exec.CommandContext(ctx, u.Binary, "-f", "pdf", "-o", tmp, src)); err != nil {
+		return err
+	}
+	return moveFile(tmp, dst)
+}
+
+func (u *Unoconv) Check(ctx context.Context) error {
+	path, err := exec.LookPath(u.Binary)
+	if err != nil {
+		return err
+	}
+	return run(exec.CommandContext(ctx, path, "--version"))
+}
+
+// Gotenberg converts with a Gotenberg service over HTTP
+// (POST /forms/libreoffice/convert).
+type Gotenberg struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewGotenberg returns a converter for the Gotenberg service at baseURL. The
+// request timeout comes from the context of each conversion.
+func NewGotenberg(baseURL string) *Gotenberg {
+	return &Gotenberg{URL: baseURL, Client: &http.Client{}}
+}
+
+func (g *Gotenberg) Name() string { return "gotenberg" }
+
+func (g *Gotenberg) Convert(ctx context.Context, src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("files", filepath.Base(src))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.URL+"/forms/libreoffice/convert", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput))
+		return fmt.Errorf("gotenberg answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, resp.Body)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return moveFile(tmp, dst)
+}
+
+func (g *Gotenberg) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.URL+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gotenberg health answered %s", resp.Status)
+	}
+	return nil
+}
+
+// run runs cmd and returns its output with the error. A child process left
+// behind by a killed converter must not keep run waiting for the output.
+func run(cmd *exec.Cmd) error {
+	cmd.WaitDelay = 5 * time.Second
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	text := strings.TrimSpace(string(output))
+	if len(text) > maxOutput {
+		text = text[:maxOutput]
+	}
+	if text == "" {
+		return err
+	}
+	return fmt.Errorf("%v: %s", err, text)
+}
+
+// moveFile renames src to dst, copying when they are on different file
+// systems, e.g. a temporary directory and the uploads volume.
+func moveFile(src, dst string) error {
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("converter produced no PDF: %w", err)
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	os.Remove(src)
+	return os.Rename(tmp, dst)
+}
@@ -12,9 +12,15 @@ import (
 
 var DB *sql.DB
 
+// InitDB opens the SQLite database at DB_PATH (default ./academic.db).
 func InitDB() {
+	InitDBAt(envOrDefault("DB_PATH", "./academic.db"))
+}
+
+// InitDBAt opens the SQLite database at path, creating and migrating the schema.
+func InitDBAt(path string) {
 	var err error
-	DB, err = sql.Open("sqlite", "./academic.db")
+	DB, err = sql.Open("sqlite", path)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -91,6 +97,13 @@ func ensureBootstrapAdmin() {
 	}
 }
 
+// BootstrapAdminID returns the id of the ADMIN_EMAIL account created on start.
+func BootstrapAdminID() (uint, error) {
+	var id uint
+	err := DB.QueryRow("SELECT id FROM users WHERE email = ?", envOrDefault("ADMIN_EMAIL", "admin@example.com")).Scan(&id)
+	return id, err
+}
+
 func envOrDefault(key, fallback string) string {
 	value := strings.TrimSpace(os.Getenv(key))
 	if value == "" {
@@ -148,7 +161,11 @@ func createTables() {
 			file_size INTEGER,
 			upload_date DATETIME DEFAULT CURRENT_TIMESTAMP,
 			status TEXT,
-			metadata_json TEXT
+			metadata_json TEXT,
+			content_hash TEXT,
+			last_error TEXT,
+			standard_id INTEGER,
+			config_json TEXT
 		);`,
 		`CREATE TABLE IF NOT EXISTS check_results (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -161,7 +178,9 @@ func createTables() {
 			failed_rules INTEGER,
 			processing_time INTEGER,
 			report_path TEXT,
-			content_json TEXT
+			content_json TEXT,
+			summary TEXT,
+			fingerprint_json TEXT
 		);`,
 		`CREATE TABLE IF NOT EXISTS violations (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -176,7 +195,212 @@ func createTables() {
 			context_text TEXT,
 			is_doubtful BOOLEAN DEFAULT FALSE,
 			ai_verified BOOLEAN DEFAULT FALSE,
-			ai_explanation TEXT
+			ai_explanation TEXT,
+			expected_num REAL,
+			actual_num REAL,
+			unit TEXT,
+			expected_bound TEXT,
+			location_json TEXT
+		);`,
+		`CREATE TABLE IF NOT EXISTS failed_jobs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			document_id INTEGER,
+			file_path TEXT,
+			stage TEXT,
+			error TEXT,
+			stack TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS result_feedback (
+			result_id INTEGER PRIMARY KEY,
+			model TEXT,
+			feedback_json TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS branding (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			institution_name TEXT,
+			logo_path TEXT,
+			report_header TEXT,
+			report_footer TEXT,
+			updated_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS impersonation_audit (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			admin_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			detail TEXT,
+			ip TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS request_stats (
+			bucket DATETIME NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL,
+			method TEXT NOT NULL,
+			route TEXT NOT NULL,
+			requests INTEGER NOT NULL DEFAULT 0,
+			errors INTEGER NOT NULL DEFAULT 0,
+			latency_ms REAL NOT NULL DEFAULT 0,
+			bytes INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (bucket, user_id, role, method, route)
+		);`,
+		`CREATE TABLE IF NOT EXISTS report_templates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			body TEXT NOT NULL,
+			is_active BOOLEAN DEFAULT FALSE,
+			created_by INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS assignments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			standard_id INTEGER NOT NULL,
+			group_id INTEGER NOT NULL,
+			created_by INTEGER,
+			opens_at DATETIME,
+			deadline DATETIME NOT NULL,
+			late_policy TEXT NOT NULL DEFAULT 'hard',
+			grace_hours INTEGER DEFAULT 0,
+			penalty_note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS academic_terms (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			starts_at DATETIME NOT NULL,
+			ends_at DATETIME NOT NULL,
+			archived BOOLEAN DEFAULT FALSE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS learning_resources (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			rule_type TEXT NOT NULL,
+			title TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			url TEXT,
+			file_path TEXT,
+			start_seconds INTEGER DEFAULT 0,
+			created_by INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS feedback_templates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			teacher_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			body TEXT NOT NULL,
+			rule_type TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS vocabulary_dictionaries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			teacher_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			category TEXT NOT NULL,
+			severity TEXT NOT NULL,
+			morphology BOOLEAN DEFAULT FALSE,
+			shared BOOLEAN DEFAULT FALSE,
+			words TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS check_schedules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			standard_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			window_start TEXT NOT NULL,
+			window_end TEXT NOT NULL,
+			status TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			started_at DATETIME,
+			finished_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			message TEXT NOT NULL,
+			link TEXT,
+			is_read BOOLEAN DEFAULT FALSE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS check_defaults (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			defaults_json TEXT NOT NULL,
+			updated_by INTEGER,
+			updated_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_by INTEGER,
+			updated_at DATETIME
+		);`,
+		`CREATE TABLE IF NOT EXISTS result_comments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			result_id INTEGER NOT NULL,
+			author_id INTEGER NOT NULL,
+			template_id INTEGER,
+			rule_type TEXT,
+			body TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS false_positive_reports (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			result_id INTEGER NOT NULL,
+			violation_id INTEGER NOT NULL,
+			rule_type TEXT NOT NULL,
+			reported_by INTEGER NOT NULL,
+			comment TEXT,
+			violation_json TEXT NOT NULL,
+			paragraph_json TEXT,
+			config_json TEXT,
+			status TEXT NOT NULL DEFAULT 'pending',
+			reviewed_by INTEGER,
+			review_note TEXT,
+			reviewed_at DATETIME,
+			created_at DATETIME,
+			UNIQUE (violation_id, reported_by)
+		);`,
+		// Immutable snapshots of a standard, one per change; check results
+		// point to the version they were checked against.
+		`CREATE TABLE IF NOT EXISTS standard_versions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			standard_id INTEGER NOT NULL,
+			version INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT,
+			document_type TEXT,
+			modules_json TEXT,
+			created_by INTEGER,
+			restored_from INTEGER,
+			created_at DATETIME,
+			UNIQUE (standard_id, version)
+		);`,
+		// A grant of a standard to a student group or to one user; exactly
+		// one of group_id and user_id is set.
+		`CREATE TABLE IF NOT EXISTS standard_access (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			standard_id INTEGER NOT NULL,
+			group_id INTEGER,
+			user_id INTEGER,
+			granted_by INTEGER,
+			created_at DATETIME
+		);`,
+		// A grant of the checks of a student group or of one student to a
+		// supervisor; exactly one of group_id and student_id is set.
+		`CREATE TABLE IF NOT EXISTS supervision (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			supervisor_id INTEGER NOT NULL,
+			group_id INTEGER,
+			student_id INTEGER,
+			granted_by INTEGER,
+			created_at DATETIME
 		);`,
 	}
 
@@ -193,4 +417,89 @@ func createTables() {
 	_, _ = DB.Exec(`ALTER TABLE violations ADD COLUMN is_doubtful BOOLEAN DEFAULT FALSE;`)
 	_, _ = DB.Exec(`ALTER TABLE violations ADD COLUMN ai_verified BOOLEAN DEFAULT FALSE;`)
 	_, _ = DB.Exec(`ALTER TABLE violations ADD COLUMN ai_explanation TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE documents ADD COLUMN content_hash TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE documents ADD COLUMN last_error TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE documents ADD COLUMN standard_id INTEGER;`)
+	_, _ = DB.Exec(`ALTER TABLE documents ADD COLUMN config_json TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE documents ADD COLUMN module_id TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE violations ADD COLUMN expected_num REAL;`)
+	_, _ = DB.Exec(`ALTER TABLE violations ADD COLUMN actual_num REAL;`)
+	_, _ = DB.Exec(`ALTER TABLE violations ADD COLUMN unit TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE violations ADD COLUMN expected_bound TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE violations ADD COLUMN location_json TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE check_results ADD COLUMN summary TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE check_results ADD COLUMN fingerprint_json TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE branding ADD COLUMN gamification_enabled BOOLEAN DEFAULT FALSE;`)
+	_, _ = DB.Exec(`ALTER TABLE formatting_standards ADD COLUMN catalog_published BOOLEAN DEFAULT FALSE;`)
+	_, _ = DB.Exec(`ALTER TABLE formatting_standards ADD COLUMN catalog_publisher_key TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE users ADD COLUMN roster_managed BOOLEAN DEFAULT FALSE;`)
+	_, _ = DB.Exec(`ALTER TABLE users ADD COLUMN roster_external_id TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE users ADD COLUMN department TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE users ADD COLUMN coordinator BOOLEAN DEFAULT FALSE;`)
+	_, _ = DB.Exec(`ALTER TABLE assignments ADD COLUMN term_id INTEGER;`)
+	_, _ = DB.Exec(`ALTER TABLE check_results ADD COLUMN term_id INTEGER;`)
+	_, _ = DB.Exec(`ALTER TABLE documents ADD COLUMN schedule_id INTEGER;`)
+	_, _ = DB.Exec(`ALTER TABLE check_results ADD COLUMN verification_code TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE check_results ADD COLUMN standard_version_id INTEGER;`)
+	_, _ = DB.Exec(`ALTER TABLE documents ADD COLUMN student_id INTEGER;`)
+	_, _ = DB.Exec(`ALTER TABLE formatting_standards ADD COLUMN parent_standard_id INTEGER;`)
+	_, _ = DB.Exec(`ALTER TABLE formatting_standards ADD COLUMN parent_version INTEGER;`)
+	_, _ = DB.Exec(`ALTER TABLE assignments ADD COLUMN file_name_pattern TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE assignments ADD COLUMN file_name_example TEXT;`)
+	_, _ = DB.Exec(`ALTER TABLE assignments ADD COLUMN file_name_reject BOOLEAN DEFAULT FALSE;`)
+	for _, table := range []string{"documents", "check_results"} {
+		_, _ = DB.Exec(`ALTER TABLE ` + table + ` ADD COLUMN assignment_id INTEGER;`)
+		_, _ = DB.Exec(`ALTER TABLE ` + table + ` ADD COLUMN submitted_late BOOLEAN DEFAULT FALSE;`)
+		_, _ = DB.Exec(`ALTER TABLE ` + table + ` ADD COLUMN late_note TEXT;`)
+		for _, column := range []string{"topic", "supervisor", "group_name", "specialty_code"} {
+			_, _ = DB.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` TEXT;`)
+		}
+	}
+	_, _ = DB.Exec(`INSERT OR IGNORE INTO branding (id) VALUES (1);`)
+	normalizeTimestamps("documents", "upload_date")
+	normalizeTimestamps("check_results", "check_date")
+	for _, table := range []string{"users", "formatting_standards", "documents", "check_results"} {
+		ensureUUIDs(table)
+	}
+
+	// Indexes
+	_, _ = DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_standard_access_group ON standard_access(standard_id, group_id) WHERE group_id IS NOT NULL;`)
+	_, _ = DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_standard_access_user ON standard_access(standard_id, user_id) WHERE user_id IS NOT NULL;`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_standard_access_user_lookup ON standard_access(user_id);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_standard_access_group_lookup ON standard_access(group_id);`)
+	_, _ = DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_supervision_group ON supervision(supervisor_id, group_id) WHERE group_id IS NOT NULL;`)
+	_, _ = DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_supervision_student ON supervision(supervisor_id, student_id) WHERE student_id IS NOT NULL;`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_users_department ON users(department);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_documents_user_hash ON documents(user_id, content_hash);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_check_results_check_date ON check_results(check_date);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_impersonation_audit_admin ON impersonation_audit(admin_id, created_at);`)
+	// History: results of a document / of a standard, newest first, and the
+	// violations of a result.
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_check_results_document ON check_results(document_id, check_date, overall_score);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_check_results_standard ON check_results(standard_id, check_date, overall_score);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_formatting_standards_created_by ON formatting_standards(created_by);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_violations_result ON violations(result_id);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_users_roster_external_id ON users(roster_external_id);`)
+	// Stamped PDFs: the result a printed verification code belongs to.
+	_, _ = DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_check_results_verification_code ON check_results(verification_code);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_false_positive_reports_status ON false_positive_reports(status, rule_type);`)
+	// Scheduled batch checks: the documents of a batch and a user's unread
+	// notifications.
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_documents_schedule ON documents(schedule_id, status);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, is_read, created_at);`)
+	// Admin stats: counts and averages read from the index alone.
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_check_results_score ON check_results(overall_score);`)
+	// Leaderboards: the students of a group.
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_users_group ON users(group_id);`)
+	// Submissions: the assignments of a group for a standard.
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_assignments_group_standard ON assignments(group_id, standard_id);`)
+	// Term statistics and history filtered by term.
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_check_results_term ON check_results(term_id, check_date);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_assignments_term ON assignments(term_id);`)
+	// Learning resources are attached to violations by rule type.
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_learning_resources_rule ON learning_resources(rule_type);`)
+	// Teacher comments are shown with their result.
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_result_comments_result ON result_comments(result_id);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_feedback_templates_teacher ON feedback_templates(teacher_id);`)
+	_, _ = DB.Exec(`CREATE INDEX IF NOT EXISTS idx_vocabulary_dictionaries_teacher ON vocabulary_dictionaries(teacher_id);`)
 }
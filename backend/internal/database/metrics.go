@@ -0,0 +1,21 @@
+package database
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "db_query_duration_seconds",
+	Help:    "Time spent in the database queries of a handler, by query name.",
+	Buckets: prometheus.ExponentialBuckets(0.0005, 4, 8), // 0.5ms .. ~8s
+}, []string{"query"})
+
+// ObserveQuery records the time elapsed since start under name:
+//
+//	defer database.ObserveQuery("history", time.Now())
+func ObserveQuery(name string, start time.Time) {
+	queryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+}
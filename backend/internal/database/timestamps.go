@@ -0,0 +1,55 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// TimestampLayout is the format SQLite's CURRENT_TIMESTAMP uses. All
+// timestamps are stored in UTC in this format so that they compare and sort
+// correctly as text and work with SQLite's date functions.
+const TimestampLayout = "2006-01-02 15:04:05"
+
+const timestampGlob = "[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]"
+
+// Timestamp converts t to the stored UTC representation.
+func Timestamp(t time.Time) string {
+	return t.UTC().Format(TimestampLayout)
+}
+
+// FormatTimestamp renders a stored timestamp for API responses (RFC 3339 in UTC).
+func FormatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// normalizeTimestamps rewrites values that were written by the driver in its
+// own layout, often with a local offset, into UTC TimestampLayout.
+func normalizeTimestamps(table, column string) {
+	rows, err := DB.Query(fmt.Sprintf("SELECT id, %s FROM %s WHERE %s IS NOT NULL AND %s NOT GLOB ?", column, table, column, column), timestampGlob)
+	if err != nil {
+		log.Printf("Error normalizing %s.%s: %v", table, column, err)
+		return
+	}
+
+	stale := map[int64]time.Time{}
+	for rows.Next() {
+		var id int64
+		var t time.Time
+		if err := rows.Scan(&id, &t); err != nil {
+			continue
+		}
+		stale[id] = t
+	}
+	rows.Close()
+
+	for id, t := range stale {
+		_, _ = DB.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", table, column), Timestamp(t), id)
+	}
+	if len(stale) > 0 {
+		log.Printf("Normalized %d timestamps in %s.%s to UTC", len(stale), table, column)
+	}
+}
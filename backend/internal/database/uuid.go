@@ -0,0 +1,51 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// uuidSQL generates a random (version 4) UUID in SQLite.
+const uuidSQL = `lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+	substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + abs(random()) % 4, 1) ||
+	substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6)))`
+
+// ensureUUIDs gives every row of table a stable uuid that identifies it across
+// instances. Existing rows are backfilled and a trigger assigns one to rows
+// inserted without it, so insert statements do not need to know about it.
+func ensureUUIDs(table string) {
+	_, _ = DB.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN uuid TEXT;`, table))
+	if _, err := DB.Exec(fmt.Sprintf(`UPDATE %s SET uuid = %s WHERE uuid IS NULL;`, table, uuidSQL)); err != nil {
+		log.Printf("Error backfilling %s.uuid: %v", table, err)
+	}
+	_, _ = DB.Exec(fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS idx_%s_uuid ON %s(uuid);`, table, table))
+	_, err := DB.Exec(fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS trg_%s_uuid AFTER INSERT ON %s
+		WHEN NEW.uuid IS NULL
+		BEGIN
+			UPDATE %s SET uuid = %s WHERE id = NEW.id;
+		END;`, table, table, table, uuidSQL))
+	if err != nil {
+		log.Printf("Error creating uuid trigger for %s: %v", table, err)
+	}
+}
+
+// ResolveID returns the internal id of the row of table that ref identifies.
+// External APIs refer to rows by uuid; numeric ids are still accepted from
+// clients written before uuids were exposed.
+func ResolveID(table, ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return id, nil
+	}
+	var id int64
+	err := DB.QueryRow(fmt.Sprintf(`SELECT id FROM %s WHERE uuid = ?`, table), ref).Scan(&id)
+	return id, err
+}
+
+// UUIDOf returns the uuid of the row of table with the given id, or "" if the
+// row does not exist.
+func UUIDOf(table string, id int64) string {
+	var uuid string
+	_ = DB.QueryRow(fmt.Sprintf(`SELECT COALESCE(uuid, '') FROM %s WHERE id = ?`, table), id).Scan(&uuid)
+	return uuid
+}
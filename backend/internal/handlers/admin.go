@@ -1,8 +1,12 @@
 package handlers
 
 import (
-	"academic-check-sys/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/middleware"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,20 +21,29 @@ type AdminStats struct {
 	ChecksLabels   []string `json:"checks_labels"`
 	PassRateStats  []int    `json:"pass_rate_stats"` // [Passed, Failed]
 	AverageScore   float64  `json:"average_score"`
+	Timezone       string   `json:"timezone"` // zone of ChecksLabels
 }
 
+// GetAdminStats reports the overall statistics. ?term= limits the check
+// totals and the pass/fail distribution to one academic term.
 func GetAdminStats(c *gin.Context) {
-	// 1. Total Users
-	var totalUsers int
-	database.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&totalUsers)
-
-	// 2. Total Checks
-	var totalChecks int
-	database.DB.QueryRow("SELECT COUNT(*) FROM check_results").Scan(&totalChecks)
+	defer database.ObserveQuery("admin_stats", time.Now())
+	term, termArgs, ok := termFilter(c, "")
+	if !ok {
+		return
+	}
 
-	// 3. Pass Rate (Score >= 50)
-	var passedChecks int
-	database.DB.QueryRow("SELECT COUNT(*) FROM check_results WHERE overall_score >= 50").Scan(&passedChecks)
+	// 1-3, 6, 7. Totals, pass count (Score >= 50) and average score in one pass
+	var totalUsers, totalChecks, passedChecks, totalStandards int
+	var avgScore float64
+	database.DB.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM users),
+		       COUNT(*),
+		       COALESCE(SUM(overall_score >= 50), 0),
+		       COALESCE(AVG(overall_score), 0),
+		       (SELECT COUNT(*) FROM formatting_standards)
+		FROM check_results
+		WHERE 1=1`+term, termArgs...).Scan(&totalUsers, &totalChecks, &passedChecks, &avgScore, &totalStandards)
 
 	passRate := 0.0
 	if totalChecks > 0 {
@@ -38,38 +51,40 @@ func GetAdminStats(c *gin.Context) {
 	}
 
 	// 4. Activity (Last 7 days)
-	// Query real data per day
+	// Days are calendar days in the display time zone; check_date is stored in UTC,
+	// so the day boundaries are computed here and counted in a single query.
 	labels := []string{}
-	data := []int{}
+	data := make([]int, 7)
 
-	now := time.Now()
+	loc := displayLocation()
+	now := time.Now().In(loc)
+	columns := make([]string, 0, 7)
+	args := make([]interface{}, 0, 16)
+	var first, last time.Time
 	for i := 6; i >= 0; i-- {
-		day := now.AddDate(0, 0, -i)
+		start, end := dayBounds(now.AddDate(0, 0, -i), loc)
+		if i == 6 {
+			first = start
+		}
+		last = end
 		// Format Label: "30.01"
-		labels = append(labels, day.Format("02.01"))
-
-		// Count checks for this day
-		// SQLite date function: strftime('%Y-%m-%d', check_date)
-		dayStr := day.Format("2006-01-02")
-		var count int
-		// Note matching only the DATE part
-		database.DB.QueryRow("SELECT COUNT(*) FROM check_results WHERE date(check_date) = ?", dayStr).Scan(&count)
-		data = append(data, count)
+		labels = append(labels, start.Format("02.01"))
+		columns = append(columns, "COALESCE(SUM(check_date >= ? AND check_date < ?), 0)")
+		args = append(args, database.Timestamp(start), database.Timestamp(end))
+	}
+	args = append(args, database.Timestamp(first), database.Timestamp(last))
+	dest := make([]interface{}, len(data))
+	for i := range data {
+		dest[i] = &data[i]
 	}
+	database.DB.QueryRow("SELECT "+strings.Join(columns, ", ")+
+		" FROM check_results WHERE check_date >= ? AND check_date < ?", args...).Scan(dest...)
 
 	// 5. Pass/Fail Distribution
 	// [Passed, Failed]
 	failedChecks := totalChecks - passedChecks
 	passRateStats := []int{passedChecks, failedChecks}
 
-	// 6. Average Score
-	var avgScore float64
-	database.DB.QueryRow("SELECT COALESCE(AVG(overall_score), 0) FROM check_results").Scan(&avgScore)
-
-	// 7. Total Standards
-	var totalStandards int
-	database.DB.QueryRow("SELECT COUNT(*) FROM formatting_standards").Scan(&totalStandards)
-
 	c.JSON(http.StatusOK, AdminStats{
 		TotalUsers:     totalUsers,
 		TotalChecks:    totalChecks,
@@ -79,19 +94,23 @@ func GetAdminStats(c *gin.Context) {
 		ChecksLabels:   labels,
 		PassRateStats:  passRateStats,
 		AverageScore:   avgScore,
+		Timezone:       loc.String(),
 	})
 }
 
 type UserDTO struct {
-	ID       int    `json:"id"`
-	Email    string `json:"email"`
-	FullName string `json:"full_name"`
-	Role     string `json:"role"`
-	Status   string `json:"status"` // derived from is_active
+	ID          int    `json:"id"`
+	UUID        string `json:"uuid"`
+	Email       string `json:"email"`
+	FullName    string `json:"full_name"`
+	Role        string `json:"role"`
+	Status      string `json:"status"` // derived from is_active
+	Department  string `json:"department,omitempty"`
+	Coordinator bool   `json:"coordinator"`
 }
 
 func GetUsers(c *gin.Context) {
-	rows, err := database.DB.Query("SELECT id, email, full_name, role, is_active FROM users ORDER BY id DESC")
+	rows, err := database.DB.Query("SELECT id, COALESCE(uuid, ''), email, full_name, role, is_active, COALESCE(department, ''), COALESCE(coordinator, FALSE) FROM users ORDER BY id DESC")
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -102,7 +121,7 @@ func GetUsers(c *gin.Context) {
 	for rows.Next() {
 		var u UserDTO
 		var isActive bool
-		if err := rows.Scan(&u.ID, &u.Email, &u.FullName, &u.Role, &isActive); err != nil {
+		if err := rows.Scan(&u.ID, &u.UUID, &u.Email, &u.FullName, &u.Role, &isActive, &u.Department, &u.Coordinator); err != nil {
 			continue
 		}
 		if isActive {
@@ -117,28 +136,201 @@ func GetUsers(c *gin.Context) {
 }
 
 func DeleteUser(c *gin.Context) {
-	id := c.Param("id")
-	if id == "" {
+	if c.Param("id") == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID required"})
 		return
 	}
+	id, err := database.ResolveID("users", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
 
-	_, err := database.DB.Exec("DELETE FROM users WHERE id = ?", id)
+	_, err = database.DB.Exec("DELETE FROM users WHERE id = ?", id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	_, _ = database.DB.Exec("DELETE FROM supervision WHERE supervisor_id = ? OR student_id = ?", id, id)
+	// Standards show their author's name.
+	invalidateResponses(cacheStandards)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
 }
 
 // Optional: toggle active instead of delete
 func ToggleUserStatus(c *gin.Context) {
-	id := c.Param("id")
-	_, err := database.DB.Exec("UPDATE users SET is_active = NOT is_active WHERE id = ?", id)
+	id, err := database.ResolveID("users", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	_, err = database.DB.Exec("UPDATE users SET is_active = NOT is_active WHERE id = ?", id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "User status updated"})
 }
+
+// GetImpersonationLog returns the most recent impersonation audit entries,
+// optionally filtered by the impersonated user (?user=<id or uuid>).
+func GetImpersonationLog(c *gin.Context) {
+	query := `
+		SELECT a.id, a.admin_id, COALESCE(adm.email, ''), a.user_id, COALESCE(u.email, ''),
+		       a.action, COALESCE(a.detail, ''), COALESCE(a.ip, ''), a.created_at
+		FROM impersonation_audit a
+		LEFT JOIN users adm ON adm.id = a.admin_id
+		LEFT JOIN users u ON u.id = a.user_id`
+	args := []interface{}{}
+	if ref := c.Query("user"); ref != "" {
+		userID, err := database.ResolveID("users", ref)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		query += " WHERE a.user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY a.created_at DESC, a.id DESC LIMIT 500"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch impersonation log"})
+		return
+	}
+	defer rows.Close()
+
+	events := []models.ImpersonationEvent{}
+	for rows.Next() {
+		var e models.ImpersonationEvent
+		if err := rows.Scan(&e.ID, &e.AdminID, &e.AdminEmail, &e.UserID, &e.UserEmail, &e.Action, &e.Detail, &e.IP, &e.CreatedAt); err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+type RouteActivity struct {
+	Method       string  `json:"method"`
+	Route        string  `json:"route"`
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	Bytes        int64   `json:"bytes"`
+}
+
+type RoleActivity struct {
+	Role     string `json:"role"`
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"`
+}
+
+type UserActivity struct {
+	UserID   uint   `json:"user_id"`
+	UUID     string `json:"uuid"`
+	Email    string `json:"email"`
+	FullName string `json:"full_name"`
+	Role     string `json:"role"`
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"`
+	LastSeen string `json:"last_seen"` // start of the last hour with requests
+}
+
+type ActivityReport struct {
+	Days   int             `json:"days"`
+	Routes []RouteActivity `json:"routes"`
+	Roles  []RoleActivity  `json:"roles"`
+	Users  []UserActivity  `json:"users"`
+}
+
+// GetActivity reports API usage over the last ?days= days (default 7, up to
+// 90) from the request aggregates collected by middleware.AccessLog: the
+// busiest routes, requests per role and the most active users.
+func GetActivity(c *gin.Context) {
+	days := 7
+	if n, err := strconv.Atoi(c.Query("days")); err == nil && n > 0 && n <= 90 {
+		days = n
+	}
+	middleware.FlushAccessStats()
+	since := database.Timestamp(time.Now().Add(-time.Duration(days) * 24 * time.Hour))
+
+	report := ActivityReport{Days: days, Routes: []RouteActivity{}, Roles: []RoleActivity{}, Users: []UserActivity{}}
+
+	rows, err := database.DB.Query(`
+		SELECT method, route, SUM(requests), SUM(errors), SUM(latency_ms), SUM(bytes)
+		FROM request_stats
+		WHERE bucket >= ?
+		GROUP BY method, route
+		ORDER BY SUM(requests) DESC
+		LIMIT 50
+	`, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+		return
+	}
+	for rows.Next() {
+		var r RouteActivity
+		var latency float64
+		if err := rows.Scan(&r.Method, &r.Route, &r.Requests, &r.Errors, &latency, &r.Bytes); err != nil {
+			continue
+		}
+		if r.Requests > 0 {
+			r.AvgLatencyMs = latency / float64(r.Requests)
+		}
+		report.Routes = append(report.Routes, r)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		SELECT role, SUM(requests), SUM(errors)
+		FROM request_stats
+		WHERE bucket >= ?
+		GROUP BY role
+		ORDER BY SUM(requests) DESC
+	`, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+		return
+	}
+	for rows.Next() {
+		var r RoleActivity
+		if err := rows.Scan(&r.Role, &r.Requests, &r.Errors); err != nil {
+			continue
+		}
+		report.Roles = append(report.Roles, r)
+	}
+	rows.Close()
+
+	// MAX(bucket) loses the column type, so it is scanned as text.
+	rows, err = database.DB.Query(`
+		SELECT s.user_id, COALESCE(u.uuid, ''), COALESCE(u.email, ''), COALESCE(u.full_name, ''), COALESCE(u.role, ''),
+		       SUM(s.requests), SUM(s.errors), MAX(s.bucket)
+		FROM request_stats s
+		LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.bucket >= ? AND s.user_id != 0
+		GROUP BY s.user_id
+		ORDER BY SUM(s.requests) DESC
+		LIMIT 20
+	`, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+		return
+	}
+	for rows.Next() {
+		var u UserActivity
+		var lastSeen string
+		if err := rows.Scan(&u.UserID, &u.UUID, &u.Email, &u.FullName, &u.Role, &u.Requests, &u.Errors, &lastSeen); err != nil {
+			continue
+		}
+		if t, err := time.Parse(database.TimestampLayout, lastSeen); err == nil {
+			u.LastSeen = database.FormatTimestamp(t)
+		}
+		report.Users = append(report.Users, u)
+	}
+	rows.Close()
+
+	c.JSON(http.StatusOK, report)
+}
@@ -1,10 +1,10 @@
 package handlers
 
 import (
-	"academic-check-sys/internal/ai"
-	"academic-check-sys/internal/database"
-	"academic-check-sys/internal/models"
 	"database/sql"
+	"github.com/Ullyminat/NormoControl/backend/internal/ai"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
 	"net/http"
 	"os"
 	"strconv"
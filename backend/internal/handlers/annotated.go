@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// annotationAuthor signs the comments of annotated copies.
+const annotationAuthor = "Нормоконтроль"
+
+// GetAnnotatedDocument returns a copy of the checked document with a Word
+// comment on every violation of the result, anchored to the paragraph it was
+// found in, so the problems can be reviewed in context. Access is as for the
+// normocontrol report: the owner of the work, the author of the standard and
+// admins.
+func GetAnnotatedDocument(c *gin.Context) {
+	id, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	var resultID, ownerID, standardAuthor uint
+	var filePath, fileName string
+	err = database.DB.QueryRow(`
+		SELECT cr.id, d.file_path, d.file_name, d.user_id, COALESCE(s.created_by, 0)
+		FROM check_results cr
+		JOIN documents d ON cr.document_id = d.id
+		LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+		WHERE cr.id = ?
+	`, id).Scan(&resultID, &filePath, &fileName, &ownerID, &standardAuthor)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	if userID != ownerID && userID != standardAuthor && c.GetString("role") != "admin" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Stored file is no longer available"})
+		return
+	}
+
+	var buf bytes.Buffer
+	comments, err := checker.NewCheckService().Annotate(filePath, localizeViolations(c, loadViolations(resultID)), annotationAuthor, &buf)
+	if err != nil {
+		var tooComplex *checker.ComplexityError
+		switch {
+		case errors.Is(err, checker.ErrAnnotationUnsupported):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.As(err, &tooComplex):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": tooComplex.Error()})
+		default:
+			fmt.Printf("GetAnnotatedDocument: annotating result %d failed: %v\n", resultID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build the annotated document"})
+		}
+		return
+	}
+
+	name := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_annotated.docx"
+	c.Header("X-Annotation-Comments", strconv.Itoa(comments))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="annotated-%d.docx"; filename*=UTF-8''%s`, resultID, url.PathEscape(name)))
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", buf.Bytes())
+}
@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultLateNote is recorded on a late submission whose assignment has no
+// penalty note of its own.
+const defaultLateNote = "Работа сдана после срока"
+
+var (
+	errWindowNotOpen   = errors.New("Submission window is not open yet")
+	errDeadlinePassed  = errors.New("Submission deadline has passed")
+	errGracePeriodOver = errors.New("Submission deadline and grace period have passed")
+)
+
+// submission is the assignment a check was submitted to and whether it came
+// in late. It is kept on the document so a retried check records the same.
+type submission struct {
+	AssignmentID *uint
+	Late         bool
+	Note         string
+	Meta         models.WorkMetadata
+}
+
+// apply records the submission on a check result before it is saved.
+func (s submission) apply(r *models.CheckResult) {
+	r.AssignmentID, r.SubmittedLate, r.LateNote = s.AssignmentID, s.Late, s.Note
+	r.Metadata = s.Meta
+}
+
+type assignmentRequest struct {
+	Title       string     `json:"title" binding:"required"`
+	StandardID  string     `json:"standard_id" binding:"required"` // id or uuid
+	GroupID     uint       `json:"group_id" binding:"required"`
+	OpensAt     *time.Time `json:"opens_at"`
+	Deadline    time.Time  `json:"deadline" binding:"required"`
+	LatePolicy  string     `json:"late_policy"`
+	GraceHours  int        `json:"grace_hours"`
+	PenaltyNote string     `json:"penalty_note"`
+	TermID      *uint      `json:"term_id"` // defaults to the term of the deadline
+
+	FileNamePattern string `json:"file_name_pattern"`
+	FileNameExample string `json:"file_name_example"`
+	FileNameReject  bool   `json:"file_name_reject"`
+}
+
+// bindAssignment reads and validates an assignment from the request body,
+// writing the error response when it is invalid.
+func bindAssignment(c *gin.Context) (models.Assignment, bool) {
+	var req assignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.Assignment{}, false
+	}
+	a := models.Assignment{
+		Title:       strings.TrimSpace(req.Title),
+		GroupID:     req.GroupID,
+		OpensAt:     req.OpensAt,
+		Deadline:    req.Deadline,
+		LatePolicy:  req.LatePolicy,
+		GraceHours:  req.GraceHours,
+		PenaltyNote: strings.TrimSpace(req.PenaltyNote),
+
+		FileNamePattern: strings.TrimSpace(req.FileNamePattern),
+		FileNameExample: strings.TrimSpace(req.FileNameExample),
+		FileNameReject:  req.FileNameReject,
+	}
+	if a.LatePolicy == "" {
+		a.LatePolicy = models.LatePolicyHard
+	}
+	if a.LatePolicy != models.LatePolicyHard && a.LatePolicy != models.LatePolicyGrace {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "late_policy must be hard or grace"})
+		return a, false
+	}
+	if a.GraceHours < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "grace_hours must not be negative"})
+		return a, false
+	}
+	if a.OpensAt != nil && !a.OpensAt.Before(a.Deadline) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "opens_at must be before deadline"})
+		return a, false
+	}
+	if a.FileNamePattern != "" {
+		if _, err := (checker.FileNameConfig{Pattern: a.FileNamePattern}).Compile(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file_name_pattern: " + err.Error()})
+			return a, false
+		}
+	}
+
+	standardID, err := database.ResolveID("formatting_standards", req.StandardID)
+	if err == nil {
+		err = database.DB.QueryRow("SELECT id FROM formatting_standards WHERE id = ?", standardID).Scan(&a.StandardID)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Standard not found"})
+		return a, false
+	}
+	var exists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM student_groups WHERE id = ?", a.GroupID).Scan(&exists); err != nil || exists == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group not found"})
+		return a, false
+	}
+
+	a.TermID = req.TermID
+	if a.TermID == nil {
+		a.TermID = termAt(a.Deadline)
+	} else if _, err := loadTerm(uint64(*a.TermID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Academic term not found"})
+		return a, false
+	}
+	if termArchived(a.TermID) {
+		c.JSON(http.StatusConflict, gin.H{"error": errTermArchived.Error()})
+		return a, false
+	}
+	return a, true
+}
+
+// termArchived reports whether the term of an assignment or check is archived.
+func termArchived(termID *uint) bool {
+	if termID == nil {
+		return false
+	}
+	var archived bool
+	database.DB.QueryRow("SELECT COALESCE(archived, FALSE) FROM academic_terms WHERE id = ?", *termID).Scan(&archived)
+	return archived
+}
+
+const assignmentColumns = `id, title, standard_id, group_id, COALESCE(created_by, 0), opens_at, deadline,
+	late_policy, COALESCE(grace_hours, 0), COALESCE(penalty_note, ''), term_id,
+	COALESCE(file_name_pattern, ''), COALESCE(file_name_example, ''), COALESCE(file_name_reject, FALSE), created_at`
+
+// notArchived excludes the assignments of archived terms.
+const notArchived = " AND (term_id IS NULL OR term_id NOT IN (SELECT id FROM academic_terms WHERE archived))"
+
+func scanAssignment(row interface{ Scan(...interface{}) error }) (models.Assignment, error) {
+	var a models.Assignment
+	var opensAt sql.NullTime
+	var termID sql.NullInt64
+	err := row.Scan(&a.ID, &a.Title, &a.StandardID, &a.GroupID, &a.CreatedBy, &opensAt, &a.Deadline,
+		&a.LatePolicy, &a.GraceHours, &a.PenaltyNote, &termID,
+		&a.FileNamePattern, &a.FileNameExample, &a.FileNameReject, &a.CreatedAt)
+	if opensAt.Valid {
+		a.OpensAt = &opensAt.Time
+	}
+	if termID.Valid {
+		id := uint(termID.Int64)
+		a.TermID = &id
+	}
+	return a, err
+}
+
+func loadAssignment(id uint64) (models.Assignment, error) {
+	return scanAssignment(database.DB.QueryRow("SELECT "+assignmentColumns+" FROM assignments WHERE id = ?", id))
+}
+
+// GetAssignments lists assignments: students see those of their group,
+// teachers the ones they created, supervisors those of the groups they
+// follow and admins all of them. ?group_id= narrows the list for the staff,
+// ?term= to one academic term. Assignments of archived terms are listed only
+// when their term is asked for.
+func GetAssignments(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	query := "SELECT " + assignmentColumns + " FROM assignments WHERE 1=1"
+	var args []interface{}
+	switch c.GetString("role") {
+	case "student":
+		query += " AND group_id = (SELECT group_id FROM users WHERE id = ?)"
+		args = append(args, userID)
+	case "teacher":
+		query += " AND created_by = ?"
+		args = append(args, userID)
+	case "supervisor":
+		query += " AND group_id IN (SELECT group_id FROM supervision WHERE supervisor_id = ?)"
+		args = append(args, userID)
+	}
+	if c.GetString("role") != "student" && c.Query("group_id") != "" {
+		groupID, err := strconv.ParseUint(c.Query("group_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group_id"})
+			return
+		}
+		query += " AND group_id = ?"
+		args = append(args, groupID)
+	}
+	filter, filterArgs, ok := termFilter(c, "")
+	if !ok {
+		return
+	}
+	if filter == "" {
+		filter = notArchived
+	}
+	query += filter
+	args = append(args, filterArgs...)
+
+	rows, err := database.DB.Query(query+" ORDER BY deadline DESC, id DESC", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
+		return
+	}
+	defer rows.Close()
+
+	response := []models.Assignment{}
+	for rows.Next() {
+		a, err := scanAssignment(rows)
+		if err != nil {
+			continue
+		}
+		response = append(response, a)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func CreateAssignment(c *gin.Context) {
+	a, ok := bindAssignment(c)
+	if !ok {
+		return
+	}
+	var opensAt interface{}
+	if a.OpensAt != nil {
+		opensAt = database.Timestamp(*a.OpensAt)
+	}
+	res, err := database.DB.Exec(`INSERT INTO assignments (title, standard_id, group_id, created_by, opens_at, deadline, late_policy, grace_hours, penalty_note, term_id,
+		file_name_pattern, file_name_example, file_name_reject, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.Title, a.StandardID, a.GroupID, c.GetUint("user_id"), opensAt, database.Timestamp(a.Deadline),
+		a.LatePolicy, a.GraceHours, a.PenaltyNote, a.TermID, a.FileNamePattern, a.FileNameExample, a.FileNameReject, database.Timestamp(time.Now()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create assignment"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	created, _ := loadAssignment(uint64(id))
+	c.JSON(http.StatusCreated, created)
+}
+
+// ownAssignment loads the assignment of the :id parameter if the current user
+// may change it: its author or an admin, while its term is not archived.
+func ownAssignment(c *gin.Context) (models.Assignment, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	var a models.Assignment
+	if err == nil {
+		a, err = loadAssignment(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+		return a, false
+	}
+	if a.CreatedBy != c.GetUint("user_id") && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own assignments"})
+		return a, false
+	}
+	if termArchived(a.TermID) {
+		c.JSON(http.StatusConflict, gin.H{"error": errTermArchived.Error()})
+		return a, false
+	}
+	return a, true
+}
+
+func UpdateAssignment(c *gin.Context) {
+	existing, ok := ownAssignment(c)
+	if !ok {
+		return
+	}
+	a, ok := bindAssignment(c)
+	if !ok {
+		return
+	}
+	var opensAt interface{}
+	if a.OpensAt != nil {
+		opensAt = database.Timestamp(*a.OpensAt)
+	}
+	_, err := database.DB.Exec(`UPDATE assignments SET title = ?, standard_id = ?, group_id = ?, opens_at = ?, deadline = ?, late_policy = ?, grace_hours = ?, penalty_note = ?, term_id = ?,
+		file_name_pattern = ?, file_name_example = ?, file_name_reject = ?
+		WHERE id = ?`,
+		a.Title, a.StandardID, a.GroupID, opensAt, database.Timestamp(a.Deadline), a.LatePolicy, a.GraceHours, a.PenaltyNote, a.TermID,
+		a.FileNamePattern, a.FileNameExample, a.FileNameReject, existing.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update assignment"})
+		return
+	}
+	updated, _ := loadAssignment(uint64(existing.ID))
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteAssignment removes an assignment. Checks submitted to it keep their
+// late flag and note.
+func DeleteAssignment(c *gin.Context) {
+	a, ok := ownAssignment(c)
+	if !ok {
+		return
+	}
+	if _, err := database.DB.Exec("DELETE FROM assignments WHERE id = ?", a.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete assignment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Assignment deleted"})
+}
+
+// submissionWindow decides whether a submission at now is accepted by the
+// assignment and whether it counts as late.
+func submissionWindow(a models.Assignment, now time.Time) (submission, error) {
+	id := a.ID
+	s := submission{AssignmentID: &id}
+	if a.OpensAt != nil && now.Before(*a.OpensAt) {
+		return s, errWindowNotOpen
+	}
+	if !now.After(a.Deadline) {
+		return s, nil
+	}
+	switch a.LatePolicy {
+	case models.LatePolicyGrace:
+		if a.GraceHours > 0 && now.After(a.Deadline.Add(time.Duration(a.GraceHours)*time.Hour)) {
+			return s, errGracePeriodOver
+		}
+		s.Late, s.Note = true, a.PenaltyNote
+		if s.Note == "" {
+			s.Note = defaultLateNote
+		}
+		return s, nil
+	default:
+		return s, errDeadlinePassed
+	}
+}
+
+// resolveSubmission finds the assignment a student's upload against
+// standardID is submitted to and enforces its submission window, writing
+// the error response when the upload is rejected. The assignment is taken
+// from the assignment_id form field or, without it, from the assignments of
+// the student's group for the standard: the one with the earliest deadline
+// still accepting work. When none does, the upload is rejected with the
+// window of the latest one. Assignments of archived terms accept no work and
+// are not candidates. Uploads of teachers and admins and
+// of students without a matching assignment are not submissions.
+func resolveSubmission(c *gin.Context, userID uint, standardID int) (submission, bool) {
+	if c.GetString("role") != "student" {
+		return submission{}, true
+	}
+	now := time.Now().UTC()
+
+	var candidates []models.Assignment
+	if ref := c.PostForm("assignment_id"); ref != "" {
+		id, err := strconv.ParseUint(ref, 10, 64)
+		var a models.Assignment
+		if err == nil {
+			a, err = loadAssignment(id)
+		}
+		var groupID sql.NullInt64
+		database.DB.QueryRow("SELECT group_id FROM users WHERE id = ?", userID).Scan(&groupID)
+		if err != nil || !groupID.Valid || uint(groupID.Int64) != a.GroupID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Assignment not found"})
+			return submission{}, false
+		}
+		if int(a.StandardID) != standardID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The assignment requires a different standard"})
+			return submission{}, false
+		}
+		if termArchived(a.TermID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": errTermArchived.Error(), "assignment_id": a.ID})
+			return submission{}, false
+		}
+		candidates = append(candidates, a)
+	} else {
+		rows, err := database.DB.Query(`SELECT `+assignmentColumns+` FROM assignments
+			WHERE group_id = (SELECT group_id FROM users WHERE id = ?) AND standard_id = ?`+notArchived+`
+			ORDER BY deadline, id`, userID, standardID)
+		if err != nil {
+			fmt.Printf("resolveSubmission: failed to load assignments: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load assignments"})
+			return submission{}, false
+		}
+		defer rows.Close()
+		for rows.Next() {
+			if a, err := scanAssignment(rows); err == nil {
+				candidates = append(candidates, a)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return submission{}, true
+	}
+
+	var last models.Assignment
+	var lastErr error
+	for _, a := range candidates {
+		s, err := submissionWindow(a, now)
+		if err == nil {
+			return s, true
+		}
+		last, lastErr = a, err
+	}
+
+	resp := gin.H{"error": lastErr.Error(), "assignment_id": last.ID, "deadline": database.FormatTimestamp(last.Deadline)}
+	if last.OpensAt != nil {
+		resp["opens_at"] = database.FormatTimestamp(*last.OpensAt)
+	}
+	c.JSON(http.StatusForbidden, resp)
+	return submission{}, false
+}
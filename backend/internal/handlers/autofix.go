@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type autoFixRequest struct {
+	Rules []string `json:"rules"`
+}
+
+// AutoFixCheck returns a corrected copy of a checked document: the
+// deterministic violations of the rules opted into (margins, font name and
+// size, line spacing, first-line indent, alignment — all of them when the
+// list is empty) are fixed in word/document.xml and the DOCX is sent as a
+// download. The number of fixes per rule is reported in the X-Autofix-Fixed
+// header. Nothing is stored; the student uploads the corrected file to check
+// it again.
+func AutoFixCheck(c *gin.Context) {
+	id, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	var req autoFixRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := checker.ValidateFixRules(req.Rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var docID int64
+	var fileName string
+	var ownerID, standardAuthor uint
+	err = database.DB.QueryRow(`
+		SELECT cr.document_id, d.file_name, d.user_id, COALESCE(s.created_by, 0)
+		FROM check_results cr
+		JOIN documents d ON cr.document_id = d.id
+		LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+		WHERE cr.id = ?
+	`, id).Scan(&docID, &fileName, &ownerID, &standardAuthor)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	if userID != ownerID && userID != standardAuthor && c.GetString("role") != "admin" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	p, doc, err := loadDocumentPipeline(docID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+	if _, err := os.Stat(doc.FilePath); err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Stored file is no longer available"})
+		return
+	}
+
+	var buf bytes.Buffer
+	svc := checker.NewCheckService()
+	svc.Defaults = checkDefaults()
+	report, err := svc.AutoFix(c.Request.Context(), p.FilePath, p.ContentHash, p.ConfigJSON, req.Rules, &buf)
+	if err != nil {
+		var tooComplex *checker.ComplexityError
+		switch {
+		case errors.Is(err, checker.ErrAutoFixUnsupported):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.As(err, &tooComplex):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": tooComplex.Error()})
+		default:
+			fmt.Printf("AutoFixCheck: correcting result %d failed: %v\n", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Automatic correction failed: %v", err)})
+		}
+		return
+	}
+
+	var counts []string
+	for rule, n := range report.Fixed {
+		counts = append(counts, fmt.Sprintf("%s=%d", rule, n))
+	}
+	sort.Strings(counts)
+	name := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_fixed.docx"
+	c.Header("X-Autofix-Fixed", strings.Join(counts, ","))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="fixed-%d.docx"; filename*=UTF-8''%s`, id, url.PathEscape(name)))
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.wordprocessingml.document", buf.Bytes())
+}
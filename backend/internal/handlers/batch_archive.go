@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/Ullyminat/NormoControl/backend/internal/archive"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// archiveTimeout bounds unpacking one uploaded archive.
+const archiveTimeout = 2 * time.Minute
+
+// unpackUpload saves an uploaded archive to dir and unpacks its documents
+// into a directory next to it.
+func unpackUpload(c *gin.Context, file *multipart.FileHeader, dir string) (archive.Result, error) {
+	src := filepath.Join(dir, "upload"+strings.ToLower(filepath.Ext(file.Filename)))
+	if err := c.SaveUploadedFile(file, src); err != nil {
+		return archive.Result{}, err
+	}
+	out := filepath.Join(dir, "files")
+	if err := os.Mkdir(out, 0700); err != nil {
+		return archive.Result{}, err
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), archiveTimeout)
+	defer cancel()
+	return archive.Extract(ctx, file.Filename, src, out, archive.DefaultLimits)
+}
+
+// storeUnpacked copies an unpacked document to path and returns the hash of
+// its content.
+func storeUnpacked(src, path string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// submissionName is what the name of a submitted file says about its
+// author, e.g. "Иванов_И_И_ИВТ-21.docx" or "Петров И.С. ПИ-22 курсовая.docx".
+type submissionName struct {
+	Surname  string
+	Initials []rune
+	Group    string
+}
+
+// Hyphens are not separators: they belong to group names such as ИВТ-21 and
+// to double surnames.
+var submissionSeparators = regexp.MustCompile(`[_\s]+`)
+
+// parseSubmissionName reads the surname, initials and group from a file
+// name. ok is false when the name does not start with a surname.
+func parseSubmissionName(fileName string) (name submissionName, ok bool) {
+	base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	tokens := submissionSeparators.Split(strings.TrimSpace(base), -1)
+	surname, rest := tokens[0], tokens[1:]
+	// "Иванов.И.И" keeps the initials in the first token.
+	if i := strings.IndexByte(surname, '.'); i > 0 {
+		rest = append([]string{surname[i:]}, rest...)
+		surname = surname[:i]
+	}
+	if utf8.RuneCountInString(surname) < 2 || strings.IndexFunc(surname, func(r rune) bool { return !unicode.IsLetter(r) && r != '-' }) >= 0 {
+		return name, false
+	}
+	name.Surname = surname
+
+	for len(rest) > 0 && len(name.Initials) < 2 {
+		initials, ok := parseInitials(rest[0])
+		if !ok || len(name.Initials)+len(initials) > 2 {
+			break
+		}
+		name.Initials = append(name.Initials, initials...)
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		name.Group = rest[0]
+	}
+	return name, true
+}
+
+// parseInitials reads a token of one or two initials: "И", "И.", "И.И." or
+// "ИИ".
+func parseInitials(token string) ([]rune, bool) {
+	var initials []rune
+	for _, part := range strings.Split(token, ".") {
+		if part == "" {
+			continue
+		}
+		runes := []rune(part)
+		if len(runes) > 2 || (len(runes) == 2 && !strings.Contains(token, ".") && !unicode.IsUpper(runes[1])) {
+			return nil, false
+		}
+		for _, r := range runes {
+			if !unicode.IsLetter(r) {
+				return nil, false
+			}
+		}
+		initials = append(initials, runes...)
+	}
+	return initials, len(initials) > 0 && len(initials) <= 2
+}
+
+// normalizeName folds case and ё so that names compare as people write them.
+func normalizeName(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+}
+
+// normalizeGroup also drops the separators people vary in group names:
+// "ИВТ-21", "ивт 21" and "ИВТ_21" are the same group.
+func normalizeGroup(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, normalizeName(s))
+}
+
+// rosterStudent is an active student as matched against file names.
+type rosterStudent struct {
+	id    uint
+	name  string
+	words []string // normalized words of the full name
+	group string   // normalized group name
+}
+
+// studentMatcher maps file names to the students of the roster.
+type studentMatcher struct {
+	students []rosterStudent
+	groups   map[string]bool
+}
+
+// loadStudentMatcher reads the active students with their groups.
+func loadStudentMatcher() (*studentMatcher, error) {
+	rows, err := database.DB.Query(`
+		SELECT u.id, COALESCE(u.full_name, ''), COALESCE(g.group_name, '')
+		FROM users u
+		LEFT JOIN student_groups g ON g.id = u.group_id
+		WHERE u.role = 'student' AND COALESCE(u.is_active, TRUE)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := &studentMatcher{groups: map[string]bool{}}
+	for rows.Next() {
+		var id uint
+		var name, group string
+		if err := rows.Scan(&id, &name, &group); err != nil {
+			return nil, err
+		}
+		m.add(id, name, group)
+	}
+	return m, rows.Err()
+}
+
+// add puts a student of the roster in the matcher; one without a name is
+// left out.
+func (m *studentMatcher) add(id uint, name, group string) {
+	s := rosterStudent{id: id, name: name, group: normalizeGroup(group)}
+	s.words = strings.FieldsFunc(normalizeName(name), func(r rune) bool { return r == '.' || unicode.IsSpace(r) })
+	if len(s.words) == 0 {
+		return
+	}
+	if s.group != "" {
+		m.groups[s.group] = true
+	}
+	m.students = append(m.students, s)
+}
+
+// match returns the only student a file name fits: the surname and the given
+// initials must match, and so must the group when the name holds a known
+// one. ok is false when no student or several fit.
+func (m *studentMatcher) match(fileName string) (id uint, name string, ok bool) {
+	sub, ok := parseSubmissionName(fileName)
+	if !ok {
+		return 0, "", false
+	}
+	surname := normalizeName(sub.Surname)
+	group := normalizeGroup(sub.Group)
+	if !m.groups[group] {
+		group = ""
+	}
+
+	var found *rosterStudent
+	for i := range m.students {
+		s := &m.students[i]
+		if s.words[0] != surname || (group != "" && s.group != group) {
+			continue
+		}
+		if len(sub.Initials) > len(s.words)-1 {
+			continue
+		}
+		fits := true
+		for j, initial := range sub.Initials {
+			first, _ := utf8.DecodeRuneInString(s.words[j+1])
+			if first != []rune(normalizeName(string(initial)))[0] {
+				fits = false
+				break
+			}
+		}
+		if !fits {
+			continue
+		}
+		if found != nil {
+			return 0, "", false
+		}
+		found = s
+	}
+	if found == nil {
+		return 0, "", false
+	}
+	return found.id, found.name, true
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSubmissionName(t *testing.T) {
+	for _, tc := range []struct {
+		file     string
+		surname  string
+		initials string
+		group    string
+		ok       bool
+	}{
+		{"Иванов_И_И_ИВТ-21.docx", "Иванов", "ИИ", "ИВТ-21", true},
+		{"Петров И.С. ПИ-22 курсовая.docx", "Петров", "ИС", "ПИ-22", true},
+		{"Иванов.И.И.docx", "Иванов", "ИИ", "", true},
+		{"Иванов.И.И._ИВТ-21.odt", "Иванов", "ИИ", "ИВТ-21", true},
+		{"Сидорова-Петрова АВ ИВТ-21.odt", "Сидорова-Петрова", "АВ", "ИВТ-21", true},
+		{"  Кузнецов  .docx", "Кузнецов", "", "", true},
+		{"Ли_А.pptx", "Ли", "А", "", true},
+		{"Иванов_И_С_Т.docx", "Иванов", "ИС", "Т", true},
+		{"Иванов И.И.И ИВТ-21.docx", "Иванов", "", "И.И.И", true},
+		// A first name is not initials; the word is read as a group.
+		{"Кузнецов_Андрей_ИВТ-21.docx", "Кузнецов", "", "Андрей", true},
+		{"Иванов_Ив_ИВТ-21.docx", "Иванов", "", "Ив", true},
+		{"1_Иванов.docx", "", "", "", false},
+		{"Иванов2_И.docx", "", "", "", false},
+		{"Я.docx", "", "", "", false},
+		{"___.docx", "", "", "", false},
+		{".docx", "", "", "", false},
+		{"", "", "", "", false},
+	} {
+		name, ok := parseSubmissionName(tc.file)
+		if ok != tc.ok {
+			t.Errorf("parseSubmissionName(%q) ok = %v, want %v", tc.file, ok, tc.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if name.Surname != tc.surname || string(name.Initials) != tc.initials || name.Group != tc.group {
+			t.Errorf("parseSubmissionName(%q) = %q %q %q, want %q %q %q",
+				tc.file, name.Surname, string(name.Initials), name.Group, tc.surname, tc.initials, tc.group)
+		}
+	}
+}
+
+func TestStudentMatcher(t *testing.T) {
+	m := &studentMatcher{groups: map[string]bool{}}
+	m.add(1, "Иванов Иван Иванович", "ИВТ-21")
+	m.add(2, "Иванов Игорь Сергеевич", "ПИ-22")
+	m.add(3, "Петров Сергей Алексеевич", "ИВТ-21")
+	m.add(4, "Ёлкина Анна", "ивт 21")
+	m.add(5, "Смирнов Олег Петрович", "")
+	m.add(6, "Смирнов Олег Павлович", "")
+	m.add(7, "  ", "ИВТ-21")
+
+	for _, tc := range []struct {
+		file string
+		id   uint
+	}{
+		{"Иванов_И_И_ИВТ-21.docx", 1},
+		{"иванов и.с..docx", 2},
+		{"Иванов_ИВТ-21.docx", 1},
+		{"Иванов ПИ_22.docx", 0}, // "ПИ" is read as initials
+		{"Иванов_И_ПИ-22.docx", 2},
+		{"Елкина_А.docx", 4},
+		{"ЁЛКИНА А ИВТ21.docx", 4},
+		{"Петров_С_А_ПИ-99.docx", 3}, // unknown groups are ignored
+		{"Иванов_И_С_Т.docx", 2},
+		// Ambiguous: two students fit.
+		{"Иванов.docx", 0},
+		{"Иванов_И.docx", 0},
+		{"Смирнов_О_П.docx", 0},
+		// Unmatched.
+		{"Петров_С_А_ПИ-22.docx", 0},
+		{"Петров_А.docx", 0},
+		{"Иванов_И_И_ПИ-22.docx", 0},
+		{"Сидоров_С_С.docx", 0},
+		{"Отчёт.docx", 0},
+		{"123.docx", 0},
+	} {
+		id, name, ok := m.match(tc.file)
+		if ok != (tc.id != 0) || id != tc.id {
+			t.Errorf("match(%q) = %d %q %v, want %d", tc.file, id, name, ok, tc.id)
+		}
+	}
+	if got := m.groups; !reflect.DeepEqual(got, map[string]bool{"ивт21": true, "пи22": true}) {
+		t.Errorf("groups = %v", got)
+	}
+}
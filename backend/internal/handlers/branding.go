@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// brandingLogoMaxBytes bounds the uploaded logo; it is shown in reports only.
+const brandingLogoMaxBytes = 1 << 20
+
+// brandingLogoTypes are the accepted logo formats. SVG is not accepted: it is
+// served from the API origin and could carry scripts.
+var brandingLogoTypes = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".webp": true}
+
+func loadBranding() (models.Branding, string, error) {
+	var b models.Branding
+	var logoPath string
+	err := database.DB.QueryRow(`
+		SELECT COALESCE(institution_name, ''), COALESCE(logo_path, ''), COALESCE(report_header, ''), COALESCE(report_footer, ''),
+		       COALESCE(gamification_enabled, FALSE)
+		FROM branding WHERE id = 1
+	`).Scan(&b.InstitutionName, &logoPath, &b.ReportHeader, &b.ReportFooter, &b.GamificationEnabled)
+	if logoPath != "" {
+		b.LogoURL = "/api/uploads/" + filepath.Base(logoPath)
+	}
+	return b, logoPath, err
+}
+
+// GetBranding returns the institution name, logo and report texts.
+func GetBranding(c *gin.Context) {
+	serveCached(c, cacheBranding, "", func() (interface{}, error) {
+		b, _, err := loadBranding()
+		if err != nil {
+			return nil, errors.New("Failed to load branding")
+		}
+		return b, nil
+	})
+}
+
+type BrandingRequest struct {
+	InstitutionName string `json:"institution_name"`
+	ReportHeader    string `json:"report_header"`
+	ReportFooter    string `json:"report_footer"`
+}
+
+// UpdateBranding replaces the branding texts. The logo is managed separately.
+func UpdateBranding(c *gin.Context) {
+	var req BrandingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.InstitutionName = strings.TrimSpace(req.InstitutionName)
+	req.ReportHeader = strings.TrimSpace(req.ReportHeader)
+	req.ReportFooter = strings.TrimSpace(req.ReportFooter)
+	if utf8.RuneCountInString(req.InstitutionName) > 200 ||
+		utf8.RuneCountInString(req.ReportHeader) > 1000 || utf8.RuneCountInString(req.ReportFooter) > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Branding text is too long"})
+		return
+	}
+
+	_, err := database.DB.Exec("UPDATE branding SET institution_name = ?, report_header = ?, report_footer = ?, updated_at = ? WHERE id = 1",
+		req.InstitutionName, req.ReportHeader, req.ReportFooter, database.Timestamp(time.Now()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update branding"})
+		return
+	}
+	invalidateResponses(cacheBranding)
+	GetBranding(c)
+}
+
+// UploadBrandingLogo stores a new logo and removes the previous one.
+func UploadBrandingLogo(c *gin.Context) {
+	file, err := c.FormFile("logo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !brandingLogoTypes[ext] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Logo must be a PNG, JPEG or WebP image"})
+		return
+	}
+	if file.Size > brandingLogoMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Logo is larger than 1 MiB"})
+		return
+	}
+
+	uploadDir := "./uploads"
+	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
+		os.Mkdir(uploadDir, 0755)
+	}
+	// A new name per upload so browsers do not show a cached old logo.
+	savePath := filepath.Join(uploadDir, fmt.Sprintf("branding_logo_%d%s", time.Now().UnixNano(), ext))
+	if err := c.SaveUploadedFile(file, savePath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+
+	_, oldPath, _ := loadBranding()
+	if _, err := database.DB.Exec("UPDATE branding SET logo_path = ?, updated_at = ? WHERE id = 1", savePath, database.Timestamp(time.Now())); err != nil {
+		os.Remove(savePath)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update branding"})
+		return
+	}
+	invalidateResponses(cacheBranding)
+	if oldPath != "" {
+		os.Remove(oldPath)
+	}
+	GetBranding(c)
+}
+
+// DeleteBrandingLogo removes the logo from reports.
+func DeleteBrandingLogo(c *gin.Context) {
+	_, oldPath, _ := loadBranding()
+	if _, err := database.DB.Exec("UPDATE branding SET logo_path = NULL, updated_at = ? WHERE id = 1", database.Timestamp(time.Now())); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update branding"})
+		return
+	}
+	invalidateResponses(cacheBranding)
+	if oldPath != "" {
+		os.Remove(oldPath)
+	}
+	GetBranding(c)
+}
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// catalogEntryVersion is the format version of catalog entries.
+const catalogEntryVersion = 1
+
+// catalogMaxBytes bounds a catalog response read from the registry.
+const catalogMaxBytes = 8 << 20
+
+// catalogEnvelope is a standard published to the shared catalog: the entry and
+// its Ed25519 signature made with the publishing deployment's key. Entries
+// keep their signature wherever they are copied, so a registry does not have
+// to be trusted, only the publishers' keys. The entry is the signed JSON text
+// of a catalogStandard, kept as a string so that re-encoding it on the way
+// does not break the signature.
+type catalogEnvelope struct {
+	Entry     string `json:"entry"`
+	PublicKey string `json:"public_key"` // base64 Ed25519 public key
+	Signature string `json:"signature"`  // "ed25519:" + base64
+}
+
+// catalogStandard is the signed entry. The uuid is that of the standard on
+// the publishing deployment; republishing it replaces the earlier version.
+type catalogStandard struct {
+	Version      int    `json:"version"`
+	UUID         string `json:"uuid"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	DocumentType string `json:"document_type"`
+	ModulesJSON  string `json:"modules_json"`
+	Publisher    string `json:"publisher"`
+	PublishedAt  string `json:"published_at"`
+}
+
+// catalogItem is an entry of the registry as shown to admins.
+type catalogItem struct {
+	catalogStandard
+	PublicKey string `json:"public_key"`
+	Verified  bool   `json:"verified"` // the signature matches the entry
+	Trusted   bool   `json:"trusted"`  // the key is trusted, so the entry can be imported
+	Imported  bool   `json:"imported"` // a standard with this uuid exists here
+	Error     string `json:"error,omitempty"`
+}
+
+var catalogClient = &http.Client{Timeout: 15 * time.Second}
+
+// catalogURL is the central registry (CATALOG_URL); empty disables browsing
+// and pushing to it.
+func catalogURL() string {
+	return strings.TrimRight(strings.TrimSpace(os.Getenv("CATALOG_URL")), "/")
+}
+
+// catalogSigningKey returns the key this deployment signs its entries with,
+// given as a base64 32-byte seed in CATALOG_SIGNING_KEY, or nil when
+// publishing is not configured.
+func catalogSigningKey() (ed25519.PrivateKey, error) {
+	value := strings.TrimSpace(os.Getenv("CATALOG_SIGNING_KEY"))
+	if value == "" {
+		return nil, nil
+	}
+	seed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("CATALOG_SIGNING_KEY must be a base64 %d-byte seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// catalogTrustedKeys are the publishers whose entries may be imported: the
+// base64 public keys listed in CATALOG_TRUSTED_KEYS and this deployment's own.
+func catalogTrustedKeys() map[string]bool {
+	keys := map[string]bool{}
+	for _, k := range strings.Split(os.Getenv("CATALOG_TRUSTED_KEYS"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys[k] = true
+		}
+	}
+	if key, err := catalogSigningKey(); err == nil && key != nil {
+		keys[base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))] = true
+	}
+	return keys
+}
+
+func signCatalogEntry(key ed25519.PrivateKey, entry []byte) catalogEnvelope {
+	return catalogEnvelope{
+		Entry:     string(entry),
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: "ed25519:" + base64.StdEncoding.EncodeToString(ed25519.Sign(key, entry)),
+	}
+}
+
+// verifyCatalogEntry checks the signature of env and decodes its entry.
+func verifyCatalogEntry(env catalogEnvelope) (catalogStandard, error) {
+	var entry catalogStandard
+	publicKey, err := base64.StdEncoding.DecodeString(env.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return entry, errors.New("invalid public key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(env.Signature, "ed25519:"))
+	if err != nil || !strings.HasPrefix(env.Signature, "ed25519:") {
+		return entry, errors.New("invalid signature format")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(publicKey), []byte(env.Entry), signature) {
+		return entry, errors.New("signature does not match the entry")
+	}
+	if err := json.Unmarshal([]byte(env.Entry), &entry); err != nil {
+		return entry, fmt.Errorf("invalid entry: %v", err)
+	}
+	if entry.Version != catalogEntryVersion {
+		return entry, fmt.Errorf("unsupported entry version %d", entry.Version)
+	}
+	if entry.UUID == "" || strings.TrimSpace(entry.Name) == "" || !json.Valid([]byte(entry.ModulesJSON)) {
+		return entry, errors.New("entry has no uuid, name or valid modules")
+	}
+	return entry, nil
+}
+
+// publishedCatalogEntries signs the standards marked for the catalog.
+func publishedCatalogEntries(key ed25519.PrivateKey, where string, args ...interface{}) ([]catalogEnvelope, error) {
+	branding, _, _ := loadBranding()
+	rows, err := database.DB.Query(`
+		SELECT COALESCE(uuid, ''), name, COALESCE(description, ''), COALESCE(document_type, ''), COALESCE(modules_json, '[]'), updated_at
+		FROM formatting_standards
+		WHERE catalog_published`+where+`
+		ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	envelopes := []catalogEnvelope{}
+	for rows.Next() {
+		entry := catalogStandard{Version: catalogEntryVersion, Publisher: branding.InstitutionName}
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&entry.UUID, &entry.Name, &entry.Description, &entry.DocumentType, &entry.ModulesJSON, &updatedAt); err != nil {
+			return nil, err
+		}
+		entry.PublishedAt = database.FormatTimestamp(updatedAt.Time)
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		envelopes = append(envelopes, signCatalogEntry(key, data))
+	}
+	return envelopes, rows.Err()
+}
+
+// GetCatalogEntries is the federation endpoint: the signed entries of the
+// standards this deployment publishes. A registry collects them from here,
+// and a deployment can point CATALOG_URL at another one's /api/catalog to
+// share standards without a registry. It is disabled without a signing key.
+func GetCatalogEntries(c *gin.Context) {
+	key, err := catalogSigningKey()
+	if err != nil || key == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This deployment does not publish standards"})
+		return
+	}
+	envelopes, err := publishedCatalogEntries(key, "")
+	if err != nil {
+		fmt.Printf("GetCatalogEntries: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load published standards"})
+		return
+	}
+	c.JSON(http.StatusOK, envelopes)
+}
+
+// PublishCatalogStandard adds a standard to the catalog of this deployment or
+// withdraws it ({"published": false}). With CATALOG_URL set, a published entry
+// is also pushed to the registry; a failed push is reported but keeps the
+// standard published, so the registry can still collect it.
+func PublishCatalogStandard(c *gin.Context) {
+	key, err := catalogSigningKey()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if key == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Catalog publishing is not configured (missing CATALOG_SIGNING_KEY)"})
+		return
+	}
+	id, err := database.ResolveID("formatting_standards", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+		return
+	}
+	var req struct {
+		Published bool `json:"published"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	res, err := database.DB.Exec("UPDATE formatting_standards SET catalog_published = ? WHERE id = ?", req.Published, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update standard"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+		return
+	}
+	resp := gin.H{"id": id, "published": req.Published}
+	if !req.Published {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	envelopes, err := publishedCatalogEntries(key, " AND id = ?", id)
+	if err != nil || len(envelopes) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign standard"})
+		return
+	}
+	resp["entry"] = envelopes[0]
+	if registry := catalogURL(); registry != "" {
+		if err := pushCatalogEntry(registry, envelopes[0]); err != nil {
+			fmt.Printf("PublishCatalogStandard: push of standard %d failed: %v\n", id, err)
+			resp["push_error"] = err.Error()
+		} else {
+			resp["pushed"] = true
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// pushCatalogEntry posts env to the registry, authenticated with
+// CATALOG_TOKEN when the registry requires it.
+func pushCatalogEntry(registry string, env catalogEnvelope) error {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, registry+"/entries", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := strings.TrimSpace(os.Getenv("CATALOG_TOKEN")); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := catalogClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("registry answered %s", resp.Status)
+	}
+	return nil
+}
+
+// fetchCatalog reads all entries of the registry.
+func fetchCatalog(registry string) ([]catalogEnvelope, error) {
+	resp, err := catalogClient.Get(registry + "/entries")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry answered %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, catalogMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > catalogMaxBytes {
+		return nil, errors.New("registry response is too large")
+	}
+	var envelopes []catalogEnvelope
+	if err := json.Unmarshal(data, &envelopes); err != nil {
+		return nil, fmt.Errorf("invalid registry response: %v", err)
+	}
+	return envelopes, nil
+}
+
+// GetCatalog lists the registry's standards with the result of the signature
+// check, whether the publisher is trusted and whether the standard is already
+// imported.
+func GetCatalog(c *gin.Context) {
+	registry := catalogURL()
+	if registry == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Catalog is not configured (missing CATALOG_URL)"})
+		return
+	}
+	envelopes, err := fetchCatalog(registry)
+	if err != nil {
+		fmt.Printf("GetCatalog: %v\n", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load catalog: " + err.Error()})
+		return
+	}
+
+	trusted := catalogTrustedKeys()
+	items := make([]catalogItem, 0, len(envelopes))
+	for _, env := range envelopes {
+		entry, err := verifyCatalogEntry(env)
+		item := catalogItem{catalogStandard: entry, PublicKey: env.PublicKey, Verified: err == nil}
+		if err != nil {
+			item.Error = err.Error()
+		} else {
+			item.Trusted = trusted[env.PublicKey]
+			var exists int
+			item.Imported = database.DB.QueryRow("SELECT 1 FROM formatting_standards WHERE uuid = ?", entry.UUID).Scan(&exists) == nil
+		}
+		items = append(items, item)
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// ImportCatalogStandard imports a standard from the registry ({"uuid": ...})
+// or from a signed entry file such as a vetted ГОСТ 7.32 pack ({"entry":
+// {...}}). The signature must verify against a trusted key. A standard
+// imported earlier from the same publisher is updated to the new version; a
+// local standard with the same uuid from anywhere else is kept and reported
+// as a conflict.
+func ImportCatalogStandard(c *gin.Context) {
+	var req struct {
+		UUID  string           `json:"uuid"`
+		Entry *catalogEnvelope `json:"entry"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.UUID == "" && req.Entry == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide the uuid of a catalog standard or a signed entry"})
+		return
+	}
+
+	env := req.Entry
+	if env == nil {
+		registry := catalogURL()
+		if registry == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Catalog is not configured (missing CATALOG_URL)"})
+			return
+		}
+		envelopes, err := fetchCatalog(registry)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load catalog: " + err.Error()})
+			return
+		}
+		for i := range envelopes {
+			if entry, err := verifyCatalogEntry(envelopes[i]); err == nil && entry.UUID == req.UUID {
+				env = &envelopes[i]
+				break
+			}
+		}
+		if env == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found in the catalog"})
+			return
+		}
+	}
+
+	entry, err := verifyCatalogEntry(*env)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Entry signature is invalid: " + err.Error()})
+		return
+	}
+	if !catalogTrustedKeys()[env.PublicKey] {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The publisher's key is not trusted (add it to CATALOG_TRUSTED_KEYS)", "public_key": env.PublicKey})
+		return
+	}
+
+	now := database.Timestamp(time.Now())
+	var id int64
+	var publisherKey sql.NullString
+	err = database.DB.QueryRow("SELECT id, catalog_publisher_key FROM formatting_standards WHERE uuid = ?", entry.UUID).Scan(&id, &publisherKey)
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := database.DB.Exec(`INSERT INTO formatting_standards (uuid, name, description, created_by, document_type, is_public, modules_json, catalog_publisher_key, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, TRUE, ?, ?, ?, ?)`,
+			entry.UUID, entry.Name, entry.Description, c.GetUint("user_id"), entry.DocumentType, entry.ModulesJSON, env.PublicKey, now, now)
+		if err != nil {
+			fmt.Printf("ImportCatalogStandard: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import standard"})
+			return
+		}
+		id, _ = res.LastInsertId()
+		invalidateResponses(cacheStandards)
+		c.JSON(http.StatusCreated, gin.H{"id": id, "uuid": entry.UUID, "name": entry.Name, "status": "created"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	case publisherKey.String != env.PublicKey:
+		c.JSON(http.StatusConflict, gin.H{"error": "A local standard with this uuid exists and was not imported from this publisher; it was kept", "id": id})
+	default:
+		if _, err := database.DB.Exec("UPDATE formatting_standards SET name = ?, description = ?, document_type = ?, modules_json = ?, updated_at = ? WHERE id = ?",
+			entry.Name, entry.Description, entry.DocumentType, entry.ModulesJSON, now, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update standard"})
+			return
+		}
+		invalidateResponses(cacheStandards)
+		c.JSON(http.StatusOK, gin.H{"id": id, "uuid": entry.UUID, "name": entry.Name, "status": "updated"})
+	}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testCatalogKey derives a signing key from a one-byte seed, and returns it
+// with its base64 public key and seed.
+func testCatalogKey(b byte) (key ed25519.PrivateKey, public, seed string) {
+	raw := make([]byte, ed25519.SeedSize)
+	for i := range raw {
+		raw[i] = b
+	}
+	key = ed25519.NewKeyFromSeed(raw)
+	return key, base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)), base64.StdEncoding.EncodeToString(raw)
+}
+
+func signedCatalogEntry(t *testing.T, key ed25519.PrivateKey, entry catalogStandard) catalogEnvelope {
+	t.Helper()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signCatalogEntry(key, data)
+}
+
+func gostEntry() catalogStandard {
+	return catalogStandard{Version: catalogEntryVersion, UUID: "gost-7-32", Name: "ГОСТ 7.32-2017", DocumentType: "report", ModulesJSON: `[{"id":"margins"}]`}
+}
+
+func TestVerifyCatalogEntry(t *testing.T) {
+	key, public, _ := testCatalogKey(1)
+	_, otherPublic, _ := testCatalogKey(2)
+	valid := signedCatalogEntry(t, key, gostEntry())
+	with := func(change func(*catalogEnvelope)) catalogEnvelope {
+		env := valid
+		change(&env)
+		return env
+	}
+	entry := func(change func(*catalogStandard)) catalogEnvelope {
+		e := gostEntry()
+		change(&e)
+		return signedCatalogEntry(t, key, e)
+	}
+	another := entry(func(s *catalogStandard) { s.Name = "Другой" })
+
+	for _, tc := range []struct {
+		name string
+		env  catalogEnvelope
+		err  string
+	}{
+		{"valid", valid, ""},
+		{"tampered entry", with(func(e *catalogEnvelope) { e.Entry = strings.Replace(e.Entry, "2017", "2018", 1) }), "signature does not match"},
+		// Re-encoding the entry changes its bytes, so it no longer verifies.
+		{"reformatted entry", with(func(e *catalogEnvelope) { e.Entry = strings.Replace(e.Entry, `":`, `": `, -1) }), "signature does not match"},
+		{"other publisher's key", with(func(e *catalogEnvelope) { e.PublicKey = otherPublic }), "signature does not match"},
+		{"signature of another entry", with(func(e *catalogEnvelope) { e.Signature = another.Signature }), "signature does not match"},
+		{"no public key", with(func(e *catalogEnvelope) { e.PublicKey = "" }), "invalid public key"},
+		{"short public key", with(func(e *catalogEnvelope) { e.PublicKey = public[:20] + "==" }), "invalid public key"},
+		{"public key not base64", with(func(e *catalogEnvelope) { e.PublicKey = "ключ" }), "invalid public key"},
+		{"no scheme", with(func(e *catalogEnvelope) { e.Signature = strings.TrimPrefix(e.Signature, "ed25519:") }), "invalid signature format"},
+		{"other scheme", with(func(e *catalogEnvelope) { e.Signature = "rsa:" + strings.TrimPrefix(e.Signature, "ed25519:") }), "invalid signature format"},
+		{"signature not base64", with(func(e *catalogEnvelope) { e.Signature = "ed25519:***" }), "invalid signature format"},
+		{"no signature", with(func(e *catalogEnvelope) { e.Signature = "" }), "invalid signature format"},
+		{"short signature", with(func(e *catalogEnvelope) { e.Signature = "ed25519:AAAA" }), "signature does not match"},
+		{"entry not json", signCatalogEntry(key, []byte("ГОСТ")), "invalid entry"},
+		{"future version", entry(func(s *catalogStandard) { s.Version = 2 }), "unsupported entry version 2"},
+		{"no uuid", entry(func(s *catalogStandard) { s.UUID = "" }), "no uuid"},
+		{"blank name", entry(func(s *catalogStandard) { s.Name = "  " }), "no uuid"},
+		{"invalid modules", entry(func(s *catalogStandard) { s.ModulesJSON = "[{" }), "no uuid"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := verifyCatalogEntry(tc.env)
+			if tc.err == "" {
+				if err != nil || got != gostEntry() {
+					t.Fatalf("verifyCatalogEntry = %+v, %v", got, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("verifyCatalogEntry error = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestCatalogKeys(t *testing.T) {
+	_, public, seed := testCatalogKey(1)
+	for _, tc := range []struct {
+		seed string
+		ok   bool
+	}{
+		{seed, true},
+		{" " + seed + "\n", true},
+		{"", true},
+		{seed[:20], false},
+		{"не base64", false},
+	} {
+		t.Setenv("CATALOG_SIGNING_KEY", tc.seed)
+		key, err := catalogSigningKey()
+		if (err == nil) != tc.ok || (tc.ok && (key == nil) != (tc.seed == "")) {
+			t.Errorf("catalogSigningKey(%q) = %v, %v", tc.seed, key, err)
+		}
+	}
+
+	_, a, _ := testCatalogKey(2)
+	_, b, _ := testCatalogKey(3)
+	t.Setenv("CATALOG_TRUSTED_KEYS", " "+a+", ,"+b+",")
+	t.Setenv("CATALOG_SIGNING_KEY", seed)
+	if got := catalogTrustedKeys(); len(got) != 3 || !got[a] || !got[b] || !got[public] {
+		t.Fatalf("trusted keys = %v, want the two listed and our own", got)
+	}
+	t.Setenv("CATALOG_SIGNING_KEY", "broken")
+	if got := catalogTrustedKeys(); len(got) != 2 || got[public] {
+		t.Fatalf("trusted keys with a broken signing key = %v", got)
+	}
+}
+
+func TestImportCatalogStandard(t *testing.T) {
+	useTestDB(t)
+	trustedKey, trusted, _ := testCatalogKey(1)
+	otherKey, _, _ := testCatalogKey(2)
+	t.Setenv("CATALOG_TRUSTED_KEYS", trusted)
+	t.Setenv("CATALOG_SIGNING_KEY", "")
+	mustExec(t, `INSERT INTO formatting_standards (id, uuid, name, created_by, is_public, modules_json) VALUES (100, 'local', 'Кафедральный', 1, 1, '[]')`)
+
+	gost := gostEntry()
+	revised := gostEntry()
+	revised.Name = "ГОСТ 7.32-2017 (изм. 1)"
+	local := gostEntry()
+	local.UUID = "local"
+	tampered := signedCatalogEntry(t, trustedKey, gost)
+	tampered.Entry = strings.Replace(tampered.Entry, "report", "thesis", 1)
+
+	for _, tc := range []struct {
+		name   string
+		body   interface{}
+		status int
+		want   string // the stored name of gost-7-32 afterwards
+	}{
+		{"empty request", `{}`, http.StatusBadRequest, ""},
+		{"not json", `entry`, http.StatusBadRequest, ""},
+		{"registry not configured", `{"uuid": "gost-7-32"}`, http.StatusServiceUnavailable, ""},
+		{"invalid signature", gin.H{"entry": tampered}, http.StatusForbidden, ""},
+		{"untrusted publisher", gin.H{"entry": signedCatalogEntry(t, otherKey, gost)}, http.StatusForbidden, ""},
+		{"created", gin.H{"entry": signedCatalogEntry(t, trustedKey, gost)}, http.StatusCreated, gost.Name},
+		{"updated by the same publisher", gin.H{"entry": signedCatalogEntry(t, trustedKey, revised)}, http.StatusOK, revised.Name},
+		{"local standard kept", gin.H{"entry": signedCatalogEntry(t, trustedKey, local)}, http.StatusConflict, revised.Name},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serve(t, ImportCatalogStandard, http.MethodPost, "/api/admin/catalog/import", tc.body, "admin", 1)
+			if w.Code != tc.status {
+				t.Fatalf("status = %d %s, want %d", w.Code, w.Body.String(), tc.status)
+			}
+			var name string
+			var key *string
+			dbErr := database.DB.QueryRow("SELECT name, catalog_publisher_key FROM formatting_standards WHERE uuid = 'gost-7-32'").Scan(&name, &key)
+			if (dbErr == nil) != (tc.want != "") || name != tc.want || (key != nil && *key != trusted) {
+				t.Fatalf("stored standard %q (publisher %v, %v), want %q", name, key, dbErr, tc.want)
+			}
+		})
+	}
+	var name string
+	database.DB.QueryRow("SELECT name FROM formatting_standards WHERE uuid = 'local'").Scan(&name)
+	if name != "Кафедральный" {
+		t.Fatalf("local standard renamed to %q", name)
+	}
+}
+
+func TestGetCatalogChecksEntries(t *testing.T) {
+	useTestDB(t)
+	trustedKey, trusted, _ := testCatalogKey(1)
+	otherKey, other, _ := testCatalogKey(2)
+	t.Setenv("CATALOG_TRUSTED_KEYS", trusted)
+	t.Setenv("CATALOG_SIGNING_KEY", "")
+
+	diploma := gostEntry()
+	diploma.UUID, diploma.Name = "diploma", "ВКР"
+	tampered := signedCatalogEntry(t, trustedKey, diploma)
+	tampered.Entry = strings.Replace(tampered.Entry, "ВКР", "ВКР (копия)", 1)
+	entries := []catalogEnvelope{
+		signedCatalogEntry(t, trustedKey, gostEntry()),
+		signedCatalogEntry(t, otherKey, diploma),
+		tampered,
+	}
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/entries" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(entries)
+	}))
+	defer registry.Close()
+	t.Setenv("CATALOG_URL", registry.URL+"/")
+	mustExec(t, `INSERT INTO formatting_standards (id, uuid, name, created_by, is_public, modules_json) VALUES (100, 'gost-7-32', 'ГОСТ', 1, 1, '[]')`)
+
+	var items []catalogItem
+	decode(t, serve(t, GetCatalog, http.MethodGet, "/api/admin/catalog", nil, "admin", 1), &items)
+	if len(items) != 3 {
+		t.Fatalf("items = %+v", items)
+	}
+	for i, want := range []catalogItem{
+		{PublicKey: trusted, Verified: true, Trusted: true, Imported: true},
+		{PublicKey: other, Verified: true},
+		{PublicKey: trusted, Error: "signature does not match the entry"},
+	} {
+		got := items[i]
+		if got.PublicKey != want.PublicKey || got.Verified != want.Verified || got.Trusted != want.Trusted || got.Imported != want.Imported || got.Error != want.Error {
+			t.Errorf("item %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// Only a verified entry is found by uuid; the tampered one is not.
+	w := serve(t, ImportCatalogStandard, http.MethodPost, "/api/admin/catalog/import", `{"uuid": "diploma"}`, "admin", 1)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("import of the untrusted entry = %d %s", w.Code, w.Body.String())
+	}
+	entries = entries[2:]
+	w = serve(t, ImportCatalogStandard, http.MethodPost, "/api/admin/catalog/import", `{"uuid": "diploma"}`, "admin", 1)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("import of the tampered entry = %d %s", w.Code, w.Body.String())
+	}
+}
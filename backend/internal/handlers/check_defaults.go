@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckDefaultsResponse shows the deployment-wide defaults next to the
+// builtin ones they replace.
+type CheckDefaultsResponse struct {
+	Defaults  checker.Defaults `json:"defaults"`
+	Builtin   checker.Defaults `json:"builtin"`
+	UpdatedAt *time.Time       `json:"updated_at"`
+}
+
+// loadCheckDefaults returns the defaults set by administrators, starting from
+// the builtin ones for fields saved before they existed.
+func loadCheckDefaults() (checker.Defaults, *time.Time, error) {
+	defaults := checker.BuiltinDefaults
+	var raw string
+	var updatedAt sql.NullTime
+	err := database.DB.QueryRow("SELECT defaults_json, updated_at FROM check_defaults WHERE id = 1").Scan(&raw, &updatedAt)
+	if err == sql.ErrNoRows {
+		return defaults, nil, nil
+	}
+	if err != nil {
+		return defaults, nil, err
+	}
+	if err := json.Unmarshal([]byte(raw), &defaults); err != nil {
+		return checker.BuiltinDefaults, nil, err
+	}
+	if updatedAt.Valid {
+		return defaults, &updatedAt.Time, nil
+	}
+	return defaults, nil, nil
+}
+
+// checkDefaults returns the defaults checks inherit. A failure to load them
+// is logged and the builtin defaults apply.
+func checkDefaults() *checker.Defaults {
+	defaults, _, err := loadCheckDefaults()
+	if err != nil {
+		fmt.Printf("checkDefaults: failed to load the check defaults: %v\n", err)
+	}
+	return &defaults
+}
+
+// GetCheckDefaults returns the tolerances and caption keywords standards
+// inherit when they leave them empty.
+func GetCheckDefaults(c *gin.Context) {
+	defaults, updatedAt, err := loadCheckDefaults()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load check defaults"})
+		return
+	}
+	c.JSON(http.StatusOK, CheckDefaultsResponse{Defaults: defaults, Builtin: checker.BuiltinDefaults, UpdatedAt: updatedAt})
+}
+
+// UpdateCheckDefaults replaces the deployment-wide defaults. Fields left out
+// of the request keep their current value. Checks started afterwards use
+// them; saved results are not re-evaluated.
+func UpdateCheckDefaults(c *gin.Context) {
+	defaults, _, err := loadCheckDefaults()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load check defaults"})
+		return
+	}
+	if err := c.ShouldBindJSON(&defaults); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := defaults.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	raw, _ := json.Marshal(defaults)
+	_, err = database.DB.Exec(`INSERT INTO check_defaults (id, defaults_json, updated_by, updated_at) VALUES (1, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET defaults_json = excluded.defaults_json, updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+		string(raw), c.GetUint("user_id"), database.Timestamp(time.Now()))
+	if err != nil {
+		fmt.Printf("UpdateCheckDefaults: failed to save: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save check defaults"})
+		return
+	}
+	GetCheckDefaults(c)
+}
+
+// ResetCheckDefaults returns to the builtin defaults.
+func ResetCheckDefaults(c *gin.Context) {
+	if _, err := database.DB.Exec("DELETE FROM check_defaults WHERE id = 1"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset check defaults"})
+		return
+	}
+	GetCheckDefaults(c)
+}
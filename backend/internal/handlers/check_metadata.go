@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metadataMaxRunes bounds one metadata field.
+const metadataMaxRunes = 300
+
+// metadataColumns lists the work metadata columns of documents or
+// check_results in WorkMetadata field order; alias qualifies them, e.g. "cr.".
+func metadataColumns(alias string) string {
+	cols := []string{"topic", "supervisor", "group_name", "specialty_code"}
+	for i, col := range cols {
+		cols[i] = "COALESCE(" + alias + col + ", '')"
+	}
+	return strings.Join(cols, ", ")
+}
+
+// resolveWorkMetadata reads the work metadata given at upload. Fields left
+// empty are taken from the assignment — the group, its specialty and the
+// teacher who set it as supervisor — or, without one, from the student's group.
+func resolveWorkMetadata(c *gin.Context, userID uint, assignmentID *uint) (models.WorkMetadata, bool) {
+	meta := models.WorkMetadata{
+		Topic:         strings.TrimSpace(c.PostForm("topic")),
+		Supervisor:    strings.TrimSpace(c.PostForm("supervisor")),
+		GroupName:     strings.TrimSpace(c.PostForm("group")),
+		SpecialtyCode: strings.TrimSpace(c.PostForm("specialty_code")),
+	}
+	if !validWorkMetadata(c, meta) {
+		return meta, false
+	}
+
+	var defaults models.WorkMetadata
+	var err error
+	if assignmentID != nil {
+		err = database.DB.QueryRow(`
+			SELECT COALESCE(u.full_name, ''), COALESCE(g.group_name, ''), COALESCE(g.specialty_code, '')
+			FROM assignments a
+			LEFT JOIN student_groups g ON a.group_id = g.id
+			LEFT JOIN users u ON a.created_by = u.id
+			WHERE a.id = ?
+		`, *assignmentID).Scan(&defaults.Supervisor, &defaults.GroupName, &defaults.SpecialtyCode)
+	} else {
+		err = database.DB.QueryRow(`
+			SELECT g.group_name, COALESCE(g.specialty_code, '')
+			FROM users u
+			JOIN student_groups g ON u.group_id = g.id
+			WHERE u.id = ?
+		`, userID).Scan(&defaults.GroupName, &defaults.SpecialtyCode)
+	}
+	if err == nil {
+		if meta.Supervisor == "" {
+			meta.Supervisor = defaults.Supervisor
+		}
+		if meta.GroupName == "" {
+			meta.GroupName = defaults.GroupName
+		}
+		if meta.SpecialtyCode == "" {
+			meta.SpecialtyCode = defaults.SpecialtyCode
+		}
+	}
+	return meta, true
+}
+
+// validWorkMetadata writes the error response when a field is too long.
+func validWorkMetadata(c *gin.Context, meta models.WorkMetadata) bool {
+	for _, value := range []string{meta.Topic, meta.Supervisor, meta.GroupName, meta.SpecialtyCode} {
+		if utf8.RuneCountInString(value) > metadataMaxRunes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Metadata fields are limited to %d characters", metadataMaxRunes)})
+			return false
+		}
+	}
+	return true
+}
+
+type metadataUpdateRequest struct {
+	Topic         *string `json:"topic"`
+	Supervisor    *string `json:"supervisor"`
+	GroupName     *string `json:"group_name"`
+	SpecialtyCode *string `json:"specialty_code"`
+}
+
+// UpdateCheckMetadata corrects the work metadata of a check result. Only the
+// fields present in the body change. The student who uploaded the document,
+// the author of the standard and admins may edit it. Editing the latest result
+// of a document also updates the document, so later re-checks keep the values.
+func UpdateCheckMetadata(c *gin.Context) {
+	id, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	var req metadataUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	var meta models.WorkMetadata
+	var docID int64
+	var ownerID, standardAuthor uint
+	err = database.DB.QueryRow(`
+		SELECT cr.document_id, d.user_id, COALESCE(s.created_by, 0), `+metadataColumns("cr.")+`
+		FROM check_results cr
+		JOIN documents d ON cr.document_id = d.id
+		LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+		WHERE cr.id = ?
+	`, id).Scan(&docID, &ownerID, &standardAuthor, &meta.Topic, &meta.Supervisor, &meta.GroupName, &meta.SpecialtyCode)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	if userID != ownerID && userID != standardAuthor && c.GetString("role") != "admin" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	for _, f := range []struct {
+		value *string
+		field *string
+	}{
+		{req.Topic, &meta.Topic},
+		{req.Supervisor, &meta.Supervisor},
+		{req.GroupName, &meta.GroupName},
+		{req.SpecialtyCode, &meta.SpecialtyCode},
+	} {
+		if f.value != nil {
+			*f.field = strings.TrimSpace(*f.value)
+		}
+	}
+	if !validWorkMetadata(c, meta) {
+		return
+	}
+
+	if _, err := database.DB.Exec("UPDATE check_results SET topic = ?, supervisor = ?, group_name = ?, specialty_code = ? WHERE id = ?",
+		meta.Topic, meta.Supervisor, meta.GroupName, meta.SpecialtyCode, id); err != nil {
+		fmt.Printf("UpdateCheckMetadata: failed to update result %d: %v\n", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update metadata"})
+		return
+	}
+	_, _ = database.DB.Exec(`UPDATE documents SET topic = ?, supervisor = ?, group_name = ?, specialty_code = ?
+		WHERE id = ? AND NOT EXISTS (SELECT 1 FROM check_results WHERE document_id = ? AND id > ?)`,
+		meta.Topic, meta.Supervisor, meta.GroupName, meta.SpecialtyCode, docID, docID, id)
+
+	c.JSON(http.StatusOK, gin.H{"id": id, "metadata": meta})
+}
+
+// metadataFilter returns the SQL condition and arguments of the teacher history
+// filters: ?topic= and ?supervisor= match a part of the value, ?group= and
+// ?specialty= the whole value.
+func metadataFilter(c *gin.Context, alias string) (string, []interface{}) {
+	var where string
+	var args []interface{}
+	like := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	for _, f := range []struct{ param, column string }{{"topic", "topic"}, {"supervisor", "supervisor"}} {
+		if v := strings.TrimSpace(c.Query(f.param)); v != "" {
+			where += " AND " + alias + f.column + ` LIKE ? ESCAPE '\'`
+			args = append(args, "%"+like.Replace(v)+"%")
+		}
+	}
+	for _, f := range []struct{ param, column string }{{"group", "group_name"}, {"specialty", "specialty_code"}} {
+		if v := strings.TrimSpace(c.Query(f.param)); v != "" {
+			where += " AND " + alias + f.column + " = ?"
+			args = append(args, v)
+		}
+	}
+	return where, args
+}
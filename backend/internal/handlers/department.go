@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// departmentTeachers selects the ids of the teachers of a department; it
+// takes the department's name.
+const departmentTeachers = `SELECT id FROM users WHERE role = 'teacher' AND department = ?`
+
+type userDepartmentRequest struct {
+	Department  string `json:"department"`
+	Coordinator bool   `json:"coordinator"`
+}
+
+// SetUserDepartment assigns a teacher to a department and appoints or
+// relieves its coordinator. Departments are not declared anywhere else: a
+// department is the teachers sharing its name, and an empty name takes the
+// teacher out of any.
+func SetUserDepartment(c *gin.Context) {
+	id, err := database.ResolveID("users", c.Param("id"))
+	var role string
+	if err == nil {
+		err = database.DB.QueryRow("SELECT role FROM users WHERE id = ?", id).Scan(&role)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	var req userDepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	department := strings.TrimSpace(req.Department)
+	switch {
+	case role != "teacher" && department != "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only teachers belong to a department"})
+		return
+	case utf8.RuneCountInString(department) > 200:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Department name is too long"})
+		return
+	case req.Coordinator && department == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A coordinator needs a department"})
+		return
+	}
+
+	if _, err := database.DB.Exec("UPDATE users SET department = NULLIF(?, ''), coordinator = ? WHERE id = ?", department, req.Coordinator, id); err != nil {
+		fmt.Printf("SetUserDepartment: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "department": department, "coordinator": req.Coordinator})
+}
+
+// Department is a department with its teachers and coordinators.
+type Department struct {
+	Name         string   `json:"name"`
+	Teachers     int      `json:"teachers"`
+	Coordinators []string `json:"coordinators"`
+}
+
+// GetDepartments lists the departments the teachers were assigned to.
+func GetDepartments(c *gin.Context) {
+	rows, err := database.DB.Query(`
+		SELECT department, COUNT(*), COALESCE(GROUP_CONCAT(CASE WHEN coordinator THEN COALESCE(NULLIF(full_name, ''), email) END, char(31)), '')
+		FROM users
+		WHERE role = 'teacher' AND department IS NOT NULL AND department <> ''
+		GROUP BY department
+		ORDER BY department`)
+	if err != nil {
+		fmt.Printf("GetDepartments: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch departments"})
+		return
+	}
+	defer rows.Close()
+
+	departments := []Department{}
+	for rows.Next() {
+		var d Department
+		var coordinators string
+		if err := rows.Scan(&d.Name, &d.Teachers, &coordinators); err != nil {
+			continue
+		}
+		d.Coordinators = []string{}
+		if coordinators != "" {
+			d.Coordinators = strings.Split(coordinators, "\x1f")
+		}
+		departments = append(departments, d)
+	}
+	c.JSON(http.StatusOK, departments)
+}
+
+// DepartmentStats are the check statistics of the teachers of one
+// department, apart from the global statistics of the administrator. Checks
+// count for a department when they ran against a standard of one of its
+// teachers; pass means a score from 50, as everywhere.
+type DepartmentStats struct {
+	Department    string  `json:"department"`
+	Teachers      int     `json:"teachers"`
+	Standards     int     `json:"standards"`
+	StandardsUsed int     `json:"standards_used"` // standards checked against at least once
+	Coverage      float64 `json:"coverage"`       // percentage of the standards used
+	Assignments   int     `json:"assignments"`
+	Checks        int     `json:"checks"`
+	Submissions   int     `json:"submissions"` // checks submitted to an assignment
+	Students      int     `json:"students"`
+	PassRate      float64 `json:"pass_rate"`
+	AverageScore  float64 `json:"average_score"`
+
+	ByTeacher []DepartmentTeacherStats `json:"by_teacher"`
+	ByGroup   []DepartmentGroupStats   `json:"by_group"`
+}
+
+// DepartmentTeacherStats are the statistics of one teacher of a department.
+type DepartmentTeacherStats struct {
+	TeacherID     uint    `json:"teacher_id"`
+	Name          string  `json:"name"`
+	Coordinator   bool    `json:"coordinator"`
+	Standards     int     `json:"standards"`
+	StandardsUsed int     `json:"standards_used"`
+	Assignments   int     `json:"assignments"`
+	Checks        int     `json:"checks"`
+	Submissions   int     `json:"submissions"`
+	Students      int     `json:"students"`
+	PassRate      float64 `json:"pass_rate"`
+	AverageScore  float64 `json:"average_score"`
+}
+
+// DepartmentGroupStats are the checks of the students of one group against
+// the standards of a department. Students without a group are collected
+// under a nil group id.
+type DepartmentGroupStats struct {
+	GroupID      *uint   `json:"group_id"`
+	GroupName    string  `json:"group_name"`
+	Students     int     `json:"students"`
+	Checks       int     `json:"checks"`
+	Submissions  int     `json:"submissions"`
+	PassRate     float64 `json:"pass_rate"`
+	AverageScore float64 `json:"average_score"`
+}
+
+// statsDepartment returns the department whose statistics are asked for: the
+// coordinator's own, or ?department= for administrators. ok is false, with
+// the error response written, otherwise.
+func statsDepartment(c *gin.Context) (string, bool) {
+	if c.GetString("role") == "admin" {
+		department := strings.TrimSpace(c.Query("department"))
+		if department == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "department is required"})
+			return "", false
+		}
+		return department, true
+	}
+	var department string
+	var coordinator bool
+	database.DB.QueryRow("SELECT COALESCE(department, ''), COALESCE(coordinator, FALSE) FROM users WHERE id = ?",
+		c.GetUint("user_id")).Scan(&department, &coordinator)
+	if !coordinator || department == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only department coordinators can view department statistics"})
+		return "", false
+	}
+	return department, true
+}
+
+// loadDepartmentStats aggregates the checks against the standards of the
+// department's teachers, by teacher and by the student's group. checkTerm
+// and assignmentTerm are the ?term= conditions of termFilter on the aliases
+// cr. and a., both taking termArgs.
+func loadDepartmentStats(department, checkTerm, assignmentTerm string, termArgs []interface{}) (DepartmentStats, error) {
+	stats := DepartmentStats{Department: department, ByTeacher: []DepartmentTeacherStats{}, ByGroup: []DepartmentGroupStats{}}
+
+	args := append(append(append([]interface{}{}, termArgs...), termArgs...), department)
+	rows, err := database.DB.Query(`
+		SELECT u.id, COALESCE(NULLIF(u.full_name, ''), u.email), COALESCE(u.coordinator, FALSE),
+		       COUNT(DISTINCT s.id), COUNT(DISTINCT cr.standard_id),
+		       (SELECT COUNT(*) FROM assignments a WHERE a.created_by = u.id`+assignmentTerm+`),
+		       COUNT(cr.id), COALESCE(SUM(cr.assignment_id IS NOT NULL), 0), COUNT(DISTINCT d.user_id),
+		       COALESCE(SUM(cr.overall_score >= 50), 0), COALESCE(AVG(cr.overall_score), 0)
+		FROM users u
+		LEFT JOIN formatting_standards s ON s.created_by = u.id
+		LEFT JOIN check_results cr ON cr.standard_id = s.id`+checkTerm+`
+		LEFT JOIN documents d ON d.id = cr.document_id
+		WHERE u.role = 'teacher' AND u.department = ?
+		GROUP BY u.id
+		ORDER BY COALESCE(NULLIF(u.full_name, ''), u.email), u.id`, args...)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	var passed int
+	var scoreSum float64
+	for rows.Next() {
+		var t DepartmentTeacherStats
+		var teacherPassed int
+		if err := rows.Scan(&t.TeacherID, &t.Name, &t.Coordinator, &t.Standards, &t.StandardsUsed, &t.Assignments,
+			&t.Checks, &t.Submissions, &t.Students, &teacherPassed, &t.AverageScore); err != nil {
+			return stats, err
+		}
+		if t.Checks > 0 {
+			t.PassRate = float64(teacherPassed) / float64(t.Checks) * 100
+		}
+		stats.Teachers++
+		stats.Standards += t.Standards
+		stats.StandardsUsed += t.StandardsUsed
+		stats.Assignments += t.Assignments
+		stats.Checks += t.Checks
+		stats.Submissions += t.Submissions
+		passed += teacherPassed
+		scoreSum += t.AverageScore * float64(t.Checks)
+		stats.ByTeacher = append(stats.ByTeacher, t)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+	rows.Close()
+	if stats.Standards > 0 {
+		stats.Coverage = float64(stats.StandardsUsed) / float64(stats.Standards) * 100
+	}
+	if stats.Checks > 0 {
+		stats.PassRate = float64(passed) / float64(stats.Checks) * 100
+		stats.AverageScore = scoreSum / float64(stats.Checks)
+	}
+
+	// Students are counted in their current group, so a student checked by
+	// several teachers counts once.
+	rows, err = database.DB.Query(`
+		SELECT g.id, COALESCE(g.group_name, ''), COUNT(DISTINCT d.user_id), COUNT(cr.id),
+		       COALESCE(SUM(cr.assignment_id IS NOT NULL), 0),
+		       COALESCE(SUM(cr.overall_score >= 50), 0), COALESCE(AVG(cr.overall_score), 0)
+		FROM check_results cr
+		JOIN formatting_standards s ON s.id = cr.standard_id
+		JOIN documents d ON d.id = cr.document_id
+		LEFT JOIN users st ON st.id = d.user_id
+		LEFT JOIN student_groups g ON g.id = st.group_id
+		WHERE s.created_by IN (`+departmentTeachers+`)`+checkTerm+`
+		GROUP BY g.id
+		ORDER BY g.id IS NULL, g.group_name`, append([]interface{}{department}, termArgs...)...)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var g DepartmentGroupStats
+		var groupID sql.NullInt64
+		var groupPassed int
+		if err := rows.Scan(&groupID, &g.GroupName, &g.Students, &g.Checks, &g.Submissions, &groupPassed, &g.AverageScore); err != nil {
+			return stats, err
+		}
+		if groupID.Valid {
+			id := uint(groupID.Int64)
+			g.GroupID = &id
+		}
+		if g.Checks > 0 {
+			g.PassRate = float64(groupPassed) / float64(g.Checks) * 100
+		}
+		stats.Students += g.Students
+		stats.ByGroup = append(stats.ByGroup, g)
+	}
+	return stats, rows.Err()
+}
+
+// departmentStatsRequest reads the department and the ?term= filter of a
+// statistics request. ok is false, with the error response written, when
+// either is not valid.
+func departmentStatsRequest(c *gin.Context) (DepartmentStats, bool) {
+	department, ok := statsDepartment(c)
+	if !ok {
+		return DepartmentStats{}, false
+	}
+	checkTerm, termArgs, ok := termFilter(c, "cr.")
+	if !ok {
+		return DepartmentStats{}, false
+	}
+	assignmentTerm, _, _ := termFilter(c, "a.")
+	stats, err := loadDepartmentStats(department, checkTerm, assignmentTerm, termArgs)
+	if err != nil {
+		fmt.Printf("DepartmentStats: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department statistics"})
+		return DepartmentStats{}, false
+	}
+	return stats, true
+}
+
+// GetDepartmentStats reports the statistics of a department: standards
+// coverage, check and submission volumes, pass rates and average scores in
+// total, by teacher and by group. ?term= limits them to one academic term.
+func GetDepartmentStats(c *gin.Context) {
+	defer database.ObserveQuery("department_stats", time.Now())
+	stats, ok := departmentStatsRequest(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// ExportDepartmentStats exports the statistics of a department by teacher or,
+// with ?by=group, by group. ?format= selects csv (default) or xlsx.
+func ExportDepartmentStats(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+		return
+	}
+	by := c.DefaultQuery("by", "teacher")
+	if by != "teacher" && by != "group" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "by must be teacher or group"})
+		return
+	}
+	stats, ok := departmentStatsRequest(c)
+	if !ok {
+		return
+	}
+
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+	var table [][]interface{}
+	if by == "group" {
+		table = [][]interface{}{{"Группа", "Студентов", "Проверок", "Сдано в задания", "Доля успешных, %", "Средняя оценка"}}
+		for _, g := range stats.ByGroup {
+			name := g.GroupName
+			if g.GroupID == nil {
+				name = "Без группы"
+			}
+			table = append(table, []interface{}{name, g.Students, g.Checks, g.Submissions, round(g.PassRate), round(g.AverageScore)})
+		}
+		table = append(table, []interface{}{"Итого", stats.Students, stats.Checks, stats.Submissions, round(stats.PassRate), round(stats.AverageScore)})
+	} else {
+		table = [][]interface{}{{"Преподаватель", "Стандартов", "Использовано стандартов", "Заданий", "Проверок", "Сдано в задания", "Студентов", "Доля успешных, %", "Средняя оценка"}}
+		for _, t := range stats.ByTeacher {
+			table = append(table, []interface{}{t.Name, t.Standards, t.StandardsUsed, t.Assignments, t.Checks, t.Submissions, t.Students, round(t.PassRate), round(t.AverageScore)})
+		}
+		table = append(table, []interface{}{"Итого", stats.Standards, stats.StandardsUsed, stats.Assignments, stats.Checks, stats.Submissions, stats.Students, round(stats.PassRate), round(stats.AverageScore)})
+	}
+
+	filename := fmt.Sprintf("department-%s-%s.%s", by, time.Now().UTC().Format("20060102"), format)
+	if err := respondTable(c, format, filename, "Кафедра", table); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export department statistics"})
+	}
+}
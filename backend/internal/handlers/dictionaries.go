@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDictionaryWords bounds one vocabulary dictionary.
+const maxDictionaryWords = 2000
+
+const dictionaryColumns = "id, teacher_id, name, category, severity, COALESCE(morphology, FALSE), COALESCE(shared, FALSE), words, created_at, updated_at"
+
+func scanDictionary(row interface{ Scan(...interface{}) error }) (models.VocabularyDictionary, error) {
+	var d models.VocabularyDictionary
+	var words string
+	var updatedAt sql.NullTime
+	err := row.Scan(&d.ID, &d.TeacherID, &d.Name, &d.Category, &d.Severity, &d.Morphology, &d.Shared, &words, &d.CreatedAt, &updatedAt)
+	if err != nil {
+		return d, err
+	}
+	d.UpdatedAt = d.CreatedAt
+	if updatedAt.Valid {
+		d.UpdatedAt = updatedAt.Time
+	}
+	d.Words = []models.DictionaryWord{}
+	_ = json.Unmarshal([]byte(words), &d.Words)
+	return d, nil
+}
+
+type dictionaryRequest struct {
+	Name       string                  `json:"name"`
+	Category   string                  `json:"category"`
+	Severity   string                  `json:"severity"`
+	Morphology bool                    `json:"morphology"`
+	Shared     bool                    `json:"shared"`
+	Words      []models.DictionaryWord `json:"words"`
+}
+
+func bindDictionary(c *gin.Context) (dictionaryRequest, bool) {
+	var req dictionaryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Category = strings.TrimSpace(req.Category)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required (up to 200 characters)"})
+		return req, false
+	}
+	if req.Category == "" || utf8.RuneCountInString(req.Category) > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category is required (" + strings.Join(checker.VocabularyCategories, ", ") + " or your own, up to 50 characters)"})
+		return req, false
+	}
+	if req.Severity == "" {
+		req.Severity = models.SeverityError
+	}
+	if !models.IsValidSeverity(req.Severity) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown severity: " + req.Severity})
+		return req, false
+	}
+
+	// Words are stored lowercased with single spaces, the way they are matched
+	words := make([]models.DictionaryWord, 0, len(req.Words))
+	seen := map[string]bool{}
+	for _, w := range req.Words {
+		w.Word = strings.Join(strings.Fields(strings.ToLower(w.Word)), " ")
+		if w.Word == "" || seen[w.Word] {
+			continue
+		}
+		if utf8.RuneCountInString(w.Word) > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Words are limited to 100 characters", "word": w.Word})
+			return req, false
+		}
+		seen[w.Word] = true
+		var replacements []string
+		for _, r := range w.Replacements {
+			if r = strings.TrimSpace(r); r != "" {
+				replacements = append(replacements, r)
+			}
+		}
+		w.Replacements = replacements
+		words = append(words, w)
+	}
+	if len(words) == 0 || len(words) > maxDictionaryWords {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A dictionary holds 1 to %d words", maxDictionaryWords)})
+		return req, false
+	}
+	req.Words = words
+	return req, true
+}
+
+// GetDictionaries lists the vocabulary dictionaries the teacher can use in
+// standards: their own and the shared ones. Admins see all.
+func GetDictionaries(c *gin.Context) {
+	var where []string
+	var args []interface{}
+	if c.GetString("role") != "admin" {
+		where = append(where, "(teacher_id = ? OR shared = TRUE)")
+		args = append(args, c.GetUint("user_id"))
+	}
+	if category := c.Query("category"); category != "" {
+		where = append(where, "category = ?")
+		args = append(args, category)
+	}
+	query := "SELECT " + dictionaryColumns + " FROM vocabulary_dictionaries"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := database.DB.Query(query+" ORDER BY category, name, id", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dictionaries"})
+		return
+	}
+	defer rows.Close()
+
+	dictionaries := []models.VocabularyDictionary{}
+	for rows.Next() {
+		d, err := scanDictionary(rows)
+		if err != nil {
+			continue
+		}
+		dictionaries = append(dictionaries, d)
+	}
+	c.JSON(http.StatusOK, dictionaries)
+}
+
+func CreateDictionary(c *gin.Context) {
+	req, ok := bindDictionary(c)
+	if !ok {
+		return
+	}
+	words, _ := json.Marshal(req.Words)
+	now := database.Timestamp(time.Now())
+	res, err := database.DB.Exec("INSERT INTO vocabulary_dictionaries (teacher_id, name, category, severity, morphology, shared, words, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		c.GetUint("user_id"), req.Name, req.Category, req.Severity, req.Morphology, req.Shared, string(words), now, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save dictionary"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	d, err := scanDictionary(database.DB.QueryRow("SELECT "+dictionaryColumns+" FROM vocabulary_dictionaries WHERE id = ?", id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dictionary"})
+		return
+	}
+	c.JSON(http.StatusCreated, d)
+}
+
+// ownDictionary loads dictionary id if the caller may change it.
+func ownDictionary(c *gin.Context, id string) (models.VocabularyDictionary, bool) {
+	d, err := scanDictionary(database.DB.QueryRow("SELECT "+dictionaryColumns+" FROM vocabulary_dictionaries WHERE id = ?", id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dictionary not found"})
+		return d, false
+	}
+	if d.TeacherID != c.GetUint("user_id") && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own dictionaries"})
+		return d, false
+	}
+	return d, true
+}
+
+// UpdateDictionary replaces a dictionary; standards referring to it check
+// against the new words from their next check on.
+func UpdateDictionary(c *gin.Context) {
+	d, ok := ownDictionary(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	req, ok := bindDictionary(c)
+	if !ok {
+		return
+	}
+	words, _ := json.Marshal(req.Words)
+	_, err := database.DB.Exec("UPDATE vocabulary_dictionaries SET name = ?, category = ?, severity = ?, morphology = ?, shared = ?, words = ?, updated_at = ? WHERE id = ?",
+		req.Name, req.Category, req.Severity, req.Morphology, req.Shared, string(words), database.Timestamp(time.Now()), d.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dictionary"})
+		return
+	}
+	d, _ = scanDictionary(database.DB.QueryRow("SELECT "+dictionaryColumns+" FROM vocabulary_dictionaries WHERE id = ?", d.ID))
+	c.JSON(http.StatusOK, d)
+}
+
+// DeleteDictionary removes a dictionary; standards still referring to it
+// check without it.
+func DeleteDictionary(c *gin.Context) {
+	d, ok := ownDictionary(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if _, err := database.DB.Exec("DELETE FROM vocabulary_dictionaries WHERE id = ?", d.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete dictionary"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dictionary deleted"})
+}
+
+// loadDictionaries is the CheckService.Dictionaries of the server: it loads
+// the dictionaries a standard refers to. Deleted dictionaries are skipped.
+func loadDictionaries(ids []uint) []checker.VocabularyDictionary {
+	var dictionaries []checker.VocabularyDictionary
+	for _, id := range ids {
+		d, err := scanDictionary(database.DB.QueryRow("SELECT "+dictionaryColumns+" FROM vocabulary_dictionaries WHERE id = ?", id))
+		if err != nil {
+			if err != sql.ErrNoRows {
+				fmt.Printf("loadDictionaries: dictionary %d: %v\n", id, err)
+			}
+			continue
+		}
+		dict := checker.VocabularyDictionary{Name: d.Name, Category: d.Category, Severity: d.Severity, Morphology: d.Morphology}
+		for _, w := range d.Words {
+			dict.Words = append(dict.Words, checker.VocabularyWord{Word: w.Word, Replacements: w.Replacements, Exact: w.Exact})
+		}
+		dictionaries = append(dictionaries, dict)
+	}
+	return dictionaries
+}
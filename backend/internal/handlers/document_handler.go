@@ -1,15 +1,19 @@
 package handlers
 
 import (
-	"academic-check-sys/internal/checker"
-	"academic-check-sys/internal/database"
-	"academic-check-sys/internal/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -40,14 +44,25 @@ func UploadAndCheck(c *gin.Context) {
 	fmt.Printf("UploadAndCheck: standard_id param = '%s'\n", standardIDStr)
 
 	var standardID int
+	var moduleID string
 	if standardIDStr != "" && standardIDStr != "undefined" && standardIDStr != "null" {
-		var parseErr error
-		standardID, parseErr = strconv.Atoi(standardIDStr)
+		// The standard may be given by uuid or, from older clients, by numeric id
+		id, parseErr := database.ResolveID("formatting_standards", standardIDStr)
 		if parseErr != nil {
-			fmt.Printf("UploadAndCheck: Failed to parse standard_id: %v\n", parseErr)
+			fmt.Printf("UploadAndCheck: Failed to resolve standard_id: %v\n", parseErr)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid standard_id format"})
 			return
 		}
+		// Private standards are checked against only by those who see them
+		if !standardVisibleTo(c, id) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+			return
+		}
+		var ok bool
+		if moduleID, ok = uploadModuleID(c, id); !ok {
+			return
+		}
+		standardID = int(id)
 	} else {
 		// If standard_id is missing, we can't save the result correctly for history.
 		// However, for robustness, we might default to 0 or 1, but really we should require it.
@@ -56,132 +71,230 @@ func UploadAndCheck(c *gin.Context) {
 		standardID = 1
 	}
 
-	// 2. Save File
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		fmt.Println("UploadAndCheck: UserID not found in context (Middleware issue?), defaulting to 1")
+		userID = 1
+	}
+
+	// Submissions to an assignment are accepted only within its window
+	sub, ok := resolveSubmission(c, userID, standardID)
+	if !ok {
+		return
+	}
+	if sub.Meta, ok = resolveWorkMetadata(c, userID, sub.AssignmentID); !ok {
+		return
+	}
+	meta := sub.Meta
+	if rejectFileName(c, file.Filename, configJSON, sub.AssignmentID) {
+		return
+	}
+
+	// 2. Save File (or reuse an identical earlier upload of the same user)
 	// Create uploads dir if not exists
 	uploadDir := "./uploads"
 	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
 		os.Mkdir(uploadDir, 0755)
 	}
 
-	filename := fmt.Sprintf("%d_%s", time.Now().Unix(), file.Filename)
-	savePath := filepath.Join(uploadDir, filename)
-	if err := c.SaveUploadedFile(file, savePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+	contentHash, err := hashUploadedFile(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
 		return
 	}
 
-	// 3. Trigger Check
-	svc := checker.NewCheckService()
-	result, violations, err := svc.RunCheck(c.Request.Context(), savePath, configJSON)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Check failed: %v", err)})
-		return
+	existingDocID, savePath := findDuplicateDocument(userID, contentHash)
+	if existingDocID != 0 {
+		fmt.Printf("UploadAndCheck: reusing document %d for identical upload (hash %s)\n", existingDocID, contentHash[:12])
+	} else {
+		savePath = filepath.Join(uploadDir, fmt.Sprintf("%d_%s", time.Now().Unix(), file.Filename))
+		if err := c.SaveUploadedFile(file, savePath); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+			return
+		}
 	}
 
-	// 3.5. Convert to PDF for Frontend Display
-	// We use LibreOffice (soffice) to convert the saved DOCX to PDF.
-	// Output file will be [filename].pdf in the same dir.
-	pdfFilename := filename[:len(filename)-len(filepath.Ext(filename))] + ".pdf"
-	// Command: soffice --headless --convert-to pdf --outdir [uploadDir] [savePath]
-	// Note: We need to use 'exec' package.
+	// 3. Register the document before processing so every stage is visible
+	docID := existingDocID
+	if docID == 0 {
+		// Insert Document Record
+		docEntry := models.Document{
+			UserID:      userID,
+			FileName:    file.Filename,
+			FilePath:    savePath,
+			FileSize:    file.Size,
+			UploadDate:  time.Now().UTC(),
+			Status:      models.DocStatusUploaded,
+			ContentHash: contentHash,
+			StandardID:  standardID,
+			ConfigJSON:  configJSON,
+			Metadata:    meta,
+		}
 
-	// Ensure we are importing "os/exec"
+		resDoc, err := database.DB.Exec("INSERT INTO documents (user_id, file_name, file_path, file_size, upload_date, status, content_hash, standard_id, config_json, module_id, assignment_id, submitted_late, late_note, topic, supervisor, group_name, specialty_code) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			docEntry.UserID, docEntry.FileName, docEntry.FilePath, docEntry.FileSize, database.Timestamp(docEntry.UploadDate), docEntry.Status, docEntry.ContentHash, docEntry.StandardID, docEntry.ConfigJSON, moduleID, sub.AssignmentID, sub.Late, sub.Note,
+			meta.Topic, meta.Supervisor, meta.GroupName, meta.SpecialtyCode)
 
-	cmd := exec.Command("soffice", "--headless", "--convert-to", "pdf", "--outdir", uploadDir, savePath)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Printf("PDF Conversion failed: %v, Output: %s\n", err, string(output))
-		// We don't fail the whole request, but PDF won't be available.
-		// c.JSON(http.StatusInternalServerError, gin.H{"error": "PDF Conversion failed"})
-		// return
-	} else {
-		fmt.Printf("PDF Conversion success: %s\n", pdfFilename)
-		result.ContentJSON = result.ContentJSON[:len(result.ContentJSON)-1] + fmt.Sprintf(`, "pdf_url": "/api/uploads/%s"}`, pdfFilename)
-	}
+		if err != nil {
+			fmt.Printf("UploadAndCheck: DB Error Inserting Document: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error saving document"})
+			return
+		}
 
-	// 4. Save Results to DB
-	userID := c.GetUint("user_id")
-	if userID == 0 {
-		fmt.Println("UploadAndCheck: UserID not found in context (Middleware issue?), defaulting to 1")
-		userID = 1
+		newID, _ := resDoc.LastInsertId()
+		docID = newID
+	} else {
+		// The name is the one of this upload: the file name rule checks it.
+		_, _ = database.DB.Exec("UPDATE documents SET file_name = ?, standard_id = ?, config_json = ?, module_id = ?, assignment_id = ?, submitted_late = ?, late_note = ?, topic = ?, supervisor = ?, group_name = ?, specialty_code = ? WHERE id = ?",
+			file.Filename, standardID, configJSON, moduleID, sub.AssignmentID, sub.Late, sub.Note, meta.Topic, meta.Supervisor, meta.GroupName, meta.SpecialtyCode, docID)
+		setDocumentStatus(docID, models.DocStatusUploaded, "")
 	}
 
-	// Insert Document Record
-	docEntry := models.Document{
-		UserID:     userID,
-		FileName:   file.Filename,
-		FilePath:   savePath,
-		FileSize:   file.Size,
-		UploadDate: time.Now(),
-		Status:     "checked",
+	// 4. Run the pipeline: parse -> check -> save -> convert
+	out := runCheckPipeline(c.Request.Context(), checkPipeline{
+		DocID:       docID,
+		FilePath:    savePath,
+		ContentHash: contentHash,
+		StandardID:  standardID,
+		ConfigJSON:  configJSON,
+		Submission:  sub,
+	})
+	if respondPipelineError(c, docID, out, "Check failed") {
+		return
 	}
 
-	resDoc, err := database.DB.Exec("INSERT INTO documents (user_id, file_name, file_path, file_size, upload_date, status) VALUES (?, ?, ?, ?, ?, ?)",
-		docEntry.UserID, docEntry.FileName, docEntry.FilePath, docEntry.FileSize, docEntry.UploadDate, docEntry.Status)
+	// 5. Return Response
+	resp := checkResponse(c, docID, out)
+	resp["deduplicated"] = existingDocID != 0
+	c.JSON(http.StatusOK, resp)
+}
 
+// RetryDocument re-runs the failed stage of a document's pipeline using the
+// stored file and the standard/config of the original request. A failed PDF
+// conversion only reconverts the already saved result.
+func RetryDocument(c *gin.Context) {
+	id, err := database.ResolveID("documents", c.Param("id"))
 	if err != nil {
-		fmt.Printf("UploadAndCheck: DB Error Inserting Document: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error saving document"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	p, doc, err := loadDocumentPipeline(id)
+	if err != nil || doc.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
 		return
 	}
 
-	docID, _ := resDoc.LastInsertId()
+	if !strings.HasPrefix(doc.Status, models.FailedStatus("")) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only failed documents can be retried", "status": doc.Status})
+		return
+	}
+	if _, err := os.Stat(doc.FilePath); err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Stored file is no longer available, please upload it again"})
+		return
+	}
 
-	// Insert Result
-	resCheck, err := database.DB.Exec("INSERT INTO check_results (document_id, standard_id, overall_score, total_rules, failed_rules, content_json) VALUES (?, ?, ?, ?, ?, ?)",
-		docID, standardID, result.OverallScore, result.TotalRules, result.FailedRules, result.ContentJSON)
+	stage := strings.TrimPrefix(doc.Status, models.FailedStatus(""))
+	fmt.Printf("RetryDocument: retrying document %d from stage %s (last error: %s)\n", doc.ID, stage, doc.LastError)
 
-	if err != nil {
-		fmt.Printf("UploadAndCheck: DB Error Inserting Result: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error saving results"})
+	out := runPipelineFrom(c.Request.Context(), p, stage)
+	if out.Err == nil {
+		discardFailedJobs(p.DocID)
+	}
+	if respondPipelineError(c, int64(doc.ID), out, "Retry failed") {
 		return
 	}
 
-	checkID, _ := resCheck.LastInsertId()
+	c.JSON(http.StatusOK, checkResponse(c, int64(doc.ID), out))
+}
 
-	// Insert Violations
-	// Transaction would be better, but for now just execute
-	tx, _ := database.DB.Begin()
-	stmt, err := tx.Prepare("INSERT INTO violations (result_id, rule_type, description, severity, position_in_doc, expected_value, actual_value, suggestion, context_text, is_doubtful) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
-	if err != nil {
-		fmt.Printf("UploadAndCheck: DB Error Preparing Violations: %v\n", err)
-		tx.Rollback()
-	} else {
-		for i := range violations {
-			res, err := stmt.Exec(
-				checkID, 
-				violations[i].RuleType, 
-				violations[i].Description, 
-				violations[i].Severity, 
-				violations[i].PositionInDoc, 
-				violations[i].ExpectedValue, 
-				violations[i].ActualValue, 
-				violations[i].Suggestion, 
-				violations[i].ContextText, 
-				violations[i].IsDoubtful,
-			)
-			if err != nil {
-				fmt.Printf("UploadAndCheck: DB Error Inserting Violation: %v\n", err)
-				continue
-			}
-			
-			// Capture the real database ID and assign it back to the slice
-			if id, err := res.LastInsertId(); err == nil {
-				violations[i].ID = uint(id)
-			}
-		}
-		stmt.Close()
-		tx.Commit()
+// respondPipelineError writes the error response for a failed pipeline run and
+// reports whether it did. A failed PDF conversion is not an error for the client,
+// and a document rejected as too complex is answered with its saved report.
+func respondPipelineError(c *gin.Context, docID int64, out pipelineOutcome, message string) bool {
+	if out.Err == nil || out.Status == models.FailedStatus(models.StageConverting) {
+		return false
 	}
 
-	// 5. Return Response
-	c.JSON(http.StatusOK, gin.H{
-		"score":        result.OverallScore,
-		"violations":   violations,
-		"content_json": result.ContentJSON, // Include for Visual Preview
+	var tooComplex *checker.ComplexityError
+	if errors.As(out.Err, &tooComplex) && out.Result != nil {
+		resp := checkResponse(c, docID, out)
+		resp["error"] = tooComplex.Error()
+		c.JSON(http.StatusUnprocessableEntity, resp)
+		return true
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":       fmt.Sprintf("%s: %v", message, out.Err),
+		"document_id": docID,
+		"status":      out.Status,
+	})
+	return true
+}
+
+// checkResponse builds the check payload. With ?debug=1 it also carries the
+// time spent in each rule group and the trace of how each group was evaluated.
+func checkResponse(c *gin.Context, docID int64, out pipelineOutcome) gin.H {
+	resp := gin.H{
+		"id":            out.ResultID,
+		"uuid":          out.Result.UUID,
+		"document_id":   docID,
+		"document_uuid": database.UUIDOf("documents", docID),
+		"status":        out.Status,
+		"score":         out.Result.OverallScore,
+		"summary":       out.Result.Summary,
+		"violations":    localizeViolations(c, out.Violations),
+		"content_json":  out.Result.ContentJSON, // Include for Visual Preview
 		"stats": gin.H{
-			"total":  result.TotalRules,
-			"failed": result.FailedRules,
+			"total":  out.Result.TotalRules,
+			"failed": out.Result.FailedRules,
 		},
-	})
+		"metadata": out.Result.Metadata,
+	}
+	if out.Result.AssignmentID != nil {
+		resp["assignment_id"] = *out.Result.AssignmentID
+		resp["submitted_late"] = out.Result.SubmittedLate
+		resp["late_note"] = out.Result.LateNote
+	}
+	if c.Query("debug") == "1" && out.Result.RuleTimings != nil {
+		resp["rule_timings"] = out.Result.RuleTimings
+	}
+	if c.Query("debug") == "1" && out.Result.RuleTrace != nil {
+		resp["rule_trace"] = out.Result.RuleTrace
+	}
+	return resp
+}
+
+// hashUploadedFile returns the hex SHA-256 of an uploaded multipart file.
+func hashUploadedFile(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findDuplicateDocument looks up an earlier upload of the same content by the same
+// user whose file is still on disk. It returns 0 and "" when there is none.
+func findDuplicateDocument(userID uint, contentHash string) (int64, string) {
+	var id int64
+	var path string
+	err := database.DB.QueryRow(
+		"SELECT id, file_path FROM documents WHERE user_id = ? AND content_hash = ? ORDER BY id DESC LIMIT 1",
+		userID, contentHash,
+	).Scan(&id, &path)
+	if err != nil {
+		return 0, ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		return 0, ""
+	}
+	return id, path
 }
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordFailedJob stores a check that panicked or timed out in the dead-letter table.
+func recordFailedJob(p checkPipeline, stage string, jobErr error, stack string) {
+	_, err := database.DB.Exec("INSERT INTO failed_jobs (document_id, file_path, stage, error, stack) VALUES (?, ?, ?, ?, ?)",
+		p.DocID, p.FilePath, stage, jobErr.Error(), stack)
+	if err != nil {
+		fmt.Printf("Pipeline: failed to record failed job for document %d: %v\n", p.DocID, err)
+	}
+}
+
+// discardFailedJobs removes the dead-letter entries of a document once it was
+// processed successfully.
+func discardFailedJobs(docID int64) {
+	_, _ = database.DB.Exec("DELETE FROM failed_jobs WHERE document_id = ?", docID)
+}
+
+func GetFailedJobs(c *gin.Context) {
+	rows, err := database.DB.Query(`
+		SELECT id, document_id, file_path, stage, error, stack, created_at
+		FROM failed_jobs
+		ORDER BY created_at DESC, id DESC
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch failed jobs"})
+		return
+	}
+	defer rows.Close()
+
+	jobs := []models.FailedJob{}
+	for rows.Next() {
+		var j models.FailedJob
+		var filePath, stack sql.NullString
+		if err := rows.Scan(&j.ID, &j.DocumentID, &filePath, &j.Stage, &j.Error, &stack, &j.CreatedAt); err != nil {
+			continue
+		}
+		j.FilePath = filePath.String
+		j.Stack = stack.String
+		jobs = append(jobs, j)
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// RetryFailedJob re-runs the failed stage of the job's document. The entry is
+// removed when the retry succeeds.
+func RetryFailedJob(c *gin.Context) {
+	id := c.Param("id")
+
+	var docID int64
+	var stage string
+	err := database.DB.QueryRow("SELECT document_id, stage FROM failed_jobs WHERE id = ?", id).Scan(&docID, &stage)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed job not found"})
+		return
+	}
+
+	p, _, err := loadDocumentPipeline(docID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document of the failed job no longer exists"})
+		return
+	}
+
+	out := runPipelineFrom(c.Request.Context(), p, stage)
+	if out.Err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":       fmt.Sprintf("Retry failed: %v", out.Err),
+			"document_id": docID,
+			"status":      out.Status,
+		})
+		return
+	}
+
+	discardFailedJobs(docID)
+	c.JSON(http.StatusOK, gin.H{"message": "Job retried", "document_id": docID, "status": out.Status, "id": out.ResultID})
+}
+
+func DiscardFailedJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	res, err := database.DB.Exec("DELETE FROM failed_jobs WHERE id = ?", id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discard job"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Failed job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job discarded"})
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxFalsePositiveComment bounds the explanation of a report, in characters.
+const maxFalsePositiveComment = 2000
+
+type falsePositiveRequest struct {
+	Comment string `json:"comment"`
+}
+
+// ReportFalsePositive records that a violation of a check result is wrong,
+// together with the parsed paragraph it points to and the standard config
+// the rule applied, and queues it for review by the author of the standard.
+// Anyone who may open the result's report may file one per violation.
+func ReportFalsePositive(c *gin.Context) {
+	resultID, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+	violationID, err := strconv.ParseUint(c.Param("vid"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Violation not found"})
+		return
+	}
+	var req falsePositiveRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if len([]rune(req.Comment)) > maxFalsePositiveComment {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("comment must be at most %d characters", maxFalsePositiveComment)})
+		return
+	}
+
+	var ownerID, standardAuthor uint
+	var contentJSON, configJSON sql.NullString
+	err = database.DB.QueryRow(`
+		SELECT d.user_id, COALESCE(s.created_by, 0), cr.content_json, d.config_json
+		FROM check_results cr
+		JOIN documents d ON cr.document_id = d.id
+		LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+		WHERE cr.id = ?
+	`, resultID).Scan(&ownerID, &standardAuthor, &contentJSON, &configJSON)
+	userID := c.GetUint("user_id")
+	if err != nil || (userID != ownerID && userID != standardAuthor && c.GetString("role") != "admin") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	var violation *models.Violation
+	for _, v := range loadViolations(uint(resultID)) {
+		if uint64(v.ID) == violationID {
+			violation = &v
+			break
+		}
+	}
+	if violation == nil || violation.Severity == models.SeverityManual {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Violation not found"})
+		return
+	}
+	violationJSON, _ := json.Marshal(violation)
+	var config interface{}
+	if json.Valid([]byte(configJSON.String)) {
+		config = configJSON.String
+	}
+
+	res, err := database.DB.Exec(`INSERT OR IGNORE INTO false_positive_reports
+		(result_id, violation_id, rule_type, reported_by, comment, violation_json, paragraph_json, config_json, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		resultID, violation.ID, violation.RuleType, userID, req.Comment, string(violationJSON),
+		violationParagraph(contentJSON.String, violation.Location), config, models.FalsePositivePending, database.Timestamp(time.Now()))
+	if err != nil {
+		fmt.Printf("ReportFalsePositive: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report false positive"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this violation"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	report, _ := loadFalsePositive(id)
+	c.JSON(http.StatusCreated, report)
+}
+
+// violationParagraph returns the parsed paragraph a violation points to,
+// taken from the document content saved with the result, or nil.
+func violationParagraph(contentJSON string, loc *models.Location) interface{} {
+	if loc == nil || loc.ParagraphIndex == nil || contentJSON == "" {
+		return nil
+	}
+	var content struct {
+		Paragraphs []json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(contentJSON), &content); err != nil {
+		return nil
+	}
+	i := *loc.ParagraphIndex
+	if i < 0 || i >= len(content.Paragraphs) {
+		return nil
+	}
+	return string(content.Paragraphs[i])
+}
+
+const falsePositiveColumns = `f.id, f.result_id, COALESCE(cr.uuid, ''), f.violation_id, f.rule_type, f.reported_by, COALESCE(u.full_name, ''),
+	COALESCE(f.comment, ''), f.violation_json, COALESCE(f.paragraph_json, ''), COALESCE(f.config_json, ''), f.status,
+	f.reviewed_by, COALESCE(f.review_note, ''), f.reviewed_at, f.created_at`
+
+const falsePositiveFrom = `
+	FROM false_positive_reports f
+	LEFT JOIN check_results cr ON f.result_id = cr.id
+	LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+	LEFT JOIN users u ON f.reported_by = u.id`
+
+func scanFalsePositive(row interface{ Scan(...interface{}) error }) (models.FalsePositiveReport, error) {
+	var r models.FalsePositiveReport
+	var violation, paragraph, config string
+	var reviewedBy sql.NullInt64
+	var reviewedAt sql.NullTime
+	err := row.Scan(&r.ID, &r.ResultID, &r.ResultUUID, &r.ViolationID, &r.RuleType, &r.ReportedBy, &r.ReporterName,
+		&r.Comment, &violation, &paragraph, &config, &r.Status, &reviewedBy, &r.ReviewNote, &reviewedAt, &r.CreatedAt)
+	r.Violation = json.RawMessage(violation)
+	if paragraph != "" {
+		r.Paragraph = json.RawMessage(paragraph)
+	}
+	if config != "" {
+		r.Config = json.RawMessage(config)
+	}
+	if reviewedBy.Valid {
+		by := uint(reviewedBy.Int64)
+		r.ReviewedBy = &by
+	}
+	if reviewedAt.Valid {
+		r.ReviewedAt = &reviewedAt.Time
+	}
+	return r, err
+}
+
+func loadFalsePositive(id int64) (models.FalsePositiveReport, error) {
+	return scanFalsePositive(database.DB.QueryRow("SELECT "+falsePositiveColumns+falsePositiveFrom+" WHERE f.id = ?", id))
+}
+
+// reviewableFalsePositives restricts a query to the reports the current
+// user reviews: those on checks against their standards, or all for admins.
+func reviewableFalsePositives(c *gin.Context) (string, []interface{}) {
+	if c.GetString("role") == "admin" {
+		return "", nil
+	}
+	return " AND s.created_by = ?", []interface{}{c.GetUint("user_id")}
+}
+
+// GetFalsePositives lists the false positive reports the user reviews,
+// newest first. ?status= (default pending) and ?rule_type= narrow the list.
+func GetFalsePositives(c *gin.Context) {
+	status := c.DefaultQuery("status", models.FalsePositivePending)
+	query := "SELECT " + falsePositiveColumns + falsePositiveFrom + " WHERE f.status = ?"
+	args := []interface{}{status}
+	if ruleType := c.Query("rule_type"); ruleType != "" {
+		query += " AND f.rule_type = ?"
+		args = append(args, ruleType)
+	}
+	filter, filterArgs := reviewableFalsePositives(c)
+	rows, err := database.DB.Query(query+filter+" ORDER BY f.created_at DESC, f.id DESC", append(args, filterArgs...)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch false positive reports"})
+		return
+	}
+	defer rows.Close()
+
+	reports := []models.FalsePositiveReport{}
+	for rows.Next() {
+		r, err := scanFalsePositive(rows)
+		if err != nil {
+			continue
+		}
+		reports = append(reports, r)
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+type falsePositiveReview struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"`
+}
+
+// ReviewFalsePositive confirms or rejects a report. A decision can be
+// changed later, e.g. after the rule was fixed.
+func ReviewFalsePositive(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	var req falsePositiveReview
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch req.Status {
+	case models.FalsePositiveConfirmed, models.FalsePositiveRejected, models.FalsePositivePending:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be confirmed, rejected or pending"})
+		return
+	}
+
+	filter, filterArgs := reviewableFalsePositives(c)
+	var exists int
+	database.DB.QueryRow("SELECT COUNT(*)"+falsePositiveFrom+" WHERE f.id = ?"+filter, append([]interface{}{id}, filterArgs...)...).Scan(&exists)
+	if exists == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+	if _, err := database.DB.Exec("UPDATE false_positive_reports SET status = ?, review_note = ?, reviewed_by = ?, reviewed_at = ? WHERE id = ?",
+		req.Status, strings.TrimSpace(req.Note), c.GetUint("user_id"), database.Timestamp(time.Now()), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review report"})
+		return
+	}
+	report, _ := loadFalsePositive(id)
+	c.JSON(http.StatusOK, report)
+}
+
+// FalsePositiveFixture is a confirmed false positive in the form the test
+// corpus replays: the rule must not report Violation for Paragraph under
+// Config.
+type FalsePositiveFixture struct {
+	ID         uint            `json:"id"`
+	RuleType   string          `json:"rule_type"`
+	Comment    string          `json:"comment,omitempty"`
+	ReviewNote string          `json:"review_note,omitempty"`
+	Violation  json.RawMessage `json:"violation"`
+	Paragraph  json.RawMessage `json:"paragraph,omitempty"`
+	Config     json.RawMessage `json:"config,omitempty"`
+}
+
+// ExportFalsePositiveFixtures downloads the confirmed reports as regression
+// fixtures (?rule_type= for one rule).
+func ExportFalsePositiveFixtures(c *gin.Context) {
+	query := "SELECT " + falsePositiveColumns + falsePositiveFrom + " WHERE f.status = ?"
+	args := []interface{}{models.FalsePositiveConfirmed}
+	if ruleType := c.Query("rule_type"); ruleType != "" {
+		query += " AND f.rule_type = ?"
+		args = append(args, ruleType)
+	}
+	rows, err := database.DB.Query(query+" ORDER BY f.rule_type, f.id", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export fixtures"})
+		return
+	}
+	defer rows.Close()
+
+	fixtures := []FalsePositiveFixture{}
+	for rows.Next() {
+		r, err := scanFalsePositive(rows)
+		if err != nil {
+			continue
+		}
+		fixtures = append(fixtures, FalsePositiveFixture{
+			ID:         r.ID,
+			RuleType:   r.RuleType,
+			Comment:    r.Comment,
+			ReviewNote: r.ReviewNote,
+			Violation:  r.Violation,
+			Paragraph:  r.Paragraph,
+			Config:     r.Config,
+		})
+	}
+	c.Header("Content-Disposition", `attachment; filename="false-positive-fixtures.json"`)
+	c.IndentedJSON(http.StatusOK, fixtures)
+}
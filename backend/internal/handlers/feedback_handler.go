@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/ai"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GenerateResultFeedback returns LLM-generated study advice for a check
+// result, grouped by violation category. The advice is generated once per
+// result and cached in result_feedback.
+func GenerateResultFeedback(c *gin.Context) {
+	id, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Result not found"})
+		return
+	}
+	resultID := int(id)
+
+	var documentUserID uint
+	err = database.DB.QueryRow(`
+		SELECT d.user_id
+		FROM check_results cr
+		JOIN documents d ON d.id = cr.document_id
+		WHERE cr.id = ?`, resultID).Scan(&documentUserID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Result not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	role, _ := c.Get("role")
+	if role != "teacher" && role != "admin" && documentUserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	// 1. Cached feedback
+	var model, feedbackJSON string
+	var createdAt time.Time
+	err = database.DB.QueryRow(`SELECT model, feedback_json, created_at FROM result_feedback WHERE result_id = ?`, resultID).
+		Scan(&model, &feedbackJSON, &createdAt)
+	if err == nil {
+		var categories []ai.CategoryFeedback
+		if json.Unmarshal([]byte(feedbackJSON), &categories) == nil {
+			c.JSON(http.StatusOK, feedbackResponse(resultID, model, database.FormatTimestamp(createdAt), categories, true))
+			return
+		}
+	}
+
+	client := ai.NewFeedbackClientFromEnv()
+	if client == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "LLM feedback is not configured"})
+		return
+	}
+
+	// 2. Collect the blocking violations of the result
+	rows, err := database.DB.Query(`
+		SELECT rule_type, description, severity, expected_value, actual_value, context_text
+		FROM violations WHERE result_id = ?`, resultID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch violations"})
+		return
+	}
+	var violations []ai.FeedbackViolation
+	for rows.Next() {
+		var v ai.FeedbackViolation
+		var severity, contextText sql.NullString
+		if err := rows.Scan(&v.RuleType, &v.Description, &severity, &v.Expected, &v.Actual, &contextText); err != nil {
+			continue
+		}
+		if !models.IsBlocking(severity.String) {
+			continue
+		}
+		v.Context = contextText.String
+		violations = append(violations, v)
+	}
+	rows.Close()
+
+	// 3. Call the model
+	categories, err := client.GenerateFeedback(c.Request.Context(), ai.BuildFeedbackItems(violations))
+	if err != nil {
+		fmt.Printf("LLM feedback for result %d failed: %v\n", resultID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Feedback generation failed", "details": err.Error()})
+		return
+	}
+	if categories == nil {
+		categories = []ai.CategoryFeedback{}
+	}
+
+	// 4. Cache
+	now := time.Now()
+	data, _ := json.Marshal(categories)
+	_, err = database.DB.Exec(`INSERT OR REPLACE INTO result_feedback (result_id, model, feedback_json, created_at) VALUES (?, ?, ?, ?)`,
+		resultID, client.Model, string(data), database.Timestamp(now))
+	if err != nil {
+		fmt.Printf("Failed to cache LLM feedback for result %d: %v\n", resultID, err)
+	}
+
+	c.JSON(http.StatusOK, feedbackResponse(resultID, client.Model, database.FormatTimestamp(now), categories, false))
+}
+
+func feedbackResponse(resultID int, model, generatedAt string, categories []ai.CategoryFeedback, cached bool) gin.H {
+	return gin.H{
+		"result_id":    resultID,
+		"automated":    true,
+		"notice":       ai.FeedbackNotice,
+		"model":        model,
+		"generated_at": generatedAt,
+		"categories":   categories,
+		"cached":       cached,
+	}
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"database/sql"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCommentResults bounds one batch of comments, e.g. all checks of a group.
+const maxCommentResults = 500
+
+const feedbackTemplateColumns = "id, teacher_id, title, body, COALESCE(rule_type, ''), created_at, updated_at"
+
+func scanFeedbackTemplate(row interface{ Scan(...interface{}) error }) (models.FeedbackTemplate, error) {
+	var t models.FeedbackTemplate
+	var updatedAt sql.NullTime
+	err := row.Scan(&t.ID, &t.TeacherID, &t.Title, &t.Body, &t.RuleType, &t.CreatedAt, &updatedAt)
+	t.UpdatedAt = t.CreatedAt
+	if updatedAt.Valid {
+		t.UpdatedAt = updatedAt.Time
+	}
+	return t, err
+}
+
+type feedbackTemplateRequest struct {
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	RuleType string `json:"rule_type"`
+}
+
+func bindFeedbackTemplate(c *gin.Context) (feedbackTemplateRequest, bool) {
+	var req feedbackTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	req.Body = strings.TrimSpace(req.Body)
+	req.RuleType = strings.TrimSpace(req.RuleType)
+	if req.Title == "" || utf8.RuneCountInString(req.Title) > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title is required (up to 200 characters)"})
+		return req, false
+	}
+	if req.Body == "" || utf8.RuneCountInString(req.Body) > 4000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Body is required (up to 4000 characters)"})
+		return req, false
+	}
+	return req, true
+}
+
+// GetFeedbackTemplates lists the teacher's templates; admins see all.
+func GetFeedbackTemplates(c *gin.Context) {
+	query := "SELECT " + feedbackTemplateColumns + " FROM feedback_templates"
+	var args []interface{}
+	if c.GetString("role") != "admin" {
+		query += " WHERE teacher_id = ?"
+		args = append(args, c.GetUint("user_id"))
+	}
+	rows, err := database.DB.Query(query+" ORDER BY title, id", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feedback templates"})
+		return
+	}
+	defer rows.Close()
+
+	templates := []models.FeedbackTemplate{}
+	for rows.Next() {
+		t, err := scanFeedbackTemplate(rows)
+		if err != nil {
+			continue
+		}
+		templates = append(templates, t)
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+func CreateFeedbackTemplate(c *gin.Context) {
+	req, ok := bindFeedbackTemplate(c)
+	if !ok {
+		return
+	}
+	now := database.Timestamp(time.Now())
+	res, err := database.DB.Exec("INSERT INTO feedback_templates (teacher_id, title, body, rule_type, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		c.GetUint("user_id"), req.Title, req.Body, req.RuleType, now, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save feedback template"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	t, err := scanFeedbackTemplate(database.DB.QueryRow("SELECT "+feedbackTemplateColumns+" FROM feedback_templates WHERE id = ?", id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load feedback template"})
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+// ownFeedbackTemplate loads template id if the caller may use and change it.
+func ownFeedbackTemplate(c *gin.Context, id string) (models.FeedbackTemplate, bool) {
+	t, err := scanFeedbackTemplate(database.DB.QueryRow("SELECT "+feedbackTemplateColumns+" FROM feedback_templates WHERE id = ?", id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feedback template not found"})
+		return t, false
+	}
+	if t.TeacherID != c.GetUint("user_id") && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own feedback templates"})
+		return t, false
+	}
+	return t, true
+}
+
+func UpdateFeedbackTemplate(c *gin.Context) {
+	t, ok := ownFeedbackTemplate(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	req, ok := bindFeedbackTemplate(c)
+	if !ok {
+		return
+	}
+	_, err := database.DB.Exec("UPDATE feedback_templates SET title = ?, body = ?, rule_type = ?, updated_at = ? WHERE id = ?",
+		req.Title, req.Body, req.RuleType, database.Timestamp(time.Now()), t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update feedback template"})
+		return
+	}
+	t, _ = scanFeedbackTemplate(database.DB.QueryRow("SELECT "+feedbackTemplateColumns+" FROM feedback_templates WHERE id = ?", t.ID))
+	c.JSON(http.StatusOK, t)
+}
+
+// DeleteFeedbackTemplate removes a template; comments made from it keep
+// their text.
+func DeleteFeedbackTemplate(c *gin.Context) {
+	t, ok := ownFeedbackTemplate(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if _, err := database.DB.Exec("DELETE FROM feedback_templates WHERE id = ?", t.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete feedback template"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Feedback template deleted"})
+}
+
+type resultCommentRequest struct {
+	ResultIDs  []string `json:"result_ids"` // uuids or numeric ids
+	TemplateID *uint    `json:"template_id"`
+	Body       string   `json:"body"`
+	RuleType   string   `json:"rule_type"`
+}
+
+// AddResultComments attaches one comment to one or many check results: the
+// text of a template, a free text, or a template followed by a free text.
+// Teachers comment on checks against their own standards, supervisors on
+// checks of the students they follow.
+func AddResultComments(c *gin.Context) {
+	var req resultCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.ResultIDs) == 0 || len(req.ResultIDs) > maxCommentResults {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "result_ids must list 1 to " + strconv.Itoa(maxCommentResults) + " results"})
+		return
+	}
+
+	body := strings.TrimSpace(req.Body)
+	ruleType := strings.TrimSpace(req.RuleType)
+	if req.TemplateID != nil {
+		t, ok := ownFeedbackTemplate(c, strconv.FormatUint(uint64(*req.TemplateID), 10))
+		if !ok {
+			return
+		}
+		if body == "" {
+			body = t.Body
+		} else {
+			body = t.Body + "\n\n" + body
+		}
+		if ruleType == "" {
+			ruleType = t.RuleType
+		}
+	}
+	if body == "" || utf8.RuneCountInString(body) > 8000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment text or template_id is required"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	role := c.GetString("role")
+	resultIDs := make([]int64, 0, len(req.ResultIDs))
+	for _, ref := range req.ResultIDs {
+		id, err := database.ResolveID("check_results", ref)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Result not found", "result_id": ref})
+			return
+		}
+		var owner, student sql.NullInt64
+		err = database.DB.QueryRow(`
+			SELECT s.created_by, d.user_id
+			FROM check_results cr
+			LEFT JOIN formatting_standards s ON s.id = cr.standard_id
+			LEFT JOIN documents d ON d.id = cr.document_id
+			WHERE cr.id = ?`, id).Scan(&owner, &student)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Result not found", "result_id": ref})
+			return
+		}
+		if role == "supervisor" {
+			if !supervises(userID, uint(student.Int64)) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You can only comment on checks of your students", "result_id": ref})
+				return
+			}
+		} else if role != "admin" && uint(owner.Int64) != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only comment on checks against your standards", "result_id": ref})
+			return
+		}
+		resultIDs = append(resultIDs, id)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save comments"})
+		return
+	}
+	now := database.Timestamp(time.Now())
+	for _, id := range resultIDs {
+		if _, err := tx.Exec("INSERT INTO result_comments (result_id, author_id, template_id, rule_type, body, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+			id, userID, req.TemplateID, ruleType, body, now); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save comments"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save comments"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"added": len(resultIDs), "body": body, "rule_type": ruleType})
+}
+
+// DeleteResultComment removes a comment; teachers remove their own.
+func DeleteResultComment(c *gin.Context) {
+	var authorID uint
+	if err := database.DB.QueryRow("SELECT author_id FROM result_comments WHERE id = ?", c.Param("id")).Scan(&authorID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if authorID != c.GetUint("user_id") && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own comments"})
+		return
+	}
+	if _, err := database.DB.Exec("DELETE FROM result_comments WHERE id = ?", c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted"})
+}
+
+// loadResultComments returns the teacher comments of a result, oldest first.
+func loadResultComments(resultID uint) []models.ResultComment {
+	comments := []models.ResultComment{}
+	rows, err := database.DB.Query(`
+		SELECT rc.id, rc.result_id, rc.author_id, COALESCE(u.full_name, ''), rc.template_id, COALESCE(rc.rule_type, ''), rc.body, rc.created_at
+		FROM result_comments rc
+		LEFT JOIN users u ON u.id = rc.author_id
+		WHERE rc.result_id = ?
+		ORDER BY rc.created_at, rc.id`, resultID)
+	if err != nil {
+		return comments
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rc models.ResultComment
+		var templateID sql.NullInt64
+		if err := rows.Scan(&rc.ID, &rc.ResultID, &rc.AuthorID, &rc.AuthorName, &templateID, &rc.RuleType, &rc.Body, &rc.CreatedAt); err != nil {
+			continue
+		}
+		if templateID.Valid {
+			id := uint(templateID.Int64)
+			rc.TemplateID = &id
+		}
+		comments = append(comments, rc)
+	}
+	return comments
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// assignmentFileNameRule returns the naming scheme of the assignment a work
+// was submitted to, or nil when it sets none and the standard's applies.
+func assignmentFileNameRule(assignmentID *uint) *checker.FileNameConfig {
+	if assignmentID == nil {
+		return nil
+	}
+	a, err := loadAssignment(uint64(*assignmentID))
+	if err != nil || a.FileNamePattern == "" {
+		return nil
+	}
+	return &checker.FileNameConfig{Pattern: a.FileNamePattern, Example: a.FileNameExample, Reject: a.FileNameReject}
+}
+
+// rejectFileName refuses an upload whose name does not follow the naming
+// scheme when the scheme asks for it, writing the error response. Other
+// schemes are left to the check, which reports the name as a violation.
+func rejectFileName(c *gin.Context, name, configJSON string, assignmentID *uint) bool {
+	var config struct {
+		FileName checker.FileNameConfig `json:"file_name"`
+	}
+	json.Unmarshal([]byte(configJSON), &config)
+	rule := config.FileName
+	if r := assignmentFileNameRule(assignmentID); r != nil {
+		rule = *r
+	}
+	if !rule.Reject || rule.Matches(name) {
+		return false
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "The file name does not follow the required scheme", "file_name": name, "expected": rule.Expected()})
+	return true
+}
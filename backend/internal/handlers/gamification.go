@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"database/sql"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// passingScore is the score from which a check counts as passed, as in the
+// admin statistics.
+const passingScore = 50
+
+// comebackGain is the score gain between attempts that earns "comeback".
+const comebackGain = 30
+
+// LeaderboardEntry is one anonymous place of a leaderboard. Students only
+// learn which entry is their own.
+type LeaderboardEntry struct {
+	Rank  int     `json:"rank"`
+	Value float64 `json:"value"`
+	IsMe  bool    `json:"is_me"`
+}
+
+// Leaderboard ranks the students of a group by their best score on a first
+// attempt and by the fewest attempts they needed to pass a standard.
+type Leaderboard struct {
+	GroupID          uint               `json:"group_id"`
+	GroupName        string             `json:"group_name"`
+	BestFirstAttempt []LeaderboardEntry `json:"best_first_attempt"`
+	FewestAttempts   []LeaderboardEntry `json:"fewest_attempts"`
+}
+
+// Achievement is a badge and whether the student has earned it.
+type Achievement struct {
+	Code        string `json:"code"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Earned      bool   `json:"earned"`
+	EarnedAt    string `json:"earned_at,omitempty"`
+}
+
+// attemptFacts describes one check of a student for the achievement rules.
+type attemptFacts struct {
+	score      float64
+	fontErrors int
+	first      bool    // first check of the student against this standard
+	lowest     float64 // lowest earlier score against this standard
+}
+
+var achievements = []struct {
+	code, title, description string
+	earned                   func(a attemptFacts) bool
+}{
+	{"first_pass", "Зачтено", "Работа прошла нормоконтроль",
+		func(a attemptFacts) bool { return a.score >= passingScore }},
+	{"first_try", "С первой попытки", "Работа прошла нормоконтроль с первой проверки",
+		func(a attemptFacts) bool { return a.first && a.score >= passingScore }},
+	{"zero_font_errors", "Шрифт без ошибок", "Работа прошла нормоконтроль без единой ошибки шрифта",
+		func(a attemptFacts) bool { return a.score >= passingScore && a.fontErrors == 0 }},
+	{"comeback", "Работа над ошибками", "Оценка выросла на 30 баллов и больше после исправлений",
+		func(a attemptFacts) bool { return !a.first && a.score-a.lowest >= comebackGain }},
+	{"perfect", "Без замечаний", "Оценка 100",
+		func(a attemptFacts) bool { return a.score >= 99.95 }},
+}
+
+func gamificationEnabled() bool {
+	var enabled bool
+	database.DB.QueryRow("SELECT COALESCE(gamification_enabled, FALSE) FROM branding WHERE id = 1").Scan(&enabled)
+	return enabled
+}
+
+// SetGamification turns leaderboards and achievements on or off for the
+// organization.
+func SetGamification(c *gin.Context) {
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := database.DB.Exec("UPDATE branding SET gamification_enabled = ?, updated_at = ? WHERE id = 1", req.Enabled, database.Timestamp(time.Now())); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+		return
+	}
+	invalidateResponses(cacheBranding)
+	GetBranding(c)
+}
+
+// GetLeaderboard returns the anonymous leaderboards of a group. Students see
+// their own group; teachers and admins choose one with ?group_id=.
+func GetLeaderboard(c *gin.Context) {
+	if !gamificationEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Leaderboards are disabled"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	var groupID uint
+	if c.GetString("role") == "student" {
+		var group sql.NullInt64
+		database.DB.QueryRow("SELECT group_id FROM users WHERE id = ?", userID).Scan(&group)
+		if !group.Valid {
+			c.JSON(http.StatusNotFound, gin.H{"error": "You are not in a group"})
+			return
+		}
+		groupID = uint(group.Int64)
+	} else {
+		id, err := strconv.ParseUint(c.Query("group_id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group_id is required"})
+			return
+		}
+		groupID = uint(id)
+	}
+
+	board := Leaderboard{GroupID: groupID, BestFirstAttempt: []LeaderboardEntry{}, FewestAttempts: []LeaderboardEntry{}}
+	if err := database.DB.QueryRow("SELECT group_name FROM student_groups WHERE id = ?", groupID).Scan(&board.GroupName); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	// Checks of the group's students, grouped into attempt series per
+	// student and standard.
+	rows, err := database.DB.Query(`
+		SELECT d.user_id, COALESCE(cr.standard_id, 0), cr.overall_score
+		FROM check_results cr
+		JOIN documents d ON cr.document_id = d.id
+		JOIN users u ON d.user_id = u.id
+		WHERE u.role = 'student' AND u.group_id = ?
+		ORDER BY d.user_id, cr.standard_id, cr.check_date, cr.id
+	`, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load leaderboard"})
+		return
+	}
+	defer rows.Close()
+
+	type series struct{ user, standard uint }
+	bestFirst := map[uint]float64{}
+	fewest := map[uint]int{}
+	attempts := map[series]int{}
+	passed := map[series]bool{}
+	for rows.Next() {
+		var s series
+		var score float64
+		if err := rows.Scan(&s.user, &s.standard, &score); err != nil {
+			continue
+		}
+		attempts[s]++
+		if attempts[s] == 1 {
+			if best, ok := bestFirst[s.user]; !ok || score > best {
+				bestFirst[s.user] = score
+			}
+		}
+		if score >= passingScore && !passed[s] {
+			passed[s] = true
+			if n, ok := fewest[s.user]; !ok || attempts[s] < n {
+				fewest[s.user] = attempts[s]
+			}
+		}
+	}
+
+	for user, score := range bestFirst {
+		board.BestFirstAttempt = append(board.BestFirstAttempt, LeaderboardEntry{Value: score, IsMe: user == userID})
+	}
+	for user, n := range fewest {
+		board.FewestAttempts = append(board.FewestAttempts, LeaderboardEntry{Value: float64(n), IsMe: user == userID})
+	}
+	rankEntries(board.BestFirstAttempt, true)
+	rankEntries(board.FewestAttempts, false)
+
+	c.JSON(http.StatusOK, board)
+}
+
+// rankEntries sorts a leaderboard and numbers it; equal values share a rank.
+func rankEntries(entries []LeaderboardEntry, descending bool) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if descending {
+			return entries[i].Value > entries[j].Value
+		}
+		return entries[i].Value < entries[j].Value
+	})
+	for i := range entries {
+		if i > 0 && entries[i].Value == entries[i-1].Value {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+}
+
+// GetAchievements returns all badges and which of them the current user has
+// earned, with the date of the check that earned each.
+func GetAchievements(c *gin.Context) {
+	if !gamificationEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Achievements are disabled"})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT COALESCE(cr.standard_id, 0), cr.check_date, cr.overall_score,
+		       (SELECT COUNT(*) FROM violations v WHERE v.result_id = cr.id AND v.rule_type IN ('font_name', 'font_size'))
+		FROM check_results cr
+		JOIN documents d ON cr.document_id = d.id
+		WHERE d.user_id = ?
+		ORDER BY cr.check_date, cr.id
+	`, c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load achievements"})
+		return
+	}
+	defer rows.Close()
+
+	result := make([]Achievement, len(achievements))
+	for i, a := range achievements {
+		result[i] = Achievement{Code: a.code, Title: a.title, Description: a.description}
+	}
+	lowest := map[uint]float64{}
+	for rows.Next() {
+		var standard uint
+		var checkDate time.Time
+		var facts attemptFacts
+		if err := rows.Scan(&standard, &checkDate, &facts.score, &facts.fontErrors); err != nil {
+			continue
+		}
+		low, seen := lowest[standard]
+		facts.first, facts.lowest = !seen, low
+		if !seen || facts.score < low {
+			lowest[standard] = facts.score
+		}
+		for i, a := range achievements {
+			if !result[i].Earned && a.earned(facts) {
+				result[i].Earned = true
+				result[i].EarnedAt = database.FormatTimestamp(checkDate)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Verdicts of a gradebook row.
+const (
+	verdictPassed       = "passed"
+	verdictFailed       = "failed"
+	verdictNotSubmitted = "not_submitted"
+)
+
+var verdictNames = map[string]string{
+	verdictPassed:       "зачтено",
+	verdictFailed:       "не зачтено",
+	verdictNotSubmitted: "не сдано",
+}
+
+// GradebookRow is one student of an assignment's gradebook. Scores and dates
+// are empty for students who never submitted.
+type GradebookRow struct {
+	StudentID    uint     `json:"student_id"`
+	StudentName  string   `json:"student_name"`
+	Email        string   `json:"email"`
+	Attempts     int      `json:"attempts"`
+	BestScore    *float64 `json:"best_score"`
+	LatestScore  *float64 `json:"latest_score"`
+	Verdict      string   `json:"verdict"` // see verdict* constants
+	SubmittedAt  string   `json:"submitted_at,omitempty"`
+	Late         bool     `json:"late"` // the latest submission came in late
+	LatestResult string   `json:"latest_result_id,omitempty"`
+}
+
+// GetAssignmentGradebook lists every student of the assignment's group with
+// the checks they submitted to it: attempts, best and latest score, the
+// verdict by the best score and the time of the latest submission. Students
+// who have left the group but submitted are listed too. ?format= selects
+// json (default), csv or xlsx.
+func GetAssignmentGradebook(c *gin.Context) {
+	a, ok := ownAssignment(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv or xlsx"})
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT u.id, COALESCE(u.full_name, ''), u.email, s.uuid, s.overall_score, s.check_date, COALESCE(s.submitted_late, FALSE)
+		FROM users u
+		LEFT JOIN (
+			SELECT d.user_id, cr.id, cr.uuid, cr.overall_score, cr.check_date, cr.submitted_late
+			FROM check_results cr
+			JOIN documents d ON d.id = cr.document_id
+			WHERE cr.assignment_id = ?
+		) s ON s.user_id = u.id
+		WHERE (u.role = 'student' AND u.group_id = ?) OR s.id IS NOT NULL
+		ORDER BY u.full_name, u.id, s.check_date, s.id`, a.ID, a.GroupID)
+	if err != nil {
+		fmt.Printf("Gradebook: failed to load submissions: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build gradebook"})
+		return
+	}
+	defer rows.Close()
+
+	gradebook := []GradebookRow{}
+	for rows.Next() {
+		var studentID uint
+		var name, email string
+		var resultID *string
+		var score *float64
+		var checkDate *time.Time
+		var late bool
+		if err := rows.Scan(&studentID, &name, &email, &resultID, &score, &checkDate, &late); err != nil {
+			continue
+		}
+		if len(gradebook) == 0 || gradebook[len(gradebook)-1].StudentID != studentID {
+			gradebook = append(gradebook, GradebookRow{StudentID: studentID, StudentName: name, Email: email, Verdict: verdictNotSubmitted})
+		}
+		if score == nil {
+			continue
+		}
+		row := &gradebook[len(gradebook)-1]
+		row.Attempts++
+		// Rows come oldest first, so the last one seen is the latest
+		row.LatestScore, row.Late = score, late
+		if checkDate != nil {
+			row.SubmittedAt = database.FormatTimestamp(*checkDate)
+		}
+		if resultID != nil {
+			row.LatestResult = *resultID
+		}
+		if row.BestScore == nil || *score > *row.BestScore {
+			row.BestScore = score
+		}
+		row.Verdict = verdictFailed
+		if *row.BestScore >= passingScore {
+			row.Verdict = verdictPassed
+		}
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"assignment": a, "rows": gradebook})
+		return
+	}
+
+	table := [][]interface{}{{"Студент", "Email", "Попыток", "Лучшая оценка", "Последняя оценка", "Итог", "Последняя сдача", "С опозданием"}}
+	for _, r := range gradebook {
+		line := []interface{}{r.StudentName, r.Email, r.Attempts, nil, nil, verdictNames[r.Verdict], r.SubmittedAt, ""}
+		if r.BestScore != nil {
+			line[3], line[4] = math.Round(*r.BestScore*10)/10, math.Round(*r.LatestScore*10)/10
+		}
+		if r.Late {
+			line[7] = "да"
+		}
+		table = append(table, line)
+	}
+
+	filename := fmt.Sprintf("gradebook-%d-%s.%s", a.ID, time.Now().UTC().Format("20060102"), format)
+	if err := respondTable(c, format, filename, "Ведомость", table); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build gradebook"})
+	}
+}
+
+// respondTable sends a table as a csv or xlsx attachment. The first row is
+// the header; cells are strings, numbers or nil for an empty cell.
+func respondTable(c *gin.Context, format, filename, sheetName string, table [][]interface{}) error {
+	var buf bytes.Buffer
+	if format == "xlsx" {
+		if err := writeXLSX(&buf, sheetName, table); err != nil {
+			return err
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", buf.Bytes())
+		return nil
+	}
+
+	// Excel opens a UTF-8 CSV correctly only with a byte order mark, and
+	// expects ";" as the separator and "," as the decimal mark in Russian
+	// locales.
+	buf.WriteString("\uFEFF")
+	w := csv.NewWriter(&buf)
+	w.Comma = ';'
+	for _, line := range table {
+		record := make([]string, len(line))
+		for i, v := range line {
+			switch v := v.(type) {
+			case nil:
+			case float64:
+				record[i] = strings.Replace(strconv.FormatFloat(v, 'f', 1, 64), ".", ",", 1)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	return nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// useTestDB points database.DB at a fresh, migrated database for the test.
+// The seeded admin and standard take the low ids; tests insert their own
+// records from id 100.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	database.InitDBAt(filepath.Join(t.TempDir(), "test.db"))
+	db := database.DB
+	t.Cleanup(func() { db.Close() })
+}
+
+// mustExec runs statements that set up a test, failing it on the first
+// error.
+func mustExec(t *testing.T, statements ...string) {
+	t.Helper()
+	for _, s := range statements {
+		if _, err := database.DB.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+}
+
+// serve runs handler for one request with a JSON body, or body itself when
+// it is a string, and the role of the user in the context.
+func serve(t *testing.T, handler gin.HandlerFunc, method, target string, body interface{}, role string, userID uint) *httptest.ResponseRecorder {
+	t.Helper()
+	var data []byte
+	switch b := body.(type) {
+	case nil:
+	case string:
+		data = []byte(b)
+	default:
+		var err error
+		if data, err = json.Marshal(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := gin.New()
+	r.Handle(method, "/*path", func(c *gin.Context) {
+		if role != "" {
+			c.Set("role", role)
+			c.Set("user_id", userID)
+		}
+		handler(c)
+	})
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// decode reads the JSON body of a response into v.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("response %d %s: %v", w.Code, w.Body.String(), err)
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCompareAttempts bounds the number of checks compared at once.
+const maxCompareAttempts = 10
+
+// CompareAttempt is one check in a comparison.
+type CompareAttempt struct {
+	ID           uint    `json:"id"`
+	UUID         string  `json:"uuid"`
+	DocumentName string  `json:"document_name"`
+	StandardName string  `json:"standard_name"`
+	CheckDate    string  `json:"check_date"`
+	Score        float64 `json:"score"`
+	Violations   int     `json:"violations"`
+}
+
+// CompareRule is the outcome of one rule type across the compared checks.
+// Counts holds the number of violations per attempt, in the order of
+// Attempts. Status compares the last attempt with the first one: fixed,
+// improved, unchanged, regressed or new.
+type CompareRule struct {
+	RuleType    string `json:"rule_type"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	Counts      []int  `json:"counts"`
+	Status      string `json:"status"`
+}
+
+type CompareResponse struct {
+	Attempts []CompareAttempt `json:"attempts"`
+	Rules    []CompareRule    `json:"rules"`
+}
+
+// CompareHistory lines up the violations of several checks of one student
+// (?ids= with ids or uuids, 2 to 10) rule by rule, oldest check first, so
+// the progress between attempts can be shown in one table. Access is as for
+// the check report: the student, the author of the standard or an admin; all
+// checks must belong to the same student. With ?from= and ?to= instead, two
+// checks of one document are diffed violation by violation (diffHistory).
+func CompareHistory(c *gin.Context) {
+	if c.Query("from") != "" || c.Query("to") != "" {
+		diffHistory(c)
+		return
+	}
+	var ids []int64
+	seen := map[int64]bool{}
+	for _, raw := range strings.Split(c.Query("ids"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		id, err := database.ResolveID("check_results", raw)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "History item not found: " + raw})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < 2 || len(ids) > maxCompareAttempts {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide between 2 and 10 distinct ids"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	isAdmin := c.GetString("role") == "admin"
+	var owner uint
+	attempts := make([]CompareAttempt, 0, len(ids))
+	dates := map[uint]time.Time{}
+	for i, id := range ids {
+		var a CompareAttempt
+		var checkDate time.Time
+		var ownerID, standardAuthor uint
+		err := database.DB.QueryRow(`
+			SELECT cr.id, COALESCE(cr.uuid, ''), d.file_name, COALESCE(s.name, ''), cr.check_date, cr.overall_score,
+			       d.user_id, COALESCE(s.created_by, 0)
+			FROM check_results cr
+			JOIN documents d ON cr.document_id = d.id
+			LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+			WHERE cr.id = ?
+		`, id).Scan(&a.ID, &a.UUID, &a.DocumentName, &a.StandardName, &checkDate, &a.Score, &ownerID, &standardAuthor)
+		if err != nil || (userID != ownerID && userID != standardAuthor && !isAdmin) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+			return
+		}
+		if i == 0 {
+			owner = ownerID
+		} else if ownerID != owner {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Checks belong to different students"})
+			return
+		}
+		a.CheckDate = database.FormatTimestamp(checkDate)
+		dates[a.ID] = checkDate
+		attempts = append(attempts, a)
+	}
+	sort.Slice(attempts, func(i, j int) bool {
+		di, dj := dates[attempts[i].ID], dates[attempts[j].ID]
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return attempts[i].ID < attempts[j].ID
+	})
+
+	// Rules are listed in the order they first occur, oldest attempt first.
+	rules := []CompareRule{}
+	index := map[string]int{}
+	for i := range attempts {
+		violations := localizeViolations(c, loadViolations(attempts[i].ID))
+		attempts[i].Violations = len(violations)
+		for _, v := range violations {
+			k, ok := index[v.RuleType]
+			if !ok {
+				k = len(rules)
+				index[v.RuleType] = k
+				rules = append(rules, CompareRule{
+					RuleType:    v.RuleType,
+					Description: v.Description,
+					Severity:    v.Severity,
+					Counts:      make([]int, len(attempts)),
+				})
+			}
+			rules[k].Counts[i]++
+		}
+	}
+	for i := range rules {
+		rules[i].Status = compareStatus(rules[i].Counts[0], rules[i].Counts[len(attempts)-1])
+	}
+
+	c.JSON(http.StatusOK, CompareResponse{Attempts: attempts, Rules: rules})
+}
+
+func compareStatus(first, last int) string {
+	switch {
+	case first > 0 && last == 0:
+		return "fixed"
+	case first == 0 && last > 0:
+		return "new"
+	case last < first:
+		return "improved"
+	case last > first:
+		return "regressed"
+	default:
+		return "unchanged"
+	}
+}
+
+// ViolationPair is a violation found by both checks of a diff.
+type ViolationPair struct {
+	From models.Violation `json:"from"`
+	To   models.Violation `json:"to"`
+}
+
+// DiffResponse is the difference between two checks of one document.
+type DiffResponse struct {
+	From    CompareAttempt     `json:"from"`
+	To      CompareAttempt     `json:"to"`
+	Fixed   []models.Violation `json:"fixed"`   // only in the earlier check
+	Persist []ViolationPair    `json:"persist"` // in both
+	New     []models.Violation `json:"new"`     // only in the later check
+}
+
+// diffHistory tells which violations of the ?from= check were fixed by the
+// ?to= check, which persist and which are new. Both checks must be of the
+// same document, or of a file of the same name uploaded again by the same
+// student, i.e. a later draft.
+func diffHistory(c *gin.Context) {
+	type side struct {
+		attempt  CompareAttempt
+		docID    int64
+		owner    uint
+		fileName string
+	}
+	userID := c.GetUint("user_id")
+	isAdmin := c.GetString("role") == "admin"
+	var sides [2]side
+	for i, raw := range []string{c.Query("from"), c.Query("to")} {
+		if strings.TrimSpace(raw) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Provide both from and to"})
+			return
+		}
+		id, err := database.ResolveID("check_results", strings.TrimSpace(raw))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "History item not found: " + raw})
+			return
+		}
+		s := &sides[i]
+		var checkDate time.Time
+		var standardAuthor uint
+		err = database.DB.QueryRow(`
+			SELECT cr.id, COALESCE(cr.uuid, ''), d.file_name, COALESCE(s.name, ''), cr.check_date, cr.overall_score,
+			       cr.document_id, d.user_id, COALESCE(s.created_by, 0)
+			FROM check_results cr
+			JOIN documents d ON cr.document_id = d.id
+			LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+			WHERE cr.id = ?
+		`, id).Scan(&s.attempt.ID, &s.attempt.UUID, &s.fileName, &s.attempt.StandardName, &checkDate, &s.attempt.Score,
+			&s.docID, &s.owner, &standardAuthor)
+		if err != nil || (userID != s.owner && userID != standardAuthor && !isAdmin) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+			return
+		}
+		s.attempt.DocumentName = s.fileName
+		s.attempt.CheckDate = database.FormatTimestamp(checkDate)
+	}
+	from, to := sides[0], sides[1]
+	if from.attempt.ID == to.attempt.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be different checks"})
+		return
+	}
+	if from.docID != to.docID && (from.owner != to.owner || from.fileName != to.fileName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Checks are not of the same document"})
+		return
+	}
+
+	before := localizeViolations(c, loadViolations(from.attempt.ID))
+	after := localizeViolations(c, loadViolations(to.attempt.ID))
+	from.attempt.Violations, to.attempt.Violations = len(before), len(after)
+	resp := DiffResponse{From: from.attempt, To: to.attempt, Fixed: []models.Violation{}, Persist: []ViolationPair{}, New: []models.Violation{}}
+
+	// Violations are paired at the same place first, then, as text moves
+	// between drafts, by rule and required value alone.
+	matched := make([]bool, len(after))
+	paired := make([]bool, len(before))
+	for _, key := range []func(models.Violation) string{
+		func(v models.Violation) string {
+			return v.RuleType + "\x00" + v.ExpectedValue + "\x00" + v.PositionInDoc
+		},
+		func(v models.Violation) string { return v.RuleType + "\x00" + v.ExpectedValue },
+	} {
+		open := map[string][]int{}
+		for j, v := range after {
+			if !matched[j] {
+				k := key(v)
+				open[k] = append(open[k], j)
+			}
+		}
+		for i, v := range before {
+			if paired[i] {
+				continue
+			}
+			k := key(v)
+			if candidates := open[k]; len(candidates) > 0 {
+				j := candidates[0]
+				open[k] = candidates[1:]
+				paired[i], matched[j] = true, true
+				resp.Persist = append(resp.Persist, ViolationPair{From: v, To: after[j]})
+			}
+		}
+	}
+	for i, v := range before {
+		if !paired[i] {
+			resp.Fixed = append(resp.Fixed, v)
+		}
+	}
+	for j, v := range after {
+		if !matched[j] {
+			resp.New = append(resp.New, v)
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
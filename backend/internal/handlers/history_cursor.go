@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxHistoryPageSize caps the ?limit= of the history endpoints.
+const maxHistoryPageSize = 100
+
+// historyPage is the keyset position requested with ?limit= and ?cursor=.
+// History is ordered by check_date and id, newest first; a cursor points at
+// the last item already shown, so checks that arrive while a client scrolls
+// do not shift or repeat the following pages.
+type historyPage struct {
+	limit int
+	after bool // false on the first page
+	date  string
+	id    uint
+}
+
+// parseHistoryPage reads ?limit= and ?cursor=. It returns nil when no limit is
+// given, in which case the whole history is returned as before. An invalid
+// value is answered with 400 and ok is false.
+func parseHistoryPage(c *gin.Context) (page *historyPage, ok bool) {
+	if c.Query("limit") == "" {
+		if c.Query("cursor") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor requires limit"})
+			return nil, false
+		}
+		return nil, true
+	}
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 || limit > maxHistoryPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and " + strconv.Itoa(maxHistoryPageSize)})
+		return nil, false
+	}
+	page = &historyPage{limit: limit}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if !page.decode(cursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return nil, false
+		}
+	}
+	return page, true
+}
+
+func (p *historyPage) decode(cursor string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return false
+	}
+	date, idText, found := strings.Cut(string(raw), "|")
+	if !found {
+		return false
+	}
+	if _, err := time.Parse(database.TimestampLayout, date); err != nil {
+		return false
+	}
+	id, err := strconv.ParseUint(idText, 10, 64)
+	if err != nil {
+		return false
+	}
+	p.after, p.date, p.id = true, date, uint(id)
+	return true
+}
+
+// where returns the condition selecting the items after the cursor (empty on
+// the first page) with its arguments.
+func (p *historyPage) where() (string, []interface{}) {
+	if p == nil || !p.after {
+		return "", nil
+	}
+	return " AND (cr.check_date < ? OR (cr.check_date = ? AND cr.id < ?))", []interface{}{p.date, p.date, p.id}
+}
+
+// sqlLimit returns the LIMIT clause. One extra row is fetched to tell whether
+// a next page exists.
+func (p *historyPage) sqlLimit() string {
+	if p == nil {
+		return ""
+	}
+	return " LIMIT " + strconv.Itoa(p.limit+1)
+}
+
+func encodeHistoryCursor(checkDate time.Time, id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(database.Timestamp(checkDate) + "|" + strconv.FormatUint(uint64(id), 10)))
+}
@@ -1,17 +1,19 @@
 package handlers
 
 import (
-	"academic-check-sys/internal/database"
-	"academic-check-sys/internal/models"
 	"database/sql"
 	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type HistoryItem struct {
 	ID           uint    `json:"id"` // CheckResult ID
+	UUID         string  `json:"uuid"`
 	DocumentName string  `json:"document_name"`
 	CheckDate    string  `json:"check_date"`
 	Score        float64 `json:"score"`
@@ -20,23 +22,39 @@ type HistoryItem struct {
 
 type TeacherHistoryItem struct {
 	ID           uint    `json:"id"`
+	UUID         string  `json:"uuid"`
 	StudentName  string  `json:"student_name"`
 	StandardName string  `json:"standard_name"`
 	CheckDate    string  `json:"check_date"`
 	Score        float64 `json:"score"`
+
+	SubmittedLate bool   `json:"submitted_late"`
+	LateNote      string `json:"late_note,omitempty"`
+
+	Metadata models.WorkMetadata `json:"metadata"`
 }
 
 func GetHistory(c *gin.Context) {
+	defer database.ObserveQuery("history", time.Now())
 	userID := c.GetUint("user_id")
 	// var userID uint = 1 // Use context user ID now
+	page, ok := parseHistoryPage(c)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where()
+	term, termArgs, ok := termFilter(c, "cr.")
+	if !ok {
+		return
+	}
+	args := append(append([]interface{}{userID}, termArgs...), afterArgs...)
 
 	rows, err := database.DB.Query(`
-		SELECT cr.id, d.file_name, cr.check_date, cr.overall_score, d.status
+		SELECT cr.id, COALESCE(cr.uuid, ''), d.file_name, cr.check_date, cr.overall_score, d.status
 		FROM check_results cr
 		JOIN documents d ON cr.document_id = d.id
-		WHERE d.user_id = ?
-		ORDER BY cr.check_date DESC
-	`, userID)
+		WHERE d.user_id = ?`+term+after+`
+		ORDER BY cr.check_date DESC, cr.id DESC`+page.sqlLimit(), args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
 		return
@@ -44,14 +62,23 @@ func GetHistory(c *gin.Context) {
 	defer rows.Close()
 
 	var response []HistoryItem
+	var nextCursor string
+	var lastDate time.Time
 	for rows.Next() {
+		if page != nil && len(response) == page.limit {
+			nextCursor = encodeHistoryCursor(lastDate, response[len(response)-1].ID)
+			break
+		}
 		var h HistoryItem
 		var score float64
-		if err := rows.Scan(&h.ID, &h.DocumentName, &h.CheckDate, &score, &h.Status); err != nil {
+		var checkDate time.Time
+		if err := rows.Scan(&h.ID, &h.UUID, &h.DocumentName, &checkDate, &score, &h.Status); err != nil {
 			continue
 		}
+		h.CheckDate = database.FormatTimestamp(checkDate)
 		h.Score = score
 		response = append(response, h)
+		lastDate = checkDate
 	}
 
 	if response == nil {
@@ -63,49 +90,81 @@ func GetHistory(c *gin.Context) {
 		fmt.Printf("📊 First item: DocumentName=%s, Score=%f\n", response[0].DocumentName, response[0].Score)
 	}
 
+	if page != nil {
+		c.JSON(http.StatusOK, gin.H{"items": response, "next_cursor": nextCursor})
+		return
+	}
 	c.JSON(http.StatusOK, response)
 }
 
 func GetHistoryDetail(c *gin.Context) {
-	id := c.Param("id")
+	id, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
 	userID := c.GetUint("user_id")
 
 	var result struct {
 		ID           uint
+		UUID         string
 		DocumentName string
-		CheckDate    string
+		CheckDate    time.Time
 		Score        float64
 		ContentJSON  string
+		Summary      sql.NullString
+		Metadata     models.WorkMetadata
 	}
 
-	err := database.DB.QueryRow(`
-		SELECT cr.id, d.file_name, cr.check_date, cr.overall_score, cr.content_json
+	err = database.DB.QueryRow(`
+		SELECT cr.id, COALESCE(cr.uuid, ''), d.file_name, cr.check_date, cr.overall_score, cr.content_json, cr.summary, `+metadataColumns("cr.")+`
 		FROM check_results cr
 		JOIN documents d ON cr.document_id = d.id
 		WHERE cr.id = ? AND d.user_id = ?
-	`, id, userID).Scan(&result.ID, &result.DocumentName, &result.CheckDate, &result.Score, &result.ContentJSON)
+	`, id, userID).Scan(&result.ID, &result.UUID, &result.DocumentName, &result.CheckDate, &result.Score, &result.ContentJSON, &result.Summary,
+		&result.Metadata.Topic, &result.Metadata.Supervisor, &result.Metadata.GroupName, &result.Metadata.SpecialtyCode)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
 		return
 	}
 
-	fetchViolationsAndRespond(c, result.ID, result.DocumentName, result.CheckDate, result.Score, result.ContentJSON)
+	fetchViolationsAndRespond(c, result.ID, result.UUID, result.DocumentName, database.FormatTimestamp(result.CheckDate), result.Score, result.ContentJSON, result.Summary.String, result.Metadata)
 }
 
 func GetTeacherHistory(c *gin.Context) {
-	teacherID := c.GetUint("user_id")
-
+	defer database.ObserveQuery("teacher_history", time.Now())
 	// Find checks against standards created by this teacher
+	respondCheckHistory(c, "GetTeacherHistory", "s.created_by = ?", c.GetUint("user_id"))
+}
+
+// respondCheckHistory lists the checks of students matching scope, a
+// condition on check_results cr, formatting_standards s, documents d and
+// users u, with the filters and paging of the teacher history.
+func respondCheckHistory(c *gin.Context, name, scope string, scopeArgs ...interface{}) {
+	page, ok := parseHistoryPage(c)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where()
+	filter, filterArgs := metadataFilter(c, "cr.")
+	term, termArgs, ok := termFilter(c, "cr.")
+	if !ok {
+		return
+	}
+	filter += term
+	filterArgs = append(filterArgs, termArgs...)
+	args := append(append(scopeArgs, filterArgs...), afterArgs...)
+
 	rows, err := database.DB.Query(`
-		SELECT cr.id, u.full_name, s.name, cr.check_date, cr.overall_score
+		SELECT cr.id, COALESCE(cr.uuid, ''), u.full_name, s.name, cr.check_date, cr.overall_score,
+		       COALESCE(cr.submitted_late, FALSE), COALESCE(cr.late_note, ''), `+metadataColumns("cr.")+`
 		FROM check_results cr
 		JOIN formatting_standards s ON cr.standard_id = s.id
 		JOIN documents d ON cr.document_id = d.id
 		JOIN users u ON d.user_id = u.id
-		WHERE s.created_by = ?
-		ORDER BY cr.check_date DESC
-	`, teacherID)
+		WHERE `+scope+filter+after+`
+		ORDER BY cr.check_date DESC, cr.id DESC`+page.sqlLimit(), args...)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch teacher history"})
@@ -114,138 +173,186 @@ func GetTeacherHistory(c *gin.Context) {
 	defer rows.Close()
 
 	var response []TeacherHistoryItem
+	var nextCursor string
+	var lastDate time.Time
 	for rows.Next() {
+		if page != nil && len(response) == page.limit {
+			nextCursor = encodeHistoryCursor(lastDate, response[len(response)-1].ID)
+			break
+		}
 		var h TeacherHistoryItem
 		var score float64
 		// full_name might be null if not set, handle scan carefully if needed,
 		// but User struct defines it as string so usually empty string if not NULL DB constraint.
 		// Assuming full_name is NOT NULL or we handle it.
-		if err := rows.Scan(&h.ID, &h.StudentName, &h.StandardName, &h.CheckDate, &score); err != nil {
+		var checkDate time.Time
+		if err := rows.Scan(&h.ID, &h.UUID, &h.StudentName, &h.StandardName, &checkDate, &score, &h.SubmittedLate, &h.LateNote,
+			&h.Metadata.Topic, &h.Metadata.Supervisor, &h.Metadata.GroupName, &h.Metadata.SpecialtyCode); err != nil {
 			continue
 		}
+		h.CheckDate = database.FormatTimestamp(checkDate)
 		h.Score = score
 		response = append(response, h)
+		lastDate = checkDate
 	}
 
 	if response == nil {
 		response = []TeacherHistoryItem{}
 	}
 
-	fmt.Printf("📊 GetTeacherHistory: Sending %d items\n", len(response))
+	fmt.Printf("📊 %s: Sending %d items\n", name, len(response))
 	if len(response) > 0 {
 		fmt.Printf("📊 First item: StudentName=%s, Score=%f\n", response[0].StudentName, response[0].Score)
 	}
 
+	if page != nil {
+		c.JSON(http.StatusOK, gin.H{"items": response, "next_cursor": nextCursor})
+		return
+	}
 	c.JSON(http.StatusOK, response)
 }
 
 func GetTeacherHistoryDetail(c *gin.Context) {
-	id := c.Param("id")
-	teacherID := c.GetUint("user_id")
+	// Verify the check belongs to a standard created by the teacher
+	respondCheckDetail(c, "s.created_by = ?", c.GetUint("user_id"))
+}
+
+// respondCheckDetail answers with the check of the :id parameter if it
+// matches scope, as respondCheckHistory.
+func respondCheckDetail(c *gin.Context, scope string, scopeArgs ...interface{}) {
+	id, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found or access denied"})
+		return
+	}
 
 	var result struct {
 		ID           uint
+		UUID         string
 		DocumentName string
 		StudentName  string
 		StandardName string
-		CheckDate    string
+		CheckDate    time.Time
 		Score        float64
 		ContentJSON  string
+		Summary      sql.NullString
+		Metadata     models.WorkMetadata
 	}
 
-	// Verify the check belongs to a standard created by the teacher
-	err := database.DB.QueryRow(`
-		SELECT cr.id, d.file_name, u.full_name, s.name, cr.check_date, cr.overall_score, cr.content_json
+	err = database.DB.QueryRow(`
+		SELECT cr.id, COALESCE(cr.uuid, ''), d.file_name, u.full_name, s.name, cr.check_date, cr.overall_score, cr.content_json, cr.summary, `+metadataColumns("cr.")+`
 		FROM check_results cr
 		JOIN formatting_standards s ON cr.standard_id = s.id
 		JOIN documents d ON cr.document_id = d.id
 		JOIN users u ON d.user_id = u.id
-		WHERE cr.id = ? AND s.created_by = ?
-	`, id, teacherID).Scan(&result.ID, &result.DocumentName, &result.StudentName, &result.StandardName, &result.CheckDate, &result.Score, &result.ContentJSON)
+		WHERE cr.id = ? AND `+scope+`
+	`, append([]interface{}{id}, scopeArgs...)...).Scan(&result.ID, &result.UUID, &result.DocumentName, &result.StudentName, &result.StandardName, &result.CheckDate, &result.Score, &result.ContentJSON, &result.Summary,
+		&result.Metadata.Topic, &result.Metadata.Supervisor, &result.Metadata.GroupName, &result.Metadata.SpecialtyCode)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found or access denied"})
 		return
 	}
 
-	fetchViolationsAndRespondTeacher(c, result.ID, result.DocumentName, result.StudentName, result.StandardName, result.CheckDate, result.Score, result.ContentJSON)
+	fetchViolationsAndRespondTeacher(c, result.ID, result.UUID, result.DocumentName, result.StudentName, result.StandardName, database.FormatTimestamp(result.CheckDate), result.Score, result.ContentJSON, result.Summary.String, result.Metadata)
 }
 
-func fetchViolationsAndRespondTeacher(c *gin.Context, resultID uint, docName, studentName, standardName, checkDate string, score float64, contentJSON string) {
-	rows, err := database.DB.Query(`
-		SELECT id, rule_type, description, severity, position_in_doc, expected_value, actual_value, suggestion
-		FROM violations
-		WHERE result_id = ?
-		ORDER BY id ASC
-	`, resultID)
-
-	var violations []models.Violation
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var v models.Violation
-			v.ResultID = resultID
-			var suggestion sql.NullString
-			if err := rows.Scan(&v.ID, &v.RuleType, &v.Description, &v.Severity, &v.PositionInDoc, &v.ExpectedValue, &v.ActualValue, &suggestion); err == nil {
-				if suggestion.Valid {
-					v.Suggestion = suggestion.String
-				}
-				violations = append(violations, v)
-			}
-		}
+func fetchViolationsAndRespondTeacher(c *gin.Context, resultID uint, resultUUID, docName, studentName, standardName, checkDate string, score float64, contentJSON, summary string, meta models.WorkMetadata) {
+	sarif, ok := wantsSARIF(c)
+	if !ok {
+		return
 	}
-
-	if violations == nil {
-		violations = []models.Violation{}
+	violations := loadViolations(resultID)
+	if sarif {
+		respondSARIF(c, resultUUID, docName, map[string]interface{}{
+			"student_name":  studentName,
+			"standard_name": standardName,
+			"check_date":    checkDate,
+			"score":         score,
+			"summary":       summary,
+			"metadata":      meta,
+		}, localizeViolations(c, violations))
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":            resultID,
-		"document_name": docName,
-		"student_name":  studentName,
-		"standard_name": standardName,
-		"check_date":    checkDate,
-		"score":         score,
-		"content_json":  contentJSON,
-		"violations":    violations,
+		"id":               resultID,
+		"uuid":             resultUUID,
+		"document_name":    docName,
+		"student_name":     studentName,
+		"standard_name":    standardName,
+		"check_date":       checkDate,
+		"score":            score,
+		"content_json":     contentJSON,
+		"summary":          summary,
+		"metadata":         meta,
+		"violations":       localizeViolations(c, violations),
+		"comments":         loadResultComments(resultID),
+		"standard_version": resultStandardVersion(resultID),
 	})
 }
 
 // Helper to fetch violations and send JSON response
-func fetchViolationsAndRespond(c *gin.Context, resultID uint, docName, checkDate string, score float64, contentJSON string) {
+func fetchViolationsAndRespond(c *gin.Context, resultID uint, resultUUID, docName, checkDate string, score float64, contentJSON, summary string, meta models.WorkMetadata) {
+	sarif, ok := wantsSARIF(c)
+	if !ok {
+		return
+	}
+	violations := loadViolations(resultID)
+	if sarif {
+		respondSARIF(c, resultUUID, docName, map[string]interface{}{
+			"check_date": checkDate,
+			"score":      score,
+			"summary":    summary,
+			"metadata":   meta,
+		}, localizeViolations(c, violations))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":               resultID,
+		"uuid":             resultUUID,
+		"document_name":    docName,
+		"check_date":       checkDate,
+		"score":            score,
+		"content_json":     contentJSON,
+		"summary":          summary,
+		"metadata":         meta,
+		"violations":       localizeViolations(c, violations),
+		"comments":         loadResultComments(resultID),
+		"standard_version": resultStandardVersion(resultID),
+	})
+}
+
+// loadViolations returns the violations of a check result in report order.
+func loadViolations(resultID uint) []models.Violation {
+	defer database.ObserveQuery("violations", time.Now())
 	rows, err := database.DB.Query(`
-		SELECT id, rule_type, description, severity, position_in_doc, expected_value, actual_value, suggestion
+		SELECT id, rule_type, description, severity, position_in_doc, expected_value, actual_value, suggestion,
+		       expected_num, actual_num, unit, expected_bound, location_json
 		FROM violations
 		WHERE result_id = ?
 		ORDER BY id ASC
 	`, resultID)
 
-	var violations []models.Violation
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var v models.Violation
-			v.ResultID = resultID
-			var suggestion sql.NullString
-			if err := rows.Scan(&v.ID, &v.RuleType, &v.Description, &v.Severity, &v.PositionInDoc, &v.ExpectedValue, &v.ActualValue, &suggestion); err == nil {
-				if suggestion.Valid {
-					v.Suggestion = suggestion.String
-				}
-				violations = append(violations, v)
+	violations := []models.Violation{}
+	if err != nil {
+		return violations
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v models.Violation
+		v.ResultID = resultID
+		var suggestion sql.NullString
+		var values violationValues
+		if err := rows.Scan(&v.ID, &v.RuleType, &v.Description, &v.Severity, &v.PositionInDoc, &v.ExpectedValue, &v.ActualValue, &suggestion,
+			&values.expected, &values.actual, &values.unit, &values.bound, &values.location); err == nil {
+			values.apply(&v)
+			if suggestion.Valid {
+				v.Suggestion = suggestion.String
 			}
+			violations = append(violations, v)
 		}
 	}
-
-	if violations == nil {
-		violations = []models.Violation{}
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"id":            resultID,
-		"document_name": docName,
-		"check_date":    checkDate,
-		"score":         score,
-		"content_json":  contentJSON,
-		"violations":    violations,
-	})
+	return violations
 }
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/settings"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Kinds of files removed by the upload janitor.
+const (
+	janitorTempTemplate = "temp_template"
+	janitorConvertedPDF = "converted_pdf"
+	janitorOrphanUpload = "orphaned_upload"
+	tempTemplatePrefix  = "temp_template_"
+	janitorUploadDir    = "./uploads"
+)
+
+var (
+	janitorFilesRemoved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upload_janitor_files_removed_total",
+		Help: "Files removed from the uploads directory by the janitor.",
+	}, []string{"kind"})
+	janitorBytesReclaimed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upload_janitor_reclaimed_bytes_total",
+		Help: "Disk space reclaimed by the upload janitor.",
+	}, []string{"kind"})
+)
+
+// JanitorReport summarises one cleanup run.
+type JanitorReport struct {
+	FilesRemoved   map[string]int   `json:"files_removed"`
+	BytesReclaimed map[string]int64 `json:"bytes_reclaimed"`
+	Errors         []string         `json:"errors,omitempty"`
+}
+
+// StartUploadJanitor periodically removes files from the uploads directory
+// that nothing refers to any more. The interval is the janitor.interval_minutes
+// setting (default 60, 0 disables the janitor) and files younger than
+// janitor.min_age_minutes (default 60) are never touched, so uploads still
+// being processed are safe.
+func StartUploadJanitor() {
+	interval := time.Duration(settings.Int("janitor.interval_minutes")) * time.Minute
+	if interval == 0 {
+		fmt.Println("Janitor: disabled")
+		return
+	}
+
+	go func() {
+		for {
+			report, err := cleanUploads(janitorUploadDir, janitorMinAge())
+			if err != nil {
+				fmt.Printf("Janitor: cleanup skipped: %v\n", err)
+			} else {
+				logJanitorReport(report)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func janitorMinAge() time.Duration {
+	return time.Duration(settings.Int("janitor.min_age_minutes")) * time.Minute
+}
+
+func logJanitorReport(r JanitorReport) {
+	total, bytes := 0, int64(0)
+	for kind, n := range r.FilesRemoved {
+		total += n
+		bytes += r.BytesReclaimed[kind]
+	}
+	if total > 0 || len(r.Errors) > 0 {
+		fmt.Printf("Janitor: removed %d files, reclaimed %d bytes, %d errors\n", total, bytes, len(r.Errors))
+	}
+}
+
+// RunUploadJanitor runs a cleanup immediately and returns what was removed.
+func RunUploadJanitor(c *gin.Context) {
+	report, err := cleanUploads(janitorUploadDir, janitorMinAge())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cleanup failed: " + err.Error()})
+		return
+	}
+	logJanitorReport(report)
+	c.JSON(http.StatusOK, report)
+}
+
+// referencedUploads returns the base names of the files in the uploads
+// directory that are still in use: documents (including those of failed jobs),
+// the branding logo and learning resource PDFs.
+func referencedUploads() (map[string]bool, error) {
+	referenced := map[string]bool{}
+	for _, query := range []string{
+		"SELECT file_path FROM documents",
+		"SELECT file_path FROM failed_jobs",
+		"SELECT logo_path FROM branding",
+		"SELECT file_path FROM learning_resources",
+	} {
+		rows, err := database.DB.Query(query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var path sql.NullString
+			if err := rows.Scan(&path); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if path.String != "" {
+				referenced[filepath.Base(path.String)] = true
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return referenced, nil
+}
+
+// cleanUploads deletes leftover extraction files, PDFs converted from
+// documents that no longer exist and uploads no document refers to. Nothing is
+// deleted when the references cannot be loaded.
+func cleanUploads(dir string, minAge time.Duration) (JanitorReport, error) {
+	report := JanitorReport{FilesRemoved: map[string]int{}, BytesReclaimed: map[string]int64{}}
+
+	referenced, err := referencedUploads()
+	if err != nil {
+		return report, fmt.Errorf("load referenced files: %w", err)
+	}
+	// A converted PDF belongs to the document with the same base name.
+	referencedStems := map[string]bool{}
+	for name := range referenced {
+		referencedStems[strings.TrimSuffix(name, filepath.Ext(name))] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return report, nil
+		}
+		return report, err
+	}
+
+	cutoff := time.Now().Add(-minAge)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		var kind string
+		switch {
+		case strings.HasPrefix(name, tempTemplatePrefix):
+			kind = janitorTempTemplate
+		case referenced[name]:
+			continue
+		case strings.EqualFold(filepath.Ext(name), ".pdf"):
+			if referencedStems[strings.TrimSuffix(name, filepath.Ext(name))] {
+				continue
+			}
+			kind = janitorConvertedPDF
+		default:
+			kind = janitorOrphanUpload
+		}
+
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.FilesRemoved[kind]++
+		report.BytesReclaimed[kind] += info.Size()
+		janitorFilesRemoved.WithLabelValues(kind).Inc()
+		janitorBytesReclaimed.WithLabelValues(kind).Add(float64(info.Size()))
+	}
+	return report, nil
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// learningResourcePDFMaxBytes bounds an uploaded PDF handout.
+const learningResourcePDFMaxBytes = 20 << 20
+
+const learningResourceColumns = "id, rule_type, title, kind, COALESCE(url, ''), COALESCE(file_path, ''), COALESCE(start_seconds, 0), COALESCE(created_by, 0), created_at"
+
+func scanLearningResource(row interface{ Scan(...interface{}) error }) (models.LearningResource, error) {
+	var r models.LearningResource
+	var filePath string
+	err := row.Scan(&r.ID, &r.RuleType, &r.Title, &r.Kind, &r.URL, &filePath, &r.StartSeconds, &r.CreatedBy, &r.CreatedAt)
+	if filePath != "" {
+		r.URL = "/api/uploads/" + filepath.Base(filePath)
+	}
+	return r, err
+}
+
+// loadLearningResources returns the resources of the given rule types, or of
+// all rule types when none are given, grouped by rule type.
+func loadLearningResources(ruleTypes ...string) (map[string][]models.LearningResource, error) {
+	query := "SELECT " + learningResourceColumns + " FROM learning_resources"
+	args := make([]interface{}, len(ruleTypes))
+	if len(ruleTypes) > 0 {
+		query += " WHERE rule_type IN (?" + strings.Repeat(", ?", len(ruleTypes)-1) + ")"
+		for i, t := range ruleTypes {
+			args[i] = t
+		}
+	}
+	rows, err := database.DB.Query(query+" ORDER BY rule_type, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byRule := map[string][]models.LearningResource{}
+	for rows.Next() {
+		r, err := scanLearningResource(rows)
+		if err != nil {
+			continue
+		}
+		byRule[r.RuleType] = append(byRule[r.RuleType], r)
+	}
+	return byRule, rows.Err()
+}
+
+// attachLearningResources sets the resources of each violation's rule type.
+// Violations are still served when the resources cannot be loaded.
+func attachLearningResources(violations []models.Violation) {
+	seen := map[string]bool{}
+	var ruleTypes []string
+	for _, v := range violations {
+		if !seen[v.RuleType] {
+			seen[v.RuleType] = true
+			ruleTypes = append(ruleTypes, v.RuleType)
+		}
+	}
+	if len(ruleTypes) == 0 {
+		return
+	}
+	byRule, err := loadLearningResources(ruleTypes...)
+	if err != nil {
+		fmt.Printf("attachLearningResources: %v\n", err)
+		return
+	}
+	for i := range violations {
+		violations[i].Resources = byRule[violations[i].RuleType]
+	}
+}
+
+// GetLearningResources lists the resources, optionally of one ?rule_type.
+func GetLearningResources(c *gin.Context) {
+	var ruleTypes []string
+	if ruleType := strings.TrimSpace(c.Query("rule_type")); ruleType != "" {
+		ruleTypes = append(ruleTypes, ruleType)
+	}
+	byRule, err := loadLearningResources(ruleTypes...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch learning resources"})
+		return
+	}
+	resources := []models.LearningResource{}
+	for _, rs := range byRule {
+		resources = append(resources, rs...)
+	}
+	c.JSON(http.StatusOK, resources)
+}
+
+type learningResourceRequest struct {
+	RuleType     string `json:"rule_type"`
+	Title        string `json:"title"`
+	Kind         string `json:"kind"`
+	URL          string `json:"url"`
+	StartSeconds int    `json:"start_seconds"`
+}
+
+// validateLearningResourceLabels checks the rule type and title shared by all resources.
+func validateLearningResourceLabels(ruleType, title string) error {
+	if ruleType == "" || utf8.RuneCountInString(ruleType) > 100 {
+		return fmt.Errorf("rule_type is required (up to 100 characters)")
+	}
+	if title == "" || utf8.RuneCountInString(title) > 200 {
+		return fmt.Errorf("Title is required (up to 200 characters)")
+	}
+	return nil
+}
+
+// validate normalizes a link or video resource. PDFs are uploaded as files.
+func (req *learningResourceRequest) validate() error {
+	req.RuleType = strings.TrimSpace(req.RuleType)
+	req.Title = strings.TrimSpace(req.Title)
+	req.URL = strings.TrimSpace(req.URL)
+	if err := validateLearningResourceLabels(req.RuleType, req.Title); err != nil {
+		return err
+	}
+	if req.Kind != models.ResourceLink && req.Kind != models.ResourceVideo {
+		return fmt.Errorf("kind must be link or video; PDFs are uploaded as files")
+	}
+	// Only web links: the URL is rendered as a link in the student's browser
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(req.URL) > 2000 {
+		return fmt.Errorf("url must be an http or https link")
+	}
+	if req.StartSeconds < 0 || req.Kind != models.ResourceVideo {
+		req.StartSeconds = 0
+	}
+	return nil
+}
+
+// CreateLearningResource attaches a link or a video fragment to a rule type.
+func CreateLearningResource(c *gin.Context) {
+	var req learningResourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := database.DB.Exec("INSERT INTO learning_resources (rule_type, title, kind, url, start_seconds, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		req.RuleType, req.Title, req.Kind, req.URL, req.StartSeconds, c.GetUint("user_id"), database.Timestamp(time.Now()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save learning resource"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	writeLearningResource(c, http.StatusCreated, id)
+}
+
+// UploadLearningResource attaches an uploaded PDF to a rule type.
+func UploadLearningResource(c *gin.Context) {
+	ruleType := strings.TrimSpace(c.PostForm("rule_type"))
+	title := strings.TrimSpace(c.PostForm("title"))
+	if err := validateLearningResourceLabels(ruleType, title); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if strings.ToLower(filepath.Ext(file.Filename)) != ".pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only PDF files are accepted"})
+		return
+	}
+	if file.Size > learningResourcePDFMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is larger than 20 MiB"})
+		return
+	}
+
+	uploadDir := "./uploads"
+	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
+		os.Mkdir(uploadDir, 0755)
+	}
+	savePath := filepath.Join(uploadDir, fmt.Sprintf("resource_%d.pdf", time.Now().UnixNano()))
+	if err := c.SaveUploadedFile(file, savePath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+
+	res, err := database.DB.Exec("INSERT INTO learning_resources (rule_type, title, kind, file_path, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		ruleType, title, models.ResourcePDF, savePath, c.GetUint("user_id"), database.Timestamp(time.Now()))
+	if err != nil {
+		os.Remove(savePath)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save learning resource"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	writeLearningResource(c, http.StatusCreated, id)
+}
+
+// UpdateLearningResource changes the rule type and title of a resource and,
+// for links and videos, the URL and start time. A PDF keeps its file.
+func UpdateLearningResource(c *gin.Context) {
+	r, err := scanLearningResource(database.DB.QueryRow("SELECT "+learningResourceColumns+" FROM learning_resources WHERE id = ?", c.Param("id")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Learning resource not found"})
+		return
+	}
+	var req learningResourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if r.Kind == models.ResourcePDF {
+		req.RuleType, req.Title = strings.TrimSpace(req.RuleType), strings.TrimSpace(req.Title)
+		if err := validateLearningResourceLabels(req.RuleType, req.Title); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		_, err = database.DB.Exec("UPDATE learning_resources SET rule_type = ?, title = ? WHERE id = ?", req.RuleType, req.Title, r.ID)
+	} else {
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		_, err = database.DB.Exec("UPDATE learning_resources SET rule_type = ?, title = ?, kind = ?, url = ?, start_seconds = ? WHERE id = ?",
+			req.RuleType, req.Title, req.Kind, req.URL, req.StartSeconds, r.ID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update learning resource"})
+		return
+	}
+	writeLearningResource(c, http.StatusOK, int64(r.ID))
+}
+
+// DeleteLearningResource removes a resource and its PDF.
+func DeleteLearningResource(c *gin.Context) {
+	var filePath sql.NullString
+	if err := database.DB.QueryRow("SELECT file_path FROM learning_resources WHERE id = ?", c.Param("id")).Scan(&filePath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Learning resource not found"})
+		return
+	}
+	if _, err := database.DB.Exec("DELETE FROM learning_resources WHERE id = ?", c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete learning resource"})
+		return
+	}
+	if filePath.String != "" {
+		os.Remove(filePath.String)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Learning resource deleted"})
+}
+
+func writeLearningResource(c *gin.Context, status int, id int64) {
+	r, err := scanLearningResource(database.DB.QueryRow("SELECT "+learningResourceColumns+" FROM learning_resources WHERE id = ?", id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load learning resource"})
+		return
+	}
+	c.JSON(status, r)
+}
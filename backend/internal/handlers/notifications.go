@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxNotifications bounds the notifications listed at once, newest first.
+const maxNotifications = 100
+
+// notify stores a notification for a user. Failures are only logged: the
+// event the user is told about has happened all the same.
+func notify(userID uint, kind, message, link string) {
+	_, err := database.DB.Exec("INSERT INTO notifications (user_id, kind, message, link, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, kind, message, link, database.Timestamp(time.Now()))
+	if err != nil {
+		fmt.Printf("notify: failed to notify user %d: %v\n", userID, err)
+	}
+}
+
+// GetNotifications lists the user's latest notifications; ?unread=true leaves
+// out the ones already read.
+func GetNotifications(c *gin.Context) {
+	query := "SELECT id, user_id, kind, message, COALESCE(link, ''), COALESCE(is_read, FALSE), created_at FROM notifications WHERE user_id = ?"
+	if c.Query("unread") == "true" {
+		query += " AND COALESCE(is_read, FALSE) = FALSE"
+	}
+	rows, err := database.DB.Query(query+" ORDER BY created_at DESC, id DESC LIMIT ?", c.GetUint("user_id"), maxNotifications)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.Message, &n.Link, &n.Read, &n.CreatedAt); err != nil {
+			continue
+		}
+		notifications = append(notifications, n)
+	}
+	c.JSON(http.StatusOK, notifications)
+}
+
+// MarkNotificationRead marks one of the user's notifications as read.
+func MarkNotificationRead(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+	res, err := database.DB.Exec("UPDATE notifications SET is_read = TRUE WHERE id = ? AND user_id = ?", id, c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
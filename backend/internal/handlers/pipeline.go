@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/convert"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"github.com/Ullyminat/NormoControl/backend/internal/settings"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// checkPipeline describes one run of the processing pipeline for a stored document.
+type checkPipeline struct {
+	DocID       int64
+	FilePath    string
+	ContentHash string
+	StandardID  int
+	ConfigJSON  string
+	Submission  submission
+
+	// WaitForPDF waits for the PDF conversion however long it takes, as
+	// background jobs do; requests stop waiting after CONVERT_WAIT_SECONDS.
+	WaitForPDF bool
+
+	tracker *stageTracker // set by the worker pool
+}
+
+// enter records stage as the document's current status. Once ctx is done it
+// records nothing and returns the context's error: the worker pool has given
+// up on the job and marked the document failed.
+func (p checkPipeline) enter(ctx context.Context, stage string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.tracker.Set(stage)
+	setDocumentStatus(p.DocID, stage, "")
+	return nil
+}
+
+type pipelineOutcome struct {
+	Result     *models.CheckResult
+	Violations []models.Violation
+	ResultID   int64
+	Status     string
+	Err        error
+}
+
+// runCheckPipeline parses, checks, persists and converts a document, recording the
+// current stage on the document row.
+func runCheckPipeline(ctx context.Context, p checkPipeline) pipelineOutcome {
+	return runPipelineFrom(ctx, p, models.StageParsing)
+}
+
+// runPipelineFrom runs the pipeline starting at stage: the check stages on the
+// check pool, then the PDF conversion on the conversion service, so slow
+// conversions do not hold check workers. A failed PDF conversion still returns
+// the saved result together with the "failed_converting" status; one that
+// outlasts the wait returns the "converting" status and finishes in the
+// background.
+func runPipelineFrom(ctx context.Context, p checkPipeline, stage string) pipelineOutcome {
+	out := getCheckPool().Run(ctx, p, stage)
+	if out.Err != nil {
+		return out
+	}
+	return convertPipelineResult(ctx, p, out)
+}
+
+// resumeCheckPipeline runs the check stages of the pipeline starting at stage
+// and leaves the document in the converting stage. Resuming at the
+// converting stage reuses the result saved for the document; earlier stages
+// re-run from the stored file, where the parse cache keeps an already parsed
+// document from being parsed again. Once ctx is done it stops without
+// touching the document and returns an outcome without a status.
+func resumeCheckPipeline(ctx context.Context, p checkPipeline, stage string) pipelineOutcome {
+	fail := func(stage string, err error) pipelineOutcome {
+		if ctx.Err() != nil {
+			return pipelineOutcome{Err: err}
+		}
+		status := models.FailedStatus(stage)
+		fmt.Printf("Pipeline: document %d failed at %s: %v\n", p.DocID, stage, err)
+		setDocumentStatus(p.DocID, status, err.Error())
+		return pipelineOutcome{Status: status, Err: err}
+	}
+
+	var out pipelineOutcome
+	if stage == models.StageConverting {
+		result, violations, err := loadLatestResult(p.DocID)
+		if err != nil {
+			return fail(models.StageConverting, err)
+		}
+		out = pipelineOutcome{Result: result, Violations: violations, ResultID: int64(result.ID)}
+	} else {
+		out = runCheckStages(ctx, p, fail)
+		if out.Err != nil {
+			return out
+		}
+	}
+
+	if err := p.enter(ctx, models.StageConverting); err != nil {
+		return fail(models.StageConverting, err)
+	}
+	out.Status = models.StageConverting
+	return out
+}
+
+// convertPipelineResult converts the checked document to PDF in the
+// background and waits for it as long as the caller wants.
+func convertPipelineResult(ctx context.Context, p checkPipeline, out pipelineOutcome) pipelineOutcome {
+	type converted struct {
+		contentJSON string
+		err         error
+	}
+	done := make(chan converted, 1)
+	contentJSON := out.Result.ContentJSON
+	go func() {
+		convertCtx, cancel := context.WithTimeout(context.Background(), maxConversionTime)
+		defer cancel()
+		content, err := convertResultPDF(convertCtx, out.ResultID, p.FilePath, contentJSON)
+		if err != nil {
+			fmt.Printf("Pipeline: document %d failed at %s: %v\n", p.DocID, models.StageConverting, err)
+			setDocumentStatus(p.DocID, models.FailedStatus(models.StageConverting), err.Error())
+		} else {
+			setDocumentStatus(p.DocID, models.DocStatusDone, "")
+		}
+		done <- converted{content, err}
+	}()
+
+	var waited <-chan time.Time
+	if !p.WaitForPDF {
+		timer := time.NewTimer(pdfWait())
+		defer timer.Stop()
+		waited = timer.C
+	}
+	select {
+	case c := <-done:
+		if c.err != nil {
+			out.Status, out.Err = models.FailedStatus(models.StageConverting), c.err
+			return out
+		}
+		out.Result.ContentJSON = c.contentJSON
+		out.Status = models.DocStatusDone
+	case <-waited:
+	case <-ctx.Done():
+	}
+	return out
+}
+
+// maxConversionTime bounds a background conversion, queueing included.
+const maxConversionTime = 15 * time.Minute
+
+// pdfWait is how long a request waits for the PDF: the
+// checks.pdf_wait_seconds setting, default 15. The document stays in the
+// converting stage after that, and the result gets its pdf_url once the
+// conversion finishes.
+func pdfWait() time.Duration {
+	return settings.Seconds("checks.pdf_wait_seconds")
+}
+
+// runCheckStages runs parsing, checking and saving; Err is set when a stage failed.
+func runCheckStages(ctx context.Context, p checkPipeline, fail func(string, error) pipelineOutcome) pipelineOutcome {
+	svc := checker.NewCheckService()
+	svc.StudentName = documentOwnerName(p.DocID)
+	svc.Dictionaries = loadDictionaries
+	svc.Defaults = checkDefaults()
+	svc.FileName = documentFileName(p.DocID)
+	svc.FileNameRule = assignmentFileNameRule(p.Submission.AssignmentID)
+
+	var result *models.CheckResult
+	var violations []models.Violation
+	if err := p.enter(ctx, models.StageParsing); err != nil {
+		return fail(models.StageParsing, err)
+	}
+	if checker.IsPresentation(p.FilePath) {
+		pres, err := svc.ParsePresentation(ctx, p.FilePath)
+		if err != nil {
+			return fail(models.StageParsing, err)
+		}
+		if err := p.enter(ctx, models.StageChecking); err != nil {
+			return fail(models.StageChecking, err)
+		}
+		if result, violations, err = svc.EvaluatePresentation(ctx, pres, p.ConfigJSON); err != nil {
+			return fail(models.StageChecking, err)
+		}
+	} else {
+		doc, err := svc.ParseCached(ctx, p.FilePath, p.ContentHash)
+		if err != nil {
+			var tooComplex *checker.ComplexityError
+			if errors.As(err, &tooComplex) {
+				return rejectComplexDocument(ctx, p, tooComplex, fail)
+			}
+			return fail(models.StageParsing, err)
+		}
+		if err := p.enter(ctx, models.StageChecking); err != nil {
+			return fail(models.StageChecking, err)
+		}
+		if result, violations, err = svc.Evaluate(ctx, doc, p.ConfigJSON); err != nil {
+			return fail(models.StageChecking, err)
+		}
+	}
+	p.Submission.apply(result)
+
+	if err := p.enter(ctx, models.StageSaving); err != nil {
+		return fail(models.StageSaving, err)
+	}
+	resultID, err := saveCheckResult(ctx, p.DocID, p.StandardID, result, violations)
+	if err != nil {
+		return fail(models.StageSaving, err)
+	}
+
+	return pipelineOutcome{Result: result, Violations: violations, ResultID: resultID}
+}
+
+// loadDocumentPipeline rebuilds the pipeline of a stored document from the
+// standard and config persisted with its last check request.
+func loadDocumentPipeline(docID int64) (checkPipeline, *models.Document, error) {
+	var doc models.Document
+	var contentHash, lastError, configJSON sql.NullString
+	var standardID sql.NullInt64
+	var s submission
+	var lateNote sql.NullString
+	err := database.DB.QueryRow(
+		"SELECT id, user_id, file_path, status, content_hash, last_error, standard_id, config_json, assignment_id, COALESCE(submitted_late, FALSE), late_note, "+metadataColumns("")+" FROM documents WHERE id = ?",
+		docID,
+	).Scan(&doc.ID, &doc.UserID, &doc.FilePath, &doc.Status, &contentHash, &lastError, &standardID, &configJSON, &s.AssignmentID, &s.Late, &lateNote,
+		&s.Meta.Topic, &s.Meta.Supervisor, &s.Meta.GroupName, &s.Meta.SpecialtyCode)
+	if err != nil {
+		return checkPipeline{}, nil, err
+	}
+	doc.ContentHash = contentHash.String
+	doc.LastError = lastError.String
+	doc.StandardID = int(standardID.Int64)
+	doc.ConfigJSON = configJSON.String
+	s.Note = lateNote.String
+	doc.Metadata = s.Meta
+
+	p := checkPipeline{
+		DocID:       docID,
+		FilePath:    doc.FilePath,
+		ContentHash: doc.ContentHash,
+		StandardID:  doc.StandardID,
+		ConfigJSON:  doc.ConfigJSON,
+		Submission:  s,
+	}
+	if p.ConfigJSON == "" {
+		p.ConfigJSON = DefaultStandard
+	}
+	if p.StandardID == 0 {
+		p.StandardID = 1
+	}
+	return p, &doc, nil
+}
+
+// rejectComplexDocument saves a report with the exceeded parser limit as its
+// only violation and leaves the document in the failed parsing state.
+func rejectComplexDocument(ctx context.Context, p checkPipeline, tooComplex *checker.ComplexityError, fail func(string, error) pipelineOutcome) pipelineOutcome {
+	result, violations := checker.ComplexityResult(tooComplex)
+	p.Submission.apply(result)
+	resultID, err := saveCheckResult(ctx, p.DocID, p.StandardID, result, violations)
+	if err != nil {
+		return fail(models.StageSaving, err)
+	}
+	out := fail(models.StageParsing, tooComplex)
+	out.Result, out.Violations, out.ResultID = result, violations, resultID
+	return out
+}
+
+// documentOwnerName returns the full name of the user who uploaded the
+// document, or "" when it is not known.
+func documentOwnerName(docID int64) string {
+	var name sql.NullString
+	database.DB.QueryRow("SELECT u.full_name FROM documents d JOIN users u ON d.user_id = u.id WHERE d.id = ?", docID).Scan(&name)
+	return name.String
+}
+
+// documentFileName returns the name the document was uploaded under.
+func documentFileName(docID int64) string {
+	var name sql.NullString
+	database.DB.QueryRow("SELECT file_name FROM documents WHERE id = ?", docID).Scan(&name)
+	return name.String
+}
+
+func setDocumentStatus(docID int64, status string, lastError string) {
+	if _, err := database.DB.Exec("UPDATE documents SET status = ?, last_error = ? WHERE id = ?", status, lastError, docID); err != nil {
+		fmt.Printf("Pipeline: failed to update status of document %d: %v\n", docID, err)
+	}
+}
+
+// saveCheckResult stores the result and its violations in a single transaction and
+// assigns the generated IDs back to the violations. Nothing is stored once ctx
+// is done.
+func saveCheckResult(ctx context.Context, docID int64, standardID int, result *models.CheckResult, violations []models.Violation) (int64, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	meta := result.Metadata
+	if result.TermID == nil {
+		result.TermID = checkTerm(result.AssignmentID, time.Now())
+	}
+	// The result points to a snapshot of the standard as it was checked
+	// against; without one the result is still saved.
+	var versionID interface{}
+	if standardID > 0 {
+		if id, err := snapshotStandard(tx, int64(standardID), 0, nil); err == nil {
+			versionID = id
+		} else if err != sql.ErrNoRows {
+			fmt.Printf("Pipeline: failed to snapshot standard %d: %v\n", standardID, err)
+		}
+	}
+	resCheck, err := tx.Exec("INSERT INTO check_results (document_id, standard_id, overall_score, total_rules, failed_rules, content_json, summary, assignment_id, submitted_late, late_note, fingerprint_json, topic, supervisor, group_name, specialty_code, term_id, standard_version_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		docID, standardID, result.OverallScore, result.TotalRules, result.FailedRules, result.ContentJSON, result.Summary, result.AssignmentID, result.SubmittedLate, result.LateNote, result.Fingerprint,
+		meta.Topic, meta.Supervisor, meta.GroupName, meta.SpecialtyCode, result.TermID, versionID)
+	if err != nil {
+		return 0, fmt.Errorf("insert result: %w", err)
+	}
+	checkID, _ := resCheck.LastInsertId()
+	var resultUUID string
+	if err := tx.QueryRow("SELECT uuid FROM check_results WHERE id = ?", checkID).Scan(&resultUUID); err != nil {
+		return 0, fmt.Errorf("read result uuid: %w", err)
+	}
+
+	if err := insertViolations(tx, checkID, violations); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	for i := range violations {
+		violations[i].ResultID = uint(checkID)
+	}
+	result.ID = uint(checkID)
+	result.UUID = resultUUID
+	return checkID, nil
+}
+
+// loadLatestResult reads back the most recent saved result of a document together
+// with its violations.
+func loadLatestResult(docID int64) (*models.CheckResult, []models.Violation, error) {
+	var r models.CheckResult
+	var resultUUID, contentJSON, summary, lateNote sql.NullString
+	err := database.DB.QueryRow(
+		"SELECT id, uuid, document_id, standard_id, overall_score, total_rules, failed_rules, content_json, summary, assignment_id, COALESCE(submitted_late, FALSE), late_note, "+metadataColumns("")+" FROM check_results WHERE document_id = ? ORDER BY id DESC LIMIT 1",
+		docID,
+	).Scan(&r.ID, &resultUUID, &r.DocumentID, &r.StandardID, &r.OverallScore, &r.TotalRules, &r.FailedRules, &contentJSON, &summary, &r.AssignmentID, &r.SubmittedLate, &lateNote,
+		&r.Metadata.Topic, &r.Metadata.Supervisor, &r.Metadata.GroupName, &r.Metadata.SpecialtyCode)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load saved result: %w", err)
+	}
+	r.UUID = resultUUID.String
+	r.ContentJSON = contentJSON.String
+	r.Summary = summary.String
+	r.LateNote = lateNote.String
+
+	violations, err := loadResultViolations(r.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &r, violations, nil
+}
+
+// insertViolations stores violations of result checkID and assigns their ids.
+func insertViolations(tx *sql.Tx, checkID int64, violations []models.Violation) error {
+	stmt, err := tx.Prepare("INSERT INTO violations (result_id, rule_type, description, severity, position_in_doc, expected_value, actual_value, suggestion, context_text, is_doubtful, expected_num, actual_num, unit, expected_bound, location_json) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare violations: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := range violations {
+		res, err := stmt.Exec(
+			checkID,
+			violations[i].RuleType,
+			violations[i].Description,
+			violations[i].Severity,
+			violations[i].PositionInDoc,
+			violations[i].ExpectedValue,
+			violations[i].ActualValue,
+			violations[i].Suggestion,
+			violations[i].ContextText,
+			violations[i].IsDoubtful,
+			violations[i].ExpectedNum,
+			violations[i].ActualNum,
+			violations[i].Unit,
+			violations[i].ExpectedBound,
+			locationJSON(violations[i].Location),
+		)
+		if err != nil {
+			return fmt.Errorf("insert violation: %w", err)
+		}
+		// Capture the real database ID and assign it back to the slice
+		if id, err := res.LastInsertId(); err == nil {
+			violations[i].ID = uint(id)
+		}
+	}
+	return nil
+}
+
+// loadResultViolations returns the stored violations of a check result in order.
+func loadResultViolations(resultID uint) ([]models.Violation, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, rule_type, description, severity, position_in_doc, expected_value, actual_value, suggestion, context_text, is_doubtful,
+		       expected_num, actual_num, unit, expected_bound, location_json
+		FROM violations
+		WHERE result_id = ?
+		ORDER BY id ASC
+	`, resultID)
+	if err != nil {
+		return nil, fmt.Errorf("load saved violations: %w", err)
+	}
+	defer rows.Close()
+
+	violations := []models.Violation{}
+	for rows.Next() {
+		var v models.Violation
+		var suggestion, contextText sql.NullString
+		var doubtful sql.NullBool
+		var values violationValues
+		if err := rows.Scan(&v.ID, &v.RuleType, &v.Description, &v.Severity, &v.PositionInDoc, &v.ExpectedValue, &v.ActualValue, &suggestion, &contextText, &doubtful,
+			&values.expected, &values.actual, &values.unit, &values.bound, &values.location); err != nil {
+			continue
+		}
+		values.apply(&v)
+		v.ResultID = resultID
+		v.Suggestion = suggestion.String
+		v.ContextText = contextText.String
+		v.IsDoubtful = doubtful.Bool
+		violations = append(violations, v)
+	}
+	return violations, nil
+}
+
+// convertResultPDF converts the stored DOCX to PDF with the conversion service
+// (unless a PDF from an earlier identical upload already exists) and links it
+// from the result's content_json for the frontend viewer. It returns the
+// content_json with the link; with conversion disabled it is left as is.
+func convertResultPDF(ctx context.Context, resultID int64, savePath string, contentJSON string) (string, error) {
+	pdfPath := strings.TrimSuffix(savePath, filepath.Ext(savePath)) + ".pdf"
+	pdfFilename := filepath.Base(pdfPath)
+
+	if _, err := os.Stat(pdfPath); err != nil {
+		if err := convert.Default().Convert(ctx, savePath, pdfPath); err != nil {
+			if errors.Is(err, convert.ErrDisabled) {
+				return contentJSON, nil
+			}
+			return contentJSON, fmt.Errorf("pdf conversion: %w", err)
+		}
+		fmt.Printf("PDF Conversion success: %s\n", pdfFilename)
+	}
+
+	contentJSON = withPDFURL(contentJSON, pdfFilename)
+	if _, err := database.DB.Exec("UPDATE check_results SET content_json = ? WHERE id = ?", contentJSON, resultID); err != nil {
+		return contentJSON, fmt.Errorf("store pdf link: %w", err)
+	}
+	return contentJSON, nil
+}
+
+// withPDFURL appends the pdf_url field to a serialized ParsedDoc object.
+func withPDFURL(contentJSON string, pdfFilename string) string {
+	if len(contentJSON) < 2 {
+		return contentJSON
+	}
+	return contentJSON[:len(contentJSON)-1] + fmt.Sprintf(`, "pdf_url": "/api/uploads/%s"}`, pdfFilename)
+}
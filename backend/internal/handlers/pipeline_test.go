@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"testing"
+)
+
+// A job the worker pool gave up on must not move the document on or store a
+// result behind the pool's back.
+func TestPipelineStopsAfterContextEnds(t *testing.T) {
+	useTestDB(t)
+	mustExec(t,
+		`INSERT INTO users (id, email, password_hash, role, full_name) VALUES (100, 's@uni.ru', 'x', 'student', 'Иванов И. И.')`,
+		`INSERT INTO documents (id, user_id, file_name, file_path, file_size, status) VALUES (100, 100, 'a.docx', 'a.docx', 1, 'failed_checking')`,
+		`INSERT INTO check_results (id, document_id, standard_id, overall_score, total_rules, failed_rules, content_json) VALUES (100, 100, 1, 80, 10, 2, '{}')`,
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := checkPipeline{DocID: 100, FilePath: "a.docx", StandardID: 1, tracker: &stageTracker{}}
+	for _, stage := range []string{models.StageParsing, models.StageConverting} {
+		out := resumeCheckPipeline(ctx, p, stage)
+		if out.Status != "" || !errors.Is(out.Err, context.Canceled) {
+			t.Fatalf("resume at %s: status %q, err %v; want no status and the context's error", stage, out.Status, out.Err)
+		}
+	}
+	if _, err := saveCheckResult(ctx, 100, 1, &models.CheckResult{}, nil); err == nil {
+		t.Fatal("saveCheckResult stored a result after the context ended")
+	}
+
+	var status string
+	var results int
+	database.DB.QueryRow("SELECT status FROM documents WHERE id = 100").Scan(&status)
+	database.DB.QueryRow("SELECT COUNT(*) FROM check_results WHERE document_id = 100").Scan(&results)
+	if status != "failed_checking" || results != 1 {
+		t.Fatalf("document status %q with %d results; want failed_checking with 1", status, results)
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecheckResult checks the document of one of the student's results again,
+// from the stored file, against the current version of the standard. The
+// new result is saved next to the old one.
+func RecheckResult(c *gin.Context) {
+	recheckResult(c, false)
+}
+
+// RecheckTeacherResult is RecheckResult for the author of the standard the
+// result was checked against, e.g. after tweaking it; admins may recheck any
+// result.
+func RecheckTeacherResult(c *gin.Context) {
+	recheckResult(c, true)
+}
+
+type recheckRequest struct {
+	ModuleID string `json:"module_id"` // module of the standard to check against
+}
+
+// errModuleUnknown is returned when the module a document was checked
+// against is not known and the standard has more than one.
+var errModuleUnknown = errors.New("The standard has several modules; choose one with module_id")
+
+// currentModuleConfig returns the current config of the standard's module to
+// check a document against again and the module's id: the module asked for,
+// else the module the document was checked against, else the only module of
+// the standard.
+func currentModuleConfig(modulesJSON, moduleID string) (string, string, error) {
+	var modules []models.ValidationModule
+	if err := json.Unmarshal([]byte(modulesJSON), &modules); err != nil || len(modules) == 0 {
+		return "", "", errors.New("The standard has no modules")
+	}
+	var match *models.ValidationModule
+	switch {
+	case moduleID != "":
+		for i := range modules {
+			if modules[i].ID == moduleID {
+				match = &modules[i]
+			}
+		}
+		if match == nil {
+			return "", "", errors.New("Module not found in the standard")
+		}
+	case len(modules) == 1:
+		match = &modules[0]
+	default:
+		return "", "", errModuleUnknown
+	}
+	config, err := json.Marshal(match.Config)
+	return string(config), match.ID, err
+}
+
+// uploadModuleID returns the module_id form value of an upload checked
+// against a standard, the module whose config it sends, so a re-check finds
+// the module again. An id the standard has no module for is rejected.
+func uploadModuleID(c *gin.Context, standardID int64) (string, bool) {
+	moduleID := strings.TrimSpace(c.PostForm("module_id"))
+	if moduleID == "" {
+		return "", true
+	}
+	var modulesJSON sql.NullString
+	database.DB.QueryRow("SELECT modules_json FROM formatting_standards WHERE id = ?", standardID).Scan(&modulesJSON)
+	if _, _, err := currentModuleConfig(modulesJSON.String, moduleID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Module not found in the standard"})
+		return "", false
+	}
+	return moduleID, true
+}
+
+func recheckResult(c *gin.Context, teacher bool) {
+	id, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+	var req recheckRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var docID int64
+	var standardID int
+	var ownerID, standardAuthor uint
+	var resultUUID, filePath, checkedModule string
+	var modulesJSON sql.NullString
+	err = database.DB.QueryRow(`
+		SELECT COALESCE(cr.uuid, ''), cr.document_id, COALESCE(cr.standard_id, 0), d.user_id, d.file_path, COALESCE(d.module_id, ''), COALESCE(s.created_by, 0), s.modules_json
+		FROM check_results cr
+		JOIN documents d ON cr.document_id = d.id
+		LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+		WHERE cr.id = ?
+	`, id).Scan(&resultUUID, &docID, &standardID, &ownerID, &filePath, &checkedModule, &standardAuthor, &modulesJSON)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	allowed := userID == ownerID
+	if teacher {
+		allowed = userID == standardAuthor || c.GetString("role") == "admin"
+	}
+	if !allowed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+	if !modulesJSON.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "The standard of this check no longer exists"})
+		return
+	}
+	moduleID := req.ModuleID
+	if moduleID == "" {
+		moduleID = checkedModule
+	}
+	configJSON, moduleID, err := currentModuleConfig(modulesJSON.String, moduleID)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Stored file is no longer available, please upload it again"})
+		return
+	}
+
+	// Claim the document, so a check still running on it is not overlapped.
+	res, err := database.DB.Exec("UPDATE documents SET status = ?, last_error = '', standard_id = ?, config_json = ?, module_id = ? WHERE id = ? AND (status = ? OR status LIKE ?)",
+		models.DocStatusUploaded, standardID, configJSON, moduleID, docID, models.DocStatusDone, models.FailedStatus("")+"%")
+	if err != nil {
+		fmt.Printf("RecheckResult: failed to claim document %d: %v\n", docID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Re-check failed"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "The document is being checked, try again when it is done"})
+		return
+	}
+
+	p, _, err := loadDocumentPipeline(docID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+	fmt.Printf("RecheckResult: re-checking document %d against the current standard %d\n", docID, standardID)
+
+	out := runCheckPipeline(c.Request.Context(), p)
+	if out.Err == nil {
+		discardFailedJobs(p.DocID)
+	}
+	if respondPipelineError(c, docID, out, "Re-check failed") {
+		return
+	}
+
+	resp := checkResponse(c, docID, out)
+	resp["previous_uuid"] = resultUUID
+	c.JSON(http.StatusOK, resp)
+}
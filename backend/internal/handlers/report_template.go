@@ -0,0 +1,510 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reportTemplateMaxBytes bounds an uploaded template.
+const reportTemplateMaxBytes = 256 << 10
+
+// reportOutputMaxBytes bounds a rendered report, so a template looping over
+// the violations many times cannot exhaust memory.
+const reportOutputMaxBytes = 5 << 20
+
+// ReportData is what a report template is executed with.
+type ReportData struct {
+	Branding     models.Branding
+	ResultUUID   string
+	DocumentName string
+	StudentName  string
+	StandardName string
+	Metadata     models.WorkMetadata // topic, supervisor, group and specialty of the work
+	CheckDate    time.Time           // in the display time zone
+	Score        float64
+	Summary      string
+	Violations   []models.Violation
+	Manual       []models.Violation // requirements checked by hand (models.SeverityManual)
+	Blocking     int                // violations that count against the score
+	Advisory     int                // info and hint violations
+	GeneratedAt  time.Time
+}
+
+var reportFuncs = template.FuncMap{
+	"inc":   func(i int) int { return i + 1 },
+	"date":  func(t time.Time) string { return t.Format("02.01.2006") },
+	"score": func(f float64) string { return fmt.Sprintf("%.1f", f) },
+	"severity": func(s string) string {
+		switch s {
+		case models.SeverityCritical:
+			return "Критично"
+		case models.SeverityError:
+			return "Ошибка"
+		case models.SeverityWarning:
+			return "Предупреждение"
+		case models.SeverityInfo:
+			return "Информация"
+		case models.SeverityHint:
+			return "Совет"
+		case models.SeverityIntegrity:
+			return "Добросовестность"
+		case models.SeverityManual:
+			return "Вручную"
+		}
+		return s
+	},
+	"blocking": models.IsBlocking,
+	"lines": func(s string) []string {
+		if strings.TrimSpace(s) == "" {
+			return nil
+		}
+		return strings.Split(s, "\n")
+	},
+}
+
+// defaultReportTemplate is the built-in лист нормоконтроля. Admins can
+// download it as a starting point for their own layout.
+const defaultReportTemplate = `<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Лист нормоконтроля — {{.DocumentName}}</title>
+<style>
+	@page { size: A4; margin: 20mm 15mm 20mm 25mm; }
+	body { font-family: "Times New Roman", serif; font-size: 12pt; color: #000; }
+	header, footer { text-align: center; }
+	header img { max-height: 64px; }
+	h1 { font-size: 14pt; text-align: center; text-transform: uppercase; margin: 16pt 0; }
+	h2 { font-size: 12pt; margin: 16pt 0 8pt; }
+	table { width: 100%; border-collapse: collapse; }
+	.meta td { padding: 2pt 0; vertical-align: top; }
+	.meta td:first-child { width: 35%; }
+	.violations th, .violations td { border: 1px solid #000; padding: 3pt 4pt; vertical-align: top; font-size: 10pt; }
+	.signatures { margin-top: 32pt; }
+	.signatures td { padding-top: 18pt; }
+	footer { margin-top: 24pt; font-size: 10pt; }
+</style>
+</head>
+<body>
+<header>
+	{{if .Branding.LogoURL}}<img src="{{.Branding.LogoURL}}" alt=""><br>{{end}}
+	{{if .Branding.InstitutionName}}<strong>{{.Branding.InstitutionName}}</strong><br>{{end}}
+	{{range lines .Branding.ReportHeader}}{{.}}<br>{{end}}
+</header>
+
+<h1>Лист нормоконтроля</h1>
+
+<table class="meta">
+	<tr><td>Документ:</td><td>{{.DocumentName}}</td></tr>
+	<tr><td>Обучающийся:</td><td>{{.StudentName}}</td></tr>
+	{{with .Metadata}}
+	{{if .GroupName}}<tr><td>Группа:</td><td>{{.GroupName}}</td></tr>{{end}}
+	{{if .SpecialtyCode}}<tr><td>Специальность:</td><td>{{.SpecialtyCode}}</td></tr>{{end}}
+	{{if .Topic}}<tr><td>Тема работы:</td><td>{{.Topic}}</td></tr>{{end}}
+	{{if .Supervisor}}<tr><td>Руководитель:</td><td>{{.Supervisor}}</td></tr>{{end}}
+	{{end}}
+	<tr><td>Стандарт оформления:</td><td>{{.StandardName}}</td></tr>
+	<tr><td>Дата проверки:</td><td>{{date .CheckDate}}</td></tr>
+	<tr><td>Оценка соответствия:</td><td>{{score .Score}}%</td></tr>
+	<tr><td>Замечаний:</td><td>{{.Blocking}}{{if .Advisory}} (и {{.Advisory}} рекомендаций){{end}}</td></tr>
+</table>
+
+{{if .Summary}}<p>{{.Summary}}</p>{{end}}
+
+{{if .Violations}}
+<table class="violations">
+	<tr><th>№</th><th>Место</th><th>Замечание</th><th>Требуется</th><th>Фактически</th><th>Важность</th></tr>
+	{{range $i, $v := .Violations}}
+	<tr>
+		<td>{{inc $i}}</td>
+		<td>{{$v.PositionInDoc}}</td>
+		<td>{{$v.Description}}</td>
+		<td>{{$v.ExpectedValue}}</td>
+		<td>{{$v.ActualValue}}</td>
+		<td>{{severity $v.Severity}}</td>
+	</tr>
+	{{end}}
+</table>
+{{else}}
+<p>Замечаний по оформлению нет.</p>
+{{end}}
+
+{{if .Manual}}
+<h2>Проверяется нормоконтролёром</h2>
+<table class="violations">
+	<tr><th>№</th><th>Требование</th><th>Почему вручную</th><th>Отметка</th></tr>
+	{{range $i, $v := .Manual}}
+	<tr>
+		<td>{{inc $i}}</td>
+		<td>{{$v.Description}}</td>
+		<td>{{$v.Suggestion}}</td>
+		<td></td>
+	</tr>
+	{{end}}
+</table>
+{{end}}
+
+<table class="signatures">
+	<tr><td>Нормоконтролёр</td><td>____________ / ______________________ /</td></tr>
+	<tr><td>Обучающийся</td><td>____________ / ______________________ /</td></tr>
+	{{if .Metadata.Supervisor}}<tr><td>Руководитель</td><td>____________ / {{.Metadata.Supervisor}} /</td></tr>{{end}}
+</table>
+
+<footer>
+	{{range lines .Branding.ReportFooter}}{{.}}<br>{{end}}
+	Сформировано {{date .GeneratedAt}}
+</footer>
+</body>
+</html>
+`
+
+// parseReportTemplate compiles a template body and checks that it renders
+// with sample data, so broken templates are rejected on upload.
+func parseReportTemplate(body string) (*template.Template, error) {
+	tmpl, err := template.New("report").Funcs(reportFuncs).Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := renderReport(tmpl, sampleReportData()); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+var errReportTooLarge = errors.New("rendered report is too large")
+
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > reportOutputMaxBytes {
+		return 0, errReportTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+func renderReport(tmpl *template.Template, data ReportData) ([]byte, error) {
+	var buf limitedBuffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// activeReportTemplate returns the active admin template, or the built-in one
+// when none is active or the stored one no longer parses.
+func activeReportTemplate() *template.Template {
+	var name, body string
+	err := database.DB.QueryRow("SELECT name, body FROM report_templates WHERE is_active ORDER BY id DESC LIMIT 1").Scan(&name, &body)
+	if err == nil {
+		tmpl, err := template.New("report").Funcs(reportFuncs).Parse(body)
+		if err == nil {
+			return tmpl
+		}
+		fmt.Printf("Report: active template %q does not parse, using the built-in one: %v\n", name, err)
+	}
+	return template.Must(template.New("report").Funcs(reportFuncs).Parse(defaultReportTemplate))
+}
+
+func sampleReportData() ReportData {
+	branding, _, _ := loadBranding()
+	expected, actual := 14.0, 12.0
+	return ReportData{
+		Branding:     branding,
+		ResultUUID:   "00000000-0000-0000-0000-000000000000",
+		DocumentName: "Курсовая_работа.docx",
+		StudentName:  "Иванов Иван Иванович",
+		StandardName: "ГОСТ 7.32-2017",
+		CheckDate:    time.Now().In(displayLocation()),
+		Score:        87.5,
+		Summary:      "Документ в целом оформлен верно; исправьте размер шрифта и подпись рисунка.",
+		Violations: []models.Violation{
+			{RuleType: "font_size", Description: "Неверный размер шрифта", Severity: models.SeverityError,
+				PositionInDoc: "Абзац 12", ExpectedValue: "14 pt", ActualValue: "12 pt",
+				ExpectedNum: &expected, ActualNum: &actual, Unit: models.UnitPoint},
+			{RuleType: "image_caption_missing", Description: "У рисунка нет подписи", Severity: models.SeverityWarning,
+				PositionInDoc: "Рисунок 3", ExpectedValue: "Рисунок N – Название", ActualValue: "Подпись отсутствует"},
+			{RuleType: "line_spacing", Description: "Межстрочный интервал отличается от рекомендованного", Severity: models.SeverityHint,
+				PositionInDoc: "Абзац 40", ExpectedValue: "1.5", ActualValue: "1.15"},
+		},
+		Manual: []models.Violation{
+			{RuleType: "manual_check", Description: "Соответствие темы работы приказу", Severity: models.SeverityManual,
+				PositionInDoc: "Весь документ", ActualValue: "Требуется проверка нормоконтролёром",
+				Suggestion: "Сверяется с приказом об утверждении тем"},
+		},
+		Blocking:    2,
+		Advisory:    1,
+		GeneratedAt: time.Now().In(displayLocation()),
+		Metadata: models.WorkMetadata{
+			Topic:         "Разработка информационной системы учёта успеваемости",
+			Supervisor:    "Петров Пётр Петрович",
+			GroupName:     "ИСП-31",
+			SpecialtyCode: "09.02.07",
+		},
+	}
+}
+
+func writeReport(c *gin.Context, tmpl *template.Template, data ReportData) {
+	out, err := renderReport(tmpl, data)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to render report: " + err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", out)
+}
+
+// GetCheckReport renders the лист нормоконтроля of a check result as printable
+// HTML using the active report template. The student who uploaded the document,
+// the author of the standard and admins may open it.
+func GetCheckReport(c *gin.Context) {
+	id, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	var data ReportData
+	var checkDate time.Time
+	var resultID, ownerID, standardAuthor uint
+	err = database.DB.QueryRow(`
+		SELECT cr.id, COALESCE(cr.uuid, ''), d.file_name, COALESCE(u.full_name, ''), COALESCE(s.name, ''),
+		       cr.check_date, cr.overall_score, COALESCE(cr.summary, ''), d.user_id, COALESCE(s.created_by, 0),
+		       `+metadataColumns("cr.")+`
+		FROM check_results cr
+		JOIN documents d ON cr.document_id = d.id
+		JOIN users u ON d.user_id = u.id
+		LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+		WHERE cr.id = ?
+	`, id).Scan(&resultID, &data.ResultUUID, &data.DocumentName, &data.StudentName, &data.StandardName,
+		&checkDate, &data.Score, &data.Summary, &ownerID, &standardAuthor,
+		&data.Metadata.Topic, &data.Metadata.Supervisor, &data.Metadata.GroupName, &data.Metadata.SpecialtyCode)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	if userID != ownerID && userID != standardAuthor && c.GetString("role") != "admin" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	data.Branding, _, _ = loadBranding()
+	data.CheckDate = checkDate.In(displayLocation())
+	data.GeneratedAt = time.Now().In(displayLocation())
+	violations := localizeViolations(c, loadViolations(resultID))
+	data.Violations = make([]models.Violation, 0, len(violations))
+	for _, v := range violations {
+		switch {
+		case v.Severity == models.SeverityManual:
+			data.Manual = append(data.Manual, v)
+			continue
+		case models.IsBlocking(v.Severity):
+			data.Blocking++
+		default:
+			data.Advisory++
+		}
+		data.Violations = append(data.Violations, v)
+	}
+
+	writeReport(c, activeReportTemplate(), data)
+}
+
+// readTemplateUpload reads the "template" file of a multipart request.
+func readTemplateUpload(c *gin.Context) (string, bool) {
+	file, err := c.FormFile("template")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return "", false
+	}
+	if file.Size > reportTemplateMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Template is larger than 256 KiB"})
+		return "", false
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return "", false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, reportTemplateMaxBytes+1))
+	if err != nil || len(data) > reportTemplateMaxBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return "", false
+	}
+	if !utf8.Valid(data) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template must be UTF-8 text"})
+		return "", false
+	}
+	return string(data), true
+}
+
+// GetReportTemplates lists the uploaded templates without their bodies.
+func GetReportTemplates(c *gin.Context) {
+	rows, err := database.DB.Query(`
+		SELECT id, name, is_active, COALESCE(created_by, 0), created_at, updated_at
+		FROM report_templates
+		ORDER BY id DESC
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch report templates"})
+		return
+	}
+	defer rows.Close()
+
+	templates := []models.ReportTemplate{}
+	for rows.Next() {
+		var t models.ReportTemplate
+		if err := rows.Scan(&t.ID, &t.Name, &t.IsActive, &t.CreatedBy, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			continue
+		}
+		templates = append(templates, t)
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetDefaultReportTemplate returns the built-in template as a starting point.
+func GetDefaultReportTemplate(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"name": "Встроенный шаблон", "body": defaultReportTemplate})
+}
+
+func loadReportTemplate(c *gin.Context) (models.ReportTemplate, bool) {
+	var t models.ReportTemplate
+	err := database.DB.QueryRow(`
+		SELECT id, name, body, is_active, COALESCE(created_by, 0), created_at, updated_at
+		FROM report_templates WHERE id = ?
+	`, c.Param("id")).Scan(&t.ID, &t.Name, &t.Body, &t.IsActive, &t.CreatedBy, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return t, false
+	}
+	return t, true
+}
+
+// GetReportTemplate returns one template including its body.
+func GetReportTemplate(c *gin.Context) {
+	if t, ok := loadReportTemplate(c); ok {
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+// UploadReportTemplate stores a new template. It is validated by rendering it
+// with sample data and is not activated automatically.
+func UploadReportTemplate(c *gin.Context) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" || utf8.RuneCountInString(name) > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template name is required (up to 200 characters)"})
+		return
+	}
+	body, ok := readTemplateUpload(c)
+	if !ok {
+		return
+	}
+	if _, err := parseReportTemplate(body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template: " + err.Error()})
+		return
+	}
+
+	now := database.Timestamp(time.Now())
+	res, err := database.DB.Exec("INSERT INTO report_templates (name, body, is_active, created_by, created_at, updated_at) VALUES (?, ?, FALSE, ?, ?, ?)",
+		name, body, c.GetUint("user_id"), now, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save template"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	c.JSON(http.StatusCreated, gin.H{"id": id, "name": name})
+}
+
+// PreviewUploadedReportTemplate renders an uploaded template with sample data
+// without storing it.
+func PreviewUploadedReportTemplate(c *gin.Context) {
+	body, ok := readTemplateUpload(c)
+	if !ok {
+		return
+	}
+	tmpl, err := template.New("report").Funcs(reportFuncs).Parse(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template: " + err.Error()})
+		return
+	}
+	writeReport(c, tmpl, sampleReportData())
+}
+
+// PreviewReportTemplate renders a stored template with sample data.
+func PreviewReportTemplate(c *gin.Context) {
+	t, ok := loadReportTemplate(c)
+	if !ok {
+		return
+	}
+	tmpl, err := template.New("report").Funcs(reportFuncs).Parse(t.Body)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid template: " + err.Error()})
+		return
+	}
+	writeReport(c, tmpl, sampleReportData())
+}
+
+// ActivateReportTemplate makes a template the one used for all reports.
+func ActivateReportTemplate(c *gin.Context) {
+	t, ok := loadReportTemplate(c)
+	if !ok {
+		return
+	}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate template"})
+		return
+	}
+	defer tx.Rollback()
+	now := database.Timestamp(time.Now())
+	if _, err := tx.Exec("UPDATE report_templates SET is_active = FALSE, updated_at = ? WHERE is_active AND id != ?", now, t.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate template"})
+		return
+	}
+	if _, err := tx.Exec("UPDATE report_templates SET is_active = TRUE, updated_at = ? WHERE id = ?", now, t.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate template"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate template"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Template activated"})
+}
+
+// DeactivateReportTemplates switches reports back to the built-in template.
+func DeactivateReportTemplates(c *gin.Context) {
+	if _, err := database.DB.Exec("UPDATE report_templates SET is_active = FALSE, updated_at = ? WHERE is_active", database.Timestamp(time.Now())); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate template"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Built-in template activated"})
+}
+
+// DeleteReportTemplate removes a template. Deleting the active one switches
+// reports back to the built-in template.
+func DeleteReportTemplate(c *gin.Context) {
+	res, err := database.DB.Exec("DELETE FROM report_templates WHERE id = ?", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+}
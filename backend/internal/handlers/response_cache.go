@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/redis"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Names of the cached responses; writes invalidate them by name.
+const (
+	cacheStandards = "standards"
+	cacheBranding  = "branding"
+)
+
+var responseCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "response_cache_lookups_total",
+	Help: "Lookups in the in-process response cache by cache name and result (hit, miss, not_modified).",
+}, []string{"cache", "result"})
+
+type cachedResponse struct {
+	body []byte
+	etag string
+}
+
+// responseCache keeps rendered JSON of rarely changing endpoints in memory.
+// Each cache has variants, e.g. one per user, since the same endpoint renders
+// differently for different callers.
+type responseCache struct {
+	mu          sync.Mutex
+	entries     map[string]map[string]cachedResponse
+	generations map[string]uint64
+
+	// With RESPONSE_CACHE_BACKEND=redis every cache also has a generation in
+	// Redis, bumped on invalidation, so that the writes served by one replica
+	// invalidate the caches of all of them.
+	shared            *redis.Client
+	sharedGenerations map[string]string // last seen in Redis
+}
+
+var responses = &responseCache{
+	entries:           map[string]map[string]cachedResponse{},
+	generations:       map[string]uint64{},
+	sharedGenerations: map[string]string{},
+}
+
+func sharedGenerationKey(name string) string {
+	return redisKeyPrefix + "responses:" + name
+}
+
+// syncShared drops the entries of name when another replica invalidated
+// them. It reports whether the cache may be used, which it may not while
+// Redis is unreachable.
+func (rc *responseCache) syncShared(name string) bool {
+	if rc.shared == nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	gen := "0"
+	value, err := rc.shared.Get(ctx, sharedGenerationKey(name))
+	switch {
+	case err == nil:
+		gen = string(value)
+	case !errors.Is(err, redis.ErrNil):
+		return false
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.sharedGenerations[name] != gen {
+		delete(rc.entries, name)
+		rc.generations[name]++
+		rc.sharedGenerations[name] = gen
+	}
+	return true
+}
+
+func (rc *responseCache) get(name, variant string) (cachedResponse, uint64, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	entry, ok := rc.entries[name][variant]
+	return entry, rc.generations[name], ok
+}
+
+// put stores a response rendered at generation gen. It is dropped when the
+// cache was invalidated in the meantime, as it may show the old data.
+func (rc *responseCache) put(name, variant string, gen uint64, entry cachedResponse) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.generations[name] != gen {
+		return
+	}
+	if rc.entries[name] == nil {
+		rc.entries[name] = map[string]cachedResponse{}
+	}
+	rc.entries[name][variant] = entry
+}
+
+// invalidateResponses drops all variants of the named caches. Call it after
+// every write to the data they are built from.
+func invalidateResponses(names ...string) {
+	responses.mu.Lock()
+	for _, name := range names {
+		delete(responses.entries, name)
+		responses.generations[name]++
+	}
+	responses.mu.Unlock()
+
+	if responses.shared == nil {
+		return
+	}
+	for _, name := range names {
+		if _, err := responses.shared.Do(context.Background(), "INCR", sharedGenerationKey(name)); err != nil {
+			fmt.Printf("ResponseCache: failed to invalidate %s on other replicas: %v\n", name, err)
+		}
+	}
+}
+
+// serveCached answers with the cached response of name/variant, rendering it
+// with load on a miss. The response carries an ETag, and a request whose
+// If-None-Match names it gets 304 Not Modified without a body.
+// Errors from load are answered with 500 and the error text.
+func serveCached(c *gin.Context, name, variant string, load func() (interface{}, error)) {
+	usable := responses.syncShared(name)
+	entry, gen, ok := responses.get(name, variant)
+	ok = ok && usable
+	if !ok {
+		value, err := load()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		body, err := json.Marshal(value)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+			return
+		}
+		sum := sha256.Sum256(body)
+		entry = cachedResponse{body: body, etag: `"` + hex.EncodeToString(sum[:16]) + `"`}
+		if usable {
+			responses.put(name, variant, gen, entry)
+		}
+	}
+
+	// Responses depend on the caller, so shared caches must not keep them,
+	// and browsers revalidate on every use.
+	c.Header("ETag", entry.etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), entry.etag) {
+		responseCacheLookups.WithLabelValues(name, "not_modified").Inc()
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if ok {
+		responseCacheLookups.WithLabelValues(name, "hit").Inc()
+	} else {
+		responseCacheLookups.WithLabelValues(name, "miss").Inc()
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", entry.body)
+}
+
+// etagMatches implements the weak comparison of If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type rolloverRequest struct {
+	FromTermID    uint64              `json:"from_term_id" binding:"required"`
+	AssignmentIDs []uint              `json:"assignment_ids"` // empty: all of the term
+	GroupMap      map[uint]uint       `json:"group_map"`      // old group id -> new group id
+	Deadlines     map[uint]time.Time  `json:"deadlines"`      // assignment id -> new deadline
+	OpensAt       map[uint]*time.Time `json:"opens_at"`       // assignment id -> new opening, null for none
+	CopyStandards bool                `json:"copy_standards"`
+}
+
+// RolloverResult reports what a rollover copied.
+type RolloverResult struct {
+	Created   []models.Assignment `json:"created"`
+	Skipped   []uint              `json:"skipped"`   // already copied into the term
+	Standards map[uint]uint       `json:"standards"` // old standard id -> copy
+}
+
+// RolloverTerm copies assignments of an earlier term into the term of the
+// :id parameter, so the same work need not be set up again each semester.
+// Dates move by the distance between the starts of the two terms unless
+// new ones are given, groups are remapped by group_map and, with
+// copy_standards, each standard used gets a copy of its own for the new
+// term. An assignment whose title and group are already in the term is
+// skipped, so a rollover can be repeated after adding a few more.
+func RolloverTerm(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	var target models.AcademicTerm
+	if err == nil {
+		target, err = loadTerm(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Academic term not found"})
+		return
+	}
+	if target.Archived {
+		c.JSON(http.StatusConflict, gin.H{"error": errTermArchived.Error()})
+		return
+	}
+	var req rolloverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	source, err := loadTerm(req.FromTermID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Source academic term not found"})
+		return
+	}
+	if source.ID == target.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from_term_id must differ from the target term"})
+		return
+	}
+	for _, groupID := range req.GroupMap {
+		var exists int
+		if err := database.DB.QueryRow("SELECT COUNT(*) FROM student_groups WHERE id = ?", groupID).Scan(&exists); err != nil || exists == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Group %d not found", groupID)})
+			return
+		}
+	}
+
+	rows, err := database.DB.Query("SELECT "+assignmentColumns+" FROM assignments WHERE term_id = ? ORDER BY deadline, id", source.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignments"})
+		return
+	}
+	wanted := map[uint]bool{}
+	for _, aid := range req.AssignmentIDs {
+		wanted[aid] = true
+	}
+	var assignments []models.Assignment
+	for rows.Next() {
+		a, err := scanAssignment(rows)
+		if err != nil {
+			continue
+		}
+		if len(wanted) == 0 || wanted[a.ID] {
+			assignments = append(assignments, a)
+			delete(wanted, a.ID)
+		}
+	}
+	rows.Close()
+	for aid := range wanted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Assignment %d is not in the source term", aid)})
+		return
+	}
+
+	shift := target.StartsAt.Sub(source.StartsAt)
+	for i := range assignments {
+		a := &assignments[i]
+		oldID := a.ID
+		if g, ok := req.GroupMap[a.GroupID]; ok {
+			a.GroupID = g
+		}
+		if deadline, ok := req.Deadlines[oldID]; ok {
+			a.Deadline = deadline
+		} else {
+			a.Deadline = a.Deadline.Add(shift)
+		}
+		if opensAt, ok := req.OpensAt[oldID]; ok {
+			a.OpensAt = opensAt
+		} else if a.OpensAt != nil {
+			moved := a.OpensAt.Add(shift)
+			a.OpensAt = &moved
+		}
+		if a.OpensAt != nil && !a.OpensAt.Before(a.Deadline) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Assignment %d: opens_at must be before deadline", oldID)})
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy assignments"})
+		return
+	}
+	defer tx.Rollback()
+
+	result := RolloverResult{Created: []models.Assignment{}, Skipped: []uint{}, Standards: map[uint]uint{}}
+	now := database.Timestamp(time.Now())
+	var createdIDs []int64
+	for _, a := range assignments {
+		var exists int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM assignments WHERE term_id = ? AND group_id = ? AND title = ?", target.ID, a.GroupID, a.Title).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy assignments"})
+			return
+		}
+		if exists > 0 {
+			result.Skipped = append(result.Skipped, a.ID)
+			continue
+		}
+
+		standardID := a.StandardID
+		if req.CopyStandards {
+			copied, ok := result.Standards[a.StandardID]
+			if !ok {
+				copied, err = copyStandard(tx, a.StandardID, target.Name, now)
+				if err != nil {
+					fmt.Printf("RolloverTerm: copying standard %d: %v\n", a.StandardID, err)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy standards"})
+					return
+				}
+				result.Standards[a.StandardID] = copied
+			}
+			standardID = copied
+		}
+
+		var opensAt interface{}
+		if a.OpensAt != nil {
+			opensAt = database.Timestamp(*a.OpensAt)
+		}
+		res, err := tx.Exec(`INSERT INTO assignments (title, standard_id, group_id, created_by, opens_at, deadline, late_policy, grace_hours, penalty_note, term_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			a.Title, standardID, a.GroupID, a.CreatedBy, opensAt, database.Timestamp(a.Deadline),
+			a.LatePolicy, a.GraceHours, a.PenaltyNote, target.ID, now)
+		if err != nil {
+			fmt.Printf("RolloverTerm: copying assignment %d: %v\n", a.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy assignments"})
+			return
+		}
+		newID, _ := res.LastInsertId()
+		createdIDs = append(createdIDs, newID)
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy assignments"})
+		return
+	}
+	if len(result.Standards) > 0 {
+		invalidateResponses(cacheStandards)
+	}
+
+	for _, newID := range createdIDs {
+		if a, err := loadAssignment(uint64(newID)); err == nil {
+			result.Created = append(result.Created, a)
+		}
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// copyStandard copies a standard for a new term, named after the term and
+// kept by the author of the original.
+func copyStandard(tx *sql.Tx, id uint, termName, now string) (uint, error) {
+	var name string
+	if err := tx.QueryRow("SELECT name FROM formatting_standards WHERE id = ?", id).Scan(&name); err != nil {
+		return 0, err
+	}
+	name = strings.TrimSpace(name) + " (" + termName + ")"
+	res, err := tx.Exec(`INSERT INTO formatting_standards (name, description, created_by, document_type, is_public, modules_json, created_at, updated_at)
+		SELECT ?, description, created_by, document_type, is_public, modules_json, ?, ? FROM formatting_standards WHERE id = ?`,
+		name, now, now, id)
+	if err != nil {
+		return 0, err
+	}
+	newID, err := res.LastInsertId()
+	return uint(newID), err
+}
@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/settings"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rosterMaxBytes bounds a roster file or SCIM page.
+const rosterMaxBytes = 32 << 20
+
+// rosterSCIMPageSize is the page size requested from a SCIM endpoint.
+const rosterSCIMPageSize = 200
+
+// rosterEntry is one person of the registrar's roster.
+type rosterEntry struct {
+	ExternalID string
+	Email      string
+	FullName   string
+	Role       string // student, teacher or empty when the roster has no roles
+	Group      string // group name of a student; empty keeps none
+	Active     bool
+}
+
+// RosterReport summarises one roster sync.
+type RosterReport struct {
+	Source        string   `json:"source"`
+	StartedAt     string   `json:"started_at"`
+	DryRun        bool     `json:"dry_run"`
+	Entries       int      `json:"entries"`
+	Created       int      `json:"created"`
+	Updated       int      `json:"updated"`
+	Unchanged     int      `json:"unchanged"`
+	Deactivated   int      `json:"deactivated"`
+	Reactivated   int      `json:"reactivated"`
+	GroupsCreated int      `json:"groups_created"`
+	Skipped       []string `json:"skipped,omitempty"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+var (
+	rosterMu         sync.Mutex // one sync at a time
+	rosterLastMu     sync.Mutex
+	rosterLastReport *RosterReport
+	rosterClient     = &http.Client{Timeout: 60 * time.Second}
+)
+
+// rosterSource names the configured roster: ROSTER_SCIM_URL, or else
+// ROSTER_CSV (a file path or an http(s) URL); empty when roster sync is off.
+func rosterSource() string {
+	if u := strings.TrimSpace(os.Getenv("ROSTER_SCIM_URL")); u != "" {
+		return "scim"
+	}
+	if strings.TrimSpace(os.Getenv("ROSTER_CSV")) != "" {
+		return "csv"
+	}
+	return ""
+}
+
+// StartRosterSync periodically brings users and groups in line with the
+// registrar's roster. The interval is read from ROSTER_INTERVAL_MINUTES
+// (default 360, 0 disables the schedule; POST /api/admin/roster/sync still
+// works). Nothing runs without a configured roster.
+func StartRosterSync() {
+	if rosterSource() == "" {
+		return
+	}
+	interval := 6 * time.Hour
+	if n, err := strconv.Atoi(os.Getenv("ROSTER_INTERVAL_MINUTES")); err == nil && n >= 0 {
+		interval = time.Duration(n) * time.Minute
+	}
+	if interval == 0 {
+		fmt.Println("Roster sync: schedule disabled")
+		return
+	}
+
+	go func() {
+		for {
+			report, err := runRosterSync(false)
+			if err != nil {
+				fmt.Printf("Roster sync: skipped: %v\n", err)
+			} else {
+				fmt.Printf("Roster sync: %d entries, %d created, %d updated, %d deactivated, %d reactivated, %d errors\n",
+					report.Entries, report.Created, report.Updated, report.Deactivated, report.Reactivated, len(report.Errors))
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// RunRosterSync syncs the roster immediately. With ?dry_run=true the changes
+// are computed and reported but not saved.
+func RunRosterSync(c *gin.Context) {
+	if rosterSource() == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Roster sync is not configured (set ROSTER_SCIM_URL or ROSTER_CSV)"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	report, err := runRosterSync(dryRun)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Roster sync failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetRosterStatus returns the configured source and the report of the last
+// sync that was saved.
+func GetRosterStatus(c *gin.Context) {
+	rosterLastMu.Lock()
+	last := rosterLastReport
+	rosterLastMu.Unlock()
+	c.JSON(http.StatusOK, gin.H{"source": rosterSource(), "last": last})
+}
+
+// runRosterSync loads the roster and applies it in one transaction.
+func runRosterSync(dryRun bool) (RosterReport, error) {
+	rosterMu.Lock()
+	defer rosterMu.Unlock()
+
+	report := RosterReport{Source: rosterSource(), StartedAt: database.FormatTimestamp(time.Now()), DryRun: dryRun}
+	var entries []rosterEntry
+	var err error
+	switch report.Source {
+	case "scim":
+		entries, err = loadSCIMRoster(strings.TrimRight(strings.TrimSpace(os.Getenv("ROSTER_SCIM_URL")), "/"), strings.TrimSpace(os.Getenv("ROSTER_SCIM_TOKEN")))
+	case "csv":
+		entries, err = loadCSVRoster(strings.TrimSpace(os.Getenv("ROSTER_CSV")))
+	default:
+		err = errors.New("no roster configured")
+	}
+	if err != nil {
+		return report, err
+	}
+	// An empty roster is far more likely a broken export than a registrar
+	// without anyone, and would deactivate every account.
+	if len(entries) == 0 {
+		return report, errors.New("the roster is empty")
+	}
+	report.Entries = len(entries)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	if err := applyRoster(tx, entries, &report); err != nil {
+		return report, err
+	}
+	if dryRun {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+	if report.Created+report.Updated+report.Deactivated+report.Reactivated > 0 {
+		invalidateResponses(cacheStandards)
+	}
+	rosterLastMu.Lock()
+	rosterLastReport = &report
+	rosterLastMu.Unlock()
+	return report, nil
+}
+
+// applyRoster creates and updates the accounts of entries and deactivates the
+// roster accounts that are no longer listed. Accounts are matched by the
+// registrar's id, then by email. Only accounts the roster created or adopted
+// are ever deactivated, and admins are never changed.
+func applyRoster(tx *sql.Tx, entries []rosterEntry, report *RosterReport) error {
+	groups := map[string]int64{}
+	groupID := func(name string) (sql.NullInt64, error) {
+		if name == "" {
+			return sql.NullInt64{}, nil
+		}
+		if id, ok := groups[name]; ok {
+			return sql.NullInt64{Int64: id, Valid: true}, nil
+		}
+		var id int64
+		err := tx.QueryRow("SELECT id FROM student_groups WHERE group_name = ?", name).Scan(&id)
+		if err == sql.ErrNoRows {
+			res, err := tx.Exec("INSERT INTO student_groups (group_name) VALUES (?)", name)
+			if err != nil {
+				return sql.NullInt64{}, err
+			}
+			id, _ = res.LastInsertId()
+			report.GroupsCreated++
+		} else if err != nil {
+			return sql.NullInt64{}, err
+		}
+		groups[name] = id
+		return sql.NullInt64{Int64: id, Valid: true}, nil
+	}
+
+	seen := map[int64]bool{}
+	for _, e := range entries {
+		key := e.Email
+		if e.ExternalID != "" {
+			key = e.ExternalID + " (" + e.Email + ")"
+		}
+
+		var id int64
+		var email, role, fullName string
+		var group sql.NullInt64
+		var active bool
+		err := sql.ErrNoRows
+		if e.ExternalID != "" {
+			err = tx.QueryRow("SELECT id, email, role, COALESCE(full_name, ''), group_id, is_active FROM users WHERE roster_external_id = ?", e.ExternalID).
+				Scan(&id, &email, &role, &fullName, &group, &active)
+		}
+		if err == sql.ErrNoRows {
+			err = tx.QueryRow("SELECT id, email, role, COALESCE(full_name, ''), group_id, is_active FROM users WHERE lower(email) = lower(?)", e.Email).
+				Scan(&id, &email, &role, &fullName, &group, &active)
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if err == nil {
+			seen[id] = true
+			if role == "admin" {
+				report.Skipped = append(report.Skipped, key+": admin account")
+				continue
+			}
+		}
+		// A roster without roles keeps the local role.
+		newRole := e.Role
+		if newRole == "" {
+			newRole = role
+		}
+		if newRole == "" {
+			newRole = "student"
+		}
+		gid, gerr := groupID(e.Group)
+		if gerr != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: group %q: %v", key, e.Group, gerr))
+			continue
+		}
+		switch {
+		case newRole != "student":
+			gid = sql.NullInt64{}
+		case e.Group == "":
+			gid = group // a roster without groups keeps the local one
+		}
+
+		if err == sql.ErrNoRows {
+			if !e.Active {
+				continue
+			}
+			// "!" is never a valid bcrypt hash, so the account cannot log in
+			// until a password is set.
+			res, err := tx.Exec(`INSERT INTO users (email, password_hash, role, full_name, group_id, is_active, roster_managed, roster_external_id)
+				VALUES (?, '!', ?, ?, ?, TRUE, TRUE, ?)`, e.Email, newRole, e.FullName, gid, nullIfEmpty(e.ExternalID))
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", key, err))
+				continue
+			}
+			id, _ = res.LastInsertId()
+			seen[id] = true
+			report.Created++
+			continue
+		}
+
+		changed := email != e.Email || role != newRole || fullName != e.FullName || group != gid
+		if _, err := tx.Exec(`UPDATE users SET email = ?, role = ?, full_name = ?, group_id = ?, is_active = ?, roster_managed = TRUE, roster_external_id = COALESCE(?, roster_external_id)
+			WHERE id = ?`, e.Email, newRole, e.FullName, gid, e.Active, nullIfEmpty(e.ExternalID), id); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		switch {
+		case active && !e.Active:
+			report.Deactivated++
+		case !active && e.Active:
+			report.Reactivated++
+		case changed:
+			report.Updated++
+		default:
+			report.Unchanged++
+		}
+	}
+
+	// Roster accounts that disappeared from the roster.
+	rows, err := tx.Query("SELECT id, email FROM users WHERE roster_managed AND is_active AND role != 'admin'")
+	if err != nil {
+		return err
+	}
+	var missing []int64
+	total := 0
+	for rows.Next() {
+		var id int64
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			rows.Close()
+			return err
+		}
+		total++
+		if !seen[id] {
+			missing = append(missing, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// A run may deactivate only this share of roster accounts, guarding
+	// against a truncated export.
+	limit := settings.Int("roster.max_deactivate_percent")
+	if len(missing) > 0 && len(missing)*100 > total*limit {
+		report.Errors = append(report.Errors, fmt.Sprintf("%d of %d roster accounts are missing from the roster, more than roster.max_deactivate_percent=%d%%; none were deactivated", len(missing), total, limit))
+		return nil
+	}
+	for _, id := range missing {
+		if _, err := tx.Exec("UPDATE users SET is_active = FALSE WHERE id = ?", id); err != nil {
+			return err
+		}
+		report.Deactivated++
+	}
+	return nil
+}
+
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// normalizeRosterEntry validates e and fills its defaults; it returns false
+// for entries without a usable email.
+func normalizeRosterEntry(e *rosterEntry) bool {
+	e.Email = strings.ToLower(strings.TrimSpace(e.Email))
+	e.FullName = strings.Join(strings.Fields(e.FullName), " ")
+	e.Group = strings.TrimSpace(e.Group)
+	e.ExternalID = strings.TrimSpace(e.ExternalID)
+	if strings.TrimSpace(e.Role) != "" {
+		e.Role = rosterRole(e.Role)
+	}
+	return strings.Contains(e.Email, "@")
+}
+
+// rosterRole maps the registrar's role names to student or teacher. Admins
+// are only ever appointed locally.
+func rosterRole(role string) string {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case "teacher", "faculty", "instructor", "staff", "преподаватель":
+		return "teacher"
+	}
+	return "student"
+}
+
+// readRosterSource reads a roster file from disk or over http(s).
+func readRosterSource(location, token string) ([]byte, error) {
+	var r io.Reader
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		req, err := http.NewRequest(http.MethodGet, location, nil)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := rosterClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s answered %s", location, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(io.LimitReader(r, rosterMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > rosterMaxBytes {
+		return nil, errors.New("roster is too large")
+	}
+	return data, nil
+}
+
+// loadCSVRoster reads a CSV roster with a header row. The columns email and
+// full_name are required; role, group, external_id and active are optional.
+// Russian headers (почта, фио, роль, группа) and the ";" separator of
+// spreadsheets saved in Russian locales are accepted.
+func loadCSVRoster(location string) ([]rosterEntry, error) {
+	data, err := readRosterSource(location, strings.TrimSpace(os.Getenv("ROSTER_CSV_TOKEN")))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	header := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		header = data[:i]
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	aliases := map[string]string{
+		"email": "email", "e-mail": "email", "почта": "email",
+		"full_name": "full_name", "name": "full_name", "фио": "full_name",
+		"role": "role", "роль": "role",
+		"group": "group", "группа": "group",
+		"external_id": "external_id", "id": "external_id",
+		"active": "active", "активен": "active",
+	}
+	col := map[string]int{}
+	for i, h := range records[0] {
+		if name, ok := aliases[strings.ToLower(strings.TrimSpace(h))]; ok {
+			col[name] = i
+		}
+	}
+	if _, ok := col["email"]; !ok {
+		return nil, errors.New("the CSV roster has no email column")
+	}
+	if _, ok := col["full_name"]; !ok {
+		return nil, errors.New("the CSV roster has no full_name column")
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := col[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	var entries []rosterEntry
+	for _, rec := range records[1:] {
+		e := rosterEntry{
+			ExternalID: field(rec, "external_id"),
+			Email:      field(rec, "email"),
+			FullName:   field(rec, "full_name"),
+			Role:       field(rec, "role"),
+			Group:      field(rec, "group"),
+			Active:     true,
+		}
+		if v := strings.ToLower(field(rec, "active")); v != "" {
+			e.Active = v == "1" || v == "true" || v == "yes" || v == "да"
+		}
+		if normalizeRosterEntry(&e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// scimUser holds the attributes of a SCIM 2.0 User resource used here.
+type scimUser struct {
+	ID       string `json:"id"`
+	UserName string `json:"userName"`
+	Name     struct {
+		Formatted  string `json:"formatted"`
+		FamilyName string `json:"familyName"`
+		GivenName  string `json:"givenName"`
+		MiddleName string `json:"middleName"`
+	} `json:"name"`
+	DisplayName string `json:"displayName"`
+	UserType    string `json:"userType"`
+	Active      *bool  `json:"active"`
+	Emails      []struct {
+		Value   string `json:"value"`
+		Primary bool   `json:"primary"`
+	} `json:"emails"`
+	Roles []struct {
+		Value string `json:"value"`
+	} `json:"roles"`
+	Groups []struct {
+		Display string `json:"display"`
+	} `json:"groups"`
+}
+
+// loadSCIMRoster pages through {base}/Users of a SCIM 2.0 service. The role
+// is taken from roles or userType, the group of a student from the first
+// group the service lists for the user.
+func loadSCIMRoster(base, token string) ([]rosterEntry, error) {
+	var entries []rosterEntry
+	for start := 1; ; {
+		data, err := readRosterSource(fmt.Sprintf("%s/Users?startIndex=%d&count=%d", base, start, rosterSCIMPageSize), token)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			TotalResults int        `json:"totalResults"`
+			ItemsPerPage int        `json:"itemsPerPage"`
+			Resources    []scimUser `json:"Resources"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("invalid SCIM response: %v", err)
+		}
+		for _, u := range page.Resources {
+			e := rosterEntry{ExternalID: u.ID, Role: u.UserType, Active: u.Active == nil || *u.Active}
+			for _, m := range u.Emails {
+				if e.Email == "" || m.Primary {
+					e.Email = m.Value
+				}
+			}
+			if e.Email == "" {
+				e.Email = u.UserName
+			}
+			e.FullName = u.Name.Formatted
+			if e.FullName == "" {
+				e.FullName = strings.Join([]string{u.Name.FamilyName, u.Name.GivenName, u.Name.MiddleName}, " ")
+			}
+			if strings.TrimSpace(e.FullName) == "" {
+				e.FullName = u.DisplayName
+			}
+			for _, r := range u.Roles {
+				if rosterRole(r.Value) == "teacher" {
+					e.Role = "teacher"
+				}
+			}
+			if len(u.Groups) > 0 {
+				e.Group = u.Groups[0].Display
+			}
+			if normalizeRosterEntry(&e) {
+				entries = append(entries, e)
+			}
+		}
+		if len(page.Resources) == 0 || start+len(page.Resources) > page.TotalResults {
+			break
+		}
+		start += len(page.Resources)
+	}
+	return entries, nil
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func writeRoster(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "roster.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCSVRoster(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    []rosterEntry
+		err     string
+	}{
+		{
+			name:    "comma separated",
+			content: "email,full_name,id,extra\na@uni.ru,\"Сидоров, Семён\",42,x\n",
+			want:    []rosterEntry{{ExternalID: "42", Email: "a@uni.ru", FullName: "Сидоров, Семён", Active: true}},
+		},
+		{
+			name: "russian spreadsheet",
+			content: "\xef\xbb\xbfФИО;Почта;Роль;Группа;Активен\r\n" +
+				"Иванов  Иван ;IVANOV@uni.ru ;Преподаватель;ИВТ-21;да\r\n" +
+				" Петров П.;petrov@uni.ru;студент; ПИ-22 ;нет\r\n",
+			want: []rosterEntry{
+				{Email: "ivanov@uni.ru", FullName: "Иванов Иван", Role: "teacher", Group: "ИВТ-21", Active: true},
+				{Email: "petrov@uni.ru", FullName: "Петров П.", Role: "student", Group: "ПИ-22", Active: false},
+			},
+		},
+		{
+			name:    "quoted line break in a name",
+			content: "email,name,active\na@uni.ru,\"Иванов\nИван\",TRUE\nb@uni.ru,Петров,0\n",
+			want: []rosterEntry{
+				{Email: "a@uni.ru", FullName: "Иванов Иван", Active: true},
+				{Email: "b@uni.ru", FullName: "Петров", Active: false},
+			},
+		},
+		{
+			name:    "short and blank rows",
+			content: "email,full_name,group\nb@uni.ru\n\n,Без почты\nnot-an-email,Кто-то,ИВТ-21\n",
+			want:    []rosterEntry{{Email: "b@uni.ru", Active: true}},
+		},
+		{name: "header only", content: "email;full_name\n"},
+		{name: "empty", content: ""},
+		{name: "only a byte order mark", content: "\xef\xbb\xbf"},
+		{name: "no email column", content: "full_name,group\nИванов,ИВТ-21\n", err: "no email column"},
+		{name: "no name column", content: "e-mail\na@uni.ru\n", err: "no full_name column"},
+		{name: "header not in the first row", content: "Список группы\nemail,full_name\n", err: "no email column"},
+		{name: "bare quote", content: "email,full_name\na@uni.ru,Иванов \"Ваня\"\n", err: "invalid CSV"},
+		{name: "unterminated quote", content: "email,full_name\n\"a@uni.ru,Иванов\n", err: "invalid CSV"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := loadCSVRoster(writeRoster(t, tc.content))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("loadCSVRoster error = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("loadCSVRoster = %+v, %v; want %+v", got, err, tc.want)
+			}
+		})
+	}
+
+	if _, err := loadCSVRoster(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Fatal("loadCSVRoster of a missing file succeeded")
+	}
+}
+
+func TestLoadSCIMRoster(t *testing.T) {
+	users := []string{
+		`{"id": "u1", "userName": "ivanov", "userType": "Student",
+			"emails": [{"value": "a@uni.ru"}, {"value": "B@uni.ru", "primary": true}],
+			"name": {"formatted": "Иванов Иван Иванович", "familyName": "Иванов"},
+			"groups": [{"display": "ИВТ-21"}, {"display": "Староста"}]}`,
+		`{"id": "u2", "userName": "t@uni.ru", "active": false,
+			"name": {"familyName": "Петров", "givenName": "Пётр"},
+			"roles": [{"value": "Student"}, {"value": "Faculty"}]}`,
+		`{"id": "u3", "userName": "nobody", "name": {"formatted": "Без почты"}}`,
+		`{"id": "u4", "emails": [{"value": "d@uni.ru"}], "displayName": "Д. Смирнов", "userType": "Преподаватель", "unknown": [1, 2]}`,
+	}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// The service pages by two, whatever count asks for.
+		start, _ := strconv.Atoi(r.URL.Query().Get("startIndex"))
+		end := start + 1
+		if end > len(users) {
+			end = len(users)
+		}
+		fmt.Fprintf(w, `{"totalResults": %d, "itemsPerPage": 2, "Resources": [%s]}`, len(users), strings.Join(users[start-1:end], ","))
+	}))
+	defer server.Close()
+
+	got, err := loadSCIMRoster(server.URL, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []rosterEntry{
+		{ExternalID: "u1", Email: "b@uni.ru", FullName: "Иванов Иван Иванович", Role: "student", Group: "ИВТ-21", Active: true},
+		{ExternalID: "u2", Email: "t@uni.ru", FullName: "Петров Пётр", Role: "teacher", Active: false},
+		{ExternalID: "u4", Email: "d@uni.ru", FullName: "Д. Смирнов", Role: "teacher", Active: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("loadSCIMRoster = %+v, want %+v", got, want)
+	}
+	wantRequests := []string{"/Users?startIndex=1&count=200", "/Users?startIndex=3&count=200"}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Fatalf("requests = %q, want %q", requests, wantRequests)
+	}
+
+	if _, err := loadSCIMRoster(server.URL, ""); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("loadSCIMRoster without a token = %v, want the status", err)
+	}
+	for _, body := range []string{"<html>Вход</html>", `{"Resources": {"id": "u1"}}`, `{"totalResults": "many"}`, `{"Resources": [{"emails": "a@uni.ru"}]}`} {
+		malformed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, body)
+		}))
+		if _, err := loadSCIMRoster(malformed.URL, ""); err == nil || !strings.Contains(err.Error(), "invalid SCIM response") {
+			t.Errorf("loadSCIMRoster of %s = %v, want invalid SCIM response", body, err)
+		}
+		malformed.Close()
+	}
+}
+
+func TestRosterRole(t *testing.T) {
+	for role, want := range map[string]string{
+		"teacher": "teacher", " Faculty ": "teacher", "INSTRUCTOR": "teacher", "staff": "teacher", "Преподаватель": "teacher",
+		"student": "student", "admin": "student", "": "student", "aspirant": "student",
+	} {
+		if got := rosterRole(role); got != want {
+			t.Errorf("rosterRole(%q) = %q, want %q", role, got, want)
+		}
+	}
+}
+
+func TestRunRosterSyncRejectsBrokenRosters(t *testing.T) {
+	useTestDB(t)
+	t.Setenv("ROSTER_SCIM_URL", "")
+	for _, content := range []string{"email,full_name\n", "email,full_name\nnot-an-email,Иванов\n", "фио,группа\nИванов,ИВТ-21\n", "email,full_name\n\"a@uni.ru\n"} {
+		t.Setenv("ROSTER_CSV", writeRoster(t, content))
+		w := serve(t, RunRosterSync, http.MethodPost, "/api/admin/roster/sync", nil, "admin", 1)
+		if w.Code != http.StatusBadGateway {
+			t.Errorf("sync of %q = %d %s, want %d", content, w.Code, w.Body.String(), http.StatusBadGateway)
+		}
+	}
+	var users int
+	database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE is_active").Scan(&users)
+	if users != 1 {
+		t.Errorf("%d active users after the failed syncs, want only the admin", users)
+	}
+	t.Setenv("ROSTER_CSV", "")
+	if w := serve(t, RunRosterSync, http.MethodPost, "/api/admin/roster/sync", nil, "admin", 1); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("sync without a roster = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TraceTeacherCheck re-runs a stored check as a dry run: the document is
+// checked again against the config of its original request and nothing is
+// saved. The response carries the trace of every rule group — what the rules
+// read from the document, the settings they applied and why they passed,
+// failed or were skipped — so teachers can understand a surprising result and
+// report parser bugs with evidence.
+func TraceTeacherCheck(c *gin.Context) {
+	id, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found or access denied"})
+		return
+	}
+	teacherID := c.GetUint("user_id")
+
+	var docID int64
+	var storedScore float64
+	err = database.DB.QueryRow(`
+		SELECT cr.document_id, cr.overall_score
+		FROM check_results cr
+		JOIN formatting_standards s ON cr.standard_id = s.id
+		WHERE cr.id = ? AND s.created_by = ?
+	`, id, teacherID).Scan(&docID, &storedScore)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Record not found or access denied"})
+		return
+	}
+
+	p, doc, err := loadDocumentPipeline(docID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+	if _, err := os.Stat(doc.FilePath); err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Stored file is no longer available"})
+		return
+	}
+
+	svc := checker.NewCheckService()
+	svc.StudentName = documentOwnerName(docID)
+	svc.Dictionaries = loadDictionaries
+	svc.Defaults = checkDefaults()
+	result, violations, err := svc.RunCheckCached(c.Request.Context(), p.FilePath, p.ContentHash, p.ConfigJSON)
+	if err != nil {
+		var tooComplex *checker.ComplexityError
+		if errors.As(err, &tooComplex) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": tooComplex.Error()})
+			return
+		}
+		fmt.Printf("TraceTeacherCheck: dry run of result %d failed: %v\n", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Dry run failed: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":           id,
+		"document_id":  docID,
+		"dry_run":      true,
+		"stored_score": storedScore,
+		"score":        result.OverallScore,
+		"summary":      result.Summary,
+		"violations":   localizeViolations(c, violations),
+		"stats": gin.H{
+			"total":  result.TotalRules,
+			"failed": result.FailedRules,
+		},
+		"rule_trace":   result.RuleTrace,
+		"rule_timings": result.RuleTimings,
+	})
+}
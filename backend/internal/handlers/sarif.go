@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Check results are also served as SARIF 2.1.0 (Static Analysis Results
+// Interchange Format), which code review tools and CI pipelines read: every
+// violation is a result with the rule type as its rule id.
+const (
+	sarifMediaType = "application/sarif+json"
+	sarifSchema    = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion   = "2.1.0"
+	sarifToolName  = "NormoControl"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool              sarifTool              `json:"tool"`
+	AutomationDetails *sarifRunAutomation    `json:"automationDetails,omitempty"`
+	Artifacts         []sarifArtifact        `json:"artifacts"`
+	Results           []sarifResult          `json:"results"`
+	Properties        map[string]interface{} `json:"properties"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID      string `json:"id"`
+	HelpURI string `json:"helpUri,omitempty"`
+}
+
+type sarifRunAutomation struct {
+	ID string `json:"id"`
+}
+
+type sarifArtifact struct {
+	Location sarifArtifactLocation `json:"location"`
+}
+
+type sarifArtifactLocation struct {
+	URI   string `json:"uri"`
+	Index *int   `json:"index,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	RuleIndex  int                    `json:"ruleIndex"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// wantsSARIF reports whether the check result is requested as SARIF:
+// ?format=sarif, or an Accept header naming application/sarif+json when there
+// is no ?format=. An unknown format is answered with 400.
+func wantsSARIF(c *gin.Context) (sarif, ok bool) {
+	switch c.Query("format") {
+	case "":
+		return strings.Contains(c.GetHeader("Accept"), sarifMediaType), true
+	case "json":
+		return false, true
+	case "sarif":
+		return true, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or sarif"})
+	return false, false
+}
+
+// sarifLevel maps a violation severity to a SARIF result level.
+func sarifLevel(severity string) string {
+	switch severity {
+	case models.SeverityCritical, models.SeverityError:
+		return "error"
+	case models.SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
+
+// respondSARIF writes a check result as a SARIF log with one run. The page,
+// paragraph, table and character offsets of a violation are in the location
+// property of its result, since a document has no lines to point at;
+// properties of the run carry the score and the metadata of the work.
+func respondSARIF(c *gin.Context, resultUUID, docName string, properties map[string]interface{}, violations []models.Violation) {
+	artifactIndex := 0
+	uri := url.PathEscape(docName)
+	run := sarifRun{
+		Tool:       sarifTool{Driver: sarifDriver{Name: sarifToolName, Rules: []sarifRule{}}},
+		Artifacts:  []sarifArtifact{{Location: sarifArtifactLocation{URI: uri}}},
+		Results:    make([]sarifResult, 0, len(violations)),
+		Properties: properties,
+	}
+	if resultUUID != "" {
+		run.AutomationDetails = &sarifRunAutomation{ID: "normocontrol/" + resultUUID}
+	}
+
+	ruleIndex := map[string]int{}
+	for _, v := range violations {
+		index, ok := ruleIndex[v.RuleType]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndex[v.RuleType] = index
+			rule := sarifRule{ID: v.RuleType}
+			for _, r := range v.Resources {
+				if r.URL != "" {
+					rule.HelpURI = r.URL
+					break
+				}
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: uri, Index: &artifactIndex},
+		}}
+		if v.PositionInDoc != "" {
+			location.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: v.PositionInDoc, Kind: "element"}}
+		}
+		properties := map[string]interface{}{"severity": v.Severity}
+		for key, value := range map[string]string{
+			"expected_value": v.ExpectedValue,
+			"actual_value":   v.ActualValue,
+			"suggestion":     v.Suggestion,
+		} {
+			if value != "" {
+				properties[key] = value
+			}
+		}
+		if v.Location != nil {
+			properties["location"] = v.Location
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:     v.RuleType,
+			RuleIndex:  index,
+			Level:      sarifLevel(v.Severity),
+			Message:    sarifMessage{Text: v.Description},
+			Locations:  []sarifLocation{location},
+			Properties: properties,
+		})
+	}
+
+	c.Header("Content-Type", sarifMediaType)
+	c.JSON(http.StatusOK, sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
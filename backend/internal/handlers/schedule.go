@@ -0,0 +1,531 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/archive"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"github.com/Ullyminat/NormoControl/backend/internal/settings"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxScheduledDocuments bounds one batch, e.g. the works of a group.
+	maxScheduledDocuments    = 200
+	defaultSchedulerInterval = time.Minute
+)
+
+var scheduleTimeRe = regexp.MustCompile(`^([01][0-9]|2[0-3]):([0-5][0-9])$`)
+
+// scheduleMinute returns the minute of the day of an "HH:MM" time.
+func scheduleMinute(s string) (int, bool) {
+	m := scheduleTimeRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	h, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	return h*60 + minute, true
+}
+
+// inScheduleWindow reports whether t falls into the daily window from start
+// to end; a window whose end is before its start spans midnight.
+func inScheduleWindow(t time.Time, start, end string) bool {
+	from, okFrom := scheduleMinute(start)
+	to, okTo := scheduleMinute(end)
+	if !okFrom || !okTo {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if from <= to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to
+}
+
+const checkScheduleColumns = `id, user_id, standard_id, name, window_start, window_end, status, created_at, started_at, finished_at,
+	(SELECT COUNT(*) FROM documents WHERE schedule_id = check_schedules.id),
+	(SELECT COUNT(*) FROM documents WHERE schedule_id = check_schedules.id AND status = 'done'),
+	(SELECT COUNT(*) FROM documents WHERE schedule_id = check_schedules.id AND status LIKE 'failed_%')`
+
+func scanCheckSchedule(row interface{ Scan(...interface{}) error }) (models.CheckSchedule, error) {
+	var s models.CheckSchedule
+	var startedAt, finishedAt sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &s.StandardID, &s.Name, &s.WindowStart, &s.WindowEnd, &s.Status, &s.CreatedAt, &startedAt, &finishedAt,
+		&s.Total, &s.Checked, &s.Failed)
+	if startedAt.Valid {
+		s.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		s.FinishedAt = &finishedAt.Time
+	}
+	return s, err
+}
+
+// ScheduleCheck accepts a batch of documents (multipart "documents") to be
+// checked against a standard in the next daily window from window_start to
+// window_end ("HH:MM" in DISPLAY_TIMEZONE). The files are stored at once and
+// checked by the scheduler; the uploader is notified when all are done.
+// Archives (zip, and rar or 7z with ARCHIVE_TOOL) are unpacked into their
+// documents; the entries left out are listed in "skipped". Each document is
+// attributed to the student its file name fits, see parseSubmissionName.
+func ScheduleCheck(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["documents"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No files uploaded"})
+		return
+	}
+	files := form.File["documents"]
+	if len(files) > maxScheduledDocuments {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d documents can be scheduled at once", maxScheduledDocuments)})
+		return
+	}
+
+	windowStart := strings.TrimSpace(c.PostForm("window_start"))
+	windowEnd := strings.TrimSpace(c.PostForm("window_end"))
+	_, okStart := scheduleMinute(windowStart)
+	_, okEnd := scheduleMinute(windowEnd)
+	if !okStart || !okEnd || windowStart == windowEnd {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window_start and window_end must be different times as HH:MM"})
+		return
+	}
+
+	standardID, err := database.ResolveID("formatting_standards", c.PostForm("standard_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid standard_id format"})
+		return
+	}
+	if !standardVisibleTo(c, standardID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+		return
+	}
+	moduleID, ok := uploadModuleID(c, standardID)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+
+	configJSON := c.PostForm("config")
+	if configJSON == "" {
+		configJSON = DefaultStandard
+	}
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		name = "Пакет от " + time.Now().In(displayLocation()).Format("02.01.2006 15:04")
+	}
+	if utf8.RuneCountInString(name) > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be up to 200 characters"})
+		return
+	}
+
+	// Files are stored before the batch is registered; they are removed again
+	// when registering fails.
+	uploadDir := "./uploads"
+	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
+		os.Mkdir(uploadDir, 0755)
+	}
+	type storedFile struct {
+		name      string
+		path      string
+		size      int64
+		hash      string
+		studentID *uint
+	}
+	var stored []storedFile
+	var skipped []models.SkippedEntry
+	removeStored := func() {
+		for _, f := range stored {
+			_ = os.Remove(f.path)
+		}
+	}
+	batch := time.Now().UnixNano()
+	for _, file := range files {
+		if archive.IsArchive(file.Filename) {
+			tmp, err := os.MkdirTemp("", "batch-")
+			if err != nil {
+				removeStored()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+				return
+			}
+			unpacked, err := unpackUpload(c, file, tmp)
+			for _, f := range unpacked.Files {
+				if err != nil {
+					break
+				}
+				path := filepath.Join(uploadDir, fmt.Sprintf("%d_%d_%s", batch, len(stored), f.Name))
+				var hash string
+				if hash, err = storeUnpacked(f.Disk, path); err == nil {
+					stored = append(stored, storedFile{name: f.Name, path: path, size: f.Size, hash: hash})
+				}
+			}
+			_ = os.RemoveAll(tmp)
+			if err != nil {
+				removeStored()
+				switch {
+				case errors.Is(err, archive.ErrUnsupported):
+					c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("Archives like %s are not supported on this server", file.Filename)})
+				case errors.Is(err, archive.ErrTooLarge):
+					c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("The archive %s holds too many or too large documents", file.Filename)})
+				default:
+					fmt.Printf("ScheduleCheck: failed to unpack %s: %v\n", file.Filename, err)
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Failed to unpack %s", file.Filename)})
+				}
+				return
+			}
+			for _, e := range unpacked.Skipped {
+				skipped = append(skipped, models.SkippedEntry{Archive: file.Filename, Path: e.Path, Reason: e.Reason})
+			}
+			continue
+		}
+
+		hash, err := hashUploadedFile(file)
+		if err != nil {
+			removeStored()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+			return
+		}
+		path := filepath.Join(uploadDir, fmt.Sprintf("%d_%d_%s", batch, len(stored), filepath.Base(file.Filename)))
+		if err := c.SaveUploadedFile(file, path); err != nil {
+			removeStored()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+			return
+		}
+		stored = append(stored, storedFile{name: file.Filename, path: path, size: file.Size, hash: hash})
+	}
+	if len(stored) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The uploaded archives hold no documents", "skipped": skipped})
+		return
+	}
+	if len(stored) > maxScheduledDocuments {
+		removeStored()
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d documents can be scheduled at once", maxScheduledDocuments)})
+		return
+	}
+
+	// Works are attributed to students by their file names; names that fit
+	// no student or several are left unattributed.
+	if matcher, err := loadStudentMatcher(); err != nil {
+		fmt.Printf("ScheduleCheck: failed to load students: %v\n", err)
+	} else {
+		for i := range stored {
+			if id, _, ok := matcher.match(stored[i].name); ok {
+				stored[i].studentID = &id
+			}
+		}
+	}
+
+	now := database.Timestamp(time.Now())
+	tx, err := database.DB.Begin()
+	if err != nil {
+		removeStored()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule the check"})
+		return
+	}
+	res, err := tx.Exec("INSERT INTO check_schedules (user_id, standard_id, name, window_start, window_end, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		userID, standardID, name, windowStart, windowEnd, models.ScheduleWaiting, now)
+	var scheduleID int64
+	if err == nil {
+		scheduleID, err = res.LastInsertId()
+	}
+	for _, f := range stored {
+		if err != nil {
+			break
+		}
+		_, err = tx.Exec("INSERT INTO documents (user_id, file_name, file_path, file_size, upload_date, status, content_hash, standard_id, config_json, module_id, schedule_id, student_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			userID, f.name, f.path, f.size, now, models.DocStatusScheduled, f.hash, standardID, configJSON, moduleID, scheduleID, f.studentID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		removeStored()
+		fmt.Printf("ScheduleCheck: failed to register the batch: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule the check"})
+		return
+	}
+
+	s, err := scanCheckSchedule(database.DB.QueryRow("SELECT "+checkScheduleColumns+" FROM check_schedules WHERE id = ?", scheduleID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load the scheduled check"})
+		return
+	}
+	s.Skipped = skipped
+	c.JSON(http.StatusCreated, s)
+}
+
+// GetCheckSchedules lists the user's batch checks, newest first; admins see all.
+func GetCheckSchedules(c *gin.Context) {
+	query := "SELECT " + checkScheduleColumns + " FROM check_schedules"
+	var args []interface{}
+	if c.GetString("role") != "admin" {
+		query += " WHERE user_id = ?"
+		args = append(args, c.GetUint("user_id"))
+	}
+	rows, err := database.DB.Query(query+" ORDER BY created_at DESC, id DESC", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled checks"})
+		return
+	}
+	defer rows.Close()
+
+	schedules := []models.CheckSchedule{}
+	for rows.Next() {
+		s, err := scanCheckSchedule(rows)
+		if err != nil {
+			continue
+		}
+		schedules = append(schedules, s)
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+// GetCheckSchedule returns a batch check with its documents and their results.
+func GetCheckSchedule(c *gin.Context) {
+	s, ok := ownCheckSchedule(c)
+	if !ok {
+		return
+	}
+	rows, err := database.DB.Query(`
+		SELECT d.id, COALESCE(d.uuid, ''), d.file_name, d.status, COALESCE(d.last_error, ''), COALESCE(cr.uuid, ''), cr.overall_score,
+			d.student_id, COALESCE(u.full_name, '')
+		FROM documents d
+		LEFT JOIN check_results cr ON cr.id = (SELECT MAX(id) FROM check_results WHERE document_id = d.id)
+		LEFT JOIN users u ON u.id = d.student_id
+		WHERE d.schedule_id = ?
+		ORDER BY d.id
+	`, s.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled documents"})
+		return
+	}
+	defer rows.Close()
+
+	s.Documents = []models.ScheduledDocument{}
+	for rows.Next() {
+		var d models.ScheduledDocument
+		var score sql.NullFloat64
+		var studentID sql.NullInt64
+		if err := rows.Scan(&d.ID, &d.UUID, &d.FileName, &d.Status, &d.LastError, &d.ResultID, &score, &studentID, &d.StudentName); err != nil {
+			continue
+		}
+		if score.Valid {
+			d.Score = &score.Float64
+		}
+		if studentID.Valid {
+			id := uint(studentID.Int64)
+			d.StudentID = &id
+		}
+		s.Documents = append(s.Documents, d)
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// CancelCheckSchedule cancels a batch check that has not finished. Documents
+// already checked keep their results; the others are removed with their files.
+func CancelCheckSchedule(c *gin.Context) {
+	s, ok := ownCheckSchedule(c)
+	if !ok {
+		return
+	}
+	if s.Status == models.ScheduleDone || s.Status == models.ScheduleCancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "The batch check is already " + s.Status})
+		return
+	}
+
+	rows, err := database.DB.Query("SELECT id, file_path FROM documents WHERE schedule_id = ? AND status = ?", s.ID, models.DocStatusScheduled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel the batch check"})
+		return
+	}
+	var paths []string
+	var ids []interface{}
+	for rows.Next() {
+		var id int64
+		var path string
+		if err := rows.Scan(&id, &path); err == nil {
+			ids = append(ids, id)
+			paths = append(paths, path)
+		}
+	}
+	rows.Close()
+
+	if _, err := database.DB.Exec("UPDATE check_schedules SET status = ?, finished_at = ? WHERE id = ?",
+		models.ScheduleCancelled, database.Timestamp(time.Now()), s.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel the batch check"})
+		return
+	}
+	if len(ids) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+		if _, err := database.DB.Exec("DELETE FROM documents WHERE id IN ("+placeholders+")", ids...); err != nil {
+			fmt.Printf("CancelCheckSchedule: failed to remove the documents of batch %d: %v\n", s.ID, err)
+		}
+		for _, path := range paths {
+			_ = os.Remove(path)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Batch check cancelled", "removed_documents": len(ids)})
+}
+
+func ownCheckSchedule(c *gin.Context) (models.CheckSchedule, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return models.CheckSchedule{}, false
+	}
+	s, err := scanCheckSchedule(database.DB.QueryRow("SELECT "+checkScheduleColumns+" FROM check_schedules WHERE id = ?", id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled check not found"})
+		return s, false
+	}
+	if s.UserID != c.GetUint("user_id") && c.GetString("role") != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage your own scheduled checks"})
+		return s, false
+	}
+	return s, true
+}
+
+// StartCheckScheduler runs the scheduled batch checks in their time windows.
+// Due batches are looked for every SCHEDULER_INTERVAL_SECONDS (default 60,
+// 0 disables the scheduler). Documents are checked one at a time, so the
+// worker pool keeps free workers for interactive checks.
+func StartCheckScheduler() {
+	interval := defaultSchedulerInterval
+	if n, err := strconv.Atoi(os.Getenv("SCHEDULER_INTERVAL_SECONDS")); err == nil && n >= 0 {
+		interval = time.Duration(n) * time.Second
+	}
+	if interval == 0 {
+		fmt.Println("Scheduler: disabled")
+		return
+	}
+
+	go func() {
+		for {
+			runDueSchedules(time.Now())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// requeueInterruptedDocuments puts the documents of a batch whose run was
+// interrupted, by a restart or a replica that died, back in its queue. It is
+// called by the holder of the batch lock, so no run of the batch is going on.
+// A document interrupted while converting already has its result and is left
+// failed, to be retried like any other.
+func requeueInterruptedDocuments(scheduleID uint) {
+	_, err := database.DB.Exec(`UPDATE documents SET status = ? WHERE schedule_id = ? AND status IN (?, ?, ?)`,
+		models.DocStatusScheduled, scheduleID, models.StageParsing, models.StageChecking, models.StageSaving)
+	if err != nil {
+		fmt.Printf("Scheduler: failed to requeue interrupted documents of batch %d: %v\n", scheduleID, err)
+	}
+	_, _ = database.DB.Exec(`UPDATE documents SET status = ?, last_error = ? WHERE schedule_id = ? AND status = ?`,
+		models.FailedStatus(models.StageConverting), "interrupted by a server restart", scheduleID, models.StageConverting)
+}
+
+// runDueSchedules checks the documents of every unfinished batch whose
+// window is open at now, in DISPLAY_TIMEZONE.
+func runDueSchedules(now time.Time) {
+	rows, err := database.DB.Query("SELECT "+checkScheduleColumns+" FROM check_schedules WHERE status IN (?, ?) ORDER BY id",
+		models.ScheduleWaiting, models.ScheduleRunning)
+	if err != nil {
+		fmt.Printf("Scheduler: failed to fetch scheduled checks: %v\n", err)
+		return
+	}
+	var due []models.CheckSchedule
+	for rows.Next() {
+		s, err := scanCheckSchedule(rows)
+		if err == nil && inScheduleWindow(now.In(displayLocation()), s.WindowStart, s.WindowEnd) {
+			due = append(due, s)
+		}
+	}
+	rows.Close()
+
+	for _, s := range due {
+		runSchedule(s)
+	}
+}
+
+// runSchedule checks the waiting documents of a batch until none is left or
+// its window closes; the rest wait for the next window. A batch is run by one
+// replica at a time: the others skip it while its lock is held. The lock is
+// extended while a document is checked and converted, so it only expires when
+// the replica running the batch is gone.
+func runSchedule(s models.CheckSchedule) {
+	const lockTTL = time.Minute
+	lock, ok := jobLocks.acquire(fmt.Sprintf("schedule:%d", s.ID), lockTTL)
+	if !ok {
+		return
+	}
+	defer lock.release()
+	requeueInterruptedDocuments(s.ID)
+
+	if s.Status == models.ScheduleWaiting {
+		_, _ = database.DB.Exec("UPDATE check_schedules SET status = ?, started_at = ? WHERE id = ?",
+			models.ScheduleRunning, database.Timestamp(time.Now()), s.ID)
+	}
+	for inScheduleWindow(time.Now().In(displayLocation()), s.WindowStart, s.WindowEnd) {
+		var docID int64
+		err := database.DB.QueryRow("SELECT id FROM documents WHERE schedule_id = ? AND status = ? ORDER BY id LIMIT 1",
+			s.ID, models.DocStatusScheduled).Scan(&docID)
+		if err == sql.ErrNoRows {
+			finishSchedule(s)
+			return
+		}
+		if err != nil {
+			fmt.Printf("Scheduler: failed to fetch the next document of batch %d: %v\n", s.ID, err)
+			return
+		}
+		p, _, err := loadDocumentPipeline(docID)
+		if err != nil {
+			setDocumentStatus(docID, models.FailedStatus(models.StageParsing), err.Error())
+			continue
+		}
+		p.WaitForPDF = true
+		stopExtending := keepJobLock(lock, lockTTL)
+		if out := runCheckPipeline(context.Background(), p); out.Err != nil {
+			fmt.Printf("Scheduler: document %d of batch %d failed: %v\n", docID, s.ID, out.Err)
+		}
+		if !stopExtending() {
+			fmt.Printf("Scheduler: lost the lock of batch %d\n", s.ID)
+			return
+		}
+	}
+}
+
+// finishSchedule marks a running batch done and notifies its uploader,
+// unless the notifications.schedule_done setting is off.
+func finishSchedule(s models.CheckSchedule) {
+	res, err := database.DB.Exec("UPDATE check_schedules SET status = ?, finished_at = ? WHERE id = ? AND status = ?",
+		models.ScheduleDone, database.Timestamp(time.Now()), s.ID, models.ScheduleRunning)
+	if err != nil {
+		fmt.Printf("Scheduler: failed to finish batch %d: %v\n", s.ID, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return // cancelled meanwhile
+	}
+	if !settings.Bool("notifications.schedule_done") {
+		return
+	}
+	done, err := scanCheckSchedule(database.DB.QueryRow("SELECT "+checkScheduleColumns+" FROM check_schedules WHERE id = ?", s.ID))
+	if err != nil {
+		return
+	}
+	message := fmt.Sprintf("Пакетная проверка «%s» завершена: проверено %d из %d", done.Name, done.Checked, done.Total)
+	if done.Failed > 0 {
+		message += fmt.Sprintf(", не удалось проверить %d", done.Failed)
+	}
+	notify(done.UserID, models.NotificationScheduleDone, message, fmt.Sprintf("/api/check/schedule/%d", done.ID))
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/settings"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSettings lists the server settings with the values in effect.
+func GetSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, settings.All())
+}
+
+// UpdateSettings saves the settings in the request body, an object of keys
+// and JSON values of the setting's type. Either all are valid and saved or
+// none is. Runtime settings apply at once, on other replicas within
+// SETTINGS_RELOAD_SECONDS.
+func UpdateSettings(c *gin.Context) {
+	var body map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(body) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No settings given"})
+		return
+	}
+	values := make(map[string]string, len(body))
+	for key, raw := range body {
+		def, ok := settings.Lookup(key)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown setting %q", key)})
+			return
+		}
+		value, err := settings.Parse(def, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		values[key] = value
+	}
+	if err := settings.Save(values, c.GetUint("user_id")); err != nil {
+		fmt.Printf("UpdateSettings: failed to save: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+		return
+	}
+	GetSettings(c)
+}
+
+// ResetSetting removes the saved value of a setting, so its environment
+// variable or builtin default applies again.
+func ResetSetting(c *gin.Context) {
+	key := c.Param("key")
+	if _, ok := settings.Lookup(key); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Setting not found"})
+		return
+	}
+	if err := settings.Reset(key); err != nil {
+		fmt.Printf("ResetSetting: failed to reset %s: %v\n", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset setting"})
+		return
+	}
+	GetSettings(c)
+}
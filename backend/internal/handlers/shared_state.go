@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/redis"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redisKeyPrefix namespaces the keys the service keeps in Redis.
+const redisKeyPrefix = "normocontrol:"
+
+// ConfigureSharedState selects where the state replicas of the service must
+// share is kept: JOB_BACKEND for the locks of background jobs,
+// PARSE_CACHE_BACKEND for parsed documents and RESPONSE_CACHE_BACKEND for the
+// invalidation of cached responses, each "memory" (default) or "redis". The
+// Redis backends use REDIS_URL and let several replicas run behind a load
+// balancer.
+func ConfigureSharedState() {
+	if backend(os.Getenv("JOB_BACKEND"), "JOB_BACKEND") == "redis" {
+		jobLocks = &redisJobLocker{client: redis.Default()}
+		log.Println("Job locks: redis")
+	}
+	if backend(os.Getenv("PARSE_CACHE_BACKEND"), "PARSE_CACHE_BACKEND") == "redis" {
+		ttl := time.Hour
+		if n, err := strconv.Atoi(os.Getenv("PARSE_CACHE_TTL_MINUTES")); err == nil && n > 0 {
+			ttl = time.Duration(n) * time.Minute
+		}
+		checker.DefaultParseCache = &checker.SharedParseCache{
+			Local:  checker.NewParseCache(32),
+			Store:  redis.Default(),
+			Prefix: redisKeyPrefix + "parsed:",
+			TTL:    ttl,
+			Logf:   func(format string, args ...interface{}) { fmt.Printf(format, args...) },
+		}
+		log.Printf("Parse cache: redis, TTL %s", ttl)
+	}
+	if backend(os.Getenv("RESPONSE_CACHE_BACKEND"), "RESPONSE_CACHE_BACKEND") == "redis" {
+		responses.shared = redis.Default()
+		log.Println("Response cache invalidation: redis")
+	}
+}
+
+// backend validates the value of the backend variable name.
+func backend(value, name string) string {
+	switch value {
+	case "", "memory":
+		return "memory"
+	case "redis":
+		if redis.Default() == nil {
+			log.Fatalf("%s=redis requires REDIS_URL", name)
+		}
+		return "redis"
+	}
+	log.Fatalf("Unknown %s %q, expected memory or redis", name, value)
+	return ""
+}
+
+// jobLocker keeps a background job, such as a scheduled batch, from running
+// on two replicas at once.
+type jobLocker interface {
+	// acquire takes the lock named name for ttl; ok is false while someone
+	// else holds it.
+	acquire(name string, ttl time.Duration) (lock jobLock, ok bool)
+}
+
+type jobLock interface {
+	// extend holds the lock for another ttl and reports whether it was
+	// still held.
+	extend(ttl time.Duration) bool
+	release()
+}
+
+var jobLocks jobLocker = &memoryJobLocker{held: map[string]bool{}}
+
+// keepJobLock extends lock every third of ttl in the background, so that work
+// outlasting ttl, like a check waiting for its PDF, keeps the lock. stop ends
+// the extending and reports whether the lock was held throughout.
+func keepJobLock(lock jobLock, ttl time.Duration) (stop func() bool) {
+	done := make(chan struct{})
+	finished := make(chan bool, 1)
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				finished <- true
+				return
+			case <-ticker.C:
+				if !lock.extend(ttl) {
+					finished <- false
+					return
+				}
+			}
+		}
+	}()
+	return func() bool {
+		close(done)
+		return <-finished && lock.extend(ttl)
+	}
+}
+
+// memoryJobLocker only excludes jobs of this process, which is all a single
+// replica needs. Its locks do not expire.
+type memoryJobLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+type memoryJobLock struct {
+	locker *memoryJobLocker
+	name   string
+}
+
+func (l *memoryJobLocker) acquire(name string, ttl time.Duration) (jobLock, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[name] {
+		return nil, false
+	}
+	l.held[name] = true
+	return &memoryJobLock{locker: l, name: name}, true
+}
+
+func (m *memoryJobLock) extend(time.Duration) bool { return true }
+
+func (m *memoryJobLock) release() {
+	m.locker.mu.Lock()
+	defer m.locker.mu.Unlock()
+	delete(m.locker.held, m.name)
+}
+
+// redisJobLocker keeps locks as keys with a random token and an expiry, so
+// the lock of a replica that died is taken over once it expires. The token
+// keeps a holder whose lock expired from extending or releasing the lock of
+// the next one.
+type redisJobLocker struct {
+	client *redis.Client
+}
+
+type redisJobLock struct {
+	client *redis.Client
+	key    string
+	token  string
+}
+
+const (
+	extendLockScript  = `if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('PEXPIRE', KEYS[1], ARGV[2]) end return 0`
+	releaseLockScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('DEL', KEYS[1]) end return 0`
+)
+
+func (l *redisJobLocker) acquire(name string, ttl time.Duration) (jobLock, bool) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, false
+	}
+	lock := &redisJobLock{client: l.client, key: redisKeyPrefix + "lock:" + name, token: hex.EncodeToString(b)}
+	ok, err := l.client.SetNX(context.Background(), lock.key, []byte(lock.token), ttl)
+	if err != nil {
+		fmt.Printf("JobLock: failed to acquire %s: %v\n", name, err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	return lock, true
+}
+
+func (l *redisJobLock) extend(ttl time.Duration) bool {
+	reply, err := l.client.Eval(context.Background(), extendLockScript, []string{l.key}, l.token, ttl.Milliseconds())
+	if err != nil {
+		fmt.Printf("JobLock: failed to extend %s: %v\n", l.key, err)
+		return false
+	}
+	n, _ := reply.(int64)
+	return n == 1
+}
+
+func (l *redisJobLock) release() {
+	if _, err := l.client.Eval(context.Background(), releaseLockScript, []string{l.key}, l.token); err != nil {
+		fmt.Printf("JobLock: failed to release %s: %v\n", l.key, err)
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingLock is a jobLock whose extend succeeds until lost is set.
+type countingLock struct {
+	mu      sync.Mutex
+	extends int
+	lost    bool
+}
+
+func (l *countingLock) extend(time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.extends++
+	return !l.lost
+}
+
+func (l *countingLock) release() {}
+
+func TestKeepJobLock(t *testing.T) {
+	lock := &countingLock{}
+	stop := keepJobLock(lock, 30*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if !stop() {
+		t.Fatal("stop reported a lost lock")
+	}
+	lock.mu.Lock()
+	extends := lock.extends
+	lock.mu.Unlock()
+	if extends < 3 {
+		t.Fatalf("lock extended %d times in 100ms with a 30ms ttl, want at least 3", extends)
+	}
+
+	lost := &countingLock{lost: true}
+	stop = keepJobLock(lost, 30*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if stop() {
+		t.Fatal("stop reported a lock that could not be extended as held")
+	}
+}
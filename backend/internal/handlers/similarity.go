@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// similarityThreshold is the default overall similarity from which two
+// submissions are reported.
+const similarityThreshold = 0.9
+
+// SimilarSubmission is one side of a pair of similar submissions.
+type SimilarSubmission struct {
+	StudentID   uint   `json:"student_id"`
+	StudentName string `json:"student_name"`
+	ResultID    string `json:"result_id"`
+	SubmittedAt string `json:"submitted_at"`
+}
+
+// SimilarPair is two students whose latest submissions to an assignment have
+// near-identical structure: a shared template or a copy. It is a signal for
+// the teacher to look at both works, not a verdict.
+type SimilarPair struct {
+	A     SimilarSubmission        `json:"a"`
+	B     SimilarSubmission        `json:"b"`
+	Match checker.FingerprintMatch `json:"match"`
+}
+
+// GetAssignmentSimilarity compares the structural fingerprints of the latest
+// submission of every student to the assignment and lists the pairs of
+// students at or above ?min_similarity= (0..1, default 0.9), most similar
+// first. Submissions too short to say anything are skipped.
+func GetAssignmentSimilarity(c *gin.Context) {
+	a, ok := ownAssignment(c)
+	if !ok {
+		return
+	}
+	threshold := similarityThreshold
+	if raw := c.Query("min_similarity"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 || v > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_similarity must be a number from 0 to 1"})
+			return
+		}
+		threshold = v
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT u.id, COALESCE(u.full_name, ''), cr.uuid, cr.check_date, cr.fingerprint_json
+		FROM check_results cr
+		JOIN documents d ON d.id = cr.document_id
+		JOIN users u ON u.id = d.user_id
+		WHERE cr.assignment_id = ? AND COALESCE(cr.fingerprint_json, '') <> ''
+		ORDER BY u.id, cr.check_date DESC, cr.id DESC`, a.ID)
+	if err != nil {
+		fmt.Printf("Similarity: failed to load submissions: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare submissions"})
+		return
+	}
+	defer rows.Close()
+
+	type fingerprinted struct {
+		SimilarSubmission
+		fingerprint checker.Fingerprint
+	}
+	var latest []fingerprinted
+	var lastStudent uint
+	for rows.Next() {
+		var s fingerprinted
+		var checkDate time.Time
+		var raw string
+		if err := rows.Scan(&s.StudentID, &s.StudentName, &s.ResultID, &checkDate, &raw); err != nil {
+			continue
+		}
+		// Rows come newest first: the first one of a student is the latest
+		if s.StudentID == lastStudent {
+			continue
+		}
+		lastStudent = s.StudentID
+		if json.Unmarshal([]byte(raw), &s.fingerprint) != nil || s.fingerprint.Paragraphs < checker.MinFingerprintParagraphs {
+			continue
+		}
+		s.SubmittedAt = database.FormatTimestamp(checkDate)
+		latest = append(latest, s)
+	}
+
+	pairs := []SimilarPair{}
+	for i := range latest {
+		for j := i + 1; j < len(latest); j++ {
+			m := checker.CompareFingerprints(latest[i].fingerprint, latest[j].fingerprint)
+			if m.Similarity >= threshold {
+				pairs = append(pairs, SimilarPair{A: latest[i].SimilarSubmission, B: latest[j].SimilarSubmission, Match: m})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Match.Similarity > pairs[j].Match.Similarity })
+
+	c.JSON(http.StatusOK, gin.H{"assignment": a, "compared": len(latest), "min_similarity": threshold, "pairs": pairs})
+}
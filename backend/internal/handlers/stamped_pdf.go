@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/convert"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/pdfstamp"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stampConversionTimeout bounds the conversion of a document whose PDF is
+// not there yet, e.g. because conversion was off when it was checked.
+const stampConversionTimeout = 2 * time.Minute
+
+// verificationAlphabet leaves out letters easily confused with digits when
+// the code is typed from paper.
+const verificationAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newVerificationCode returns a random code such as "7KQ2M-XH9PD".
+func newVerificationCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, 11)
+	for i, c := range b {
+		if i == 5 {
+			code = append(code, '-')
+		}
+		code = append(code, verificationAlphabet[int(c)%len(verificationAlphabet)])
+	}
+	return string(code), nil
+}
+
+// resultVerificationCode returns the verification code of a result, giving
+// it one on first use.
+func resultVerificationCode(resultID uint) (string, error) {
+	var code sql.NullString
+	if err := database.DB.QueryRow("SELECT verification_code FROM check_results WHERE id = ?", resultID).Scan(&code); err != nil {
+		return "", err
+	}
+	if code.Valid && code.String != "" {
+		return code.String, nil
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		newCode, err := newVerificationCode()
+		if err != nil {
+			return "", err
+		}
+		// The unique index rejects a code another result has; a concurrent
+		// request for the same result may have set one first.
+		_, err = database.DB.Exec("UPDATE check_results SET verification_code = ? WHERE id = ? AND verification_code IS NULL", newCode, resultID)
+		if err != nil {
+			continue
+		}
+		if err := database.DB.QueryRow("SELECT verification_code FROM check_results WHERE id = ?", resultID).Scan(&code); err != nil {
+			return "", err
+		}
+		if code.Valid {
+			return code.String, nil
+		}
+	}
+	return "", errors.New("failed to assign a verification code")
+}
+
+// stampText is the footer of each page of a stamped PDF.
+func stampText(score float64, checkDate time.Time, code string) string {
+	return fmt.Sprintf("Проверено NormoControl, балл %s, %s, код верификации %s",
+		strconv.FormatFloat(math.Round(score*10)/10, 'f', -1, 64), checkDate.Format("02.01.2006"), code)
+}
+
+// GetStampedPDF returns the PDF of the checked document with a footer on
+// every page naming the score, the date of the check and a verification
+// code, for the printed thesis packet. The PDF is converted first when the
+// check did not produce one. Access is as for the normocontrol report.
+func GetStampedPDF(c *gin.Context) {
+	id, err := database.ResolveID("check_results", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	var resultID, ownerID, standardAuthor uint
+	var filePath, fileName string
+	var score float64
+	var checkDate time.Time
+	err = database.DB.QueryRow(`
+		SELECT cr.id, d.file_path, d.file_name, cr.overall_score, cr.check_date, d.user_id, COALESCE(s.created_by, 0)
+		FROM check_results cr
+		JOIN documents d ON cr.document_id = d.id
+		LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+		WHERE cr.id = ?
+	`, id).Scan(&resultID, &filePath, &fileName, &score, &checkDate, &ownerID, &standardAuthor)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	if userID != ownerID && userID != standardAuthor && c.GetString("role") != "admin" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History item not found"})
+		return
+	}
+
+	pdfPath := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".pdf"
+	if _, err := os.Stat(pdfPath); err != nil {
+		if _, err := os.Stat(filePath); err != nil {
+			c.JSON(http.StatusGone, gin.H{"error": "Stored file is no longer available"})
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), stampConversionTimeout)
+		err := convert.Default().Convert(ctx, filePath, pdfPath)
+		cancel()
+		switch {
+		case errors.Is(err, convert.ErrDisabled):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "PDF conversion is disabled on this server"})
+			return
+		case errors.Is(err, convert.ErrUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "PDF converter is unavailable, try again later"})
+			return
+		case err != nil:
+			fmt.Printf("GetStampedPDF: converting result %d failed: %v\n", resultID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to convert the document to PDF"})
+			return
+		}
+	}
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Stored file is no longer available"})
+		return
+	}
+
+	code, err := resultVerificationCode(resultID)
+	if err != nil {
+		fmt.Printf("GetStampedPDF: verification code of result %d: %v\n", resultID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stamp the document"})
+		return
+	}
+	stamped, err := pdfstamp.Stamp(data, stampText(score, checkDate.In(displayLocation()), code), pdfstamp.DefaultStyle)
+	if err != nil {
+		fmt.Printf("GetStampedPDF: stamping result %d failed: %v\n", resultID, err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "The PDF of this document cannot be stamped"})
+		return
+	}
+
+	name := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "_stamped.pdf"
+	c.Header("X-Verification-Code", code)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="stamped-%d.pdf"; filename*=UTF-8''%s`, resultID, url.PathEscape(name)))
+	c.Data(http.StatusOK, "application/pdf", stamped)
+}
+
+// VerifyStamp confirms the footer of a stamped PDF: anyone holding the
+// printed copy may look up the score and date the code was issued for.
+func VerifyStamp(c *gin.Context) {
+	code := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+	var fileName, standardName string
+	var score float64
+	var checkDate time.Time
+	err := database.DB.QueryRow(`
+		SELECT d.file_name, COALESCE(s.name, ''), cr.overall_score, cr.check_date
+		FROM check_results cr
+		JOIN documents d ON cr.document_id = d.id
+		LEFT JOIN formatting_standards s ON cr.standard_id = s.id
+		WHERE cr.verification_code = ?
+	`, code).Scan(&fileName, &standardName, &score, &checkDate)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Verification code not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"verification_code": code,
+		"document_name":     fileName,
+		"standard_name":     standardName,
+		"score":             score,
+		"check_date":        checkDate,
+	})
+}
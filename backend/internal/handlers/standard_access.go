@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxStandardGrants bounds the groups and users one standard is shared with.
+const maxStandardGrants = 500
+
+// sharedStandardCondition selects the standards of the formatting_standards
+// alias fs shared with a user, directly or through their group; it takes the
+// user's id twice.
+const sharedStandardCondition = `fs.id IN (SELECT standard_id FROM standard_access
+	WHERE user_id = ? OR group_id = (SELECT group_id FROM users WHERE id = ?))`
+
+// standardSharedWith reports whether a standard is shared with the user,
+// directly or through their group.
+func standardSharedWith(standardID int64, userID uint) bool {
+	var n int
+	database.DB.QueryRow(`SELECT COUNT(*) FROM formatting_standards fs WHERE fs.id = ? AND `+sharedStandardCondition,
+		standardID, userID, userID).Scan(&n)
+	return n > 0
+}
+
+// standardVisibleTo reports whether the user of the request may check
+// against a standard: it is public, theirs, shared with them or required by
+// an assignment of their group, or they are an admin.
+func standardVisibleTo(c *gin.Context, standardID int64) bool {
+	userID := c.GetUint("user_id")
+	var createdBy sql.NullInt64
+	var public bool
+	err := database.DB.QueryRow("SELECT created_by, COALESCE(is_public, FALSE) FROM formatting_standards WHERE id = ?", standardID).Scan(&createdBy, &public)
+	if err != nil {
+		return false
+	}
+	if public || uint(createdBy.Int64) == userID || c.GetString("role") == "admin" || standardSharedWith(standardID, userID) {
+		return true
+	}
+	var n int
+	database.DB.QueryRow("SELECT COUNT(*) FROM assignments WHERE standard_id = ? AND group_id = (SELECT group_id FROM users WHERE id = ?)",
+		standardID, userID).Scan(&n)
+	return n > 0
+}
+
+// loadStandardAccess lists the grants of a standard, groups first.
+func loadStandardAccess(standardID int64) ([]models.StandardAccess, error) {
+	rows, err := database.DB.Query(`
+		SELECT a.id, a.standard_id, a.group_id, COALESCE(g.group_name, ''), a.user_id, COALESCE(NULLIF(u.full_name, ''), u.email, ''),
+		       COALESCE(a.granted_by, 0), a.created_at
+		FROM standard_access a
+		LEFT JOIN student_groups g ON g.id = a.group_id
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE a.standard_id = ?
+		ORDER BY a.group_id IS NULL, g.group_name, u.full_name, a.id
+	`, standardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []models.StandardAccess{}
+	for rows.Next() {
+		var a models.StandardAccess
+		var groupID, userID sql.NullInt64
+		var createdAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.StandardID, &groupID, &a.GroupName, &userID, &a.UserName, &a.GrantedBy, &createdAt); err != nil {
+			return nil, err
+		}
+		if groupID.Valid {
+			id := uint(groupID.Int64)
+			a.GroupID = &id
+		}
+		if userID.Valid {
+			id := uint(userID.Int64)
+			a.UserID = &id
+		}
+		a.CreatedAt = createdAt.Time
+		grants = append(grants, a)
+	}
+	return grants, rows.Err()
+}
+
+// GetStandardAccess lists the groups and users a standard is shared with.
+func GetStandardAccess(c *gin.Context) {
+	id, _, ok := ownStandard(c)
+	if !ok {
+		return
+	}
+	grants, err := loadStandardAccess(id)
+	if err != nil {
+		fmt.Printf("GetStandardAccess: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch standard access"})
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+type standardAccessRequest struct {
+	GroupIDs []uint `json:"group_ids"`
+	UserIDs  []uint `json:"user_ids"`
+}
+
+// SetStandardAccess replaces the groups and users a standard is shared with.
+// Students of a group and the users given see the standard in their list and
+// may check against it, export and copy it, as if it were public; editing
+// stays with the author. An empty request stops sharing.
+func SetStandardAccess(c *gin.Context) {
+	id, _, ok := ownStandard(c)
+	if !ok {
+		return
+	}
+	var req standardAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	groups, users := uniqueIDs(req.GroupIDs), uniqueIDs(req.UserIDs)
+	if len(groups)+len(users) > maxStandardGrants {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d grants per standard", maxStandardGrants)})
+		return
+	}
+	for _, g := range groups {
+		var exists int
+		if database.DB.QueryRow("SELECT COUNT(*) FROM student_groups WHERE id = ?", g).Scan(&exists); exists == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Group %d not found", g)})
+			return
+		}
+	}
+	for _, u := range users {
+		var exists int
+		if database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE id = ?", u).Scan(&exists); exists == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("User %d not found", u)})
+			return
+		}
+	}
+
+	userID := c.GetUint("user_id")
+	now := database.Timestamp(time.Now())
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update standard access"})
+		return
+	}
+	_, err = tx.Exec("DELETE FROM standard_access WHERE standard_id = ?", id)
+	for _, g := range groups {
+		if err != nil {
+			break
+		}
+		_, err = tx.Exec("INSERT INTO standard_access (standard_id, group_id, granted_by, created_at) VALUES (?, ?, ?, ?)", id, g, userID, now)
+	}
+	for _, u := range users {
+		if err != nil {
+			break
+		}
+		_, err = tx.Exec("INSERT INTO standard_access (standard_id, user_id, granted_by, created_at) VALUES (?, ?, ?, ?)", id, u, userID, now)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		fmt.Printf("SetStandardAccess: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update standard access"})
+		return
+	}
+	invalidateResponses(cacheStandards)
+
+	grants, err := loadStandardAccess(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch standard access"})
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// uniqueIDs drops zeros and repeats, keeping the order.
+func uniqueIDs(ids []uint) []uint {
+	seen := map[uint]bool{}
+	var out []uint
+	for _, id := range ids {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
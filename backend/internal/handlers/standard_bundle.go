@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// standardBundleFormat and standardBundleVersion identify a standard export.
+// The version grows when the bundle changes incompatibly; older bundles
+// keep importing.
+const (
+	standardBundleFormat  = "normocontrol-standard"
+	standardBundleVersion = 1
+)
+
+// standardBundleMaxBytes bounds an imported bundle.
+const standardBundleMaxBytes = 2 << 20
+
+// StandardBundle is a standard as a self-contained file, to share it with
+// other departments or deployments without the catalog.
+type StandardBundle struct {
+	Format        string                    `json:"format"`
+	SchemaVersion int                       `json:"schema_version"`
+	ExportedAt    time.Time                 `json:"exported_at"`
+	Source        string                    `json:"source,omitempty"` // uuid of the standard exported
+	Version       int                       `json:"version,omitempty"`
+	Name          string                    `json:"name"`
+	Description   string                    `json:"description"`
+	DocumentType  string                    `json:"document_type"`
+	Author        string                    `json:"author,omitempty"`
+	Modules       []models.ValidationModule `json:"modules"`
+}
+
+// ExportStandard downloads a standard as a bundle. Its author, admins and,
+// for public standards, every teacher may export it.
+func ExportStandard(c *gin.Context) {
+	id, err := database.ResolveID("formatting_standards", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+		return
+	}
+	var b StandardBundle
+	var description, documentType, modulesJSON, author sql.NullString
+	var createdBy uint
+	var isPublic bool
+	err = database.DB.QueryRow(`
+		SELECT COALESCE(s.uuid, ''), s.name, s.description, s.document_type, s.modules_json, COALESCE(s.created_by, 0),
+		       COALESCE(s.is_public, FALSE), COALESCE(u.full_name, u.email)
+		FROM formatting_standards s
+		LEFT JOIN users u ON s.created_by = u.id
+		WHERE s.id = ?
+	`, id).Scan(&b.Source, &b.Name, &description, &documentType, &modulesJSON, &createdBy, &isPublic, &author)
+	if err != nil || (!isPublic && createdBy != c.GetUint("user_id") && c.GetString("role") != "admin" && !standardSharedWith(id, c.GetUint("user_id"))) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+		return
+	}
+
+	b.Format, b.SchemaVersion, b.ExportedAt = standardBundleFormat, standardBundleVersion, time.Now().UTC()
+	b.Description, b.DocumentType, b.Author = description.String, documentType.String, author.String
+	b.Modules = []models.ValidationModule{}
+	json.Unmarshal([]byte(modulesJSON.String), &b.Modules)
+	if versionID, err := snapshotStandard(database.DB, id, 0, nil); err == nil {
+		database.DB.QueryRow("SELECT version FROM standard_versions WHERE id = ?", versionID).Scan(&b.Version)
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="standard-%d.json"; filename*=UTF-8''%s`, id, url.PathEscape(b.Name+".json")))
+	c.IndentedJSON(http.StatusOK, b)
+}
+
+// readStandardBundle reads a bundle from the "file" field of a multipart
+// request or from the JSON body.
+func readStandardBundle(c *gin.Context) ([]byte, error) {
+	var r io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("no file uploaded")
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(io.LimitReader(r, standardBundleMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > standardBundleMaxBytes {
+		return nil, fmt.Errorf("bundle is larger than %d MB", standardBundleMaxBytes>>20)
+	}
+	return data, nil
+}
+
+// validateStandardBundle checks a bundle before import. Module configs must
+// decode as a standard config, so a bundle from a newer deployment with
+// settings this one reads differently is refused rather than misapplied.
+func validateStandardBundle(b *StandardBundle) error {
+	switch {
+	case b.Format != standardBundleFormat:
+		return fmt.Errorf("not a standard bundle (format must be %q)", standardBundleFormat)
+	case b.SchemaVersion < 1 || b.SchemaVersion > standardBundleVersion:
+		return fmt.Errorf("unsupported schema_version %d (this server reads up to %d)", b.SchemaVersion, standardBundleVersion)
+	case strings.TrimSpace(b.Name) == "":
+		return fmt.Errorf("bundle has no name")
+	case len(b.Modules) == 0:
+		return fmt.Errorf("bundle has no modules")
+	}
+	seen := map[string]bool{}
+	for i, m := range b.Modules {
+		if strings.TrimSpace(m.ID) == "" || seen[m.ID] {
+			return fmt.Errorf("module %d has no id or a duplicate one", i+1)
+		}
+		seen[m.ID] = true
+		data, _ := json.Marshal(m.Config)
+		var config checker.ConfigSchema
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("module %q: invalid config: %v", m.Name, err)
+		}
+		if err := checker.ValidateCustomRules(config.CustomRules); err != nil {
+			return fmt.Errorf("module %q: invalid config: %v", m.Name, err)
+		}
+		if err := checker.ValidateModules(config.Modules); err != nil {
+			return fmt.Errorf("module %q: invalid config: %v", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// ImportStandard creates a standard from a bundle, owned by the importing
+// teacher and private until they publish it. Importing the same bundle again
+// creates another copy.
+func ImportStandard(c *gin.Context) {
+	data, err := readStandardBundle(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var b StandardBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bundle: " + err.Error()})
+		return
+	}
+	if err := validateStandardBundle(&b); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("user_id")
+	modules, _ := json.Marshal(b.Modules)
+	now := database.Timestamp(time.Now())
+	res, err := database.DB.Exec(`INSERT INTO formatting_standards (name, description, created_by, document_type, is_public, modules_json, created_at, updated_at)
+		VALUES (?, ?, ?, ?, FALSE, ?, ?, ?)`,
+		strings.TrimSpace(b.Name), b.Description, userID, b.DocumentType, string(modules), now, now)
+	if err != nil {
+		fmt.Printf("ImportStandard: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import standard"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	recordStandardVersion(id, userID)
+	invalidateResponses(cacheStandards)
+	c.JSON(http.StatusCreated, gin.H{"id": id, "uuid": database.UUIDOf("formatting_standards", id), "name": b.Name, "message": "Standard imported"})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type cloneStandardRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// CloneStandard copies the standard of the :id parameter into the account
+// of the current user: their own standard, a public one of a colleague or
+// one shared with them.
+// The copy is private, keeps the modules and document type, and records the
+// standard and the version it was copied from. The name and description may
+// be replaced; the name defaults to the original's with "(копия)".
+func CloneStandard(c *gin.Context) {
+	id, err := database.ResolveID("formatting_standards", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+		return
+	}
+	userID := c.GetUint("user_id")
+	var name string
+	var description, documentType, modulesJSON sql.NullString
+	var createdBy uint
+	var isPublic bool
+	err = database.DB.QueryRow(`
+		SELECT name, description, document_type, modules_json, COALESCE(created_by, 0), COALESCE(is_public, FALSE)
+		FROM formatting_standards WHERE id = ?
+	`, id).Scan(&name, &description, &documentType, &modulesJSON, &createdBy, &isPublic)
+	if err != nil || (!isPublic && createdBy != userID && c.GetString("role") != "admin" && !standardSharedWith(id, userID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+		return
+	}
+
+	var req cloneStandardRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	cloneName := strings.TrimSpace(req.Name)
+	if cloneName == "" {
+		cloneName = name + " (копия)"
+	}
+	cloneDescription := strings.TrimSpace(req.Description)
+	if cloneDescription == "" {
+		cloneDescription = description.String
+	}
+
+	// The version copied is the one current now, recorded first if the
+	// standard predates versioning.
+	var parentVersion sql.NullInt64
+	if versionID, err := snapshotStandard(database.DB, id, 0, nil); err == nil {
+		database.DB.QueryRow("SELECT version FROM standard_versions WHERE id = ?", versionID).Scan(&parentVersion)
+	}
+
+	now := database.Timestamp(time.Now())
+	res, err := database.DB.Exec(`INSERT INTO formatting_standards (name, description, created_by, document_type, is_public, modules_json, parent_standard_id, parent_version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, FALSE, ?, ?, ?, ?, ?)`,
+		cloneName, cloneDescription, userID, documentType.String, modulesJSON.String, id, parentVersion, now, now)
+	if err != nil {
+		fmt.Printf("CloneStandard: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create standard"})
+		return
+	}
+	cloneID, _ := res.LastInsertId()
+	recordStandardVersion(cloneID, userID)
+	invalidateResponses(cacheStandards)
+
+	response := gin.H{
+		"id":                 cloneID,
+		"uuid":               database.UUIDOf("formatting_standards", cloneID),
+		"name":               cloneName,
+		"parent_standard_id": id,
+		"message":            "Standard created",
+	}
+	if parentVersion.Valid {
+		response["parent_version"] = parentVersion.Int64
+	}
+	c.JSON(http.StatusCreated, response)
+}
@@ -1,18 +1,38 @@
 package handlers
 
 import (
-	"academic-check-sys/internal/checker"
-	"academic-check-sys/internal/database"
-	"academic-check-sys/internal/models"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
 	"net/http"
+	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// validateModuleRules refuses custom rules and rule modules the checker
+// could not apply, so a broken pattern or an unknown module is reported when
+// the standard is saved rather than silently skipped in every check.
+func validateModuleRules(modules []models.ValidationModule) error {
+	var rules []checker.CustomRule
+	for _, m := range modules {
+		data, _ := json.Marshal(m.Config)
+		var config checker.ConfigSchema
+		if json.Unmarshal(data, &config) == nil {
+			rules = append(rules, config.CustomRules...)
+			if err := checker.ValidateModules(config.Modules); err != nil {
+				return err
+			}
+		}
+	}
+	return checker.ValidateCustomRules(rules)
+}
+
 func CreateStandard(c *gin.Context) {
 	// Using generic map or struct for input binding for simplicity
 	type CreateRequest struct {
@@ -28,6 +48,10 @@ func CreateStandard(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateModuleRules(input.Modules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Assuming Auth Middleware sets user_id
 	userID := c.GetUint("user_id")
@@ -47,13 +71,19 @@ func CreateStandard(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create standard: " + err.Error()})
 		return
 	}
-
 	id, _ := res.LastInsertId()
-	c.JSON(http.StatusCreated, gin.H{"id": id, "message": "Standard created"})
+	recordStandardVersion(id, userID)
+	invalidateResponses(cacheStandards)
+
+	c.JSON(http.StatusCreated, gin.H{"id": id, "uuid": database.UUIDOf("formatting_standards", id), "message": "Standard created"})
 }
 
 func UpdateStandard(c *gin.Context) {
-	id := c.Param("id")
+	id, err := database.ResolveID("formatting_standards", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+		return
+	}
 	userID := c.GetUint("user_id")
 
 	type UpdateRequest struct {
@@ -69,10 +99,14 @@ func UpdateStandard(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateModuleRules(input.Modules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Verify ownership before update
 	var ownerID uint
-	err := database.DB.QueryRow("SELECT created_by FROM formatting_standards WHERE id = ?", id).Scan(&ownerID)
+	err = database.DB.QueryRow("SELECT created_by FROM formatting_standards WHERE id = ?", id).Scan(&ownerID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
@@ -97,6 +131,8 @@ func UpdateStandard(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update standard"})
 		return
 	}
+	recordStandardVersion(id, userID)
+	invalidateResponses(cacheStandards)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Standard updated"})
 }
@@ -117,11 +153,20 @@ func GetStandards(c *gin.Context) {
 	}
 	role := roleAny.(string)
 
-	// 3. Prepare Query based on Role
+	// The list is cached per caller: visibility and can_edit depend on both.
+	serveCached(c, cacheStandards, fmt.Sprintf("%s:%d", role, userID), func() (interface{}, error) {
+		return loadStandards(userID, role)
+	})
+}
+
+// loadStandards returns the standards visible to the user, newest first.
+func loadStandards(userID uint, role string) ([]gin.H, error) {
+	// Prepare Query based on Role
 	// using explicit column names is safer
 	baseQuery := `
 		SELECT 
 			fs.id, 
+			COALESCE(fs.uuid, ''),
 			fs.name, 
 			fs.description, 
 			fs.document_type, 
@@ -130,22 +175,26 @@ func GetStandards(c *gin.Context) {
 			fs.created_at, 
 			fs.created_by,
 			u.full_name as author_real_name,
-			u.email as author_email
+			u.email as author_email,
+			fs.parent_standard_id,
+			fs.parent_version,
+			parent.name
 		FROM formatting_standards fs
 		LEFT JOIN users u ON fs.created_by = u.id
+		LEFT JOIN formatting_standards parent ON fs.parent_standard_id = parent.id
 	`
 
 	var rows *sql.Rows
 	var qErr error
 
 	if role == "teacher" {
-		// Teachers see ONLY their own standards
-		query := baseQuery + " WHERE fs.created_by = ? ORDER BY fs.created_at DESC"
-		rows, qErr = database.DB.Query(query, userID)
-	} else if role == "student" {
-		// Students see ONLY public standards
-		query := baseQuery + " WHERE fs.is_public = 1 ORDER BY fs.created_at DESC"
-		rows, qErr = database.DB.Query(query)
+		// Teachers see their own standards and those shared with them
+		query := baseQuery + " WHERE fs.created_by = ? OR " + sharedStandardCondition + " ORDER BY fs.created_at DESC"
+		rows, qErr = database.DB.Query(query, userID, userID, userID)
+	} else if role == "student" || role == "supervisor" {
+		// Students and supervisors see public standards and those shared with them or their group
+		query := baseQuery + " WHERE fs.is_public = 1 OR " + sharedStandardCondition + " ORDER BY fs.created_at DESC"
+		rows, qErr = database.DB.Query(query, userID, userID)
 	} else {
 		// Admins or others see ALL
 		query := baseQuery + " ORDER BY fs.created_at DESC"
@@ -153,21 +202,23 @@ func GetStandards(c *gin.Context) {
 	}
 
 	if qErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error: " + qErr.Error()})
-		return
+		return nil, fmt.Errorf("Database error: %v", qErr)
 	}
 	defer rows.Close()
 
 	var standards []gin.H
 	for rows.Next() {
 		var id uint
-		var name, description, docType, modulesJSON string
+		var uuid, name, description, docType, modulesJSON string
 		var isPublic bool
 		var authorNameStr, authorEmailStr sql.NullString
-		var createdAt interface{}
+		var createdAt time.Time
 		var createdByID uint
+		var parentID, parentVersion sql.NullInt64
+		var parentName sql.NullString
 
-		if err := rows.Scan(&id, &name, &description, &docType, &isPublic, &modulesJSON, &createdAt, &createdByID, &authorNameStr, &authorEmailStr); err != nil {
+		if err := rows.Scan(&id, &uuid, &name, &description, &docType, &isPublic, &modulesJSON, &createdAt, &createdByID, &authorNameStr, &authorEmailStr,
+			&parentID, &parentVersion, &parentName); err != nil {
 			fmt.Println("Scan error:", err)
 			continue
 		}
@@ -185,17 +236,25 @@ func GetStandards(c *gin.Context) {
 			json.Unmarshal([]byte(modulesJSON), &modules)
 		}
 
-		standards = append(standards, gin.H{
+		standard := gin.H{
 			"id":            id,
+			"uuid":          uuid,
 			"name":          name,
 			"description":   description,
 			"document_type": docType,
 			"modules":       modules,
 			"is_public":     isPublic,
-			"created_at":    createdAt,
+			"created_at":    database.FormatTimestamp(createdAt),
 			"author_name":   authorName,
 			"can_edit":      createdByID == userID || role == "admin",
-		})
+		}
+		// Provenance of a copy; the name is empty once the original is deleted.
+		if parentID.Valid {
+			standard["parent_standard_id"] = parentID.Int64
+			standard["parent_version"] = parentVersion.Int64
+			standard["parent_name"] = parentName.String
+		}
+		standards = append(standards, standard)
 	}
 
 	// Return empty list instead of null if empty
@@ -203,7 +262,7 @@ func GetStandards(c *gin.Context) {
 		standards = []gin.H{}
 	}
 
-	c.JSON(http.StatusOK, standards)
+	return standards, nil
 }
 
 func ExtractStandardFromDoc(c *gin.Context) {
@@ -213,11 +272,14 @@ func ExtractStandardFromDoc(c *gin.Context) {
 		return
 	}
 
-	tempPath := filepath.Join("./uploads", "temp_template_"+file.Filename)
+	// The file is only needed while parsing; a unique name keeps concurrent
+	// extractions of equally named files apart.
+	tempPath := filepath.Join("./uploads", fmt.Sprintf("%s%d_%s", tempTemplatePrefix, time.Now().UnixNano(), filepath.Base(file.Filename)))
 	if err := c.SaveUploadedFile(file, tempPath); err != nil {
 		c.JSON(500, gin.H{"error": "Failed to save file"})
 		return
 	}
+	defer os.Remove(tempPath)
 
 	parser := checker.NewDocParser()
 	doc, err := parser.Parse(tempPath)
@@ -235,7 +297,11 @@ func ExtractStandardFromDoc(c *gin.Context) {
 }
 
 func DeleteStandard(c *gin.Context) {
-	id := c.Param("id")
+	id, err := database.ResolveID("formatting_standards", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+		return
+	}
 
 	// Get user ID and role for permission check
 	userID := c.GetUint("user_id")
@@ -244,7 +310,7 @@ func DeleteStandard(c *gin.Context) {
 
 	// Check standard existence and creator
 	var creatorID uint
-	err := database.DB.QueryRow("SELECT created_by FROM formatting_standards WHERE id = ?", id).Scan(&creatorID)
+	err = database.DB.QueryRow("SELECT created_by FROM formatting_standards WHERE id = ?", id).Scan(&creatorID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
@@ -265,6 +331,8 @@ func DeleteStandard(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete standard"})
 		return
 	}
+	_, _ = database.DB.Exec("DELETE FROM standard_access WHERE standard_id = ?", id)
+	invalidateResponses(cacheStandards)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Standard deleted successfully"})
 }
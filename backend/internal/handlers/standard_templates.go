@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/standards"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStandardTemplates lists the built-in standard templates with their
+// full configuration, so the editor can preview one before copying it.
+func GetStandardTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, standards.Templates())
+}
+
+// GetRuleModules lists the rule modules of this server in the order checks
+// run them, built-in ones first. Standards configure them under "modules".
+func GetRuleModules(c *gin.Context) {
+	c.JSON(http.StatusOK, checker.Modules())
+}
+
+type fromTemplateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// CreateStandardFromTemplate creates a standard from the template of the :key
+// parameter, owned by the teacher and private until they publish it. The
+// name and description may be replaced, e.g. with the department's own.
+func CreateStandardFromTemplate(c *gin.Context) {
+	t, ok := standards.Lookup(c.Param("key"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+	var req fromTemplateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = t.Name
+	}
+	description := strings.TrimSpace(req.Description)
+	if description == "" {
+		description = t.Description
+	}
+
+	userID := c.GetUint("user_id")
+	modules, _ := json.Marshal(t.Modules)
+	now := database.Timestamp(time.Now())
+	res, err := database.DB.Exec(`INSERT INTO formatting_standards (name, description, created_by, document_type, is_public, modules_json, created_at, updated_at)
+		VALUES (?, ?, ?, ?, FALSE, ?, ?, ?)`,
+		name, description, userID, t.DocumentType, string(modules), now, now)
+	if err != nil {
+		fmt.Printf("CreateStandardFromTemplate: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create standard"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	recordStandardVersion(id, userID)
+	invalidateResponses(cacheStandards)
+	c.JSON(http.StatusCreated, gin.H{"id": id, "uuid": database.UUIDOf("formatting_standards", id), "name": name, "template": t.Key, "message": "Standard created"})
+}
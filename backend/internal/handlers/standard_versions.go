@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sqlRunner is satisfied by both *sql.DB and *sql.Tx.
+type sqlRunner interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// snapshotStandard returns the id of the latest version of a standard,
+// adding a version first when the standard differs from it, e.g. after an
+// edit, a catalog import or a sync. userID is the author of the change, 0
+// for the author of the standard. A rollback always adds a version, noting
+// the version it restored.
+func snapshotStandard(db sqlRunner, standardID int64, userID uint, restoredFrom *int) (int64, error) {
+	var name string
+	var description, documentType, modulesJSON sql.NullString
+	var author uint
+	err := db.QueryRow("SELECT name, description, document_type, modules_json, COALESCE(created_by, 0) FROM formatting_standards WHERE id = ?", standardID).
+		Scan(&name, &description, &documentType, &modulesJSON, &author)
+	if err != nil {
+		return 0, err
+	}
+
+	var latestID int64
+	var latest int
+	var latestName string
+	var latestDescription, latestType, latestModules sql.NullString
+	err = db.QueryRow("SELECT id, version, name, description, document_type, modules_json FROM standard_versions WHERE standard_id = ? ORDER BY version DESC LIMIT 1", standardID).
+		Scan(&latestID, &latest, &latestName, &latestDescription, &latestType, &latestModules)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return 0, err
+	case restoredFrom == nil && latestName == name && latestDescription.String == description.String &&
+		latestType.String == documentType.String && latestModules.String == modulesJSON.String:
+		return latestID, nil
+	}
+
+	if userID == 0 {
+		userID = author
+	}
+	res, err := db.Exec(`INSERT INTO standard_versions (standard_id, version, name, description, document_type, modules_json, created_by, restored_from, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		standardID, latest+1, name, description.String, documentType.String, modulesJSON.String, userID, restoredFrom, database.Timestamp(time.Now()))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// recordStandardVersion snapshots a standard after it was created or
+// changed. A failure is logged: the next check takes the snapshot instead.
+func recordStandardVersion(standardID int64, userID uint) {
+	if _, err := snapshotStandard(database.DB, standardID, userID, nil); err != nil {
+		fmt.Printf("Standards: failed to record a version of standard %d: %v\n", standardID, err)
+	}
+}
+
+// resultStandardVersion returns the number of the standard version a check
+// result was checked against, or nil for checks made before versioning.
+func resultStandardVersion(resultID uint) *int {
+	var version int
+	err := database.DB.QueryRow(`
+		SELECT v.version FROM check_results cr
+		JOIN standard_versions v ON cr.standard_version_id = v.id
+		WHERE cr.id = ?
+	`, resultID).Scan(&version)
+	if err != nil {
+		return nil
+	}
+	return &version
+}
+
+// ownStandard resolves the standard of the :id parameter if the current user
+// may see its history: its author or an admin.
+func ownStandard(c *gin.Context) (int64, uint, bool) {
+	id, err := database.ResolveID("formatting_standards", c.Param("id"))
+	var author uint
+	if err == nil {
+		err = database.DB.QueryRow("SELECT COALESCE(created_by, 0) FROM formatting_standards WHERE id = ?", id).Scan(&author)
+	}
+	if err != nil || (author != c.GetUint("user_id") && c.GetString("role") != "admin") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard not found"})
+		return 0, 0, false
+	}
+	return id, author, true
+}
+
+// GetStandardVersions lists the versions of a standard, newest first, with
+// the number of checks made against each.
+func GetStandardVersions(c *gin.Context) {
+	id, _, ok := ownStandard(c)
+	if !ok {
+		return
+	}
+	// Standards changed outside the API since their last check get their
+	// current state recorded first.
+	recordStandardVersion(id, 0)
+
+	rows, err := database.DB.Query(`
+		SELECT v.id, v.version, v.name, COALESCE(v.description, ''), COALESCE(v.document_type, ''), COALESCE(v.modules_json, ''),
+		       COALESCE(v.created_by, 0), v.restored_from, v.created_at,
+		       (SELECT COUNT(*) FROM check_results cr WHERE cr.standard_version_id = v.id)
+		FROM standard_versions v
+		WHERE v.standard_id = ?
+		ORDER BY v.version DESC
+	`, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch standard versions"})
+		return
+	}
+	defer rows.Close()
+
+	versions := []models.StandardVersion{}
+	for rows.Next() {
+		var v models.StandardVersion
+		var modulesJSON string
+		var restoredFrom sql.NullInt64
+		if err := rows.Scan(&v.ID, &v.Version, &v.Name, &v.Description, &v.DocumentType, &modulesJSON,
+			&v.CreatedBy, &restoredFrom, &v.CreatedAt, &v.Checks); err != nil {
+			continue
+		}
+		v.StandardID = uint(id)
+		v.Modules = []models.ValidationModule{}
+		json.Unmarshal([]byte(modulesJSON), &v.Modules)
+		if restoredFrom.Valid {
+			from := int(restoredFrom.Int64)
+			v.RestoredFrom = &from
+		}
+		versions = append(versions, v)
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// RollbackStandard makes an earlier version the current state of a standard.
+// The history is kept: the restored state becomes a new version. Like
+// editing, only the author may do this.
+func RollbackStandard(c *gin.Context) {
+	id, author, ok := ownStandard(c)
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+	if author != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own standards"})
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard version not found"})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back standard"})
+		return
+	}
+	defer tx.Rollback()
+	// Edits since the last snapshot are kept as a version of their own.
+	if _, err := snapshotStandard(tx, id, 0, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back standard"})
+		return
+	}
+	res, err := tx.Exec(`
+		UPDATE formatting_standards SET
+			name = v.name, description = v.description, document_type = v.document_type, modules_json = v.modules_json,
+			updated_at = ?
+		FROM (SELECT name, description, document_type, modules_json FROM standard_versions WHERE standard_id = ? AND version = ?) AS v
+		WHERE formatting_standards.id = ?
+	`, database.Timestamp(time.Now()), id, version, id)
+	if err != nil {
+		fmt.Printf("RollbackStandard: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back standard"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Standard version not found"})
+		return
+	}
+	versionID, err := snapshotStandard(tx, id, userID, &version)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		fmt.Printf("RollbackStandard: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back standard"})
+		return
+	}
+	invalidateResponses(cacheStandards)
+
+	var current int
+	database.DB.QueryRow("SELECT version FROM standard_versions WHERE id = ?", versionID).Scan(&current)
+	c.JSON(http.StatusOK, gin.H{"message": "Standard rolled back", "version": current, "restored_from": version})
+}
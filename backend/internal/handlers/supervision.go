@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSupervisionGrants bounds the groups and students of one supervisor.
+const maxSupervisionGrants = 500
+
+// supervisedCondition selects the documents d of the students a supervisor
+// was granted, directly or through their group; it takes the supervisor's id
+// twice.
+const supervisedCondition = `d.user_id IN (SELECT student_id FROM supervision WHERE supervisor_id = ?
+	UNION SELECT u2.id FROM users u2 JOIN supervision sv ON sv.group_id = u2.group_id WHERE sv.supervisor_id = ?)`
+
+// supervises reports whether the student was granted to the supervisor,
+// directly or through their group.
+func supervises(supervisorID, studentID uint) bool {
+	var n int
+	database.DB.QueryRow(`SELECT COUNT(*) FROM (SELECT ? AS user_id) d WHERE `+supervisedCondition,
+		studentID, supervisorID, supervisorID).Scan(&n)
+	return n > 0
+}
+
+// loadSupervision lists the grants of a supervisor, groups first.
+func loadSupervision(supervisorID int64) ([]models.Supervision, error) {
+	rows, err := database.DB.Query(`
+		SELECT sv.id, sv.supervisor_id, sv.group_id, COALESCE(g.group_name, ''), sv.student_id, COALESCE(NULLIF(u.full_name, ''), u.email, ''),
+		       COALESCE(sv.granted_by, 0), sv.created_at
+		FROM supervision sv
+		LEFT JOIN student_groups g ON g.id = sv.group_id
+		LEFT JOIN users u ON u.id = sv.student_id
+		WHERE sv.supervisor_id = ?
+		ORDER BY sv.group_id IS NULL, g.group_name, u.full_name, sv.id
+	`, supervisorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []models.Supervision{}
+	for rows.Next() {
+		var s models.Supervision
+		var groupID, studentID sql.NullInt64
+		var createdAt sql.NullTime
+		if err := rows.Scan(&s.ID, &s.SupervisorID, &groupID, &s.GroupName, &studentID, &s.StudentName, &s.GrantedBy, &createdAt); err != nil {
+			return nil, err
+		}
+		if groupID.Valid {
+			id := uint(groupID.Int64)
+			s.GroupID = &id
+		}
+		if studentID.Valid {
+			id := uint(studentID.Int64)
+			s.StudentID = &id
+		}
+		s.CreatedAt = createdAt.Time
+		grants = append(grants, s)
+	}
+	return grants, rows.Err()
+}
+
+type userRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=student teacher supervisor"`
+}
+
+// SetUserRole changes the role of a user. Registration only offers the
+// student and teacher roles, so supervisors are appointed here. Supervision
+// grants and departments the new role has no use for are dropped; the role
+// applies from the user's next login.
+func SetUserRole(c *gin.Context) {
+	id, err := database.ResolveID("users", c.Param("id"))
+	var role string
+	if err == nil {
+		err = database.DB.QueryRow("SELECT role FROM users WHERE id = ?", id).Scan(&role)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	var req userRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if role == "admin" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The role of an admin cannot be changed"})
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("UPDATE users SET role = ? WHERE id = ?", req.Role, id)
+	if err == nil && req.Role != "supervisor" {
+		_, err = tx.Exec("DELETE FROM supervision WHERE supervisor_id = ?", id)
+	}
+	if err == nil && req.Role != "student" {
+		_, err = tx.Exec("DELETE FROM supervision WHERE student_id = ?", id)
+	}
+	if err == nil && req.Role != "teacher" {
+		_, err = tx.Exec("UPDATE users SET department = NULL, coordinator = FALSE WHERE id = ?", id)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		fmt.Printf("SetUserRole: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "role": req.Role})
+}
+
+// supervisorParam resolves the :id parameter to a supervisor, writing the
+// error response otherwise.
+func supervisorParam(c *gin.Context) (int64, bool) {
+	id, err := database.ResolveID("users", c.Param("id"))
+	var role string
+	if err == nil {
+		err = database.DB.QueryRow("SELECT role FROM users WHERE id = ?", id).Scan(&role)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return 0, false
+	}
+	if role != "supervisor" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User is not a supervisor"})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetSupervision lists the groups and students a supervisor may follow.
+func GetSupervision(c *gin.Context) {
+	id, ok := supervisorParam(c)
+	if !ok {
+		return
+	}
+	grants, err := loadSupervision(id)
+	if err != nil {
+		fmt.Printf("GetSupervision: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch supervision"})
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+type supervisionRequest struct {
+	GroupIDs   []uint `json:"group_ids"`
+	StudentIDs []uint `json:"student_ids"`
+}
+
+// SetSupervision replaces the groups and students a supervisor may follow.
+// The supervisor reads the checks of these students and comments on them,
+// and sees nothing else of the teachers. An empty request revokes access.
+func SetSupervision(c *gin.Context) {
+	id, ok := supervisorParam(c)
+	if !ok {
+		return
+	}
+	var req supervisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	groups, students := uniqueIDs(req.GroupIDs), uniqueIDs(req.StudentIDs)
+	if len(groups)+len(students) > maxSupervisionGrants {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d grants per supervisor", maxSupervisionGrants)})
+		return
+	}
+	for _, g := range groups {
+		var exists int
+		if database.DB.QueryRow("SELECT COUNT(*) FROM student_groups WHERE id = ?", g).Scan(&exists); exists == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Group %d not found", g)})
+			return
+		}
+	}
+	for _, s := range students {
+		var exists int
+		if database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE id = ? AND role = 'student'", s).Scan(&exists); exists == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Student %d not found", s)})
+			return
+		}
+	}
+
+	adminID := c.GetUint("user_id")
+	now := database.Timestamp(time.Now())
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supervision"})
+		return
+	}
+	_, err = tx.Exec("DELETE FROM supervision WHERE supervisor_id = ?", id)
+	for _, g := range groups {
+		if err != nil {
+			break
+		}
+		_, err = tx.Exec("INSERT INTO supervision (supervisor_id, group_id, granted_by, created_at) VALUES (?, ?, ?, ?)", id, g, adminID, now)
+	}
+	for _, s := range students {
+		if err != nil {
+			break
+		}
+		_, err = tx.Exec("INSERT INTO supervision (supervisor_id, student_id, granted_by, created_at) VALUES (?, ?, ?, ?)", id, s, adminID, now)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		fmt.Printf("SetSupervision: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supervision"})
+		return
+	}
+
+	grants, err := loadSupervision(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch supervision"})
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// SupervisedStudent is a student a supervisor follows, with their latest
+// check.
+type SupervisedStudent struct {
+	ID            uint    `json:"id"`
+	UUID          string  `json:"uuid"`
+	FullName      string  `json:"full_name"`
+	Email         string  `json:"email"`
+	GroupName     string  `json:"group_name,omitempty"`
+	Checks        int     `json:"checks"`
+	LastCheckDate string  `json:"last_check_date,omitempty"`
+	LastScore     float64 `json:"last_score,omitempty"`
+}
+
+// GetSupervisedStudents lists the students of the current supervisor.
+func GetSupervisedStudents(c *gin.Context) {
+	supervisorID := c.GetUint("user_id")
+	rows, err := database.DB.Query(`
+		SELECT d.user_id, COALESCE(u.uuid, ''), COALESCE(u.full_name, ''), u.email, COALESCE(g.group_name, ''),
+		       (SELECT COUNT(*) FROM check_results cr JOIN documents dd ON dd.id = cr.document_id WHERE dd.user_id = d.user_id)
+		FROM (SELECT id AS user_id FROM users WHERE role = 'student') d
+		JOIN users u ON u.id = d.user_id
+		LEFT JOIN student_groups g ON g.id = u.group_id
+		WHERE `+supervisedCondition+`
+		ORDER BY g.group_name, u.full_name, u.id
+	`, supervisorID, supervisorID)
+	if err != nil {
+		fmt.Printf("GetSupervisedStudents: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch students"})
+		return
+	}
+	defer rows.Close()
+
+	students := []SupervisedStudent{}
+	for rows.Next() {
+		var s SupervisedStudent
+		if err := rows.Scan(&s.ID, &s.UUID, &s.FullName, &s.Email, &s.GroupName, &s.Checks); err != nil {
+			continue
+		}
+		students = append(students, s)
+	}
+	rows.Close()
+
+	// The latest check of each student
+	for i := range students {
+		var checkDate time.Time
+		err := database.DB.QueryRow(`
+			SELECT cr.check_date, cr.overall_score
+			FROM check_results cr
+			JOIN documents d ON d.id = cr.document_id
+			WHERE d.user_id = ?
+			ORDER BY cr.check_date DESC, cr.id DESC LIMIT 1`, students[i].ID).Scan(&checkDate, &students[i].LastScore)
+		if err == nil {
+			students[i].LastCheckDate = database.FormatTimestamp(checkDate)
+		}
+	}
+	c.JSON(http.StatusOK, students)
+}
+
+// GetSupervisorHistory lists the checks of the current supervisor's
+// students, with the filters and paging of the teacher history.
+func GetSupervisorHistory(c *gin.Context) {
+	defer database.ObserveQuery("supervisor_history", time.Now())
+	supervisorID := c.GetUint("user_id")
+	respondCheckHistory(c, "GetSupervisorHistory", supervisedCondition, supervisorID, supervisorID)
+}
+
+// GetSupervisorHistoryDetail returns a check of one of the current
+// supervisor's students with its violations and comments.
+func GetSupervisorHistoryDetail(c *gin.Context) {
+	supervisorID := c.GetUint("user_id")
+	respondCheckDetail(c, supervisedCondition, supervisorID, supervisorID)
+}
@@ -0,0 +1,557 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// syncBundleVersion is the format version of export bundles.
+const syncBundleVersion = 2
+
+// syncEnvelope is the exported file: the bundle and its HMAC-SHA256 signature
+// made with SYNC_SECRET, which all instances exchanging data share.
+type syncEnvelope struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Signature string          `json:"signature"`
+}
+
+// syncBundle carries the users, standards, documents and check results of one
+// instance (e.g. a desktop installation) to another. Every record is identified
+// by its uuid. Uploaded files are not included.
+type syncBundle struct {
+	Version    int            `json:"version"`
+	Instance   string         `json:"instance"`
+	ExportedAt string         `json:"exported_at"`
+	Users      []syncUser     `json:"users"`
+	Standards  []syncStandard `json:"standards"`
+	Documents  []syncDocument `json:"documents"`
+	Results    []syncResult   `json:"results"`
+}
+
+type syncUser struct {
+	UUID     string `json:"uuid"`
+	Email    string `json:"email"`
+	FullName string `json:"full_name"`
+	Role     string `json:"role"`
+}
+
+type syncStandard struct {
+	UUID         string `json:"uuid"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	AuthorUUID   string `json:"author_uuid"`
+	DocumentType string `json:"document_type"`
+	IsPublic     bool   `json:"is_public"`
+	ModulesJSON  string `json:"modules_json"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type syncDocument struct {
+	UUID         string `json:"uuid"`
+	OwnerUUID    string `json:"owner_uuid"`
+	FileName     string `json:"file_name"`
+	FileSize     int64  `json:"file_size"`
+	ContentHash  string `json:"content_hash"`
+	UploadDate   string `json:"upload_date"`
+	Status       string `json:"status"`
+	StandardUUID string `json:"standard_uuid,omitempty"`
+}
+
+type syncResult struct {
+	UUID         string             `json:"uuid"`
+	DocumentUUID string             `json:"document_uuid"`
+	StandardUUID string             `json:"standard_uuid,omitempty"`
+	CheckDate    string             `json:"check_date"`
+	Score        float64            `json:"score"`
+	TotalRules   int                `json:"total_rules"`
+	FailedRules  int                `json:"failed_rules"`
+	Summary      string             `json:"summary"`
+	ContentJSON  string             `json:"content_json"`
+	Violations   []models.Violation `json:"violations"`
+}
+
+// syncConflict is a record of the bundle that was not merged because the local
+// copy differs from it. The local copy is always kept.
+type syncConflict struct {
+	Entity string `json:"entity"`
+	UUID   string `json:"uuid"`
+	Reason string `json:"reason"`
+}
+
+type syncCounts struct {
+	Users     int `json:"users"`
+	Standards int `json:"standards"`
+	Documents int `json:"documents"`
+	Results   int `json:"results"`
+}
+
+// syncImportReport describes what an import created, what was already present
+// and which records conflict with local data.
+type syncImportReport struct {
+	Created   syncCounts     `json:"created"`
+	Unchanged syncCounts     `json:"unchanged"`
+	Conflicts []syncConflict `json:"conflicts"`
+	Errors    []string       `json:"errors"`
+}
+
+func syncSecret() []byte {
+	return []byte(strings.TrimSpace(os.Getenv("SYNC_SECRET")))
+}
+
+func signSyncBundle(secret, bundle []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(bundle)
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ExportSyncBundle returns all users, standards, documents and check results of
+// this instance as a signed bundle for ImportSyncBundle on another instance.
+func ExportSyncBundle(c *gin.Context) {
+	secret := syncSecret()
+	if len(secret) == 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Sync is not configured (missing SYNC_SECRET)"})
+		return
+	}
+
+	bundle, err := buildSyncBundle()
+	if err != nil {
+		fmt.Printf("Sync export failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+		return
+	}
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="normocontrol-%s.json"`, time.Now().UTC().Format("20060102-150405")))
+	c.JSON(http.StatusOK, syncEnvelope{Bundle: data, Signature: signSyncBundle(secret, data)})
+}
+
+func buildSyncBundle() (*syncBundle, error) {
+	instance, _ := os.Hostname()
+	bundle := &syncBundle{
+		Version:    syncBundleVersion,
+		Instance:   instance,
+		ExportedAt: database.FormatTimestamp(time.Now()),
+		Users:      []syncUser{},
+		Standards:  []syncStandard{},
+		Documents:  []syncDocument{},
+		Results:    []syncResult{},
+	}
+
+	rows, err := database.DB.Query("SELECT uuid, email, full_name, role FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var u syncUser
+		var fullName sql.NullString
+		if err := rows.Scan(&u.UUID, &u.Email, &fullName, &u.Role); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		u.FullName = fullName.String
+		bundle.Users = append(bundle.Users, u)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		SELECT s.uuid, s.name, s.description, COALESCE(u.uuid, ''), s.document_type, s.is_public, s.modules_json, s.created_at
+		FROM formatting_standards s
+		LEFT JOIN users u ON u.id = s.created_by
+		ORDER BY s.id`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var s syncStandard
+		var description, documentType, modulesJSON sql.NullString
+		var isPublic sql.NullBool
+		var createdAt sql.NullTime
+		if err := rows.Scan(&s.UUID, &s.Name, &description, &s.AuthorUUID, &documentType, &isPublic, &modulesJSON, &createdAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.Description = description.String
+		s.DocumentType = documentType.String
+		s.IsPublic = isPublic.Bool
+		s.ModulesJSON = modulesJSON.String
+		s.CreatedAt = database.FormatTimestamp(createdAt.Time)
+		bundle.Standards = append(bundle.Standards, s)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		SELECT d.uuid, u.uuid, d.file_name, d.file_size, COALESCE(d.content_hash, ''), d.upload_date, COALESCE(d.status, ''), COALESCE(s.uuid, '')
+		FROM documents d
+		JOIN users u ON u.id = d.user_id
+		LEFT JOIN formatting_standards s ON s.id = d.standard_id
+		ORDER BY d.id`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var d syncDocument
+		var fileSize sql.NullInt64
+		var uploadDate sql.NullTime
+		if err := rows.Scan(&d.UUID, &d.OwnerUUID, &d.FileName, &fileSize, &d.ContentHash, &uploadDate, &d.Status, &d.StandardUUID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.FileSize = fileSize.Int64
+		d.UploadDate = database.FormatTimestamp(uploadDate.Time)
+		bundle.Documents = append(bundle.Documents, d)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(`
+		SELECT cr.id, cr.uuid, d.uuid, COALESCE(s.uuid, ''),
+		       cr.check_date, cr.overall_score, cr.total_rules, cr.failed_rules, cr.summary, cr.content_json
+		FROM check_results cr
+		JOIN documents d ON d.id = cr.document_id
+		LEFT JOIN formatting_standards s ON s.id = cr.standard_id
+		ORDER BY cr.id`)
+	if err != nil {
+		return nil, err
+	}
+	var resultIDs []uint
+	for rows.Next() {
+		var r syncResult
+		var resultID uint
+		var summary, contentJSON sql.NullString
+		var checkDate sql.NullTime
+		if err := rows.Scan(&resultID, &r.UUID, &r.DocumentUUID, &r.StandardUUID,
+			&checkDate, &r.Score, &r.TotalRules, &r.FailedRules, &summary, &contentJSON); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r.CheckDate = database.FormatTimestamp(checkDate.Time)
+		r.Summary = summary.String
+		r.ContentJSON = withoutPDFURL(contentJSON.String)
+		resultIDs = append(resultIDs, resultID)
+		bundle.Results = append(bundle.Results, r)
+	}
+	rows.Close()
+
+	for i, id := range resultIDs {
+		violations, err := loadResultViolations(id)
+		if err != nil {
+			return nil, err
+		}
+		for j := range violations {
+			violations[j].ID = 0
+			violations[j].ResultID = 0
+		}
+		bundle.Results[i].Violations = violations
+	}
+	return bundle, nil
+}
+
+// ImportSyncBundle verifies the signature of a bundle produced by
+// ExportSyncBundle and merges it by uuid. Records that already exist are left
+// alone, so importing the same bundle twice changes nothing; records whose
+// local copy differs are reported as conflicts. Imported users get no password
+// and have to be given one by an admin.
+func ImportSyncBundle(c *gin.Context) {
+	secret := syncSecret()
+	if len(secret) == 0 {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Sync is not configured (missing SYNC_SECRET)"})
+		return
+	}
+
+	var envelope syncEnvelope
+	if err := c.ShouldBindJSON(&envelope); err != nil || len(envelope.Bundle) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bundle"})
+		return
+	}
+	if !hmac.Equal([]byte(envelope.Signature), []byte(signSyncBundle(secret, envelope.Bundle))) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bundle signature is invalid"})
+		return
+	}
+
+	var bundle syncBundle
+	if err := json.Unmarshal(envelope.Bundle, &bundle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bundle: " + err.Error()})
+		return
+	}
+	if bundle.Version != syncBundleVersion {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unsupported bundle version %d", bundle.Version)})
+		return
+	}
+
+	m := &syncMerger{
+		report:    syncImportReport{Conflicts: []syncConflict{}, Errors: []string{}},
+		users:     map[string]int64{},
+		standards: map[string]int64{},
+		documents: map[string]int64{},
+	}
+	for _, u := range bundle.Users {
+		m.mergeUser(u)
+	}
+	for _, s := range bundle.Standards {
+		m.mergeStandard(s)
+	}
+	for _, d := range bundle.Documents {
+		m.mergeDocument(d)
+	}
+	for _, r := range bundle.Results {
+		m.mergeResult(r)
+	}
+	invalidateResponses(cacheStandards)
+
+	c.JSON(http.StatusOK, m.report)
+}
+
+// syncMerger maps bundle uuids to local ids while a bundle is imported.
+type syncMerger struct {
+	report    syncImportReport
+	users     map[string]int64
+	standards map[string]int64
+	documents map[string]int64
+}
+
+func (m *syncMerger) conflict(entity, uuid, reason string) {
+	m.report.Conflicts = append(m.report.Conflicts, syncConflict{Entity: entity, UUID: uuid, Reason: reason})
+}
+
+func (m *syncMerger) fail(entity, uuid string, err error) {
+	m.report.Errors = append(m.report.Errors, fmt.Sprintf("%s %s: %v", entity, uuid, err))
+}
+
+// localID resolves a bundle uuid through ids (filled during the merge) or the
+// table itself. An empty uuid resolves to NULL.
+func localID(ids map[string]int64, table, uuid string) interface{} {
+	if uuid == "" {
+		return nil
+	}
+	if id, ok := ids[uuid]; ok {
+		return id
+	}
+	var id int64
+	if err := database.DB.QueryRow(fmt.Sprintf("SELECT id FROM %s WHERE uuid = ?", table), uuid).Scan(&id); err != nil {
+		return nil
+	}
+	return id
+}
+
+func (m *syncMerger) mergeUser(u syncUser) {
+	if u.UUID == "" {
+		m.fail("user", u.Email, fmt.Errorf("missing uuid"))
+		return
+	}
+	// Unknown roles are imported as students, and compared as such on the
+	// next import.
+	if u.Role != "student" && u.Role != "teacher" && u.Role != "supervisor" && u.Role != "admin" {
+		u.Role = "student"
+	}
+
+	var id int64
+	var email, role string
+	err := database.DB.QueryRow("SELECT id, email, role FROM users WHERE uuid = ?", u.UUID).Scan(&id, &email, &role)
+	if err == nil {
+		m.users[u.UUID] = id
+		if email != u.Email || role != u.Role {
+			m.conflict("user", u.UUID, fmt.Sprintf("local account is %s (%s), bundle has %s (%s)", email, role, u.Email, u.Role))
+			return
+		}
+		m.report.Unchanged.Users++
+		return
+	} else if err != sql.ErrNoRows {
+		m.fail("user", u.UUID, err)
+		return
+	}
+
+	// A different account with the same email: the records of the bundle user
+	// are attached to it, since the email is what people log in with.
+	if err := database.DB.QueryRow("SELECT id FROM users WHERE email = ?", u.Email).Scan(&id); err == nil {
+		m.users[u.UUID] = id
+		m.conflict("user", u.UUID, fmt.Sprintf("email %s belongs to another local account; its records were attached to that account", u.Email))
+		return
+	}
+
+	// "!" is never a valid bcrypt hash, so the account cannot log in until a
+	// password is set.
+	res, err := database.DB.Exec("INSERT INTO users (uuid, email, password_hash, role, full_name, is_active) VALUES (?, ?, ?, ?, ?, ?)",
+		u.UUID, u.Email, "!", u.Role, u.FullName, true)
+	if err != nil {
+		m.fail("user", u.UUID, err)
+		return
+	}
+	id, _ = res.LastInsertId()
+	m.users[u.UUID] = id
+	m.report.Created.Users++
+}
+
+func (m *syncMerger) mergeStandard(s syncStandard) {
+	if s.UUID == "" {
+		m.fail("standard", s.Name, fmt.Errorf("missing uuid"))
+		return
+	}
+
+	var id int64
+	var name string
+	var modulesJSON sql.NullString
+	err := database.DB.QueryRow("SELECT id, name, modules_json FROM formatting_standards WHERE uuid = ?", s.UUID).Scan(&id, &name, &modulesJSON)
+	if err == nil {
+		m.standards[s.UUID] = id
+		if name != s.Name || modulesJSON.String != s.ModulesJSON {
+			m.conflict("standard", s.UUID, fmt.Sprintf("standard %q was changed on one of the instances; the local version was kept", name))
+			return
+		}
+		m.report.Unchanged.Standards++
+		return
+	} else if err != sql.ErrNoRows {
+		m.fail("standard", s.UUID, err)
+		return
+	}
+
+	res, err := database.DB.Exec(`INSERT INTO formatting_standards (uuid, name, description, created_by, document_type, is_public, modules_json, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.UUID, s.Name, s.Description, localID(m.users, "users", s.AuthorUUID), s.DocumentType, s.IsPublic, s.ModulesJSON,
+		syncTimestamp(s.CreatedAt), database.Timestamp(time.Now()))
+	if err != nil {
+		m.fail("standard", s.UUID, err)
+		return
+	}
+	id, _ = res.LastInsertId()
+	m.standards[s.UUID] = id
+	m.report.Created.Standards++
+}
+
+func (m *syncMerger) mergeDocument(d syncDocument) {
+	if d.UUID == "" {
+		m.fail("document", d.FileName, fmt.Errorf("missing uuid"))
+		return
+	}
+
+	var id int64
+	var ownerUUID, contentHash string
+	err := database.DB.QueryRow(`
+		SELECT d.id, COALESCE(u.uuid, ''), COALESCE(d.content_hash, '')
+		FROM documents d LEFT JOIN users u ON u.id = d.user_id
+		WHERE d.uuid = ?`, d.UUID).Scan(&id, &ownerUUID, &contentHash)
+	if err == nil {
+		m.documents[d.UUID] = id
+		if contentHash != d.ContentHash {
+			m.conflict("document", d.UUID, "the local document has different content")
+			return
+		}
+		m.report.Unchanged.Documents++
+		return
+	} else if err != sql.ErrNoRows {
+		m.fail("document", d.UUID, err)
+		return
+	}
+
+	ownerID := localID(m.users, "users", d.OwnerUUID)
+	if ownerID == nil {
+		m.fail("document", d.UUID, fmt.Errorf("unknown owner %s", d.OwnerUUID))
+		return
+	}
+	// The uploaded file stays on the exporting instance.
+	res, err := database.DB.Exec(`INSERT INTO documents (uuid, user_id, file_name, file_path, file_size, upload_date, status, content_hash, standard_id)
+		VALUES (?, ?, ?, '', ?, ?, ?, ?, ?)`,
+		d.UUID, ownerID, d.FileName, d.FileSize, syncTimestamp(d.UploadDate), d.Status, d.ContentHash, localID(m.standards, "formatting_standards", d.StandardUUID))
+	if err != nil {
+		m.fail("document", d.UUID, err)
+		return
+	}
+	id, _ = res.LastInsertId()
+	m.documents[d.UUID] = id
+	m.report.Created.Documents++
+}
+
+func (m *syncMerger) mergeResult(r syncResult) {
+	if r.UUID == "" {
+		m.fail("result", r.DocumentUUID, fmt.Errorf("missing uuid"))
+		return
+	}
+
+	var score float64
+	err := database.DB.QueryRow("SELECT overall_score FROM check_results WHERE uuid = ?", r.UUID).Scan(&score)
+	if err == nil {
+		if score != r.Score {
+			m.conflict("result", r.UUID, fmt.Sprintf("local score %.2f differs from %.2f in the bundle", score, r.Score))
+			return
+		}
+		m.report.Unchanged.Results++
+		return
+	} else if err != sql.ErrNoRows {
+		m.fail("result", r.UUID, err)
+		return
+	}
+
+	docID := localID(m.documents, "documents", r.DocumentUUID)
+	if docID == nil {
+		m.fail("result", r.UUID, fmt.Errorf("unknown document %s", r.DocumentUUID))
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		m.fail("result", r.UUID, err)
+		return
+	}
+	defer tx.Rollback()
+
+	checkDate := syncTimestamp(r.CheckDate)
+	res, err := tx.Exec(`INSERT INTO check_results (uuid, document_id, standard_id, check_date, overall_score, total_rules, failed_rules, content_json, summary, term_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT id FROM academic_terms WHERE starts_at <= ? AND ends_at > ? ORDER BY starts_at DESC LIMIT 1))`,
+		r.UUID, docID, localID(m.standards, "formatting_standards", r.StandardUUID), checkDate,
+		r.Score, r.TotalRules, r.FailedRules, r.ContentJSON, r.Summary, checkDate, checkDate)
+	if err != nil {
+		m.fail("result", r.UUID, err)
+		return
+	}
+	checkID, _ := res.LastInsertId()
+	if err := insertViolations(tx, checkID, r.Violations); err != nil {
+		m.fail("result", r.UUID, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		m.fail("result", r.UUID, err)
+		return
+	}
+	m.report.Created.Results++
+}
+
+// withoutPDFURL drops the link to the PDF preview, which is not part of the
+// bundle and would point at a file missing on the importing instance.
+func withoutPDFURL(contentJSON string) string {
+	var content map[string]interface{}
+	if err := json.Unmarshal([]byte(contentJSON), &content); err != nil {
+		return contentJSON
+	}
+	if _, ok := content["pdf_url"]; !ok {
+		return contentJSON
+	}
+	delete(content, "pdf_url")
+	data, err := json.Marshal(content)
+	if err != nil {
+		return contentJSON
+	}
+	return string(data)
+}
+
+// syncTimestamp converts an RFC 3339 bundle timestamp to the stored format.
+func syncTimestamp(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return database.Timestamp(time.Now())
+	}
+	return database.Timestamp(t)
+}
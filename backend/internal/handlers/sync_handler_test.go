@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// signedEnvelope returns an import request body for bundle signed with
+// secret.
+func signedEnvelope(t *testing.T, secret string, bundle interface{}) syncEnvelope {
+	t.Helper()
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return syncEnvelope{Bundle: data, Signature: signSyncBundle([]byte(secret), data)}
+}
+
+func TestImportSyncBundleSignature(t *testing.T) {
+	useTestDB(t)
+	empty := syncBundle{Version: syncBundleVersion, Instance: "desktop"}
+	valid := signedEnvelope(t, "s3cret", empty)
+	tampered := valid
+	tampered.Bundle = json.RawMessage(strings.Replace(string(valid.Bundle), "desktop", "desktoq", 1))
+	bare := valid
+	bare.Signature = strings.TrimPrefix(valid.Signature, "hmac-sha256:")
+	upper := valid
+	upper.Signature = "hmac-sha256:" + strings.ToUpper(bare.Signature)
+
+	for _, tc := range []struct {
+		name   string
+		secret string
+		body   interface{}
+		status int
+	}{
+		{"valid", "s3cret", valid, http.StatusOK},
+		{"secret with spaces", " s3cret\n", valid, http.StatusOK},
+		{"not configured", "", valid, http.StatusServiceUnavailable},
+		{"other secret", "other", valid, http.StatusForbidden},
+		{"tampered bundle", "s3cret", tampered, http.StatusForbidden},
+		{"no scheme", "s3cret", bare, http.StatusForbidden},
+		{"upper-case digest", "s3cret", upper, http.StatusForbidden},
+		{"no signature", "s3cret", syncEnvelope{Bundle: valid.Bundle}, http.StatusForbidden},
+		// The signature covers the bytes of the bundle, not its meaning.
+		{"reformatted bundle", "s3cret", `{"bundle": {"instance": "desktop", "version": 2}, "signature": "` + valid.Signature + `"}`, http.StatusForbidden},
+		{"no bundle", "s3cret", `{"signature": "` + valid.Signature + `"}`, http.StatusBadRequest},
+		{"not json", "s3cret", "bundle", http.StatusBadRequest},
+		{"bundle not an object", "s3cret", signedEnvelope(t, "s3cret", []int{1}), http.StatusBadRequest},
+		{"old version", "s3cret", signedEnvelope(t, "s3cret", syncBundle{Version: 1}), http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("SYNC_SECRET", tc.secret)
+			w := serve(t, ImportSyncBundle, http.MethodPost, "/api/admin/sync/import", tc.body, "admin", 1)
+			if w.Code != tc.status {
+				t.Fatalf("status = %d %s, want %d", w.Code, w.Body.String(), tc.status)
+			}
+		})
+	}
+}
+
+func TestImportSyncBundleMerge(t *testing.T) {
+	useTestDB(t)
+	t.Setenv("SYNC_SECRET", "s3cret")
+	mustExec(t,
+		`INSERT INTO users (id, uuid, email, password_hash, role, full_name) VALUES
+			(100, 'u-same', 'same@uni.ru', 'x', 'student', 'Иванов И. И.'),
+			(101, 'u-role', 'role@uni.ru', 'x', 'teacher', 'Петров П. П.'),
+			(102, 'u-local', 'taken@uni.ru', 'x', 'student', 'Сидоров С. С.')`,
+		`INSERT INTO formatting_standards (id, uuid, name, created_by, is_public, document_type, modules_json) VALUES
+			(100, 's-same', 'ГОСТ', 100, 1, 'report', '[]'),
+			(101, 's-edited', 'Курсовая (кафедра)', 100, 1, 'report', '[{"id":"m1"}]')`,
+		`INSERT INTO documents (id, uuid, user_id, file_name, file_path, file_size, status, content_hash) VALUES
+			(100, 'd-same', 100, 'a.docx', '', 1, 'done', 'h1'),
+			(101, 'd-edited', 100, 'b.docx', '', 1, 'done', 'local')`,
+		`INSERT INTO check_results (id, uuid, document_id, standard_id, overall_score) VALUES
+			(100, 'r-same', 100, 100, 80),
+			(101, 'r-edited', 101, 100, 75)`,
+	)
+
+	bundle := syncBundle{
+		Version: syncBundleVersion,
+		Users: []syncUser{
+			{UUID: "u-same", Email: "same@uni.ru", Role: "student"},
+			{UUID: "u-role", Email: "role@uni.ru", Role: "student"},
+			{UUID: "u-new", Email: "new@uni.ru", FullName: "Новиков Н. Н.", Role: "superuser"},
+			{UUID: "u-remote", Email: "taken@uni.ru", Role: "student"},
+			{Email: "nouuid@uni.ru", Role: "student"},
+		},
+		Standards: []syncStandard{
+			{UUID: "s-same", Name: "ГОСТ", ModulesJSON: "[]"},
+			{UUID: "s-edited", Name: "Курсовая", ModulesJSON: `[{"id":"m1"}]`},
+			{UUID: "s-new", Name: "Диплом", AuthorUUID: "u-new", ModulesJSON: "[]", CreatedAt: "2026-03-01T10:00:00Z"},
+		},
+		Documents: []syncDocument{
+			{UUID: "d-same", OwnerUUID: "u-same", ContentHash: "h1"},
+			{UUID: "d-edited", OwnerUUID: "u-same", ContentHash: "remote"},
+			{UUID: "d-new", OwnerUUID: "u-remote", FileName: "c.docx", ContentHash: "h3", Status: "done", StandardUUID: "s-new"},
+			{UUID: "d-orphan", OwnerUUID: "u-missing", ContentHash: "h4"},
+		},
+		Results: []syncResult{
+			{UUID: "r-same", DocumentUUID: "d-same", Score: 80},
+			{UUID: "r-edited", DocumentUUID: "d-edited", Score: 90},
+			{UUID: "r-new", DocumentUUID: "d-new", StandardUUID: "s-new", Score: 60, CheckDate: "2026-03-02T10:00:00Z",
+				Violations: []models.Violation{{ID: 7, RuleType: "margins", Description: "Поле слева", Severity: models.SeverityError}, {RuleType: "font"}}},
+			{UUID: "r-orphan", DocumentUUID: "d-orphan", Score: 50},
+		},
+	}
+	envelope := signedEnvelope(t, "s3cret", bundle)
+
+	var report syncImportReport
+	w := serve(t, ImportSyncBundle, http.MethodPost, "/api/admin/sync/import", envelope, "admin", 1)
+	decode(t, w, &report)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d %s", w.Code, w.Body.String())
+	}
+	if want := (syncCounts{Users: 1, Standards: 1, Documents: 1, Results: 1}); report.Created != want {
+		t.Fatalf("created = %+v, want %+v", report.Created, want)
+	}
+	if want := (syncCounts{Users: 1, Standards: 1, Documents: 1, Results: 1}); report.Unchanged != want {
+		t.Fatalf("unchanged = %+v, want %+v", report.Unchanged, want)
+	}
+	conflicts := func(r syncImportReport) []string {
+		var got []string
+		for _, c := range r.Conflicts {
+			got = append(got, c.Entity+" "+c.UUID)
+		}
+		sort.Strings(got)
+		return got
+	}
+	wantConflicts := []string{"document d-edited", "result r-edited", "standard s-edited", "user u-remote", "user u-role"}
+	if got := conflicts(report); !reflect.DeepEqual(got, wantConflicts) {
+		t.Fatalf("conflicts = %q, want %q", got, wantConflicts)
+	}
+	if len(report.Errors) != 3 {
+		t.Fatalf("errors = %q, want the record without uuid and the two orphans", report.Errors)
+	}
+
+	// Local copies win, and new records point at local ids.
+	var role, hash, name string
+	var owner, author, standard int64
+	database.DB.QueryRow("SELECT role, password_hash FROM users WHERE uuid = 'u-new'").Scan(&role, &hash)
+	if role != "student" || hash != "!" {
+		t.Fatalf("new user: role %q, password %q; want a student who cannot log in", role, hash)
+	}
+	database.DB.QueryRow("SELECT role FROM users WHERE uuid = 'u-role'").Scan(&role)
+	database.DB.QueryRow("SELECT name FROM formatting_standards WHERE uuid = 's-edited'").Scan(&name)
+	if role != "teacher" || name != "Курсовая (кафедра)" {
+		t.Fatalf("local records changed: role %q, standard %q", role, name)
+	}
+	database.DB.QueryRow("SELECT created_by FROM formatting_standards WHERE uuid = 's-new'").Scan(&author)
+	database.DB.QueryRow("SELECT user_id, standard_id FROM documents WHERE uuid = 'd-new'").Scan(&owner, &standard)
+	var newUser, newStandard int64
+	database.DB.QueryRow("SELECT id FROM users WHERE uuid = 'u-new'").Scan(&newUser)
+	database.DB.QueryRow("SELECT id FROM formatting_standards WHERE uuid = 's-new'").Scan(&newStandard)
+	if author != newUser || owner != 102 || standard != newStandard {
+		t.Fatalf("d-new owner %d, standard %d, s-new author %d; want 102, %d, %d", owner, standard, author, newStandard, newUser)
+	}
+	var score float64
+	var violations int
+	database.DB.QueryRow("SELECT overall_score FROM check_results WHERE uuid = 'r-edited'").Scan(&score)
+	database.DB.QueryRow("SELECT COUNT(*) FROM violations v JOIN check_results cr ON cr.id = v.result_id WHERE cr.uuid = 'r-new'").Scan(&violations)
+	if score != 75 || violations != 2 {
+		t.Fatalf("r-edited score %v, r-new violations %d; want 75, 2", score, violations)
+	}
+
+	// Importing the same bundle again creates nothing.
+	var again syncImportReport
+	decode(t, serve(t, ImportSyncBundle, http.MethodPost, "/api/admin/sync/import", envelope, "admin", 1), &again)
+	if again.Created != (syncCounts{}) {
+		t.Fatalf("second import created %+v", again.Created)
+	}
+	if want := (syncCounts{Users: 2, Standards: 2, Documents: 2, Results: 2}); again.Unchanged != want {
+		t.Fatalf("second import unchanged = %+v, want %+v", again.Unchanged, want)
+	}
+	if got := conflicts(again); !reflect.DeepEqual(got, wantConflicts) {
+		t.Fatalf("second import conflicts = %q, want %q", got, wantConflicts)
+	}
+}
+
+func TestWithoutPDFURL(t *testing.T) {
+	for in, want := range map[string]string{
+		`{"score":90,"pdf_url":"/uploads/a.pdf"}`: `{"score":90}`,
+		`{"score":90}`: `{"score":90}`,
+		`not json`:     `not json`,
+		``:             ``,
+	} {
+		if got := withoutPDFURL(in); got != want {
+			t.Errorf("withoutPDFURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errTermArchived = errors.New("The academic term is archived")
+
+const termColumns = "id, name, starts_at, ends_at, COALESCE(archived, FALSE), created_at"
+
+func scanTerm(row interface{ Scan(...interface{}) error }) (models.AcademicTerm, error) {
+	var t models.AcademicTerm
+	err := row.Scan(&t.ID, &t.Name, &t.StartsAt, &t.EndsAt, &t.Archived, &t.CreatedAt)
+	return t, err
+}
+
+func loadTerm(id uint64) (models.AcademicTerm, error) {
+	return scanTerm(database.DB.QueryRow("SELECT "+termColumns+" FROM academic_terms WHERE id = ?", id))
+}
+
+// termAt returns the id of the term containing t, or nil when t falls
+// between terms.
+func termAt(t time.Time) *uint {
+	var id uint
+	ts := database.Timestamp(t)
+	if err := database.DB.QueryRow("SELECT id FROM academic_terms WHERE starts_at <= ? AND ends_at > ? ORDER BY starts_at DESC LIMIT 1", ts, ts).Scan(&id); err != nil {
+		return nil
+	}
+	return &id
+}
+
+// checkTerm returns the term a check made at t is counted in: the term of
+// its assignment or, without one, the term containing t.
+func checkTerm(assignmentID *uint, t time.Time) *uint {
+	if assignmentID != nil {
+		var termID sql.NullInt64
+		if err := database.DB.QueryRow("SELECT term_id FROM assignments WHERE id = ?", *assignmentID).Scan(&termID); err == nil && termID.Valid {
+			id := uint(termID.Int64)
+			return &id
+		}
+	}
+	return termAt(t)
+}
+
+// termFilter returns the SQL condition and argument of the ?term= filter on
+// the term_id column of alias, e.g. "cr.". ok is false, with the error
+// response written, when the term does not exist.
+func termFilter(c *gin.Context, alias string) (string, []interface{}, bool) {
+	ref := strings.TrimSpace(c.Query("term"))
+	if ref == "" {
+		return "", nil, true
+	}
+	id, err := strconv.ParseUint(ref, 10, 64)
+	if err == nil {
+		_, err = loadTerm(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Academic term not found"})
+		return "", nil, false
+	}
+	return " AND " + alias + "term_id = ?", []interface{}{id}, true
+}
+
+// GetTerms lists the academic terms, newest first.
+func GetTerms(c *gin.Context) {
+	rows, err := database.DB.Query("SELECT " + termColumns + " FROM academic_terms ORDER BY starts_at DESC, id DESC")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch academic terms"})
+		return
+	}
+	defer rows.Close()
+
+	terms := []models.AcademicTerm{}
+	for rows.Next() {
+		t, err := scanTerm(rows)
+		if err != nil {
+			continue
+		}
+		terms = append(terms, t)
+	}
+	c.JSON(http.StatusOK, terms)
+}
+
+type termRequest struct {
+	Name     string    `json:"name" binding:"required"`
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+	Archived bool      `json:"archived"`
+}
+
+// bindTerm reads and validates a term from the request body, writing the error
+// response when it is invalid. Terms may not overlap: a date belongs to at
+// most one term. excludeID is the term being updated.
+func bindTerm(c *gin.Context, excludeID uint) (models.AcademicTerm, bool) {
+	var req termRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.AcademicTerm{}, false
+	}
+	t := models.AcademicTerm{
+		Name:     strings.TrimSpace(req.Name),
+		StartsAt: req.StartsAt.UTC(),
+		EndsAt:   req.EndsAt.UTC(),
+		Archived: req.Archived,
+	}
+	if t.Name == "" || utf8.RuneCountInString(t.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required (up to 100 characters)"})
+		return t, false
+	}
+	if !t.StartsAt.Before(t.EndsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "starts_at must be before ends_at"})
+		return t, false
+	}
+
+	var clash models.AcademicTerm
+	err := database.DB.QueryRow("SELECT id, name FROM academic_terms WHERE id != ? AND (name = ? OR (starts_at < ? AND ends_at > ?)) LIMIT 1",
+		excludeID, t.Name, database.Timestamp(t.EndsAt), database.Timestamp(t.StartsAt)).Scan(&clash.ID, &clash.Name)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("The term clashes with %q: names must be unique and dates must not overlap", clash.Name)})
+		return t, false
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check academic terms"})
+		return t, false
+	}
+	return t, true
+}
+
+// assignTermDates files the assignments (by deadline) and check results (by
+// check date) without a term under the term of their dates.
+func assignTermDates(t models.AcademicTerm) {
+	start, end := database.Timestamp(t.StartsAt), database.Timestamp(t.EndsAt)
+	if _, err := database.DB.Exec("UPDATE assignments SET term_id = ? WHERE term_id IS NULL AND deadline >= ? AND deadline < ?", t.ID, start, end); err != nil {
+		fmt.Printf("assignTermDates: failed to update assignments: %v\n", err)
+	}
+	if _, err := database.DB.Exec("UPDATE check_results SET term_id = ? WHERE term_id IS NULL AND check_date >= ? AND check_date < ?", t.ID, start, end); err != nil {
+		fmt.Printf("assignTermDates: failed to update check results: %v\n", err)
+	}
+}
+
+// CreateTerm adds a term. Existing assignments and checks dated within it and
+// not yet filed under a term are moved into it.
+func CreateTerm(c *gin.Context) {
+	t, ok := bindTerm(c, 0)
+	if !ok {
+		return
+	}
+	res, err := database.DB.Exec("INSERT INTO academic_terms (name, starts_at, ends_at, archived, created_at) VALUES (?, ?, ?, ?, ?)",
+		t.Name, database.Timestamp(t.StartsAt), database.Timestamp(t.EndsAt), t.Archived, database.Timestamp(time.Now()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create academic term"})
+		return
+	}
+	id, _ := res.LastInsertId()
+	created, _ := loadTerm(uint64(id))
+	assignTermDates(created)
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateTerm changes a term. Assignments and checks already filed under it
+// stay there when its dates change; unfiled ones within the new dates join it.
+func UpdateTerm(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	var existing models.AcademicTerm
+	if err == nil {
+		existing, err = loadTerm(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Academic term not found"})
+		return
+	}
+	t, ok := bindTerm(c, existing.ID)
+	if !ok {
+		return
+	}
+	if _, err := database.DB.Exec("UPDATE academic_terms SET name = ?, starts_at = ?, ends_at = ?, archived = ? WHERE id = ?",
+		t.Name, database.Timestamp(t.StartsAt), database.Timestamp(t.EndsAt), t.Archived, existing.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update academic term"})
+		return
+	}
+	updated, _ := loadTerm(id)
+	assignTermDates(updated)
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteTerm removes a term. Its assignments and checks are kept without a term.
+func DeleteTerm(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err == nil {
+		_, err = loadTerm(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Academic term not found"})
+		return
+	}
+	tx, err := database.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete academic term"})
+		return
+	}
+	defer tx.Rollback()
+	for _, query := range []string{
+		"UPDATE assignments SET term_id = NULL WHERE term_id = ?",
+		"UPDATE check_results SET term_id = NULL WHERE term_id = ?",
+		"DELETE FROM academic_terms WHERE id = ?",
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete academic term"})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete academic term"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Academic term deleted"})
+}
+
+// TermStats are the check statistics of one term.
+type TermStats struct {
+	TermID       *uint   `json:"term_id"` // nil: checks outside any term
+	Name         string  `json:"name"`
+	StartsAt     string  `json:"starts_at,omitempty"`
+	Archived     bool    `json:"archived"`
+	Assignments  int     `json:"assignments"`
+	Students     int     `json:"students"`
+	Checks       int     `json:"checks"`
+	PassRate     float64 `json:"pass_rate"`
+	AverageScore float64 `json:"average_score"`
+}
+
+// GetTermStats compares the terms: checks, distinct students, pass rate
+// (score from 50) and average score of each, oldest first, followed by the
+// checks outside any term when there are some.
+func GetTermStats(c *gin.Context) {
+	defer database.ObserveQuery("term_stats", time.Now())
+	rows, err := database.DB.Query(`
+		SELECT t.id, t.name, CAST(t.starts_at AS TEXT), COALESCE(t.archived, FALSE),
+		       (SELECT COUNT(*) FROM assignments a WHERE a.term_id = t.id),
+		       COUNT(DISTINCT d.user_id), COUNT(cr.id),
+		       COALESCE(SUM(cr.overall_score >= 50), 0), COALESCE(AVG(cr.overall_score), 0)
+		FROM academic_terms t
+		LEFT JOIN check_results cr ON cr.term_id = t.id
+		LEFT JOIN documents d ON cr.document_id = d.id
+		GROUP BY t.id
+		UNION ALL
+		SELECT NULL, '', NULL, FALSE,
+		       (SELECT COUNT(*) FROM assignments WHERE term_id IS NULL),
+		       COUNT(DISTINCT d.user_id), COUNT(cr.id),
+		       COALESCE(SUM(cr.overall_score >= 50), 0), COALESCE(AVG(cr.overall_score), 0)
+		FROM check_results cr
+		LEFT JOIN documents d ON cr.document_id = d.id
+		WHERE cr.term_id IS NULL
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch term statistics"})
+		return
+	}
+	defer rows.Close()
+
+	stats := []TermStats{}
+	var outside *TermStats
+	for rows.Next() {
+		var s TermStats
+		var id sql.NullInt64
+		var startsAt sql.NullString
+		var passed int
+		if err := rows.Scan(&id, &s.Name, &startsAt, &s.Archived, &s.Assignments, &s.Students, &s.Checks, &passed, &s.AverageScore); err != nil {
+			continue
+		}
+		if s.Checks > 0 {
+			s.PassRate = float64(passed) / float64(s.Checks) * 100
+		}
+		if ts, err := time.Parse(database.TimestampLayout, startsAt.String); err == nil {
+			s.StartsAt = database.FormatTimestamp(ts)
+		}
+		if !id.Valid {
+			if s.Checks > 0 || s.Assignments > 0 {
+				outside = &s
+			}
+			continue
+		}
+		termID := uint(id.Int64)
+		s.TermID = &termID
+		stats = append(stats, s)
+	}
+	// RFC 3339 in UTC sorts chronologically as text.
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].StartsAt < stats[j].StartsAt })
+	if outside != nil {
+		stats = append(stats, *outside)
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // the container image may not ship a zoneinfo database
+)
+
+var (
+	displayLoc     *time.Location
+	displayLocOnce sync.Once
+)
+
+// displayLocation is the time zone used to group timestamps into calendar days
+// for statistics. It is read from DISPLAY_TIMEZONE (an IANA name such as
+// "Europe/Moscow") and defaults to UTC.
+func displayLocation() *time.Location {
+	displayLocOnce.Do(func() {
+		displayLoc = time.UTC
+		name := strings.TrimSpace(os.Getenv("DISPLAY_TIMEZONE"))
+		if name == "" {
+			return
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			log.Printf("Invalid DISPLAY_TIMEZONE %q, using UTC: %v", name, err)
+			return
+		}
+		displayLoc = loc
+	})
+	return displayLoc
+}
+
+// dayBounds returns the start of the calendar day containing t in loc and the
+// start of the following day.
+func dayBounds(t time.Time, loc *time.Location) (start, end time.Time) {
+	t = t.In(loc)
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/Ullyminat/NormoControl/backend/internal/i18n"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// violationValues scans the nullable numeric and location columns of a violation.
+type violationValues struct {
+	expected sql.NullFloat64
+	actual   sql.NullFloat64
+	unit     sql.NullString
+	bound    sql.NullString
+	location sql.NullString
+}
+
+func (vv violationValues) apply(v *models.Violation) {
+	if vv.expected.Valid {
+		n := vv.expected.Float64
+		v.ExpectedNum = &n
+	}
+	if vv.actual.Valid {
+		n := vv.actual.Float64
+		v.ActualNum = &n
+	}
+	v.Unit = vv.unit.String
+	v.ExpectedBound = vv.bound.String
+	if vv.location.String != "" {
+		var loc models.Location
+		if err := json.Unmarshal([]byte(vv.location.String), &loc); err == nil {
+			v.Location = &loc
+		}
+	}
+}
+
+// locationJSON encodes a violation location for the location_json column.
+func locationJSON(loc *models.Location) interface{} {
+	if loc == nil {
+		return nil
+	}
+	data, err := json.Marshal(loc)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+// localizeViolations renders the numeric values of the violations in the
+// language requested with ?lang= (default: Russian) and attaches the learning
+// resources of their rule types. Integrity findings are left out for students.
+func localizeViolations(c *gin.Context, violations []models.Violation) []models.Violation {
+	if c.GetString("role") == "student" {
+		visible := make([]models.Violation, 0, len(violations))
+		for _, v := range violations {
+			if v.Severity != models.SeverityIntegrity {
+				visible = append(visible, v)
+			}
+		}
+		violations = visible
+	}
+	i18n.Localize(violations, i18n.Lang(c.Query("lang")))
+	attachLearningResources(violations)
+	return violations
+}
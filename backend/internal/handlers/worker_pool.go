@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"github.com/Ullyminat/NormoControl/backend/internal/reporting"
+	"github.com/Ullyminat/NormoControl/backend/internal/settings"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// checkWorkerPool bounds the number of documents processed at once. Every job
+// runs under a timeout and a panic guard; jobs that panic or time out are
+// recorded in the failed_jobs table instead of only being logged.
+type checkWorkerPool struct {
+	slots chan struct{}
+}
+
+var (
+	checkPool     *checkWorkerPool
+	checkPoolOnce sync.Once
+)
+
+// getCheckPool sizes the pool from the checks.workers setting (default:
+// number of CPUs) on first use.
+func getCheckPool() *checkWorkerPool {
+	checkPoolOnce.Do(func() {
+		workers := runtime.NumCPU()
+		if n := settings.Int("checks.workers"); n > 0 {
+			workers = n
+		}
+		checkPool = &checkWorkerPool{slots: make(chan struct{}, workers)}
+	})
+	return checkPool
+}
+
+// timeout bounds one check, waiting for a worker included: the
+// checks.timeout_seconds setting, default 120.
+func (wp *checkWorkerPool) timeout() time.Duration {
+	return settings.Seconds("checks.timeout_seconds")
+}
+
+// Run executes the pipeline for p starting at stage on a pool worker and waits
+// for its outcome.
+func (wp *checkWorkerPool) Run(ctx context.Context, p checkPipeline, stage string) pipelineOutcome {
+	timeout := wp.timeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	select {
+	case wp.slots <- struct{}{}:
+	case <-ctx.Done():
+		return wp.abandon(p, stage, fmt.Errorf("waiting for a free worker: %w", ctx.Err()))
+	}
+
+	tracker := &stageTracker{stage: stage}
+	p.tracker = tracker
+	done := make(chan pipelineOutcome, 1)
+	go func() {
+		defer func() { <-wp.slots }()
+		defer func() {
+			if r := recover(); r != nil {
+				failedStage := tracker.Get()
+				stack := debug.Stack()
+				tags := map[string]string{"document_id": strconv.FormatInt(p.DocID, 10), "stage": failedStage}
+				err := fmt.Errorf("panic: %v", r)
+				if rp, ok := r.(*checker.RulePanic); ok {
+					stack = rp.Stack
+					tags["rule"] = rp.Rule
+					err = rp
+				}
+				fmt.Printf("Pipeline: recovered panic for document %d at %s: %v\n", p.DocID, failedStage, err)
+				reporting.CapturePanic(r, stack, tags)
+				status := models.FailedStatus(failedStage)
+				setDocumentStatus(p.DocID, status, err.Error())
+				recordFailedJob(p, failedStage, err, string(stack))
+				done <- pipelineOutcome{Status: status, Err: err}
+			}
+		}()
+		done <- resumeCheckPipeline(ctx, p, stage)
+	}()
+
+	select {
+	case out := <-done:
+		// A job stopped by the timeout leaves the document to abandon.
+		if out.Status != "" {
+			return out
+		}
+	case <-ctx.Done():
+	}
+	return wp.abandon(p, tracker.Get(), fmt.Errorf("check timed out after %s: %w", timeout, ctx.Err()))
+}
+
+func (wp *checkWorkerPool) abandon(p checkPipeline, stage string, err error) pipelineOutcome {
+	status := models.FailedStatus(stage)
+	fmt.Printf("Pipeline: document %d abandoned at %s: %v\n", p.DocID, stage, err)
+	setDocumentStatus(p.DocID, status, err.Error())
+	recordFailedJob(p, stage, err, "")
+	reporting.CaptureError(err, map[string]string{"document_id": strconv.FormatInt(p.DocID, 10), "stage": stage})
+	return pipelineOutcome{Status: status, Err: err}
+}
+
+// stageTracker remembers the stage a running job is in, so a panic or timeout
+// can be attributed to it.
+type stageTracker struct {
+	mu    sync.Mutex
+	stage string
+}
+
+func (t *stageTracker) Set(stage string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.stage = stage
+	t.mu.Unlock()
+}
+
+func (t *stageTracker) Get() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stage
+}
@@ -0,0 +1,454 @@
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errorEntry is an API error of the catalog: its stable code, which clients
+// match on instead of the text, and the Russian text.
+type errorEntry struct {
+	code string
+	ru   string
+}
+
+// errorCatalog maps the English message a handler responds with to its
+// entry. %s marks a variable part, e.g. an id or the text of a wrapped error;
+// it is carried over into the translation as is.
+var errorCatalog = map[string]errorEntry{
+	// General
+	"Internal server error":             {"internal_error", "Внутренняя ошибка сервера"},
+	"Database error":                    {"database_error", "Ошибка базы данных"},
+	"Database error: %s":                {"database_error", "Ошибка базы данных: %s"},
+	"Failed to encode response":         {"encode_failed", "Не удалось сформировать ответ"},
+	"Invalid request body":              {"invalid_request_body", "Некорректное тело запроса"},
+	"Invalid ID":                        {"invalid_id", "Некорректный идентификатор"},
+	"ID required":                       {"id_required", "Требуется идентификатор"},
+	"Access denied":                     {"access_denied", "Доступ запрещён"},
+	"Permission denied":                 {"permission_denied", "Недостаточно прав"},
+	"Record not found or access denied": {"record_not_found", "Запись не найдена или доступ запрещён"},
+	"Slow down! Too many requests (Rate Limit Exceeded)": {"rate_limited", "Трошки медленнее! Слишком много запросов (Rate Limit Exceeded)"},
+
+	// Authentication and accounts
+	"Authentication required":                           {"authentication_required", "Требуется авторизация"},
+	"Unauthorized":                                      {"unauthorized", "Не авторизован"},
+	"Unauthorized (Role not found)":                     {"role_missing", "Не авторизован (роль не найдена)"},
+	"Role not found in token":                           {"role_missing", "В токене не указана роль"},
+	"Internal server error: Invalid role format":        {"invalid_role_format", "Внутренняя ошибка сервера: неверный формат роли"},
+	"Invalid token":                                     {"invalid_token", "Недействительный токен"},
+	"Invalid session link":                              {"invalid_session_link", "Недействительная ссылка для входа"},
+	"Invalid email or password":                         {"invalid_credentials", "Неверный email или пароль"},
+	"Email likely already exists":                       {"email_taken", "Пользователь с таким email, вероятно, уже существует"},
+	"Failed to hash password":                           {"password_hash_failed", "Не удалось обработать пароль"},
+	"Failed to generate token":                          {"token_failed", "Не удалось выдать токен"},
+	"User is inactive":                                  {"user_inactive", "Учётная запись отключена"},
+	"Not logged in":                                     {"not_logged_in", "Вход не выполнен"},
+	"You do not have permission to perform this action": {"forbidden", "У вас нет прав для выполнения этого действия"},
+	"Impersonation is read-only":                        {"impersonation_read_only", "Режим просмотра от имени пользователя доступен только для чтения"},
+	"Administrators cannot be impersonated":             {"impersonation_admin", "Нельзя войти от имени администратора"},
+	"Not impersonating":                                 {"not_impersonating", "Режим просмотра от имени пользователя не включён"},
+	"Admin account unavailable":                         {"admin_unavailable", "Учётная запись администратора недоступна"},
+	"Failed to fetch impersonation log":                 {"impersonation_log_failed", "Не удалось загрузить журнал входов от имени пользователей"},
+	"User not found":                                    {"user_not_found", "Пользователь не найден"},
+	"Failed to update user":                             {"user_update_failed", "Не удалось обновить пользователя"},
+	"Failed to delete user":                             {"user_delete_failed", "Не удалось удалить пользователя"},
+	"User is not a supervisor":                          {"not_supervisor", "Пользователь не является руководителем"},
+	"The role of an admin cannot be changed":            {"admin_role_locked", "Роль администратора изменить нельзя"},
+	"Failed to fetch supervision":                       {"supervision_fetch_failed", "Не удалось загрузить закреплённых студентов"},
+	"Failed to update supervision":                      {"supervision_update_failed", "Не удалось изменить закреплённых студентов"},
+	"At most %s grants per supervisor":                  {"supervision_too_many", "За руководителем можно закрепить не более %s групп и студентов"},
+	"Student %s not found":                              {"student_not_found", "Студент %s не найден"},
+	"Failed to fetch students":                          {"students_fetch_failed", "Не удалось загрузить студентов"},
+	"Only teachers belong to a department":              {"department_not_teacher", "В кафедру входят только преподаватели"},
+	"Department name is too long":                       {"department_too_long", "Слишком длинное название кафедры"},
+	"A coordinator needs a department":                  {"coordinator_department_required", "Для координатора нужно указать кафедру"},
+	"Failed to fetch departments":                       {"departments_fetch_failed", "Не удалось загрузить кафедры"},
+	"Failed to fetch activity":                          {"activity_fetch_failed", "Не удалось загрузить активность"},
+	"Failed to export data":                             {"export_failed", "Не удалось выгрузить данные"},
+
+	// Documents and checks
+	"No file uploaded":    {"no_file", "Файл не загружен"},
+	"No files uploaded":   {"no_files", "Файлы не загружены"},
+	"Failed to save file": {"file_save_failed", "Не удалось сохранить файл"},
+	"Failed to read file": {"file_read_failed", "Не удалось прочитать файл"},
+	"The file name does not follow the required scheme":                    {"file_name_mismatch", "Имя файла не соответствует требуемому образцу"},
+	"File is larger than 20 MiB":                                           {"file_too_large", "Файл больше 20 МиБ"},
+	"Only PDF files are accepted":                                          {"pdf_only", "Принимаются только файлы PDF"},
+	"Database error saving document":                                       {"document_save_failed", "Ошибка базы данных при сохранении документа"},
+	"Document not found":                                                   {"document_not_found", "Документ не найден"},
+	"Invalid standard_id format":                                           {"invalid_standard_id", "Некорректный standard_id"},
+	"Stored file is no longer available":                                   {"stored_file_missing", "Сохранённый файл больше недоступен"},
+	"Stored file is no longer available, please upload it again":           {"stored_file_missing", "Сохранённый файл больше недоступен, загрузите его снова"},
+	"The document is being checked, try again when it is done":             {"document_busy", "Документ проверяется, повторите, когда проверка завершится"},
+	"Check failed: %s":                                                     {"check_failed", "Проверка не выполнена: %s"},
+	"Re-check failed":                                                      {"recheck_failed", "Повторная проверка не выполнена"},
+	"Re-check failed: %s":                                                  {"recheck_failed", "Повторная проверка не выполнена: %s"},
+	"Retry failed: %s":                                                     {"retry_failed", "Повтор не выполнен: %s"},
+	"document too complex: %s exceeds the limit of %s (found at least %s)": {"document_too_complex", "Документ слишком сложный: %s превышает предел %s (найдено не менее %s)"},
+	"Failed to parse DOCX: %s":                                             {"docx_parse_failed", "Не удалось разобрать DOCX: %s"},
+	"Automatic correction failed: %s":                                      {"autofix_failed", "Автоисправление не выполнено: %s"},
+	"Dry run failed: %s":                                                   {"dry_run_failed", "Пробный запуск не выполнен: %s"},
+	"The assignment requires a different standard":                         {"assignment_standard_mismatch", "Задание требует другой стандарт"},
+	"Submission window is not open yet":                                    {"submission_not_open", "Приём работ ещё не открыт"},
+	"Submission deadline has passed":                                       {"deadline_passed", "Срок сдачи истёк"},
+	"Submission deadline and grace period have passed":                     {"grace_period_over", "Срок сдачи и льготный период истекли"},
+	"The standard has several modules; choose one with module_id":          {"module_ambiguous", "В стандарте несколько модулей, выберите один в module_id"},
+	"The standard has no modules":                                          {"standard_no_modules", "В стандарте нет модулей"},
+	"Module not found in the standard":                                     {"module_not_found", "Модуль не найден в стандарте"},
+	"The standard of this check no longer exists":                          {"check_standard_deleted", "Стандарт этой проверки больше не существует"},
+	"Only failed documents can be retried":                                 {"retry_not_failed", "Повторить можно только проверку, завершившуюся ошибкой"},
+	"Document of the failed job no longer exists":                          {"failed_job_document_missing", "Документ задания больше не существует"},
+	"Failed job not found":                                                 {"failed_job_not_found", "Задание не найдено"},
+	"Failed to fetch failed jobs":                                          {"failed_jobs_fetch_failed", "Не удалось загрузить задания с ошибками"},
+	"Failed to discard job":                                                {"failed_job_discard_failed", "Не удалось удалить задание"},
+
+	// History and reports
+	"History item not found":                        {"history_not_found", "Проверка не найдена"},
+	"History item not found: %s":                    {"history_not_found", "Проверка не найдена: %s"},
+	"Failed to fetch history":                       {"history_fetch_failed", "Не удалось загрузить историю"},
+	"Failed to fetch teacher history":               {"history_fetch_failed", "Не удалось загрузить историю проверок"},
+	"Result not found":                              {"result_not_found", "Результат не найден"},
+	"Failed to fetch violations":                    {"violations_fetch_failed", "Не удалось загрузить нарушения"},
+	"Violation not found":                           {"violation_not_found", "Нарушение не найдено"},
+	"Invalid violation ID":                          {"invalid_violation_id", "Некорректный идентификатор нарушения"},
+	"Invalid cursor":                                {"invalid_cursor", "Некорректный курсор"},
+	"Cursor requires limit":                         {"cursor_requires_limit", "Курсор используется только вместе с limit"},
+	"Limit must be between 1 and %s":                {"invalid_limit", "limit должен быть от 1 до %s"},
+	"Provide both from and to":                      {"compare_range_required", "Укажите и from, и to"},
+	"from and to must be different checks":          {"compare_same_check", "from и to должны быть разными проверками"},
+	"Checks are not of the same document":           {"compare_different_documents", "Проверки относятся к разным документам"},
+	"Checks belong to different students":           {"compare_different_students", "Проверки принадлежат разным студентам"},
+	"Provide between 2 and 10 distinct ids":         {"compare_ids_count", "Укажите от 2 до 10 разных идентификаторов"},
+	"Failed to compare submissions":                 {"compare_failed", "Не удалось сравнить работы"},
+	"min_similarity must be a number from 0 to 1":   {"invalid_min_similarity", "min_similarity должен быть числом от 0 до 1"},
+	"format must be json or sarif":                  {"invalid_format", "format должен быть json или sarif"},
+	"format must be json, csv or xlsx":              {"invalid_format", "format должен быть json, csv или xlsx"},
+	"Failed to update metadata":                     {"metadata_update_failed", "Не удалось обновить метаданные"},
+	"Metadata fields are limited to %s characters":  {"metadata_too_long", "Поля метаданных ограничены %s символами"},
+	"Failed to build the annotated document":        {"annotated_failed", "Не удалось построить документ с замечаниями"},
+	"Failed to convert the document to PDF":         {"pdf_conversion_failed", "Не удалось преобразовать документ в PDF"},
+	"PDF conversion is disabled on this server":     {"pdf_conversion_disabled", "Преобразование в PDF отключено на этом сервере"},
+	"PDF converter is unavailable, try again later": {"pdf_converter_unavailable", "Конвертер PDF недоступен, повторите позже"},
+	"Failed to stamp the document":                  {"stamp_failed", "Не удалось проставить штамп"},
+	"The PDF of this document cannot be stamped":    {"stamp_unsupported", "На PDF этого документа нельзя проставить штамп"},
+	"Verification code not found":                   {"verification_code_not_found", "Код проверки не найден"},
+	"Failed to build gradebook":                     {"gradebook_failed", "Не удалось построить ведомость"},
+
+	// Standards
+	"Standard not found":                   {"standard_not_found", "Стандарт не найден"},
+	"Failed to create standard":            {"standard_create_failed", "Не удалось создать стандарт"},
+	"Failed to create standard: %s":        {"standard_create_failed", "Не удалось создать стандарт: %s"},
+	"Failed to update standard":            {"standard_update_failed", "Не удалось обновить стандарт"},
+	"Failed to delete standard":            {"standard_delete_failed", "Не удалось удалить стандарт"},
+	"You can only edit your own standards": {"standard_not_owned", "Можно изменять только свои стандарты"},
+	"Failed to fetch standard versions":    {"standard_versions_fetch_failed", "Не удалось загрузить версии стандарта"},
+	"Standard version not found":           {"standard_version_not_found", "Версия стандарта не найдена"},
+	"Failed to roll back standard":         {"standard_rollback_failed", "Не удалось откатить стандарт"},
+	"Failed to import standard":            {"standard_import_failed", "Не удалось импортировать стандарт"},
+	"Template not found":                   {"template_not_found", "Шаблон не найден"},
+	"Failed to fetch standard access":      {"standard_access_fetch_failed", "Не удалось загрузить доступ к стандарту"},
+	"Failed to update standard access":     {"standard_access_update_failed", "Не удалось изменить доступ к стандарту"},
+	"At most %s grants per standard":       {"standard_access_too_many", "Стандартом можно поделиться не более чем с %s группами и пользователями"},
+	"User %s not found":                    {"user_not_found", "Пользователь %s не найден"},
+	"Unknown severity: %s":                 {"unknown_severity", "Неизвестная серьёзность: %s"},
+	"Failed to load check defaults":        {"check_defaults_failed", "Не удалось загрузить параметры проверки по умолчанию"},
+	"Failed to save check defaults":        {"check_defaults_failed", "Не удалось сохранить параметры проверки по умолчанию"},
+	"Failed to reset check defaults":       {"check_defaults_failed", "Не удалось сбросить параметры проверки по умолчанию"},
+
+	// Standard bundles, the catalog and sync
+	"Invalid bundle":                                                      {"invalid_bundle", "Некорректный файл стандарта"},
+	"Invalid bundle: %s":                                                  {"invalid_bundle", "Некорректный файл стандарта: %s"},
+	"no file uploaded":                                                    {"no_file", "Файл не загружен"},
+	"bundle is larger than %s MB":                                         {"bundle_too_large", "Файл стандарта больше %s МБ"},
+	"not a standard bundle (format must be %s)":                           {"bundle_format_invalid", "Это не файл стандарта (format должен быть %s)"},
+	"unsupported schema_version %s (this server reads up to %s)":          {"bundle_version_unsupported", "Неподдерживаемая schema_version %s (сервер читает версии до %s)"},
+	"Unsupported bundle version %s":                                       {"bundle_version_unsupported", "Неподдерживаемая версия выгрузки %s"},
+	"bundle has no name":                                                  {"bundle_no_name", "В файле стандарта нет названия"},
+	"bundle has no modules":                                               {"bundle_no_modules", "В файле стандарта нет модулей"},
+	"module %s has no id or a duplicate one":                              {"bundle_module_id", "У модуля %s нет id или он повторяется"},
+	"module %s: invalid config: %s":                                       {"bundle_module_config", "Модуль %s: некорректные настройки: %s"},
+	"custom rule %s has no name":                                          {"custom_rule_invalid", "Пользовательское правило %s без названия"},
+	"custom rule %s is defined twice":                                     {"custom_rule_invalid", "Пользовательское правило %s задано дважды"},
+	"custom rule %s has no pattern":                                       {"custom_rule_invalid", "У пользовательского правила %s нет шаблона"},
+	"custom rule %s: invalid pattern: %s":                                 {"custom_rule_invalid", "Пользовательское правило %s: ошибка в шаблоне: %s"},
+	"custom rule %s: unknown scope %s (body, headings, references, all)":  {"custom_rule_invalid", "Пользовательское правило %s: неизвестная область %s (body, headings, references, all)"},
+	"custom rule %s: unknown severity %s":                                 {"custom_rule_invalid", "Пользовательское правило %s: неизвестная серьёзность %s"},
+	"module %s is not registered":                                         {"rule_module_unknown", "Модуль правил %s не подключён к серверу"},
+	"module %s: %s":                                                       {"rule_module_invalid", "Модуль правил %s: %s"},
+	"Bundle signature is invalid":                                         {"bundle_signature_invalid", "Подпись выгрузки недействительна"},
+	"Sync is not configured (missing SYNC_SECRET)":                        {"sync_not_configured", "Синхронизация не настроена (не задан SYNC_SECRET)"},
+	"Catalog is not configured (missing CATALOG_URL)":                     {"catalog_not_configured", "Каталог не настроен (не задан CATALOG_URL)"},
+	"Catalog publishing is not configured (missing CATALOG_SIGNING_KEY)":  {"catalog_publishing_not_configured", "Публикация в каталог не настроена (не задан CATALOG_SIGNING_KEY)"},
+	"This deployment does not publish standards":                          {"catalog_not_publisher", "Этот сервер не публикует стандарты"},
+	"Failed to load catalog: %s":                                          {"catalog_load_failed", "Не удалось загрузить каталог: %s"},
+	"Failed to load published standards":                                  {"catalog_load_failed", "Не удалось загрузить опубликованные стандарты"},
+	"Standard not found in the catalog":                                   {"catalog_standard_not_found", "Стандарт не найден в каталоге"},
+	"Provide the uuid of a catalog standard or a signed entry":            {"catalog_source_required", "Укажите uuid стандарта из каталога или подписанную запись"},
+	"Entry signature is invalid: %s":                                      {"catalog_signature_invalid", "Подпись записи недействительна: %s"},
+	"The publisher's key is not trusted (add it to CATALOG_TRUSTED_KEYS)": {"catalog_publisher_untrusted", "Ключ издателя не является доверенным (добавьте его в CATALOG_TRUSTED_KEYS)"},
+	"A local standard with this uuid exists and was not imported from this publisher; it was kept": {"catalog_uuid_conflict", "Локальный стандарт с этим uuid уже есть и не был импортирован от этого издателя; он оставлен без изменений"},
+	"Failed to sign standard": {"standard_sign_failed", "Не удалось подписать стандарт"},
+
+	// Assignments, groups and terms
+	"Assignment not found":                     {"assignment_not_found", "Задание не найдено"},
+	"Failed to create assignment":              {"assignment_create_failed", "Не удалось создать задание"},
+	"Failed to update assignment":              {"assignment_update_failed", "Не удалось обновить задание"},
+	"Failed to delete assignment":              {"assignment_delete_failed", "Не удалось удалить задание"},
+	"Failed to fetch assignments":              {"assignments_fetch_failed", "Не удалось загрузить задания"},
+	"Failed to load assignments":               {"assignments_fetch_failed", "Не удалось загрузить задания"},
+	"You can only edit your own assignments":   {"assignment_not_owned", "Можно изменять только свои задания"},
+	"Title is required (up to 200 characters)": {"title_required", "Укажите название (до 200 символов)"},
+	"late_policy must be hard or grace":        {"invalid_late_policy", "late_policy должен быть hard или grace"},
+	"grace_hours must not be negative":         {"invalid_grace_hours", "grace_hours не может быть отрицательным"},
+	"opens_at must be before deadline":         {"invalid_opens_at", "opens_at должен быть раньше deadline"},
+	"Invalid file_name_pattern: %s":            {"invalid_file_name_pattern", "Некорректный file_name_pattern: %s"},
+	"Group not found":                          {"group_not_found", "Группа не найдена"},
+	"Group %s not found":                       {"group_not_found", "Группа %s не найдена"},
+	"Invalid group_id":                         {"invalid_group_id", "Некорректный group_id"},
+	"group_id is required":                     {"group_id_required", "Укажите group_id"},
+	"You are not in a group":                   {"no_group", "Вы не состоите в группе"},
+	"Academic term not found":                  {"term_not_found", "Учебный период не найден"},
+	"Source academic term not found":           {"term_not_found", "Исходный учебный период не найден"},
+	"Failed to create academic term":           {"term_create_failed", "Не удалось создать учебный период"},
+	"Failed to update academic term":           {"term_update_failed", "Не удалось обновить учебный период"},
+	"Failed to delete academic term":           {"term_delete_failed", "Не удалось удалить учебный период"},
+	"Failed to fetch academic terms":           {"terms_fetch_failed", "Не удалось загрузить учебные периоды"},
+	"Failed to check academic terms":           {"terms_fetch_failed", "Не удалось проверить учебные периоды"},
+	"Failed to fetch term statistics":          {"term_statistics_failed", "Не удалось загрузить статистику учебного периода"},
+	"department is required":                   {"department_required", "Укажите department"},
+	"Failed to fetch department statistics":    {"department_statistics_failed", "Не удалось загрузить статистику кафедры"},
+	"Failed to export department statistics":   {"department_export_failed", "Не удалось выгрузить статистику кафедры"},
+	"format must be csv or xlsx":               {"invalid_format", "format должен быть csv или xlsx"},
+	"by must be teacher or group":              {"invalid_by", "by должен быть teacher или group"},
+	"starts_at must be before ends_at":         {"invalid_term_dates", "starts_at должен быть раньше ends_at"},
+	"The academic term is archived":            {"term_archived", "Учебный период в архиве"},
+	"The term clashes with %s: names must be unique and dates must not overlap": {"term_clash", "Период пересекается с %s: названия должны быть уникальными, а даты не должны перекрываться"},
+	"Only department coordinators can view department statistics":               {"department_forbidden", "Статистика кафедры доступна только её координатору"},
+	"from_term_id must differ from the target term":                             {"rollover_same_term", "from_term_id должен отличаться от целевого периода"},
+	"Assignment %s is not in the source term":                                   {"rollover_assignment_missing", "Задание %s не относится к исходному периоду"},
+	"Assignment %s: opens_at must be before deadline":                           {"invalid_opens_at", "Задание %s: opens_at должен быть раньше deadline"},
+	"Failed to copy assignments":                                                {"rollover_failed", "Не удалось скопировать задания"},
+	"Failed to copy standards":                                                  {"rollover_failed", "Не удалось скопировать стандарты"},
+
+	// Scheduled checks
+	"Scheduled check not found":                                    {"schedule_not_found", "Запланированная проверка не найдена"},
+	"Failed to fetch scheduled checks":                             {"schedules_fetch_failed", "Не удалось загрузить запланированные проверки"},
+	"Failed to fetch scheduled documents":                          {"schedules_fetch_failed", "Не удалось загрузить документы запланированной проверки"},
+	"Failed to load the scheduled check":                           {"schedules_fetch_failed", "Не удалось загрузить запланированную проверку"},
+	"Failed to schedule the check":                                 {"schedule_failed", "Не удалось запланировать проверку"},
+	"Failed to cancel the batch check":                             {"schedule_cancel_failed", "Не удалось отменить пакетную проверку"},
+	"You can only manage your own scheduled checks":                {"schedule_not_owned", "Можно управлять только своими запланированными проверками"},
+	"The batch check is already %s":                                {"schedule_state_conflict", "Пакетная проверка уже в состоянии %s"},
+	"At most %s documents can be scheduled at once":                {"schedule_too_many", "За один раз можно запланировать не более %s документов"},
+	"window_start and window_end must be different times as HH:MM": {"invalid_schedule_window", "window_start и window_end должны быть разными значениями времени в формате ЧЧ:ММ"},
+	"Archives like %s are not supported on this server":            {"archive_unsupported", "Архивы вида %s на этом сервере не поддерживаются"},
+	"The archive %s holds too many or too large documents":         {"archive_too_large", "В архиве %s слишком много документов или они слишком большие"},
+	"Failed to unpack %s":                                          {"archive_unpack_failed", "Не удалось распаковать %s"},
+	"The uploaded archives hold no documents":                      {"archive_empty", "В загруженных архивах нет документов"},
+
+	// Comments, feedback and AI
+	"Comment not found":                                     {"comment_not_found", "Комментарий не найден"},
+	"Failed to save comments":                               {"comment_save_failed", "Не удалось сохранить комментарии"},
+	"Failed to delete comment":                              {"comment_delete_failed", "Не удалось удалить комментарий"},
+	"Comment text or template_id is required":               {"comment_text_required", "Укажите текст комментария или template_id"},
+	"You can only comment on checks against your standards": {"comment_not_allowed", "Комментировать можно только проверки по своим стандартам"},
+	"You can only comment on checks of your students":       {"comment_not_allowed", "Комментировать можно только проверки закреплённых за вами студентов"},
+	"You can only delete your own comments":                 {"comment_not_owned", "Удалять можно только свои комментарии"},
+	"result_ids must list 1 to %s results":                  {"invalid_result_ids", "result_ids должен содержать от 1 до %s результатов"},
+	"Feedback template not found":                           {"feedback_template_not_found", "Шаблон комментария не найден"},
+	"Failed to fetch feedback templates":                    {"feedback_templates_fetch_failed", "Не удалось загрузить шаблоны комментариев"},
+	"Failed to load feedback template":                      {"feedback_templates_fetch_failed", "Не удалось загрузить шаблон комментария"},
+	"Failed to save feedback template":                      {"feedback_template_save_failed", "Не удалось сохранить шаблон комментария"},
+	"Failed to update feedback template":                    {"feedback_template_save_failed", "Не удалось обновить шаблон комментария"},
+	"Failed to delete feedback template":                    {"feedback_template_delete_failed", "Не удалось удалить шаблон комментария"},
+	"You can only edit your own feedback templates":         {"feedback_template_not_owned", "Можно изменять только свои шаблоны комментариев"},
+	"Body is required (up to 4000 characters)":              {"body_required", "Укажите текст (до 4000 символов)"},
+	"Name is required (up to 100 characters)":               {"name_required", "Укажите название (до 100 символов)"},
+	"Feedback generation failed":                            {"feedback_failed", "Не удалось сформировать отзыв"},
+	"LLM feedback is not configured":                        {"feedback_not_configured", "Отзывы с помощью LLM не настроены"},
+	"AI Service is not configured (missing API Key)":        {"ai_not_configured", "Сервис ИИ не настроен (не задан ключ API)"},
+	"AI Verification failed":                                {"ai_verification_failed", "Проверка с помощью ИИ не выполнена"},
+
+	// False positive reports
+	"Failed to report false positive":               {"false_positive_failed", "Не удалось отправить жалобу на ложное срабатывание"},
+	"You have already reported this violation":      {"false_positive_duplicate", "Вы уже сообщили об этом нарушении"},
+	"comment must be at most %s characters":         {"comment_too_long", "Комментарий должен быть не длиннее %s символов"},
+	"Failed to fetch false positive reports":        {"false_positives_fetch_failed", "Не удалось загрузить жалобы на ложные срабатывания"},
+	"Report not found":                              {"report_not_found", "Жалоба не найдена"},
+	"Failed to review report":                       {"report_review_failed", "Не удалось сохранить решение по жалобе"},
+	"status must be confirmed, rejected or pending": {"invalid_report_status", "status должен быть confirmed, rejected или pending"},
+	"Failed to export fixtures":                     {"fixtures_export_failed", "Не удалось выгрузить примеры"},
+
+	// Dictionaries and learning resources
+	"Dictionary not found":                                       {"dictionary_not_found", "Словарь не найден"},
+	"Failed to fetch dictionaries":                               {"dictionaries_fetch_failed", "Не удалось загрузить словари"},
+	"Failed to load dictionary":                                  {"dictionaries_fetch_failed", "Не удалось загрузить словарь"},
+	"Failed to save dictionary":                                  {"dictionary_save_failed", "Не удалось сохранить словарь"},
+	"Failed to update dictionary":                                {"dictionary_save_failed", "Не удалось обновить словарь"},
+	"Failed to delete dictionary":                                {"dictionary_delete_failed", "Не удалось удалить словарь"},
+	"You can only edit your own dictionaries":                    {"dictionary_not_owned", "Можно изменять только свои словари"},
+	"Words are limited to 100 characters":                        {"word_too_long", "Слово должно быть не длиннее 100 символов"},
+	"A dictionary holds 1 to %s words":                           {"invalid_word_count", "В словаре должно быть от 1 до %s слов"},
+	"Category is required (%s or your own, up to 50 characters)": {"category_required", "Укажите категорию (%s или свою, до 50 символов)"},
+	"Name is required (up to 200 characters)":                    {"name_required", "Укажите название (до 200 символов)"},
+	"Name must be up to 200 characters":                          {"name_too_long", "Название должно быть не длиннее 200 символов"},
+	"Learning resource not found":                                {"resource_not_found", "Учебный материал не найден"},
+	"Failed to fetch learning resources":                         {"resources_fetch_failed", "Не удалось загрузить учебные материалы"},
+	"Failed to load learning resource":                           {"resources_fetch_failed", "Не удалось загрузить учебный материал"},
+	"Failed to save learning resource":                           {"resource_save_failed", "Не удалось сохранить учебный материал"},
+	"Failed to update learning resource":                         {"resource_save_failed", "Не удалось обновить учебный материал"},
+	"Failed to delete learning resource":                         {"resource_delete_failed", "Не удалось удалить учебный материал"},
+	"rule_type is required (up to 100 characters)":               {"rule_type_required", "Укажите rule_type (до 100 символов)"},
+	"kind must be link or video; PDFs are uploaded as files":     {"invalid_resource_kind", "kind должен быть link или video; PDF загружаются файлами"},
+	"url must be an http or https link":                          {"invalid_resource_url", "url должен быть ссылкой http или https"},
+
+	// Report templates and branding
+	"Failed to fetch report templates":                 {"report_templates_fetch_failed", "Не удалось загрузить шаблоны отчётов"},
+	"Failed to save template":                          {"template_save_failed", "Не удалось сохранить шаблон"},
+	"Failed to delete template":                        {"template_delete_failed", "Не удалось удалить шаблон"},
+	"Failed to activate template":                      {"template_activate_failed", "Не удалось включить шаблон"},
+	"Failed to deactivate template":                    {"template_activate_failed", "Не удалось отключить шаблон"},
+	"Invalid template: %s":                             {"invalid_template", "Некорректный шаблон: %s"},
+	"Failed to render report: %s":                      {"report_render_failed", "Не удалось сформировать отчёт: %s"},
+	"Template is larger than 256 KiB":                  {"template_too_large", "Шаблон больше 256 КиБ"},
+	"Template must be UTF-8 text":                      {"template_not_utf8", "Шаблон должен быть текстом в UTF-8"},
+	"Template name is required (up to 200 characters)": {"name_required", "Укажите название шаблона (до 200 символов)"},
+	"Failed to load branding":                          {"branding_failed", "Не удалось загрузить оформление"},
+	"Failed to update branding":                        {"branding_failed", "Не удалось обновить оформление"},
+	"Branding text is too long":                        {"branding_text_too_long", "Текст оформления слишком длинный"},
+	"Logo is larger than 1 MiB":                        {"logo_too_large", "Логотип больше 1 МиБ"},
+	"Logo must be a PNG, JPEG or WebP image":           {"logo_invalid", "Логотип должен быть изображением PNG, JPEG или WebP"},
+
+	// Gamification and notifications
+	"Achievements are disabled":     {"achievements_disabled", "Достижения отключены"},
+	"Leaderboards are disabled":     {"leaderboards_disabled", "Рейтинги отключены"},
+	"Failed to load achievements":   {"achievements_failed", "Не удалось загрузить достижения"},
+	"Failed to load leaderboard":    {"leaderboard_failed", "Не удалось загрузить рейтинг"},
+	"Notification not found":        {"notification_not_found", "Уведомление не найдено"},
+	"Failed to fetch notifications": {"notifications_fetch_failed", "Не удалось загрузить уведомления"},
+	"Failed to update notification": {"notification_update_failed", "Не удалось обновить уведомление"},
+
+	// Administration
+	"Setting not found":         {"setting_not_found", "Настройка не найдена"},
+	"Unknown setting %s":        {"setting_not_found", "Неизвестная настройка %s"},
+	"No settings given":         {"settings_required", "Не передано ни одной настройки"},
+	"Failed to save settings":   {"settings_save_failed", "Не удалось сохранить настройки"},
+	"Failed to update settings": {"settings_save_failed", "Не удалось обновить настройки"},
+	"Failed to reset setting":   {"settings_save_failed", "Не удалось сбросить настройку"},
+	"Roster sync is not configured (set ROSTER_SCIM_URL or ROSTER_CSV)": {"roster_not_configured", "Синхронизация списков не настроена (задайте ROSTER_SCIM_URL или ROSTER_CSV)"},
+	"Roster sync failed: %s": {"roster_sync_failed", "Синхронизация списков не выполнена: %s"},
+	"Cleanup failed: %s":     {"cleanup_failed", "Очистка не выполнена: %s"},
+}
+
+type errorPattern struct {
+	re    *regexp.Regexp
+	entry errorEntry
+}
+
+// errorPatterns are the catalog messages with variable parts, longest first
+// so that the most specific one matches.
+var errorPatterns = compileErrorPatterns()
+
+func compileErrorPatterns() []errorPattern {
+	var keys []string
+	for k := range errorCatalog {
+		if strings.Contains(k, "%s") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	patterns := make([]errorPattern, 0, len(keys))
+	for _, k := range keys {
+		parts := strings.Split(k, "%s")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		re := regexp.MustCompile("^(?s)" + strings.Join(parts, "(.*?)") + "$")
+		patterns = append(patterns, errorPattern{re: re, entry: errorCatalog[k]})
+	}
+	return patterns
+}
+
+// Negotiate picks the supported language a client prefers from its
+// Accept-Language header, e.g. "en-US,en;q=0.9,ru;q=0.8", or fallback when
+// it accepts none of them.
+func Negotiate(acceptLanguage, fallback string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if i := strings.IndexAny(tag, "-_"); i > 0 {
+			tag = tag[:i]
+		}
+		if (tag == LangRU || tag == LangEN) && q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	if best == "" {
+		return Lang(fallback)
+	}
+	return best
+}
+
+// TranslateError returns the stable code of an API error message and its
+// text in lang. A message missing from the catalog, such as a validation
+// error of the request body, keeps its text and gets the code of status,
+// e.g. "bad_request".
+func TranslateError(message, lang string, status int) (code, text string) {
+	entry, args, ok := lookupError(message)
+	if !ok {
+		return StatusErrorCode(status), message
+	}
+	if lang != LangRU {
+		return entry.code, message
+	}
+	if len(args) == 0 {
+		return entry.code, entry.ru
+	}
+	return entry.code, fmt.Sprintf(entry.ru, args...)
+}
+
+func lookupError(message string) (errorEntry, []interface{}, bool) {
+	if entry, ok := errorCatalog[message]; ok && !strings.Contains(message, "%s") {
+		return entry, nil, true
+	}
+	for _, p := range errorPatterns {
+		m := p.re.FindStringSubmatch(message)
+		if m == nil {
+			continue
+		}
+		args := make([]interface{}, len(m)-1)
+		for i, s := range m[1:] {
+			args[i] = s
+		}
+		return p.entry, args, true
+	}
+	return errorEntry{}, nil, false
+}
+
+// StatusErrorCode is the code of an error the catalog does not know, derived
+// from the HTTP status: 404 -> "not_found".
+func StatusErrorCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
@@ -0,0 +1,93 @@
+// Package i18n renders the language-dependent parts of check reports.
+package i18n
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"strings"
+)
+
+const (
+	LangRU = "ru"
+	LangEN = "en"
+
+	// DefaultLang is used for the strings stored with a report.
+	DefaultLang = LangRU
+)
+
+// unitFormats maps a unit code to its printf format per language.
+var unitFormats = map[string]map[string]string{
+	models.UnitMillimeter: {LangRU: "%.1f мм", LangEN: "%.1f mm"},
+	models.UnitPoint:      {LangRU: "%.1f пт", LangEN: "%.1f pt"},
+	models.UnitLines:      {LangRU: "%.2f", LangEN: "%.2f"},
+	models.UnitPages:      {LangRU: "%.0f стр.", LangEN: "%.0f pages"},
+	models.UnitPage:       {LangRU: "Стр. %.0f", LangEN: "Page %.0f"},
+	models.UnitPercent:    {LangRU: "%.0f%%", LangEN: "%.0f%%"},
+}
+
+var boundFormats = map[string]map[string]string{
+	models.BoundMin: {LangRU: "не менее %s", LangEN: "at least %s"},
+	models.BoundMax: {LangRU: "не более %s", LangEN: "at most %s"},
+}
+
+// Lang maps a requested language tag (e.g. "en", "en-US") to a supported
+// language, falling back to DefaultLang.
+func Lang(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i > 0 {
+		tag = tag[:i]
+	}
+	if tag == LangRU || tag == LangEN {
+		return tag
+	}
+	return DefaultLang
+}
+
+// FormatValue renders a number with its unit, e.g. 20 mm -> "20.0 мм".
+func FormatValue(lang, unit string, value float64) string {
+	formats, ok := unitFormats[unit]
+	if !ok {
+		return fmt.Sprintf("%g", value)
+	}
+	format, ok := formats[lang]
+	if !ok {
+		format = formats[DefaultLang]
+	}
+	return fmt.Sprintf(format, value)
+}
+
+// FormatBound renders a limit such as "не менее 30 стр." for the min/max bound
+// kinds; an empty bound renders the plain value.
+func FormatBound(lang, bound, unit string, value float64) string {
+	s := FormatValue(lang, unit, value)
+	formats, ok := boundFormats[bound]
+	if !ok {
+		return s
+	}
+	format, ok := formats[lang]
+	if !ok {
+		format = formats[DefaultLang]
+	}
+	return fmt.Sprintf(format, s)
+}
+
+// Render fills ExpectedValue/ActualValue of v from its numeric fields. Values
+// without a number keep their text.
+func Render(v *models.Violation, lang string) {
+	if v.Unit == "" {
+		return
+	}
+	if v.ExpectedNum != nil {
+		v.ExpectedValue = FormatBound(lang, v.ExpectedBound, v.Unit, *v.ExpectedNum)
+	}
+	if v.ActualNum != nil {
+		v.ActualValue = FormatValue(lang, v.Unit, *v.ActualNum)
+	}
+}
+
+// Localize renders all violations for lang in place.
+func Localize(violations []models.Violation, lang string) {
+	for i := range violations {
+		Render(&violations[i], lang)
+	}
+}
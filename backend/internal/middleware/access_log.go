@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// accessStatsFlushInterval is how often the in-memory request aggregates are
+// written to the request_stats table.
+const accessStatsFlushInterval = time.Minute
+
+// defaultAccessStatsRetention is how long aggregates are kept unless
+// ACCESS_STATS_RETENTION_DAYS says otherwise.
+const defaultAccessStatsRetention = 90 * 24 * time.Hour
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route, method, status and role of the caller.",
+	}, []string{"method", "route", "status", "role"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route, method and role of the caller.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "role"})
+	httpResponseBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_response_bytes_total",
+		Help: "Response body bytes by route, method and role of the caller.",
+	}, []string{"method", "route", "role"})
+)
+
+// AccessLog records every request once it has been handled: as a JSON log
+// entry (unless ACCESS_LOG=off), in the Prometheus metrics and in hourly
+// per-user aggregates in the request_stats table (unless ACCESS_STATS=off;
+// kept for ACCESS_STATS_RETENTION_DAYS, default 90).
+// User ID and role are those set by AuthMiddleware; anonymous requests have
+// user 0 and role "anonymous". Prometheus labels carry the role only, so the
+// number of series does not grow with the number of users.
+func AccessLog() gin.HandlerFunc {
+	var logger *slog.Logger
+	if !envOff("ACCESS_LOG") {
+		logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	}
+	var stats *accessStats
+	if !envOff("ACCESS_STATS") {
+		stats = defaultAccessStats()
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched" // keeps unknown paths out of the metric labels
+		}
+		role := c.GetString("role")
+		if role == "" {
+			role = "anonymous"
+		}
+		userID := c.GetUint("user_id")
+		status := c.Writer.Status()
+		bytes := c.Writer.Size()
+		if bytes < 0 {
+			bytes = 0
+		}
+		method := c.Request.Method
+
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(status), role).Inc()
+		httpRequestDuration.WithLabelValues(method, route, role).Observe(latency.Seconds())
+		httpResponseBytes.WithLabelValues(method, route, role).Add(float64(bytes))
+
+		if logger != nil {
+			attrs := []any{
+				"method", method,
+				"route", route,
+				"path", c.Request.URL.Path,
+				"status", status,
+				"latency_ms", float64(latency.Microseconds()) / 1000,
+				"bytes", bytes,
+				"user_id", userID,
+				"role", role,
+				"ip", c.ClientIP(),
+			}
+			if impersonator := c.GetUint("impersonator_id"); impersonator != 0 {
+				attrs = append(attrs, "impersonator_id", impersonator)
+			}
+			logger.Info("request", attrs...)
+		}
+
+		if stats != nil {
+			stats.add(accessKey{
+				bucket: start.UTC().Truncate(time.Hour),
+				userID: userID,
+				role:   role,
+				method: method,
+				route:  route,
+			}, status, latency, bytes)
+		}
+	}
+}
+
+func envOff(key string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+	case "off", "false", "0":
+		return true
+	}
+	return false
+}
+
+type accessKey struct {
+	bucket time.Time // start of the UTC hour
+	userID uint
+	role   string
+	method string
+	route  string
+}
+
+type accessTotals struct {
+	requests  int
+	errors    int // status >= 400
+	latencyMs float64
+	bytes     int64
+}
+
+// accessStats accumulates request counts in memory and periodically adds them
+// to the request_stats table.
+type accessStats struct {
+	mu      sync.Mutex
+	pending map[accessKey]*accessTotals
+}
+
+var (
+	accessStatsInstance *accessStats
+	accessStatsOnce     sync.Once
+)
+
+func defaultAccessStats() *accessStats {
+	accessStatsOnce.Do(func() {
+		accessStatsInstance = &accessStats{pending: map[accessKey]*accessTotals{}}
+		go func() {
+			for range time.Tick(accessStatsFlushInterval) {
+				accessStatsInstance.flush()
+			}
+		}()
+	})
+	return accessStatsInstance
+}
+
+func (s *accessStats) add(key accessKey, status int, latency time.Duration, bytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.pending[key]
+	if t == nil {
+		t = &accessTotals{}
+		s.pending[key] = t
+	}
+	t.requests++
+	if status >= 400 {
+		t.errors++
+	}
+	t.latencyMs += float64(latency.Microseconds()) / 1000
+	t.bytes += int64(bytes)
+}
+
+func (s *accessStats) flush() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = map[accessKey]*accessTotals{}
+	s.mu.Unlock()
+
+	if len(pending) == 0 || database.DB == nil {
+		return
+	}
+	for key, t := range pending {
+		_, err := database.DB.Exec(`
+			INSERT INTO request_stats (bucket, user_id, role, method, route, requests, errors, latency_ms, bytes)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(bucket, user_id, role, method, route) DO UPDATE SET
+				requests = requests + excluded.requests,
+				errors = errors + excluded.errors,
+				latency_ms = latency_ms + excluded.latency_ms,
+				bytes = bytes + excluded.bytes
+		`, database.Timestamp(key.bucket), key.userID, key.role, key.method, key.route, t.requests, t.errors, t.latencyMs, t.bytes)
+		if err != nil {
+			fmt.Printf("AccessLog: failed to store request stats: %v\n", err)
+			return
+		}
+	}
+
+	retention := defaultAccessStatsRetention
+	if n, err := strconv.Atoi(os.Getenv("ACCESS_STATS_RETENTION_DAYS")); err == nil && n > 0 {
+		retention = time.Duration(n) * 24 * time.Hour
+	}
+	_, _ = database.DB.Exec("DELETE FROM request_stats WHERE bucket < ?", database.Timestamp(time.Now().Add(-retention)))
+}
+
+// FlushAccessStats writes the pending request aggregates to the database, so
+// a report reads up-to-date numbers.
+func FlushAccessStats() {
+	if accessStatsInstance != nil {
+		accessStatsInstance.flush()
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/Ullyminat/NormoControl/backend/internal/i18n"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorWriter holds back a JSON error response so LocalizeErrors can
+// translate it once the handler is done. Other responses pass through.
+type errorWriter struct {
+	gin.ResponseWriter
+	held bool
+	body bytes.Buffer
+}
+
+func (w *errorWriter) hold() bool {
+	if !w.held && !w.ResponseWriter.Written() && w.Status() >= 400 &&
+		strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		w.held = true
+	}
+	return w.held
+}
+
+func (w *errorWriter) Write(data []byte) (int, error) {
+	if w.hold() {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorWriter) WriteString(s string) (int, error) {
+	if w.hold() {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// LocalizeErrors translates the "error" message of JSON error responses into
+// the language the client asks for in Accept-Language, or defaultLang() when
+// it asks for none this server speaks, and adds its stable "code", which
+// clients should match on instead of the text. Handlers and the middleware
+// after this one keep responding with the English messages of the catalog in
+// i18n. The negotiated language is stored in the context under "lang".
+func LocalizeErrors(defaultLang func() string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.Negotiate(c.GetHeader("Accept-Language"), defaultLang())
+		c.Set("lang", lang)
+		w := &errorWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if !w.held {
+			return
+		}
+
+		body := w.body.Bytes()
+		var payload map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&payload); err == nil {
+			if message, ok := payload["error"].(string); ok {
+				code, text := i18n.TranslateError(message, lang, w.Status())
+				payload["error"], payload["code"] = text, code
+				if translated, err := json.Marshal(payload); err == nil {
+					body = translated
+					c.Header("Content-Language", lang)
+					c.Writer.Header().Add("Vary", "Accept-Language")
+				}
+			}
+		}
+		c.Writer.Header().Del("Content-Length")
+		c.Writer.Write(body)
+	}
+}
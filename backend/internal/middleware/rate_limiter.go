@@ -1,14 +1,47 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/redis"
+	"log"
 	"net/http"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
 
+// Limiter decides whether one more request of a client may pass now.
+type Limiter interface {
+	Allow(key string) bool
+	// SetLimit changes the rate and burst, also for clients seen before.
+	SetLimit(r rate.Limit, b int)
+}
+
+// NewRateLimiter returns the limiter named name, allowing r requests per
+// second with bursts of b per client. RATE_LIMIT_BACKEND=redis shares the
+// limits between all replicas through REDIS_URL; by default each process
+// counts on its own.
+func NewRateLimiter(name string, r rate.Limit, b int) Limiter {
+	switch os.Getenv("RATE_LIMIT_BACKEND") {
+	case "", "memory":
+		return NewIPRateLimiter(r, b)
+	case "redis":
+		client := redis.Default()
+		if client == nil {
+			log.Fatal("RATE_LIMIT_BACKEND=redis requires REDIS_URL")
+		}
+		return &RedisRateLimiter{client: client, name: name, r: r, b: b, fallback: NewIPRateLimiter(r, b)}
+	default:
+		log.Fatalf("Unknown RATE_LIMIT_BACKEND %q, expected memory or redis", os.Getenv("RATE_LIMIT_BACKEND"))
+	}
+	return nil
+}
+
 // IPRateLimiter stores rate limiters for each IP address
 type IPRateLimiter struct {
 	ips map[string]*rate.Limiter
@@ -45,15 +78,97 @@ func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
 	return limiter
 }
 
+// Allow takes a token from the bucket of ip.
+func (i *IPRateLimiter) Allow(ip string) bool {
+	return i.GetLimiter(ip).Allow()
+}
+
+// SetLimit changes the rate and burst of all buckets.
+func (i *IPRateLimiter) SetLimit(r rate.Limit, b int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.r, i.b = r, b
+	for _, limiter := range i.ips {
+		limiter.SetLimit(r)
+		limiter.SetBurst(b)
+	}
+}
+
+// tokenBucketScript refills the bucket in KEYS[1] at ARGV[1] tokens per second
+// up to ARGV[2] and takes one token if there is one. The clock of the Redis
+// server is used, so replicas with skewed clocks count alike.
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return allowed
+`
+
+// RedisRateLimiter keeps a token bucket per client in Redis, shared by all
+// replicas. While Redis is unreachable the replica falls back to its own
+// in-memory buckets rather than rejecting or letting through everything.
+type RedisRateLimiter struct {
+	client   *redis.Client
+	name     string
+	mu       sync.RWMutex
+	r        rate.Limit
+	b        int
+	fallback *IPRateLimiter
+	degraded atomic.Bool // logged once per outage
+}
+
+// Allow takes a token from the shared bucket of ip.
+func (l *RedisRateLimiter) Allow(ip string) bool {
+	l.mu.RLock()
+	r, b := l.r, l.b
+	l.mu.RUnlock()
+	if r <= 0 {
+		return l.fallback.Allow(ip)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	key := fmt.Sprintf("normocontrol:ratelimit:%s:%s", l.name, ip)
+	reply, err := l.client.Eval(ctx, tokenBucketScript, []string{key}, float64(r), b)
+	if err != nil {
+		if !l.degraded.Swap(true) {
+			fmt.Printf("RateLimit: shared limiter %s unavailable, using the local one: %v\n", l.name, err)
+		}
+		return l.fallback.Allow(ip)
+	}
+	if l.degraded.Swap(false) {
+		fmt.Printf("RateLimit: shared limiter %s available again\n", l.name)
+	}
+	allowed, _ := reply.(int64)
+	return allowed == 1
+}
+
+// SetLimit changes the rate and burst of the shared buckets and the local
+// fallback.
+func (l *RedisRateLimiter) SetLimit(r rate.Limit, b int) {
+	l.mu.Lock()
+	l.r, l.b = r, b
+	l.mu.Unlock()
+	l.fallback.SetLimit(r, b)
+}
+
 // RateLimitMiddleware is a Gin middleware that enforces the IP rate limit
-func RateLimitMiddleware(limiter *IPRateLimiter) gin.HandlerFunc {
+func RateLimitMiddleware(limiter Limiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		limiterForIP := limiter.GetLimiter(ip)
-
-		if !limiterForIP.Allow() {
+		if !limiter.Allow(c.ClientIP()) {
 			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Трошки медленнее! Слишком много запросов (Rate Limit Exceeded)",
+				"error": "Slow down! Too many requests (Rate Limit Exceeded)",
 			})
 			c.Abort()
 			return
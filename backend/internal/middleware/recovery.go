@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/reporting"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery converts a panic in a handler into a JSON 500 response and reports
+// it together with the request context.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				stack := debug.Stack()
+				log.Printf("panic recovered: %v\n%s", r, stack)
+				reporting.CapturePanic(r, stack, map[string]string{
+					"method":  c.Request.Method,
+					"route":   c.FullPath(),
+					"user_id": fmt.Sprint(c.GetUint("user_id")),
+				})
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			}
+		}()
+		c.Next()
+	}
+}
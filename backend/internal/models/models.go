@@ -1,18 +1,22 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type User struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
+	UUID         string    `json:"uuid"` // external identifier, see database.ResolveID
 	Email        string    `json:"email" gorm:"unique;not null"`
 	PasswordHash string    `json:"-"`
-	Role         string    `json:"role" gorm:"not null"` // student, teacher, admin
+	Role         string    `json:"role" gorm:"not null"` // student, teacher, supervisor, admin
 	FullName     string    `json:"full_name"`
 	GroupID      *uint     `json:"group_id"`
 	CreatedAt    time.Time `json:"created_at"`
 	IsActive     bool      `json:"is_active" gorm:"default:true"`
+	Department   string    `json:"department,omitempty"` // teachers: the department their statistics roll up to
+	Coordinator  bool      `json:"coordinator"`          // a teacher who sees the statistics of their department
 }
 
 type StudentGroup struct {
@@ -25,8 +29,51 @@ type StudentGroup struct {
 	CreatedYear   int    `json:"created_year"`
 }
 
+// Assignment asks the students of a group to submit a work checked against a
+// standard within a submission window. What happens to a submission after the
+// deadline depends on LatePolicy.
+type Assignment struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	StandardID  uint       `json:"standard_id"`
+	GroupID     uint       `json:"group_id"`
+	CreatedBy   uint       `json:"created_by"`
+	OpensAt     *time.Time `json:"opens_at"` // nil: open from creation
+	Deadline    time.Time  `json:"deadline"`
+	LatePolicy  string     `json:"late_policy"` // see LatePolicy* constants
+	GraceHours  int        `json:"grace_hours"` // grace policy only; 0 accepts late work at any time
+	PenaltyNote string     `json:"penalty_note"`
+	TermID      *uint      `json:"term_id"` // nil: the deadline falls outside every term
+	// The naming scheme of submitted files, replacing the file_name rule of
+	// the standard when FileNamePattern is set.
+	FileNamePattern string    `json:"file_name_pattern"`
+	FileNameExample string    `json:"file_name_example"`
+	FileNameReject  bool      `json:"file_name_reject"` // refuse misnamed uploads instead of reporting them
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// AcademicTerm is a semester, e.g. "2024-осень", configured by admins.
+// Assignments are filed under the term of their deadline and checks under
+// the term of their assignment or check date. An archived term is read-only:
+// its assignments no longer accept submissions and cannot be changed.
+type AcademicTerm struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"` // exclusive
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Late submission policies of an assignment.
+const (
+	LatePolicyHard  = "hard"  // submissions after the deadline are rejected
+	LatePolicyGrace = "grace" // accepted during the grace period and flagged as late
+)
+
 type FormattingStandard struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
+	UUID         string    `json:"uuid"`
 	Name         string    `json:"name"`
 	Description  string    `json:"description"`
 	CreatedBy    uint      `json:"created_by"`
@@ -38,6 +85,51 @@ type FormattingStandard struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// StandardAccess shares a private standard with a student group or with one
+// user, who then see and use it like a public one. Exactly one of GroupID
+// and UserID is set.
+type StandardAccess struct {
+	ID         uint      `json:"id"`
+	StandardID uint      `json:"standard_id"`
+	GroupID    *uint     `json:"group_id,omitempty"`
+	GroupName  string    `json:"group_name,omitempty"`
+	UserID     *uint     `json:"user_id,omitempty"`
+	UserName   string    `json:"user_name,omitempty"`
+	GrantedBy  uint      `json:"granted_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Supervision grants a supervisor (научный руководитель) read access to the
+// checks of a student group or of one student. Exactly one of GroupID and
+// StudentID is set.
+type Supervision struct {
+	ID           uint      `json:"id"`
+	SupervisorID uint      `json:"supervisor_id"`
+	GroupID      *uint     `json:"group_id,omitempty"`
+	GroupName    string    `json:"group_name,omitempty"`
+	StudentID    *uint     `json:"student_id,omitempty"`
+	StudentName  string    `json:"student_name,omitempty"`
+	GrantedBy    uint      `json:"granted_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// StandardVersion is an immutable snapshot of a standard. Every change of
+// the standard adds one, and each check result refers to the version it was
+// checked against.
+type StandardVersion struct {
+	ID           uint               `json:"id"`
+	StandardID   uint               `json:"standard_id"`
+	Version      int                `json:"version"` // 1, 2, ... per standard
+	Name         string             `json:"name"`
+	Description  string             `json:"description"`
+	DocumentType string             `json:"document_type"`
+	Modules      []ValidationModule `json:"modules"`
+	CreatedBy    uint               `json:"created_by"`
+	RestoredFrom *int               `json:"restored_from,omitempty"` // version a rollback copied
+	Checks       int                `json:"checks"`                  // check results against this version
+	CreatedAt    time.Time          `json:"created_at"`
+}
+
 type ValidationModule struct {
 	ID     string                 `json:"id"`     // uuid or simple random string
 	Name   string                 `json:"name"`   // e.g., "Title Page"
@@ -46,17 +138,278 @@ type ValidationModule struct {
 
 type Document struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
+	UUID         string    `json:"uuid"`
 	UserID       uint      `json:"user_id"`
 	FileName     string    `json:"file_name"`
 	FilePath     string    `json:"file_path"`
 	FileSize     int64     `json:"file_size"`
 	UploadDate   time.Time `json:"upload_date"`
-	Status       string    `json:"status"` // new, processing, checked
+	Status       string    `json:"status"` // see DocStatus* constants
 	MetadataJSON string    `json:"metadata_json"`
+	ContentHash  string    `json:"content_hash"` // SHA-256 of the uploaded file, used for de-duplication
+	LastError    string    `json:"last_error"`   // error of the last failed pipeline stage
+	StandardID   int       `json:"standard_id"`  // standard and config of the last requested check,
+	ConfigJSON   string    `json:"-"`            // kept so a failed stage can be retried
+
+	Metadata WorkMetadata `json:"metadata"` // of the last requested check
+}
+
+// WorkMetadata describes the checked work for reports and teacher history. The
+// fields are optional: they are given at upload or taken from the assignment
+// and can be corrected after the check.
+type WorkMetadata struct {
+	Topic         string `json:"topic"`
+	Supervisor    string `json:"supervisor"`
+	GroupName     string `json:"group_name"`
+	SpecialtyCode string `json:"specialty_code"`
+}
+
+// Document processing pipeline. A document moves through the stages in order;
+// when a stage fails the status becomes "failed_<stage>" (see FailedStatus).
+const (
+	DocStatusUploaded  = "uploaded"
+	DocStatusScheduled = "scheduled" // waiting for the time window of its CheckSchedule
+	DocStatusDone      = "done"
+
+	StageParsing    = "parsing"
+	StageChecking   = "checking"
+	StageSaving     = "saving"
+	StageConverting = "converting"
+)
+
+// FailedStatus returns the document status recorded when stage fails.
+func FailedStatus(stage string) string {
+	return "failed_" + stage
+}
+
+// FailedJob is a dead-letter entry for a check that panicked or timed out.
+type FailedJob struct {
+	ID         uint      `json:"id"`
+	DocumentID uint      `json:"document_id"`
+	FilePath   string    `json:"file_path"`
+	Stage      string    `json:"stage"`
+	Error      string    `json:"error"`
+	Stack      string    `json:"stack"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Branding is the identity of the institution running the service, printed on
+// check reports. There is a single branding per installation.
+type Branding struct {
+	InstitutionName string `json:"institution_name"`
+	LogoURL         string `json:"logo_url"`
+	ReportHeader    string `json:"report_header"`
+	ReportFooter    string `json:"report_footer"`
+	// GamificationEnabled turns on leaderboards and achievements.
+	GamificationEnabled bool `json:"gamification_enabled"`
+}
+
+// ReportTemplate is an admin-provided layout of the check report (лист
+// нормоконтроля) written as a Go html/template. At most one is active; without
+// an active template the built-in layout is used.
+type ReportTemplate struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body,omitempty"`
+	IsActive  bool      `json:"is_active"`
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LearningResource is study material attached to a rule type: a link, a PDF
+// uploaded by an admin or a video fragment starting at StartSeconds. It is
+// returned with every violation of that rule type.
+type LearningResource struct {
+	ID           uint      `json:"id"`
+	RuleType     string    `json:"rule_type"`
+	Title        string    `json:"title"`
+	Kind         string    `json:"kind"` // see Resource* constants
+	URL          string    `json:"url"`
+	StartSeconds int       `json:"start_seconds,omitempty"` // video only
+	CreatedBy    uint      `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CheckSchedule is a batch of documents, e.g. a group's works, uploaded at
+// once and checked later in the daily time window from WindowStart to
+// WindowEnd ("23:00" – "06:00"), so that batch processing does not slow down
+// interactive checks during the day. The uploader is notified when the
+// batch is done.
+type CheckSchedule struct {
+	ID          uint                `json:"id"`
+	UserID      uint                `json:"user_id"`
+	StandardID  int                 `json:"standard_id"`
+	Name        string              `json:"name"`
+	WindowStart string              `json:"window_start"`
+	WindowEnd   string              `json:"window_end"`
+	Status      string              `json:"status"` // see Schedule* constants
+	Total       int                 `json:"total"`
+	Checked     int                 `json:"checked"`
+	Failed      int                 `json:"failed"`
+	CreatedAt   time.Time           `json:"created_at"`
+	StartedAt   *time.Time          `json:"started_at,omitempty"`
+	FinishedAt  *time.Time          `json:"finished_at,omitempty"`
+	Documents   []ScheduledDocument `json:"documents,omitempty"`
+	// Skipped lists the entries of uploaded archives that were not taken,
+	// only in the response to the upload.
+	Skipped []SkippedEntry `json:"skipped,omitempty"`
+}
+
+// SkippedEntry is an entry of an uploaded archive left out of a batch check.
+// Reason is one of the archive.Skip* reasons.
+type SkippedEntry struct {
+	Archive string `json:"archive"`
+	Path    string `json:"path"`
+	Reason  string `json:"reason"`
+}
+
+// Statuses of a CheckSchedule.
+const (
+	ScheduleWaiting   = "waiting"
+	ScheduleRunning   = "running"
+	ScheduleDone      = "done"
+	ScheduleCancelled = "cancelled"
+)
+
+// ScheduledDocument is a document of a CheckSchedule with the result of its
+// check once it has run.
+type ScheduledDocument struct {
+	ID        uint     `json:"id"`
+	UUID      string   `json:"uuid"`
+	FileName  string   `json:"file_name"`
+	Status    string   `json:"status"` // see DocStatus* constants
+	LastError string   `json:"last_error,omitempty"`
+	ResultID  string   `json:"result_id,omitempty"`
+	Score     *float64 `json:"score,omitempty"`
+	// StudentID is the student the file name was matched to, see
+	// ScheduleCheck.
+	StudentID   *uint  `json:"student_id,omitempty"`
+	StudentName string `json:"student_name,omitempty"`
+}
+
+// Notification is a message to a user about something that happened in the
+// background, e.g. a finished batch check. Link is the API path of the
+// subject.
+type Notification struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"user_id"`
+	Kind      string    `json:"kind"` // see Notification* constants
+	Message   string    `json:"message"`
+	Link      string    `json:"link,omitempty"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Kinds of notifications.
+const (
+	NotificationScheduleDone = "schedule_done"
+)
+
+// FeedbackTemplate is a reusable comment of a teacher, e.g. "Исправьте список
+// литературы по ГОСТ 7.0.100 и перезалейте". RuleType, when set, ties it to a
+// group of violations.
+type FeedbackTemplate struct {
+	ID        uint      `json:"id"`
+	TeacherID uint      `json:"teacher_id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	RuleType  string    `json:"rule_type,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// VocabularyDictionary is a managed list of words to avoid, e.g. the
+// colloquialisms or first-person pronouns of a department. Standards refer to
+// it by id in scope.dictionaries, so one dictionary serves many standards;
+// shared dictionaries can be used by every teacher.
+type VocabularyDictionary struct {
+	ID         uint             `json:"id"`
+	TeacherID  uint             `json:"teacher_id"`
+	Name       string           `json:"name"`
+	Category   string           `json:"category"`
+	Severity   string           `json:"severity"`
+	Morphology bool             `json:"morphology"`
+	Shared     bool             `json:"shared"`
+	Words      []DictionaryWord `json:"words"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+// DictionaryWord is a vocabulary dictionary entry with what to write instead.
+// Exact entries are matched only as written, not in other forms.
+type DictionaryWord struct {
+	Word         string   `json:"word"`
+	Replacements []string `json:"replacements,omitempty"`
+	Exact        bool     `json:"exact,omitempty"`
+}
+
+// ResultComment is a teacher's comment on a check result or, when RuleType is
+// set, on its violations of that type.
+type ResultComment struct {
+	ID         uint      `json:"id"`
+	ResultID   uint      `json:"result_id"`
+	AuthorID   uint      `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	TemplateID *uint     `json:"template_id,omitempty"`
+	RuleType   string    `json:"rule_type,omitempty"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Review states of a false positive report.
+const (
+	FalsePositivePending   = "pending"
+	FalsePositiveConfirmed = "confirmed" // the rule was wrong; exported as a fixture
+	FalsePositiveRejected  = "rejected"  // the violation stands
+)
+
+// FalsePositiveReport is a claim that a violation was reported wrongly. It
+// keeps what the rule saw, so confirmed cases can be replayed as regression
+// fixtures after the document itself is gone.
+type FalsePositiveReport struct {
+	ID           uint            `json:"id"`
+	ResultID     uint            `json:"result_id"`
+	ResultUUID   string          `json:"result_uuid"`
+	ViolationID  uint            `json:"violation_id"`
+	RuleType     string          `json:"rule_type"`
+	ReportedBy   uint            `json:"reported_by"`
+	ReporterName string          `json:"reporter_name"`
+	Comment      string          `json:"comment"`
+	Violation    json.RawMessage `json:"violation"`           // the violation as reported
+	Paragraph    json.RawMessage `json:"paragraph,omitempty"` // the parsed paragraph it points to
+	Config       json.RawMessage `json:"config,omitempty"`    // the standard config of the check
+	Status       string          `json:"status"`              // see FalsePositive* constants
+	ReviewedBy   *uint           `json:"reviewed_by,omitempty"`
+	ReviewNote   string          `json:"review_note,omitempty"`
+	ReviewedAt   *time.Time      `json:"reviewed_at,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// Kinds of learning resources.
+const (
+	ResourceLink  = "link"
+	ResourcePDF   = "pdf"
+	ResourceVideo = "video"
+)
+
+// ImpersonationEvent is an audit entry of an admin acting as another user:
+// the start and end of the session and every request made with it.
+type ImpersonationEvent struct {
+	ID         uint      `json:"id"`
+	AdminID    uint      `json:"admin_id"`
+	AdminEmail string    `json:"admin_email"`
+	UserID     uint      `json:"user_id"`
+	UserEmail  string    `json:"user_email"`
+	Action     string    `json:"action"` // start, request, stop
+	Detail     string    `json:"detail"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type CheckResult struct {
 	ID             uint      `json:"id" gorm:"primaryKey"`
+	UUID           string    `json:"uuid"`
 	DocumentID     uint      `json:"document_id"`
 	StandardID     uint      `json:"standard_id"`
 	CheckDate      time.Time `json:"check_date"`
@@ -67,22 +420,139 @@ type CheckResult struct {
 	ProcessingTime int       `json:"processing_time"` // ms
 	ReportPath     string    `json:"report_path"`
 	ContentJSON    string    `json:"content_json"` // Serialized []ParsedParagraph for Reader View
+	Summary        string    `json:"summary"`      // short human-readable conclusion of the check
+	Fingerprint    string    `json:"-"`            // serialized checker.Fingerprint, compared between submissions
+
+	// Submission to an assignment; late submissions carry the penalty note.
+	AssignmentID  *uint  `json:"assignment_id"`
+	SubmittedLate bool   `json:"submitted_late"`
+	LateNote      string `json:"late_note"`
+
+	TermID *uint `json:"term_id"` // academic term the check is counted in
+
+	Metadata WorkMetadata `json:"metadata"`
+
+	RuleTimings map[string]float64 `json:"-"` // ms spent per rule group, returned only in debug mode
+	RuleTrace   []RuleTrace        `json:"-"` // how each rule group was evaluated, returned only in debug mode
 }
 
+// RuleTrace explains one rule group of a check: the document values it read,
+// the settings it applied and why it passed, failed or was skipped.
+type RuleTrace struct {
+	Rule       string                 `json:"rule"`
+	Status     string                 `json:"status"`           // passed, failed, skipped
+	Reason     string                 `json:"reason,omitempty"` // why the group was skipped
+	Rules      int                    `json:"rules"`            // rules counted towards the score
+	Violations int                    `json:"violations"`
+	Failed     map[string]int         `json:"failed,omitempty"`     // violations per rule type
+	Read       map[string]interface{} `json:"read,omitempty"`       // document values the rules read
+	Thresholds map[string]interface{} `json:"thresholds,omitempty"` // standard settings applied
+	Evidence   []RuleEvidence         `json:"evidence,omitempty"`   // the first violations of the group
+}
+
+// RuleEvidence is a violation as shown in a rule trace.
+type RuleEvidence struct {
+	RuleType string `json:"rule_type"`
+	Position string `json:"position"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// Statuses of a RuleTrace.
+const (
+	TracePassed  = "passed"
+	TraceFailed  = "failed"
+	TraceSkipped = "skipped"
+)
+
 type Violation struct {
 	ID            uint   `json:"id" gorm:"primaryKey"`
 	ResultID      uint   `json:"result_id"`
 	RuleType      string `json:"rule_type"`
 	Description   string `json:"description"`
-	Severity      string `json:"severity"` // critical, error, warning
+	Severity      string `json:"severity"` // see Severity* constants
 	PositionInDoc string `json:"position_in_doc"`
 	ExpectedValue string `json:"expected_value"`
 	ActualValue   string `json:"actual_value"`
 	Suggestion    string `json:"suggestion"`
 	ContextText   string `json:"context_text"` // Snippet from the document for precise locating
 
+	// Machine-readable form of numeric values. ExpectedValue/ActualValue are
+	// rendered from these for the requested language (see package i18n).
+	ExpectedNum   *float64 `json:"expected_num,omitempty"`
+	ActualNum     *float64 `json:"actual_num,omitempty"`
+	Unit          string   `json:"unit,omitempty"`           // see Unit* constants
+	ExpectedBound string   `json:"expected_bound,omitempty"` // BoundMin, BoundMax or empty for an exact value
+
+	// Structured counterpart of PositionInDoc; nil for document-wide violations.
+	Location *Location `json:"location,omitempty"`
+
 	// AI Hybrid Verification fields
 	IsDoubtful    bool   `json:"is_doubtful"`     // Flagged by algorithm for AI double-check
 	AIVerified    bool   `json:"ai_verified"`     // Whether AI has processed this
 	AIExplanation string `json:"ai_explanation"` // Explanation from AI
+
+	// Study material for the rule type, attached when the violation is served.
+	Resources []LearningResource `json:"resources,omitempty"`
 }
+
+// Violation severities. Info and hint are non-blocking observations: they are
+// listed in the report but never affect the score.
+const (
+	SeverityCritical = "critical"
+	SeverityError    = "error"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+	SeverityHint     = "hint"
+)
+
+// SeverityIntegrity marks formatting that looks meant to inflate the page
+// count. Such violations are shown to teachers only and do not affect the
+// score; they cannot be assigned through severity overrides.
+const SeverityIntegrity = "integrity"
+
+// SeverityManual lists a requirement the standard leaves to the
+// normocontroller instead of a finding. It does not affect the score and
+// cannot be assigned through severity overrides.
+const SeverityManual = "manual"
+
+// IsBlocking reports whether a violation of this severity counts against the score.
+func IsBlocking(severity string) bool {
+	return severity != SeverityInfo && severity != SeverityHint && severity != SeverityIntegrity && severity != SeverityManual
+}
+
+// IsValidSeverity reports whether s is one of the Severity* constants.
+func IsValidSeverity(s string) bool {
+	switch s {
+	case SeverityCritical, SeverityError, SeverityWarning, SeverityInfo, SeverityHint:
+		return true
+	}
+	return false
+}
+
+// Location anchors a violation in the parsed document. Indexes are zero-based
+// positions in the parser's paragraph/table/image/formula lists; nil means the
+// index does not apply. CharStart/CharEnd are rune offsets in the paragraph text.
+type Location struct {
+	Page           int    `json:"page,omitempty"`
+	ParagraphIndex *int   `json:"paragraph_index,omitempty"`
+	ParagraphID    string `json:"paragraph_id,omitempty"`
+	TableIndex     *int   `json:"table_index,omitempty"`
+	ImageIndex     *int   `json:"image_index,omitempty"`
+	FormulaIndex   *int   `json:"formula_index,omitempty"`
+	CharStart      *int   `json:"char_start,omitempty"`
+	CharEnd        *int   `json:"char_end,omitempty"`
+}
+
+// Unit codes of Violation.ExpectedNum/ActualNum.
+const (
+	UnitMillimeter = "mm"
+	UnitPoint      = "pt"
+	UnitLines      = "lines" // line spacing multiplier
+	UnitPages      = "pages" // page count
+	UnitPage       = "page"  // page number
+	UnitPercent    = "percent"
+
+	BoundMin = "min"
+	BoundMax = "max"
+)
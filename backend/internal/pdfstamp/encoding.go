@@ -0,0 +1,94 @@
+package pdfstamp
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// The stamp uses Helvetica, one of the fonts every viewer provides, so no
+// font file is embedded. Its codes follow Windows-1251 for Cyrillic: the
+// Differences of the encoding name the Cyrillic glyphs, which viewers take
+// from the substituted system font.
+
+// cyrillicCode maps the letters of the Russian alphabet and № to their codes.
+func cyrillicCode(r rune) (byte, bool) {
+	switch {
+	case r >= 'А' && r <= 'я':
+		return byte(0xC0 + r - 'А'), true
+	case r == 'Ё':
+		return 0xA8, true
+	case r == 'ё':
+		return 0xB8, true
+	case r == '№':
+		return 0xB9, true
+	}
+	return 0, false
+}
+
+// glyphName returns the Adobe glyph name of code, as fontObject encodes it.
+func glyphName(code byte) string {
+	switch {
+	case code == 0xA8:
+		return "afii10023" // Ё
+	case code == 0xB8:
+		return "afii10071" // ё
+	case code == 0xB9:
+		return "afii61352" // №
+	case code >= 0xC0 && code < 0xE0: // А..Я; Ё sits between Е and Ж
+		i := int(code - 0xC0)
+		if i >= 6 {
+			i++
+		}
+		return fmt.Sprintf("afii%d", 10017+i)
+	default: // а..я
+		i := int(code - 0xE0)
+		if i >= 6 {
+			i++
+		}
+		return fmt.Sprintf("afii%d", 10065+i)
+	}
+}
+
+// fontObject returns the font dictionary of the stamp.
+func fontObject() []byte {
+	var b bytes.Buffer
+	b.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding << /Type /Encoding /BaseEncoding /WinAnsiEncoding /Differences [")
+	for _, code := range []byte{0xA8, 0xB8, 0xB9} {
+		fmt.Fprintf(&b, " %d /%s", code, glyphName(code))
+	}
+	b.WriteString(" 192")
+	for code := 0xC0; code <= 0xFF; code++ {
+		b.WriteString(" /" + glyphName(byte(code)))
+	}
+	b.WriteString(" ] >> >>")
+	return b.Bytes()
+}
+
+// encodeText returns the codes that show s in the stamp's font. Characters
+// it has no glyph for become '?'.
+func encodeText(s string) []byte {
+	var out []byte
+	for _, r := range s {
+		if r >= 0x20 && r < 0x7F {
+			out = append(out, byte(r))
+		} else if code, ok := cyrillicCode(r); ok {
+			out = append(out, code)
+		} else {
+			switch r {
+			case '«':
+				out = append(out, 0xAB)
+			case '»':
+				out = append(out, 0xBB)
+			case '–':
+				out = append(out, 0x96)
+			case '—':
+				out = append(out, 0x97)
+			case ' ':
+				out = append(out, ' ')
+			default:
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,226 @@
+package pdfstamp
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// The object model covers what stamping reads and writes again: strings and
+// numbers keep their source text, so untouched values are written back as
+// they were.
+type (
+	name    string // without the leading slash, #xx escapes kept
+	number  string
+	keyword string // true, false or null
+	str     []byte // a literal or hex string with its delimiters
+	array   []object
+	dict    map[string]object
+	ref     struct{ num, gen int }
+	stream  struct {
+		dict dict
+		data []byte // raw, still encoded
+	}
+	object interface{}
+)
+
+func (n number) float() float64 {
+	f, _ := strconv.ParseFloat(string(n), 64)
+	return f
+}
+
+func isWhite(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// lexer reads objects from data starting at pos.
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isWhite(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+// token returns the next regular token, such as a number or keyword.
+func (l *lexer) token() string {
+	l.skipSpace()
+	start := l.pos
+	for l.pos < len(l.data) && !isWhite(l.data[l.pos]) && !isDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("pdf: offset %d: %s", l.pos, fmt.Sprintf(format, args...))
+}
+
+// object reads one object; depth guards against nesting bombs.
+func (l *lexer) object(depth int) (object, error) {
+	if depth > 64 {
+		return nil, l.errorf("objects nested too deeply")
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, l.errorf("unexpected end of data")
+	}
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isWhite(l.data[l.pos]) && !isDelim(l.data[l.pos]) {
+			l.pos++
+		}
+		return name(l.data[start:l.pos]), nil
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		d := dict{}
+		for {
+			l.skipSpace()
+			if bytes.HasPrefix(l.data[l.pos:], []byte(">>")) {
+				l.pos += 2
+				return d, nil
+			}
+			key, err := l.object(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(name)
+			if !ok {
+				return nil, l.errorf("dictionary key is not a name")
+			}
+			value, err := l.object(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			d[string(k)] = value
+		}
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return nil, l.errorf("unterminated hex string")
+		}
+		s := str(l.data[l.pos : l.pos+end+1])
+		l.pos += end + 1
+		return s, nil
+	case c == '(':
+		start, nesting := l.pos, 0
+		for ; l.pos < len(l.data); l.pos++ {
+			switch l.data[l.pos] {
+			case '\\':
+				l.pos++
+			case '(':
+				nesting++
+			case ')':
+				nesting--
+				if nesting == 0 {
+					l.pos++
+					return str(l.data[start:l.pos]), nil
+				}
+			}
+		}
+		return nil, l.errorf("unterminated string")
+	case c == '[':
+		l.pos++
+		a := array{}
+		for {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == ']' {
+				l.pos++
+				return a, nil
+			}
+			item, err := l.object(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, item)
+		}
+	case isDelim(c):
+		return nil, l.errorf("unexpected %q", c)
+	}
+
+	tok := l.token()
+	switch tok {
+	case "true", "false", "null":
+		return keyword(tok), nil
+	}
+	if _, err := strconv.ParseFloat(tok, 64); err != nil {
+		return nil, l.errorf("unexpected token %q", tok)
+	}
+	// An integer may start a reference "num gen R".
+	if num, err := strconv.Atoi(tok); err == nil {
+		save := l.pos
+		if gen, err := strconv.Atoi(l.token()); err == nil && l.token() == "R" {
+			return ref{num, gen}, nil
+		}
+		l.pos = save
+	}
+	return number(tok), nil
+}
+
+// write serializes o.
+func write(b *bytes.Buffer, o object) {
+	switch v := o.(type) {
+	case name:
+		b.WriteByte('/')
+		b.WriteString(string(v))
+	case number:
+		b.WriteString(string(v))
+	case keyword:
+		b.WriteString(string(v))
+	case str:
+		b.Write(v)
+	case ref:
+		fmt.Fprintf(b, "%d %d R", v.num, v.gen)
+	case array:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			write(b, item)
+		}
+		b.WriteByte(']')
+	case dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString("<<")
+		for _, k := range keys {
+			b.WriteString(" /")
+			b.WriteString(k)
+			b.WriteByte(' ')
+			write(b, v[k])
+		}
+		b.WriteString(" >>")
+	case nil:
+		b.WriteString("null")
+	default:
+		panic(fmt.Sprintf("pdfstamp: cannot write %T", o))
+	}
+}
@@ -0,0 +1,305 @@
+// Package pdfstamp adds a line of text to the foot of every page of a PDF,
+// such as the note that a thesis passed normocontrol. The original file is
+// kept byte for byte and the stamp is appended as an incremental update, so
+// the earlier revision can still be recovered from the stamped file.
+package pdfstamp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+var (
+	// ErrEncrypted is returned for encrypted files, which cannot be changed
+	// without the password.
+	ErrEncrypted = errors.New("pdf: encrypted files cannot be stamped")
+	// ErrNoPages is returned when the page tree has no pages.
+	ErrNoPages = errors.New("pdf: no pages found")
+)
+
+// Style places the stamp: FontSize in points, Margin the distance of the
+// baseline from the left and bottom edges of the visible page in points.
+type Style struct {
+	FontSize float64
+	Margin   float64
+}
+
+// DefaultStyle is 8 pt text 1 cm from the left and 0.5 cm from the bottom.
+var DefaultStyle = Style{FontSize: 8, Margin: 14.17}
+
+// fontResource names the stamp's font in the page resources. Stamping a
+// stamped file again replaces it with an identical font.
+const fontResource = "NormoControlStamp"
+
+// maxPages bounds the page tree walked, against loops and bombs.
+const maxPages = 100000
+
+// a4 is the page size assumed when a page has no usable MediaBox.
+var a4 = []float64{0, 0, 595.28, 841.89}
+
+// page is a leaf of the page tree with the attributes it inherits.
+type page struct {
+	id        ref
+	dict      dict
+	resources object
+	mediaBox  []float64
+	cropBox   []float64
+	rotate    int
+}
+
+// Stamp returns pdf with text at the foot of every page. Text may contain
+// Latin and Cyrillic letters; other characters are replaced with '?'.
+func Stamp(pdf []byte, text string, style Style) ([]byte, error) {
+	d, err := parse(pdf)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := d.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+	root, ok := d.resolve(d.trailer["Root"]).(dict)
+	if !ok {
+		return nil, errors.New("pdf: document catalog not found")
+	}
+	var pages []page
+	if err := d.walkPages(root["Pages"], page{}, map[int]bool{}, &pages, 0); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, ErrNoPages
+	}
+
+	size, _ := d.trailer["Size"].(number)
+	u := &update{next: int(size.float()), offsets: map[int]int{}, gens: map[int]int{}}
+	u.out.Write(pdf)
+	if pdf[len(pdf)-1] != '\n' {
+		u.out.WriteByte('\n')
+	}
+
+	font := u.add(fontObject())
+	open := u.addStream([]byte("q\n"))
+	shown := encodeText(text)
+	stamps := map[string]ref{} // one stamp stream per page geometry
+	for _, p := range pages {
+		box := p.cropBox
+		if box == nil {
+			box = p.mediaBox
+		}
+		matrix := placement(box, p.rotate)
+		stamp, ok := stamps[matrix]
+		if !ok {
+			content := fmt.Sprintf("\nQ\nq\n%s cm\nBT\n/%s %s Tf\n0.3 0.3 0.3 rg\n%s %s Td\n<%X> Tj\nET\nQ\n",
+				matrix, fontResource, num(style.FontSize), num(style.Margin), num(style.Margin), shown)
+			stamp = u.addStream([]byte(content))
+			stamps[matrix] = stamp
+		}
+		d.stampPage(u, p, font, open, stamp)
+	}
+	return u.finish(d), nil
+}
+
+// walkPages collects the leaves of the page tree below node, passing the
+// inheritable attributes down.
+func (d *document) walkPages(node object, inherited page, visited map[int]bool, pages *[]page, depth int) error {
+	r, isRef := node.(ref)
+	if !isRef {
+		return errors.New("pdf: page tree node is not an indirect object")
+	}
+	if visited[r.num] || depth > 64 || len(*pages) >= maxPages {
+		return errors.New("pdf: malformed page tree")
+	}
+	visited[r.num] = true
+	n, ok := d.resolve(r).(dict)
+	if !ok {
+		return fmt.Errorf("pdf: page tree node %d not found", r.num)
+	}
+
+	if res, ok := n["Resources"]; ok {
+		inherited.resources = res
+	}
+	if box := d.rect(n["MediaBox"]); box != nil {
+		inherited.mediaBox = box
+	}
+	if box := d.rect(n["CropBox"]); box != nil {
+		inherited.cropBox = box
+	}
+	if rot, ok := d.resolve(n["Rotate"]).(number); ok {
+		inherited.rotate = ((int(rot.float())%360 + 360) % 360) / 90 * 90
+	}
+
+	kids, isNode := d.resolve(n["Kids"]).(array)
+	if n["Type"] == name("Page") || !isNode {
+		inherited.id, inherited.dict = r, n
+		*pages = append(*pages, inherited)
+		return nil
+	}
+	for _, kid := range kids {
+		if err := d.walkPages(kid, inherited, visited, pages, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rect returns the rectangle o as [llx lly urx ury], or nil.
+func (d *document) rect(o object) []float64 {
+	a, ok := d.resolve(o).(array)
+	if !ok || len(a) != 4 {
+		return nil
+	}
+	r := make([]float64, 4)
+	for i, v := range a {
+		n, ok := d.resolve(v).(number)
+		if !ok {
+			return nil
+		}
+		r[i] = n.float()
+	}
+	if r[0] > r[2] {
+		r[0], r[2] = r[2], r[0]
+	}
+	if r[1] > r[3] {
+		r[1], r[3] = r[3], r[1]
+	}
+	return r
+}
+
+// placement returns the matrix that puts the origin at the bottom left
+// corner of the page as it is shown, with the x axis along the bottom edge.
+func placement(box []float64, rotate int) string {
+	if box == nil {
+		box = a4
+	}
+	llx, lly, urx, ury := box[0], box[1], box[2], box[3]
+	switch rotate {
+	case 90:
+		return fmt.Sprintf("0 1 -1 0 %s %s", num(urx), num(lly))
+	case 180:
+		return fmt.Sprintf("-1 0 0 -1 %s %s", num(urx), num(ury))
+	case 270:
+		return fmt.Sprintf("0 -1 1 0 %s %s", num(llx), num(ury))
+	}
+	return fmt.Sprintf("1 0 0 1 %s %s", num(llx), num(lly))
+}
+
+// stampPage writes a new revision of the page that draws stamp after its
+// own content, which is wrapped in q/Q so its graphics state does not leak
+// into the stamp.
+func (d *document) stampPage(u *update, p page, font, open, stamp ref) {
+	pd := dict{}
+	for k, v := range p.dict {
+		pd[k] = v
+	}
+
+	resources := dict{}
+	if res, ok := d.resolve(p.resources).(dict); ok {
+		for k, v := range res {
+			resources[k] = v
+		}
+	}
+	fonts := dict{}
+	if f, ok := d.resolve(resources["Font"]).(dict); ok {
+		for k, v := range f {
+			fonts[k] = v
+		}
+	}
+	fonts[fontResource] = font
+	resources["Font"] = fonts
+	pd["Resources"] = resources
+
+	contents := array{open}
+	switch c := p.dict["Contents"].(type) {
+	case ref:
+		// Either a stream or an array of streams.
+		if a, ok := d.resolve(c).(array); ok {
+			contents = append(contents, a...)
+		} else {
+			contents = append(contents, c)
+		}
+	case array:
+		contents = append(contents, c...)
+	}
+	pd["Contents"] = append(contents, stamp)
+
+	var b bytes.Buffer
+	write(&b, pd)
+	u.replace(p.id, b.Bytes())
+}
+
+// update collects the objects of an incremental update.
+type update struct {
+	out     bytes.Buffer
+	next    int
+	offsets map[int]int
+	gens    map[int]int
+}
+
+// add appends a new object and returns a reference to it.
+func (u *update) add(body []byte) ref {
+	r := ref{u.next, 0}
+	u.next++
+	u.replace(r, body)
+	return r
+}
+
+func (u *update) addStream(data []byte) ref {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< /Length %d >>\nstream\n", len(data))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	return u.add(b.Bytes())
+}
+
+// replace writes object r, new or a new revision of an existing one.
+func (u *update) replace(r ref, body []byte) {
+	u.offsets[r.num] = u.out.Len()
+	u.gens[r.num] = r.gen
+	fmt.Fprintf(&u.out, "%d %d obj\n", r.num, r.gen)
+	u.out.Write(body)
+	u.out.WriteString("\nendobj\n")
+}
+
+// finish writes the cross-reference section and trailer of the update.
+func (u *update) finish(d *document) []byte {
+	nums := make([]int, 0, len(u.offsets))
+	for n := range u.offsets {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+
+	start := u.out.Len()
+	u.out.WriteString("xref\n")
+	for i := 0; i < len(nums); {
+		j := i + 1
+		for j < len(nums) && nums[j] == nums[j-1]+1 {
+			j++
+		}
+		fmt.Fprintf(&u.out, "%d %d\n", nums[i], j-i)
+		for _, n := range nums[i:j] {
+			fmt.Fprintf(&u.out, "%010d %05d n\r\n", u.offsets[n], u.gens[n])
+		}
+		i = j
+	}
+
+	trailer := dict{
+		"Size": number(strconv.Itoa(u.next)),
+		"Root": d.trailer["Root"],
+		"Prev": number(strconv.Itoa(d.startxref)),
+	}
+	for _, k := range []string{"Info", "ID"} {
+		if v, ok := d.trailer[k]; ok {
+			trailer[k] = v
+		}
+	}
+	u.out.WriteString("trailer\n")
+	write(&u.out, trailer)
+	fmt.Fprintf(&u.out, "\nstartxref\n%d\n%%%%EOF\n", start)
+	return u.out.Bytes()
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
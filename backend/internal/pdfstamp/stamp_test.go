@@ -0,0 +1,395 @@
+package pdfstamp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// streamObject returns the body of a stream object with data.
+func streamObject(entries string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", entries, len(data), data)
+}
+
+// classicPDF writes objects 1..n with the given bodies, a cross-reference
+// table and a trailer with the extra entries.
+func classicPDF(trailer string, bodies ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(bodies))
+	for i, body := range bodies {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f\r\n", len(bodies)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n\r\n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R %s>>\nstartxref\n%d\n%%%%EOF\n", len(bodies)+1, trailer, start)
+	return b.Bytes()
+}
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// xrefStreamPDF writes a PDF 1.5 file whose catalog (1) and page (3) sit in
+// an object stream (5), with a cross-reference stream (6) compressed with a
+// PNG Up predictor.
+func xrefStreamPDF() []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	offsets := map[int]int{}
+	obj := func(n int, body string) {
+		offsets[n] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", n, body)
+	}
+
+	obj(2, "<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	obj(4, streamObject("", []byte("BT /F1 12 Tf 72 720 Td (Body) Tj ET")))
+	catalog := "<< /Type /Catalog /Pages 2 0 R >>"
+	page := "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 842 595] /Contents 4 0 R /Resources << /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Courier >> >> >> >>"
+	header := fmt.Sprintf("1 0 3 %d ", len(catalog)+1)
+	packed := deflate([]byte(header + catalog + " " + page))
+	obj(5, streamObject(fmt.Sprintf("/Type /ObjStm /N 2 /First %d /Filter /FlateDecode", len(header)), packed))
+
+	offsets[6] = b.Len()
+	rows := [][]int{
+		{0, 0, 0xFFFF},
+		{2, 5, 0},
+		{1, offsets[2], 0},
+		{2, 5, 1},
+		{1, offsets[4], 0},
+		{1, offsets[5], 0},
+		{1, offsets[6], 0},
+	}
+	var raw []byte
+	prev := make([]byte, 7)
+	for _, r := range rows {
+		row := make([]byte, 7)
+		row[0] = byte(r[0])
+		binary.BigEndian.PutUint32(row[1:5], uint32(r[1]))
+		binary.BigEndian.PutUint16(row[5:7], uint16(r[2]))
+		raw = append(raw, 2) // Up
+		for i := range row {
+			raw = append(raw, row[i]-prev[i])
+		}
+		prev = row
+	}
+	fmt.Fprintf(&b, "6 0 obj\n%s\nendobj\n", streamObject(
+		"/Type /XRef /Size 7 /W [1 4 2] /Root 1 0 R /Filter /FlateDecode /DecodeParms << /Columns 7 /Predictor 12 >>", deflate(raw)))
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", offsets[6])
+	return b.Bytes()
+}
+
+func samplePDF() []byte {
+	return classicPDF("/Info 7 0 R ",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 595 842] /Resources << /Font << /F1 6 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Rotate 90 /Contents [5 0 R] >>",
+		streamObject("", []byte("BT /F1 12 Tf 72 720 Td (Hello) Tj ET")),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Times-Roman >>",
+		"<< /Title (Thesis) >>",
+	)
+}
+
+// checkUpdate parses the stamped file and checks that it is the original
+// followed by a well-formed incremental update adding added objects. It
+// returns the parsed stamped file.
+func checkUpdate(t *testing.T, original, stamped []byte, added int) *document {
+	t.Helper()
+	if !bytes.HasPrefix(stamped, original) {
+		t.Fatal("the original revision is not kept byte for byte")
+	}
+	before, err := parse(original)
+	if err != nil {
+		t.Fatalf("parse original: %v", err)
+	}
+	d, err := parse(stamped)
+	if err != nil {
+		t.Fatalf("parse stamped: %v", err)
+	}
+
+	if d.startxref < len(original) || !bytes.HasPrefix(stamped[d.startxref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the new cross-reference table", d.startxref)
+	}
+	if prev, _ := d.trailer["Prev"].(number); int(prev.float()) != before.startxref {
+		t.Fatalf("trailer /Prev = %v, want %d", d.trailer["Prev"], before.startxref)
+	}
+	if !reflect.DeepEqual(d.trailer["Root"], before.trailer["Root"]) {
+		t.Fatalf("trailer /Root = %v, want %v", d.trailer["Root"], before.trailer["Root"])
+	}
+	oldSize, _ := before.trailer["Size"].(number)
+	size, _ := d.trailer["Size"].(number)
+	if int(size.float()) != int(oldSize.float())+added {
+		t.Fatalf("trailer /Size = %v, want %v + %d", size, oldSize, added)
+	}
+
+	// Every object of the update is where its entry says.
+	for n, e := range d.xref {
+		if e.inStream || e.offset < len(original) {
+			continue
+		}
+		id, _, err := d.readObjectAt(e.offset)
+		if err != nil || id.num != n || id.gen != e.gen {
+			t.Fatalf("entry of object %d points at %v (%v)", n, id, err)
+		}
+	}
+	for n := int(oldSize.float()); n < int(size.float()); n++ {
+		if e, ok := d.xref[n]; !ok || e.inStream || e.offset < len(original) {
+			t.Fatalf("new object %d is not in the update: %+v", n, e)
+		}
+	}
+	return d
+}
+
+// stampedPage returns the contents of a stamped page and its stamp stream.
+func stampedPage(t *testing.T, d *document, p page) (array, string) {
+	t.Helper()
+	contents, ok := p.dict["Contents"].(array)
+	if !ok || len(contents) < 3 {
+		t.Fatalf("page %d contents = %v", p.id.num, p.dict["Contents"])
+	}
+	open, _ := d.resolve(contents[0]).(stream)
+	if string(open.data) != "q\n" {
+		t.Fatalf("page %d contents start with %q, want q", p.id.num, open.data)
+	}
+	stamp, _ := d.resolve(contents[len(contents)-1]).(stream)
+	res, _ := d.resolve(p.dict["Resources"]).(dict)
+	fonts, _ := d.resolve(res["Font"]).(dict)
+	font, _ := d.resolve(fonts[fontResource]).(dict)
+	if font["BaseFont"] != name("Helvetica") {
+		t.Fatalf("page %d stamp font = %v", p.id.num, fonts[fontResource])
+	}
+	return contents, string(stamp.data)
+}
+
+func TestStampClassicXref(t *testing.T) {
+	original := samplePDF()
+	stamped, err := Stamp(original, "Нормоконтроль пройден", DefaultStyle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The font, the opening q and a stamp for each of the two page rotations.
+	d := checkUpdate(t, original, stamped, 4)
+	if d.trailer["Info"] != (ref{7, 0}) {
+		t.Fatalf("trailer /Info = %v", d.trailer["Info"])
+	}
+
+	root, _ := d.resolve(d.trailer["Root"]).(dict)
+	var pages []page
+	if err := d.walkPages(root["Pages"], page{}, map[int]bool{}, &pages, 0); err != nil || len(pages) != 2 {
+		t.Fatalf("pages = %d, %v", len(pages), err)
+	}
+	text := fmt.Sprintf("<%X> Tj", encodeText("Нормоконтроль пройден"))
+	for i, want := range []string{"1 0 0 1 0 0 cm", "0 1 -1 0 595 0 cm"} {
+		p := pages[i]
+		if d.xref[p.id.num].offset < len(original) {
+			t.Fatalf("page %d was not rewritten in the update", p.id.num)
+		}
+		contents, stamp := stampedPage(t, d, p)
+		if len(contents) != 3 || contents[1] != (ref{5, 0}) {
+			t.Fatalf("page %d contents = %v, want the page's own stream wrapped", p.id.num, contents)
+		}
+		if !strings.Contains(stamp, want) || !strings.Contains(stamp, text) || !strings.Contains(stamp, "/"+fontResource+" 8 Tf") {
+			t.Fatalf("page %d stamp = %q", p.id.num, stamp)
+		}
+		// The inherited resources are kept next to the stamp font.
+		res, _ := d.resolve(p.dict["Resources"]).(dict)
+		fonts, _ := d.resolve(res["Font"]).(dict)
+		if fonts["F1"] != (ref{6, 0}) {
+			t.Fatalf("page %d fonts = %v", p.id.num, fonts)
+		}
+	}
+
+	// Objects the update does not touch are read from the first revision.
+	if f, _ := d.load(6).(dict); f["BaseFont"] != name("Times-Roman") {
+		t.Fatalf("object 6 = %v", d.load(6))
+	}
+}
+
+func TestStampXrefStream(t *testing.T) {
+	original := xrefStreamPDF()
+	stamped, err := Stamp(original, "Проверено", Style{FontSize: 10, Margin: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := checkUpdate(t, original, stamped, 3)
+
+	// The catalog is still read from the object stream of the first revision.
+	if e := d.xref[1]; !e.inStream || e.stream != 5 {
+		t.Fatalf("catalog entry = %+v, want it in object stream 5", e)
+	}
+	root, _ := d.resolve(d.trailer["Root"]).(dict)
+	var pages []page
+	if err := d.walkPages(root["Pages"], page{}, map[int]bool{}, &pages, 0); err != nil || len(pages) != 1 {
+		t.Fatalf("pages = %d, %v", len(pages), err)
+	}
+	p := pages[0]
+	if e := d.xref[3]; e.inStream || e.offset < len(original) {
+		t.Fatalf("page entry = %+v, want the new revision", e)
+	}
+	contents, stamp := stampedPage(t, d, p)
+	if len(contents) != 3 || contents[1] != (ref{4, 0}) {
+		t.Fatalf("contents = %v", contents)
+	}
+	if !strings.Contains(stamp, "1 0 0 1 0 0 cm") || !strings.Contains(stamp, "/"+fontResource+" 10 Tf") || !strings.Contains(stamp, "20 20 Td") {
+		t.Fatalf("stamp = %q", stamp)
+	}
+	res, _ := d.resolve(p.dict["Resources"]).(dict)
+	fonts, _ := d.resolve(res["Font"]).(dict)
+	if f1, _ := fonts["F1"].(dict); f1["BaseFont"] != name("Courier") {
+		t.Fatalf("fonts = %v", fonts)
+	}
+}
+
+func TestStampStampedFileChainsRevisions(t *testing.T) {
+	original := samplePDF()
+	once, err := Stamp(original, "one", DefaultStyle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	twice, err := Stamp(once, "two", DefaultStyle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := checkUpdate(t, once, twice, 4)
+	first, _ := parse(once)
+	if prev, _ := d.trailer["Prev"].(number); int(prev.float()) != first.startxref {
+		t.Fatalf("/Prev = %v, want the first stamp's table at %d", prev, first.startxref)
+	}
+
+	p := page{id: ref{3, 0}}
+	p.dict, _ = d.load(3).(dict)
+	p.resources = p.dict["Resources"]
+	contents, stamp := stampedPage(t, d, p)
+	// q₂ q₁ own Q₁stamp Q₂stamp
+	if len(contents) != 5 || contents[2] != (ref{5, 0}) || !strings.Contains(stamp, "<74776F> Tj") {
+		t.Fatalf("contents = %v, stamp = %q", contents, stamp)
+	}
+}
+
+func TestStampRejects(t *testing.T) {
+	valid := samplePDF()
+	start, err := parse(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		pdf  []byte
+		want error
+	}{
+		{"encrypted", classicPDF("/Encrypt 2 0 R ", "<< /Type /Catalog /Pages 2 0 R >>", "<< /Filter /Standard >>"), ErrEncrypted},
+		{"no pages", classicPDF("", "<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [] /Count 0 >>"), ErrNoPages},
+		{"root without a page tree", classicPDF("", "<< /Type /Pages /Kids [] /Count 0 >>"), nil},
+		{"no startxref", []byte("%PDF-1.4\n1 0 obj\n<< >>\nendobj\n"), nil},
+		{"startxref out of range", bytes.Replace(valid, []byte(fmt.Sprintf("startxref\n%d", start.startxref)), []byte("startxref\n99999999"), 1), nil},
+		{"xref loop", classicPDF(fmt.Sprintf("/Prev %d ", start.startxref),
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /MediaBox [0 0 595 842] /Resources << /Font << /F1 6 0 R >> >> >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+			"<< /Type /Page /Parent 2 0 R /Rotate 90 /Contents [5 0 R] >>",
+			streamObject("", []byte("BT /F1 12 Tf 72 720 Td (Hello) Tj ET")),
+			"<< /Type /Font /Subtype /Type1 /BaseFont /Times-Roman >>",
+			"<< /Title (Thesis) >>",
+		), nil},
+		{"page tree loop", classicPDF("", "<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [2 0 R] /Count 1 >>"), nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Stamp(tc.pdf, "x", DefaultStyle)
+			if err == nil || (tc.want != nil && !errors.Is(err, tc.want)) {
+				t.Fatalf("Stamp error = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestObjectsAreWrittenBackAsRead(t *testing.T) {
+	for _, src := range []string{
+		"<< /A [1 0 R (a\\)b (c)) <4142>] /B /N#20x /C true /D null /E -1.50 >>",
+		"[]",
+		"<< >>",
+		"(nested (parens) and \\( escapes)",
+		"[0 0 595.28 841.89]",
+	} {
+		l := &lexer{data: []byte(src)}
+		o, err := l.object(0)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		var b bytes.Buffer
+		write(&b, o)
+		if b.String() != src {
+			t.Fatalf("write(%s) = %s", src, b.String())
+		}
+	}
+	for _, bad := range []string{"<< /A 1", "[1 2", "(open", "<< /A >>"} {
+		l := &lexer{data: []byte(bad)}
+		if _, err := l.object(0); err == nil {
+			t.Fatalf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestUnpredictPNG(t *testing.T) {
+	raw := [][]byte{{10, 20, 30, 40}, {11, 19, 35, 250}, {0, 255, 128, 7}}
+	for kind := byte(0); kind <= 4; kind++ {
+		var encoded []byte
+		prev := make([]byte, 4)
+		for _, row := range raw {
+			encoded = append(encoded, kind)
+			for i, v := range row {
+				var left, upLeft byte
+				if i > 0 {
+					left, upLeft = row[i-1], prev[i-1]
+				}
+				up := prev[i]
+				var p byte
+				switch kind {
+				case 1:
+					p = left
+				case 2:
+					p = up
+				case 3:
+					p = byte((int(left) + int(up)) / 2)
+				case 4:
+					p = paeth(left, up, upLeft)
+				}
+				encoded = append(encoded, v-p)
+			}
+			prev = row
+		}
+		got, err := unpredictPNG(encoded, 4)
+		if err != nil || !bytes.Equal(got, bytes.Join(raw, nil)) {
+			t.Fatalf("filter %d: %v, %v", kind, got, err)
+		}
+	}
+	if _, err := unpredictPNG([]byte{5, 1, 2}, 2); err == nil {
+		t.Fatal("expected an error for an unknown filter")
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+	got := encodeText("Ёж №1 «ok» — ✓")
+	want := []byte{0xA8, 0xE6, ' ', 0xB9, '1', ' ', 0xAB, 'o', 'k', 0xBB, ' ', 0x97, ' ', '?'}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("encodeText = % X, want % X", got, want)
+	}
+	for code, glyph := range map[byte]string{0xC0: "afii10017", 0xC6: "afii10024", 0xDF: "afii10049", 0xE0: "afii10065", 0xFF: "afii10097"} {
+		if g := glyphName(code); g != glyph {
+			t.Fatalf("glyphName(%X) = %s, want %s", code, g, glyph)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package pdfstamp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxDecoded bounds a decoded cross-reference or object stream.
+const maxDecoded = 64 << 20
+
+// xrefEntry locates an object: at offset in the file, or as entry index of
+// the object stream stream.
+type xrefEntry struct {
+	offset   int
+	gen      int
+	inStream bool
+	stream   int
+	index    int
+}
+
+// document is a parsed PDF file: its cross-reference table and trailer.
+type document struct {
+	data      []byte
+	xref      map[int]xrefEntry
+	trailer   dict
+	startxref int
+	objstms   map[int]*objectStream
+}
+
+type objectStream struct {
+	data    []byte
+	offsets map[int]int // object number → offset in data
+}
+
+func parse(data []byte) (*document, error) {
+	tail := data
+	if len(tail) > 2048 {
+		tail = tail[len(tail)-2048:]
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return nil, errors.New("pdf: startxref not found")
+	}
+	l := &lexer{data: tail, pos: i + len("startxref")}
+	start, err := strconv.Atoi(l.token())
+	if err != nil || start < 0 || start >= len(data) {
+		return nil, errors.New("pdf: invalid startxref")
+	}
+
+	d := &document{data: data, xref: map[int]xrefEntry{}, startxref: start, objstms: map[int]*objectStream{}}
+	seen := map[int]bool{}
+	for offset := start; ; {
+		if seen[offset] || len(seen) > 1000 {
+			return nil, errors.New("pdf: cross-reference sections form a loop")
+		}
+		seen[offset] = true
+		trailer, err := d.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if d.trailer == nil {
+			d.trailer = trailer
+		}
+		// A hybrid file keeps the entries of compressed objects in a stream.
+		if stm, ok := trailer["XRefStm"].(number); ok {
+			if _, err := d.readXref(int(stm.float())); err != nil {
+				return nil, err
+			}
+		}
+		prev, ok := trailer["Prev"].(number)
+		if !ok {
+			break
+		}
+		offset = int(prev.float())
+	}
+	return d, nil
+}
+
+// readXref reads the cross-reference section at offset, a table or a stream,
+// keeping entries already known from a newer section.
+func (d *document) readXref(offset int) (dict, error) {
+	if offset < 0 || offset >= len(d.data) {
+		return nil, errors.New("pdf: cross-reference offset out of range")
+	}
+	l := &lexer{data: d.data, pos: offset}
+	if l.token() != "xref" {
+		return d.readXrefStream(offset)
+	}
+	for {
+		save := l.pos
+		tok := l.token()
+		if tok == "trailer" {
+			break
+		}
+		first, err1 := strconv.Atoi(tok)
+		count, err2 := strconv.Atoi(l.token())
+		if err1 != nil || err2 != nil || count < 0 {
+			l.pos = save
+			return nil, l.errorf("malformed cross-reference table")
+		}
+		for n := first; n < first+count; n++ {
+			off, err1 := strconv.Atoi(l.token())
+			gen, err2 := strconv.Atoi(l.token())
+			kind := l.token()
+			if err1 != nil || err2 != nil || (kind != "n" && kind != "f") {
+				return nil, l.errorf("malformed cross-reference entry")
+			}
+			if _, known := d.xref[n]; !known && kind == "n" {
+				d.xref[n] = xrefEntry{offset: off, gen: gen}
+			} else if !known {
+				d.xref[n] = xrefEntry{offset: -1}
+			}
+		}
+	}
+	trailer, err := l.object(0)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := trailer.(dict)
+	if !ok {
+		return nil, l.errorf("trailer is not a dictionary")
+	}
+	return t, nil
+}
+
+func (d *document) readXrefStream(offset int) (dict, error) {
+	_, o, err := d.readObjectAt(offset)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := o.(stream)
+	if !ok || s.dict["Type"] != name("XRef") {
+		return nil, errors.New("pdf: cross-reference section not found")
+	}
+	data, err := decode(s)
+	if err != nil {
+		return nil, err
+	}
+	w, _ := s.dict["W"].(array)
+	if len(w) != 3 {
+		return nil, errors.New("pdf: invalid /W of cross-reference stream")
+	}
+	var widths [3]int
+	row := 0
+	for i, v := range w {
+		n, _ := v.(number)
+		widths[i] = int(n.float())
+		if widths[i] < 0 || widths[i] > 8 {
+			return nil, errors.New("pdf: invalid /W of cross-reference stream")
+		}
+		row += widths[i]
+	}
+	if row == 0 {
+		return nil, errors.New("pdf: invalid /W of cross-reference stream")
+	}
+	index, _ := s.dict["Index"].(array)
+	if index == nil {
+		size, _ := s.dict["Size"].(number)
+		index = array{number("0"), size}
+	}
+
+	field := func(b []byte, def int) int {
+		if len(b) == 0 {
+			return def
+		}
+		n := 0
+		for _, c := range b {
+			n = n<<8 | int(c)
+		}
+		return n
+	}
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		first, _ := index[i].(number)
+		count, _ := index[i+1].(number)
+		for n := int(first.float()); n < int(first.float()+count.float()); n++ {
+			if pos+row > len(data) {
+				return nil, errors.New("pdf: cross-reference stream too short")
+			}
+			b := data[pos : pos+row]
+			pos += row
+			kind := field(b[:widths[0]], 1)
+			f2 := field(b[widths[0]:widths[0]+widths[1]], 0)
+			f3 := field(b[widths[0]+widths[1]:], 0)
+			if _, known := d.xref[n]; known {
+				continue
+			}
+			switch kind {
+			case 1:
+				d.xref[n] = xrefEntry{offset: f2, gen: f3}
+			case 2:
+				d.xref[n] = xrefEntry{inStream: true, stream: f2, index: f3}
+			default:
+				d.xref[n] = xrefEntry{offset: -1}
+			}
+		}
+	}
+	return s.dict, nil
+}
+
+// readObjectAt reads the indirect object "num gen obj ... endobj" at offset.
+func (d *document) readObjectAt(offset int) (ref, object, error) {
+	l := &lexer{data: d.data, pos: offset}
+	num, err1 := strconv.Atoi(l.token())
+	gen, err2 := strconv.Atoi(l.token())
+	if err1 != nil || err2 != nil || l.token() != "obj" {
+		return ref{}, nil, l.errorf("object not found")
+	}
+	o, err := l.object(0)
+	if err != nil {
+		return ref{}, nil, err
+	}
+	id := ref{num, gen}
+	sd, isDict := o.(dict)
+	if !isDict {
+		return id, o, nil
+	}
+	save := l.pos
+	if l.token() != "stream" {
+		l.pos = save
+		return id, o, nil
+	}
+	if l.pos < len(d.data) && d.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(d.data) && d.data[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+	length := -1
+	switch v := sd["Length"].(type) {
+	case number:
+		length = int(v.float())
+	case ref:
+		if v.num != num {
+			if n, ok := d.resolve(v).(number); ok {
+				length = int(n.float())
+			}
+		}
+	}
+	if length < 0 || start+length > len(d.data) || !bytes.Contains(d.data[start+length:min(len(d.data), start+length+32)], []byte("endstream")) {
+		// A wrong /Length is common enough to fall back on the keyword.
+		end := bytes.Index(d.data[start:], []byte("endstream"))
+		if end < 0 {
+			return id, nil, l.errorf("unterminated stream")
+		}
+		length = end
+	}
+	return id, stream{dict: sd, data: d.data[start : start+length]}, nil
+}
+
+// resolve returns the object o refers to, or o itself.
+func (d *document) resolve(o object) object {
+	for i := 0; i < 32; i++ {
+		r, ok := o.(ref)
+		if !ok {
+			return o
+		}
+		o = d.load(r.num)
+	}
+	return nil
+}
+
+// load returns object num, or nil when it is missing or unreadable.
+func (d *document) load(num int) object {
+	e, ok := d.xref[num]
+	if !ok || (!e.inStream && e.offset < 0) {
+		return nil
+	}
+	if e.inStream {
+		s, err := d.objectStream(e.stream)
+		if err != nil {
+			return nil
+		}
+		off, ok := s.offsets[num]
+		if !ok {
+			return nil
+		}
+		l := &lexer{data: s.data, pos: off}
+		o, err := l.object(0)
+		if err != nil {
+			return nil
+		}
+		return o
+	}
+	id, o, err := d.readObjectAt(e.offset)
+	if err != nil || id.num != num {
+		return nil
+	}
+	return o
+}
+
+func (d *document) objectStream(num int) (*objectStream, error) {
+	if s, ok := d.objstms[num]; ok {
+		if s == nil {
+			return nil, fmt.Errorf("pdf: object stream %d refers to itself", num)
+		}
+		return s, nil
+	}
+	d.objstms[num] = nil // guards against a stream listed inside itself
+	e, ok := d.xref[num]
+	if !ok || e.inStream || e.offset < 0 {
+		return nil, fmt.Errorf("pdf: object stream %d not found", num)
+	}
+	_, o, err := d.readObjectAt(e.offset)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := o.(stream)
+	if !ok {
+		return nil, fmt.Errorf("pdf: object %d is not a stream", num)
+	}
+	data, err := decode(s)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := s.dict["N"].(number)
+	first, _ := s.dict["First"].(number)
+	l := &lexer{data: data}
+	objs := &objectStream{data: data, offsets: map[int]int{}}
+	for i := 0; i < int(n.float()); i++ {
+		objNum, err1 := strconv.Atoi(l.token())
+		off, err2 := strconv.Atoi(l.token())
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("pdf: malformed object stream %d", num)
+		}
+		objs.offsets[objNum] = int(first.float()) + off
+	}
+	d.objstms[num] = objs
+	return objs, nil
+}
+
+// decode returns the decoded data of s. Only FlateDecode, with or without a
+// PNG predictor, is needed for cross-reference and object streams.
+func decode(s stream) ([]byte, error) {
+	filter := s.dict["Filter"]
+	params, _ := s.dict["DecodeParms"].(dict)
+	if a, ok := filter.(array); ok {
+		if len(a) > 1 {
+			return nil, errors.New("pdf: chained stream filters are not supported")
+		}
+		if len(a) == 1 {
+			filter = a[0]
+		} else {
+			filter = nil
+		}
+		if p, ok := s.dict["DecodeParms"].(array); ok && len(p) == 1 {
+			params, _ = p[0].(dict)
+		}
+	}
+	switch filter {
+	case nil:
+		return s.data, nil
+	case name("FlateDecode"):
+	default:
+		return nil, fmt.Errorf("pdf: stream filter %v is not supported", filter)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(s.data))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(zr, maxDecoded+1))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	if len(data) > maxDecoded {
+		return nil, errors.New("pdf: stream too large")
+	}
+	predictor, _ := params["Predictor"].(number)
+	if predictor.float() < 10 {
+		return data, nil
+	}
+	columns := 1
+	if c, ok := params["Columns"].(number); ok {
+		columns = int(c.float())
+	}
+	return unpredictPNG(data, columns)
+}
+
+// unpredictPNG reverses the PNG row filters of byte-sized samples.
+func unpredictPNG(data []byte, columns int) ([]byte, error) {
+	if columns <= 0 {
+		return nil, errors.New("pdf: invalid predictor columns")
+	}
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, columns)
+	for len(data) > columns {
+		kind, row := data[0], append([]byte(nil), data[1:columns+1]...)
+		data = data[columns+1:]
+		for i := range row {
+			var left, upLeft byte
+			if i > 0 {
+				left, upLeft = row[i-1], prev[i-1]
+			}
+			up := prev[i]
+			switch kind {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, errors.New("pdf: unknown PNG predictor")
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,312 @@
+// Package redis is a small client for the Redis protocol (RESP2). It covers
+// what replicas of the service share through Redis: counters evaluated by
+// scripts, locks and cached blobs.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNil is returned for a missing key.
+var ErrNil = errors.New("redis: nil")
+
+// Error is an error reply of the server, such as a script error.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+const (
+	maxIdleConns   = 8
+	defaultTimeout = 3 * time.Second
+)
+
+// Client sends commands to one Redis server over a small pool of connections.
+// It is safe for concurrent use.
+type Client struct {
+	addr     string
+	username string
+	password string
+	db       int
+	timeout  time.Duration
+	idle     chan *conn
+	dial     func(ctx context.Context) (net.Conn, error)
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+var defaultClient *Client
+
+// Init configures the process-wide client from REDIS_URL. Without it Default
+// returns nil and all shared state stays in memory.
+func Init() {
+	raw := strings.TrimSpace(os.Getenv("REDIS_URL"))
+	if raw == "" {
+		return
+	}
+	c, err := NewClient(raw)
+	if err != nil {
+		log.Fatalf("Invalid REDIS_URL: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	if _, err := c.Do(ctx, "PING"); err != nil {
+		log.Printf("Redis is not reachable yet: %v", err)
+	}
+	defaultClient = c
+	log.Printf("Redis: %s, database %d", c.addr, c.db)
+}
+
+// Default returns the client configured by Init, or nil.
+func Default() *Client {
+	return defaultClient
+}
+
+// NewClient parses a URL of the form redis://[[user]:password@]host[:port][/db].
+func NewClient(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("unsupported scheme %q, expected redis://", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+	c := &Client{
+		addr:    u.Host,
+		timeout: defaultTimeout,
+		idle:    make(chan *conn, maxIdleConns),
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+		if c.password == "" {
+			// redis://secret@host is read as a password, as redis-cli does.
+			c.username, c.password = "", c.username
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("invalid database %q", db)
+		}
+	}
+	c.dial = func(ctx context.Context) (net.Conn, error) {
+		dialer := net.Dialer{Timeout: c.timeout}
+		return dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	return c, nil
+}
+
+// Do sends one command and returns its reply: a string for status replies,
+// int64 for integers, []byte for bulk strings, []interface{} for arrays.
+// A nil reply is returned as ErrNil, an error reply as Error.
+func (c *Client) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = cn.SetDeadline(deadline)
+
+	reply, err := cn.roundTrip(args)
+	var replyErr Error
+	if err != nil && !errors.Is(err, ErrNil) && !errors.As(err, &replyErr) {
+		// The connection state is unknown after a network error.
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Get returns the value of key, or ErrNil.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.Do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply %T to GET", reply)
+	}
+	return value, nil
+}
+
+// Set stores value under key for ttl; a zero ttl keeps it without expiry.
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []interface{}{"SET", key, value}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// SetNX stores value under key for ttl unless the key exists, and reports
+// whether it did.
+func (c *Client) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	_, err := c.Do(ctx, "SET", key, value, "NX", "PX", ttl.Milliseconds())
+	if errors.Is(err, ErrNil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Eval runs a Lua script with the given keys and arguments.
+func (c *Client) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	cmd := make([]interface{}, 0, 3+len(keys)+len(args))
+	cmd = append(cmd, "EVAL", script, len(keys))
+	for _, k := range keys {
+		cmd = append(cmd, k)
+	}
+	return c.Do(ctx, append(cmd, args...)...)
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+	nc, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	_ = cn.SetDeadline(time.Now().Add(c.timeout))
+	if c.password != "" {
+		auth := []interface{}{"AUTH", c.password}
+		if c.username != "" {
+			auth = []interface{}{"AUTH", c.username, c.password}
+		}
+		if _, err := cn.roundTrip(auth); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.roundTrip([]interface{}{"SELECT", c.db}); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+func (cn *conn) roundTrip(args []interface{}) (interface{}, error) {
+	if _, err := cn.Write(encodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return readReply(cn.r)
+}
+
+// encodeCommand writes args as a RESP array of bulk strings.
+func encodeCommand(args []interface{}) []byte {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case []byte:
+			b = v
+		case string:
+			b = []byte(v)
+		case int:
+			b = strconv.AppendInt(nil, int64(v), 10)
+		case int64:
+			b = strconv.AppendInt(nil, v, 10)
+		case float64:
+			b = strconv.AppendFloat(nil, v, 'f', -1, 64)
+		default:
+			b = []byte(fmt.Sprint(v))
+		}
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(b)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, b...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, ErrNil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readReply(r)
+			var replyErr Error
+			if err != nil && !errors.Is(err, ErrNil) && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
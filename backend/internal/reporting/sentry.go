@@ -0,0 +1,152 @@
+package reporting
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Reporter sends crash reports to a Sentry-compatible server (Sentry or
+// GlitchTip) through the store API. A nil Reporter drops all reports.
+type Reporter struct {
+	storeURL    string
+	publicKey   string
+	environment string
+	client      *http.Client
+}
+
+var defaultReporter *Reporter
+
+// Init configures the process-wide reporter from SENTRY_DSN and
+// SENTRY_ENVIRONMENT. Reporting stays disabled when no DSN is set.
+func Init() {
+	dsn := strings.TrimSpace(os.Getenv("SENTRY_DSN"))
+	if dsn == "" {
+		return
+	}
+	r, err := NewReporter(dsn, os.Getenv("SENTRY_ENVIRONMENT"))
+	if err != nil {
+		log.Printf("Error reporting disabled: %v", err)
+		return
+	}
+	defaultReporter = r
+	log.Println("Error reporting enabled")
+}
+
+// NewReporter parses a DSN of the form https://<key>@<host>/<project>.
+func NewReporter(dsn string, environment string) (*Reporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %v", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("invalid DSN: missing public key")
+	}
+	project := strings.Trim(u.Path, "/")
+	if project == "" {
+		return nil, fmt.Errorf("invalid DSN: missing project id")
+	}
+	prefix := ""
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+	if environment == "" {
+		environment = "production"
+	}
+
+	return &Reporter{
+		storeURL:    fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project),
+		publicKey:   u.User.Username(),
+		environment: environment,
+		client:      &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Environment string            `json:"environment"`
+	Message     string            `json:"message"`
+	Exception   *exceptionList    `json:"exception,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+type exceptionList struct {
+	Values []exception `json:"values"`
+}
+
+type exception struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// CapturePanic reports a recovered panic with its stack and context tags
+// (e.g. document_id, rule, stage). It does not block the caller.
+func CapturePanic(recovered interface{}, stack []byte, tags map[string]string) {
+	defaultReporter.capture("panic", fmt.Sprint(recovered), stack, tags)
+}
+
+// CaptureError reports a non-panic failure such as a check timeout.
+func CaptureError(err error, tags map[string]string) {
+	defaultReporter.capture("error", err.Error(), nil, tags)
+}
+
+func (r *Reporter) capture(kind string, message string, stack []byte, tags map[string]string) {
+	if r == nil {
+		return
+	}
+	ev := event{
+		EventID:     newEventID(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Level:       "error",
+		Platform:    "go",
+		Environment: r.environment,
+		Message:     message,
+		Exception:   &exceptionList{Values: []exception{{Type: kind, Value: message}}},
+		Tags:        tags,
+	}
+	if len(stack) > 0 {
+		ev.Extra = map[string]string{"stack": string(stack)}
+	}
+	go r.send(ev)
+}
+
+func (r *Reporter) send(ev event) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", r.storeURL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=academic-check-sys/1.0, sentry_key=%s", r.publicKey))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		log.Printf("Error reporting failed: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Error reporting failed: status %d", resp.StatusCode)
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,295 @@
+// Package server assembles the HTTP API shared by the server and desktop builds.
+package server
+
+import (
+	"github.com/Ullyminat/NormoControl/backend/internal/auth"
+	"github.com/Ullyminat/NormoControl/backend/internal/convert"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"github.com/Ullyminat/NormoControl/backend/internal/handlers"
+	"github.com/Ullyminat/NormoControl/backend/internal/middleware"
+	"github.com/Ullyminat/NormoControl/backend/internal/settings"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/time/rate"
+)
+
+// newRateLimiter returns the limiter named name with the rate and burst of
+// the rate_limit.<name>_rps and rate_limit.<name>_burst settings.
+func newRateLimiter(name string) middleware.Limiter {
+	limit := func() (rate.Limit, int) {
+		return rate.Limit(settings.Float("rate_limit." + name + "_rps")), settings.Int("rate_limit." + name + "_burst")
+	}
+	r, b := limit()
+	limiter := middleware.NewRateLimiter(name, r, b)
+	settings.Watch(func() { limiter.SetLimit(limit()) })
+	return limiter
+}
+
+// NewRouter builds the Gin engine with all API routes.
+func NewRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(middleware.AccessLog())
+	// Errors are answered in the language of Accept-Language, the panics
+	// Recovery turns into errors included.
+	r.Use(middleware.LocalizeErrors(func() string { return settings.String("api.default_language") }))
+	r.Use(middleware.Recovery())
+	// Increase Max Multipart Memory for uploads
+	r.MaxMultipartMemory = 100 << 20 // 100 MiB
+
+	// Initialize Rate Limiters from the rate_limit.* settings, resized when
+	// an admin changes them.
+	// Global: 50 req/sec, burst of 100
+	globalLimiter := newRateLimiter("global")
+	// Auth routes (Login/Register): 2 req/sec, burst of 5 (Anti-Bruteforce)
+	authLimiter := newRateLimiter("auth")
+	// AI verification is expensive: 6 req/min per IP with a small burst.
+	aiLimiter := newRateLimiter("ai")
+
+	// Apply Global Rate Limiting
+	r.Use(middleware.RateLimitMiddleware(globalLimiter))
+
+	// Security Headers & CORS Middleware
+	r.Use(func(c *gin.Context) {
+		allowedOrigin := os.Getenv("ALLOWED_ORIGIN")
+		if allowedOrigin == "" {
+			allowedOrigin = "http://localhost:5173" // Default fail-safe
+		}
+
+		origin := c.Request.Header.Get("Origin")
+
+		// STRICT CORS: Only allow the exact origin specified, no dynamic reflection
+		if origin == allowedOrigin {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+
+		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+
+		// Security Headers (OWASP Recommended)
+		c.Writer.Header().Set("X-Content-Type-Options", "nosniff")
+		c.Writer.Header().Set("X-Frame-Options", "DENY")
+		c.Writer.Header().Set("X-XSS-Protection", "1; mode=block")
+		c.Writer.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	})
+
+	api := r.Group("/api")
+	{
+		// Serve Static Uploads (for PDFs)
+		api.Static("/uploads", "./uploads")
+
+		// Verification codes printed on stamped PDFs can be checked without an account
+		api.GET("/verify/:code", handlers.VerifyStamp)
+
+		authGroup := api.Group("/auth")
+		authGroup.Use(middleware.RateLimitMiddleware(authLimiter)) // Strict rate limit for auth
+		{
+			authGroup.POST("/register", auth.Register)
+			authGroup.POST("/login", auth.Login)
+			authGroup.POST("/logout", auth.Logout)
+
+			// Secured Auth Routes
+			authGroup.GET("/me", auth.AuthMiddleware(), auth.Me)
+			authGroup.POST("/impersonation/stop", auth.AuthMiddleware(), auth.StopImpersonation)
+		}
+
+		// Secured Routes (Require Login)
+		secured := api.Group("/")
+		secured.Use(auth.AuthMiddleware(), auth.ReadOnlyImpersonation())
+		{
+			// Student / Shared Routes
+			secured.GET("/standards", handlers.GetStandards)
+			secured.GET("/branding", handlers.GetBranding)
+			secured.GET("/learning-resources", handlers.GetLearningResources)
+			secured.GET("/leaderboard", handlers.GetLeaderboard)
+			secured.GET("/achievements", handlers.GetAchievements)
+			secured.GET("/assignments", handlers.GetAssignments)
+			secured.GET("/terms", handlers.GetTerms)
+			secured.GET("/history", handlers.GetHistory)
+			secured.GET("/history/compare", handlers.CompareHistory)
+			secured.GET("/history/:id", handlers.GetHistoryDetail)
+			secured.GET("/history/:id/report", handlers.GetCheckReport)
+			secured.GET("/history/:id/annotated", handlers.GetAnnotatedDocument)
+			secured.GET("/history/:id/stamped", handlers.GetStampedPDF)
+			secured.GET("/notifications", handlers.GetNotifications)
+			secured.PUT("/notifications/:id/read", handlers.MarkNotificationRead)
+
+			// Checking Routes (Uploads and changes to own checks; supervisors only read)
+			checkRoutes := secured.Group("/")
+			checkRoutes.Use(auth.RequireRole("student", "teacher", "admin"))
+			{
+				checkRoutes.POST("/check", handlers.UploadAndCheck)
+				checkRoutes.POST("/check/:id/autofix", handlers.AutoFixCheck)
+				checkRoutes.POST("/history/:id/recheck", handlers.RecheckResult)
+				checkRoutes.PATCH("/history/:id/metadata", handlers.UpdateCheckMetadata)
+				checkRoutes.POST("/history/:id/violations/:vid/false-positive", handlers.ReportFalsePositive)
+				checkRoutes.POST("/documents/:id/retry", handlers.RetryDocument)
+
+				// AI Verification
+				checkRoutes.POST("/ai/verify/:id", middleware.RateLimitMiddleware(aiLimiter), handlers.VerifyViolationWithAI)
+				checkRoutes.POST("/ai/feedback/:id", middleware.RateLimitMiddleware(aiLimiter), handlers.GenerateResultFeedback)
+			}
+
+			// Teacher & Admin Routes (Mutating Standards & Teacher History)
+			teacherRoutes := secured.Group("/")
+			teacherRoutes.Use(auth.RequireRole("teacher", "admin"))
+			{
+				teacherRoutes.POST("/standards", handlers.CreateStandard)
+				teacherRoutes.PUT("/standards/:id", handlers.UpdateStandard)
+				teacherRoutes.DELETE("/standards/:id", handlers.DeleteStandard)
+				teacherRoutes.GET("/standards/:id/versions", handlers.GetStandardVersions)
+				teacherRoutes.POST("/standards/:id/versions/:version/rollback", handlers.RollbackStandard)
+				teacherRoutes.POST("/standards/extract", handlers.ExtractStandardFromDoc)
+				teacherRoutes.POST("/standards/import", handlers.ImportStandard)
+				teacherRoutes.GET("/standards/templates", handlers.GetStandardTemplates)
+				teacherRoutes.GET("/standards/rule-modules", handlers.GetRuleModules)
+				teacherRoutes.POST("/standards/from-template/:key", handlers.CreateStandardFromTemplate)
+				teacherRoutes.POST("/standards/:id/clone", handlers.CloneStandard)
+				teacherRoutes.GET("/standards/:id/access", handlers.GetStandardAccess)
+				teacherRoutes.PUT("/standards/:id/access", handlers.SetStandardAccess)
+				teacherRoutes.GET("/standards/:id/export", handlers.ExportStandard)
+				teacherRoutes.GET("/check-defaults", handlers.GetCheckDefaults)
+				teacherRoutes.POST("/check/schedule", handlers.ScheduleCheck)
+				teacherRoutes.GET("/check/schedule", handlers.GetCheckSchedules)
+				teacherRoutes.GET("/check/schedule/:id", handlers.GetCheckSchedule)
+				teacherRoutes.DELETE("/check/schedule/:id", handlers.CancelCheckSchedule)
+				teacherRoutes.GET("/teacher/history", handlers.GetTeacherHistory)
+				teacherRoutes.GET("/teacher/history/:id", handlers.GetTeacherHistoryDetail)
+				teacherRoutes.GET("/teacher/history/:id/trace", handlers.TraceTeacherCheck)
+				teacherRoutes.POST("/teacher/history/:id/recheck", handlers.RecheckTeacherResult)
+				teacherRoutes.POST("/assignments", handlers.CreateAssignment)
+				teacherRoutes.PUT("/assignments/:id", handlers.UpdateAssignment)
+				teacherRoutes.DELETE("/assignments/:id", handlers.DeleteAssignment)
+				teacherRoutes.GET("/assignments/:id/gradebook", handlers.GetAssignmentGradebook)
+				teacherRoutes.GET("/assignments/:id/similarity", handlers.GetAssignmentSimilarity)
+				teacherRoutes.GET("/feedback-templates", handlers.GetFeedbackTemplates)
+				teacherRoutes.POST("/feedback-templates", handlers.CreateFeedbackTemplate)
+				teacherRoutes.PUT("/feedback-templates/:id", handlers.UpdateFeedbackTemplate)
+				teacherRoutes.DELETE("/feedback-templates/:id", handlers.DeleteFeedbackTemplate)
+				teacherRoutes.GET("/dictionaries", handlers.GetDictionaries)
+				teacherRoutes.POST("/dictionaries", handlers.CreateDictionary)
+				teacherRoutes.PUT("/dictionaries/:id", handlers.UpdateDictionary)
+				teacherRoutes.DELETE("/dictionaries/:id", handlers.DeleteDictionary)
+				teacherRoutes.POST("/teacher/comments", handlers.AddResultComments)
+				teacherRoutes.DELETE("/teacher/comments/:id", handlers.DeleteResultComment)
+				teacherRoutes.GET("/teacher/false-positives", handlers.GetFalsePositives)
+				teacherRoutes.PUT("/teacher/false-positives/:id", handlers.ReviewFalsePositive)
+			}
+
+			// Department Coordinator Routes (Statistics of the teachers of a department)
+			departmentRoutes := secured.Group("/department")
+			departmentRoutes.Use(auth.RequireRole("teacher", "admin"))
+			{
+				departmentRoutes.GET("/stats", handlers.GetDepartmentStats)
+				departmentRoutes.GET("/stats/export", handlers.ExportDepartmentStats)
+			}
+
+			// Supervisor Routes (Read-only access to the checks of granted students)
+			supervisorRoutes := secured.Group("/supervisor")
+			supervisorRoutes.Use(auth.RequireRole("supervisor"))
+			{
+				supervisorRoutes.GET("/students", handlers.GetSupervisedStudents)
+				supervisorRoutes.GET("/history", handlers.GetSupervisorHistory)
+				supervisorRoutes.GET("/history/:id", handlers.GetSupervisorHistoryDetail)
+				supervisorRoutes.POST("/comments", handlers.AddResultComments)
+				supervisorRoutes.DELETE("/comments/:id", handlers.DeleteResultComment)
+			}
+
+			// Admin Only Routes
+			adminGroup := secured.Group("/admin")
+			adminGroup.Use(auth.RequireRole("admin"))
+			{
+				adminGroup.GET("/stats", handlers.GetAdminStats)
+				adminGroup.GET("/stats/terms", handlers.GetTermStats)
+				adminGroup.GET("/activity", handlers.GetActivity)
+				adminGroup.POST("/terms", handlers.CreateTerm)
+				adminGroup.PUT("/terms/:id", handlers.UpdateTerm)
+				adminGroup.DELETE("/terms/:id", handlers.DeleteTerm)
+				adminGroup.POST("/terms/:id/rollover", handlers.RolloverTerm)
+				adminGroup.GET("/false-positives/fixtures", handlers.ExportFalsePositiveFixtures)
+				adminGroup.GET("/users", handlers.GetUsers)
+				adminGroup.DELETE("/users/:id", handlers.DeleteUser)
+				adminGroup.PUT("/users/:id/status", handlers.ToggleUserStatus)
+				adminGroup.PUT("/users/:id/role", handlers.SetUserRole)
+				adminGroup.PUT("/users/:id/department", handlers.SetUserDepartment)
+				adminGroup.GET("/departments", handlers.GetDepartments)
+				adminGroup.GET("/users/:id/supervision", handlers.GetSupervision)
+				adminGroup.PUT("/users/:id/supervision", handlers.SetSupervision)
+				adminGroup.POST("/users/:id/impersonate", auth.StartImpersonation)
+				adminGroup.GET("/impersonations", handlers.GetImpersonationLog)
+				adminGroup.GET("/failed-jobs", handlers.GetFailedJobs)
+				adminGroup.POST("/failed-jobs/:id/retry", handlers.RetryFailedJob)
+				adminGroup.DELETE("/failed-jobs/:id", handlers.DiscardFailedJob)
+				adminGroup.POST("/cleanup", handlers.RunUploadJanitor)
+				adminGroup.GET("/sync/export", handlers.ExportSyncBundle)
+				adminGroup.POST("/sync/import", handlers.ImportSyncBundle)
+				adminGroup.GET("/catalog", handlers.GetCatalog)
+				adminGroup.POST("/catalog/import", handlers.ImportCatalogStandard)
+				adminGroup.PUT("/catalog/standards/:id", handlers.PublishCatalogStandard)
+				adminGroup.GET("/roster", handlers.GetRosterStatus)
+				adminGroup.POST("/roster/sync", handlers.RunRosterSync)
+				adminGroup.PUT("/branding", handlers.UpdateBranding)
+				adminGroup.POST("/branding/logo", handlers.UploadBrandingLogo)
+				adminGroup.DELETE("/branding/logo", handlers.DeleteBrandingLogo)
+				adminGroup.PUT("/gamification", handlers.SetGamification)
+				adminGroup.PUT("/check-defaults", handlers.UpdateCheckDefaults)
+				adminGroup.DELETE("/check-defaults", handlers.ResetCheckDefaults)
+				adminGroup.GET("/settings", handlers.GetSettings)
+				adminGroup.PUT("/settings", handlers.UpdateSettings)
+				adminGroup.DELETE("/settings/:key", handlers.ResetSetting)
+				adminGroup.GET("/report-templates", handlers.GetReportTemplates)
+				adminGroup.GET("/report-templates/default", handlers.GetDefaultReportTemplate)
+				adminGroup.POST("/report-templates", handlers.UploadReportTemplate)
+				adminGroup.POST("/report-templates/preview", handlers.PreviewUploadedReportTemplate)
+				adminGroup.DELETE("/report-templates/active", handlers.DeactivateReportTemplates)
+				adminGroup.GET("/report-templates/:id", handlers.GetReportTemplate)
+				adminGroup.GET("/report-templates/:id/preview", handlers.PreviewReportTemplate)
+				adminGroup.PUT("/report-templates/:id/activate", handlers.ActivateReportTemplate)
+				adminGroup.DELETE("/report-templates/:id", handlers.DeleteReportTemplate)
+				adminGroup.POST("/learning-resources", handlers.CreateLearningResource)
+				adminGroup.POST("/learning-resources/pdf", handlers.UploadLearningResource)
+				adminGroup.PUT("/learning-resources/:id", handlers.UpdateLearningResource)
+				adminGroup.DELETE("/learning-resources/:id", handlers.DeleteLearningResource)
+			}
+		}
+
+		api.GET("/ping", func(c *gin.Context) {
+			c.JSON(200, gin.H{
+				"message": "pong",
+			})
+		})
+
+		api.GET("/health", func(c *gin.Context) {
+			// Check DB
+			db := database.DB
+			if db == nil || db.Ping() != nil {
+				c.JSON(503, gin.H{"status": "unhealthy", "database": "disconnected"})
+				return
+			}
+			resp := gin.H{"status": "healthy", "database": "connected"}
+			// PDF previews are optional: a broken converter is reported
+			// without making the service unhealthy.
+			if conv := convert.Default(); conv != nil {
+				resp["converter"] = conv.Health()
+			}
+			c.JSON(200, resp)
+		})
+
+		// Signed entries of the standards this deployment shares with others
+		api.GET("/catalog/entries", handlers.GetCatalogEntries)
+
+		// Prometheus Metrics Endpoint
+		api.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	}
+
+	return r
+}
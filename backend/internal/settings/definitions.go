@@ -0,0 +1,35 @@
+package settings
+
+// definitions lists the settings in the order the admin API shows them.
+// Tolerances and caption keywords checks inherit have their own endpoint,
+// /api/admin/check-defaults, and take effect at once as well.
+var definitions = []Definition{
+	{Key: "rate_limit.global_rps", Type: TypeFloat, Scope: ScopeRuntime, Default: "50", Range: &Range{0.01, 10000},
+		Description: "Requests per second one client may send to the API"},
+	{Key: "rate_limit.global_burst", Type: TypeInt, Scope: ScopeRuntime, Default: "100", Range: &Range{1, 100000},
+		Description: "Requests one client may send to the API in a burst"},
+	{Key: "rate_limit.auth_rps", Type: TypeFloat, Scope: ScopeRuntime, Default: "2", Range: &Range{0.01, 1000},
+		Description: "Login and registration attempts per second of one client"},
+	{Key: "rate_limit.auth_burst", Type: TypeInt, Scope: ScopeRuntime, Default: "5", Range: &Range{1, 1000},
+		Description: "Login and registration attempts of one client in a burst"},
+	{Key: "rate_limit.ai_rps", Type: TypeFloat, Scope: ScopeRuntime, Default: "0.1", Range: &Range{0.001, 100},
+		Description: "AI verification and feedback requests per second of one client"},
+	{Key: "rate_limit.ai_burst", Type: TypeInt, Scope: ScopeRuntime, Default: "3", Range: &Range{1, 100},
+		Description: "AI verification and feedback requests of one client in a burst"},
+	{Key: "api.default_language", Type: TypeString, Scope: ScopeRuntime, Env: "API_DEFAULT_LANGUAGE", Default: "ru", Options: []string{"ru", "en"},
+		Description: "Language of API errors for clients whose Accept-Language names none the server speaks"},
+	{Key: "checks.workers", Type: TypeInt, Scope: ScopeRestart, Env: "CHECK_WORKERS", Default: "0", Range: &Range{0, 256},
+		Description: "Checks run at once; 0 is the number of CPUs"},
+	{Key: "checks.timeout_seconds", Type: TypeInt, Scope: ScopeRuntime, Env: "CHECK_TIMEOUT_SECONDS", Default: "120", Range: &Range{1, 3600},
+		Description: "Time limit of one check, waiting for a worker included"},
+	{Key: "checks.pdf_wait_seconds", Type: TypeInt, Scope: ScopeRuntime, Env: "CONVERT_WAIT_SECONDS", Default: "15", Range: &Range{0, 600},
+		Description: "How long a check request waits for the PDF preview"},
+	{Key: "notifications.schedule_done", Type: TypeBool, Scope: ScopeRuntime, Default: "true",
+		Description: "Notify teachers when a scheduled batch check has finished"},
+	{Key: "roster.max_deactivate_percent", Type: TypeInt, Scope: ScopeRuntime, Env: "ROSTER_MAX_DEACTIVATE_PERCENT", Default: "25", Range: &Range{0, 100},
+		Description: "Largest share of roster accounts one roster sync may deactivate"},
+	{Key: "janitor.interval_minutes", Type: TypeInt, Scope: ScopeRestart, Env: "JANITOR_INTERVAL_MINUTES", Default: "60", Range: &Range{0, 10080},
+		Description: "Interval of the uploads cleanup; 0 disables it"},
+	{Key: "janitor.min_age_minutes", Type: TypeInt, Scope: ScopeRuntime, Env: "JANITOR_MIN_AGE_MINUTES", Default: "60", Range: &Range{0, 10080},
+		Description: "Age below which the uploads cleanup leaves files alone"},
+}
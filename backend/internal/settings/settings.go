@@ -0,0 +1,361 @@
+// Package settings keeps the server settings administrators change without
+// editing the environment. A setting has a key, a type and a scope: runtime
+// settings take effect as soon as they are saved, restart settings on the
+// next start of the server. Until a value is saved, the environment variable
+// the setting replaces applies, and without it the builtin default.
+package settings
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/database"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type is the type of a setting's value.
+type Type string
+
+const (
+	TypeInt    Type = "int"
+	TypeFloat  Type = "float"
+	TypeBool   Type = "bool"
+	TypeString Type = "string"
+)
+
+// Scope tells when a saved value takes effect.
+type Scope string
+
+const (
+	// ScopeRuntime settings are read whenever they are used.
+	ScopeRuntime Scope = "runtime"
+	// ScopeRestart settings shape structures built at startup, such as the
+	// size of a worker pool.
+	ScopeRestart Scope = "restart"
+)
+
+// Range bounds a numeric setting, both ends included.
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Definition describes a setting.
+type Definition struct {
+	Key         string `json:"key"`
+	Type        Type   `json:"type"`
+	Scope       Scope  `json:"scope"`
+	Description string `json:"description"`
+	// Env is the environment variable the setting replaces.
+	Env     string `json:"env,omitempty"`
+	Default string `json:"default"`
+	Range   *Range `json:"range,omitempty"`
+	// Options lists the values a string setting may take.
+	Options []string `json:"options,omitempty"`
+}
+
+// Value is a setting with the value in effect and where it comes from:
+// "stored", "env" or "default".
+type Value struct {
+	Definition
+	Value     interface{} `json:"value"`
+	Source    string      `json:"source"`
+	UpdatedAt *time.Time  `json:"updated_at,omitempty"`
+	// PendingRestart is set for a restart setting changed since the server
+	// started.
+	PendingRestart bool `json:"pending_restart,omitempty"`
+}
+
+type storedValue struct {
+	value     string
+	updatedAt *time.Time
+}
+
+var (
+	mu       sync.RWMutex
+	stored   = map[string]storedValue{}
+	started  map[string]string // restart settings in effect at the first load
+	watchers []func()
+)
+
+// Lookup returns the definition of key.
+func Lookup(key string) (Definition, bool) {
+	for _, d := range definitions {
+		if d.Key == key {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Definitions returns all settings in a stable order.
+func Definitions() []Definition {
+	return append([]Definition(nil), definitions...)
+}
+
+// Watch registers fn to run after saved settings changed, e.g. to resize
+// limiters built from them.
+func Watch(fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	watchers = append(watchers, fn)
+}
+
+// Load reads the saved settings and runs the watchers when they changed.
+func Load() error {
+	rows, err := database.DB.Query("SELECT key, value, updated_at FROM settings")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	next := map[string]storedValue{}
+	for rows.Next() {
+		var key, value string
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&key, &value, &updatedAt); err != nil {
+			return err
+		}
+		v := storedValue{value: value}
+		if updatedAt.Valid {
+			v.updatedAt = &updatedAt.Time
+		}
+		next[key] = v
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	changed := len(next) != len(stored)
+	for key, v := range next {
+		if old, ok := stored[key]; !ok || old.value != v.value {
+			changed = true
+		}
+	}
+	stored = next
+	if started == nil {
+		started = map[string]string{}
+		for _, d := range definitions {
+			if d.Scope == ScopeRestart {
+				started[d.Key], _ = effective(d)
+			}
+		}
+	}
+	notify := append([]func(){}, watchers...)
+	mu.Unlock()
+
+	if changed {
+		for _, fn := range notify {
+			fn()
+		}
+	}
+	return nil
+}
+
+// StartReloader loads the saved settings every SETTINGS_RELOAD_SECONDS
+// (default 30, 0 disables), so replicas pick up a change saved on another.
+func StartReloader() {
+	interval := 30 * time.Second
+	if n, err := strconv.Atoi(os.Getenv("SETTINGS_RELOAD_SECONDS")); err == nil && n >= 0 {
+		interval = time.Duration(n) * time.Second
+	}
+	if interval == 0 {
+		return
+	}
+	go func() {
+		for {
+			time.Sleep(interval)
+			if err := Load(); err != nil {
+				fmt.Printf("Settings: reload failed: %v\n", err)
+			}
+		}
+	}()
+}
+
+// Save stores values, checked with Parse, as the user, and loads them. All
+// or none are saved.
+func Save(values map[string]string, userID uint) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := database.Timestamp(time.Now())
+	for key, value := range values {
+		_, err := tx.Exec(`INSERT INTO settings (key, value, updated_by, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+			key, value, userID, now)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return Load()
+}
+
+// Reset removes the saved value of key, so the environment or the default
+// applies again.
+func Reset(key string) error {
+	if _, err := database.DB.Exec("DELETE FROM settings WHERE key = ?", key); err != nil {
+		return err
+	}
+	return Load()
+}
+
+// Parse checks a JSON value against the type and range of d and returns it
+// in the form it is stored in.
+func Parse(d Definition, raw json.RawMessage) (string, error) {
+	var value string
+	switch d.Type {
+	case TypeInt, TypeFloat:
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return "", fmt.Errorf("%s must be a number", d.Key)
+		}
+		if d.Type == TypeInt && n != math.Trunc(n) {
+			return "", fmt.Errorf("%s must be a whole number", d.Key)
+		}
+		value = strconv.FormatFloat(n, 'f', -1, 64)
+	case TypeBool:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return "", fmt.Errorf("%s must be true or false", d.Key)
+		}
+		value = strconv.FormatBool(b)
+	default:
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return "", fmt.Errorf("%s must be a string", d.Key)
+		}
+	}
+	if err := check(d, value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// check validates a value in its stored form.
+func check(d Definition, value string) error {
+	switch d.Type {
+	case TypeInt, TypeFloat:
+		var n float64
+		var err error
+		if d.Type == TypeInt {
+			var i int
+			i, err = strconv.Atoi(value)
+			n = float64(i)
+		} else {
+			n, err = strconv.ParseFloat(value, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("%s must be a number", d.Key)
+		}
+		if d.Range != nil && (n < d.Range.Min || n > d.Range.Max) {
+			return fmt.Errorf("%s must be between %g and %g", d.Key, d.Range.Min, d.Range.Max)
+		}
+	case TypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false", d.Key)
+		}
+	case TypeString:
+		if len(d.Options) > 0 && !slices.Contains(d.Options, value) {
+			return fmt.Errorf("%s must be one of %s", d.Key, strings.Join(d.Options, ", "))
+		}
+	}
+	return nil
+}
+
+// effective returns the value of d in effect and its source. Saved and
+// environment values that fail validation are skipped. The caller holds mu.
+func effective(d Definition) (string, string) {
+	if v, ok := stored[d.Key]; ok && check(d, v.value) == nil {
+		return v.value, "stored"
+	}
+	if d.Env != "" {
+		if v := strings.TrimSpace(os.Getenv(d.Env)); v != "" && check(d, v) == nil {
+			return v, "env"
+		}
+	}
+	return d.Default, "default"
+}
+
+// All returns every setting with the value in effect.
+func All() []Value {
+	mu.RLock()
+	defer mu.RUnlock()
+	values := make([]Value, 0, len(definitions))
+	for _, d := range definitions {
+		raw, source := effective(d)
+		v := Value{Definition: d, Value: typed(d, raw), Source: source}
+		if source == "stored" {
+			v.UpdatedAt = stored[d.Key].updatedAt
+		}
+		if d.Scope == ScopeRestart && started != nil {
+			v.PendingRestart = started[d.Key] != raw
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+func typed(d Definition, raw string) interface{} {
+	switch d.Type {
+	case TypeInt:
+		n, _ := strconv.Atoi(raw)
+		return n
+	case TypeFloat:
+		n, _ := strconv.ParseFloat(raw, 64)
+		return n
+	case TypeBool:
+		b, _ := strconv.ParseBool(raw)
+		return b
+	}
+	return raw
+}
+
+func get(key string) string {
+	d, ok := Lookup(key)
+	if !ok {
+		log.Panicf("settings: unknown setting %q", key)
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	raw, _ := effective(d)
+	return raw
+}
+
+// Int returns the value of the int setting key.
+func Int(key string) int {
+	n, _ := strconv.Atoi(get(key))
+	return n
+}
+
+// Float returns the value of the float setting key.
+func Float(key string) float64 {
+	n, _ := strconv.ParseFloat(get(key), 64)
+	return n
+}
+
+// Bool returns the value of the bool setting key.
+func Bool(key string) bool {
+	b, _ := strconv.ParseBool(get(key))
+	return b
+}
+
+// String returns the value of the string setting key.
+func String(key string) string {
+	return get(key)
+}
+
+// Seconds returns the int setting key as a number of seconds.
+func Seconds(key string) time.Duration {
+	return time.Duration(Int(key)) * time.Second
+}
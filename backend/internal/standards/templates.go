@@ -0,0 +1,95 @@
+// Package standards ships the built-in library of standard templates: ready
+// configurations of common standards that a teacher copies and adjusts
+// instead of filling in every field of the editor.
+package standards
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+	"path"
+	"sort"
+)
+
+//go:embed templates/*.json
+var templateFiles embed.FS
+
+// Template is a built-in standard configuration.
+type Template struct {
+	Key          string                    `json:"key"`
+	Name         string                    `json:"name"`
+	Description  string                    `json:"description"`
+	DocumentType string                    `json:"document_type"`
+	Order        int                       `json:"-"`
+	Modules      []models.ValidationModule `json:"modules"`
+}
+
+var templates = mustLoadTemplates()
+
+// mustLoadTemplates reads the embedded templates. They ship with the binary,
+// so a template that does not decode as a standard config is a build error
+// and stops the server at start.
+func mustLoadTemplates() []Template {
+	files, err := templateFiles.ReadDir("templates")
+	if err != nil {
+		panic(err)
+	}
+	var list []Template
+	for _, f := range files {
+		data, err := templateFiles.ReadFile(path.Join("templates", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		t, err := parseTemplate(data)
+		if err != nil {
+			panic(fmt.Sprintf("standards: template %s: %v", f.Name(), err))
+		}
+		list = append(list, t)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Order < list[j].Order })
+	return list
+}
+
+func parseTemplate(data []byte) (Template, error) {
+	var t Template
+	var raw struct {
+		Template
+		Order int `json:"order"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return t, err
+	}
+	t = raw.Template
+	t.Order = raw.Order
+	if t.Key == "" || t.Name == "" || len(t.Modules) == 0 {
+		return t, fmt.Errorf("key, name and modules are required")
+	}
+	for _, m := range t.Modules {
+		config, _ := json.Marshal(m.Config)
+		var schema checker.ConfigSchema
+		if err := json.Unmarshal(config, &schema); err != nil {
+			return t, fmt.Errorf("module %q: %v", m.ID, err)
+		}
+		if err := checker.ValidateCustomRules(schema.CustomRules); err != nil {
+			return t, fmt.Errorf("module %q: %v", m.ID, err)
+		}
+	}
+	return t, nil
+}
+
+// Templates returns the built-in templates in catalog order.
+func Templates() []Template {
+	return append([]Template(nil), templates...)
+}
+
+// Lookup returns the template with the given key.
+func Lookup(key string) (Template, bool) {
+	for _, t := range templates {
+		if t.Key == key {
+			return t, true
+		}
+	}
+	return Template{}, false
+}
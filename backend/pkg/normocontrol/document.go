@@ -0,0 +1,129 @@
+package normocontrol
+
+import (
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"strings"
+)
+
+// Document is a parsed document. It can be checked against several
+// configurations without parsing the file again. Documents come from
+// Checker.ParseFile and Checker.Parse, or are built with NewDocument; the
+// zero Document is an empty one.
+type Document struct {
+	doc *checker.ParsedDoc
+}
+
+// Margins are the page margins of a document in millimetres.
+type Margins struct {
+	TopMm    float64
+	BottomMm float64
+	LeftMm   float64
+	RightMm  float64
+	HeaderMm float64
+	FooterMm float64
+}
+
+// Paragraph is a paragraph of the document body with its effective
+// formatting.
+type Paragraph struct {
+	Text string
+	// Role is what the paragraph is: body, heading, list, toc,
+	// table_caption, figure_caption or formula. NewDocument reads a
+	// paragraph without one as body text.
+	Role         string
+	StyleName    string // display name of the style, e.g. "heading 1"
+	OutlineLevel int    // 1-9 for headings; 0 = body text
+	Alignment    string // left, center, right or both
+
+	FontName   string
+	FontSizePt float64
+	Bold       bool
+	Italic     bool
+
+	LineSpacing       float64 // multiplier, e.g. 1.5
+	FirstLineIndentMm float64
+	SpacingBeforePt   float64
+	SpacingAfterPt    float64
+
+	PageNumber int // estimated page the paragraph starts on
+}
+
+// NewDocument builds a document from its margins and body paragraphs, for
+// text that does not come from a file or for tests of custom modules.
+func NewDocument(margins Margins, paragraphs ...Paragraph) *Document {
+	doc := &checker.ParsedDoc{Margins: checker.Margins(margins)}
+	for i, p := range paragraphs {
+		pp := p.parsed()
+		pp.ID = fmt.Sprintf("p-%d", i)
+		if pp.Role == "" && strings.TrimSpace(pp.Text) != "" {
+			pp.Role = "body"
+		}
+		if pp.PageNumber == 0 {
+			pp.PageNumber = 1
+		}
+		doc.Paragraphs = append(doc.Paragraphs, pp)
+	}
+	return &Document{doc: doc}
+}
+
+func (d *Document) parsed() *checker.ParsedDoc {
+	if d == nil || d.doc == nil {
+		return &checker.ParsedDoc{}
+	}
+	return d.doc
+}
+
+// Margins returns the page margins of the main section.
+func (d *Document) Margins() Margins {
+	return Margins(d.parsed().Margins)
+}
+
+// Paragraphs returns the body paragraphs in document order. Changing them
+// does not change the document.
+func (d *Document) Paragraphs() []Paragraph {
+	parsed := d.parsed().Paragraphs
+	paragraphs := make([]Paragraph, len(parsed))
+	for i, p := range parsed {
+		paragraphs[i] = paragraphFrom(p)
+	}
+	return paragraphs
+}
+
+func paragraphFrom(p checker.ParsedParagraph) Paragraph {
+	return Paragraph{
+		Text:              p.Text,
+		Role:              p.Role,
+		StyleName:         p.StyleName,
+		OutlineLevel:      p.OutlineLevel,
+		Alignment:         p.Alignment,
+		FontName:          p.FontName,
+		FontSizePt:        p.FontSizePt,
+		Bold:              p.IsBold,
+		Italic:            p.IsItalic,
+		LineSpacing:       p.LineSpacing,
+		FirstLineIndentMm: p.FirstLineIndentMm,
+		SpacingBeforePt:   p.SpacingBeforePt,
+		SpacingAfterPt:    p.SpacingAfterPt,
+		PageNumber:        p.PageNumber,
+	}
+}
+
+func (p Paragraph) parsed() checker.ParsedParagraph {
+	return checker.ParsedParagraph{
+		Text:              p.Text,
+		Role:              p.Role,
+		StyleName:         p.StyleName,
+		OutlineLevel:      p.OutlineLevel,
+		Alignment:         p.Alignment,
+		FontName:          p.FontName,
+		FontSizePt:        p.FontSizePt,
+		IsBold:            p.Bold,
+		IsItalic:          p.Italic,
+		LineSpacing:       p.LineSpacing,
+		FirstLineIndentMm: p.FirstLineIndentMm,
+		SpacingBeforePt:   p.SpacingBeforePt,
+		SpacingAfterPt:    p.SpacingAfterPt,
+		PageNumber:        p.PageNumber,
+	}
+}
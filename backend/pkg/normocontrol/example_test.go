@@ -0,0 +1,113 @@
+package normocontrol_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/pkg/normocontrol"
+	"log"
+	"strings"
+)
+
+func Example() {
+	cfg, err := normocontrol.ParseConfig(`{"margins": {"left": 30, "right": 15, "tolerance": 1}}`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Normally the document comes from Checker.ParseFile or Checker.Parse.
+	doc := normocontrol.NewDocument(normocontrol.Margins{LeftMm: 20, RightMm: 15})
+
+	result, violations, err := normocontrol.New().Check(context.Background(), doc, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, v := range violations {
+		fmt.Printf("%s: expected %s, found %s\n", v.RuleType, v.ExpectedValue, v.ActualValue)
+	}
+	fmt.Println(result.Summary)
+	// Output:
+	// margin_left: expected 30.0 мм, found 20.0 мм
+	// Основные проблемы: поле слева.
+}
+
+func ExampleChecker_CheckFile() {
+	cfg, err := normocontrol.ParseConfig(`{"font": {"name": "Times New Roman", "size": 14}}`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result, violations, err := normocontrol.New().CheckFile(context.Background(), "thesis.docx", cfg)
+	if ce, ok := err.(*normocontrol.ComplexityError); ok {
+		result, violations = normocontrol.ComplexityReport(ce)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	normocontrol.Localize(violations, normocontrol.LangEN)
+	fmt.Printf("score %.1f, %d violations\n", result.OverallScore, len(violations))
+}
+
+// signatureModule requires a paragraph with the author's signature line.
+type signatureModule struct {
+	Label string `json:"label"`
+}
+
+func (m *signatureModule) Name() string { return "signature" }
+
+func (m *signatureModule) Configure(config json.RawMessage) error {
+	return json.Unmarshal(config, m)
+}
+
+func (m *signatureModule) Check(doc *normocontrol.Document) []normocontrol.Violation {
+	for _, p := range doc.Paragraphs() {
+		if strings.HasPrefix(p.Text, m.Label) {
+			return nil
+		}
+	}
+	return []normocontrol.Violation{{
+		RuleType:      "signature_missing",
+		Description:   "Нет строки подписи",
+		ExpectedValue: m.Label,
+		Severity:      normocontrol.SeverityError,
+	}}
+}
+
+func ExampleRegisterModule() {
+	normocontrol.RegisterModule(func() normocontrol.RuleModule { return &signatureModule{} })
+
+	cfg, err := normocontrol.ParseConfig(`{"modules": {"signature": {"label": "Подпись автора"}}}`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	doc := normocontrol.NewDocument(normocontrol.Margins{}, normocontrol.Paragraph{Text: "Заключение"})
+	_, violations, err := normocontrol.New().Check(context.Background(), doc, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, v := range violations {
+		fmt.Printf("%s: %s\n", v.RuleType, v.ExpectedValue)
+	}
+	// Output:
+	// signature_missing: Подпись автора
+}
+
+func ExampleConfig() {
+	// A standard stored by the embedding application along with its name.
+	var standard struct {
+		Name   string              `json:"name"`
+		Config normocontrol.Config `json:"config"`
+	}
+	err := json.Unmarshal([]byte(`{"name": "ГОСТ 7.32", "config": {"margins": {"left": 30, "right": 15}}}`), &standard)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, _ := json.Marshal(standard)
+	fmt.Println(string(data))
+
+	_, err = normocontrol.ParseConfig(`{"margins": {"left": "30 мм"}}`)
+	fmt.Println(err != nil)
+	// Output:
+	// {"name":"ГОСТ 7.32","config":{"margins":{"left":30,"right":15}}}
+	// true
+}
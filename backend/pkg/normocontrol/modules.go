@@ -0,0 +1,68 @@
+package normocontrol
+
+import (
+	"encoding/json"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+)
+
+// RuleModule is a custom rule group, turned on by the "modules" of a Config.
+// See RegisterModule.
+type RuleModule interface {
+	// Name is the key of the module under "modules" and the name of its
+	// group in the rule trace.
+	Name() string
+	// Configure applies the settings of the module in the standard. An
+	// error skips the module in the check.
+	Configure(config json.RawMessage) error
+	// Check returns the violations found in the document. Violations
+	// without a rule type are reported under the module's name.
+	Check(doc *Document) []Violation
+}
+
+// RuleCounter is implemented by modules that check more than one rule, so
+// the score weighs their violations against the right number of rules. A
+// module without it counts as one rule.
+type RuleCounter interface {
+	// Rules is the number of rules the last Check applied.
+	Rules() int
+}
+
+// module runs a RuleModule as a module of the checker.
+type module struct {
+	rules RuleModule
+}
+
+func (m module) Name() string { return m.rules.Name() }
+
+func (m module) Configure(config json.RawMessage) error { return m.rules.Configure(config) }
+
+func (m module) Check(doc *checker.ParsedDoc) []models.Violation {
+	return violationModels(m.rules.Check(&Document{doc: doc}))
+}
+
+func (m module) Rules() int {
+	if counter, ok := m.rules.(RuleCounter); ok {
+		return counter.Rules()
+	}
+	return 1
+}
+
+// RegisterModule makes a custom rule module available to configurations
+// under its name, usually from an init function. Checks run it after the
+// built-in rules; a configuration turns it on with its settings:
+//
+//	{"modules": {"title_page_stamp": {"position": "bottom"}}}
+//
+// A name that is empty or registered before panics.
+func RegisterModule(factory func() RuleModule) {
+	if factory == nil {
+		panic("normocontrol: RegisterModule factory is nil")
+	}
+	checker.RegisterModule(func() checker.RuleModule { return module{factory()} })
+}
+
+// Modules lists the names of the registered rule modules.
+func Modules() []string {
+	return checker.Modules()
+}
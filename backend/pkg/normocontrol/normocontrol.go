@@ -0,0 +1,181 @@
+// Package normocontrol is the embeddable DOCX formatting checker used by the
+// NormoControl server. It parses a document, checks it against a standard
+// configuration and returns the score and the list of violations, without any
+// database, HTTP or file storage dependencies.
+//
+// Basic use:
+//
+//	c := normocontrol.New()
+//	cfg, err := normocontrol.ParseConfig(standardJSON)
+//	...
+//	result, violations, err := c.CheckFile(ctx, "thesis.docx", cfg)
+//
+// Results and violations marshal to the same JSON layout as the /api/check
+// response, so they can be stored or exchanged with the server.
+package normocontrol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"io"
+)
+
+// Config is a formatting standard: the config_json of a standard on the
+// server. It is built with ParseConfig or decoded from JSON; the zero Config
+// checks with the built-in defaults.
+type Config struct {
+	data []byte // compact JSON, validated against the server's schema
+}
+
+// ParseConfig decodes a standard configuration from its JSON form.
+func ParseConfig(data string) (Config, error) {
+	var cfg Config
+	if err := cfg.UnmarshalJSON([]byte(data)); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// MarshalJSON returns the configuration as it was parsed.
+func (c Config) MarshalJSON() ([]byte, error) {
+	if len(c.data) == 0 {
+		return []byte("{}"), nil
+	}
+	return c.data, nil
+}
+
+// UnmarshalJSON parses a standard configuration, rejecting settings of the
+// wrong type.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	var schema checker.ConfigSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("invalid standard config: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return fmt.Errorf("invalid standard config: %v", err)
+	}
+	c.data = buf.Bytes()
+	return nil
+}
+
+func (c Config) json() string {
+	data, _ := c.MarshalJSON()
+	return string(data)
+}
+
+// Limits bounds the size and complexity of documents the parser accepts. A
+// zero field disables the corresponding limit.
+type Limits struct {
+	MaxXMLBytes   int64 // size of a single XML part
+	MaxXMLDepth   int   // element nesting
+	MaxParagraphs int
+	MaxTables     int
+
+	// Zip-bomb protection for the package itself
+	MaxZipEntries int
+	MaxEntryBytes int64 // decompressed size of a single entry
+	MaxTotalBytes int64 // decompressed size of all entries together
+}
+
+// ComplexityError is returned when a document exceeds Limits.
+type ComplexityError struct {
+	Limit  string // what was exceeded, e.g. "paragraphs"
+	Actual int64  // the size found, at least
+	Max    int64
+}
+
+func (e *ComplexityError) Error() string {
+	return (*checker.ComplexityError)(e).Error()
+}
+
+// publicError turns the parser's complexity errors into *ComplexityError.
+func publicError(err error) error {
+	var ce *checker.ComplexityError
+	if errors.As(err, &ce) {
+		return &ComplexityError{Limit: ce.Limit, Actual: ce.Actual, Max: ce.Max}
+	}
+	return err
+}
+
+// Checker parses and checks documents. It is safe for concurrent use.
+type Checker struct {
+	svc *checker.CheckService
+}
+
+// New returns a Checker with the built-in parser limits.
+func New() *Checker {
+	return NewWithLimits(DefaultLimits())
+}
+
+// NewWithLimits returns a Checker that rejects documents exceeding limits.
+func NewWithLimits(limits Limits) *Checker {
+	// No parse cache: embedding applications decide themselves what to keep.
+	return &Checker{svc: &checker.CheckService{Parser: &checker.DocParser{Limits: checker.ParserLimits(limits)}}}
+}
+
+// DefaultLimits returns the built-in parser limits. Unlike the server it does
+// not read PARSER_* environment variables.
+func DefaultLimits() Limits {
+	return Limits(checker.BuiltinParserLimits())
+}
+
+// ParseFile parses the DOCX or ODT file at path.
+func (c *Checker) ParseFile(path string) (*Document, error) {
+	doc, err := c.svc.Parser.Parse(path)
+	if err != nil {
+		return nil, publicError(err)
+	}
+	return &Document{doc: doc}, nil
+}
+
+// Parse parses a DOCX or ODT package of the given size read from r.
+func (c *Checker) Parse(r io.ReaderAt, size int64) (*Document, error) {
+	doc, err := c.svc.Parser.ParseReader(r, size)
+	if err != nil {
+		return nil, publicError(err)
+	}
+	return &Document{doc: doc}, nil
+}
+
+// Check validates a parsed document against cfg.
+func (c *Checker) Check(ctx context.Context, doc *Document, cfg Config) (*Result, []Violation, error) {
+	result, violations, err := c.svc.Evaluate(ctx, doc.parsed(), cfg.json())
+	if err != nil {
+		return nil, nil, err
+	}
+	return resultFrom(result), violationsFrom(violations), nil
+}
+
+// CheckFile parses the DOCX file at path and checks it against cfg. A document
+// over the parser limits fails with a *ComplexityError; use ComplexityReport
+// to turn it into a report. A .pptx file is checked against the presentation
+// rules of cfg.
+func (c *Checker) CheckFile(ctx context.Context, path string, cfg Config) (*Result, []Violation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if checker.IsPresentation(path) {
+		result, violations, err := c.svc.RunCheck(ctx, path, cfg.json())
+		if err != nil {
+			return nil, nil, publicError(err)
+		}
+		return resultFrom(result), violationsFrom(violations), nil
+	}
+	doc, err := c.ParseFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.Check(ctx, doc, cfg)
+}
+
+// ComplexityReport builds the report the server shows for a document rejected
+// by the parser limits.
+func ComplexityReport(err *ComplexityError) (*Result, []Violation) {
+	result, violations := checker.ComplexityResult((*checker.ComplexityError)(err))
+	return resultFrom(result), violationsFrom(violations)
+}
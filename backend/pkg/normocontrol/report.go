@@ -0,0 +1,164 @@
+package normocontrol
+
+import (
+	"github.com/Ullyminat/NormoControl/backend/internal/checker"
+	"github.com/Ullyminat/NormoControl/backend/internal/i18n"
+	"github.com/Ullyminat/NormoControl/backend/internal/models"
+)
+
+// Violation severities. Only SeverityCritical, SeverityError and
+// SeverityWarning affect the score.
+const (
+	SeverityCritical  = models.SeverityCritical
+	SeverityError     = models.SeverityError
+	SeverityWarning   = models.SeverityWarning
+	SeverityInfo      = models.SeverityInfo
+	SeverityHint      = models.SeverityHint
+	SeverityIntegrity = models.SeverityIntegrity
+)
+
+// Units of Violation.ExpectedNum and ActualNum, and the kinds of
+// Violation.ExpectedBound.
+const (
+	UnitMillimeter = models.UnitMillimeter
+	UnitPoint      = models.UnitPoint
+	UnitLines      = models.UnitLines // line spacing multiplier
+	UnitPages      = models.UnitPages // page count
+	UnitPage       = models.UnitPage  // page number
+	UnitPercent    = models.UnitPercent
+
+	BoundMin = models.BoundMin
+	BoundMax = models.BoundMax
+)
+
+// Report languages accepted by Localize.
+const (
+	LangRU = i18n.LangRU
+	LangEN = i18n.LangEN
+)
+
+// Result holds the score and rule counters of a check.
+type Result struct {
+	OverallScore   float64 `json:"overall_score"`
+	TotalRules     int     `json:"total_rules"`
+	PassedRules    int     `json:"passed_rules"`
+	FailedRules    int     `json:"failed_rules"`
+	ProcessingTime int     `json:"processing_time"` // ms
+	Summary        string  `json:"summary"`         // short conclusion of the check, in Russian
+}
+
+// Violation is a single formatting problem found in a document.
+type Violation struct {
+	RuleType      string `json:"rule_type"`
+	Description   string `json:"description"`
+	Severity      string `json:"severity"` // see Severity* constants
+	PositionInDoc string `json:"position_in_doc"`
+	ExpectedValue string `json:"expected_value"`
+	ActualValue   string `json:"actual_value"`
+	Suggestion    string `json:"suggestion"`
+	ContextText   string `json:"context_text"` // snippet of the document around the problem
+
+	// Machine-readable form of numeric values. ExpectedValue/ActualValue are
+	// rendered from these by Localize.
+	ExpectedNum   *float64 `json:"expected_num,omitempty"`
+	ActualNum     *float64 `json:"actual_num,omitempty"`
+	Unit          string   `json:"unit,omitempty"`           // see Unit* constants
+	ExpectedBound string   `json:"expected_bound,omitempty"` // BoundMin, BoundMax or empty for an exact value
+
+	// Structured counterpart of PositionInDoc; nil for document-wide violations.
+	Location *Location `json:"location,omitempty"`
+}
+
+// Location points at the place of a violation in the document. Indexes count
+// from 0.
+type Location struct {
+	Page           int    `json:"page,omitempty"`
+	ParagraphIndex *int   `json:"paragraph_index,omitempty"`
+	ParagraphID    string `json:"paragraph_id,omitempty"`
+	TableIndex     *int   `json:"table_index,omitempty"`
+	ImageIndex     *int   `json:"image_index,omitempty"`
+	FormulaIndex   *int   `json:"formula_index,omitempty"`
+	CharStart      *int   `json:"char_start,omitempty"`
+	CharEnd        *int   `json:"char_end,omitempty"`
+}
+
+func resultFrom(r *models.CheckResult) *Result {
+	return &Result{
+		OverallScore:   r.OverallScore,
+		TotalRules:     r.TotalRules,
+		PassedRules:    r.PassedRules,
+		FailedRules:    r.FailedRules,
+		ProcessingTime: r.ProcessingTime,
+		Summary:        r.Summary,
+	}
+}
+
+func violationsFrom(violations []models.Violation) []Violation {
+	out := make([]Violation, len(violations))
+	for i, v := range violations {
+		out[i] = Violation{
+			RuleType:      v.RuleType,
+			Description:   v.Description,
+			Severity:      v.Severity,
+			PositionInDoc: v.PositionInDoc,
+			ExpectedValue: v.ExpectedValue,
+			ActualValue:   v.ActualValue,
+			Suggestion:    v.Suggestion,
+			ContextText:   v.ContextText,
+			ExpectedNum:   v.ExpectedNum,
+			ActualNum:     v.ActualNum,
+			Unit:          v.Unit,
+			ExpectedBound: v.ExpectedBound,
+		}
+		if v.Location != nil {
+			loc := Location(*v.Location)
+			out[i].Location = &loc
+		}
+	}
+	return out
+}
+
+func (v Violation) model() models.Violation {
+	m := models.Violation{
+		RuleType:      v.RuleType,
+		Description:   v.Description,
+		Severity:      v.Severity,
+		PositionInDoc: v.PositionInDoc,
+		ExpectedValue: v.ExpectedValue,
+		ActualValue:   v.ActualValue,
+		Suggestion:    v.Suggestion,
+		ContextText:   v.ContextText,
+		ExpectedNum:   v.ExpectedNum,
+		ActualNum:     v.ActualNum,
+		Unit:          v.Unit,
+		ExpectedBound: v.ExpectedBound,
+	}
+	if v.Location != nil {
+		loc := models.Location(*v.Location)
+		m.Location = &loc
+	}
+	return m
+}
+
+func violationModels(violations []Violation) []models.Violation {
+	out := make([]models.Violation, len(violations))
+	for i, v := range violations {
+		out[i] = v.model()
+	}
+	return out
+}
+
+// Summarize describes the main problems of a check in one sentence (in Russian).
+func Summarize(violations []Violation) string {
+	return checker.Summarize(violationModels(violations))
+}
+
+// Localize renders the expected and actual values of violations for lang in
+// place. Reports are produced in Russian by default.
+func Localize(violations []Violation, lang string) {
+	for i := range violations {
+		m := violations[i].model()
+		i18n.Render(&m, i18n.Lang(lang))
+		violations[i].ExpectedValue, violations[i].ActualValue = m.ExpectedValue, m.ActualValue
+	}
+}
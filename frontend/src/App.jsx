@@ -13,13 +13,15 @@ import HistoryPage from './features/student/HistoryPage'
 import AdminDashboard from './features/admin/AdminDashboard'
 import UserManagement from './features/admin/UserManagement'
 import StandardsManagement from './features/admin/StandardsManagement'
+import BrandingSettings from './features/admin/BrandingSettings'
+import LearningResources from './features/admin/LearningResources'
 import AdminRoute from './features/admin/AdminRoute'
 import { ToastContainer } from 'react-toastify'
 import 'react-toastify/dist/ReactToastify.css'
 import './toastify-custom.css'
 
 function MainLayout() {
-  const { user, logout } = useAuth();
+  const { user, logout, stopImpersonation } = useAuth();
   const [isLogin, setIsLogin] = useState(true);
 
   if (!user) {
@@ -36,6 +38,14 @@ function MainLayout() {
 
   return (
     <div className="app-container">
+      {user.impersonated_by && (
+        <div style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center', marginBottom: '1.5rem', padding: '0.8rem 1.2rem', background: '#FFF3CD', border: '1px solid black', fontWeight: 600, fontSize: '0.9rem' }}>
+          <span>Просмотр от имени пользователя <b>{user.full_name}</b> (только чтение). Администратор: {user.impersonated_by.full_name}</span>
+          <button onClick={stopImpersonation} className="btn btn-ghost" style={{ fontSize: '0.85rem', padding: '6px 14px' }}>
+            ВЕРНУТЬСЯ
+          </button>
+        </div>
+      )}
       <header style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center', marginBottom: '3rem', borderBottom: '2px solid black', paddingBottom: '1.5rem' }}>
         <div>
           <Link to="/" style={{ textDecoration: 'none' }}>
@@ -64,6 +74,8 @@ function MainLayout() {
               <Link to="/admin" style={{ color: 'black', textDecoration: 'none', fontWeight: 600, textTransform: 'uppercase', fontSize: '0.9rem' }}>ДАШБОРД</Link>
               <Link to="/admin/users" style={{ color: 'black', textDecoration: 'none', fontWeight: 600, textTransform: 'uppercase', fontSize: '0.9rem' }}>ПОЛЬЗОВАТЕЛИ</Link>
               <Link to="/admin/standards" style={{ color: 'black', textDecoration: 'none', fontWeight: 600, textTransform: 'uppercase', fontSize: '0.9rem' }}>СТАНДАРТЫ</Link>
+              <Link to="/admin/branding" style={{ color: 'black', textDecoration: 'none', fontWeight: 600, textTransform: 'uppercase', fontSize: '0.9rem' }}>ОФОРМЛЕНИЕ</Link>
+              <Link to="/admin/resources" style={{ color: 'black', textDecoration: 'none', fontWeight: 600, textTransform: 'uppercase', fontSize: '0.9rem' }}>МАТЕРИАЛЫ</Link>
             </>
           )}
 
@@ -99,6 +111,8 @@ function MainLayout() {
             <Route path="/admin" element={<AdminDashboard />} />
             <Route path="/admin/users" element={<UserManagement />} />
             <Route path="/admin/standards" element={<StandardsManagement />} />
+            <Route path="/admin/branding" element={<BrandingSettings />} />
+            <Route path="/admin/resources" element={<LearningResources />} />
           </Route>
         </Routes>
       </main>
@@ -6,6 +6,7 @@ ChartJS.register(CategoryScale, LinearScale, PointElement, LineElement, Title, T
 
 function AdminDashboard() {
     const [stats, setStats] = useState(null);
+    const [activity, setActivity] = useState(null);
     const [loading, setLoading] = useState(true);
 
     useEffect(() => {
@@ -19,6 +20,10 @@ function AdminDashboard() {
                 console.error(err);
                 setLoading(false);
             });
+        fetch('/api/admin/activity?days=7', { credentials: 'include' })
+            .then(res => res.ok ? res.json() : null)
+            .then(data => setActivity(data))
+            .catch(err => console.error(err));
     }, []);
 
     if (loading) return <div style={{ padding: '2rem' }}>Загрузка статистики...</div>;
@@ -134,6 +139,61 @@ function AdminDashboard() {
                         </div>
                     </div>
                 </div>
+
+                {/* API usage (last 7 days) */}
+                {activity && (
+                    <div style={{ display: 'grid', gridTemplateColumns: '1fr 1fr', gap: '0', border: '1px solid black', borderTop: 'none' }}>
+                        <div style={{ padding: '2rem', borderRight: '1px solid black' }}>
+                            <h3 style={{ margin: '0 0 1.5rem 0', fontSize: '1rem', textTransform: 'uppercase', letterSpacing: '0.05em' }}>Запросы к API (7 дней)</h3>
+                            <table style={{ width: '100%', borderCollapse: 'collapse', fontSize: '0.85rem' }}>
+                                <thead>
+                                    <tr style={{ textAlign: 'left', borderBottom: '1px solid black' }}>
+                                        <th style={{ padding: '0.4rem 0' }}>Маршрут</th>
+                                        <th>Запросов</th>
+                                        <th>Ошибок</th>
+                                        <th>Среднее, мс</th>
+                                    </tr>
+                                </thead>
+                                <tbody>
+                                    {activity.routes.slice(0, 10).map(r => (
+                                        <tr key={r.method + r.route} style={{ borderBottom: '1px solid #E5E5E5' }}>
+                                            <td style={{ padding: '0.4rem 0', fontFamily: 'monospace' }}>{r.method} {r.route}</td>
+                                            <td>{r.requests}</td>
+                                            <td style={{ color: r.errors ? COLORS.red : COLORS.textDim }}>{r.errors}</td>
+                                            <td>{r.avg_latency_ms.toFixed(1)}</td>
+                                        </tr>
+                                    ))}
+                                </tbody>
+                            </table>
+                            <div style={{ marginTop: '1rem', fontSize: '0.85rem', color: COLORS.textDim }}>
+                                {activity.roles.map(r => `${r.role}: ${r.requests}`).join(' · ')}
+                            </div>
+                        </div>
+                        <div style={{ padding: '2rem' }}>
+                            <h3 style={{ margin: '0 0 1.5rem 0', fontSize: '1rem', textTransform: 'uppercase', letterSpacing: '0.05em' }}>Активные пользователи</h3>
+                            <table style={{ width: '100%', borderCollapse: 'collapse', fontSize: '0.85rem' }}>
+                                <thead>
+                                    <tr style={{ textAlign: 'left', borderBottom: '1px solid black' }}>
+                                        <th style={{ padding: '0.4rem 0' }}>Пользователь</th>
+                                        <th>Роль</th>
+                                        <th>Запросов</th>
+                                        <th>Последний раз</th>
+                                    </tr>
+                                </thead>
+                                <tbody>
+                                    {activity.users.slice(0, 10).map(u => (
+                                        <tr key={u.user_id} style={{ borderBottom: '1px solid #E5E5E5' }}>
+                                            <td style={{ padding: '0.4rem 0' }}>{u.full_name || u.email || `#${u.user_id}`}</td>
+                                            <td>{u.role}</td>
+                                            <td>{u.requests}</td>
+                                            <td>{u.last_seen ? new Date(u.last_seen).toLocaleString('ru-RU', { dateStyle: 'short', timeStyle: 'short' }) : '—'}</td>
+                                        </tr>
+                                    ))}
+                                </tbody>
+                            </table>
+                        </div>
+                    </div>
+                )}
             </div>
 
         </div>
@@ -0,0 +1,323 @@
+import { useState, useEffect } from 'react';
+import { showToast } from '../../utils/toast';
+
+function BrandingSettings() {
+    const [branding, setBranding] = useState({ institution_name: '', logo_url: '', report_header: '', report_footer: '' });
+    const [loading, setLoading] = useState(true);
+    const [saving, setSaving] = useState(false);
+    const [templates, setTemplates] = useState([]);
+    const [templateName, setTemplateName] = useState('');
+    const [templateFile, setTemplateFile] = useState(null);
+
+    const loadTemplates = () => {
+        fetch('/api/admin/report-templates', { credentials: 'include' })
+            .then(res => res.json())
+            .then(data => setTemplates(Array.isArray(data) ? data : []))
+            .catch(err => console.error(err));
+    };
+
+    useEffect(() => {
+        loadTemplates();
+    }, []);
+
+    useEffect(() => {
+        fetch('/api/branding', { credentials: 'include' })
+            .then(res => res.json())
+            .then(data => {
+                setBranding(data);
+                setLoading(false);
+            })
+            .catch(err => {
+                console.error(err);
+                setLoading(false);
+            });
+    }, []);
+
+    const handleSave = async () => {
+        setSaving(true);
+        try {
+            const res = await fetch('/api/admin/branding', {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    institution_name: branding.institution_name,
+                    report_header: branding.report_header,
+                    report_footer: branding.report_footer
+                }),
+                credentials: 'include'
+            });
+            const data = await res.json();
+            if (!res.ok) {
+                showToast.error(data.error || 'Не удалось сохранить');
+                return;
+            }
+            setBranding(data);
+            showToast.success('Оформление сохранено');
+        } catch (err) {
+            console.error(err);
+            showToast.error('Ошибка сети');
+        } finally {
+            setSaving(false);
+        }
+    };
+
+    const handleLogo = async (file) => {
+        if (!file) return;
+        const formData = new FormData();
+        formData.append('logo', file);
+        try {
+            const res = await fetch('/api/admin/branding/logo', {
+                method: 'POST',
+                body: formData,
+                credentials: 'include'
+            });
+            const data = await res.json();
+            if (!res.ok) {
+                showToast.error(data.error || 'Не удалось загрузить логотип');
+                return;
+            }
+            setBranding(data);
+        } catch (err) {
+            console.error(err);
+            showToast.error('Ошибка сети');
+        }
+    };
+
+    const handleGamification = async (enabled) => {
+        const res = await fetch('/api/admin/gamification', {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ enabled }),
+            credentials: 'include'
+        });
+        const data = await res.json();
+        if (!res.ok) {
+            showToast.error(data.error || 'Не удалось сохранить');
+            return;
+        }
+        setBranding(data);
+    };
+
+    const handleRemoveLogo = async () => {
+        const res = await fetch('/api/admin/branding/logo', { method: 'DELETE', credentials: 'include' });
+        if (res.ok) {
+            setBranding(await res.json());
+        }
+    };
+
+    const handlePreviewFile = async () => {
+        if (!templateFile) return;
+        const formData = new FormData();
+        formData.append('template', templateFile);
+        try {
+            const res = await fetch('/api/admin/report-templates/preview', {
+                method: 'POST',
+                body: formData,
+                credentials: 'include'
+            });
+            if (!res.ok) {
+                const data = await res.json();
+                showToast.error(data.error || 'Не удалось построить предпросмотр');
+                return;
+            }
+            const url = URL.createObjectURL(await res.blob());
+            window.open(url, '_blank');
+        } catch (err) {
+            console.error(err);
+            showToast.error('Ошибка сети');
+        }
+    };
+
+    const handleUploadTemplate = async () => {
+        if (!templateFile || !templateName.trim()) {
+            showToast.error('Укажите название и файл шаблона');
+            return;
+        }
+        const formData = new FormData();
+        formData.append('name', templateName.trim());
+        formData.append('template', templateFile);
+        try {
+            const res = await fetch('/api/admin/report-templates', {
+                method: 'POST',
+                body: formData,
+                credentials: 'include'
+            });
+            const data = await res.json();
+            if (!res.ok) {
+                showToast.error(data.error || 'Не удалось загрузить шаблон');
+                return;
+            }
+            setTemplateName('');
+            setTemplateFile(null);
+            showToast.success('Шаблон загружен');
+            loadTemplates();
+        } catch (err) {
+            console.error(err);
+            showToast.error('Ошибка сети');
+        }
+    };
+
+    const handleDownloadDefault = async () => {
+        const res = await fetch('/api/admin/report-templates/default', { credentials: 'include' });
+        if (!res.ok) return;
+        const data = await res.json();
+        const url = URL.createObjectURL(new Blob([data.body], { type: 'text/html' }));
+        const a = document.createElement('a');
+        a.href = url;
+        a.download = 'report_template.html';
+        a.click();
+        URL.revokeObjectURL(url);
+    };
+
+    const handleTemplateAction = async (url, method) => {
+        const res = await fetch(url, { method, credentials: 'include' });
+        if (!res.ok) {
+            const data = await res.json();
+            showToast.error(data.error || 'Операция не выполнена');
+        }
+        loadTemplates();
+    };
+
+    if (loading) return <div className="container">Загрузка...</div>;
+
+    return (
+        <div className="container">
+            <div style={{ marginBottom: '3rem', borderBottom: '2px solid black', paddingBottom: '1rem' }}>
+                <h1 className="text-huge" style={{ fontSize: '4rem', lineHeight: 0.9 }}>Оформление.</h1>
+                <p style={{ color: 'var(--text-dim)', marginTop: '1rem' }}>
+                    Название организации, логотип и тексты, которые выводятся в отчётах о проверке.
+                </p>
+            </div>
+
+            <div style={{ display: 'grid', gridTemplateColumns: '1fr', gap: '2rem', maxWidth: '48rem' }}>
+                <div>
+                    <label>Название организации</label>
+                    <input
+                        className="input-field"
+                        type="text"
+                        value={branding.institution_name}
+                        onChange={e => setBranding({ ...branding, institution_name: e.target.value })}
+                    />
+                </div>
+
+                <div>
+                    <label>Логотип (PNG, JPEG или WebP, до 1 МБ)</label>
+                    <div style={{ display: 'flex', alignItems: 'center', gap: '1.5rem' }}>
+                        {branding.logo_url && (
+                            <img src={branding.logo_url} alt="Логотип" style={{ height: '64px', border: '1px solid #E5E5E5' }} />
+                        )}
+                        <input type="file" accept=".png,.jpg,.jpeg,.webp" onChange={e => handleLogo(e.target.files[0])} />
+                        {branding.logo_url && (
+                            <button className="btn btn-ghost" onClick={handleRemoveLogo} style={{ fontSize: '0.8rem', padding: '6px 12px' }}>
+                                УДАЛИТЬ
+                            </button>
+                        )}
+                    </div>
+                </div>
+
+                <div>
+                    <label>Шапка отчёта</label>
+                    <textarea
+                        className="input-field"
+                        rows={3}
+                        value={branding.report_header}
+                        onChange={e => setBranding({ ...branding, report_header: e.target.value })}
+                    />
+                </div>
+
+                <div>
+                    <label>Подвал отчёта</label>
+                    <textarea
+                        className="input-field"
+                        rows={3}
+                        value={branding.report_footer}
+                        onChange={e => setBranding({ ...branding, report_footer: e.target.value })}
+                    />
+                </div>
+
+                <div>
+                    <button className="btn" onClick={handleSave} disabled={saving}>
+                        {saving ? 'СОХРАНЕНИЕ...' : 'СОХРАНИТЬ'}
+                    </button>
+                </div>
+            </div>
+
+            <div style={{ marginTop: '4rem', maxWidth: '48rem' }}>
+                <h2 style={{ borderBottom: '2px solid black', paddingBottom: '0.5rem' }}>Рейтинги и достижения</h2>
+                <p style={{ color: 'var(--text-dim)' }}>
+                    Анонимные рейтинги групп (лучшая оценка с первой попытки, меньше всего попыток до зачёта)
+                    и значки достижений для студентов. По умолчанию выключены.
+                </p>
+                <label style={{ display: 'flex', alignItems: 'center', gap: '0.75rem', cursor: 'pointer' }}>
+                    <input
+                        type="checkbox"
+                        checked={!!branding.gamification_enabled}
+                        onChange={e => handleGamification(e.target.checked)}
+                    />
+                    Включить рейтинги и достижения
+                </label>
+            </div>
+
+            <div style={{ marginTop: '4rem', maxWidth: '48rem' }}>
+                <h2 style={{ borderBottom: '2px solid black', paddingBottom: '0.5rem' }}>Шаблон листа нормоконтроля</h2>
+                <p style={{ color: 'var(--text-dim)' }}>
+                    Шаблон — HTML-файл с подстановками Go html/template. Возьмите за основу встроенный шаблон,
+                    проверьте свой через предпросмотр и сделайте его активным.
+                </p>
+                <button className="btn btn-ghost" onClick={handleDownloadDefault} style={{ fontSize: '0.8rem', padding: '6px 12px' }}>
+                    СКАЧАТЬ ВСТРОЕННЫЙ ШАБЛОН
+                </button>
+
+                <div style={{ display: 'grid', gap: '1rem', marginTop: '1.5rem' }}>
+                    <input
+                        className="input-field"
+                        type="text"
+                        placeholder="Название шаблона"
+                        value={templateName}
+                        onChange={e => setTemplateName(e.target.value)}
+                    />
+                    <input type="file" accept=".html,.htm,.tmpl" onChange={e => setTemplateFile(e.target.files[0] || null)} />
+                    <div style={{ display: 'flex', gap: '1rem' }}>
+                        <button className="btn btn-ghost" onClick={handlePreviewFile} disabled={!templateFile}>ПРЕДПРОСМОТР</button>
+                        <button className="btn" onClick={handleUploadTemplate} disabled={!templateFile}>ЗАГРУЗИТЬ</button>
+                    </div>
+                </div>
+
+                <table style={{ width: '100%', marginTop: '2rem', borderCollapse: 'collapse' }}>
+                    <tbody>
+                        <tr style={{ borderBottom: '1px solid #E5E5E5' }}>
+                            <td style={{ padding: '0.5rem 0' }}>Встроенный шаблон</td>
+                            <td style={{ textAlign: 'right' }}>
+                                {templates.some(t => t.is_active) ? (
+                                    <button className="btn btn-ghost" onClick={() => handleTemplateAction('/api/admin/report-templates/active', 'DELETE')} style={{ fontSize: '0.8rem', padding: '6px 12px' }}>
+                                        ИСПОЛЬЗОВАТЬ
+                                    </button>
+                                ) : <strong>АКТИВЕН</strong>}
+                            </td>
+                        </tr>
+                        {templates.map(t => (
+                            <tr key={t.id} style={{ borderBottom: '1px solid #E5E5E5' }}>
+                                <td style={{ padding: '0.5rem 0' }}>{t.name}</td>
+                                <td style={{ textAlign: 'right', display: 'flex', gap: '0.5rem', justifyContent: 'flex-end', padding: '0.5rem 0' }}>
+                                    <a className="btn btn-ghost" href={`/api/admin/report-templates/${t.id}/preview`} target="_blank" rel="noreferrer" style={{ fontSize: '0.8rem', padding: '6px 12px' }}>
+                                        ПРЕДПРОСМОТР
+                                    </a>
+                                    {t.is_active ? <strong style={{ alignSelf: 'center' }}>АКТИВЕН</strong> : (
+                                        <button className="btn btn-ghost" onClick={() => handleTemplateAction(`/api/admin/report-templates/${t.id}/activate`, 'PUT')} style={{ fontSize: '0.8rem', padding: '6px 12px' }}>
+                                            ИСПОЛЬЗОВАТЬ
+                                        </button>
+                                    )}
+                                    <button className="btn btn-ghost" onClick={() => handleTemplateAction(`/api/admin/report-templates/${t.id}`, 'DELETE')} style={{ fontSize: '0.8rem', padding: '6px 12px' }}>
+                                        УДАЛИТЬ
+                                    </button>
+                                </td>
+                            </tr>
+                        ))}
+                    </tbody>
+                </table>
+            </div>
+        </div>
+    );
+}
+
+export default BrandingSettings;
@@ -0,0 +1,211 @@
+import { useState, useEffect } from 'react';
+import { showToast } from '../../utils/toast';
+import { ERROR_CATEGORIES } from '../student/utils/errorConfig';
+
+const KIND_NAMES = { link: 'Ссылка', video: 'Видео', pdf: 'PDF' };
+
+const RULE_TYPES = Object.values(ERROR_CATEGORIES).flatMap(category => category.types);
+
+const emptyForm = { rule_type: '', title: '', kind: 'link', url: '', start: '' };
+
+// "2:15" or "135" -> 135
+const parseStart = (value) => {
+    const parts = String(value).trim().split(':').map(Number);
+    if (parts.some(isNaN)) return 0;
+    return parts.reduce((total, part) => total * 60 + part, 0);
+};
+
+function LearningResources() {
+    const [resources, setResources] = useState([]);
+    const [form, setForm] = useState(emptyForm);
+    const [file, setFile] = useState(null);
+    const [saving, setSaving] = useState(false);
+
+    const loadResources = () => {
+        fetch('/api/learning-resources', { credentials: 'include' })
+            .then(res => res.json())
+            .then(data => setResources(Array.isArray(data) ? data : []))
+            .catch(err => console.error(err));
+    };
+
+    useEffect(() => {
+        loadResources();
+    }, []);
+
+    const handleCreate = async () => {
+        if (!form.rule_type.trim() || !form.title.trim()) {
+            showToast.error('Укажите тип ошибки и название');
+            return;
+        }
+        setSaving(true);
+        try {
+            let res;
+            if (form.kind === 'pdf') {
+                if (!file) {
+                    showToast.error('Выберите PDF-файл');
+                    return;
+                }
+                const formData = new FormData();
+                formData.append('rule_type', form.rule_type.trim());
+                formData.append('title', form.title.trim());
+                formData.append('file', file);
+                res = await fetch('/api/admin/learning-resources/pdf', {
+                    method: 'POST',
+                    body: formData,
+                    credentials: 'include'
+                });
+            } else {
+                res = await fetch('/api/admin/learning-resources', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({
+                        rule_type: form.rule_type.trim(),
+                        title: form.title.trim(),
+                        kind: form.kind,
+                        url: form.url.trim(),
+                        start_seconds: form.kind === 'video' ? parseStart(form.start) : 0
+                    }),
+                    credentials: 'include'
+                });
+            }
+            const data = await res.json();
+            if (!res.ok) {
+                showToast.error(data.error || 'Не удалось сохранить материал');
+                return;
+            }
+            setForm({ ...emptyForm, rule_type: form.rule_type });
+            setFile(null);
+            showToast.success('Материал добавлен');
+            loadResources();
+        } catch (err) {
+            console.error(err);
+            showToast.error('Ошибка сети');
+        } finally {
+            setSaving(false);
+        }
+    };
+
+    const handleDelete = async (id) => {
+        const res = await fetch(`/api/admin/learning-resources/${id}`, { method: 'DELETE', credentials: 'include' });
+        if (!res.ok) {
+            const data = await res.json();
+            showToast.error(data.error || 'Не удалось удалить материал');
+        }
+        loadResources();
+    };
+
+    return (
+        <div className="container">
+            <div style={{ marginBottom: '3rem', borderBottom: '2px solid black', paddingBottom: '1rem' }}>
+                <h1 className="text-huge" style={{ fontSize: '4rem', lineHeight: 0.9 }}>Материалы.</h1>
+                <p style={{ color: 'var(--text-dim)', marginTop: '1rem' }}>
+                    Ссылки, PDF и фрагменты видео, которые студент видит рядом с ошибкой соответствующего типа.
+                </p>
+            </div>
+
+            <div style={{ display: 'grid', gridTemplateColumns: '1fr', gap: '1.5rem', maxWidth: '48rem' }}>
+                <div className="grid-2">
+                    <div>
+                        <label>Тип ошибки</label>
+                        <input
+                            className="input-field"
+                            type="text"
+                            list="rule-types"
+                            placeholder="indent"
+                            value={form.rule_type}
+                            onChange={e => setForm({ ...form, rule_type: e.target.value })}
+                        />
+                        <datalist id="rule-types">
+                            {RULE_TYPES.map(type => <option key={type} value={type} />)}
+                        </datalist>
+                    </div>
+                    <div>
+                        <label>Вид материала</label>
+                        <select
+                            className="input-field"
+                            value={form.kind}
+                            onChange={e => setForm({ ...form, kind: e.target.value })}
+                        >
+                            <option value="link">Ссылка</option>
+                            <option value="video">Видео</option>
+                            <option value="pdf">PDF</option>
+                        </select>
+                    </div>
+                </div>
+
+                <div>
+                    <label>Название</label>
+                    <input
+                        className="input-field"
+                        type="text"
+                        placeholder="Как настроить абзацный отступ (2 мин)"
+                        value={form.title}
+                        onChange={e => setForm({ ...form, title: e.target.value })}
+                    />
+                </div>
+
+                {form.kind === 'pdf' ? (
+                    <div>
+                        <label>Файл (PDF, до 20 МБ)</label>
+                        <input type="file" accept=".pdf" onChange={e => setFile(e.target.files[0] || null)} />
+                    </div>
+                ) : (
+                    <div className={form.kind === 'video' ? 'grid-2' : undefined}>
+                        <div>
+                            <label>Адрес (http или https)</label>
+                            <input
+                                className="input-field"
+                                type="url"
+                                placeholder="https://"
+                                value={form.url}
+                                onChange={e => setForm({ ...form, url: e.target.value })}
+                            />
+                        </div>
+                        {form.kind === 'video' && (
+                            <div>
+                                <label>Начало фрагмента (мм:сс)</label>
+                                <input
+                                    className="input-field"
+                                    type="text"
+                                    placeholder="2:15"
+                                    value={form.start}
+                                    onChange={e => setForm({ ...form, start: e.target.value })}
+                                />
+                            </div>
+                        )}
+                    </div>
+                )}
+
+                <div>
+                    <button className="btn" onClick={handleCreate} disabled={saving}>
+                        {saving ? 'СОХРАНЕНИЕ...' : 'ДОБАВИТЬ'}
+                    </button>
+                </div>
+            </div>
+
+            <table style={{ width: '100%', maxWidth: '48rem', marginTop: '3rem', borderCollapse: 'collapse' }}>
+                <tbody>
+                    {resources.length === 0 && (
+                        <tr><td style={{ padding: '0.5rem 0', color: 'var(--text-dim)' }}>Материалов пока нет.</td></tr>
+                    )}
+                    {resources.map(r => (
+                        <tr key={r.id} style={{ borderBottom: '1px solid #E5E5E5' }}>
+                            <td style={{ padding: '0.5rem 0', fontFamily: 'monospace', fontSize: '0.85rem' }}>{r.rule_type}</td>
+                            <td style={{ padding: '0.5rem 0.5rem' }}>
+                                <a href={r.url} target="_blank" rel="noreferrer" style={{ color: 'black' }}>{r.title}</a>
+                            </td>
+                            <td style={{ color: 'var(--text-dim)', fontSize: '0.85rem' }}>{KIND_NAMES[r.kind] || r.kind}</td>
+                            <td style={{ textAlign: 'right', padding: '0.5rem 0' }}>
+                                <button className="btn btn-ghost" onClick={() => handleDelete(r.id)} style={{ fontSize: '0.8rem', padding: '6px 12px' }}>
+                                    УДАЛИТЬ
+                                </button>
+                            </td>
+                        </tr>
+                    ))}
+                </tbody>
+            </table>
+        </div>
+    );
+}
+
+export default LearningResources;
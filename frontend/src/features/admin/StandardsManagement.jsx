@@ -74,7 +74,7 @@ function StandardsManagement() {
                             </span>
                             <div style={{ display: 'flex', gap: '1rem' }}>
                                 <button
-                                    onClick={() => handleDelete(std.id)}
+                                    onClick={() => handleDelete(std.uuid)}
                                     style={{
                                         background: 'none',
                                         border: 'none',
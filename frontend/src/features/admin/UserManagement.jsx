@@ -1,5 +1,7 @@
 import { useState, useEffect } from 'react';
+import { useNavigate } from 'react-router-dom';
 import Pagination from '../common/Pagination';
+import { useAuth } from '../auth/AuthContext';
 
 function UserManagement() {
     const [users, setUsers] = useState([]);
@@ -7,6 +9,8 @@ function UserManagement() {
     const [searchTerm, setSearchTerm] = useState('');
     const [currentPage, setCurrentPage] = useState(1);
     const itemsPerPage = 10;
+    const { impersonate } = useAuth();
+    const navigate = useNavigate();
 
     useEffect(() => {
         fetchUsers();
@@ -44,6 +48,18 @@ function UserManagement() {
         }
     };
 
+    const handleImpersonate = async (user) => {
+        const reason = window.prompt(`Причина входа от имени ${user.full_name} (попадёт в журнал аудита):`);
+        if (reason === null) return;
+
+        try {
+            await impersonate(user.uuid, reason);
+            navigate('/');
+        } catch (err) {
+            console.error(err);
+        }
+    };
+
     const filteredUsers = users.filter(user =>
         user.full_name.toLowerCase().includes(searchTerm.toLowerCase()) ||
         user.email.toLowerCase().includes(searchTerm.toLowerCase())
@@ -84,7 +100,7 @@ function UserManagement() {
                 {/* Header Row */}
                 <div style={{
                     display: 'grid',
-                    gridTemplateColumns: '80px 2fr 2fr 1fr 1fr 100px',
+                    gridTemplateColumns: '80px 2fr 2fr 1fr 1fr 200px',
                     padding: '1rem 2rem',
                     borderBottom: '1px solid black',
                     background: '#F4F4F4',
@@ -104,7 +120,7 @@ function UserManagement() {
                 {filteredUsers.slice((currentPage - 1) * itemsPerPage, currentPage * itemsPerPage).map((user) => (
                     <div key={user.id} style={{
                         display: 'grid',
-                        gridTemplateColumns: '80px 2fr 2fr 1fr 1fr 100px',
+                        gridTemplateColumns: '80px 2fr 2fr 1fr 1fr 200px',
                         padding: '1.5rem 2rem',
                         borderBottom: '1px solid #E5E5E5',
                         alignItems: 'center',
@@ -134,8 +150,16 @@ function UserManagement() {
                             )}
                         </div>
                         <div style={{ textAlign: 'right' }}>
+                            {user.role !== 'admin' && user.status === 'active' && (
+                                <button
+                                    onClick={() => handleImpersonate(user)}
+                                    style={{ background: 'none', border: 'none', cursor: 'pointer', color: 'black', textTransform: 'uppercase', fontWeight: 700, fontSize: '0.8rem', marginRight: '1rem' }}
+                                >
+                                    Войти как
+                                </button>
+                            )}
                             <button
-                                onClick={() => handleDelete(user.id)}
+                                onClick={() => handleDelete(user.uuid)}
                                 style={{ background: 'none', border: 'none', cursor: 'pointer', color: '#FF3B30', textTransform: 'uppercase', fontWeight: 700, fontSize: '0.8rem' }}
                             >
                                 Удалить
@@ -103,8 +103,39 @@ export const AuthProvider = ({ children }) => {
         setUser(null);
     };
 
+    // Admin "view as user": the backend swaps the session cookie for a
+    // short-lived read-only token and restores the admin session on stop.
+    const impersonate = async (userId, reason) => {
+        const res = await fetch(`/api/admin/users/${userId}/impersonate`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ reason }),
+            credentials: 'include'
+        });
+        const data = await res.json();
+        if (!res.ok) {
+            showToast.error(data.error || 'Не удалось войти от имени пользователя');
+            throw new Error(data.error);
+        }
+        setUser(data.user);
+    };
+
+    const stopImpersonation = async () => {
+        try {
+            const res = await fetch('/api/auth/impersonation/stop', {
+                method: 'POST',
+                credentials: 'include'
+            });
+            const data = await res.json();
+            setUser(res.ok ? data.user : null);
+        } catch (e) {
+            console.error(e);
+            setUser(null);
+        }
+    };
+
     return (
-        <AuthContext.Provider value={{ user, login, register, logout, loading }}>
+        <AuthContext.Provider value={{ user, login, register, logout, loading, impersonate, stopImpersonation }}>
             {children}
         </AuthContext.Provider>
     );
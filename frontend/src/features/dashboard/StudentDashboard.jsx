@@ -254,7 +254,7 @@ export default function StudentDashboard() {
             {selectedStandard.modules && selectedStandard.modules.length > 0 ? (
                 <div className="grid-2" style={{ gap: '2rem' }}>
                     {selectedStandard.modules.map(module => (
-                        <ModuleCard key={module.id} module={module} standardId={selectedStandard.id} />
+                        <ModuleCard key={module.id} module={module} standardId={selectedStandard.uuid} />
                     ))}
                 </div>
             ) : (
@@ -275,6 +275,9 @@ function ModuleCard({ module, standardId }) {
     const abortControllerRef = useRef(null);
 
     const [isDragging, setIsDragging] = useState(false);
+    // Optional work metadata for the report; group and specialty come from the assignment
+    const [topic, setTopic] = useState('');
+    const [supervisor, setSupervisor] = useState('');
 
     const processFile = async (file) => {
         if (!file) return;
@@ -293,6 +296,9 @@ function ModuleCard({ module, standardId }) {
         formData.append('document', file);
         formData.append('config', JSON.stringify(module.config));
         formData.append('standard_id', standardId);
+        formData.append('module_id', module.id);
+        if (topic.trim()) formData.append('topic', topic.trim());
+        if (supervisor.trim()) formData.append('supervisor', supervisor.trim());
 
         try {
             const res = await fetch('/api/check', {
@@ -355,10 +361,10 @@ function ModuleCard({ module, standardId }) {
         e.stopPropagation();
         setIsDragging(false);
         const file = e.dataTransfer.files[0];
-        if (file && file.name.endsWith('.docx')) {
+        if (file && /\.(docx|odt|pptx)$/i.test(file.name)) {
             processFile(file);
         } else if (file) {
-            showToast.error('Поддерживаются только файлы .docx');
+            showToast.error('Поддерживаются только файлы .docx, .odt и .pptx');
         }
     };
 
@@ -376,6 +382,27 @@ function ModuleCard({ module, standardId }) {
                 <p style={{ color: 'var(--text-dim)' }}>Модуль автоматической проверки</p>
             </div>
 
+            {(status === 'idle' || status === 'error') && (
+                <div style={{ display: 'flex', gap: '0.5rem', marginBottom: '1rem' }}>
+                    <input
+                        type="text"
+                        value={topic}
+                        onChange={e => setTopic(e.target.value)}
+                        placeholder="Тема работы (необязательно)"
+                        maxLength={300}
+                        style={{ flex: 2, padding: '8px', border: '1px solid #D1D5DB' }}
+                    />
+                    <input
+                        type="text"
+                        value={supervisor}
+                        onChange={e => setSupervisor(e.target.value)}
+                        placeholder="Руководитель"
+                        maxLength={300}
+                        style={{ flex: 1, padding: '8px', border: '1px solid #D1D5DB' }}
+                    />
+                </div>
+            )}
+
             {status === 'idle' || status === 'error' ? (
                 <div
                     className={`upload-zone ${isDragging ? 'dragging' : ''}`}
@@ -402,12 +429,12 @@ function ModuleCard({ module, standardId }) {
                     onMouseEnter={e => { if (!isDragging) { e.currentTarget.style.borderColor = 'var(--accent-primary)'; e.currentTarget.style.background = '#FFF5F5'; } }}
                     onMouseLeave={e => { if (!isDragging) { e.currentTarget.style.borderColor = '#D1D5DB'; e.currentTarget.style.background = '#FAFAFA'; } }}
                 >
-                    <input id={`file-${module.id}`} type="file" onChange={handleFileSelect} hidden accept=".docx" />
+                    <input id={`file-${module.id}`} type="file" onChange={handleFileSelect} hidden accept=".docx,.odt,.pptx" />
                     <div style={{ marginBottom: '1.5rem', color: '#6B7280' }}>
                         <DocumentUploadIcon size={56} />
                     </div>
                     <div style={{ fontWeight: 600, fontSize: '1.1rem', color: '#111827', marginBottom: '0.5rem', textTransform: 'uppercase' }}>
-                        Загрузить документ (.docx)
+                        Загрузить документ (.docx, .odt, .pptx)
                     </div>
                     <div style={{ fontSize: '0.85rem', color: '#6B7280' }}>
                         Нажмите или перетащите файл для проверки
@@ -501,7 +528,9 @@ function ModuleCard({ module, standardId }) {
                 isOpen={showPreview && !!result}
                 onClose={() => setShowPreview(false)}
                 documentName={module.name}
+                resultId={result?.uuid}
                 score={result?.score}
+                summary={result?.summary}
                 contentJSON={result?.content_json}
                 violations={result?.violations}
                 file={selectedFile}
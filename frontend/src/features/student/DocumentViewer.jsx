@@ -120,6 +120,7 @@ export default function DocumentViewer({ file, contentJSON, violations: propViol
                 error: localViolations.filter(v => v.severity === 'error').length,
                 warning: localViolations.filter(v => v.severity === 'warning').length,
                 info: localViolations.filter(v => v.severity === 'info').length,
+                hint: localViolations.filter(v => v.severity === 'hint').length,
                 total: localViolations.length
             };
             console.log('📊 Using backend score:', result);
@@ -1,32 +1,58 @@
-import { useState, useEffect } from 'react';
+import { useState, useEffect, useRef, useCallback } from 'react';
 import { useNavigate } from 'react-router-dom';
 import ReportModal from './components/ReportModal';
-import Pagination from '../common/Pagination';
 import SlotCounter from '../../components/SlotCounter';
 
+// 'checked' is the status used before the processing pipeline was tracked.
+const isDone = (status) => status === 'done' || status === 'checked';
+const isFailed = (status) => typeof status === 'string' && status.startsWith('failed_');
+
+// Items per request; further pages are loaded by cursor while scrolling, so
+// checks added in the meantime do not shift the list.
+const PAGE_SIZE = 20;
+
 export default function HistoryPage() {
     const [history, setHistory] = useState([]);
     const [loading, setLoading] = useState(true);
     const [selectedItem, setSelectedItem] = useState(null); // Full detail object
     const [loadingDetail, setLoadingDetail] = useState(false);
-    const [currentPage, setCurrentPage] = useState(1);
-    const itemsPerPage = 10;
+    const [nextCursor, setNextCursor] = useState('');
+    const [loadingMore, setLoadingMore] = useState(false);
+    const sentinelRef = useRef(null);
     const navigate = useNavigate();
 
-    useEffect(() => {
-        fetch('/api/history', { credentials: 'include' })
+    const loadPage = useCallback((cursor) => {
+        const query = `limit=${PAGE_SIZE}` + (cursor ? `&cursor=${encodeURIComponent(cursor)}` : '');
+        setLoadingMore(true);
+        return fetch(`/api/history?${query}`, { credentials: 'include' })
             .then(res => res.json())
             .then(data => {
-                console.log('📊 History data received:', data);
-                setHistory(data);
-                setLoading(false);
+                const items = data.items || [];
+                setHistory(prev => {
+                    if (!cursor) return items;
+                    const seen = new Set(prev.map(item => item.id));
+                    return [...prev, ...items.filter(item => !seen.has(item.id))];
+                });
+                setNextCursor(data.next_cursor || '');
             })
-            .catch(err => {
-                console.error(err);
-                setLoading(false);
-            });
+            .catch(err => console.error(err))
+            .finally(() => setLoadingMore(false));
     }, []);
 
+    useEffect(() => {
+        loadPage('').finally(() => setLoading(false));
+    }, [loadPage]);
+
+    useEffect(() => {
+        const sentinel = sentinelRef.current;
+        if (!sentinel || !nextCursor || loadingMore) return;
+        const observer = new IntersectionObserver(entries => {
+            if (entries[0].isIntersecting) loadPage(nextCursor);
+        }, { rootMargin: '200px' });
+        observer.observe(sentinel);
+        return () => observer.disconnect();
+    }, [nextCursor, loadingMore, loadPage]);
+
     const handleItemClick = async (id) => {
         setLoadingDetail(true);
         try {
@@ -73,10 +99,10 @@ export default function HistoryPage() {
                         <div>Статус</div>
                     </div>
 
-                    {history.slice((currentPage - 1) * itemsPerPage, currentPage * itemsPerPage).map(item => (
+                    {history.map(item => (
                         <div
                             key={item.id}
-                            onClick={() => handleItemClick(item.id)}
+                            onClick={() => handleItemClick(item.uuid)}
                             style={{
                                 display: 'grid',
                                 gridTemplateColumns: '1.5fr 1fr 1fr 1fr',
@@ -104,8 +130,8 @@ export default function HistoryPage() {
                                 </span>
                             </div>
                             <div>
-                                <span className={`badge ${item.status === 'checked' ? 'success' : 'warning'}`}>
-                                    {item.status === 'checked' ? 'ПРОВЕРЕНО' : 'В РАБОТЕ'}
+                                <span className={`badge ${isDone(item.status) ? 'success' : isFailed(item.status) ? 'error' : 'warning'}`}>
+                                    {isDone(item.status) ? 'ПРОВЕРЕНО' : isFailed(item.status) ? 'ОШИБКА' : 'В РАБОТЕ'}
                                 </span>
                             </div>
                         </div>
@@ -113,12 +139,10 @@ export default function HistoryPage() {
                 </div>
             )}
 
-            {!loading && history.length > 0 && (
-                <Pagination
-                    currentPage={currentPage}
-                    totalPages={Math.ceil(history.length / itemsPerPage)}
-                    onPageChange={setCurrentPage}
-                />
+            {!loading && nextCursor && (
+                <div ref={sentinelRef} style={{ textAlign: 'center', padding: '2rem' }}>
+                    {loadingMore && <div className="spinner" style={{ margin: '0 auto', borderColor: '#000', borderTopColor: 'transparent' }}></div>}
+                </div>
             )}
 
             {/* Detail Viewer Modal */}
@@ -126,9 +150,12 @@ export default function HistoryPage() {
                 isOpen={!!selectedItem}
                 onClose={() => setSelectedItem(null)}
                 documentName={selectedItem?.document_name}
+                resultId={selectedItem?.uuid}
                 score={selectedItem?.score}
+                summary={selectedItem?.summary}
                 contentJSON={selectedItem?.content_json}
                 violations={selectedItem?.violations}
+                comments={selectedItem?.comments}
             />
 
             {loadingDetail && (
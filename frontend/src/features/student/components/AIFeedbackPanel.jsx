@@ -0,0 +1,65 @@
+import { useState, useEffect } from 'react';
+import { ERROR_CATEGORIES } from '../utils/errorConfig';
+import { showToast } from '../../../utils/toast';
+
+const categoryName = (key) => ERROR_CATEGORIES[key]?.name || {
+    images: 'Рисунки',
+    references: 'Список литературы'
+}[key] || key;
+
+/**
+ * Рекомендации по категориям нарушений, сгенерированные языковой моделью.
+ * Всегда показываются с пометкой об автоматической генерации.
+ */
+export default function AIFeedbackPanel({ resultId }) {
+    const [feedback, setFeedback] = useState(null);
+    const [loading, setLoading] = useState(false);
+
+    useEffect(() => {
+        setFeedback(null);
+    }, [resultId]);
+
+    if (!resultId) return null;
+
+    const handleGenerate = async () => {
+        setLoading(true);
+        try {
+            const res = await fetch(`/api/ai/feedback/${resultId}`, {
+                method: 'POST',
+                credentials: 'include'
+            });
+            const data = await res.json();
+            if (!res.ok) {
+                throw new Error(data.error || 'Feedback generation failed');
+            }
+            setFeedback(data);
+        } catch (error) {
+            console.error(error);
+            showToast.error(`Ошибка ИИ: ${error.message}`);
+        } finally {
+            setLoading(false);
+        }
+    };
+
+    if (!feedback) {
+        return (
+            <button className="btn btn-ghost" onClick={handleGenerate} disabled={loading} style={{ marginTop: '0.5rem' }}>
+                {loading ? 'ГЕНЕРАЦИЯ...' : 'СОВЕТЫ ПО ИСПРАВЛЕНИЮ (ИИ)'}
+            </button>
+        );
+    }
+
+    return (
+        <div style={{ marginTop: '0.75rem', border: '1px dashed black', padding: '0.75rem', maxWidth: '60rem', maxHeight: '30vh', overflowY: 'auto' }}>
+            <div style={{ fontSize: '0.75rem', textTransform: 'uppercase', color: 'var(--text-secondary, #4A4A4A)', marginBottom: '0.5rem' }}>
+                Автоматические рекомендации · {feedback.notice}
+            </div>
+            {feedback.categories.length === 0 && <p style={{ margin: 0 }}>Рекомендаций нет.</p>}
+            {feedback.categories.map(item => (
+                <div key={item.category} style={{ marginBottom: '0.5rem' }}>
+                    <strong>{categoryName(item.category)}.</strong> {item.advice}
+                </div>
+            ))}
+        </div>
+    );
+}
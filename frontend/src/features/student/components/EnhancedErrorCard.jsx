@@ -1,6 +1,28 @@
 import React from 'react';
 import { getCategoryConfig, getSeverityConfig, getFixSuggestions } from '../utils/errorConfig';
 
+const RESOURCE_ICONS = { link: '🔗', pdf: '📄', video: '▶️' };
+
+/**
+ * Ссылка на материал; видео открывается с нужной секунды
+ */
+const resourceHref = (resource) => {
+    if (resource.kind !== 'video' || !resource.start_seconds) return resource.url;
+    try {
+        const url = new URL(resource.url);
+        if (/youtube\.com|youtu\.be|rutube\.ru|vk\.com/.test(url.hostname)) {
+            url.searchParams.set('t', resource.start_seconds);
+            return url.toString();
+        }
+        url.hash = `t=${resource.start_seconds}`;
+        return url.toString();
+    } catch {
+        return resource.url;
+    }
+};
+
+const formatStart = (seconds) => `${Math.floor(seconds / 60)}:${String(seconds % 60).padStart(2, '0')}`;
+
 /**
  * Расширенная карточка ошибки с деталями и рекомендациями
  */
@@ -241,6 +263,48 @@ const EnhancedErrorCard = ({
                         </ol>
                     </div>
                 )}
+
+                {/* Учебные материалы */}
+                {violation.resources?.length > 0 && (
+                    <div style={{ marginTop: '1rem' }}>
+                        <div style={{
+                            fontSize: '0.7rem',
+                            color: '#888',
+                            marginBottom: '0.4rem',
+                            textTransform: 'uppercase',
+                            letterSpacing: '0.5px'
+                        }}>
+                            Материалы по теме
+                        </div>
+                        <div style={{ display: 'flex', flexDirection: 'column', gap: '0.4rem' }}>
+                            {violation.resources.map(resource => (
+                                <a
+                                    key={resource.id}
+                                    href={resourceHref(resource)}
+                                    target="_blank"
+                                    rel="noreferrer"
+                                    style={{
+                                        color: '#ddd',
+                                        fontSize: '0.9rem',
+                                        textDecoration: 'none',
+                                        background: '#2a2a2a',
+                                        padding: '0.6rem 0.75rem',
+                                        borderRadius: '6px',
+                                        display: 'flex',
+                                        alignItems: 'center',
+                                        gap: '0.5rem'
+                                    }}
+                                >
+                                    <span>{RESOURCE_ICONS[resource.kind] || '🔗'}</span>
+                                    <span style={{ flex: 1 }}>{resource.title}</span>
+                                    {resource.kind === 'video' && resource.start_seconds > 0 && (
+                                        <span style={{ color: '#888', fontFamily: 'monospace' }}>с {formatStart(resource.start_seconds)}</span>
+                                    )}
+                                </a>
+                            ))}
+                        </div>
+                    </div>
+                )}
             </div>
 
             {/* Навигация */}
@@ -1,8 +1,19 @@
-import React from 'react';
+import React, { useState, useEffect } from 'react';
 import DocumentViewer from '../DocumentViewer';
 import SlotCounter from '../../../components/SlotCounter';
+import AIFeedbackPanel from './AIFeedbackPanel';
+
+export default function ReportModal({ isOpen, onClose, documentName, resultId, score, summary, contentJSON, violations, comments, file }) {
+    const [branding, setBranding] = useState(null);
+
+    useEffect(() => {
+        if (!isOpen || branding) return;
+        fetch('/api/branding', { credentials: 'include' })
+            .then(res => res.ok ? res.json() : null)
+            .then(setBranding)
+            .catch(err => console.error(err));
+    }, [isOpen, branding]);
 
-export default function ReportModal({ isOpen, onClose, documentName, score, contentJSON, violations, file }) {
     if (!isOpen) return null;
 
     return (
@@ -13,6 +24,15 @@ export default function ReportModal({ isOpen, onClose, documentName, score, cont
         }}>
             <div style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center', marginBottom: '1rem', borderBottom: '2px solid black', paddingBottom: '1rem' }}>
                 <div>
+                    {(branding?.logo_url || branding?.institution_name) && (
+                        <div style={{ display: 'flex', alignItems: 'center', gap: '0.75rem', marginBottom: '0.5rem' }}>
+                            {branding.logo_url && <img src={branding.logo_url} alt="" style={{ height: '40px' }} />}
+                            {branding.institution_name && <span style={{ fontWeight: 700, textTransform: 'uppercase', fontSize: '0.85rem' }}>{branding.institution_name}</span>}
+                        </div>
+                    )}
+                    {branding?.report_header && (
+                        <p style={{ margin: '0 0 0.5rem', color: 'var(--text-dim)', whiteSpace: 'pre-line', fontSize: '0.85rem' }}>{branding.report_header}</p>
+                    )}
                     <h2 style={{ color: 'black', margin: 0, fontSize: '1.5rem' }}>ОТЧЕТ: {documentName || 'Документ'}</h2>
                     {score !== undefined && score !== null && (
                         <span style={{
@@ -22,8 +42,32 @@ export default function ReportModal({ isOpen, onClose, documentName, score, cont
                             Оценка: <SlotCounter value={Number(score)} />/100
                         </span>
                     )}
+                    {summary && (
+                        <p style={{ margin: '0.5rem 0 0', color: 'black', maxWidth: '60rem' }}>{summary}</p>
+                    )}
+                    {comments?.length > 0 && (
+                        <div style={{ marginTop: '0.75rem', maxWidth: '60rem', borderLeft: '3px solid black', paddingLeft: '0.75rem' }}>
+                            {comments.map(comment => (
+                                <div key={comment.id} style={{ marginBottom: '0.5rem' }}>
+                                    <div style={{ fontSize: '0.75rem', fontWeight: 700, textTransform: 'uppercase' }}>
+                                        {comment.author_name || 'Преподаватель'}
+                                        {comment.rule_type && <span style={{ fontFamily: 'monospace', fontWeight: 400, marginLeft: '0.5rem', color: 'var(--text-dim)' }}>{comment.rule_type}</span>}
+                                    </div>
+                                    <p style={{ margin: 0, whiteSpace: 'pre-line' }}>{comment.body}</p>
+                                </div>
+                            ))}
+                        </div>
+                    )}
+                    <AIFeedbackPanel resultId={resultId} />
+                </div>
+                <div style={{ display: 'flex', alignItems: 'center', gap: '0.5rem' }}>
+                    {resultId && (
+                        <a className="btn btn-ghost" href={`/api/history/${resultId}/report`} target="_blank" rel="noreferrer" style={{ fontSize: '0.8rem', padding: '6px 12px' }}>
+                            ЛИСТ НОРМОКОНТРОЛЯ
+                        </a>
+                    )}
+                    <button className="btn btn-ghost" onClick={onClose} style={{ fontSize: '1.5rem', padding: '0.5rem 1rem' }}>✕</button>
                 </div>
-                <button className="btn btn-ghost" onClick={onClose} style={{ fontSize: '1.5rem', padding: '0.5rem 1rem' }}>✕</button>
             </div>
             <DocumentViewer
                 file={file}
@@ -31,6 +75,11 @@ export default function ReportModal({ isOpen, onClose, documentName, score, cont
                 violations={violations}
                 score={score}
             />
+            {branding?.report_footer && (
+                <p style={{ margin: '1rem 0 0', color: 'var(--text-dim)', whiteSpace: 'pre-line', fontSize: '0.8rem', borderTop: '1px solid black', paddingTop: '0.5rem' }}>
+                    {branding.report_footer}
+                </p>
+            )}
         </div>
     );
 }
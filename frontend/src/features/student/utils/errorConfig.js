@@ -40,6 +40,12 @@ export const SEVERITY_CONFIG = {
         priority: 4,
         marker: '○', // Пустой круг
         color: SWISS_COLORS.gray300
+    },
+    hint: {
+        name: 'Совет',
+        priority: 5,
+        marker: '·',
+        color: SWISS_COLORS.gray300
     }
 };
 
@@ -80,7 +86,88 @@ export const ERROR_CATEGORIES = {
             'style_italic',
             'style_underline',
             'style_caps',
-            'text_case'
+            'text_case',
+            'typography_dash',
+            'typography_quotes',
+            'typography_double_space',
+            'typography_space_before_punctuation',
+            'typography_nbsp',
+            'typography_hanging_preposition',
+            'hyphenation_auto',
+            'heading_hyphenation'
+        ]
+    },
+    units: {
+        name: 'Числа и единицы',
+        types: [
+            'unit_spacing',
+            'unit_name',
+            'decimal_separator',
+            'number_range_dash'
+        ]
+    },
+    lists: {
+        name: 'Списки',
+        types: [
+            'list_alignment',
+            'list_marker',
+            'list_number_format',
+            'list_punctuation',
+            'list_case',
+            'list_indent',
+            'list_depth'
+        ]
+    },
+    footnotes: {
+        name: 'Сноски',
+        types: [
+            'footnote_font_size',
+            'footnote_numbering',
+            'endnotes',
+            'footnotes_per_page'
+        ]
+    },
+    revisions: {
+        name: 'Рецензирование',
+        types: [
+            'tracked_changes',
+            'review_comments'
+        ]
+    },
+    integrity: {
+        name: 'Добросовестность',
+        types: [
+            'integrity_empty_paragraphs',
+            'integrity_spacing',
+            'integrity_font_size',
+            'integrity_invisible_text',
+            'integrity_section_margins',
+            'integrity_image_scale',
+            'doc_properties_author',
+            'doc_properties_converter',
+            'doc_properties_editing_time'
+        ]
+    },
+    presentation: {
+        name: 'Презентация',
+        types: [
+            'presentation_slide_count',
+            'presentation_title_slide',
+            'presentation_font_size',
+            'presentation_slide_number',
+            'presentation_slide_title'
+        ]
+    },
+    article: {
+        name: 'Статья',
+        types: [
+            'article_udc',
+            'article_authors',
+            'article_affiliation',
+            'article_abstract',
+            'article_keywords',
+            'article_reference_style',
+            'article_columns'
         ]
     },
     structure: {
@@ -91,10 +178,14 @@ export const ERROR_CATEGORIES = {
             'heading_hierarchy',
             'toc_page_mismatch',
             'toc_missing_heading',
+            'toc_manual',
             'section_numbering',
             'page_break',
             'section_order',
-            'section_missing'
+            'section_missing',
+            'widow_control',
+            'heading_keep_next',
+            'heading_last_on_page'
         ]
     },
     content: {
@@ -111,8 +202,12 @@ export const ERROR_CATEGORIES = {
         name: 'Параметры страницы',
         types: [
             'page_orientation',
+            'section_orientation',
             'page_size',
-            'page_numbering'
+            'page_numbering',
+            'page_number_alignment',
+            'header_title_page',
+            'header_running_title'
         ]
     },
     tables: {
@@ -126,7 +221,29 @@ export const ERROR_CATEGORIES = {
             'table_borders_missing',
             'table_header_missing',
             'table_row_height',
-            'table_width'
+            'table_width',
+            'table_text_reference_missing',
+            'table_not_referenced'
+        ]
+    },
+    images: {
+        name: 'Рисунки',
+        types: [
+            'image_alignment',
+            'image_alt_text_missing',
+            'image_caption_missing',
+            'image_caption_position',
+            'image_caption_keyword',
+            'image_caption_dash',
+            'image_caption_alignment',
+            'image_caption_indent',
+            'image_caption_spacing',
+            'image_caption_number_missing',
+            'image_caption_number_duplicate',
+            'image_caption_number_format',
+            'image_caption_sequence',
+            'image_text_reference_missing',
+            'image_not_referenced'
         ]
     },
     formulas: {
@@ -135,7 +252,26 @@ export const ERROR_CATEGORIES = {
             'formula_alignment',
             'formula_numbering_missing',
             'formula_spacing',
-            'formula_where_colon'
+            'formula_where_colon',
+            'formula_text_reference_missing',
+            'formula_not_referenced',
+            'formula_image',
+            'formula_variable_style',
+            'formula_font_size'
+        ]
+    },
+    references: {
+        name: 'Список литературы',
+        types: [
+            'references_missing',
+            'reference_age',
+            'reference_numbering',
+            'reference_author_format',
+            'reference_title_punctuation',
+            'reference_imprint',
+            'reference_url_access_date',
+            'reference_citation_undefined',
+            'reference_not_cited'
         ]
     },
     other: {
@@ -210,6 +346,7 @@ export const assessOverallSeverity = (violations) => {
         error: 0,
         warning: 0,
         info: 0,
+        hint: 0,
         total: violations.length
     };
 
@@ -222,9 +359,9 @@ export const assessOverallSeverity = (violations) => {
         }
     });
 
-    // Расчет оценки (0-100)
+    // Расчет оценки (0-100); info и hint на оценку не влияют
     const score = Math.max(0, Math.min(100,
-        100 - (stats.critical * 20 + stats.error * 5 + stats.warning * 2 + stats.info * 0.5)
+        100 - (stats.critical * 20 + stats.error * 5 + stats.warning * 2)
     ));
 
     return { ...stats, score: Math.round(score) };
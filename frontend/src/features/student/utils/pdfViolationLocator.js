@@ -11,7 +11,7 @@ const cleanSearchText = (value) => {
     if (!value) return '';
     return String(value)
         .replace(/\s+/g, ' ')
-        .replace(/\.{3,}$/g, '')
+        .replace(/(\.{3,}|…)$/g, '')
         .trim();
 };
 
@@ -0,0 +1,183 @@
+import { useState, useEffect } from 'react';
+import { showToast } from '../../utils/toast';
+import { ERROR_CATEGORIES } from '../student/utils/errorConfig';
+
+const RULE_TYPES = Object.values(ERROR_CATEGORIES).flatMap(category => category.types);
+
+const emptyTemplate = { title: '', body: '', rule_type: '' };
+
+// FeedbackPanel sends one comment to all selected checks: a saved template,
+// a free text, or both.
+export default function FeedbackPanel({ selectedIds, onSent }) {
+    const [templates, setTemplates] = useState([]);
+    const [templateId, setTemplateId] = useState('');
+    const [body, setBody] = useState('');
+    const [ruleType, setRuleType] = useState('');
+    const [sending, setSending] = useState(false);
+    const [editing, setEditing] = useState(false);
+    const [form, setForm] = useState(emptyTemplate);
+
+    const loadTemplates = () => {
+        fetch('/api/feedback-templates', { credentials: 'include' })
+            .then(res => res.json())
+            .then(data => setTemplates(Array.isArray(data) ? data : []))
+            .catch(err => console.error(err));
+    };
+
+    useEffect(() => {
+        loadTemplates();
+    }, []);
+
+    const selectedTemplate = templates.find(t => String(t.id) === templateId);
+
+    const handleSend = async () => {
+        if (!templateId && !body.trim()) {
+            showToast.error('Выберите шаблон или введите текст');
+            return;
+        }
+        setSending(true);
+        try {
+            const res = await fetch('/api/teacher/comments', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    result_ids: selectedIds,
+                    template_id: templateId ? Number(templateId) : null,
+                    body: body.trim(),
+                    rule_type: ruleType.trim()
+                }),
+                credentials: 'include'
+            });
+            const data = await res.json();
+            if (!res.ok) {
+                showToast.error(data.error || 'Не удалось отправить комментарий');
+                return;
+            }
+            showToast.success(`Комментарий добавлен к проверкам: ${data.added}`);
+            setBody('');
+            onSent?.();
+        } catch (err) {
+            console.error(err);
+            showToast.error('Ошибка сети');
+        } finally {
+            setSending(false);
+        }
+    };
+
+    const handleCreateTemplate = async () => {
+        const res = await fetch('/api/feedback-templates', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(form),
+            credentials: 'include'
+        });
+        const data = await res.json();
+        if (!res.ok) {
+            showToast.error(data.error || 'Не удалось сохранить шаблон');
+            return;
+        }
+        setForm(emptyTemplate);
+        setTemplateId(String(data.id));
+        loadTemplates();
+    };
+
+    const handleDeleteTemplate = async (id) => {
+        const res = await fetch(`/api/feedback-templates/${id}`, { method: 'DELETE', credentials: 'include' });
+        if (!res.ok) {
+            const data = await res.json();
+            showToast.error(data.error || 'Не удалось удалить шаблон');
+        }
+        if (String(id) === templateId) setTemplateId('');
+        loadTemplates();
+    };
+
+    return (
+        <div style={{ border: '1px solid black', borderBottom: 'none', padding: '1.5rem 2rem', display: 'flex', flexDirection: 'column', gap: '1rem' }}>
+            <div style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center' }}>
+                <h3 style={{ margin: 0, fontSize: '1rem', textTransform: 'uppercase', letterSpacing: '0.05em' }}>
+                    Комментарий к выбранным: {selectedIds.length}
+                </h3>
+                <button className="btn btn-ghost" onClick={() => setEditing(!editing)} style={{ fontSize: '0.8rem', padding: '6px 12px' }}>
+                    {editing ? 'ЗАКРЫТЬ' : 'ШАБЛОНЫ'}
+                </button>
+            </div>
+
+            {editing && (
+                <div style={{ display: 'flex', flexDirection: 'column', gap: '0.75rem', borderBottom: '1px solid #E5E5E5', paddingBottom: '1rem' }}>
+                    {templates.map(t => (
+                        <div key={t.id} style={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center', gap: '1rem' }}>
+                            <div>
+                                <span style={{ fontWeight: 600 }}>{t.title}</span>
+                                {t.rule_type && <span style={{ fontFamily: 'monospace', fontSize: '0.8rem', marginLeft: '0.5rem', color: 'var(--text-dim)' }}>{t.rule_type}</span>}
+                            </div>
+                            <button className="btn btn-ghost" onClick={() => handleDeleteTemplate(t.id)} style={{ fontSize: '0.8rem', padding: '6px 12px' }}>
+                                УДАЛИТЬ
+                            </button>
+                        </div>
+                    ))}
+                    <div className="grid-2">
+                        <input
+                            className="input-field"
+                            placeholder="Название шаблона"
+                            value={form.title}
+                            onChange={e => setForm({ ...form, title: e.target.value })}
+                        />
+                        <input
+                            className="input-field"
+                            list="feedback-rule-types"
+                            placeholder="Тип ошибки (необязательно)"
+                            value={form.rule_type}
+                            onChange={e => setForm({ ...form, rule_type: e.target.value })}
+                        />
+                    </div>
+                    <textarea
+                        className="input-field"
+                        rows={3}
+                        placeholder="Исправьте список литературы по ГОСТ 7.0.100 и перезалейте"
+                        value={form.body}
+                        onChange={e => setForm({ ...form, body: e.target.value })}
+                    />
+                    <div>
+                        <button className="btn" onClick={handleCreateTemplate}>СОХРАНИТЬ ШАБЛОН</button>
+                    </div>
+                </div>
+            )}
+
+            <div className="grid-2">
+                <select
+                    className="input-field"
+                    value={templateId}
+                    onChange={e => setTemplateId(e.target.value)}
+                >
+                    <option value="">Без шаблона</option>
+                    {templates.map(t => <option key={t.id} value={t.id}>{t.title}</option>)}
+                </select>
+                <input
+                    className="input-field"
+                    list="feedback-rule-types"
+                    placeholder={selectedTemplate?.rule_type || 'Тип ошибки (необязательно)'}
+                    value={ruleType}
+                    onChange={e => setRuleType(e.target.value)}
+                />
+                <datalist id="feedback-rule-types">
+                    {RULE_TYPES.map(type => <option key={type} value={type} />)}
+                </datalist>
+            </div>
+            {selectedTemplate && (
+                <p style={{ margin: 0, color: 'var(--text-dim)', whiteSpace: 'pre-line' }}>{selectedTemplate.body}</p>
+            )}
+            <textarea
+                className="input-field"
+                rows={2}
+                placeholder={selectedTemplate ? 'Дополнение к шаблону (необязательно)' : 'Текст комментария'}
+                value={body}
+                onChange={e => setBody(e.target.value)}
+            />
+            <div>
+                <button className="btn btn-primary" onClick={handleSend} disabled={sending}>
+                    {sending ? 'ОТПРАВКА...' : 'ОТПРАВИТЬ'}
+                </button>
+            </div>
+        </div>
+    );
+}
@@ -79,12 +79,12 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                     code_blocks: { enabled: false, font_name: 'Consolas', font_size: 12, line_spacing: 1.0, first_line_indent: 0, alignment: 'left' },
                     headings: createDefaultHeadingRules(false),
                     paragraph: { line_spacing: 1.5, alignment: 'justify', first_line_indent: 12.5 },
-                    structure: { heading_1_start_new_page: true, heading_hierarchy: true, list_alignment: 'left', verify_toc: false },
+                    structure: { heading_1_start_new_page: true, heading_hierarchy: true, list_alignment: 'left', verify_toc: false, heading_keep_next: false, heading_not_last_on_page: false, require_widow_control: false },
                     images: { caption_position: 'bottom', alignment: 'center', require_caption: false, caption_keyword: 'Рисунок', caption_dash_format: true, check_caption_layout: false, caption_indent_mm: 0, caption_max_spacing_pt: 0, caption_alignment: 'center', check_sequence: false, numbering_mode: 'auto', check_text_references: false },
                     references: { required: true, title_keyword: 'Список литературы' },
                     scope: { start_page: 1, min_pages: 0, max_pages: 0, forbidden_words: '' },
                     tables: { caption_position: 'top', alignment: 'center', require_caption: false, caption_keyword: 'Таблица', caption_dash_format: false, check_caption_layout: false, caption_indent_mm: 0, caption_max_spacing_pt: 0, caption_alignment: 'left', check_sequence: false, numbering_mode: 'auto', check_text_references: false, require_borders: false, require_header_row: false, min_row_height_mm: 0, max_width_pct: 0 },
-                    formulas: { alignment: 'center', require_numbering: false, numbering_position: 'right', numbering_format: '(1)', require_spacing_around: false, check_where_no_colon: false }
+                    formulas: { alignment: 'center', require_numbering: false, numbering_position: 'right', numbering_format: '(1)', require_spacing_around: false, check_where_no_colon: false, check_text_references: false, check_variables_italic: false, check_font_size: false, forbid_images: false }
                 }
             }]
         }));
@@ -165,7 +165,8 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                         numbering_position: 'right',
                         numbering_format: '(1)',
                         require_spacing_around: true,
-                        check_where_no_colon: true
+                        check_where_no_colon: true,
+                        check_text_references: true
                     }
                 }
             })
@@ -193,6 +194,57 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
         }));
     };
 
+    const updateHeadingDetection = (field, value) => {
+        setFormData(prev => ({
+            ...prev,
+            modules: prev.modules.map(m => {
+                if (m.id !== activeModuleId) return m;
+                const structure = m.config.structure || {};
+                return {
+                    ...m,
+                    config: {
+                        ...m.config,
+                        structure: {
+                            ...structure,
+                            heading_detection: { ...(structure.heading_detection || {}), [field]: value }
+                        }
+                    }
+                };
+            })
+        }));
+    };
+
+    // "Заголовок раздела: 1" per line <-> { "Заголовок раздела": 1 }
+    const parseHeadingStyles = (text) => {
+        const styles = {};
+        text.split('\n').forEach(line => {
+            const m = line.match(/^(.+?)\s*[:=]\s*(\d)\s*$/);
+            if (m) styles[m[1].trim()] = parseInt(m[2]);
+        });
+        return styles;
+    };
+    const formatHeadingStyles = (styles) => Object.entries(styles || {}).map(([name, level]) => `${name}: ${level}`).join('\n');
+
+    const updateArticleConfig = (field, value) => {
+        setFormData(prev => ({
+            ...prev,
+            modules: prev.modules.map(m => {
+                if (m.id !== activeModuleId) return m;
+                const structure = m.config.structure || {};
+                return {
+                    ...m,
+                    config: {
+                        ...m.config,
+                        structure: {
+                            ...structure,
+                            article: { ...(structure.article || {}), [field]: value }
+                        }
+                    }
+                };
+            })
+        }));
+    };
+
     const updateHeadingLevelConfig = (level, field, value) => {
         setFormData(prev => ({
             ...prev,
@@ -243,7 +295,7 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
 
         try {
             const url = initialData
-                ? `/api/standards/${initialData.id}`
+                ? `/api/standards/${initialData.uuid}`
                 : '/api/standards';
 
             const method = initialData ? 'PUT' : 'POST';
@@ -317,10 +369,10 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
         e.preventDefault();
         e.stopPropagation();
         const file = e.dataTransfer.files[0];
-        if (file && file.name.endsWith('.docx')) {
+        if (file && /\.(docx|odt)$/i.test(file.name)) {
             processImportFile(file);
         } else if (file) {
-            showToast.error('Поддерживаются только файлы .docx');
+            showToast.error('Поддерживаются только файлы .docx и .odt');
         }
     };
 
@@ -472,7 +524,7 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                 onDrop={handleImportDrop}
                                 style={{ display: 'flex', alignItems: 'flex-end', paddingBottom: '1px' }}
                             >
-                                <input type="file" ref={fileInputRef} hidden accept=".docx" onChange={handleFileUpload} />
+                                <input type="file" ref={fileInputRef} hidden accept=".docx,.odt" onChange={handleFileUpload} />
                                 <button
                                     className="btn"
                                     onClick={() => fileInputRef.current.click()}
@@ -512,7 +564,8 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                         { id: 'tables', l: 'Таблицы' },
                                         { id: 'formulas', l: 'Формулы' },
                                         { id: 'references', l: 'Библиография' },
-                                        { id: 'scope', l: 'Область' }
+                                        { id: 'scope', l: 'Область' },
+                                        { id: 'presentation', l: 'Презентация' }
                                     ].map(tab => (
                                         <button
                                             key={tab.id}
@@ -561,6 +614,43 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                                 onChange={e => updateModuleConfig('header_footer', 'footer_dist', parseFloat(e.target.value))}
                                             />
                                         </div>
+                                        <div>
+                                            <label>Положение номера страницы</label>
+                                            <select
+                                                className="input-field"
+                                                value={activeModule.config.header_footer?.page_number_alignment || ''}
+                                                onChange={e => updateModuleConfig('header_footer', 'page_number_alignment', e.target.value)}
+                                            >
+                                                <option value="">Не проверять</option>
+                                                <option value="center">По центру (ГОСТ 7.32)</option>
+                                                <option value="right">Справа</option>
+                                                <option value="left">Слева</option>
+                                            </select>
+                                        </div>
+                                        <div />
+                                        {[
+                                            { k: 'require_page_number', l: 'Номер страницы', hint: 'Нижний колонтитул должен содержать поле номера страницы' },
+                                            { k: 'title_page_empty', l: 'Пустой титульный лист', hint: 'На титульном листе нет колонтитулов и номера' },
+                                            { k: 'running_title', l: 'Колонтитул = название раздела', hint: 'Текст верхнего колонтитула совпадает с заголовком раздела' },
+                                        ].map(item => (
+                                            <div key={item.k}
+                                                onClick={() => updateModuleConfig('header_footer', item.k, !activeModule.config.header_footer?.[item.k])}
+                                                style={{
+                                                    padding: '1.25rem',
+                                                    border: activeModule.config.header_footer?.[item.k] ? '2px solid black' : '1px solid #CCC',
+                                                    background: activeModule.config.header_footer?.[item.k] ? 'white' : '#FAFAFA',
+                                                    cursor: 'pointer',
+                                                    display: 'flex', alignItems: 'center', justifyContent: 'space-between',
+                                                    userSelect: 'none', gap: '1rem'
+                                                }}
+                                            >
+                                                <div>
+                                                    <div style={{ fontWeight: 600, color: activeModule.config.header_footer?.[item.k] ? 'black' : 'var(--text-dim)' }}>{item.l}</div>
+                                                    <div style={{ fontSize: '0.78rem', color: 'var(--text-dim)', marginTop: '2px' }}>{item.hint}</div>
+                                                </div>
+                                                <input type="checkbox" readOnly checked={!!activeModule.config.header_footer?.[item.k]} />
+                                            </div>
+                                        ))}
                                     </div>
                                 )}
 
@@ -578,6 +668,19 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                                 <option value="landscape">Альбомная (Landscape)</option>
                                             </select>
                                         </div>
+                                        <div style={{ marginBottom: '2rem' }}>
+                                            <label>Разделы с другой ориентацией</label>
+                                            <select
+                                                className="input-field"
+                                                value={activeModule.config.page_setup?.other_orientation || ''}
+                                                onChange={e => updateModuleConfig('page_setup', 'other_orientation', e.target.value)}
+                                                style={{ width: '300px' }}
+                                            >
+                                                <option value="">Допускаются везде</option>
+                                                <option value="appendices">Только в приложениях</option>
+                                                <option value="forbidden">Не допускаются</option>
+                                            </select>
+                                        </div>
                                         <h4 style={{ fontSize: '0.9rem', textTransform: 'uppercase', color: 'black', marginBottom: '1.5rem', fontWeight: 700 }}>Поля (мм)</h4>
                                         <div className="grid-2" style={{ gridTemplateColumns: 'repeat(4, 1fr)', gap: '1.5rem' }}>
                                             {[{ k: 'top', l: 'Верхнее' }, { k: 'bottom', l: 'Нижнее' }, { k: 'left', l: 'Левое' }, { k: 'right', l: 'Правое' }].map(pos => (
@@ -647,6 +750,113 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                                 </div>
                                             ))}
                                         </div>
+
+                                        {/* Typographic Punctuation */}
+                                        <div style={{ borderTop: '1px solid #E5E5E5', paddingTop: '1.5rem', marginTop: '1.5rem' }}>
+                                            <h4 style={{ fontSize: '0.85rem', fontWeight: 700, textTransform: 'uppercase', color: 'black', marginBottom: '1rem' }}>
+                                                Типографика
+                                            </h4>
+                                            <div className="grid-2">
+                                                {[
+                                                    { k: 'check_dashes', l: 'Тире вместо дефиса', hint: 'Между словами с пробелами ставится тире «–», а не дефис «-»' },
+                                                    { k: 'check_quotes', l: 'Кавычки-«ёлочки»', hint: 'Прямые кавычки "..." заменяются на «...»' },
+                                                    { k: 'check_double_spaces', l: 'Двойные пробелы', hint: 'Между словами ровно один пробел' },
+                                                    { k: 'check_space_before_punctuation', l: 'Пробел перед знаками', hint: 'Перед запятой, точкой, двоеточием и т. п. пробел не ставится' },
+                                                    { k: 'check_non_breaking_spaces', l: 'Неразрывные пробелы', hint: 'Между числом и единицей (10 кг) и в инициалах (И. И. Иванов)' },
+                                                    { k: 'check_hanging_prepositions', l: 'Висячие предлоги', hint: 'После «в», «и», «на» и других коротких слов — неразрывный пробел' },
+                                                    { k: 'forbid_auto_hyphenation', l: 'Без автопереносов', hint: 'Автоматическая расстановка переносов выключена' },
+                                                    { k: 'forbid_heading_hyphenation', l: 'Заголовки без переносов', hint: 'Нет мягких переносов, автоперенос в стилях заголовков запрещён' },
+                                                ].map(item => (
+                                                    <div key={item.k}
+                                                        onClick={() => updateModuleConfig('typography_rules', item.k, !activeModule.config.typography_rules?.[item.k])}
+                                                        style={{
+                                                            padding: '1.5rem',
+                                                            border: activeModule.config.typography_rules?.[item.k] ? '2px solid black' : '1px solid #CCC',
+                                                            background: activeModule.config.typography_rules?.[item.k] ? 'white' : '#FAFAFA',
+                                                            cursor: 'pointer',
+                                                            display: 'flex', alignItems: 'center', justifyContent: 'space-between',
+                                                            userSelect: 'none', gap: '1rem'
+                                                        }}
+                                                    >
+                                                        <div>
+                                                            <div style={{ fontWeight: 600, color: activeModule.config.typography_rules?.[item.k] ? 'black' : 'var(--text-dim)' }}>{item.l}</div>
+                                                            <div style={{ fontSize: '0.78rem', color: 'var(--text-dim)', marginTop: '2px' }}>{item.hint}</div>
+                                                        </div>
+                                                        <div style={{
+                                                            width: '44px', height: '24px', flexShrink: 0,
+                                                            background: activeModule.config.typography_rules?.[item.k] ? 'black' : '#DDD',
+                                                            borderRadius: '24px', position: 'relative', transition: 'background 0.2s'
+                                                        }}>
+                                                            <div style={{
+                                                                width: '20px', height: '20px', background: 'white', borderRadius: '50%',
+                                                                position: 'absolute', top: '2px',
+                                                                left: activeModule.config.typography_rules?.[item.k] ? '22px' : '2px',
+                                                                transition: 'left 0.2s cubic-bezier(0.4, 0.0, 0.2, 1)',
+                                                                boxShadow: '0 1px 2px rgba(0,0,0,0.2)'
+                                                            }} />
+                                                        </div>
+                                                    </div>
+                                                ))}
+                                            </div>
+                                        </div>
+
+                                        {/* Numbers and Units */}
+                                        <div style={{ borderTop: '1px solid #E5E5E5', paddingTop: '1.5rem', marginTop: '1.5rem' }}>
+                                            <h4 style={{ fontSize: '0.85rem', fontWeight: 700, textTransform: 'uppercase', color: 'black', marginBottom: '1rem' }}>
+                                                Числа и единицы (ГОСТ 8.417)
+                                            </h4>
+                                            <div className="grid-2">
+                                                {[
+                                                    { k: 'check_unit_spacing', l: 'Пробел перед единицей', hint: '«10 мм», а не «10мм»' },
+                                                    { k: 'check_ranges', l: 'Тире в диапазонах', hint: '«10–20», а не «10-20»' },
+                                                    { k: 'check_decimal_separator', l: 'Десятичная запятая', hint: '«2,5 кг», а не «2.5 кг»' },
+                                                    { k: 'check_unit_names', l: 'Обозначения единиц', hint: '«с», а не «сек»; «Гбайт», а не «ГБ»' },
+                                                ].map(item => (
+                                                    <div key={item.k}
+                                                        onClick={() => updateModuleConfig('units', item.k, !activeModule.config.units?.[item.k])}
+                                                        style={{
+                                                            padding: '1.5rem',
+                                                            border: activeModule.config.units?.[item.k] ? '2px solid black' : '1px solid #CCC',
+                                                            background: activeModule.config.units?.[item.k] ? 'white' : '#FAFAFA',
+                                                            cursor: 'pointer',
+                                                            display: 'flex', alignItems: 'center', justifyContent: 'space-between',
+                                                            userSelect: 'none', gap: '1rem'
+                                                        }}
+                                                    >
+                                                        <div>
+                                                            <div style={{ fontWeight: 600, color: activeModule.config.units?.[item.k] ? 'black' : 'var(--text-dim)' }}>{item.l}</div>
+                                                            <div style={{ fontSize: '0.78rem', color: 'var(--text-dim)', marginTop: '2px' }}>{item.hint}</div>
+                                                        </div>
+                                                        <div style={{
+                                                            width: '44px', height: '24px', flexShrink: 0,
+                                                            background: activeModule.config.units?.[item.k] ? 'black' : '#DDD',
+                                                            borderRadius: '24px', position: 'relative', transition: 'background 0.2s'
+                                                        }}>
+                                                            <div style={{
+                                                                width: '20px', height: '20px', background: 'white', borderRadius: '50%',
+                                                                position: 'absolute', top: '2px',
+                                                                left: activeModule.config.units?.[item.k] ? '22px' : '2px',
+                                                                transition: 'left 0.2s cubic-bezier(0.4, 0.0, 0.2, 1)',
+                                                                boxShadow: '0 1px 2px rgba(0,0,0,0.2)'
+                                                            }} />
+                                                        </div>
+                                                    </div>
+                                                ))}
+                                            </div>
+                                            {activeModule.config.units?.check_unit_names && (
+                                                <div style={{ marginTop: '1rem' }}>
+                                                    <label>Допустимые обозначения единиц</label>
+                                                    <textarea
+                                                        className="input-field"
+                                                        value={activeModule.config.units?.allowed_units || ''}
+                                                        onChange={e => updateModuleConfig('units', 'allowed_units', e.target.value)}
+                                                        placeholder="мм, см, м, кг, с, Гц, Мбайт"
+                                                        style={{ height: '80px', resize: 'none', lineHeight: 1.5 }}
+                                                    />
+                                                    <span style={{ fontSize: '0.8rem', color: 'var(--text-dim)', marginTop: '0.5rem', display: 'block' }}>Перечислите через запятую. Пусто — обозначения по ГОСТ 8.417.</span>
+                                                </div>
+                                            )}
+                                        </div>
                                     </div>
                                 )}
 
@@ -920,6 +1130,9 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                             {[
                                                 { k: 'heading_1_start_new_page', l: 'Заголовок 1 с новой страницы' },
                                                 { k: 'heading_hierarchy', l: 'Строгая иерархия (1→2→3)' },
+                                                { k: 'heading_keep_next', l: 'Заголовок не отрывать от текста' },
+                                                { k: 'heading_not_last_on_page', l: 'Заголовок не в конце страницы' },
+                                                { k: 'require_widow_control', l: 'Запрет висячих строк' },
                                             ].map(item => (
                                                 <div key={item.k}
                                                     onClick={() => updateModuleConfig('structure', item.k, !activeModule.config.structure?.[item.k])}
@@ -988,6 +1201,268 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                                 <option value="justify">По ширине (Justify)</option>
                                             </select>
                                         </div>
+
+                                        {/* Lists */}
+                                        <div style={{ borderTop: '1px solid #E5E5E5', paddingTop: '1.5rem', marginTop: '2rem' }}>
+                                            <div
+                                                onClick={() => updateModuleConfig('lists', 'enabled', !activeModule.config.lists?.enabled)}
+                                                style={{
+                                                    padding: '1.5rem',
+                                                    border: activeModule.config.lists?.enabled ? '2px solid black' : '1px solid #CCC',
+                                                    background: activeModule.config.lists?.enabled ? 'white' : '#FAFAFA',
+                                                    cursor: 'pointer',
+                                                    display: 'flex', alignItems: 'center', justifyContent: 'space-between',
+                                                    userSelect: 'none'
+                                                }}
+                                            >
+                                                <span style={{ fontWeight: 600 }}>Проверять оформление списков</span>
+                                                <div style={{
+                                                    width: '44px', height: '24px', flexShrink: 0,
+                                                    background: activeModule.config.lists?.enabled ? 'black' : '#DDD',
+                                                    borderRadius: '24px', position: 'relative', transition: 'background 0.2s'
+                                                }}>
+                                                    <div style={{
+                                                        width: '20px', height: '20px', background: 'white', borderRadius: '50%',
+                                                        position: 'absolute', top: '2px', left: activeModule.config.lists?.enabled ? '22px' : '2px',
+                                                        transition: 'left 0.2s cubic-bezier(0.4, 0.0, 0.2, 1)',
+                                                        boxShadow: '0 1px 2px rgba(0,0,0,0.2)'
+                                                    }} />
+                                                </div>
+                                            </div>
+                                            {activeModule.config.lists?.enabled && (
+                                                <div className="grid-2" style={{ marginTop: '1rem' }}>
+                                                    <div>
+                                                        <label>Маркер списка</label>
+                                                        <select
+                                                            className="input-field"
+                                                            value={activeModule.config.lists?.marker_type || ''}
+                                                            onChange={e => updateModuleConfig('lists', 'marker_type', e.target.value)}
+                                                        >
+                                                            <option value="">Любой</option>
+                                                            <option value="dash">Тире (–)</option>
+                                                            <option value="bullet">Маркер (•)</option>
+                                                            <option value="numbered">Нумерация (1., а))</option>
+                                                        </select>
+                                                    </div>
+                                                    <div>
+                                                        <label>Формат номера</label>
+                                                        <select
+                                                            className="input-field"
+                                                            value={activeModule.config.lists?.number_format || ''}
+                                                            onChange={e => updateModuleConfig('lists', 'number_format', e.target.value)}
+                                                        >
+                                                            <option value="">Любой</option>
+                                                            <option value="1)">1)</option>
+                                                            <option value="1.">1.</option>
+                                                            <option value="а)">а)</option>
+                                                            <option value="a)">a)</option>
+                                                        </select>
+                                                    </div>
+                                                    <div>
+                                                        <label>Знак в конце элемента</label>
+                                                        <select
+                                                            className="input-field"
+                                                            value={activeModule.config.lists?.item_punctuation || ''}
+                                                            onChange={e => updateModuleConfig('lists', 'item_punctuation', e.target.value)}
+                                                        >
+                                                            <option value="">Не проверять</option>
+                                                            <option value=";">«;», в последнем «.»</option>
+                                                            <option value=".">«.»</option>
+                                                        </select>
+                                                    </div>
+                                                    <div>
+                                                        <label>Первая буква элемента</label>
+                                                        <select
+                                                            className="input-field"
+                                                            value={activeModule.config.lists?.item_case || ''}
+                                                            onChange={e => updateModuleConfig('lists', 'item_case', e.target.value)}
+                                                        >
+                                                            <option value="">Не проверять</option>
+                                                            <option value="lower">Строчная</option>
+                                                            <option value="upper">Прописная</option>
+                                                        </select>
+                                                    </div>
+                                                    <div>
+                                                        <label>Отступ маркера (мм, 0 = не проверять)</label>
+                                                        <input
+                                                            className="input-field"
+                                                            type="number" step="0.1"
+                                                            value={activeModule.config.lists?.indent_mm || 0}
+                                                            onChange={e => updateModuleConfig('lists', 'indent_mm', parseFloat(e.target.value) || 0)}
+                                                        />
+                                                    </div>
+                                                    <div>
+                                                        <label>Макс. вложенность (0 = не проверять)</label>
+                                                        <input
+                                                            className="input-field"
+                                                            type="number" min="0"
+                                                            value={activeModule.config.lists?.max_depth || 0}
+                                                            onChange={e => updateModuleConfig('lists', 'max_depth', parseInt(e.target.value) || 0)}
+                                                        />
+                                                    </div>
+                                                </div>
+                                            )}
+                                        </div>
+                                        {/* Footnotes */}
+                                        <div style={{ borderTop: '1px solid #E5E5E5', paddingTop: '1.5rem', marginTop: '2rem' }}>
+                                            <div
+                                                onClick={() => updateModuleConfig('footnotes', 'enabled', !activeModule.config.footnotes?.enabled)}
+                                                style={{
+                                                    padding: '1.5rem',
+                                                    border: activeModule.config.footnotes?.enabled ? '2px solid black' : '1px solid #CCC',
+                                                    background: activeModule.config.footnotes?.enabled ? 'white' : '#FAFAFA',
+                                                    cursor: 'pointer',
+                                                    display: 'flex', alignItems: 'center', justifyContent: 'space-between',
+                                                    userSelect: 'none'
+                                                }}
+                                            >
+                                                <span style={{ fontWeight: 600 }}>Проверять оформление сносок</span>
+                                                <div style={{
+                                                    width: '44px', height: '24px', flexShrink: 0,
+                                                    background: activeModule.config.footnotes?.enabled ? 'black' : '#DDD',
+                                                    borderRadius: '24px', position: 'relative', transition: 'background 0.2s'
+                                                }}>
+                                                    <div style={{
+                                                        width: '20px', height: '20px', background: 'white', borderRadius: '50%',
+                                                        position: 'absolute', top: '2px', left: activeModule.config.footnotes?.enabled ? '22px' : '2px',
+                                                        transition: 'left 0.2s cubic-bezier(0.4, 0.0, 0.2, 1)',
+                                                        boxShadow: '0 1px 2px rgba(0,0,0,0.2)'
+                                                    }} />
+                                                </div>
+                                            </div>
+                                            {activeModule.config.footnotes?.enabled && (
+                                                <div className="grid-2" style={{ marginTop: '1rem' }}>
+                                                    <div>
+                                                        <label>Размер шрифта сносок (pt, 0 = не проверять)</label>
+                                                        <input
+                                                            className="input-field"
+                                                            type="number" step="0.5"
+                                                            value={activeModule.config.footnotes?.font_size_pt || 0}
+                                                            onChange={e => updateModuleConfig('footnotes', 'font_size_pt', parseFloat(e.target.value) || 0)}
+                                                        />
+                                                    </div>
+                                                    <div>
+                                                        <label>Нумерация сносок</label>
+                                                        <select
+                                                            className="input-field"
+                                                            value={activeModule.config.footnotes?.numbering || ''}
+                                                            onChange={e => updateModuleConfig('footnotes', 'numbering', e.target.value)}
+                                                        >
+                                                            <option value="">Не проверять</option>
+                                                            <option value="continuous">Сквозная</option>
+                                                            <option value="each_page">На каждой странице</option>
+                                                            <option value="each_section">В каждом разделе</option>
+                                                        </select>
+                                                    </div>
+                                                    <div>
+                                                        <label>Макс. сносок на странице (0 = не проверять)</label>
+                                                        <input
+                                                            className="input-field"
+                                                            type="number" min="0"
+                                                            value={activeModule.config.footnotes?.max_per_page || 0}
+                                                            onChange={e => updateModuleConfig('footnotes', 'max_per_page', parseInt(e.target.value) || 0)}
+                                                        />
+                                                    </div>
+                                                    <div>
+                                                        <label style={{ display: 'flex', alignItems: 'center', gap: '0.5rem', marginTop: '1.5rem' }}>
+                                                            <input
+                                                                type="checkbox"
+                                                                checked={!!activeModule.config.footnotes?.forbid_endnotes}
+                                                                onChange={e => updateModuleConfig('footnotes', 'forbid_endnotes', e.target.checked)}
+                                                            />
+                                                            Запретить концевые сноски
+                                                        </label>
+                                                    </div>
+                                                </div>
+                                            )}
+                                        </div>
+                                        {/* Revisions */}
+                                        <div style={{ borderTop: '1px solid #E5E5E5', paddingTop: '1.5rem', marginTop: '2rem' }}>
+                                            <label>Рецензирование</label>
+                                            <div style={{ display: 'flex', flexDirection: 'column', gap: '0.5rem' }}>
+                                                <label style={{ display: 'flex', alignItems: 'center', gap: '0.5rem' }}>
+                                                    <input
+                                                        type="checkbox"
+                                                        checked={!!activeModule.config.revisions?.forbid_tracked_changes}
+                                                        onChange={e => updateModuleConfig('revisions', 'forbid_tracked_changes', e.target.checked)}
+                                                    />
+                                                    Запретить непринятые исправления
+                                                </label>
+                                                <label style={{ display: 'flex', alignItems: 'center', gap: '0.5rem' }}>
+                                                    <input
+                                                        type="checkbox"
+                                                        checked={!!activeModule.config.revisions?.forbid_comments}
+                                                        onChange={e => updateModuleConfig('revisions', 'forbid_comments', e.target.checked)}
+                                                    />
+                                                    Запретить примечания рецензента
+                                                </label>
+                                            </div>
+                                            <span style={{ fontSize: '0.8rem', color: 'var(--text-dim)', marginTop: '4px', display: 'block' }}>
+                                                Окончательная версия работы сдаётся без режима исправлений и примечаний.
+                                            </span>
+                                        </div>
+                                        {/* Integrity */}
+                                        <div style={{ borderTop: '1px solid #E5E5E5', paddingTop: '1.5rem', marginTop: '2rem' }}>
+                                            <div
+                                                onClick={() => updateModuleConfig('integrity', 'enabled', !activeModule.config.integrity?.enabled)}
+                                                style={{
+                                                    padding: '1.5rem',
+                                                    border: activeModule.config.integrity?.enabled ? '2px solid black' : '1px solid #CCC',
+                                                    background: activeModule.config.integrity?.enabled ? 'white' : '#FAFAFA',
+                                                    cursor: 'pointer',
+                                                    display: 'flex', alignItems: 'center', justifyContent: 'space-between',
+                                                    userSelect: 'none'
+                                                }}
+                                            >
+                                                <span style={{ fontWeight: 600 }}>Искать приёмы, увеличивающие объём работы</span>
+                                                <div style={{
+                                                    width: '44px', height: '24px', flexShrink: 0,
+                                                    background: activeModule.config.integrity?.enabled ? 'black' : '#DDD',
+                                                    borderRadius: '24px', position: 'relative', transition: 'background 0.2s'
+                                                }}>
+                                                    <div style={{
+                                                        width: '20px', height: '20px', background: 'white', borderRadius: '50%',
+                                                        position: 'absolute', top: '2px', left: activeModule.config.integrity?.enabled ? '22px' : '2px',
+                                                        transition: 'left 0.2s cubic-bezier(0.4, 0.0, 0.2, 1)',
+                                                        boxShadow: '0 1px 2px rgba(0,0,0,0.2)'
+                                                    }} />
+                                                </div>
+                                            </div>
+                                            <span style={{ fontSize: '0.8rem', color: 'var(--text-dim)', marginTop: '4px', display: 'block' }}>
+                                                Пустые абзацы, огромные интервалы, крупный шрифт, невидимый текст, широкие поля отдельных разделов, растянутые рисунки. Находки видны только преподавателю и не влияют на оценку.
+                                            </span>
+                                            <div style={{ marginTop: '1rem', display: 'flex', flexDirection: 'column', gap: '0.5rem' }}>
+                                                <label style={{ display: 'flex', alignItems: 'center', gap: '0.5rem' }}>
+                                                    <input
+                                                        type="checkbox"
+                                                        checked={!!activeModule.config.doc_properties?.check_author}
+                                                        onChange={e => updateModuleConfig('doc_properties', 'check_author', e.target.checked)}
+                                                    />
+                                                    Автор в свойствах документа совпадает с обучающимся
+                                                </label>
+                                                <label style={{ display: 'flex', alignItems: 'center', gap: '0.5rem' }}>
+                                                    <input
+                                                        type="checkbox"
+                                                        checked={!!activeModule.config.doc_properties?.forbid_converters}
+                                                        onChange={e => updateModuleConfig('doc_properties', 'forbid_converters', e.target.checked)}
+                                                    />
+                                                    Документ не создан конвертером из PDF или онлайн-сервисом
+                                                </label>
+                                                <label style={{ display: 'flex', alignItems: 'center', gap: '0.5rem' }}>
+                                                    Время редактирования не менее
+                                                    <input
+                                                        type="number"
+                                                        min="0"
+                                                        className="input-field"
+                                                        style={{ width: '90px' }}
+                                                        value={activeModule.config.doc_properties?.min_editing_minutes || ''}
+                                                        onChange={e => updateModuleConfig('doc_properties', 'min_editing_minutes', parseInt(e.target.value, 10) || 0)}
+                                                        placeholder="0"
+                                                    />
+                                                    мин (0 — не проверять)
+                                                </label>
+                                            </div>
+                                        </div>
                                         <div style={{ marginTop: '2rem' }}>
                                             <label>Порядок разделов</label>
                                             <input
@@ -1000,6 +1475,103 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                                 Разделите запятой названия разделов в нужном порядке (регистр не важен). Пусто = не проверять.
                                             </span>
                                         </div>
+                                        {/* Heading detection */}
+                                        <div style={{ marginTop: '2rem' }}>
+                                            <label>Распознавание заголовков</label>
+                                            <select
+                                                className="input-field"
+                                                value={activeModule.config.structure?.heading_detection?.strategy || ''}
+                                                onChange={e => updateHeadingDetection('strategy', e.target.value)}
+                                            >
+                                                <option value="">Стили заголовков и оформление (жирный, крупный шрифт)</option>
+                                                <option value="styles">Только стили заголовков</option>
+                                                <option value="outline">По уровню структуры (outline level)</option>
+                                                <option value="custom">Только стили из списка ниже</option>
+                                            </select>
+                                            <label style={{ marginTop: '1rem' }}>Свои стили заголовков</label>
+                                            <textarea
+                                                key={activeModuleId}
+                                                className="input-field"
+                                                rows={3}
+                                                defaultValue={formatHeadingStyles(activeModule.config.structure?.heading_detection?.styles)}
+                                                onBlur={e => updateHeadingDetection('styles', parseHeadingStyles(e.target.value))}
+                                                placeholder={'Заголовок раздела: 1\nПодраздел: 2'}
+                                            />
+                                            <span style={{ fontSize: '0.8rem', color: 'var(--text-dim)', marginTop: '4px', display: 'block' }}>
+                                                По одному стилю в строке: название или идентификатор стиля и уровень заголовка. Эти стили считаются заголовками при любом способе распознавания.
+                                            </span>
+                                        </div>
+                                        {/* Journal article */}
+                                        <div style={{ borderTop: '1px solid #E5E5E5', paddingTop: '1.5rem', marginTop: '2rem' }}>
+                                            <label>Тип работы</label>
+                                            <select
+                                                className="input-field"
+                                                value={activeModule.config.structure?.document_type || ''}
+                                                onChange={e => updateModuleConfig('structure', 'document_type', e.target.value)}
+                                            >
+                                                <option value="">Курсовая / выпускная работа</option>
+                                                <option value="article">Научная статья (шаблон журнала)</option>
+                                            </select>
+                                            {activeModule.config.structure?.document_type === 'article' && (
+                                                <div className="grid-2" style={{ marginTop: '1rem', gap: '1rem', border: 'none' }}>
+                                                    {[
+                                                        { k: 'require_udc', l: 'Требовать индекс УДК' },
+                                                        { k: 'require_affiliation', l: 'Требовать место работы авторов' },
+                                                        { k: 'require_keywords', l: 'Требовать ключевые слова' },
+                                                    ].map(item => (
+                                                        <label key={item.k} style={{ display: 'flex', alignItems: 'center', gap: '0.5rem' }}>
+                                                            <input
+                                                                type="checkbox"
+                                                                checked={!!activeModule.config.structure?.article?.[item.k]}
+                                                                onChange={e => updateArticleConfig(item.k, e.target.checked)}
+                                                            />
+                                                            {item.l}
+                                                        </label>
+                                                    ))}
+                                                    <div>
+                                                        <label>Языки аннотации</label>
+                                                        <input
+                                                            className="input-field"
+                                                            value={activeModule.config.structure?.article?.abstract_languages || ''}
+                                                            onChange={e => updateArticleConfig('abstract_languages', e.target.value)}
+                                                            placeholder="ru, en"
+                                                        />
+                                                    </div>
+                                                    {[
+                                                        { k: 'max_authors', l: 'Макс. авторов (0 = не проверять)' },
+                                                        { k: 'min_abstract_words', l: 'Мин. слов в аннотации' },
+                                                        { k: 'max_abstract_words', l: 'Макс. слов в аннотации' },
+                                                        { k: 'min_keywords', l: 'Мин. ключевых слов' },
+                                                        { k: 'max_keywords', l: 'Макс. ключевых слов' },
+                                                        { k: 'columns', l: 'Колонок текста (0 = не проверять)' },
+                                                    ].map(item => (
+                                                        <div key={item.k}>
+                                                            <label>{item.l}</label>
+                                                            <input
+                                                                className="input-field"
+                                                                type="number" min="0"
+                                                                value={activeModule.config.structure?.article?.[item.k] || 0}
+                                                                onChange={e => updateArticleConfig(item.k, parseInt(e.target.value) || 0)}
+                                                            />
+                                                        </div>
+                                                    ))}
+                                                    <div>
+                                                        <label>Стиль списка литературы</label>
+                                                        <select
+                                                            className="input-field"
+                                                            value={activeModule.config.structure?.article?.reference_style || ''}
+                                                            onChange={e => updateArticleConfig('reference_style', e.target.value)}
+                                                        >
+                                                            <option value="">Не проверять</option>
+                                                            <option value="gost">ГОСТ 7.0.100-2018</option>
+                                                            <option value="apa">APA</option>
+                                                            <option value="vancouver">Vancouver</option>
+                                                            <option value="ieee">IEEE</option>
+                                                        </select>
+                                                    </div>
+                                                </div>
+                                            )}
+                                        </div>
                                     </div>
                                 )}
 
@@ -1285,6 +1857,7 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                                 { k: 'require_borders', l: 'Требовать рамки', hint: 'Таблица должна иметь видимые границы' },
                                                 { k: 'require_header_row', l: 'Требовать строку заголовка', hint: 'Первая строка — заголовок (Header Row)' },
                                                 { k: 'caption_dash_format', l: 'Формат ESKD «Таблица N – Название»', hint: 'В подписи должно быть тире (– или —)' },
+                                                { k: 'check_cell_content', l: 'Проверять текст в ячейках', hint: 'Шрифт, размер и выравнивание текста внутри таблиц' },
                                             ].map(item => (
                                                 <div key={item.k}
                                                     onClick={() => updateModuleConfig('tables', item.k, !activeModule.config.tables?.[item.k])}
@@ -1500,6 +2073,36 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                             />
                                             <span style={{ fontSize: '0.8rem', color: 'var(--text-dim)', marginTop: '4px', display: 'block' }}>0 = не проверять · ЕСКД = 8 мм</span>
                                         </div>
+
+                                        {/* Cell content */}
+                                        {activeModule.config.tables?.check_cell_content && (
+                                            <div className="grid-2" style={{ marginTop: '1.5rem', gap: '1rem', border: 'none' }}>
+                                                <div>
+                                                    <label>Размер шрифта в ячейках (pt)</label>
+                                                    <input
+                                                        className="input-field"
+                                                        type="number" min="0" max="28" step="0.5"
+                                                        value={activeModule.config.tables?.cell_font_size || 0}
+                                                        onChange={e => updateModuleConfig('tables', 'cell_font_size', parseFloat(e.target.value) || 0)}
+                                                    />
+                                                    <span style={{ fontSize: '0.8rem', color: 'var(--text-dim)', marginTop: '4px', display: 'block' }}>0 = как в основном тексте</span>
+                                                </div>
+                                                <div>
+                                                    <label>Выравнивание текста в ячейках</label>
+                                                    <select
+                                                        className="input-field"
+                                                        value={activeModule.config.tables?.cell_alignment || ''}
+                                                        onChange={e => updateModuleConfig('tables', 'cell_alignment', e.target.value)}
+                                                    >
+                                                        <option value="">Не проверять</option>
+                                                        <option value="left">Слева</option>
+                                                        <option value="center">По центру</option>
+                                                        <option value="right">Справа</option>
+                                                        <option value="justify">По ширине</option>
+                                                    </select>
+                                                </div>
+                                            </div>
+                                        )}
                                     </div>
                                 )}
 
@@ -1564,6 +2167,10 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                                 { k: 'require_numbering', l: 'Требовать нумерацию', hint: 'Каждая формула должна иметь порядковый номер' },
                                                 { k: 'require_spacing_around', l: 'Пустая строка вокруг формулы', hint: 'Требовать пустую строку до и после формулы' },
                                                 { k: 'check_where_no_colon', l: '«где» без двоеточия', hint: 'После «где» не должно быть двоеточия (ГОСТ Р 2.105)' },
+                                                { k: 'check_text_references', l: 'Ссылки в тексте', hint: 'На каждую формулу есть ссылка вида «по формуле (1)»' },
+                                                { k: 'check_variables_italic', l: 'Переменные курсивом', hint: 'Латинские и греческие обозначения величин набраны курсивом' },
+                                                { k: 'check_font_size', l: 'Размер как у текста', hint: 'Размер шрифта формулы равен размеру основного текста' },
+                                                { k: 'forbid_images', l: 'Запрет формул-рисунков', hint: 'Формулы набраны в редакторе, а не вставлены картинкой или объектом MathType' },
                                             ].map(item => (
                                                 <div key={item.k}
                                                     onClick={() => updateModuleConfig('formulas', item.k, !activeModule.config.formulas?.[item.k])}
@@ -1699,6 +2306,49 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                                 </div>
                                             </div>
                                         </div>
+
+                                        {/* Entry Format and Citations */}
+                                        <div style={{ borderTop: '1px solid #E5E5E5', paddingTop: '1.5rem', marginTop: '1.5rem' }}>
+                                            <h4 style={{ fontSize: '0.85rem', fontWeight: 700, textTransform: 'uppercase', color: 'black', marginBottom: '1rem' }}>
+                                                Оформление записей
+                                            </h4>
+                                            <div className="grid-2">
+                                                {[
+                                                    { k: 'check_entry_format', l: 'Записи по ГОСТ 7.0.100-2018', hint: 'Нумерация, инициалы автора, «– Текст : непосредственный», выходные данные, URL и дата обращения' },
+                                                    { k: 'check_citations', l: 'Ссылки на источники', hint: 'Ссылки вида [5] или [12, с. 34] ведут на существующий источник, и на каждый источник есть ссылка' },
+                                                ].map(item => (
+                                                    <div key={item.k}
+                                                        onClick={() => updateModuleConfig('references', item.k, !activeModule.config.references?.[item.k])}
+                                                        style={{
+                                                            padding: '1.5rem',
+                                                            border: activeModule.config.references?.[item.k] ? '2px solid black' : '1px solid #CCC',
+                                                            background: activeModule.config.references?.[item.k] ? 'white' : '#FAFAFA',
+                                                            cursor: 'pointer',
+                                                            display: 'flex', alignItems: 'center', justifyContent: 'space-between',
+                                                            userSelect: 'none', gap: '1rem'
+                                                        }}
+                                                    >
+                                                        <div>
+                                                            <div style={{ fontWeight: 600, color: activeModule.config.references?.[item.k] ? 'black' : 'var(--text-dim)' }}>{item.l}</div>
+                                                            <div style={{ fontSize: '0.78rem', color: 'var(--text-dim)', marginTop: '2px' }}>{item.hint}</div>
+                                                        </div>
+                                                        <div style={{
+                                                            width: '44px', height: '24px', flexShrink: 0,
+                                                            background: activeModule.config.references?.[item.k] ? 'black' : '#DDD',
+                                                            borderRadius: '24px', position: 'relative', transition: 'background 0.2s'
+                                                        }}>
+                                                            <div style={{
+                                                                width: '20px', height: '20px', background: 'white', borderRadius: '50%',
+                                                                position: 'absolute', top: '2px',
+                                                                left: activeModule.config.references?.[item.k] ? '22px' : '2px',
+                                                                transition: 'left 0.2s cubic-bezier(0.4, 0.0, 0.2, 1)',
+                                                                boxShadow: '0 1px 2px rgba(0,0,0,0.2)'
+                                                            }} />
+                                                        </div>
+                                                    </div>
+                                                ))}
+                                            </div>
+                                        </div>
                                     </div>
                                 )}
 
@@ -1751,6 +2401,55 @@ export default function StandardEditor({ onCancel, onSuccess, initialData = null
                                     </div>
                                 )}
 
+                                {activeTab === 'presentation' && (
+                                    <div>
+                                        <p style={{ color: 'var(--text-dim)', marginBottom: '2rem', fontSize: '0.9rem' }}>
+                                            Правила для презентации к защите (.pptx). Загруженная презентация проверяется только по ним; скрытые слайды пропускаются.
+                                        </p>
+                                        <div className="grid-3" style={{ marginBottom: '2rem' }}>
+                                            {[
+                                                { k: 'min_slides', l: 'Мин. слайдов', step: '1' },
+                                                { k: 'max_slides', l: 'Макс. слайдов', step: '1' },
+                                                { k: 'min_font_size_pt', l: 'Мин. размер шрифта (pt)', step: '0.5' },
+                                            ].map(item => (
+                                                <div key={item.k}>
+                                                    <label>{item.l}</label>
+                                                    <input
+                                                        className="input-field"
+                                                        type="number" min="0" step={item.step}
+                                                        value={activeModule.config.presentation?.[item.k] || 0}
+                                                        onChange={e => updateModuleConfig('presentation', item.k, parseFloat(e.target.value) || 0)}
+                                                    />
+                                                    <span style={{ fontSize: '0.8rem', color: 'var(--text-dim)' }}>0 = не проверять</span>
+                                                </div>
+                                            ))}
+                                        </div>
+                                        <div style={{ marginBottom: '2rem' }}>
+                                            <label>Поля титульного слайда</label>
+                                            <input
+                                                className="input-field"
+                                                value={activeModule.config.presentation?.title_slide_fields || ''}
+                                                onChange={e => updateModuleConfig('presentation', 'title_slide_fields', e.target.value)}
+                                                placeholder="Выполнил, Руководитель"
+                                            />
+                                            <span style={{ fontSize: '0.8rem', color: 'var(--text-dim)', marginTop: '4px', display: 'block' }}>Слова через запятую, которые должны быть на первом слайде. Регистр не важен.</span>
+                                        </div>
+                                        {[
+                                            { k: 'require_slide_numbers', l: 'Требовать номера слайдов (кроме титульного)' },
+                                            { k: 'require_slide_titles', l: 'Требовать заголовок на каждом слайде' },
+                                        ].map(item => (
+                                            <label key={item.k} style={{ display: 'flex', alignItems: 'center', gap: '0.5rem', marginBottom: '0.75rem' }}>
+                                                <input
+                                                    type="checkbox"
+                                                    checked={!!activeModule.config.presentation?.[item.k]}
+                                                    onChange={e => updateModuleConfig('presentation', item.k, e.target.checked)}
+                                                />
+                                                {item.l}
+                                            </label>
+                                        ))}
+                                    </div>
+                                )}
+
                                 {activeTab === 'introduction' && (
                                     <div>
                                         <p style={{ color: 'var(--text-dim)', marginBottom: '2rem', fontSize: '0.9rem' }}>
@@ -126,7 +126,7 @@ export default function TeacherDashboard() {
                                             РЕДАКТИРОВАТЬ
                                         </button>
                                         <button
-                                            onClick={() => handleDelete(s.id)}
+                                            onClick={() => handleDelete(s.uuid)}
                                             style={{
                                                 width: '100%',
                                                 background: 'transparent',
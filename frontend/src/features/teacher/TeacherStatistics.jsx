@@ -2,6 +2,7 @@ import { useState, useEffect } from 'react';
 import ReportModal from '../student/components/ReportModal';
 import Pagination from '../common/Pagination';
 import DateRangeReport from '../common/DateRangeReport';
+import FeedbackPanel from './FeedbackPanel';
 import {
     Chart as ChartJS,
     CategoryScale,
@@ -32,10 +33,12 @@ export default function TeacherStatistics() {
     const [sortField, setSortField] = useState('check_date'); // 'check_date' or 'score'
     const [sortDirection, setSortDirection] = useState('desc'); // 'asc' or 'desc'
     const [selectedStandard, setSelectedStandard] = useState('all'); // Filter by standard
+    const [selectedGroup, setSelectedGroup] = useState('all'); // Filter by group of the work
     const [currentPage, setCurrentPage] = useState(1);
     const itemsPerPage = 10;
     const [isReportOpen, setIsReportOpen] = useState(false);
     const [reportData, setReportData] = useState(null);
+    const [selectedIds, setSelectedIds] = useState([]);
 
     useEffect(() => {
         fetchHistory();
@@ -71,6 +74,10 @@ export default function TeacherStatistics() {
         }
     };
 
+    const toggleSelected = (uuid) => {
+        setSelectedIds(ids => ids.includes(uuid) ? ids.filter(id => id !== uuid) : [...ids, uuid]);
+    };
+
     const handleSort = (field) => {
         // If clicking the same field, toggle direction
         if (sortField === field) {
@@ -115,12 +122,16 @@ export default function TeacherStatistics() {
     // Filter Logic - by search AND standard
     const filteredHistory = history.filter(item => {
         const query = searchQuery.toLowerCase();
+        const meta = item.metadata || {};
         const matchesSearch = (item.student_name && item.student_name.toLowerCase().includes(query)) ||
-            (item.standard_name && item.standard_name.toLowerCase().includes(query));
+            (item.standard_name && item.standard_name.toLowerCase().includes(query)) ||
+            (meta.topic && meta.topic.toLowerCase().includes(query)) ||
+            (meta.supervisor && meta.supervisor.toLowerCase().includes(query));
 
         const matchesStandard = selectedStandard === 'all' || item.standard_name === selectedStandard;
+        const matchesGroup = selectedGroup === 'all' || meta.group_name === selectedGroup;
 
-        return matchesSearch && matchesStandard;
+        return matchesSearch && matchesStandard && matchesGroup;
     });
 
     // Sort Logic
@@ -144,6 +155,7 @@ export default function TeacherStatistics() {
 
     // Analytics Calculations
     const uniqueStandards = ['all', ...new Set(history.map(item => item.standard_name))];
+    const uniqueGroups = ['all', ...new Set(history.map(item => item.metadata?.group_name).filter(Boolean))];
 
     const avgScore = filteredHistory.length > 0
         ? (filteredHistory.reduce((sum, item) => sum + item.score, 0) / filteredHistory.length).toFixed(1)
@@ -350,6 +362,20 @@ export default function TeacherStatistics() {
                         </option>
                     ))}
                 </select>
+                {uniqueGroups.length > 1 && (
+                    <select
+                        className="input-field"
+                        value={selectedGroup}
+                        onChange={(e) => setSelectedGroup(e.target.value)}
+                        style={{ border: 'none', borderLeft: '1px solid black', height: '60px', fontSize: '1rem', textTransform: 'uppercase' }}
+                    >
+                        {uniqueGroups.map(group => (
+                            <option key={group} value={group}>
+                                {group === 'all' ? 'ВСЕ ГРУППЫ' : group}
+                            </option>
+                        ))}
+                    </select>
+                )}
                 <button
                     onClick={() => setIsReportOpen(true)}
                     className="btn btn-primary"
@@ -449,9 +475,20 @@ export default function TeacherStatistics() {
                 </div>
             </div>
 
+            {selectedIds.length > 0 && (
+                <FeedbackPanel selectedIds={selectedIds} onSent={() => setSelectedIds([])} />
+            )}
+
             {/* Table */}
             <div style={{ display: 'grid', gridTemplateColumns: '1fr', gap: '0', border: '1px solid black' }}>
-                <div style={{ display: 'grid', gridTemplateColumns: '2fr 2fr 1.5fr 1fr 1fr', padding: '1rem 2rem', background: '#F4F4F4', fontWeight: 700, textTransform: 'uppercase', fontSize: '0.85rem', letterSpacing: '0.05em', borderBottom: '1px solid black' }}>
+                <div style={{ display: 'grid', gridTemplateColumns: '40px 2fr 2fr 1.5fr 1fr 1fr', padding: '1rem 2rem', background: '#F4F4F4', fontWeight: 700, textTransform: 'uppercase', fontSize: '0.85rem', letterSpacing: '0.05em', borderBottom: '1px solid black' }}>
+                    <div>
+                        <input
+                            type="checkbox"
+                            checked={sortedHistory.length > 0 && selectedIds.length === sortedHistory.length}
+                            onChange={e => setSelectedIds(e.target.checked ? sortedHistory.map(item => item.uuid) : [])}
+                        />
+                    </div>
                     <div>Студент</div>
                     <div>Стандарт</div>
                     <div
@@ -473,7 +510,7 @@ export default function TeacherStatistics() {
                         key={item.id}
                         style={{
                             display: 'grid',
-                            gridTemplateColumns: '2fr 2fr 1.5fr 1fr 1fr',
+                            gridTemplateColumns: '40px 2fr 2fr 1.5fr 1fr 1fr',
                             padding: '1.5rem 2rem',
                             borderBottom: '1px solid #E5E5E5',
                             alignItems: 'center',
@@ -483,7 +520,15 @@ export default function TeacherStatistics() {
                         onMouseEnter={e => e.currentTarget.style.background = '#FAFAFA'}
                         onMouseLeave={e => e.currentTarget.style.background = 'white'}
                     >
-                        <div style={{ fontWeight: 600 }}>{item.student_name || 'Неизвестно'}</div>
+                        <div>
+                            <input type="checkbox" checked={selectedIds.includes(item.uuid)} onChange={() => toggleSelected(item.uuid)} />
+                        </div>
+                        <div>
+                            <div style={{ fontWeight: 600 }}>{item.student_name || 'Неизвестно'}</div>
+                            {item.metadata?.topic && (
+                                <div style={{ fontSize: '0.8rem', color: COLORS.textDim }}>{item.metadata.topic}</div>
+                            )}
+                        </div>
                         <div style={{ fontSize: '0.9rem', color: COLORS.textDim }}>{item.standard_name}</div>
                         <div style={{ fontSize: '0.85rem', fontFamily: 'JetBrains Mono', color: COLORS.textDim, textAlign: 'center' }}>{new Date(item.check_date).toLocaleDateString()}</div>
                         <div style={{ textAlign: 'center' }}>
@@ -493,7 +538,7 @@ export default function TeacherStatistics() {
                         </div>
                         <div style={{ textAlign: 'center' }}>
                             <button
-                                onClick={() => handleViewDetail(item.id)}
+                                onClick={() => handleViewDetail(item.uuid)}
                                 style={{
                                     background: 'none',
                                     border: 'none',
@@ -540,9 +585,12 @@ export default function TeacherStatistics() {
                 isOpen={!!selectedCheck}
                 onClose={() => setSelectedCheck(null)}
                 documentName={selectedCheck ? `${selectedCheck.student_name}: ${selectedCheck.standard_name}` : 'Отчет'}
+                resultId={selectedCheck?.uuid}
                 score={selectedCheck?.score}
+                summary={selectedCheck?.summary}
                 contentJSON={selectedCheck?.content_json}
                 violations={selectedCheck?.violations}
+                comments={selectedCheck?.comments}
             />
         </div>
     );